@@ -57,7 +57,12 @@ func main() {
 			used_quota BIGINT NOT NULL DEFAULT 0,
 			is_admin BOOLEAN NOT NULL DEFAULT false,
 			status VARCHAR(50) NOT NULL DEFAULT 'active',
-			last_sign_in TIMESTAMP
+			last_sign_in TIMESTAMP,
+			overdraft_limit BIGINT,
+			stream_precharge BOOLEAN,
+			aggregate_rate_limit INTEGER NOT NULL DEFAULT 0,
+			aggregate_rate_window INTEGER NOT NULL DEFAULT 60,
+			aggregate_rate_unit VARCHAR(20) NOT NULL DEFAULT 'requests'
 		)
 	`).Error
 	if err != nil {
@@ -78,7 +83,28 @@ func main() {
 			name VARCHAR(255) NOT NULL,
 			is_active BOOLEAN NOT NULL DEFAULT true,
 			rate_limit INTEGER NOT NULL DEFAULT 60,
-			last_used_at TIMESTAMP
+			last_used_at TIMESTAMP,
+			redaction_enabled BOOLEAN NOT NULL DEFAULT false,
+			redaction_patterns JSONB DEFAULT '[]',
+			log_requests BOOLEAN NOT NULL DEFAULT true,
+			metadata JSONB DEFAULT '{}',
+			length_routing JSONB DEFAULT '[]',
+			rate_limit_per_hour INTEGER NOT NULL DEFAULT 0,
+			rate_limit_per_day INTEGER NOT NULL DEFAULT 0,
+			param_overrides JSONB DEFAULT '{}',
+			stream_pacing_tps INTEGER NOT NULL DEFAULT 0,
+			routing_mode VARCHAR(20) NOT NULL DEFAULT '',
+			semantic_cache_disabled BOOLEAN NOT NULL DEFAULT false,
+			max_candidates INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP,
+			deleted_at TIMESTAMP,
+			max_history_messages INTEGER NOT NULL DEFAULT 0,
+			history_limit_policy VARCHAR(20) NOT NULL DEFAULT '',
+			max_context_tokens INTEGER NOT NULL DEFAULT 0,
+			context_truncation VARCHAR(20) NOT NULL DEFAULT '',
+			error_format VARCHAR(20) NOT NULL DEFAULT '',
+			error_template TEXT,
+			suspended BOOLEAN NOT NULL DEFAULT false
 		)
 	`).Error
 	if err != nil {
@@ -99,6 +125,7 @@ func main() {
 			base_url TEXT,
 			api_key TEXT,
 			account_pool_id INTEGER,
+			account_pools JSONB,
 			models JSONB NOT NULL DEFAULT '[]',
 			headers JSONB,
 			metadata JSONB,
@@ -106,7 +133,35 @@ func main() {
 			priority INTEGER NOT NULL DEFAULT 100,
 			weight INTEGER NOT NULL DEFAULT 1,
 			max_rps INTEGER NOT NULL DEFAULT 0,
-			timeout INTEGER NOT NULL DEFAULT 30
+			timeout INTEGER NOT NULL DEFAULT 30,
+			log_requests BOOLEAN NOT NULL DEFAULT true,
+			user_agent VARCHAR(512),
+			context_truncation VARCHAR(20),
+			context_window INTEGER NOT NULL DEFAULT 0,
+			tool_result_max_tokens INTEGER NOT NULL DEFAULT 0,
+			tool_result_policy VARCHAR(20) NOT NULL DEFAULT '',
+			stream_idle_timeout INTEGER NOT NULL DEFAULT 0,
+			upstream_keys JSONB,
+			prefix_caching BOOLEAN NOT NULL DEFAULT false,
+			max_tools INTEGER NOT NULL DEFAULT 0,
+			tool_limit_policy VARCHAR(20),
+			max_history_messages INTEGER NOT NULL DEFAULT 0,
+			history_limit_policy VARCHAR(20),
+			retry_empty_response BOOLEAN NOT NULL DEFAULT false,
+			health_weight_decay BOOLEAN NOT NULL DEFAULT false,
+			read_only BOOLEAN NOT NULL DEFAULT false,
+			model_aliases JSONB,
+			capabilities JSONB,
+			required_capabilities JSONB,
+			canary_percent INTEGER NOT NULL DEFAULT 0,
+			canary_ramp_minutes INTEGER NOT NULL DEFAULT 0,
+			canary_started_at TIMESTAMP,
+			tls_settings JSONB,
+			max_concurrency INTEGER NOT NULL DEFAULT 0,
+			concurrency_weights JSONB,
+			forward_header_allowlist JSONB,
+			forward_header_denylist JSONB,
+			trace_sample_rate DOUBLE PRECISION
 		)
 	`).Error
 	if err != nil {
@@ -144,7 +199,9 @@ func main() {
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-			quota_awarded INTEGER NOT NULL
+			quota_awarded INTEGER NOT NULL,
+			streak INTEGER NOT NULL DEFAULT 1,
+			streak_bonus INTEGER NOT NULL DEFAULT 0
 		)
 	`).Error
 	if err != nil {
@@ -177,6 +234,28 @@ func main() {
 	}
 	fmt.Println("  ✓ pricings")
 
+	// 创建 pricing_templates 表 - 模型默认定价表
+	// 对应模型：backend/internal/domain/pricing/model.go - PricingTemplate
+	// 配置没有单独定价时按模型名使用此表的定价
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS pricing_templates (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			model_name VARCHAR(255) NOT NULL UNIQUE,
+			input_price DOUBLE PRECISION NOT NULL DEFAULT 0,
+			output_price DOUBLE PRECISION NOT NULL DEFAULT 0,
+			currency VARCHAR(20) NOT NULL DEFAULT 'credits',
+			unit INTEGER NOT NULL DEFAULT 1000,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			description VARCHAR(500)
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create pricing_templates table: %v", err)
+	}
+	fmt.Println("  ✓ pricing_templates")
+
 	// ==================== 日志和缓存表 ====================
 
 	// 创建 request_logs 表 - 请求日志表
@@ -187,7 +266,7 @@ func main() {
 		CREATE TABLE IF NOT EXISTS request_logs (
 			id SERIAL PRIMARY KEY,
 			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
 			api_key_id INTEGER NOT NULL,
 			api_config_id INTEGER NOT NULL,
 			model VARCHAR(255) NOT NULL,
@@ -196,6 +275,14 @@ func main() {
 			status_code INTEGER NOT NULL,
 			response_time INTEGER NOT NULL,
 			tokens_used INTEGER NOT NULL DEFAULT 0,
+			quota_cost BIGINT NOT NULL DEFAULT 0,
+			request_bytes BIGINT NOT NULL DEFAULT 0,
+			response_bytes BIGINT NOT NULL DEFAULT 0,
+			provider VARCHAR(50),
+			service_tier VARCHAR(20),
+			tools_dropped INTEGER NOT NULL DEFAULT 0,
+			history_dropped INTEGER NOT NULL DEFAULT 0,
+			upstream_request_id VARCHAR(255),
 			error_msg TEXT
 		)
 	`).Error
@@ -204,6 +291,73 @@ func main() {
 	}
 	fmt.Println("  ✓ request_logs")
 
+	// 创建 quota_ledger 表 - 配额账本表
+	// 对应模型：backend/internal/domain/quota/model.go - QuotaLedger
+	// 外键关系：
+	//   - user_id -> users(id) ON DELETE CASCADE
+	//   - request_log_id -> request_logs(id) ON DELETE SET NULL (保留账本)
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_ledger (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			type VARCHAR(50) NOT NULL,
+			amount BIGINT NOT NULL,
+			reason TEXT,
+			request_log_id INTEGER REFERENCES request_logs(id) ON DELETE SET NULL,
+			request_id VARCHAR(255),
+			operator_id INTEGER
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create quota_ledger table: %v", err)
+	}
+	fmt.Println("  ✓ quota_ledger")
+
+	// 创建 usage_counters 表 - 聚合用量计数表
+	// 对应模型：backend/internal/domain/log/model.go - UsageCounter
+	// 用于关闭请求日志（log_requests=false）的配置或密钥，只保留按天聚合的计数
+	// 唯一约束：(date, user_id, api_key_id, api_config_id, model)
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_counters (
+			id SERIAL PRIMARY KEY,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			date DATE NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			api_key_id INTEGER NOT NULL,
+			api_config_id INTEGER NOT NULL,
+			model VARCHAR(255) NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			errors BIGINT NOT NULL DEFAULT 0,
+			tokens BIGINT NOT NULL DEFAULT 0,
+			quota_cost BIGINT NOT NULL DEFAULT 0,
+			UNIQUE (date, user_id, api_key_id, api_config_id, model)
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create usage_counters table: %v", err)
+	}
+	fmt.Println("  ✓ usage_counters")
+
+	// 创建 usage_buckets 表 - API Key 用量分桶表
+	// 对应模型：backend/internal/domain/log/model.go - UsageBucket
+	// 关闭请求日志的流量按 5 分钟分桶累加，供用量异常检测统计
+	// 唯一约束：(bucket_start, user_id, api_key_id)
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_buckets (
+			bucket_start TIMESTAMP NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			api_key_id INTEGER NOT NULL,
+			requests BIGINT NOT NULL DEFAULT 0,
+			quota_cost BIGINT NOT NULL DEFAULT 0,
+			UNIQUE (bucket_start, user_id, api_key_id)
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create usage_buckets table: %v", err)
+	}
+	fmt.Println("  ✓ usage_buckets")
+
 	// 创建 request_caches 表 - 请求缓存表
 	// 对应模型：backend/internal/domain/cache/model.go - RequestCache
 	// 外键关系：user_id -> users(id) ON DELETE CASCADE
@@ -221,8 +375,12 @@ func main() {
 			request TEXT NOT NULL,
 			response TEXT NOT NULL,
 			tokens_saved INTEGER NOT NULL DEFAULT 0,
+			prompt_tokens INTEGER NOT NULL DEFAULT 0,
+			completion_tokens INTEGER NOT NULL DEFAULT 0,
+			total_tokens INTEGER NOT NULL DEFAULT 0,
 			hit_count INTEGER NOT NULL DEFAULT 0,
-			expires_at TIMESTAMP NOT NULL
+			expires_at TIMESTAMP NOT NULL,
+			stale_until TIMESTAMP
 		)
 	`).Error
 	if err != nil {
@@ -262,6 +420,8 @@ func main() {
 			description TEXT,
 			provider_type VARCHAR(50) NOT NULL,
 			strategy VARCHAR(50) NOT NULL DEFAULT 'round_robin',
+			rotation_requests INTEGER NOT NULL DEFAULT 0,
+			rotation_interval INTEGER NOT NULL DEFAULT 0,
 			health_check_interval INTEGER NOT NULL DEFAULT 300,
 			health_check_timeout INTEGER NOT NULL DEFAULT 10,
 			max_retries INTEGER NOT NULL DEFAULT 3,
@@ -292,6 +452,7 @@ func main() {
 			expires_at TIMESTAMP,
 			metadata JSONB DEFAULT '{}',
 			weight INTEGER NOT NULL DEFAULT 1,
+			allowed_models JSONB DEFAULT '[]',
 			is_active BOOLEAN NOT NULL DEFAULT true,
 			health_status VARCHAR(50) DEFAULT 'unknown',
 			last_error TEXT,
@@ -335,8 +496,138 @@ func main() {
 	}
 	fmt.Println("  ✓ account_pool_request_logs")
 
+	// 创建 audit_logs 表 - 管理操作审计日志
+	// 对应模型：backend/internal/domain/user/model.go - AuditLog
+	// 不设外键，用户删除后审计记录仍需保留
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_logs (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			operator_id INTEGER NOT NULL,
+			action VARCHAR(100) NOT NULL,
+			target_type VARCHAR(50) NOT NULL,
+			target_id INTEGER NOT NULL,
+			details JSONB
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create audit_logs table: %v", err)
+	}
+	fmt.Println("  ✓ audit_logs")
+
+	// 创建 user_preferences 表 - 用户级默认模型、供应商偏好和请求参数
+	// 对应模型：backend/internal/domain/user/preferences.go - Preferences
+	// 外键关系：user_id -> users(id) ON DELETE CASCADE
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_preferences (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id INTEGER NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+			default_model VARCHAR(100) NOT NULL DEFAULT '',
+			provider_preference JSONB NOT NULL DEFAULT '[]',
+			temperature DOUBLE PRECISION,
+			top_p DOUBLE PRECISION,
+			max_tokens INTEGER
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create user_preferences table: %v", err)
+	}
+	fmt.Println("  ✓ user_preferences")
+
+	// 创建 anomaly_flags 表 - 用量异常标记
+	// 对应模型：backend/internal/domain/anomaly/model.go - Flag
+	// 不设外键，密钥或用户删除后标记仍需保留
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS anomaly_flags (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			scope VARCHAR(20) NOT NULL,
+			api_key_id INTEGER NOT NULL DEFAULT 0,
+			user_id INTEGER NOT NULL DEFAULT 0,
+			metric VARCHAR(20) NOT NULL,
+			observed BIGINT NOT NULL DEFAULT 0,
+			baseline DOUBLE PRECISION NOT NULL DEFAULT 0,
+			ratio DOUBLE PRECISION NOT NULL DEFAULT 0,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			suspended BOOLEAN NOT NULL DEFAULT false,
+			cleared_at TIMESTAMP,
+			cleared_by INTEGER,
+			note TEXT
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create anomaly_flags table: %v", err)
+	}
+	fmt.Println("  ✓ anomaly_flags")
+
+	// 创建 quota_holds 表 - 配额预留
+	// 对应模型：backend/internal/domain/quota/model.go - QuotaHold
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_holds (
+			id VARCHAR(40) PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			amount BIGINT NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'held',
+			expires_at TIMESTAMP NOT NULL,
+			settled_at TIMESTAMP
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create quota_holds table: %v", err)
+	}
+	fmt.Println("  ✓ quota_holds")
+
+	// 创建 model_metadata 表 - 模型元数据（弃用状态、替代模型）
+	// 对应模型：backend/internal/domain/modelmeta/model.go - ModelMetadata
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS model_metadata (
+			model VARCHAR(255) PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			deprecated BOOLEAN NOT NULL DEFAULT false,
+			replacement VARCHAR(255),
+			deprecation_message TEXT,
+			sunset_at TIMESTAMP
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create model_metadata table: %v", err)
+	}
+	fmt.Println("  ✓ model_metadata")
+
+	// 创建 prompt_blocklist 表 - 提示词屏蔽规则（指纹精确匹配或正则）
+	// 对应模型：backend/internal/domain/promptblock/model.go - PromptBlock
+	err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS prompt_blocklist (
+			id SERIAL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			type VARCHAR(10) NOT NULL,
+			pattern TEXT NOT NULL,
+			reason TEXT,
+			is_active BOOLEAN NOT NULL DEFAULT true,
+			hit_count BIGINT NOT NULL DEFAULT 0,
+			last_hit_at TIMESTAMP
+		)
+	`).Error
+	if err != nil {
+		log.Fatalf("❌ Failed to create prompt_blocklist table: %v", err)
+	}
+	fmt.Println("  ✓ prompt_blocklist")
+
 	fmt.Println("✅ All tables created successfully")
 
+	// 为已存在的表补充新增列
+	fmt.Println("\n🔄 Adding missing columns...")
+	addMissingColumns(db)
+	fmt.Println("✅ All columns are up to date")
+
 	// 创建索引
 	fmt.Println("\n🔄 Creating indexes...")
 	createIndexes(db)
@@ -359,6 +650,110 @@ func main() {
 	fmt.Println("   3. Configure API providers in the admin panel")
 }
 
+// addMissingColumns 为旧版本创建的表补充新增列
+func addMissingColumns(db *gorm.DB) {
+	columns := []string{
+		// ==================== users 表 ====================
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS overdraft_limit BIGINT",
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS stream_precharge BOOLEAN",
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS aggregate_rate_limit INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS aggregate_rate_window INTEGER NOT NULL DEFAULT 60",
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS aggregate_rate_unit VARCHAR(20) NOT NULL DEFAULT 'requests'",
+
+		// ==================== api_keys 表 ====================
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS redaction_enabled BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS redaction_patterns JSONB DEFAULT '[]'",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS log_requests BOOLEAN NOT NULL DEFAULT true",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS metadata JSONB DEFAULT '{}'",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS length_routing JSONB DEFAULT '[]'",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rate_limit_per_hour INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS rate_limit_per_day INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS param_overrides JSONB DEFAULT '{}'",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS stream_pacing_tps INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS routing_mode VARCHAR(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS semantic_cache_disabled BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS max_candidates INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS max_history_messages INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS history_limit_policy VARCHAR(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS max_context_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS context_truncation VARCHAR(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS error_format VARCHAR(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS error_template TEXT",
+		"ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS suspended BOOLEAN NOT NULL DEFAULT false",
+
+		// ==================== api_configs 表 ====================
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS log_requests BOOLEAN NOT NULL DEFAULT true",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS user_agent VARCHAR(512)",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS context_truncation VARCHAR(20)",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS context_window INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS tool_result_max_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS tool_result_policy VARCHAR(20) NOT NULL DEFAULT ''",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS stream_idle_timeout INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS upstream_keys JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS prefix_caching BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS max_tools INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS tool_limit_policy VARCHAR(20)",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS max_history_messages INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS history_limit_policy VARCHAR(20)",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS retry_empty_response BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS model_aliases JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS capabilities JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS required_capabilities JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS canary_percent INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS canary_ramp_minutes INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS canary_started_at TIMESTAMP",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS tls_settings JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS account_pools JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS health_weight_decay BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS read_only BOOLEAN NOT NULL DEFAULT false",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS max_concurrency INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS concurrency_weights JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS forward_header_allowlist JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS forward_header_denylist JSONB",
+		"ALTER TABLE api_configs ADD COLUMN IF NOT EXISTS trace_sample_rate DOUBLE PRECISION",
+
+		// ==================== account_pools 表 ====================
+		"ALTER TABLE account_pools ADD COLUMN IF NOT EXISTS rotation_requests INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE account_pools ADD COLUMN IF NOT EXISTS rotation_interval INTEGER NOT NULL DEFAULT 0",
+
+		// ==================== account_credentials 表 ====================
+		"ALTER TABLE account_credentials ADD COLUMN IF NOT EXISTS allowed_models JSONB DEFAULT '[]'",
+
+		// ==================== sign_in_records 表 ====================
+		"ALTER TABLE sign_in_records ADD COLUMN IF NOT EXISTS streak INTEGER NOT NULL DEFAULT 1",
+		"ALTER TABLE sign_in_records ADD COLUMN IF NOT EXISTS streak_bonus INTEGER NOT NULL DEFAULT 0",
+
+		// ==================== request_caches 表 ====================
+		"ALTER TABLE request_caches ADD COLUMN IF NOT EXISTS stale_until TIMESTAMP",
+		"ALTER TABLE request_caches ADD COLUMN IF NOT EXISTS prompt_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE request_caches ADD COLUMN IF NOT EXISTS completion_tokens INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE request_caches ADD COLUMN IF NOT EXISTS total_tokens INTEGER NOT NULL DEFAULT 0",
+
+		// ==================== request_logs 表 ====================
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS quota_cost BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS request_bytes BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS response_bytes BIGINT NOT NULL DEFAULT 0",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS provider VARCHAR(50)",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS service_tier VARCHAR(20)",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS tools_dropped INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS history_dropped INTEGER NOT NULL DEFAULT 0",
+		"ALTER TABLE request_logs ADD COLUMN IF NOT EXISTS upstream_request_id VARCHAR(255)",
+		// 数据清除（GDPR）时匿名化日志需要将 user_id 置空
+		"ALTER TABLE request_logs ALTER COLUMN user_id DROP NOT NULL",
+
+		// ==================== quota_ledger 表 ====================
+		"ALTER TABLE quota_ledger ADD COLUMN IF NOT EXISTS request_id VARCHAR(255)",
+	}
+
+	for _, col := range columns {
+		if err := db.Exec(col).Error; err != nil {
+			log.Printf("  ⚠️  Warning: Failed to add column: %v", err)
+		}
+	}
+}
+
 // createIndexes 创建所有索引以优化查询性能
 func createIndexes(db *gorm.DB) {
 	indexes := []string{
@@ -372,6 +767,8 @@ func createIndexes(db *gorm.DB) {
 		"CREATE INDEX IF NOT EXISTS idx_api_keys_key ON api_keys(key)",
 		"CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_api_keys_user_active ON api_keys(user_id, is_active)",
+		"CREATE INDEX IF NOT EXISTS idx_api_keys_expires_at ON api_keys(expires_at) WHERE expires_at IS NOT NULL",
+		"CREATE INDEX IF NOT EXISTS idx_api_keys_deleted_at ON api_keys(deleted_at)",
 
 		// ==================== api_configs 表索引 ====================
 		"CREATE INDEX IF NOT EXISTS idx_api_configs_type ON api_configs(type)",
@@ -405,6 +802,26 @@ func createIndexes(db *gorm.DB) {
 		// 复合索引优化常见查询
 		"CREATE INDEX IF NOT EXISTS idx_request_logs_user_model_created ON request_logs(user_id, model, created_at DESC)",
 
+		// ==================== quota_ledger 表索引 ====================
+		"CREATE INDEX IF NOT EXISTS idx_quota_ledger_user_created ON quota_ledger(user_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_quota_ledger_request_log_id ON quota_ledger(request_log_id) WHERE request_log_id IS NOT NULL",
+		"CREATE INDEX IF NOT EXISTS idx_quota_ledger_user_request_id ON quota_ledger(user_id, request_id) WHERE request_id IS NOT NULL",
+
+		// ==================== audit_logs 表索引 ====================
+		"CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs(target_type, target_id)",
+		"CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at DESC)",
+
+		// ==================== anomaly_flags 表索引 ====================
+		"CREATE INDEX IF NOT EXISTS idx_anomaly_flags_open ON anomaly_flags(scope, api_key_id, user_id, metric) WHERE cleared_at IS NULL",
+		"CREATE INDEX IF NOT EXISTS idx_anomaly_flags_created_at ON anomaly_flags(created_at DESC)",
+
+		// ==================== quota_holds 表索引 ====================
+		"CREATE INDEX IF NOT EXISTS idx_quota_holds_user_id ON quota_holds(user_id)",
+		"CREATE INDEX IF NOT EXISTS idx_quota_holds_expires_at ON quota_holds(expires_at) WHERE status = 'held'",
+
+		// ==================== usage_counters 表索引 ====================
+		"CREATE INDEX IF NOT EXISTS idx_usage_counters_user_date ON usage_counters(user_id, date DESC)",
+
 		// ==================== sign_in_records 表索引 ====================
 		"CREATE INDEX IF NOT EXISTS idx_sign_in_records_user_id ON sign_in_records(user_id)",
 		"CREATE INDEX IF NOT EXISTS idx_sign_in_records_user_created ON sign_in_records(user_id, created_at DESC)",
@@ -415,6 +832,7 @@ func createIndexes(db *gorm.DB) {
 		"CREATE INDEX IF NOT EXISTS idx_request_caches_cache_key ON request_caches(cache_key)",
 		"CREATE INDEX IF NOT EXISTS idx_request_caches_model ON request_caches(model)",
 		"CREATE INDEX IF NOT EXISTS idx_request_caches_expires_at ON request_caches(expires_at)",
+		"CREATE INDEX IF NOT EXISTS idx_request_caches_stale_until ON request_caches(stale_until) WHERE stale_until IS NOT NULL",
 		// 清理过期缓存优化
 		"CREATE INDEX IF NOT EXISTS idx_request_caches_expires_user ON request_caches(expires_at, user_id)",
 		// 缓存命中统计优化
@@ -470,12 +888,44 @@ func insertDefaultSettings(db *gorm.DB) {
 			('runtime.cache_ttl', '3600', 'int', 'Cache TTL in seconds', true, NOW(), NOW()),
 			('runtime.semantic_cache_enabled', 'false', 'bool', 'Enable semantic cache matching', true, NOW(), NOW()),
 			('runtime.semantic_threshold', '0.85', 'float', 'Semantic matching threshold (0.0-1.0)', true, NOW(), NOW()),
+			('runtime.cache_key_normalize', 'true', 'bool', 'Normalize whitespace and defaulted params before computing cache keys', true, NOW(), NOW()),
+			('runtime.cache_stale_while_revalidate', '0', 'int', 'Seconds after expiry a cached response is still served while refreshing in the background (0 disables)', true, NOW(), NOW()),
+			('runtime.cache_refresh_billed', 'true', 'bool', 'Bill the user for background cache refreshes', true, NOW(), NOW()),
 			('runtime.embedding_enabled', 'false', 'bool', 'Enable embedding service', true, NOW(), NOW()),
 			('runtime.embedding_url', 'http://localhost:8765', 'string', 'Embedding service URL', true, NOW(), NOW()),
 			('runtime.embedding_timeout', '30', 'int', 'Embedding service timeout in seconds', true, NOW(), NOW()),
 			('runtime.max_retries', '3', 'int', 'Maximum retry attempts', true, NOW(), NOW()),
 			('runtime.timeout', '30', 'int', 'Request timeout in seconds', true, NOW(), NOW()),
 			('runtime.enable_load_balance', 'true', 'bool', 'Enable load balancing', true, NOW(), NOW()),
+			('runtime.stream_quota_check_tokens', '200', 'int', 'Check remaining quota every N estimated output tokens while streaming (0 = disabled)', true, NOW(), NOW()),
+			('runtime.stream_max_duration', '0', 'int', 'Maximum seconds a stream may stay open, even while still producing output; delivered tokens are billed (0 = unlimited)', true, NOW(), NOW()),
+			('runtime.stream_fallback_emulate', 'true', 'bool', 'Serve streaming requests to providers without streaming support with a single emulated chunk instead of an error', true, NOW(), NOW()),
+			('runtime.bill_refusals', 'true', 'bool', 'Bill non-streaming responses the provider refused on content-policy grounds (finish_reason content_filter) for the tokens used', true, NOW(), NOW()),
+			('runtime.routing_diagnostics', 'false', 'bool', 'Allow requests sending X-Prism-Routing: true to receive a prism_routing extension describing the selected provider, strategy, cache hit and retries', true, NOW(), NOW()),
+			('runtime.dynamic_timeout_enabled', 'false', 'bool', 'Derive the non-streaming upstream timeout from the model''s recent p99 latency in request_logs instead of the static config timeout', true, NOW(), NOW()),
+			('runtime.dynamic_timeout_multiplier', '3', 'float', 'Multiplier applied to the model''s recent p99 latency to get the dynamic upstream timeout', true, NOW(), NOW()),
+			('runtime.dynamic_timeout_min', '10', 'int', 'Lower bound in seconds for the dynamic upstream timeout', true, NOW(), NOW()),
+			('runtime.dynamic_timeout_max', '600', 'int', 'Upper bound in seconds for the dynamic upstream timeout', true, NOW(), NOW()),
+			('runtime.flex_tier_price_multiplier', '1', 'float', 'Billing multiplier for requests sent with service_tier=flex', true, NOW(), NOW()),
+			('runtime.stream_precharge', 'false', 'bool', 'Reserve quota for max_tokens before a stream starts, for users without their own setting', true, NOW(), NOW()),
+			('runtime.stream_precharge_ratio', '1', 'float', 'Fraction of the max_tokens cost reserved before a stream starts; unused reservation is released on completion', true, NOW(), NOW()),
+			('runtime.priority_tier_price_multiplier', '1', 'float', 'Billing multiplier for requests sent with service_tier=priority', true, NOW(), NOW()),
+			('runtime.request_schema_validation', 'true', 'bool', 'Validate proxy request bodies against the protocol schema before processing', true, NOW(), NOW()),
+			('runtime.request_max_messages', '10000', 'int', 'Reject proxy requests with more messages than this while the body is still being read (0 = unlimited)', true, NOW(), NOW()),
+			('runtime.request_disallow_unknown_fields', 'false', 'bool', 'Reject proxy requests with top-level fields unknown to the protocol and the gateway', true, NOW(), NOW()),
+			('runtime.role_aliases', 'human:user,ai:assistant,bot:assistant', 'string', 'Comma-separated alias:role pairs mapping non-standard message roles to user, assistant or system before validation; other unknown roles are rejected (empty = disabled)', true, NOW(), NOW()),
+			('runtime.expired_key_retention_days', '30', 'int', 'Days an expired API key is kept before the background sweeper soft-deletes it (0 = never)', true, NOW(), NOW()),
+			('runtime.prefix_cache_min_tokens', '1024', 'int', 'Minimum estimated tokens of a repeated conversation prefix before it is cached', true, NOW(), NOW()),
+			('runtime.prefix_cache_ttl', '300', 'int', 'Seconds a cached conversation prefix stays valid without being reused', true, NOW(), NOW()),
+			('runtime.pool_near_limit_percent', '10', 'int', 'Account pool credentials with less than this percent of their upstream rate limit remaining are used only when no other credential is available', true, NOW(), NOW()),
+			('runtime.pool_min_healthy_credentials', '2', 'int', 'Send an alert when an account pool has fewer healthy credentials than this (0 = disabled)', true, NOW(), NOW()),
+			('runtime.pool_credential_grace_period', '600', 'int', 'Seconds an expired credential whose refresh failed is still used (flagged degraded) before it is disabled (0 = disable immediately)', true, NOW(), NOW()),
+			('runtime.echo_model_enabled', 'false', 'bool', 'Enable the built-in prism-echo test model (non-production only)', true, NOW(), NOW()),
+			('runtime.echo_model_latency_ms', '0', 'int', 'Simulated latency of prism-echo in milliseconds', true, NOW(), NOW()),
+			('runtime.echo_model_transform', '', 'string', 'Transform applied to the echoed message: empty, upper or reverse', true, NOW(), NOW()),
+			('runtime.echo_model_completion_tokens', '0', 'int', 'Completion tokens reported by prism-echo (0 = estimate from content)', true, NOW(), NOW()),
+			('runtime.alert_webhook_url', '', 'string', 'Webhook URL for operational alerts', true, NOW(), NOW()),
+			('runtime.payload_alert_bytes', '0', 'int', 'Alert when a request or response body exceeds this many bytes (0 = disabled)', true, NOW(), NOW()),
 			
 			-- 系统配置
 			('system.site_name', 'Prism API', 'string', 'Site name', false, NOW(), NOW()),
@@ -487,11 +937,44 @@ func insertDefaultSettings(db *gorm.DB) {
 			('default_quota.daily', '100000', 'int', 'Default user quota', false, NOW(), NOW()),
 			('default_quota.monthly', '30000', 'int', 'Default monthly quota (deprecated)', false, NOW(), NOW()),
 			('default_quota.total', '0', 'int', 'Default total quota (deprecated)', false, NOW(), NOW()),
+			('default_quota.overdraft', '0', 'int', 'Quota a user may go below zero before requests are blocked, settled by the next grant (0 = disabled)', false, NOW(), NOW()),
+			
+			-- 签到奖励
+			('sign_in.base_quota', '1000', 'int', 'Base quota granted by daily sign-in', false, NOW(), NOW()),
+			('sign_in.streak_bonus_percent', '10', 'int', 'Extra percent of the base grant per consecutive sign-in day', false, NOW(), NOW()),
+			('sign_in.streak_cap_days', '7', 'int', 'Maximum streak length counted towards the bonus', false, NOW(), NOW()),
+			('sign_in.max_quota', '2000', 'int', 'Maximum quota granted by a single sign-in (0 = unlimited)', false, NOW(), NOW()),
+			
+			-- 登录、注册接口限流
+			('auth_rate_limit.per_minute', '20', 'int', 'Requests per minute allowed per IP on the login and register endpoints (0 = unlimited)', false, NOW(), NOW()),
+			('auth_rate_limit.max_login_failures', '5', 'int', 'Consecutive failed logins before the username and IP are locked out (0 = never)', false, NOW(), NOW()),
+			('auth_rate_limit.lockout_seconds', '60', 'int', 'Duration of the first login lockout in seconds, doubled on each repeat', false, NOW(), NOW()),
+			('auth_rate_limit.max_lockout_seconds', '3600', 'int', 'Maximum login lockout duration in seconds', false, NOW(), NOW()),
 			
 			-- 默认速率限制
 			('default_rate_limit.per_minute', '60', 'int', 'Default rate limit per minute', false, NOW(), NOW()),
-			('default_rate_limit.per_hour', '1000', 'int', 'Default rate limit per hour (deprecated)', false, NOW(), NOW()),
-			('default_rate_limit.per_day', '10000', 'int', 'Default rate limit per day', false, NOW(), NOW())
+			('default_rate_limit.per_hour', '1000', 'int', 'Default requests per hour per API key, used when the key sets no limit (0 = unlimited)', false, NOW(), NOW()),
+			('default_rate_limit.per_day', '10000', 'int', 'Default requests per UTC day per API key, used when the key sets no limit (0 = unlimited)', false, NOW(), NOW()),
+			
+			-- 用量异常检测
+			('anomaly.enabled', 'false', 'bool', 'Flag API keys and users whose usage jumps well above their recent baseline', false, NOW(), NOW()),
+			('anomaly.window_minutes', '60', 'int', 'Length of the detection window in minutes', false, NOW(), NOW()),
+			('anomaly.baseline_windows', '24', 'int', 'Number of preceding windows averaged into the baseline', false, NOW(), NOW()),
+			('anomaly.spike_ratio', '5', 'float', 'Flag when usage in the window exceeds the baseline average by this factor', false, NOW(), NOW()),
+			('anomaly.min_requests', '100', 'int', 'Minimum requests in the window before a request spike is flagged', false, NOW(), NOW()),
+			('anomaly.min_spend', '10000', 'int', 'Minimum quota spent in the window before a spend spike is flagged', false, NOW(), NOW()),
+			('anomaly.auto_suspend', 'false', 'bool', 'Deactivate a flagged API key automatically', false, NOW(), NOW()),
+			('quota_hold.ttl_seconds', '300', 'int', 'Default lifetime of a quota hold; unused holds are released when it expires', false, NOW(), NOW()),
+			('quota_hold.max_ttl_seconds', '3600', 'int', 'Longest lifetime a client may request for a quota hold', false, NOW(), NOW()),
+
+			-- 热门缓存预热
+			('cache_warming.enabled', 'false', 'bool', 'Refresh frequently hit cache entries in the background before they expire', false, NOW(), NOW()),
+			('cache_warming.lead_minutes', '10', 'int', 'Refresh entries expiring within this many minutes', false, NOW(), NOW()),
+			('cache_warming.min_hits', '5', 'int', 'Minimum cache hits before an entry is kept warm', false, NOW(), NOW()),
+			('cache_warming.daily_budget', '100', 'int', 'Maximum warming calls to upstream per day', false, NOW(), NOW()),
+			('cache_warming.off_peak_start_hour', '0', 'int', 'Hour (0-23) warming may start; equal start and end hours allow warming all day', false, NOW(), NOW()),
+			('cache_warming.off_peak_end_hour', '0', 'int', 'Hour (0-23) warming stops', false, NOW(), NOW()),
+			('cache_warming.billing_user_id', '0', 'int', 'System account billed for warming calls (0 = free of charge)', false, NOW(), NOW())
 		ON CONFLICT ("key") DO NOTHING
 	`).Error
 	
@@ -14,10 +14,52 @@ type Config struct {
 	Redis        RedisConfig
 	Server       ServerConfig
 	JWT          JWTConfig
+	Security     SecurityConfig
 	Embedding    EmbeddingConfig
 	Cache        CacheConfig
 	Admin        AdminConfig
 	Registration RegistrationConfig
+	RequestLog   RequestLogConfig
+	Archive      ArchiveConfig
+	Declarative  DeclarativeConfig
+	Tracing      TracingConfig
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL, empty disables tracing
+	Endpoint string
+	// SampleRate is the fraction of new requests traced, requests arriving with a sampled parent are always traced.
+	// An API config's trace_sample_rate overrides it for requests routed to that config
+	SampleRate float64
+	// ErrorSampleRate is the fraction of failed requests traced, never lower than the regular rate
+	ErrorSampleRate float64
+	ServiceName     string
+}
+
+// DeclarativeConfig holds the declarative API config file reconciled into the DB on startup
+type DeclarativeConfig struct {
+	// Path is a YAML or JSON file of API configs and pricing, empty disables it
+	Path string
+	// ReadOnly makes file-defined configs read-only through the admin API
+	ReadOnly bool
+}
+
+// ArchiveConfig holds compliance archive configuration
+type ArchiveConfig struct {
+	// Path is the append-only archive file, empty disables archiving
+	Path string
+	// FailClosed rejects new requests while the archive cannot be written
+	FailClosed bool
+	QueueSize  int
+}
+
+// RequestLogConfig holds request log batching configuration
+type RequestLogConfig struct {
+	// BatchSize is the max number of request logs per insert, 1 or less writes each log on its own
+	BatchSize     int
+	QueueSize     int
+	FlushInterval time.Duration
 }
 
 // RegistrationConfig holds registration configuration
@@ -47,6 +89,11 @@ type ServerConfig struct {
 	ReadTimeout    time.Duration
 	WriteTimeout   time.Duration
 	RequestTimeout time.Duration
+
+	// MaxInFlight caps concurrent requests across the server, excess requests get 503.
+	// 0 derives the cap from the DB and Redis pool sizes times InFlightPerConn, negative disables it
+	MaxInFlight     int
+	InFlightPerConn int
 }
 
 // JWTConfig holds JWT configuration
@@ -54,11 +101,22 @@ type JWTConfig struct {
 	Secret string
 }
 
+// SecurityConfig holds at-rest encryption configuration
+type SecurityConfig struct {
+	// EncryptionKey encrypts stored secrets such as upstream API keys, falls back to the JWT secret
+	EncryptionKey string
+}
+
 // EmbeddingConfig holds embedding service configuration
 type EmbeddingConfig struct {
 	URL     string
 	Timeout time.Duration
 	Enabled bool
+	// MaxRetries retries transient embedding failures with exponential backoff starting at RetryBackoff
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// BackfillInterval is how often cache rows stored without an embedding are retried, 0 disables the backfill
+	BackfillInterval time.Duration
 }
 
 // CacheConfig holds cache configuration
@@ -76,6 +134,16 @@ type AdminConfig struct {
 	Password string
 }
 
+// MaxInFlightRequests returns the global concurrency cap, 0 means unlimited.
+// Most requests spend their time waiting on upstreams rather than holding a connection,
+// so the derived cap allows several requests per DB and Redis connection
+func (c *Config) MaxInFlightRequests() int {
+	if c.Server.MaxInFlight != 0 {
+		return max(c.Server.MaxInFlight, 0)
+	}
+	return (c.Database.MaxOpenConns + c.Redis.PoolSize) * c.Server.InFlightPerConn
+}
+
 // Load loads configuration from environment variables
 // It automatically loads .env file if it exists
 func Load() (*Config, error) {
@@ -98,14 +166,24 @@ func Load() (*Config, error) {
 			ReadTimeout:    getEnvAsDuration("SERVER_READ_TIMEOUT", 10*time.Second),
 			WriteTimeout:   getEnvAsDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
 			RequestTimeout: getEnvAsDuration("REQUEST_TIMEOUT", 30*time.Second),
+
+			MaxInFlight:     getEnvAsInt("MAX_IN_FLIGHT_REQUESTS", 0),
+			InFlightPerConn: getEnvAsInt("IN_FLIGHT_PER_CONN", 8),
 		},
 		JWT: JWTConfig{
 			Secret: getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		},
+		Security: SecurityConfig{
+			EncryptionKey: getEnv("ENCRYPTION_KEY", ""),
+		},
 		Embedding: EmbeddingConfig{
 			URL:     getEnv("EMBEDDING_URL", "http://localhost:8765"),
 			Timeout: getEnvAsDuration("EMBEDDING_TIMEOUT", 30*time.Second),
 			Enabled: getEnvAsBool("EMBEDDING_ENABLED", true),
+
+			MaxRetries:       getEnvAsInt("EMBEDDING_MAX_RETRIES", 2),
+			RetryBackoff:     getEnvAsDuration("EMBEDDING_RETRY_BACKOFF", 200*time.Millisecond),
+			BackfillInterval: getEnvAsDuration("EMBEDDING_BACKFILL_INTERVAL", 10*time.Minute),
 		},
 		Cache: CacheConfig{
 			Enabled:       getEnvAsBool("CACHE_ENABLED", true),
@@ -122,6 +200,26 @@ func Load() (*Config, error) {
 			Enabled:      getEnvAsBool("REGISTRATION_ENABLED", true),
 			DefaultQuota: int64(getEnvAsInt("DEFAULT_QUOTA", 10000)),
 		},
+		RequestLog: RequestLogConfig{
+			BatchSize:     getEnvAsInt("REQUEST_LOG_BATCH_SIZE", 100),
+			QueueSize:     getEnvAsInt("REQUEST_LOG_QUEUE_SIZE", 10000),
+			FlushInterval: getEnvAsDuration("REQUEST_LOG_FLUSH_INTERVAL", time.Second),
+		},
+		Archive: ArchiveConfig{
+			Path:       getEnv("ARCHIVE_PATH", ""),
+			FailClosed: getEnvAsBool("ARCHIVE_FAIL_CLOSED", false),
+			QueueSize:  getEnvAsInt("ARCHIVE_QUEUE_SIZE", 1000),
+		},
+		Declarative: DeclarativeConfig{
+			Path:     getEnv("DECLARATIVE_CONFIG_FILE", ""),
+			ReadOnly: getEnvAsBool("DECLARATIVE_CONFIG_READ_ONLY", false),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			SampleRate:      getEnvAsFloat("TRACING_SAMPLE_RATE", 0.1),
+			ErrorSampleRate: getEnvAsFloat("TRACING_ERROR_SAMPLE_RATE", 1),
+			ServiceName:     getEnv("OTEL_SERVICE_NAME", "prism-api"),
+		},
 	}
 
 	// Validate required fields
@@ -129,6 +227,11 @@ func Load() (*Config, error) {
 		fmt.Println("WARNING: Using default JWT secret. Please set JWT_SECRET environment variable in production.")
 	}
 
+	if cfg.Security.EncryptionKey == "" {
+		fmt.Println("WARNING: ENCRYPTION_KEY not set, using the JWT secret to encrypt stored API keys.")
+		cfg.Security.EncryptionKey = cfg.JWT.Secret
+	}
+
 	// Validate registration config
 	if !cfg.Registration.Enabled {
 		fmt.Println("INFO: User registration is disabled.")
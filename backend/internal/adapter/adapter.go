@@ -12,6 +12,7 @@ type Message struct {
 	Name    string `json:"name,omitempty"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string `json:"tool_call_id,omitempty"`
+	Refusal string `json:"refusal,omitempty"` // OpenAI: the model's refusal text
 }
 
 // ContentBlock represents a content block in multimodal messages
@@ -54,9 +55,9 @@ type ChatRequest struct {
 	Messages []Message `json:"messages"`
 
 	// 采样参数
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"top_p,omitempty"`
-	TopK        int     `json:"top_k,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        int      `json:"top_k,omitempty"`
 
 	// 输出控制
 	MaxTokens int         `json:"max_tokens,omitempty"`
@@ -82,13 +83,19 @@ type ChatRequest struct {
 	TopLogprobs       int            `json:"top_logprobs,omitempty"`
 	ResponseFormat    *ResponseFormat `json:"response_format,omitempty"`
 	ServiceTier       string         `json:"service_tier,omitempty"`
+	ReasoningEffort   string         `json:"reasoning_effort,omitempty"` // low / medium / high，仅推理模型支持
 	ParallelToolCalls *bool          `json:"parallel_tool_calls,omitempty"`
 	StreamOptions     *StreamOptions `json:"stream_options,omitempty"`
+	PromptCacheKey    string         `json:"prompt_cache_key,omitempty"`  // 供应商侧缓存路由键，同时参与网关缓存键
+	SafetyIdentifier  string         `json:"safety_identifier,omitempty"` // 终端用户的稳定标识，供供应商识别滥用
 
 	// Anthropic 特有参数
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
 	StopSequences []string               `json:"stop_sequences,omitempty"`
 
+	// CachePrefix 由网关设置：对话前缀（除最后一条消息外）在多次请求间保持不变，支持的上游应缓存该前缀
+	CachePrefix bool `json:"-"`
+
 	// Gemini 特有参数
 	SafetySettings []SafetySetting `json:"safety_settings,omitempty"`
 	CachedContent  string          `json:"cached_content,omitempty"`
@@ -120,6 +127,7 @@ type ChatResponse struct {
 	Choices []ChatChoice `json:"choices"`
 	Usage   UsageInfo    `json:"usage"`
 	Cached  bool         `json:"cached,omitempty"` // 标记是否来自缓存
+	Refusal *Refusal     `json:"prism_refusal,omitempty"` // 上游以内容策略拒绝回答
 }
 
 // ChatChoice represents a single choice in the response
@@ -134,6 +142,8 @@ type UsageInfo struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
 }
 
 // Adapter is the interface that all API adapters must implement
@@ -156,4 +166,10 @@ type Config struct {
 	Model   string
 	Timeout int
 	Client  *http.Client
+
+	// UserAgent is the user-agent template sent upstream, empty uses DefaultUserAgent
+	UserAgent string
+
+	// HeaderPolicy selects which inbound client headers are forwarded upstream
+	HeaderPolicy HeaderPolicy
 }
@@ -69,7 +69,7 @@ func TestOpenAIAdapter_RequestResponseConversion(t *testing.T) {
 			{Role: "system", Content: "You are a helpful assistant."},
 			{Role: "user", Content: "Hello"},
 		},
-		Temperature: 0.7,
+		Temperature: float64Ptr(0.7),
 		MaxTokens:   100,
 	}
 
@@ -153,7 +153,7 @@ func TestAnthropicAdapter_RequestResponseConversion(t *testing.T) {
 			{Role: "system", Content: "You are a helpful assistant."},
 			{Role: "user", Content: "Hello"},
 		},
-		Temperature: 0.7,
+		Temperature: float64Ptr(0.7),
 		MaxTokens:   100,
 	}
 
@@ -239,7 +239,7 @@ func TestGeminiAdapter_RequestResponseConversion(t *testing.T) {
 			{Role: "system", Content: "You are a helpful assistant."},
 			{Role: "user", Content: "Hello"},
 		},
-		Temperature: 0.7,
+		Temperature: float64Ptr(0.7),
 		MaxTokens:   100,
 	}
 
@@ -305,7 +305,7 @@ func TestGeminiAdapter_MessageConversion(t *testing.T) {
 		{Role: "user", Content: "How are you?"},
 	}
 
-	convertedMessages := adapter.convertMessages(messages)
+	convertedMessages, _ := adapter.convertMessages(messages)
 
 	// Verify system message skipped
 	if len(convertedMessages) != 3 {
@@ -379,3 +379,7 @@ func TestAdapter_GetType(t *testing.T) {
 		})
 	}
 }
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
@@ -36,13 +36,33 @@ func (a *AnthropicAdapter) GetType() string {
 	return "anthropic"
 }
 
+// SetUserAgent overrides the user-agent template
+func (a *AnthropicAdapter) SetUserAgent(template string) {
+	a.config.UserAgent = template
+}
+
+// Capabilities returns the features supported by the Anthropic adapter
+// response_format is not translated, so JSON mode is not supported
+func (a *AnthropicAdapter) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, Tools: true, Vision: true, PromptCaching: true}
+}
+
+// HealthCheck lists models as a lightweight probe
+func (a *AnthropicAdapter) HealthCheck(ctx context.Context) error {
+	return probeUpstream(ctx, a.config.Client, a.config.BaseURL+"/v1/models", map[string]string{
+		"x-api-key":         a.config.APIKey,
+		"anthropic-version": "2023-06-01",
+		"User-Agent":        a.config.userAgent("anthropic"),
+	})
+}
+
 // Anthropic request/response structures
 type anthropicRequest struct {
 	Model         string             `json:"model"`
 	Messages      []anthropicMessage `json:"messages"`
 	MaxTokens     int                `json:"max_tokens"`
-	Temperature   float64            `json:"temperature,omitempty"`
-	TopP          float64            `json:"top_p,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
 	TopK          int                `json:"top_k,omitempty"`
 	System        string             `json:"system,omitempty"`
 	Tools         []anthropicTool    `json:"tools,omitempty"`
@@ -50,6 +70,7 @@ type anthropicRequest struct {
 	Stream        bool               `json:"stream,omitempty"`
 	StopSequences []string           `json:"stop_sequences,omitempty"`
 	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	ServiceTier   string             `json:"service_tier,omitempty"`
 }
 
 type anthropicMessage struct {
@@ -80,23 +101,37 @@ type anthropicContent struct {
 	ID     string                 `json:"id,omitempty"`
 	Name   string                 `json:"name,omitempty"`
 	Input  map[string]interface{} `json:"input,omitempty"`
+
+	// tool_result fields
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   interface{} `json:"content,omitempty"` // string or []anthropicContent (text, image)
+
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
 }
 
 type anthropicImageSource struct {
-	Type      string `json:"type"`       // base64
-	MediaType string `json:"media_type"` // image/png, image/jpeg, etc.
-	Data      string `json:"data"`
+	Type      string `json:"type"`                 // base64, url
+	MediaType string `json:"media_type,omitempty"` // image/png, image/jpeg, etc.
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
 }
 
 type anthropicUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+
+	// Prompt caching: input_tokens excludes both, cache reads are billed at a discount upstream
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
 }
 
 // Call makes a request to Anthropic API
 func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
 	// Convert unified request to Anthropic format
 	messages, system := a.convertMessages(req.Messages)
+	if req.CachePrefix {
+		markAnthropicCachePrefix(messages)
+	}
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -112,7 +147,8 @@ func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRes
 		TopK:          req.TopK,
 		System:        system,
 		StopSequences: req.StopSequences,
-		Metadata:      req.Metadata,
+		Metadata:      anthropicMetadata(req),
+		ServiceTier:   anthropicServiceTier(req.ServiceTier),
 	}
 
 	// Convert stop to stop_sequences if provided
@@ -144,6 +180,7 @@ func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRes
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	url := a.config.BaseURL + "/v1/messages"
@@ -153,9 +190,11 @@ func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRes
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", a.config.APIKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	httpReq.Header.Set("User-Agent", a.config.userAgent("anthropic"))
 
 	// Make request
 	resp, err := a.config.Client.Do(httpReq)
@@ -169,6 +208,10 @@ func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRes
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	recordResponseSize(ctx, len(respBody))
+
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
@@ -185,6 +228,52 @@ func (a *AnthropicAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRes
 	return a.convertResponse(&anthropicResp), nil
 }
 
+// convertToolResultContent converts typed tool result parts to Anthropic tool_result content
+// Text-only results stay a plain string; images are passed as image blocks and JSON is serialized
+func convertToolResultContent(parts []ToolResultPart) interface{} {
+	hasImage := false
+	for _, part := range parts {
+		if part.Type == ToolResultPartImage {
+			hasImage = true
+			break
+		}
+	}
+	if !hasImage {
+		return toolResultText(parts)
+	}
+
+	contents := make([]anthropicContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case ToolResultPartText:
+			contents = append(contents, anthropicContent{Type: "text", Text: part.Text})
+		case ToolResultPartJSON:
+			contents = append(contents, anthropicContent{Type: "text", Text: part.jsonText()})
+		case ToolResultPartImage:
+			source := &anthropicImageSource{Type: "base64", MediaType: part.MediaType, Data: part.Data}
+			if part.Data == "" {
+				source = &anthropicImageSource{Type: "url", URL: part.URL}
+			}
+			contents = append(contents, anthropicContent{Type: "image", Source: source})
+		}
+	}
+	return contents
+}
+
+// isToolResultTurn reports whether the message is a user turn carrying only tool results
+func isToolResultTurn(msg anthropicMessage) bool {
+	blocks, ok := msg.Content.([]anthropicContent)
+	if !ok || msg.Role != "user" || len(blocks) == 0 {
+		return false
+	}
+	for _, block := range blocks {
+		if block.Type != "tool_result" {
+			return false
+		}
+	}
+	return true
+}
+
 // convertMessages converts OpenAI-style messages to Anthropic format
 // Extracts system message separately as Anthropic uses a separate system field
 func (a *AnthropicAdapter) convertMessages(messages []Message) ([]anthropicMessage, string) {
@@ -198,16 +287,19 @@ func (a *AnthropicAdapter) convertMessages(messages []Message) ([]anthropicMessa
 			// Anthropic uses a separate system field
 			system = contentStr
 		} else if msg.Role == "tool" {
-			// Tool result message
+			// Tool result message; results of parallel tool calls share one user turn
+			result := anthropicContent{
+				Type:      "tool_result",
+				ToolUseID: msg.ToolCallID,
+				Content:   convertToolResultContent(GetToolResultParts(msg.Content)),
+			}
+			if n := len(anthropicMessages); n > 0 && isToolResultTurn(anthropicMessages[n-1]) {
+				anthropicMessages[n-1].Content = append(anthropicMessages[n-1].Content.([]anthropicContent), result)
+				continue
+			}
 			anthropicMessages = append(anthropicMessages, anthropicMessage{
-				Role: "user",
-				Content: []anthropicContent{
-					{
-						Type: "tool_result",
-						ID:   msg.ToolCallID,
-						Text: contentStr,
-					},
-				},
+				Role:    "user",
+				Content: []anthropicContent{result},
 			})
 		} else {
 			// Check if message has tool calls
@@ -360,28 +452,47 @@ func (a *AnthropicAdapter) convertResponse(resp *anthropicResponse) *ChatRespons
 		msg.ToolCalls = toolCalls
 	}
 
-	return &ChatResponse{
+	// input_tokens excludes cached prompt tokens; report the full prompt like OpenAI does
+	promptTokens := resp.Usage.InputTokens + resp.Usage.CacheReadInputTokens + resp.Usage.CacheCreationInputTokens
+
+	unified := &ChatResponse{
 		ID:    resp.ID,
 		Model: resp.Model,
 		Choices: []ChatChoice{
 			{
 				Index:        0,
 				Message:      msg,
-				FinishReason: resp.StopReason,
+				FinishReason: NormalizeFinishReason("anthropic", resp.StopReason, len(toolCalls) > 0),
 			},
 		},
 		Usage: UsageInfo{
-			PromptTokens:     resp.Usage.InputTokens,
+			PromptTokens:     promptTokens,
 			CompletionTokens: resp.Usage.OutputTokens,
-			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+			TotalTokens:      promptTokens + resp.Usage.OutputTokens,
+
+			PromptTokensDetails: anthropicPromptTokensDetails(resp.Usage),
 		},
 	}
+	choice := &unified.Choices[0]
+	unified.setRefusal(choice, newRefusal("anthropic", resp.StopReason, choice.FinishReason, textContent))
+	return unified
+}
+
+// anthropicPromptTokensDetails reports prompt cache reads, nil when nothing was read from cache
+func anthropicPromptTokensDetails(usage anthropicUsage) *PromptTokensDetails {
+	if usage.CacheReadInputTokens == 0 {
+		return nil
+	}
+	return &PromptTokensDetails{CachedTokens: usage.CacheReadInputTokens}
 }
 
 // CallStream makes a streaming request to Anthropic API
 func (a *AnthropicAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.Response, error) {
 	// Convert unified request to Anthropic format
 	messages, system := a.convertMessages(req.Messages)
+	if req.CachePrefix {
+		markAnthropicCachePrefix(messages)
+	}
 
 	maxTokens := req.MaxTokens
 	if maxTokens == 0 {
@@ -398,7 +509,8 @@ func (a *AnthropicAdapter) CallStream(ctx context.Context, req *ChatRequest) (*h
 		System:        system,
 		Stream:        true,
 		StopSequences: req.StopSequences,
-		Metadata:      req.Metadata,
+		Metadata:      anthropicMetadata(req),
+		ServiceTier:   anthropicServiceTier(req.ServiceTier),
 	}
 
 	// Convert stop to stop_sequences if provided
@@ -430,6 +542,7 @@ func (a *AnthropicAdapter) CallStream(ctx context.Context, req *ChatRequest) (*h
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	url := a.config.BaseURL + "/v1/messages"
@@ -439,9 +552,11 @@ func (a *AnthropicAdapter) CallStream(ctx context.Context, req *ChatRequest) (*h
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("x-api-key", a.config.APIKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
+	httpReq.Header.Set("User-Agent", a.config.userAgent("anthropic"))
 	httpReq.Header.Set("Accept", "text/event-stream")
 
 	// Make request and return response directly
@@ -450,6 +565,9 @@ func (a *AnthropicAdapter) CallStream(ctx context.Context, req *ChatRequest) (*h
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
+
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
@@ -0,0 +1,134 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Capabilities describes the features an adapter supports
+type Capabilities struct {
+	Streaming  bool `json:"streaming"`
+	Tools      bool `json:"tools"`
+	Vision     bool `json:"vision"`
+	Embeddings bool `json:"embeddings"`
+	JSONMode   bool `json:"json_mode"`
+
+	// PromptCaching means the upstream can cache a repeated prompt prefix and bill it at a discount
+	PromptCaching bool `json:"prompt_caching"`
+
+	// MaxTools is the provider's limit on tools per request, 0 means no known limit
+	MaxTools int `json:"max_tools,omitempty"`
+}
+
+// maxProviderTools is the number of tool definitions OpenAI and Gemini accept per request
+const maxProviderTools = 128
+
+// CapabilityReporter is implemented by adapters that report their features and can be probed
+// It is optional so that adapters outside this package keep working unchanged
+type CapabilityReporter interface {
+	// Capabilities returns the features supported by the adapter
+	Capabilities() Capabilities
+
+	// HealthCheck performs a lightweight probe against the upstream
+	HealthCheck(ctx context.Context) error
+}
+
+// allCapabilities is assumed for adapters that do not report capabilities
+var allCapabilities = Capabilities{Streaming: true, Tools: true, Vision: true, Embeddings: true, JSONMode: true, PromptCaching: true}
+
+// GetCapabilities returns the adapter's capabilities, adapters that do not report are assumed to support everything
+func GetCapabilities(a Adapter) Capabilities {
+	if reporter, ok := a.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return allCapabilities
+}
+
+// HealthCheck probes the adapter if it supports health checks
+func HealthCheck(ctx context.Context, a Adapter) error {
+	if reporter, ok := a.(CapabilityReporter); ok {
+		return reporter.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// CapabilityError is returned when a request needs a feature the adapter does not support
+type CapabilityError struct {
+	AdapterType string
+	Feature     string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s adapter does not support %s", e.AdapterType, e.Feature)
+}
+
+// ValidateRequest checks that the adapter supports every feature the request uses
+func ValidateRequest(a Adapter, req *ChatRequest) error {
+	caps := GetCapabilities(a)
+	unsupported := func(feature string) error {
+		return &CapabilityError{AdapterType: a.GetType(), Feature: feature}
+	}
+
+	if req.Stream && !caps.Streaming {
+		return unsupported("streaming")
+	}
+	if len(req.Tools) > 0 && !caps.Tools {
+		return unsupported("tools")
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type != "" && req.ResponseFormat.Type != "text" && !caps.JSONMode {
+		return unsupported("json_mode")
+	}
+	if !caps.Vision && hasImageContent(req.Messages) {
+		return unsupported("vision")
+	}
+	return nil
+}
+
+// hasImageContent reports whether any message carries image parts
+func hasImageContent(messages []Message) bool {
+	for _, msg := range messages {
+		parts, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			if partMap, ok := part.(map[string]interface{}); ok {
+				switch partMap["type"] {
+				case "image_url", "image", "inline_data":
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// probeUpstream sends a GET request and treats any 2xx status as healthy
+func probeUpstream(ctx context.Context, client *http.Client, url string, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check request failed: %w", err)
+	}
+	defer func() {
+		// Drain before closing so the keep-alive connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("health check failed (status %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// limitedAdapter reports a fixed capability set
+type limitedAdapter struct {
+	Adapter
+	caps Capabilities
+}
+
+func (a *limitedAdapter) GetType() string                   { return "limited" }
+func (a *limitedAdapter) Capabilities() Capabilities        { return a.caps }
+func (a *limitedAdapter) HealthCheck(context.Context) error { return nil }
+
+// plainAdapter does not report capabilities
+type plainAdapter struct {
+	Adapter
+}
+
+func (a *plainAdapter) GetType() string { return "plain" }
+
+func TestValidateRequest_RejectsUnsupportedFeatures(t *testing.T) {
+	tools := []Tool{{Type: "function", Function: ToolFunction{Name: "lookup"}}}
+	image := []interface{}{map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/a.png"}}}
+
+	tests := []struct {
+		name    string
+		adapter Adapter
+		req     *ChatRequest
+		feature string
+	}{
+		{"tools to non-tools adapter", &limitedAdapter{caps: Capabilities{Streaming: true}}, &ChatRequest{Tools: tools}, "tools"},
+		{"image to non-vision adapter", &limitedAdapter{caps: Capabilities{Tools: true}}, &ChatRequest{Messages: []Message{{Role: "user", Content: image}}}, "vision"},
+		{"stream to non-streaming adapter", &limitedAdapter{}, &ChatRequest{Stream: true}, "streaming"},
+		{"json mode to anthropic", NewAnthropicAdapter(&Config{}), &ChatRequest{ResponseFormat: &ResponseFormat{Type: "json_object"}}, "json_mode"},
+		{"tools allowed", &limitedAdapter{caps: Capabilities{Tools: true}}, &ChatRequest{Tools: tools}, ""},
+		{"non-reporting adapter is permissive", &plainAdapter{}, &ChatRequest{Stream: true, Tools: tools}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRequest(tt.adapter, tt.req)
+			if tt.feature == "" {
+				if err != nil {
+					t.Fatalf("Expected request to pass, got %v", err)
+				}
+				return
+			}
+			var capErr *CapabilityError
+			if !errors.As(err, &capErr) || capErr.Feature != tt.feature {
+				t.Fatalf("Expected %s capability error, got %v", tt.feature, err)
+			}
+		})
+	}
+}
+
+func TestOpenAIHealthCheck(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("Unexpected probe %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL + "/v1", APIKey: "sk-test", Client: server.Client()})
+	if err := HealthCheck(context.Background(), a); err != nil {
+		t.Fatalf("Expected healthy upstream, got %v", err)
+	}
+
+	status = http.StatusUnauthorized
+	if err := HealthCheck(context.Background(), a); err == nil {
+		t.Fatal("Expected health check to fail on 401")
+	}
+}
@@ -1,6 +1,10 @@
 package adapter
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
 
 // GetContentAsString extracts string content from Message.Content
 // Message.Content can be either string or []ContentPart (for vision)
@@ -56,4 +60,162 @@ func parseDataURL(url string) (mediaType, data string) {
 	}
 
 	return mediaType, data
-}
\ No newline at end of file
+}
+
+// Tool result part types
+const (
+	ToolResultPartText  = "text"
+	ToolResultPartImage = "image"
+	ToolResultPartJSON  = "json"
+)
+
+// ToolResultPart is a typed part of a tool result message
+// Tool results may carry text, images (e.g. screenshots) or structured JSON
+type ToolResultPart struct {
+	Type      string      // text, image, json
+	Text      string      // text part
+	MediaType string      // image part: media type of inline data
+	Data      string      // image part: base64 data
+	URL       string      // image part: remote URL when not inlined
+	JSON      interface{} // json part
+}
+
+// GetToolResultParts splits tool message content into typed parts
+// Accepts a plain string, OpenAI-style parts (text, image_url), Anthropic image blocks,
+// Gemini inline_data and {"type": "json", "json": ...} parts
+func GetToolResultParts(content interface{}) []ToolResultPart {
+	switch v := content.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []ToolResultPart{{Type: ToolResultPartText, Text: v}}
+	case map[string]interface{}:
+		return []ToolResultPart{{Type: ToolResultPartJSON, JSON: v}}
+	case []interface{}:
+		parts := make([]ToolResultPart, 0, len(v))
+		for _, part := range v {
+			partMap, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			partType, _ := partMap["type"].(string)
+			switch partType {
+			case "text":
+				if text, ok := partMap["text"].(string); ok {
+					parts = append(parts, ToolResultPart{Type: ToolResultPartText, Text: text})
+				}
+			case "json":
+				if value, ok := partMap["json"]; ok {
+					parts = append(parts, ToolResultPart{Type: ToolResultPartJSON, JSON: value})
+				}
+			case "image_url":
+				if imageURL, ok := partMap["image_url"].(map[string]interface{}); ok {
+					if url, ok := imageURL["url"].(string); ok && url != "" {
+						parts = append(parts, imagePartFromURL(url))
+					}
+				}
+			case "image":
+				// Anthropic format
+				if source, ok := partMap["source"].(map[string]interface{}); ok {
+					mediaType, _ := source["media_type"].(string)
+					data, _ := source["data"].(string)
+					url, _ := source["url"].(string)
+					if data != "" || url != "" {
+						parts = append(parts, ToolResultPart{Type: ToolResultPartImage, MediaType: mediaType, Data: data, URL: url})
+					}
+				}
+			case "inline_data":
+				// Gemini format
+				if inlineData, ok := partMap["inline_data"].(map[string]interface{}); ok {
+					mimeType, _ := inlineData["mime_type"].(string)
+					data, _ := inlineData["data"].(string)
+					if data != "" {
+						parts = append(parts, ToolResultPart{Type: ToolResultPartImage, MediaType: mimeType, Data: data})
+					}
+				}
+			}
+		}
+		return parts
+	default:
+		return nil
+	}
+}
+
+// imagePartFromURL builds an image part from a data URL or a remote URL
+func imagePartFromURL(url string) ToolResultPart {
+	if mediaType, data := parseDataURL(url); data != "" {
+		return ToolResultPart{Type: ToolResultPartImage, MediaType: mediaType, Data: data}
+	}
+	return ToolResultPart{Type: ToolResultPartImage, URL: url}
+}
+
+// jsonText serializes a JSON tool result part
+func (p ToolResultPart) jsonText() string {
+	data, err := json.Marshal(p.JSON)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// toolResultText flattens tool result parts into text for providers that only accept text
+// JSON parts are serialized, image parts are replaced by a placeholder
+func toolResultText(parts []ToolResultPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case ToolResultPartText:
+			texts = append(texts, part.Text)
+		case ToolResultPartJSON:
+			texts = append(texts, part.jsonText())
+		case ToolResultPartImage:
+			texts = append(texts, droppedImagePlaceholder(part))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// droppedImagePlaceholder returns the text placeholder for an unsupported image part
+func droppedImagePlaceholder(part ToolResultPart) string {
+	return fmt.Sprintf("[image omitted: %s]", part.describe())
+}
+
+// toolResultImageDropper is implemented by adapters that replace some tool result images with a placeholder
+type toolResultImageDropper interface {
+	dropsToolResultImage(part ToolResultPart) bool
+}
+
+// DroppedToolResultImages counts the tool result images the adapter will replace with a text placeholder
+// The conversion itself does not log, so callers report the count with their request-scoped logger
+func DroppedToolResultImages(a Adapter, req *ChatRequest) int {
+	dropper, ok := a.(toolResultImageDropper)
+	if !ok || req == nil {
+		return 0
+	}
+	dropped := 0
+	for _, msg := range req.Messages {
+		if msg.Role != "tool" {
+			continue
+		}
+		for _, part := range GetToolResultParts(msg.Content) {
+			if part.Type == ToolResultPartImage && dropper.dropsToolResultImage(part) {
+				dropped++
+			}
+		}
+	}
+	return dropped
+}
+
+// describe returns a short description of an image part for placeholders and logs
+func (p ToolResultPart) describe() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	if p.MediaType != "" {
+		return p.MediaType
+	}
+	return "inline data"
+}
@@ -0,0 +1,231 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// EchoModel is the built-in pseudo-model served by EchoAdapter
+	EchoModel = "prism-echo"
+	// EchoType is the adapter type of EchoAdapter
+	EchoType = "echo"
+)
+
+// Echo transforms applied to the echoed message
+const (
+	EchoTransformNone    = ""
+	EchoTransformUpper   = "upper"
+	EchoTransformReverse = "reverse"
+)
+
+// EchoOptions configures the synthetic echo responses
+type EchoOptions struct {
+	Latency          time.Duration // simulated upstream latency
+	Transform        string        // EchoTransformNone, EchoTransformUpper or EchoTransformReverse
+	CompletionTokens int           // reported completion tokens, 0 estimates from content
+}
+
+// EchoAdapter answers with the last user message without calling any upstream
+// It is meant for integration testing and never costs real tokens
+type EchoAdapter struct {
+	options EchoOptions
+}
+
+// NewEchoAdapter creates a new echo adapter
+func NewEchoAdapter(options EchoOptions) *EchoAdapter {
+	return &EchoAdapter{options: options}
+}
+
+// GetType returns the adapter type
+func (a *EchoAdapter) GetType() string {
+	return EchoType
+}
+
+// Capabilities returns the features supported by the echo adapter
+func (a *EchoAdapter) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, Tools: true, Vision: true, JSONMode: true}
+}
+
+// HealthCheck always succeeds as there is no upstream
+func (a *EchoAdapter) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
+// Call returns the echoed message as a chat completion
+func (a *EchoAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	if err := a.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	content := a.reply(req)
+	usage := a.usage(req, content)
+	return &ChatResponse{
+		ID:      fmt.Sprintf("chatcmpl-echo-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []ChatChoice{
+			{
+				Index:        0,
+				Message:      Message{Role: "assistant", Content: content},
+				FinishReason: "stop",
+			},
+		},
+		Usage: usage,
+	}, nil
+}
+
+// echoStreamChunk is a stream chunk that may carry usage on the final chunk
+type echoStreamChunk struct {
+	ChatStreamChunk
+	Usage *UsageInfo `json:"usage,omitempty"`
+}
+
+// CallStream streams the echoed message word by word in OpenAI SSE format
+func (a *EchoAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.Response, error) {
+	if err := a.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	content := a.reply(req)
+	usage := a.usage(req, content)
+	id := fmt.Sprintf("chatcmpl-echo-%d", time.Now().UnixNano())
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer pw.Close()
+
+		write := func(delta StreamDelta, finishReason string, usage *UsageInfo) error {
+			chunk := echoStreamChunk{
+				ChatStreamChunk: ChatStreamChunk{
+					ID:      id,
+					Object:  "chat.completion.chunk",
+					Created: time.Now().Unix(),
+					Model:   req.Model,
+					Choices: []StreamChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+				},
+				Usage: usage,
+			}
+			data, _ := json.Marshal(chunk)
+			_, err := fmt.Fprintf(pw, "data: %s\n\n", data)
+			return err
+		}
+
+		if err := write(StreamDelta{Role: "assistant"}, "", nil); err != nil {
+			return
+		}
+		for _, piece := range splitEchoWords(content) {
+			if ctx.Err() != nil {
+				pw.CloseWithError(ctx.Err())
+				return
+			}
+			if err := write(StreamDelta{Content: piece}, "", nil); err != nil {
+				return
+			}
+		}
+		if err := write(StreamDelta{}, "stop", &usage); err != nil {
+			return
+		}
+		fmt.Fprintf(pw, "data: [DONE]\n\n")
+	}()
+
+	streamResp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    200,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Body:          pr,
+		ContentLength: -1,
+		Header:        make(http.Header),
+	}
+	streamResp.Header.Set("Content-Type", "text/event-stream")
+	streamResp.Header.Set("Cache-Control", "no-cache")
+	return streamResp, nil
+}
+
+// wait simulates upstream latency
+func (a *EchoAdapter) wait(ctx context.Context) error {
+	if a.options.Latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(a.options.Latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reply returns the last user message with the configured transform applied
+func (a *EchoAdapter) reply(req *ChatRequest) string {
+	var content string
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			content = GetContentAsString(req.Messages[i].Content)
+			break
+		}
+	}
+
+	switch a.options.Transform {
+	case EchoTransformUpper:
+		return strings.ToUpper(content)
+	case EchoTransformReverse:
+		runes := []rune(content)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	default:
+		return content
+	}
+}
+
+// usage estimates token usage at roughly 4 characters per token
+func (a *EchoAdapter) usage(req *ChatRequest, content string) UsageInfo {
+	promptChars := 0
+	for _, msg := range req.Messages {
+		promptChars += len([]rune(GetContentAsString(msg.Content)))
+	}
+	completion := a.options.CompletionTokens
+	if completion <= 0 {
+		completion = echoTokens(len([]rune(content)))
+	}
+	prompt := echoTokens(promptChars)
+	return UsageInfo{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
+// echoTokens converts a character count to tokens, never reporting zero
+func echoTokens(chars int) int {
+	if chars <= 0 {
+		return 1
+	}
+	return (chars + 3) / 4
+}
+
+// splitEchoWords splits content into stream pieces keeping the separating spaces
+func splitEchoWords(content string) []string {
+	if content == "" {
+		return nil
+	}
+	words := strings.SplitAfter(content, " ")
+	pieces := words[:0]
+	for _, w := range words {
+		if w != "" {
+			pieces = append(pieces, w)
+		}
+	}
+	return pieces
+}
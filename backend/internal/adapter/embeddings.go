@@ -0,0 +1,37 @@
+package adapter
+
+import "context"
+
+// EmbeddingRequest is a unified embeddings request for a single upstream call
+type EmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+	User       string   `json:"user,omitempty"`
+}
+
+// Embedding is one vector in an embeddings response, Index refers to the position in the request input
+type Embedding struct {
+	Object    string    `json:"object"`
+	Index     int       `json:"index"`
+	Embedding []float64 `json:"embedding"`
+}
+
+// EmbeddingResponse is a unified embeddings response
+type EmbeddingResponse struct {
+	Model string      `json:"model"`
+	Data  []Embedding `json:"data"`
+	Usage UsageInfo   `json:"usage"`
+}
+
+// EmbeddingAdapter is implemented by adapters that can generate embeddings
+type EmbeddingAdapter interface {
+	// Embed sends one embeddings request, the input must not exceed MaxEmbeddingBatch items
+	Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error)
+
+	// MaxEmbeddingBatch returns the provider's limit on inputs per request
+	MaxEmbeddingBatch() int
+}
+
+// maxOpenAIEmbeddingBatch is the number of inputs OpenAI accepts per embeddings request
+const maxOpenAIEmbeddingBatch = 2048
@@ -1,6 +1,9 @@
 package adapter
 
 import (
+	"api-aggregator/backend/pkg/crypto"
+	"api-aggregator/backend/pkg/logger"
+	"crypto/tls"
 	"fmt"
 )
 
@@ -10,23 +13,138 @@ type APIConfigInterface interface {
 	GetBaseURL() string
 	GetAPIKey() string
 	GetTimeout() int
+	GetUserAgent() string
 }
 
 // Factory creates adapters based on API configuration
-type Factory struct{}
+type Factory struct {
+	secrets *crypto.SecretBox
+	logger  *logger.Logger
+}
 
 // NewFactory creates a new adapter factory
 func NewFactory() *Factory {
 	return &Factory{}
 }
 
+// WithSecretBox sets the box used to decrypt stored upstream API keys
+func (f *Factory) WithSecretBox(secrets *crypto.SecretBox) *Factory {
+	f.secrets = secrets
+	return f
+}
+
+// WithLogger sets the logger used to report upstream keys that cannot be decrypted
+func (f *Factory) WithLogger(log *logger.Logger) *Factory {
+	f.logger = log
+	return f
+}
+
 // CreateAdapter creates an adapter based on the API configuration
+// Configs holding several API keys get a KeyFallbackAdapter that moves to the next key on auth failures
 func (f *Factory) CreateAdapter(config APIConfigInterface) (Adapter, error) {
+	keys, err := f.apiKeys(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) <= 1 {
+		apiKey := config.GetAPIKey()
+		if len(keys) == 1 {
+			apiKey = keys[0]
+		}
+		return f.createAdapter(config, apiKey)
+	}
+
+	adapters := make([]Adapter, 0, len(keys))
+	for _, key := range keys {
+		a, err := f.createAdapter(config, key)
+		if err != nil {
+			return nil, err
+		}
+		adapters = append(adapters, a)
+	}
+	return NewKeyFallbackAdapter(adapters), nil
+}
+
+// apiKeys returns the config's decrypted key set
+// Keys that fail to decrypt are logged and skipped; an error is returned when none of the stored keys is usable
+func (f *Factory) apiKeys(config APIConfigInterface) ([]string, error) {
+	multi, ok := config.(MultiKeyConfig)
+	if !ok {
+		return nil, nil
+	}
+	stored := multi.GetAPIKeys()
+	keys := make([]string, 0, len(stored))
+	var lastErr error
+	for i, key := range stored {
+		if crypto.IsEncrypted(key) {
+			if f.secrets == nil {
+				lastErr = fmt.Errorf("cannot decrypt upstream API key: encryption key not configured")
+			} else if plain, err := f.secrets.Decrypt(key); err != nil {
+				lastErr = fmt.Errorf("failed to decrypt upstream API key: %w", err)
+			} else {
+				keys = append(keys, plain)
+				continue
+			}
+			if f.logger != nil {
+				f.logger.Warn("Skipping upstream API key that cannot be decrypted",
+					logger.String("base_url", config.GetBaseURL()),
+					logger.Int("key_index", i),
+					logger.Error(lastErr))
+			}
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(stored) > 0 && len(keys) == 0 {
+		return nil, fmt.Errorf("no usable upstream API key (%d stored): %w", len(stored), lastErr)
+	}
+	return keys, nil
+}
+
+// tlsConfig builds the config's custom TLS settings, decrypting the stored client key
+func (f *Factory) tlsConfig(config APIConfigInterface) (*tls.Config, error) {
+	provider, ok := config.(TLSConfigProvider)
+	if !ok {
+		return nil, nil
+	}
+	settings := provider.GetTLSSettings()
+	if settings.IsEmpty() {
+		return nil, nil
+	}
+
+	plain := *settings
+	if crypto.IsEncrypted(plain.ClientKey) {
+		if f.secrets == nil {
+			return nil, fmt.Errorf("cannot decrypt TLS client key: encryption key not configured")
+		}
+		key, err := f.secrets.Decrypt(plain.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt TLS client key: %w", err)
+		}
+		plain.ClientKey = key
+	}
+	return NewTLSConfig(&plain)
+}
+
+func (f *Factory) createAdapter(config APIConfigInterface, apiKey string) (Adapter, error) {
 	adapterConfig := &Config{
 		BaseURL: config.GetBaseURL(),
-		APIKey:  config.GetAPIKey(),
+		APIKey:  apiKey,
 		Model:   "", // Model will be set per request
 		Timeout: config.GetTimeout(),
+
+		UserAgent: config.GetUserAgent(),
+	}
+	if provider, ok := config.(HeaderPolicyProvider); ok {
+		adapterConfig.HeaderPolicy = provider.GetHeaderPolicy()
+	}
+
+	tlsConfig, err := f.tlsConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		adapterConfig.Client = newTLSClient(adapterConfig.Timeout, tlsConfig)
 	}
 
 	configType := config.GetType()
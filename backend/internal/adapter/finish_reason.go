@@ -0,0 +1,63 @@
+package adapter
+
+import "strings"
+
+// Unified finish reasons, using the OpenAI vocabulary
+const (
+	FinishReasonStop          = "stop"
+	FinishReasonLength        = "length"
+	FinishReasonToolCalls     = "tool_calls"
+	FinishReasonContentFilter = "content_filter"
+)
+
+// anthropicFinishReasons maps Anthropic stop_reason values to unified finish reasons
+var anthropicFinishReasons = map[string]string{
+	"end_turn":      FinishReasonStop,
+	"stop_sequence": FinishReasonStop,
+	"pause_turn":    FinishReasonStop,
+	"max_tokens":    FinishReasonLength,
+	"tool_use":      FinishReasonToolCalls,
+	"refusal":       FinishReasonContentFilter,
+}
+
+// geminiFinishReasons maps Gemini finishReason values to unified finish reasons
+var geminiFinishReasons = map[string]string{
+	"STOP":               FinishReasonStop,
+	"MAX_TOKENS":         FinishReasonLength,
+	"SAFETY":             FinishReasonContentFilter,
+	"RECITATION":         FinishReasonContentFilter,
+	"BLOCKLIST":          FinishReasonContentFilter,
+	"PROHIBITED_CONTENT": FinishReasonContentFilter,
+	"SPII":               FinishReasonContentFilter,
+	"IMAGE_SAFETY":       FinishReasonContentFilter,
+}
+
+// NormalizeFinishReason converts a provider's finish reason to the unified vocabulary
+// Providers that do not report tool calls as a finish reason (Gemini, Kiro) get tool_calls when the
+// message carries tool calls and generation otherwise ended normally
+func NormalizeFinishReason(provider, reason string, hasToolCalls bool) string {
+	var normalized string
+	switch provider {
+	case "anthropic":
+		normalized = lookupFinishReason(anthropicFinishReasons, reason)
+	case "gemini":
+		normalized = lookupFinishReason(geminiFinishReasons, strings.ToUpper(reason))
+	default:
+		normalized = strings.ToLower(reason)
+	}
+	if normalized == "" {
+		normalized = FinishReasonStop
+	}
+	if hasToolCalls && normalized == FinishReasonStop {
+		return FinishReasonToolCalls
+	}
+	return normalized
+}
+
+// lookupFinishReason unknown non-empty reasons end generation normally
+func lookupFinishReason(table map[string]string, reason string) string {
+	if mapped, ok := table[reason]; ok {
+		return mapped
+	}
+	return FinishReasonStop
+}
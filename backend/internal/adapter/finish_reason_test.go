@@ -0,0 +1,95 @@
+package adapter
+
+import "testing"
+
+func TestNormalizeFinishReason(t *testing.T) {
+	cases := []struct {
+		provider     string
+		reason       string
+		hasToolCalls bool
+		want         string
+	}{
+		{"anthropic", "end_turn", false, FinishReasonStop},
+		{"anthropic", "max_tokens", false, FinishReasonLength},
+		{"anthropic", "tool_use", true, FinishReasonToolCalls},
+		{"anthropic", "refusal", false, FinishReasonContentFilter},
+		{"gemini", "STOP", false, FinishReasonStop},
+		{"gemini", "MAX_TOKENS", false, FinishReasonLength},
+		{"gemini", "STOP", true, FinishReasonToolCalls},
+		{"gemini", "MAX_TOKENS", true, FinishReasonLength},
+		{"gemini", "SAFETY", false, FinishReasonContentFilter},
+		{"openai", "length", false, FinishReasonLength},
+		{"openai", "tool_calls", true, FinishReasonToolCalls},
+		{"kiro", "", true, FinishReasonToolCalls},
+		{"kiro", "", false, FinishReasonStop},
+	}
+	for _, tc := range cases {
+		if got := NormalizeFinishReason(tc.provider, tc.reason, tc.hasToolCalls); got != tc.want {
+			t.Errorf("%s %q (tools=%v): expected %q, got %q", tc.provider, tc.reason, tc.hasToolCalls, tc.want, got)
+		}
+	}
+}
+
+func TestAnthropicAdapter_NormalizesStopReason(t *testing.T) {
+	a := NewAnthropicAdapter(&Config{})
+
+	truncated := a.convertResponse(&anthropicResponse{
+		Content:    []anthropicContent{{Type: "text", Text: "Once upon"}},
+		StopReason: "max_tokens",
+	})
+	if got := truncated.Choices[0].FinishReason; got != FinishReasonLength {
+		t.Errorf("Expected length for max_tokens, got %q", got)
+	}
+
+	toolUse := a.convertResponse(&anthropicResponse{
+		Content: []anthropicContent{{
+			Type: "tool_use", ID: "toolu_1", Name: "weather", Input: map[string]interface{}{"city": "Paris"},
+		}},
+		StopReason: "tool_use",
+	})
+	if got := toolUse.Choices[0].FinishReason; got != FinishReasonToolCalls {
+		t.Errorf("Expected tool_calls for tool_use, got %q", got)
+	}
+}
+
+func TestGeminiAdapter_ReportsToolCallsFinishReason(t *testing.T) {
+	a := NewGeminiAdapter(&Config{})
+
+	resp := a.convertResponse(&geminiResponse{
+		Candidates: []geminiCandidate{{
+			Content: geminiContent{Role: "model", Parts: []geminiPart{{
+				FunctionCall: &geminiFunctionCall{Name: "weather", Args: map[string]interface{}{"city": "Paris"}},
+			}}},
+			FinishReason: "STOP",
+		}},
+	}, "gemini-pro")
+	if got := resp.Choices[0].FinishReason; got != FinishReasonToolCalls {
+		t.Errorf("Expected tool_calls for function call, got %q", got)
+	}
+
+	truncated := a.convertResponse(&geminiResponse{
+		Candidates: []geminiCandidate{{
+			Content:      geminiContent{Role: "model", Parts: []geminiPart{{Text: "Once upon"}}},
+			FinishReason: "MAX_TOKENS",
+		}},
+	}, "gemini-pro")
+	if got := truncated.Choices[0].FinishReason; got != FinishReasonLength {
+		t.Errorf("Expected length for MAX_TOKENS, got %q", got)
+	}
+}
+
+func TestKiroAdapter_ReportsToolCallsFinishReason(t *testing.T) {
+	a := &KiroAdapter{}
+
+	resp := a.convertEventStreamResponse("", []ToolCall{{
+		ID: "call_0", Type: "function", Function: FunctionCall{Name: "weather", Arguments: "{}"},
+	}}, "claude-sonnet")
+	if got := resp.Choices[0].FinishReason; got != FinishReasonToolCalls {
+		t.Errorf("Expected tool_calls, got %q", got)
+	}
+
+	plain := a.convertEventStreamResponse("Hello", nil, "claude-sonnet")
+	if got := plain.Choices[0].FinishReason; got != FinishReasonStop {
+		t.Errorf("Expected stop, got %q", got)
+	}
+}
@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// DefaultForwardHeaders is the allowlist used when a config does not set one.
+// It only covers headers that change upstream behavior without identifying the client.
+var DefaultForwardHeaders = []string{"Accept-Language", "OpenAI-Beta", "Anthropic-Beta"}
+
+// neverForwardedHeaders are owned by the gateway or the transport and are never copied
+// from the inbound request, regardless of the allowlist
+var neverForwardedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"Api-Key":             true,
+	"X-Goog-Api-Key":      true,
+	"Host":                true,
+	"Content-Length":      true,
+	"Content-Type":        true,
+	"Content-Encoding":    true,
+	"Transfer-Encoding":   true,
+	"Accept-Encoding":     true,
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Upgrade":             true,
+	"Te":                  true,
+	"Trailer":             true,
+	"User-Agent":          true,
+}
+
+// HeaderPolicy selects which inbound request headers are forwarded upstream.
+// Entries are case-insensitive header names, a trailing "*" matches a prefix and "*" alone matches every header.
+// The denylist wins over the allowlist; an empty allowlist means DefaultForwardHeaders.
+type HeaderPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// HeaderPolicyProvider is implemented by configs that restrict forwarded headers
+type HeaderPolicyProvider interface {
+	GetHeaderPolicy() HeaderPolicy
+}
+
+// HeaderPolicySetter is implemented by adapters whose header policy can be set after creation
+// Account pool adapters are created from credentials, so the config's policy is applied afterwards
+type HeaderPolicySetter interface {
+	SetHeaderPolicy(policy HeaderPolicy)
+}
+
+// ApplyHeaderPolicy sets the header policy on the adapter if it supports it
+func ApplyHeaderPolicy(a Adapter, policy HeaderPolicy) {
+	if setter, ok := a.(HeaderPolicySetter); ok {
+		setter.SetHeaderPolicy(policy)
+	}
+}
+
+type inboundHeadersKey struct{}
+
+// WithInboundHeaders attaches the client's request headers to the context so adapters can forward the allowed ones
+func WithInboundHeaders(ctx context.Context, h http.Header) context.Context {
+	return context.WithValue(ctx, inboundHeadersKey{}, h.Clone())
+}
+
+// Forwards reports whether the inbound header is sent upstream under this policy
+func (p HeaderPolicy) Forwards(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if neverForwardedHeaders[name] || strings.HasPrefix(name, "X-Prism-") {
+		return false
+	}
+	if matchHeader(p.Deny, name) {
+		return false
+	}
+	allow := p.Allow
+	if len(allow) == 0 {
+		allow = DefaultForwardHeaders
+	}
+	return matchHeader(allow, name)
+}
+
+func matchHeader(patterns []string, name string) bool {
+	lower := strings.ToLower(name)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(lower, prefix) {
+				return true
+			}
+		} else if pattern == lower {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardHeaders copies the allowed inbound headers onto the upstream request.
+// Call it before the adapter sets its own headers so those always take precedence.
+func (c *Config) forwardHeaders(req *http.Request) {
+	inbound, ok := req.Context().Value(inboundHeadersKey{}).(http.Header)
+	if !ok {
+		return
+	}
+	for name, values := range inbound {
+		if c.HeaderPolicy.Forwards(name) {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+	}
+}
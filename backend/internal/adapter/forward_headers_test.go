@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIAdapter_ForwardsOnlyAllowedInboundHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	inbound := http.Header{}
+	inbound.Set("Authorization", "Bearer client-gateway-key")
+	inbound.Set("X-Tenant-ID", "acme")
+	inbound.Set("X-Trace-Span", "span-1")
+	inbound.Set("X-Trace-Secret", "internal")
+	inbound.Set("Traceparent", "00-abc-def-01")
+	inbound.Set("X-Prism-Semantic-Cache", "off")
+	ctx := WithInboundHeaders(context.Background(), inbound)
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "upstream-key", HeaderPolicy: HeaderPolicy{
+		Allow: []string{"x-tenant-id", "x-trace-*", "traceparent", "authorization"},
+		Deny:  []string{"Traceparent", "x-trace-secret"},
+	}})
+	if _, err := a.Call(ctx, &ChatRequest{Model: "gpt-4", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	if received.Get("X-Tenant-ID") != "acme" || received.Get("X-Trace-Span") != "span-1" {
+		t.Errorf("Expected allowlisted custom headers forwarded, got %v", received)
+	}
+	for _, name := range []string{"Traceparent", "X-Trace-Secret", "X-Prism-Semantic-Cache"} {
+		if v := received.Get(name); v != "" {
+			t.Errorf("Expected %s not forwarded, got %q", name, v)
+		}
+	}
+	if got := received.Get("Authorization"); got != "Bearer upstream-key" {
+		t.Errorf("Expected the client's Authorization never forwarded, got %q", got)
+	}
+}
+
+func TestHeaderPolicy_DefaultsToSafeAllowlist(t *testing.T) {
+	var policy HeaderPolicy
+	if !policy.Forwards("accept-language") || !policy.Forwards("Anthropic-Beta") {
+		t.Error("Expected the default allowlist to forward locale and beta headers")
+	}
+	for _, name := range []string{"X-Tenant-ID", "Traceparent", "Cookie", "Authorization"} {
+		if policy.Forwards(name) {
+			t.Errorf("Expected %s not forwarded by default", name)
+		}
+	}
+
+	if (HeaderPolicy{Allow: []string{"*"}, Deny: []string{"*"}}).Forwards("Accept-Language") {
+		t.Error("Expected a wildcard denylist to stop all forwarding")
+	}
+}
@@ -36,6 +36,24 @@ func (a *GeminiAdapter) GetType() string {
 	return "gemini"
 }
 
+// SetUserAgent overrides the user-agent template
+func (a *GeminiAdapter) SetUserAgent(template string) {
+	a.config.UserAgent = template
+}
+
+// Capabilities returns the features supported by the Gemini adapter
+func (a *GeminiAdapter) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, Tools: true, Vision: true, JSONMode: true, MaxTools: maxProviderTools}
+}
+
+// HealthCheck lists models as a lightweight probe
+func (a *GeminiAdapter) HealthCheck(ctx context.Context) error {
+	url := fmt.Sprintf("%s/v1/models?key=%s", a.config.BaseURL, a.config.APIKey)
+	return probeUpstream(ctx, a.config.Client, url, map[string]string{
+		"User-Agent": a.config.userAgent("gemini"),
+	})
+}
+
 // Gemini request/response structures
 type geminiRequest struct {
 	Contents           []geminiContent         `json:"contents"`
@@ -99,8 +117,8 @@ type geminiFunctionDeclaration struct {
 }
 
 type geminiGenerationConfig struct {
-	Temperature        float64                `json:"temperature,omitempty"`
-	TopP               float64                `json:"topP,omitempty"`
+	Temperature        *float64               `json:"temperature,omitempty"`
+	TopP               *float64               `json:"topP,omitempty"`
 	TopK               int                    `json:"topK,omitempty"`
 	MaxOutputTokens    int                    `json:"maxOutputTokens,omitempty"`
 	StopSequences      []string               `json:"stopSequences,omitempty"`
@@ -115,8 +133,15 @@ type geminiGenerationConfig struct {
 }
 
 type geminiResponse struct {
-	Candidates    []geminiCandidate `json:"candidates"`
-	UsageMetadata geminiUsage       `json:"usageMetadata"`
+	Candidates     []geminiCandidate     `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  geminiUsage           `json:"usageMetadata"`
+}
+
+// geminiPromptFeedback is set when the prompt itself was blocked, in which case there are no candidates
+type geminiPromptFeedback struct {
+	BlockReason        string `json:"blockReason"`
+	BlockReasonMessage string `json:"blockReasonMessage,omitempty"`
 }
 
 type geminiCandidate struct {
@@ -145,11 +170,11 @@ func (a *GeminiAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRespon
 	genConfig := &geminiGenerationConfig{}
 	hasConfig := false
 
-	if req.Temperature > 0 {
+	if req.Temperature != nil {
 		genConfig.Temperature = req.Temperature
 		hasConfig = true
 	}
-	if req.TopP > 0 {
+	if req.TopP != nil {
 		genConfig.TopP = req.TopP
 		hasConfig = true
 	}
@@ -278,6 +303,7 @@ genConfig.Seed = *req.Seed
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Gemini uses model in URL path
@@ -289,7 +315,9 @@ genConfig.Seed = *req.Seed
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", a.config.userAgent("gemini"))
 
 	// Make request
 	resp, err := a.config.Client.Do(httpReq)
@@ -303,6 +331,10 @@ genConfig.Seed = *req.Seed
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	recordResponseSize(ctx, len(respBody))
+
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
@@ -324,6 +356,8 @@ genConfig.Seed = *req.Seed
 func (a *GeminiAdapter) convertMessages(messages []Message) ([]geminiContent, *geminiContent) {
 	var contents []geminiContent
 	var systemInstruction *geminiContent
+	// Tool messages from OpenAI clients carry only tool_call_id; functionResponse needs the function name
+	toolCallNames := make(map[string]string)
 
 	for _, msg := range messages {
 		// Gemini uses "user" and "model" roles
@@ -334,15 +368,33 @@ func (a *GeminiAdapter) convertMessages(messages []Message) ([]geminiContent, *g
 
 		contentStr := GetContentAsString(msg.Content)
 
-		// Extract system message as system instruction
+		// Extract system messages as system instruction; each one becomes a part so none is dropped
 		if role == "system" {
 			if contentStr != "" {
-				systemInstruction = &geminiContent{
-					Parts: []geminiPart{
-						{Text: contentStr},
-					},
+				if systemInstruction == nil {
+					systemInstruction = &geminiContent{}
 				}
+				systemInstruction.Parts = append(systemInstruction.Parts, geminiPart{Text: contentStr})
+			}
+			continue
+		}
+
+		// Tool result message: functionResponse plus inline images, sent as a user turn;
+		// responses to parallel function calls share one turn
+		if msg.ToolCallID != "" {
+			name := msg.Name
+			if name == "" {
+				name = toolCallNames[msg.ToolCallID]
 			}
+			parts := convertToolResultParts(name, GetToolResultParts(msg.Content))
+			if n := len(contents); n > 0 && isFunctionResponseTurn(contents[n-1]) {
+				contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+				continue
+			}
+			contents = append(contents, geminiContent{
+				Role:  "user",
+				Parts: parts,
+			})
 			continue
 		}
 
@@ -421,6 +473,7 @@ func (a *GeminiAdapter) convertMessages(messages []Message) ([]geminiContent, *g
 		// Add tool calls if present
 		if len(msg.ToolCalls) > 0 {
 			for _, tc := range msg.ToolCalls {
+				toolCallNames[tc.ID] = tc.Function.Name
 				var args map[string]interface{}
 				json.Unmarshal([]byte(tc.Function.Arguments), &args)
 
@@ -433,23 +486,6 @@ func (a *GeminiAdapter) convertMessages(messages []Message) ([]geminiContent, *g
 			}
 		}
 
-		// Add tool result if present
-		if msg.ToolCallID != "" {
-			// This is a tool result message
-			var response map[string]interface{}
-			json.Unmarshal([]byte(contentStr), &response)
-			if response == nil {
-				response = map[string]interface{}{"result": contentStr}
-			}
-
-			parts = append(parts, geminiPart{
-				FunctionResponse: &geminiFunctionResponse{
-					Name:     msg.Name, // Tool name should be in Name field
-					Response: response,
-				},
-			})
-		}
-
 		if len(parts) > 0 {
 			contents = append(contents, geminiContent{
 				Role:  role,
@@ -461,6 +497,68 @@ func (a *GeminiAdapter) convertMessages(messages []Message) ([]geminiContent, *g
 	return contents, systemInstruction
 }
 
+// isFunctionResponseTurn reports whether the content is a user turn answering function calls
+func isFunctionResponseTurn(content geminiContent) bool {
+	return content.Role == "user" && len(content.Parts) > 0 && content.Parts[0].FunctionResponse != nil
+}
+
+// geminiDropsImage reports whether a tool result image cannot be sent inline; remote URLs are not supported
+func geminiDropsImage(part ToolResultPart) bool {
+	return part.Data == ""
+}
+
+// dropsToolResultImage reports whether a tool result image degrades to a placeholder
+func (a *GeminiAdapter) dropsToolResultImage(part ToolResultPart) bool {
+	return geminiDropsImage(part)
+}
+
+// convertToolResultParts converts typed tool result parts to Gemini parts
+// Text and JSON go into the functionResponse; inline images follow as inlineData parts
+func convertToolResultParts(name string, toolParts []ToolResultPart) []geminiPart {
+	var response map[string]interface{}
+	var rest []ToolResultPart
+	var images []geminiPart
+
+	for _, part := range toolParts {
+		if part.Type == ToolResultPartImage && !geminiDropsImage(part) {
+			images = append(images, geminiPart{
+				InlineData: &geminiInlineData{
+					MimeType: part.MediaType,
+					Data:     part.Data,
+				},
+			})
+			continue
+		}
+		if obj, ok := part.JSON.(map[string]interface{}); ok && response == nil {
+			response = make(map[string]interface{}, len(obj)+1)
+			for k, v := range obj {
+				response[k] = v
+			}
+			continue
+		}
+		// Remote image URLs are not supported and degrade to a placeholder
+		rest = append(rest, part)
+	}
+
+	text := toolResultText(rest)
+	if response == nil {
+		json.Unmarshal([]byte(text), &response)
+		if response == nil {
+			response = map[string]interface{}{"result": text}
+		}
+	} else if text != "" {
+		response["result"] = text
+	}
+
+	parts := []geminiPart{{
+		FunctionResponse: &geminiFunctionResponse{
+			Name:     name, // Tool name should be in Name field
+			Response: response,
+		},
+	}}
+	return append(parts, images...)
+}
+
 // convertTools converts OpenAI-style tools to Gemini format
 func (a *GeminiAdapter) convertTools(tools []Tool) []geminiToolConfig {
 	declarations := make([]geminiFunctionDeclaration, len(tools))
@@ -523,11 +621,11 @@ func (a *GeminiAdapter) convertResponse(resp *geminiResponse, model string) *Cha
 		choices[i] = ChatChoice{
 			Index:        candidate.Index,
 			Message:      msg,
-			FinishReason: convertGeminiFinishReason(candidate.FinishReason),
+			FinishReason: NormalizeFinishReason("gemini", candidate.FinishReason, len(toolCalls) > 0),
 		}
 	}
 
-	return &ChatResponse{
+	unified := &ChatResponse{
 		ID:      fmt.Sprintf("gemini-%d", time.Now().Unix()),
 		Model:   model,
 		Choices: choices,
@@ -537,20 +635,20 @@ func (a *GeminiAdapter) convertResponse(resp *geminiResponse, model string) *Cha
 			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
 		},
 	}
-}
 
-// convertGeminiFinishReason converts Gemini finish reason to OpenAI format
-func convertGeminiFinishReason(reason string) string {
-	switch reason {
-	case "STOP":
-		return "stop"
-	case "MAX_TOKENS":
-		return "length"
-	case "SAFETY", "RECITATION":
-		return "content_filter"
-	default:
-		return "stop"
+	// A blocked prompt has no candidates; report it as a single filtered choice
+	if len(choices) == 0 && resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" {
+		unified.Choices = []ChatChoice{{
+			Message:      Message{Role: "assistant", Content: ""},
+			FinishReason: FinishReasonContentFilter,
+		}}
+		unified.setRefusal(&unified.Choices[0], newRefusal("gemini", resp.PromptFeedback.BlockReason, FinishReasonContentFilter, resp.PromptFeedback.BlockReasonMessage))
+		return unified
 	}
+	for i, candidate := range resp.Candidates {
+		unified.setRefusal(&unified.Choices[i], newRefusal("gemini", candidate.FinishReason, choices[i].FinishReason, ""))
+	}
+	return unified
 }
 
 // CallStream makes a streaming request to Gemini API
@@ -567,11 +665,11 @@ func (a *GeminiAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http
 	genConfig := &geminiGenerationConfig{}
 	hasConfig := false
 
-	if req.Temperature > 0 {
+	if req.Temperature != nil {
 		genConfig.Temperature = req.Temperature
 		hasConfig = true
 	}
-	if req.TopP > 0 {
+	if req.TopP != nil {
 		genConfig.TopP = req.TopP
 		hasConfig = true
 	}
@@ -700,6 +798,7 @@ geminiReq.SafetySettings = safetySettings
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Gemini uses streamGenerateContent for streaming
@@ -711,7 +810,9 @@ geminiReq.SafetySettings = safetySettings
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", a.config.userAgent("gemini"))
 
 	// Make request and return response directly
 	resp, err := a.config.Client.Do(httpReq)
@@ -719,6 +820,9 @@ geminiReq.SafetySettings = safetySettings
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
+
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
@@ -0,0 +1,47 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGeminiAdapter_SystemMessagesSentAsSystemInstruction(t *testing.T) {
+	var got geminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(geminiResponse{Candidates: []geminiCandidate{{
+			Content:      geminiContent{Role: "model", Parts: []geminiPart{{Text: "ok"}}},
+			FinishReason: "STOP",
+		}}})
+	}))
+	defer server.Close()
+
+	adapter := NewGeminiAdapter(&Config{BaseURL: server.URL, APIKey: "test-key", Timeout: 30})
+	_, err := adapter.Call(context.Background(), &ChatRequest{
+		Model: "gemini-pro",
+		Messages: []Message{
+			{Role: "system", Content: "You are terse."},
+			{Role: "user", Content: "Hello"},
+			{Role: "system", Content: "Answer in French."},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got.SystemInstruction == nil || len(got.SystemInstruction.Parts) != 2 {
+		t.Fatalf("Expected both system messages in systemInstruction, got %+v", got.SystemInstruction)
+	}
+	if got.SystemInstruction.Parts[0].Text != "You are terse." || got.SystemInstruction.Parts[1].Text != "Answer in French." {
+		t.Errorf("Expected system messages in order, got %+v", got.SystemInstruction.Parts)
+	}
+	if len(got.Contents) != 1 || got.Contents[0].Role != "user" {
+		t.Errorf("Expected only the user turn in contents, got %+v", got.Contents)
+	}
+}
@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+)
+
+// MultiKeyConfig is implemented by configs that hold several upstream API keys
+// Keys are returned in priority order, the first one is the primary key
+type MultiKeyConfig interface {
+	GetAPIKeys() []string
+}
+
+// IsAuthError reports whether the upstream rejected the request's credentials (401 or 403)
+func IsAuthError(err error) bool {
+	status := UpstreamStatus(err)
+	return status == 401 || status == 403
+}
+
+// KeyFallbackAdapter tries the same upstream with each API key in order,
+// moving to the next key only when the current one fails authentication.
+// This lets a rotated-out primary keep working while the secondary takes over.
+type KeyFallbackAdapter struct {
+	adapters []Adapter
+}
+
+// NewKeyFallbackAdapter wraps one adapter per API key, adapters[0] being the primary
+func NewKeyFallbackAdapter(adapters []Adapter) *KeyFallbackAdapter {
+	return &KeyFallbackAdapter{adapters: adapters}
+}
+
+// Primary returns the adapter for the primary key
+func (a *KeyFallbackAdapter) Primary() Adapter {
+	return a.adapters[0]
+}
+
+// Call tries each key until one is accepted
+func (a *KeyFallbackAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var lastErr error
+	for _, candidate := range a.adapters {
+		resp, err := candidate.Call(ctx, req)
+		if !IsAuthError(err) {
+			return resp, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// CallStream tries each key until one is accepted
+// Auth failures happen before any data is streamed, so falling back is safe
+func (a *KeyFallbackAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.Response, error) {
+	var lastErr error
+	for _, candidate := range a.adapters {
+		resp, err := candidate.CallStream(ctx, req)
+		if !IsAuthError(err) {
+			return resp, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GetType returns the wrapped adapter type
+func (a *KeyFallbackAdapter) GetType() string {
+	return a.Primary().GetType()
+}
+
+// Capabilities returns the wrapped adapter's capabilities
+func (a *KeyFallbackAdapter) Capabilities() Capabilities {
+	return GetCapabilities(a.Primary())
+}
+
+// HealthCheck probes the upstream with the primary key
+func (a *KeyFallbackAdapter) HealthCheck(ctx context.Context) error {
+	return HealthCheck(ctx, a.Primary())
+}
+
+// dropsToolResultImage follows the wrapped adapter
+func (a *KeyFallbackAdapter) dropsToolResultImage(part ToolResultPart) bool {
+	dropper, ok := a.Primary().(toolResultImageDropper)
+	return ok && dropper.dropsToolResultImage(part)
+}
+
+// SetUserAgent overrides the user-agent template on every key's adapter
+func (a *KeyFallbackAdapter) SetUserAgent(template string) {
+	for _, candidate := range a.adapters {
+		ApplyUserAgent(candidate, template)
+	}
+}
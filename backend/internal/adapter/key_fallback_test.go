@@ -0,0 +1,135 @@
+package adapter
+
+import (
+	"api-aggregator/backend/pkg/crypto"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// multiKeyConfig is a direct config holding an ordered key set
+type multiKeyConfig struct {
+	baseURL string
+	keys    []string
+}
+
+func (c *multiKeyConfig) GetType() string      { return "openai" }
+func (c *multiKeyConfig) GetBaseURL() string   { return c.baseURL }
+func (c *multiKeyConfig) GetAPIKey() string    { return "legacy" }
+func (c *multiKeyConfig) GetTimeout() int      { return 5 }
+func (c *multiKeyConfig) GetUserAgent() string { return "" }
+func (c *multiKeyConfig) GetAPIKeys() []string { return c.keys }
+
+// keyServer accepts only the given key and records the keys it was called with
+func keyServer(valid string, status int, seen *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		*seen = append(*seen, key)
+		if key != valid {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":{"message":"rejected"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+}
+
+func encryptKeys(t *testing.T, box *crypto.SecretBox, keys ...string) []string {
+	t.Helper()
+	encrypted := make([]string, len(keys))
+	for i, key := range keys {
+		var err error
+		if encrypted[i], err = box.Encrypt(key); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+	}
+	return encrypted
+}
+
+func TestFactory_FallsBackToSecondaryKeyOnAuthFailure(t *testing.T) {
+	box, _ := crypto.NewSecretBox("test-secret")
+	var seen []string
+	server := keyServer("sk-secondary", http.StatusUnauthorized, &seen)
+	defer server.Close()
+
+	a, err := NewFactory().WithSecretBox(box).CreateAdapter(&multiKeyConfig{
+		baseURL: server.URL,
+		keys:    encryptKeys(t, box, "sk-primary", "sk-secondary"),
+	})
+	if err != nil {
+		t.Fatalf("CreateAdapter failed: %v", err)
+	}
+
+	resp, err := a.Call(context.Background(), metadataRequest())
+	if err != nil {
+		t.Fatalf("Expected secondary key to succeed, got %v", err)
+	}
+	if GetContentAsString(resp.Choices[0].Message.Content) != "ok" {
+		t.Errorf("Unexpected response: %+v", resp)
+	}
+	if strings.Join(seen, ",") != "sk-primary,sk-secondary" {
+		t.Errorf("Expected primary then secondary, got %v", seen)
+	}
+
+	seen = nil
+	stream, err := a.CallStream(context.Background(), metadataRequest())
+	if err != nil {
+		t.Fatalf("Expected stream fallback to succeed, got %v", err)
+	}
+	io.Copy(io.Discard, stream.Body)
+	stream.Body.Close()
+	if strings.Join(seen, ",") != "sk-primary,sk-secondary" {
+		t.Errorf("Expected stream to fall back to secondary, got %v", seen)
+	}
+}
+
+func TestFactory_DoesNotFallBackOnOtherErrors(t *testing.T) {
+	box, _ := crypto.NewSecretBox("test-secret")
+	var seen []string
+	server := keyServer("sk-secondary", http.StatusInternalServerError, &seen)
+	defer server.Close()
+
+	a, _ := NewFactory().WithSecretBox(box).CreateAdapter(&multiKeyConfig{
+		baseURL: server.URL,
+		keys:    encryptKeys(t, box, "sk-primary", "sk-secondary"),
+	})
+	if _, err := a.Call(context.Background(), metadataRequest()); err == nil {
+		t.Fatal("Expected upstream error")
+	}
+	if len(seen) != 1 {
+		t.Errorf("Expected no fallback for non-auth errors, got %v", seen)
+	}
+}
+
+func TestFactory_SingleOrUndecryptableKeysUsePlainAdapter(t *testing.T) {
+	box, _ := crypto.NewSecretBox("test-secret")
+	other, _ := crypto.NewSecretBox("other-secret")
+
+	a, _ := NewFactory().WithSecretBox(box).CreateAdapter(&multiKeyConfig{keys: encryptKeys(t, box, "sk-only")})
+	if openai, ok := a.(*OpenAIAdapter); !ok || openai.config.APIKey != "sk-only" {
+		t.Errorf("Expected plain adapter with the only key, got %T", a)
+	}
+
+	keys := append(encryptKeys(t, other, "sk-foreign"), encryptKeys(t, box, "sk-usable")...)
+	a, _ = NewFactory().WithSecretBox(box).CreateAdapter(&multiKeyConfig{keys: keys})
+	if openai, ok := a.(*OpenAIAdapter); !ok || openai.config.APIKey != "sk-usable" {
+		t.Errorf("Expected the undecryptable key skipped, got %T", a)
+	}
+}
+
+func TestFactory_NoDecryptableKeyIsAnError(t *testing.T) {
+	box, _ := crypto.NewSecretBox("test-secret")
+	other, _ := crypto.NewSecretBox("other-secret")
+	config := &multiKeyConfig{keys: encryptKeys(t, other, "sk-foreign")}
+
+	if a, err := NewFactory().WithSecretBox(box).CreateAdapter(config); err == nil {
+		t.Errorf("Expected an error instead of an adapter with an empty credential, got %T", a)
+	}
+	if a, err := NewFactory().CreateAdapter(config); err == nil {
+		t.Errorf("Expected an error without an encryption key, got %T", a)
+	}
+}
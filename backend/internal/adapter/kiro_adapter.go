@@ -63,6 +63,30 @@ func (a *KiroAdapter) GetType() string {
 	return "kiro"
 }
 
+// SetUserAgent overrides the user-agent template
+func (a *KiroAdapter) SetUserAgent(template string) {
+	a.config.UserAgent = template
+}
+
+// SetHeaderPolicy overrides which inbound headers are forwarded
+func (a *KiroAdapter) SetHeaderPolicy(policy HeaderPolicy) {
+	a.config.HeaderPolicy = policy
+}
+
+// Capabilities returns the features supported by the Kiro adapter
+func (a *KiroAdapter) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, Tools: true, Vision: true}
+}
+
+// HealthCheck verifies the adapter has credentials
+// Kiro has no cheap read-only endpoint, token validity is checked by the credential refresher
+func (a *KiroAdapter) HealthCheck(ctx context.Context) error {
+	if a.accessToken == "" {
+		return fmt.Errorf("kiro adapter has no access token")
+	}
+	return nil
+}
+
 // Kiro request/response structures
 type kiroRequest struct {
 	ConversationState kiroConversationState `json:"conversationState"`
@@ -71,9 +95,9 @@ type kiroRequest struct {
 }
 
 type kiroInferenceConfig struct {
-	MaxTokens   int     `json:"maxTokens,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"topP,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
 }
 
 type kiroConversationState struct {
@@ -145,7 +169,16 @@ type kiroToolResult struct {
 }
 
 type kiroToolResultContent struct {
-	Text string `json:"text"`
+	Text string      `json:"text"`
+	JSON interface{} `json:"json,omitempty"`
+}
+
+// MarshalJSON emits either a text or a json block, Kiro rejects blocks carrying both
+func (c kiroToolResultContent) MarshalJSON() ([]byte, error) {
+	if c.JSON != nil {
+		return json.Marshal(map[string]interface{}{"json": c.JSON})
+	}
+	return json.Marshal(map[string]string{"text": c.Text})
 }
 
 type kiroResponse struct {
@@ -167,6 +200,7 @@ func (a *KiroAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Kiro uses CodeWhisperer endpoint for AI_EDITOR origin
@@ -203,6 +237,10 @@ func (a *KiroAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	recordResponseSize(ctx, len(respBody))
+
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
@@ -234,6 +272,7 @@ func (a *KiroAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.R
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Kiro uses CodeWhisperer endpoint for AI_EDITOR origin
@@ -254,6 +293,9 @@ func (a *KiroAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.R
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
+
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
@@ -491,10 +533,8 @@ for i, msg := range nonSystemMessages {
 			if msg.ToolCallID != "" {
 				pendingToolResults = append(pendingToolResults, kiroToolResult{
 					ToolUseID: msg.ToolCallID,
-					Content: []kiroToolResultContent{
-						{Text: GetContentAsString(msg.Content)},
-					},
-					Status: "success",
+					Content:   convertToKiroToolResultContent(GetToolResultParts(msg.Content)),
+					Status:    "success",
 				})
 			}
 
@@ -620,17 +660,13 @@ for i, msg := range nonSystemMessages {
 	}
 
 	// Add inference config
-	if req.MaxTokens > 0 || req.Temperature > 0 || req.TopP > 0 {
+	if req.MaxTokens > 0 || req.Temperature != nil || req.TopP != nil {
 		kiroReq.InferenceConfig = &kiroInferenceConfig{}
 		if req.MaxTokens > 0 {
 			kiroReq.InferenceConfig.MaxTokens = req.MaxTokens
 		}
-		if req.Temperature > 0 {
-			kiroReq.InferenceConfig.Temperature = req.Temperature
-		}
-		if req.TopP > 0 {
-			kiroReq.InferenceConfig.TopP = req.TopP
-		}
+		kiroReq.InferenceConfig.Temperature = req.Temperature
+		kiroReq.InferenceConfig.TopP = req.TopP
 	}
 
 	return kiroReq, nil
@@ -858,6 +894,31 @@ func removeEmptyUserMessages(messages []kiroMessage) []kiroMessage {
 	return result
 }
 
+// dropsToolResultImage reports that Kiro tool results do not accept images
+func (a *KiroAdapter) dropsToolResultImage(part ToolResultPart) bool {
+	return true
+}
+
+// convertToKiroToolResultContent converts typed tool result parts to Kiro content blocks
+// Kiro does not accept images in tool results, so they degrade to a text placeholder
+func convertToKiroToolResultContent(parts []ToolResultPart) []kiroToolResultContent {
+	contents := make([]kiroToolResultContent, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case ToolResultPartJSON:
+			contents = append(contents, kiroToolResultContent{JSON: part.JSON})
+		case ToolResultPartImage:
+			contents = append(contents, kiroToolResultContent{Text: droppedImagePlaceholder(part)})
+		default:
+			contents = append(contents, kiroToolResultContent{Text: part.Text})
+		}
+	}
+	if len(contents) == 0 {
+		contents = append(contents, kiroToolResultContent{Text: ""})
+	}
+	return contents
+}
+
 // shortenToolName shortens tool names that are too long
 func shortenToolName(name string) string {
 	const limit = 64
@@ -911,7 +972,7 @@ func (a *KiroAdapter) convertResponse(resp *kiroResponse, model string) *ChatRes
 			{
 				Index:        0,
 				Message:      msg,
-				FinishReason: "stop",
+				FinishReason: NormalizeFinishReason("kiro", FinishReasonStop, len(toolCalls) > 0),
 			},
 		},
 		Usage: UsageInfo{
@@ -928,13 +989,18 @@ func (a *KiroAdapter) setKiroHeaders(req *http.Request) {
 	// Generate unique invocation ID for each request
 	invocationID := uuid.New().String()
 
-	// Kiro version (use a recent version from Kiro-account-manager)
-	kiroVersion := "0.6.18"
-
-	// User-Agent headers matching Kiro IDE
-	userAgent := fmt.Sprintf("aws-sdk-js/1.0.18 ua/2.1 os/windows lang/js md/nodejs#20.16.0 api/codewhispererstreaming#1.0.18 m/E KiroIDE-%s-%s", kiroVersion, a.machineID)
+	// User-Agent headers default to the Kiro IDE ones, the template is configurable per config
+	template := a.config.UserAgent
+	if template == "" {
+		template = DefaultKiroUserAgent
+	}
+	userAgent := ResolveUserAgent(template, "kiro", map[string]string{
+		"kiro_version": kiroVersion,
+		"machine_id":   a.machineID,
+	})
 	amzUserAgent := fmt.Sprintf("aws-sdk-js/1.0.18 KiroIDE-%s %s", kiroVersion, a.machineID)
 
+	a.config.forwardHeaders(req)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", "Bearer "+a.accessToken)
@@ -1156,7 +1222,7 @@ func (a *KiroAdapter) convertEventStreamResponse(content string, toolCalls []Too
 			{
 				Index:        0,
 				Message:      msg,
-				FinishReason: "stop",
+				FinishReason: NormalizeFinishReason("kiro", FinishReasonStop, len(toolCalls) > 0),
 			},
 		},
 		Usage: UsageInfo{
@@ -1263,6 +1329,20 @@ func (a *KiroAdapter) streamEventStreamToSSE(eventStreamBody io.Reader, sseWrite
 		argsBuffer string
 	}
 	currentToolUse := make(map[string]*toolUseState) // key: toolUseId
+	sawToolCalls := false
+
+	// Like OpenAI, announce the assistant role in a first chunk before any content or tool call deltas
+	chunkID++
+	roleChunk := ChatStreamChunk{
+		ID:      fmt.Sprintf("chatcmpl-%d", chunkID),
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []StreamChoice{{Index: 0, Delta: StreamDelta{Role: "assistant"}}},
+	}
+	if err := writeStreamChunk(sseWriter, &roleChunk); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
 
 	for {
 		n, err := eventStreamBody.Read(readBuf)
@@ -1329,8 +1409,7 @@ func (a *KiroAdapter) streamEventStreamToSSE(eventStreamBody io.Reader, sseWrite
 									},
 								}
 
-								chunkJSON, _ := json.Marshal(chunk)
-								fmt.Fprintf(sseWriter, "data: %s\n\n", string(chunkJSON))
+								writeStreamChunk(sseWriter, &chunk)
 							}
 						}
 
@@ -1406,8 +1485,8 @@ func (a *KiroAdapter) streamEventStreamToSSE(eventStreamBody io.Reader, sseWrite
 										},
 									}
 
-									chunkJSON, _ := json.Marshal(chunk)
-									fmt.Fprintf(sseWriter, "data: %s\n\n", string(chunkJSON))
+									writeStreamChunk(sseWriter, &chunk)
+									sawToolCalls = true
 									
 									// Clean up completed tool use
 									delete(currentToolUse, toolUseID)
@@ -1439,12 +1518,11 @@ func (a *KiroAdapter) streamEventStreamToSSE(eventStreamBody io.Reader, sseWrite
 						{
 							Index:        0,
 							Delta:        StreamDelta{},
-							FinishReason: "stop",
+							FinishReason: NormalizeFinishReason("kiro", FinishReasonStop, sawToolCalls),
 						},
 					},
 				}
-				chunkJSON, _ := json.Marshal(finalChunk)
-				fmt.Fprintf(sseWriter, "data: %s\n\n", string(chunkJSON))
+				writeStreamChunk(sseWriter, &finalChunk)
 				fmt.Fprintf(sseWriter, "data: [DONE]\n\n")
 				return nil
 			}
@@ -0,0 +1,54 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// eventStreamMessage encodes a payload as an AWS EventStream message without headers (CRCs are not checked)
+func eventStreamMessage(payload string) []byte {
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, uint32(12+len(payload)+4))
+	binary.Write(&msg, binary.BigEndian, uint32(0))
+	binary.Write(&msg, binary.BigEndian, uint32(0))
+	msg.WriteString(payload)
+	binary.Write(&msg, binary.BigEndian, uint32(0))
+	return msg.Bytes()
+}
+
+func TestKiroStream_FirstChunkCarriesAssistantRole(t *testing.T) {
+	var upstream bytes.Buffer
+	upstream.Write(eventStreamMessage(`{"assistantResponseEvent":{"content":"Hello"}}`))
+	upstream.Write(eventStreamMessage(`{"assistantResponseEvent":{"content":" world"}}`))
+
+	var out bytes.Buffer
+	if err := (&KiroAdapter{}).streamEventStreamToSSE(&upstream, &out, "claude-sonnet"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var deltas []StreamDelta
+	for _, event := range strings.Split(strings.TrimSpace(out.String()), "\n\n") {
+		data := strings.TrimPrefix(event, "data: ")
+		if data == "[DONE]" {
+			continue
+		}
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("Invalid chunk %q: %v", data, err)
+		}
+		deltas = append(deltas, chunk.Choices[0].Delta)
+	}
+
+	if len(deltas) != 4 {
+		t.Fatalf("Expected role, two content and finish chunks, got %+v", deltas)
+	}
+	if deltas[0].Role != "assistant" || deltas[0].Content != "" {
+		t.Errorf("Expected the first chunk to announce the assistant role without content, got %+v", deltas[0])
+	}
+	if deltas[1].Content != "Hello" || deltas[2].Content != " world" || deltas[1].Role != "" {
+		t.Errorf("Expected content deltas after the role chunk, got %+v", deltas[1:])
+	}
+}
@@ -0,0 +1,34 @@
+package adapter
+
+import "strings"
+
+// maxCompletionTokensModels lists model name prefixes that reject max_tokens and
+// expect the output limit as max_completion_tokens
+var maxCompletionTokensModels = []string{"o1", "o3", "o4", "gpt-5"}
+
+// UsesMaxCompletionTokens reports whether the model expects max_completion_tokens instead of max_tokens
+func UsesMaxCompletionTokens(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range maxCompletionTokensModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAIMaxTokens returns the output limit to send as max_tokens, 0 when the model expects max_completion_tokens
+func openAIMaxTokens(req *ChatRequest) int {
+	if UsesMaxCompletionTokens(req.Model) {
+		return 0
+	}
+	return req.MaxTokens
+}
+
+// openAIMaxCompletionTokens returns the output limit to send as max_completion_tokens
+func openAIMaxCompletionTokens(req *ChatRequest) int {
+	if !UsesMaxCompletionTokens(req.Model) {
+		return 0
+	}
+	return req.MaxTokens
+}
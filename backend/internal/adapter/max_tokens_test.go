@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenAIAdapter_MapsOutputLimitPerModel(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+
+	for model, field := range map[string]string{
+		"o3-mini": "max_completion_tokens",
+		"gpt-5":   "max_completion_tokens",
+		"gpt-4o":  "max_tokens",
+	} {
+		body = nil
+		req := &ChatRequest{Model: model, Messages: []Message{{Role: "user", Content: "hi"}}, MaxTokens: 300}
+		if _, err := a.Call(context.Background(), req); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if body[field] != float64(300) {
+			t.Errorf("%s: expected %s 300, got %v", model, field, body)
+		}
+		other := "max_tokens"
+		if field == other {
+			other = "max_completion_tokens"
+		}
+		if _, ok := body[other]; ok {
+			t.Errorf("%s: expected %s omitted, got %v", model, other, body[other])
+		}
+	}
+}
+
+func TestAnthropicAdapter_SendsOutputLimitAsMaxTokens(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+	defer server.Close()
+
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	req := &ChatRequest{Model: "claude-sonnet-4", Messages: []Message{{Role: "user", Content: "hi"}}, MaxTokens: 300}
+	if _, err := a.Call(context.Background(), req); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["max_tokens"] != float64(300) {
+		t.Errorf("Expected max_tokens 300, got %v", body["max_tokens"])
+	}
+	if _, ok := body["max_completion_tokens"]; ok {
+		t.Error("Expected no max_completion_tokens for Anthropic")
+	}
+}
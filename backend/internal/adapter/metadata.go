@@ -0,0 +1,51 @@
+package adapter
+
+import "fmt"
+
+// MetadataUserID is the unified metadata key identifying the end user
+const MetadataUserID = "user_id"
+
+// MergeMetadata adds defaults to the request metadata without overriding keys set by the request
+func MergeMetadata(req *ChatRequest, defaults map[string]string) {
+	if len(defaults) == 0 {
+		return
+	}
+	merged := make(map[string]interface{}, len(req.Metadata)+len(defaults))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range req.Metadata {
+		merged[k] = v
+	}
+	req.Metadata = merged
+}
+
+// metadataUserID returns the end-user identifier from metadata, falling back to the OpenAI user field
+func metadataUserID(req *ChatRequest) string {
+	if v, ok := req.Metadata[MetadataUserID]; ok && v != nil {
+		if s := fmt.Sprint(v); s != "" {
+			return s
+		}
+	}
+	return req.User
+}
+
+// anthropicMetadata maps metadata to Anthropic's metadata object
+// Anthropic only accepts user_id, other keys are dropped
+func anthropicMetadata(req *ChatRequest) map[string]interface{} {
+	userID := metadataUserID(req)
+	if userID == "" {
+		return nil
+	}
+	return map[string]interface{}{MetadataUserID: userID}
+}
+
+// openAIUser maps metadata to OpenAI's user field, an explicit user takes precedence
+func openAIUser(req *ChatRequest) string {
+	if req.User != "" {
+		return req.User
+	}
+	return metadataUserID(req)
+}
+
+// Gemini and Kiro have no end-user tagging field, metadata is not sent to them
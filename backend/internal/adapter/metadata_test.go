@@ -0,0 +1,94 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// captureServer records the decoded request body and replies with the given response
+func captureServer(t *testing.T, reply string, body *map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, body); err != nil {
+			t.Errorf("Invalid upstream body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(reply))
+	}))
+}
+
+func metadataRequest() *ChatRequest {
+	return &ChatRequest{
+		Model:    "m",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Metadata: map[string]interface{}{MetadataUserID: "u-42", "team": "search"},
+	}
+}
+
+func TestMergeMetadata_RequestKeysWin(t *testing.T) {
+	req := &ChatRequest{Metadata: map[string]interface{}{"team": "ads"}}
+	MergeMetadata(req, map[string]string{"team": "search", MetadataUserID: "u-1"})
+
+	want := map[string]interface{}{"team": "ads", MetadataUserID: "u-1"}
+	if !reflect.DeepEqual(req.Metadata, want) {
+		t.Errorf("Expected %v, got %v", want, req.Metadata)
+	}
+}
+
+func TestOpenAIAdapter_MapsMetadataToUser(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), metadataRequest()); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["user"] != "u-42" {
+		t.Errorf("Expected user from metadata, got %v", body["user"])
+	}
+
+	req := metadataRequest()
+	req.User = "explicit"
+	if _, err := a.Call(context.Background(), req); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["user"] != "explicit" {
+		t.Errorf("Expected explicit user to win, got %v", body["user"])
+	}
+}
+
+func TestAnthropicAdapter_MapsMetadataUserID(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+	defer server.Close()
+
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), metadataRequest()); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	want := map[string]interface{}{MetadataUserID: "u-42"}
+	if !reflect.DeepEqual(body["metadata"], want) {
+		t.Errorf("Expected only user_id forwarded, got %v", body["metadata"])
+	}
+}
+
+func TestGeminiAdapter_DropsMetadata(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"candidates":[]}`, &body)
+	defer server.Close()
+
+	a := NewGeminiAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	a.Call(context.Background(), metadataRequest())
+	if _, ok := body["metadata"]; ok {
+		t.Error("Expected metadata not sent to Gemini")
+	}
+	if _, ok := body["user"]; ok {
+		t.Error("Expected user not sent to Gemini")
+	}
+}
@@ -37,29 +37,52 @@ func (a *OpenAIAdapter) GetType() string {
 	return "openai"
 }
 
+// SetUserAgent overrides the user-agent template
+func (a *OpenAIAdapter) SetUserAgent(template string) {
+	a.config.UserAgent = template
+}
+
+// Capabilities returns the features supported by the OpenAI adapter
+func (a *OpenAIAdapter) Capabilities() Capabilities {
+	return Capabilities{Streaming: true, Tools: true, Vision: true, Embeddings: true, JSONMode: true, PromptCaching: true, MaxTools: maxProviderTools}
+}
+
+// HealthCheck lists models as a lightweight probe
+func (a *OpenAIAdapter) HealthCheck(ctx context.Context) error {
+	baseURL := strings.TrimSuffix(a.config.BaseURL, "/v1")
+	return probeUpstream(ctx, a.config.Client, baseURL+"/v1/models", map[string]string{
+		"Authorization": "Bearer " + a.config.APIKey,
+		"User-Agent":    a.config.userAgent("openai"),
+	})
+}
+
 // OpenAI request/response structures
 type openAIRequest struct {
-	Model              string                 `json:"model"`
-	Messages           []Message              `json:"messages"`
-	Temperature        float64                `json:"temperature,omitempty"`
-	TopP               float64                `json:"top_p,omitempty"`
-	MaxTokens          int                    `json:"max_tokens,omitempty"`
-	Stream             bool                   `json:"stream,omitempty"`
-	Stop               interface{}            `json:"stop,omitempty"` // string or []string
-	N                  int                    `json:"n,omitempty"`
-	PresencePenalty    float64                `json:"presence_penalty,omitempty"`
-	FrequencyPenalty   float64                `json:"frequency_penalty,omitempty"`
-	Tools              []Tool                 `json:"tools,omitempty"`
-	ToolChoice         interface{}            `json:"tool_choice,omitempty"`
-	User               string                 `json:"user,omitempty"`
-	Seed               *int                   `json:"seed,omitempty"`
-	LogitBias          map[string]int         `json:"logit_bias,omitempty"`
-	Logprobs           bool                   `json:"logprobs,omitempty"`
-	TopLogprobs        int                    `json:"top_logprobs,omitempty"`
-	ResponseFormat     *ResponseFormat        `json:"response_format,omitempty"`
-	ServiceTier        string                 `json:"service_tier,omitempty"`
-	ParallelToolCalls  *bool                  `json:"parallel_tool_calls,omitempty"`
-	StreamOptions      *StreamOptions         `json:"stream_options,omitempty"`
+	Model               string                 `json:"model"`
+	Messages            []Message              `json:"messages"`
+	Temperature         *float64               `json:"temperature,omitempty"`
+	TopP                *float64               `json:"top_p,omitempty"`
+	MaxTokens           int                    `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int                    `json:"max_completion_tokens,omitempty"`
+	Stream              bool                   `json:"stream,omitempty"`
+	Stop                interface{}            `json:"stop,omitempty"` // string or []string
+	N                   int                    `json:"n,omitempty"`
+	PresencePenalty     float64                `json:"presence_penalty,omitempty"`
+	FrequencyPenalty    float64                `json:"frequency_penalty,omitempty"`
+	Tools               []Tool                 `json:"tools,omitempty"`
+	ToolChoice          interface{}            `json:"tool_choice,omitempty"`
+	User                string                 `json:"user,omitempty"`
+	Seed                *int                   `json:"seed,omitempty"`
+	LogitBias           map[string]int         `json:"logit_bias,omitempty"`
+	Logprobs            bool                   `json:"logprobs,omitempty"`
+	TopLogprobs         int                    `json:"top_logprobs,omitempty"`
+	ResponseFormat      *ResponseFormat        `json:"response_format,omitempty"`
+	ServiceTier         string                 `json:"service_tier,omitempty"`
+	ReasoningEffort     string                 `json:"reasoning_effort,omitempty"`
+	ParallelToolCalls   *bool                  `json:"parallel_tool_calls,omitempty"`
+	StreamOptions       *StreamOptions         `json:"stream_options,omitempty"`
+	PromptCacheKey      string                 `json:"prompt_cache_key,omitempty"`
+	SafetyIdentifier    string                 `json:"safety_identifier,omitempty"`
 }
 
 type openAIResponse struct {
@@ -80,6 +103,7 @@ type openAIChoice struct {
 type openAIMessage struct {
 	Role      string     `json:"role"`
 	Content   string     `json:"content"`
+	Refusal   string     `json:"refusal,omitempty"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 }
 
@@ -89,31 +113,63 @@ type openAIUsage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
+// dropsToolResultImage reports that OpenAI tool messages only accept text, so every image is dropped
+func (a *OpenAIAdapter) dropsToolResultImage(part ToolResultPart) bool {
+	return true
+}
+
+// convertOpenAIMessages prepares messages for the OpenAI API
+// Tool messages only accept text, so typed tool results are flattened to a string
+func convertOpenAIMessages(messages []Message) []Message {
+	var converted []Message
+	for i, msg := range messages {
+		if msg.Role != "tool" {
+			continue
+		}
+		if _, ok := msg.Content.(string); ok || msg.Content == nil {
+			continue
+		}
+		if converted == nil {
+			converted = make([]Message, len(messages))
+			copy(converted, messages)
+		}
+		converted[i].Content = toolResultText(GetToolResultParts(msg.Content))
+	}
+	if converted == nil {
+		return messages
+	}
+	return converted
+}
+
 // Call makes a request to OpenAI API
 func (a *OpenAIAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
 	// Convert unified request to OpenAI format
 	openAIReq := &openAIRequest{
-		Model:             req.Model,
-		Messages:          req.Messages,
-		Temperature:       req.Temperature,
-		TopP:              req.TopP,
-		MaxTokens:         req.MaxTokens,
-		Stream:            req.Stream,
-		Stop:              req.Stop,
-		N:                 req.N,
-		PresencePenalty:   req.PresencePenalty,
-		FrequencyPenalty:  req.FrequencyPenalty,
-		Tools:             req.Tools,
-		ToolChoice:        req.ToolChoice,
-		User:              req.User,
-		Seed:              req.Seed,
-		LogitBias:         req.LogitBias,
-		Logprobs:          req.Logprobs,
-		TopLogprobs:       req.TopLogprobs,
-		ResponseFormat:    req.ResponseFormat,
-		ServiceTier:       req.ServiceTier,
-		ParallelToolCalls: req.ParallelToolCalls,
-		StreamOptions:     req.StreamOptions,
+		Model:               req.Model,
+		Messages:            convertOpenAIMessages(req.Messages),
+		Temperature:         req.Temperature,
+		TopP:                req.TopP,
+		MaxTokens:           openAIMaxTokens(req),
+		MaxCompletionTokens: openAIMaxCompletionTokens(req),
+		Stream:              req.Stream,
+		Stop:                req.Stop,
+		N:                   req.N,
+		PresencePenalty:     req.PresencePenalty,
+		FrequencyPenalty:    req.FrequencyPenalty,
+		Tools:               req.Tools,
+		ToolChoice:          req.ToolChoice,
+		User:                openAIUser(req),
+		Seed:                req.Seed,
+		LogitBias:           req.LogitBias,
+		Logprobs:            req.Logprobs,
+		TopLogprobs:         req.TopLogprobs,
+		ResponseFormat:      req.ResponseFormat,
+		ServiceTier:         req.ServiceTier,
+		ReasoningEffort:     openAIReasoningEffort(req),
+		ParallelToolCalls:   req.ParallelToolCalls,
+		StreamOptions:       req.StreamOptions,
+		PromptCacheKey:      req.PromptCacheKey,
+		SafetyIdentifier:    req.SafetyIdentifier,
 	}
 
 	// Marshal request
@@ -121,6 +177,7 @@ func (a *OpenAIAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRespon
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Handle base URLs that already include /v1
@@ -135,8 +192,10 @@ func (a *OpenAIAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRespon
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	httpReq.Header.Set("User-Agent", a.config.userAgent("openai"))
 
 	// Make request
 	resp, err := a.config.Client.Do(httpReq)
@@ -150,6 +209,10 @@ func (a *OpenAIAdapter) Call(ctx context.Context, req *ChatRequest) (*ChatRespon
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	recordResponseSize(ctx, len(respBody))
+
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
 
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
@@ -192,7 +255,7 @@ func (a *OpenAIAdapter) convertResponse(resp *openAIResponse) *ChatResponse {
 		}
 	}
 
-	return &ChatResponse{
+	unified := &ChatResponse{
 		ID:      resp.ID,
 		Object:  resp.Object,  // Preserve OpenAI's "chat.completion"
 		Created: resp.Created, // Preserve Unix timestamp
@@ -204,33 +267,48 @@ func (a *OpenAIAdapter) convertResponse(resp *openAIResponse) *ChatResponse {
 			TotalTokens:      resp.Usage.TotalTokens,
 		},
 	}
+
+	// A refusal is reported in message.refusal with finish_reason "stop"; Azure filters report content_filter
+	for i, choice := range resp.Choices {
+		reason := choice.FinishReason
+		if choice.Message.Refusal != "" {
+			reason = "refusal"
+			choices[i].FinishReason = FinishReasonContentFilter
+		}
+		unified.setRefusal(&choices[i], newRefusal("openai", reason, choices[i].FinishReason, choice.Message.Refusal))
+	}
+	return unified
 }
 
 // CallStream makes a streaming request to OpenAI API
 func (a *OpenAIAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http.Response, error) {
 	// Convert unified request to OpenAI format with stream enabled
 	openAIReq := &openAIRequest{
-		Model:             req.Model,
-		Messages:          req.Messages,
-		Temperature:       req.Temperature,
-		TopP:              req.TopP,
-		MaxTokens:         req.MaxTokens,
-		Stream:            true,
-		Stop:              req.Stop,
-		N:                 req.N,
-		PresencePenalty:   req.PresencePenalty,
-		FrequencyPenalty:  req.FrequencyPenalty,
-		Tools:             req.Tools,
-		ToolChoice:        req.ToolChoice,
-		User:              req.User,
-		Seed:              req.Seed,
-		LogitBias:         req.LogitBias,
-		Logprobs:          req.Logprobs,
-		TopLogprobs:       req.TopLogprobs,
-		ResponseFormat:    req.ResponseFormat,
-		ServiceTier:       req.ServiceTier,
-		ParallelToolCalls: req.ParallelToolCalls,
-		StreamOptions:     req.StreamOptions,
+		Model:               req.Model,
+		Messages:            convertOpenAIMessages(req.Messages),
+		Temperature:         req.Temperature,
+		TopP:                req.TopP,
+		MaxTokens:           openAIMaxTokens(req),
+		MaxCompletionTokens: openAIMaxCompletionTokens(req),
+		Stream:              true,
+		Stop:                req.Stop,
+		N:                   req.N,
+		PresencePenalty:     req.PresencePenalty,
+		FrequencyPenalty:    req.FrequencyPenalty,
+		Tools:               req.Tools,
+		ToolChoice:          req.ToolChoice,
+		User:                openAIUser(req),
+		Seed:                req.Seed,
+		LogitBias:           req.LogitBias,
+		Logprobs:            req.Logprobs,
+		TopLogprobs:         req.TopLogprobs,
+		ResponseFormat:      req.ResponseFormat,
+		ServiceTier:         req.ServiceTier,
+		ReasoningEffort:     openAIReasoningEffort(req),
+		ParallelToolCalls:   req.ParallelToolCalls,
+		StreamOptions:       req.StreamOptions,
+		PromptCacheKey:      req.PromptCacheKey,
+		SafetyIdentifier:    req.SafetyIdentifier,
 	}
 
 	// Marshal request
@@ -238,6 +316,7 @@ func (a *OpenAIAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
+	recordRequestSize(ctx, len(reqBody))
 
 	// Create HTTP request
 	// Handle base URLs that already include /v1
@@ -252,8 +331,10 @@ func (a *OpenAIAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http
 	}
 
 	// Set headers
+	a.config.forwardHeaders(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	httpReq.Header.Set("User-Agent", a.config.userAgent("openai"))
 	httpReq.Header.Set("Accept", "text/event-stream")
 
 	// Make request and return response directly
@@ -262,6 +343,9 @@ func (a *OpenAIAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
+
 	// Check status code
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
@@ -272,3 +356,57 @@ func (a *OpenAIAdapter) CallStream(ctx context.Context, req *ChatRequest) (*http
 	// Return response for streaming (caller must close body)
 	return resp, nil
 }
+
+// MaxEmbeddingBatch returns the number of inputs OpenAI accepts per embeddings request
+func (a *OpenAIAdapter) MaxEmbeddingBatch() int {
+	return maxOpenAIEmbeddingBatch
+}
+
+// Embed calls the embeddings endpoint
+func (a *OpenAIAdapter) Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	recordRequestSize(ctx, len(reqBody))
+
+	baseURL := strings.TrimSuffix(a.config.BaseURL, "/v1")
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", baseURL+"/v1/embeddings", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	a.config.forwardHeaders(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	httpReq.Header.Set("User-Agent", a.config.userAgent("openai"))
+
+	resp, err := a.config.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	recordResponseSize(ctx, len(respBody))
+	recordRateLimitHeaders(ctx, resp.Header)
+	recordUpstreamRequestID(ctx, resp.Header)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var embedResp EmbeddingResponse
+	if err := json.Unmarshal(respBody, &embedResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if len(embedResp.Data) != len(req.Input) {
+		return nil, fmt.Errorf("upstream returned %d embeddings for %d inputs", len(embedResp.Data), len(req.Input))
+	}
+	if embedResp.Usage.TotalTokens == 0 {
+		embedResp.Usage.TotalTokens = embedResp.Usage.PromptTokens
+	}
+	return &embedResp, nil
+}
@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// PayloadSizes records upstream request/response body sizes in bytes.
+// Adapters fill it in where bodies are marshaled and read; for streaming
+// calls only the request size is known here and the caller counts the rest.
+type PayloadSizes struct {
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+}
+
+type payloadSizesKey struct{}
+
+// WithPayloadSizes attaches a fresh size recorder to the context
+func WithPayloadSizes(ctx context.Context) (context.Context, *PayloadSizes) {
+	sizes := &PayloadSizes{}
+	return context.WithValue(ctx, payloadSizesKey{}, sizes), sizes
+}
+
+// RequestBytes returns the recorded request body size
+func (p *PayloadSizes) RequestBytes() int64 {
+	return p.requestBytes.Load()
+}
+
+// ResponseBytes returns the recorded response body size
+func (p *PayloadSizes) ResponseBytes() int64 {
+	return p.responseBytes.Load()
+}
+
+// AddResponseBytes adds to the response size (used while streaming)
+func (p *PayloadSizes) AddResponseBytes(n int64) {
+	p.responseBytes.Add(n)
+}
+
+// recordRequestSize stores the marshaled request body size, if a recorder is attached
+func recordRequestSize(ctx context.Context, n int) {
+	if sizes, ok := ctx.Value(payloadSizesKey{}).(*PayloadSizes); ok {
+		sizes.requestBytes.Store(int64(n))
+	}
+}
+
+// recordResponseSize stores the read response body size, if a recorder is attached
+func recordResponseSize(ctx context.Context, n int) {
+	if sizes, ok := ctx.Value(payloadSizesKey{}).(*PayloadSizes); ok {
+		sizes.responseBytes.Store(int64(n))
+	}
+}
@@ -0,0 +1,47 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test that the adapter records upstream request/response body sizes
+func TestOpenAIAdapter_RecordsPayloadSizes(t *testing.T) {
+	const respBody = `{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(respBody))
+	}))
+	defer server.Close()
+
+	adapter := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "test-key", Timeout: 30})
+	ctx, sizes := WithPayloadSizes(context.Background())
+
+	_, err := adapter.Call(ctx, &ChatRequest{
+		Model:    "gpt-4",
+		Messages: []Message{{Role: "user", Content: "Hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if received == 0 || sizes.RequestBytes() != int64(received) {
+		t.Errorf("Expected request bytes %d, got %d", received, sizes.RequestBytes())
+	}
+	if sizes.ResponseBytes() != int64(len(respBody)) {
+		t.Errorf("Expected response bytes %d, got %d", len(respBody), sizes.ResponseBytes())
+	}
+}
+
+// Test that calls without a recorder in the context are unaffected
+func TestRecordPayloadSize_WithoutRecorder(t *testing.T) {
+	recordRequestSize(context.Background(), 10)
+	recordResponseSize(context.Background(), 10)
+}
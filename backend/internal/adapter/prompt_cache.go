@@ -0,0 +1,33 @@
+package adapter
+
+// PromptTokensDetails breaks down prompt tokens, mirroring OpenAI's usage.prompt_tokens_details
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"` // ephemeral
+}
+
+// markAnthropicCachePrefix sets a cache breakpoint on the message before the last one,
+// so everything up to it is cached and later requests only pay full price for the final message
+func markAnthropicCachePrefix(messages []anthropicMessage) {
+	if len(messages) < 2 {
+		return
+	}
+	msg := &messages[len(messages)-2]
+	switch content := msg.Content.(type) {
+	case string:
+		if content == "" {
+			return
+		}
+		msg.Content = []anthropicContent{{Type: "text", Text: content, CacheControl: &anthropicCacheControl{Type: "ephemeral"}}}
+	case []anthropicContent:
+		if len(content) > 0 {
+			content[len(content)-1].CacheControl = &anthropicCacheControl{Type: "ephemeral"}
+		}
+	}
+}
+
+// OpenAI caches long prompt prefixes automatically and reports them in prompt_tokens_details,
+// so no request change is needed there. Gemini and Kiro have no prompt caching.
@@ -0,0 +1,75 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+)
+
+func cacheIdentifierRequest() *ChatRequest {
+	return &ChatRequest{
+		Model:            "gpt-4o",
+		Messages:         []Message{{Role: "user", Content: "hi"}},
+		PromptCacheKey:   "tenant-42",
+		SafetyIdentifier: "user-hash-1",
+	}
+}
+
+func TestOpenAIAdapter_ForwardsPromptCacheKeyAndSafetyIdentifier(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), cacheIdentifierRequest()); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["prompt_cache_key"] != "tenant-42" || body["safety_identifier"] != "user-hash-1" {
+		t.Errorf("Expected prompt_cache_key and safety_identifier forwarded, got %v / %v",
+			body["prompt_cache_key"], body["safety_identifier"])
+	}
+}
+
+func TestOpenAIAdapter_OmitsUnsetCacheIdentifiers(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	req := &ChatRequest{Model: "gpt-4o", Messages: []Message{{Role: "user", Content: "hi"}}}
+	if _, err := a.Call(context.Background(), req); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	for _, field := range []string{"prompt_cache_key", "safety_identifier"} {
+		if _, ok := body[field]; ok {
+			t.Errorf("Expected %s omitted when unset", field)
+		}
+	}
+}
+
+func TestNonOpenAIAdapters_DropCacheIdentifiers(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), cacheIdentifierRequest()); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	server.Close()
+	if _, ok := body["prompt_cache_key"]; ok {
+		t.Error("Expected prompt_cache_key not sent to Anthropic")
+	}
+	if _, ok := body["safety_identifier"]; ok {
+		t.Error("Expected safety_identifier not sent to Anthropic")
+	}
+
+	body = nil
+	server = captureServer(t, `{"candidates":[]}`, &body)
+	defer server.Close()
+	g := NewGeminiAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	g.Call(context.Background(), cacheIdentifierRequest())
+	if _, ok := body["prompt_cache_key"]; ok {
+		t.Error("Expected prompt_cache_key not sent to Gemini")
+	}
+	if _, ok := body["safety_identifier"]; ok {
+		t.Error("Expected safety_identifier not sent to Gemini")
+	}
+}
@@ -0,0 +1,63 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnthropicAdapter_MarksCachePrefix(t *testing.T) {
+	var body map[string]interface{}
+	reply := `{"id":"1","type":"message","role":"assistant","content":[],"usage":{"input_tokens":5,"output_tokens":2,"cache_read_input_tokens":1200}}`
+	server := captureServer(t, reply, &body)
+	defer server.Close()
+
+	req := &ChatRequest{
+		Model: "m",
+		Messages: []Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "long document"},
+			{Role: "assistant", Content: "noted"},
+			{Role: "user", Content: "question"},
+		},
+		CachePrefix: true,
+	}
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	resp, err := a.Call(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+
+	messages := body["messages"].([]interface{})
+	prefixEnd := messages[len(messages)-2].(map[string]interface{})
+	blocks, ok := prefixEnd["content"].([]interface{})
+	if !ok || len(blocks) != 1 {
+		t.Fatalf("Expected prefix end converted to a content block, got %v", prefixEnd["content"])
+	}
+	if cc, _ := blocks[0].(map[string]interface{})["cache_control"].(map[string]interface{}); cc["type"] != "ephemeral" {
+		t.Errorf("Expected ephemeral cache_control on the prefix end, got %v", blocks[0])
+	}
+	if last := messages[len(messages)-1].(map[string]interface{}); last["content"] != "question" {
+		t.Errorf("Expected final message left unmarked, got %v", last["content"])
+	}
+
+	if resp.Usage.PromptTokens != 1205 || resp.Usage.PromptTokensDetails == nil || resp.Usage.PromptTokensDetails.CachedTokens != 1200 {
+		t.Errorf("Expected cached tokens reported in usage, got %+v", resp.Usage)
+	}
+}
+
+func TestAnthropicAdapter_NoCachePrefixByDefault(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+	defer server.Close()
+
+	req := &ChatRequest{Model: "m", Messages: []Message{{Role: "user", Content: "a"}, {Role: "assistant", Content: "b"}, {Role: "user", Content: "c"}}}
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), req); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	for _, m := range body["messages"].([]interface{}) {
+		if _, ok := m.(map[string]interface{})["content"].(string); !ok {
+			t.Errorf("Expected plain string content without cache_control, got %v", m)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request-count rate-limit headers, most specific first: OpenAI, Anthropic, then the generic form
+var (
+	rateLimitLimitHeaders     = []string{"x-ratelimit-limit-requests", "anthropic-ratelimit-requests-limit", "x-ratelimit-limit"}
+	rateLimitRemainingHeaders = []string{"x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining", "x-ratelimit-remaining"}
+	rateLimitResetHeaders     = []string{"x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset", "x-ratelimit-reset"}
+)
+
+// defaultRateLimitWindow is assumed when a provider reports remaining requests without a reset time
+const defaultRateLimitWindow = time.Minute
+
+// RateLimitState is the request rate-limit window reported by an upstream response
+type RateLimitState struct {
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// ParseRateLimitHeaders reads the request-count limit, remaining count and reset time from
+// provider rate-limit headers. Reset accepts RFC 3339 timestamps, Go durations ("6m0s"),
+// epoch seconds and delta seconds. ok is false when the response carries no usable limit.
+func ParseRateLimitHeaders(h http.Header, now time.Time) (RateLimitState, bool) {
+	limit, okLimit := headerInt(h, rateLimitLimitHeaders)
+	remaining, okRemaining := headerInt(h, rateLimitRemainingHeaders)
+	if !okLimit || !okRemaining || limit <= 0 {
+		return RateLimitState{}, false
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining > limit {
+		remaining = limit
+	}
+
+	state := RateLimitState{Limit: limit, Remaining: remaining, ResetAt: now.Add(defaultRateLimitWindow)}
+	if reset := headerValue(h, rateLimitResetHeaders); reset != "" {
+		if at, ok := parseRateLimitReset(reset, now); ok {
+			state.ResetAt = at
+		}
+	}
+	return state, true
+}
+
+func parseRateLimitReset(value string, now time.Time) (time.Time, bool) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, true
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), true
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+		// Values this large are epoch timestamps rather than a delta
+		if secs > 1e9 {
+			return time.Unix(int64(secs), 0), true
+		}
+		return now.Add(time.Duration(secs * float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+func headerValue(h http.Header, names []string) string {
+	for _, name := range names {
+		if v := strings.TrimSpace(h.Get(name)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func headerInt(h http.Header, names []string) (int, bool) {
+	v := headerValue(h, names)
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+// RateLimitRecorder captures the rate-limit state of the last upstream response
+type RateLimitRecorder struct {
+	mu    sync.Mutex
+	state RateLimitState
+	ok    bool
+}
+
+type rateLimitRecorderKey struct{}
+
+// WithRateLimitRecorder attaches a fresh rate-limit recorder to the context
+func WithRateLimitRecorder(ctx context.Context) (context.Context, *RateLimitRecorder) {
+	recorder := &RateLimitRecorder{}
+	return context.WithValue(ctx, rateLimitRecorderKey{}, recorder), recorder
+}
+
+// State returns the recorded rate-limit state; ok is false if the upstream sent none
+func (r *RateLimitRecorder) State() (RateLimitState, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state, r.ok
+}
+
+// recordRateLimitHeaders stores the response's rate-limit state, if a recorder is attached
+func recordRateLimitHeaders(ctx context.Context, h http.Header) {
+	recorder, ok := ctx.Value(rateLimitRecorderKey{}).(*RateLimitRecorder)
+	if !ok {
+		return
+	}
+	if state, ok := ParseRateLimitHeaders(h, time.Now()); ok {
+		recorder.mu.Lock()
+		recorder.state, recorder.ok = state, true
+		recorder.mu.Unlock()
+	}
+}
@@ -0,0 +1,89 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    RateLimitState
+		ok      bool
+	}{
+		{
+			name:    "openai duration reset",
+			headers: map[string]string{"x-ratelimit-limit-requests": "500", "x-ratelimit-remaining-requests": "12", "x-ratelimit-reset-requests": "6m0s"},
+			want:    RateLimitState{Limit: 500, Remaining: 12, ResetAt: now.Add(6 * time.Minute)},
+			ok:      true,
+		},
+		{
+			name:    "anthropic timestamp reset",
+			headers: map[string]string{"anthropic-ratelimit-requests-limit": "50", "anthropic-ratelimit-requests-remaining": "0", "anthropic-ratelimit-requests-reset": "2024-05-01T12:00:30Z"},
+			want:    RateLimitState{Limit: 50, Remaining: 0, ResetAt: now.Add(30 * time.Second)},
+			ok:      true,
+		},
+		{
+			name:    "generic epoch reset",
+			headers: map[string]string{"X-RateLimit-Limit": "100", "X-RateLimit-Remaining": "40", "X-RateLimit-Reset": "1714564860"},
+			want:    RateLimitState{Limit: 100, Remaining: 40, ResetAt: time.Unix(1714564860, 0)},
+			ok:      true,
+		},
+		{
+			name:    "remaining clamped and missing reset falls back to a minute",
+			headers: map[string]string{"x-ratelimit-limit": "100", "x-ratelimit-remaining": "150"},
+			want:    RateLimitState{Limit: 100, Remaining: 100, ResetAt: now.Add(time.Minute)},
+			ok:      true,
+		},
+		{
+			name:    "token limits only",
+			headers: map[string]string{"x-ratelimit-limit-tokens": "10000", "x-ratelimit-remaining-tokens": "9000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+			got, ok := ParseRateLimitHeaders(h, now)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if ok && (got.Limit != tt.want.Limit || got.Remaining != tt.want.Remaining || !got.ResetAt.Equal(tt.want.ResetAt)) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenAIAdapter_RecordsRateLimitHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-requests", "60")
+		w.Header().Set("x-ratelimit-remaining-requests", "3")
+		w.Header().Set("x-ratelimit-reset-requests", "20s")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	adapter := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "test-key", Timeout: 30})
+	ctx, recorder := WithRateLimitRecorder(context.Background())
+	if _, err := adapter.Call(ctx, &ChatRequest{Model: "gpt-4", Messages: []Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	state, ok := recorder.State()
+	if !ok || state.Limit != 60 || state.Remaining != 3 {
+		t.Fatalf("Expected recorded limit 60 with 3 remaining, got %+v (ok=%v)", state, ok)
+	}
+	if until := time.Until(state.ResetAt); until <= 0 || until > 20*time.Second {
+		t.Errorf("Expected reset about 20s ahead, got %v", until)
+	}
+}
@@ -0,0 +1,57 @@
+package adapter
+
+import "strings"
+
+// reasoning_effort values accepted on the unified request
+const (
+	ReasoningEffortLow    = "low"
+	ReasoningEffortMedium = "medium"
+	ReasoningEffortHigh   = "high"
+)
+
+// reasoningEffortModels lists model name prefixes that accept reasoning_effort.
+// Earlier o1 previews reason at a fixed effort and reject the parameter
+var reasoningEffortModels = []string{"o1", "o3", "o4", "gpt-5", "deepseek-reasoner"}
+
+var fixedEffortModels = []string{"o1-mini", "o1-preview"}
+
+// reasoningTokenEstimates is the typical number of hidden reasoning tokens per effort level,
+// used to estimate cost when the request does not bound output with max_tokens
+var reasoningTokenEstimates = map[string]int{
+	ReasoningEffortLow:    1024,
+	ReasoningEffortMedium: 4096,
+	ReasoningEffortHigh:   16384,
+}
+
+// SupportsReasoningEffort reports whether the model accepts reasoning_effort
+func SupportsReasoningEffort(model string) bool {
+	model = strings.ToLower(model)
+	for _, prefix := range fixedEffortModels {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	for _, prefix := range reasoningEffortModels {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAIReasoningEffort drops reasoning_effort for models that would reject it
+func openAIReasoningEffort(req *ChatRequest) string {
+	if !SupportsReasoningEffort(req.Model) {
+		return ""
+	}
+	return req.ReasoningEffort
+}
+
+// EstimatedReasoningTokens returns the expected reasoning tokens for the effort level,
+// 0 when the model does not reason or no effort is set
+func EstimatedReasoningTokens(model, effort string) int {
+	if !SupportsReasoningEffort(model) {
+		return 0
+	}
+	return reasoningTokenEstimates[effort]
+}
@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSupportsReasoningEffort(t *testing.T) {
+	for model, want := range map[string]bool{
+		"o3-mini":           true,
+		"o1":                true,
+		"o4-mini-2025":      true,
+		"gpt-5":             true,
+		"deepseek-reasoner": true,
+		"o1-mini":           false,
+		"gpt-4o":            false,
+		"deepseek-chat":     false,
+		"claude-3-opus":     false,
+	} {
+		if got := SupportsReasoningEffort(model); got != want {
+			t.Errorf("%s: expected %v, got %v", model, want, got)
+		}
+	}
+}
+
+func TestOpenAIAdapter_ForwardsReasoningEffortToReasoningModels(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+
+	for model, want := range map[string]interface{}{
+		"o3-mini":           "high",
+		"deepseek-reasoner": "high",
+		"gpt-4o":            nil,
+	} {
+		body = nil
+		req := &ChatRequest{Model: model, Messages: []Message{{Role: "user", Content: "hi"}}, ReasoningEffort: "high"}
+		if _, err := a.Call(context.Background(), req); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if body["reasoning_effort"] != want {
+			t.Errorf("%s: expected reasoning_effort %v, got %v", model, want, body["reasoning_effort"])
+		}
+	}
+}
+
+func TestAnthropicAdapter_DropsReasoningEffort(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+	defer server.Close()
+
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	req := &ChatRequest{Model: "o3-mini", Messages: []Message{{Role: "user", Content: "hi"}}, ReasoningEffort: "high"}
+	if _, err := a.Call(context.Background(), req); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if _, ok := body["reasoning_effort"]; ok {
+		t.Errorf("Expected reasoning_effort to be dropped, got %v", body["reasoning_effort"])
+	}
+}
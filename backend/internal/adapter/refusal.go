@@ -0,0 +1,53 @@
+package adapter
+
+// RefusalTypeContentPolicy is the only refusal type reported today
+const RefusalTypeContentPolicy = "content_policy"
+
+// Refusal describes a provider declining to answer on content-policy grounds.
+// Providers report this differently (OpenAI's message.refusal, Anthropic's stop_reason "refusal",
+// Gemini's safety finish reasons and prompt blocks); adapters normalize all of them to this shape
+// and a content_filter finish reason.
+type Refusal struct {
+	Type     string `json:"type"`
+	Provider string `json:"provider"`
+	Reason   string `json:"reason"`            // the provider's own reason, e.g. refusal, SAFETY, PROHIBITED_CONTENT
+	Message  string `json:"message,omitempty"` // explanation returned by the provider, if any
+}
+
+// newRefusal returns a content-policy refusal, or nil when the choice was not filtered
+func newRefusal(provider, reason, finishReason, message string) *Refusal {
+	if finishReason != FinishReasonContentFilter {
+		return nil
+	}
+	return &Refusal{Type: RefusalTypeContentPolicy, Provider: provider, Reason: reason, Message: message}
+}
+
+// setRefusal records a choice's refusal on the response, the first refused choice wins.
+// The refusal text is also exposed as message.refusal so OpenAI clients see the native field.
+func (r *ChatResponse) setRefusal(choice *ChatChoice, refusal *Refusal) {
+	if refusal == nil {
+		return
+	}
+	if choice.Message.Refusal == "" {
+		choice.Message.Refusal = refusal.Message
+	}
+	if r.Refusal == nil {
+		r.Refusal = refusal
+	}
+}
+
+// IsRefusal reports whether the provider refused to answer on content-policy grounds
+func (r *ChatResponse) IsRefusal() bool {
+	if r == nil {
+		return false
+	}
+	if r.Refusal != nil {
+		return true
+	}
+	for _, choice := range r.Choices {
+		if choice.FinishReason == FinishReasonContentFilter {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package adapter
+
+import "testing"
+
+func TestRefusals_MapToContentFilter(t *testing.T) {
+	cases := []struct {
+		name    string
+		resp    *ChatResponse
+		reason  string
+		message string
+	}{
+		{
+			name: "openai refusal field",
+			resp: NewOpenAIAdapter(&Config{}).convertResponse(&openAIResponse{Choices: []openAIChoice{{
+				Message:      openAIMessage{Role: "assistant", Refusal: "I can't help with that."},
+				FinishReason: "stop",
+			}}}),
+			reason:  "refusal",
+			message: "I can't help with that.",
+		},
+		{
+			name: "openai content filter",
+			resp: NewOpenAIAdapter(&Config{}).convertResponse(&openAIResponse{Choices: []openAIChoice{{
+				Message:      openAIMessage{Role: "assistant"},
+				FinishReason: "content_filter",
+			}}}),
+			reason: "content_filter",
+		},
+		{
+			name: "anthropic stop_reason",
+			resp: NewAnthropicAdapter(&Config{}).convertResponse(&anthropicResponse{
+				Role:       "assistant",
+				Content:    []anthropicContent{{Type: "text", Text: "I won't do that."}},
+				StopReason: "refusal",
+			}),
+			reason:  "refusal",
+			message: "I won't do that.",
+		},
+		{
+			name: "gemini safety finish",
+			resp: NewGeminiAdapter(&Config{}).convertResponse(&geminiResponse{Candidates: []geminiCandidate{{
+				Content:      geminiContent{Role: "model"},
+				FinishReason: "SAFETY",
+			}}}, "gemini-pro"),
+			reason: "SAFETY",
+		},
+		{
+			name: "gemini blocked prompt",
+			resp: NewGeminiAdapter(&Config{}).convertResponse(&geminiResponse{
+				PromptFeedback: &geminiPromptFeedback{BlockReason: "PROHIBITED_CONTENT", BlockReasonMessage: "blocked"},
+			}, "gemini-pro"),
+			reason:  "PROHIBITED_CONTENT",
+			message: "blocked",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.resp.Choices) != 1 || tc.resp.Choices[0].FinishReason != FinishReasonContentFilter {
+				t.Fatalf("Expected a single content_filter choice, got %+v", tc.resp.Choices)
+			}
+			refusal := tc.resp.Refusal
+			if refusal == nil || refusal.Type != RefusalTypeContentPolicy || refusal.Reason != tc.reason || refusal.Message != tc.message {
+				t.Fatalf("Expected refusal with reason %q and message %q, got %+v", tc.reason, tc.message, refusal)
+			}
+			if tc.resp.Choices[0].Message.Refusal != tc.message {
+				t.Errorf("Expected message.refusal %q, got %q", tc.message, tc.resp.Choices[0].Message.Refusal)
+			}
+			if !tc.resp.IsRefusal() {
+				t.Error("Expected the response reported as a refusal")
+			}
+		})
+	}
+}
+
+func TestRefusals_NormalCompletionIsNotRefusal(t *testing.T) {
+	resp := NewAnthropicAdapter(&Config{}).convertResponse(&anthropicResponse{
+		Content:    []anthropicContent{{Type: "text", Text: "Sure."}},
+		StopReason: "end_turn",
+	})
+	if resp.Refusal != nil || resp.IsRefusal() {
+		t.Errorf("Expected no refusal for a normal completion, got %+v", resp.Refusal)
+	}
+}
@@ -0,0 +1,58 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// UpstreamStatus extracts the HTTP status from an adapter error ("API returned status N: ..."),
+// 0 when the error did not come from an upstream response
+func UpstreamStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	msg := err.Error()
+	idx := strings.Index(msg, "API returned status ")
+	if idx < 0 {
+		return 0
+	}
+	var status int
+	fmt.Sscanf(msg[idx+len("API returned status "):], "%d", &status)
+	return status
+}
+
+// IsRetryableError reports whether another upstream may succeed where this one failed:
+// transport failures, timeouts, rate limits and server errors. Client errors are not retried
+// because every upstream would reject the same request.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	switch status := UpstreamStatus(err); {
+	case status == 408, status == 429, status >= 500:
+		return true
+	case status > 0:
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "failed to make request") || strings.Contains(msg, "failed to read response")
+}
+
+// CloneChatRequest copies the request so that a retry can start from the original
+// after the previous attempt rewrote messages, tools or metadata for its upstream.
+// Message contents are shared; rewrites replace messages rather than modifying them.
+func CloneChatRequest(req *ChatRequest) *ChatRequest {
+	clone := *req
+	clone.Messages = append([]Message(nil), req.Messages...)
+	clone.Tools = append([]Tool(nil), req.Tools...)
+	clone.StopSequences = append([]string(nil), req.StopSequences...)
+	if req.Metadata != nil {
+		clone.Metadata = make(map[string]interface{}, len(req.Metadata))
+		for k, v := range req.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+	return &clone
+}
@@ -0,0 +1,52 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("API returned status 503: unavailable"), true},
+		{fmt.Errorf("API returned status 429: slow down"), true},
+		{fmt.Errorf("API returned status 400: bad request"), false},
+		{fmt.Errorf("API returned status 401: bad key"), false},
+		{fmt.Errorf("failed to make request: connection refused"), true},
+		{fmt.Errorf("failed to marshal request: bad"), false},
+		{fmt.Errorf("failed to make request: %w", context.Canceled), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryableError(tt.err); got != tt.want {
+			t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+	if !errors.Is(fmt.Errorf("failed to make request: %w", context.Canceled), context.Canceled) {
+		t.Fatal("Expected wrapped cancellation to be detectable")
+	}
+}
+
+func TestCloneChatRequest_IsolatesRewrites(t *testing.T) {
+	original := &ChatRequest{
+		Messages: []Message{{Role: "user", Content: "a"}, {Role: "user", Content: "b"}},
+		Tools:    []Tool{{Type: "function", Function: ToolFunction{Name: "t"}}},
+		Metadata: map[string]interface{}{"team": "search"},
+	}
+	clone := CloneChatRequest(original)
+	clone.Messages[0] = Message{Role: "system", Content: "summary"}
+	clone.Messages = clone.Messages[:1]
+	clone.Tools[0].Function.Name = "merged"
+	clone.Metadata["team"] = "ads"
+
+	if len(original.Messages) != 2 || original.Messages[0].Role != "user" {
+		t.Errorf("Expected original messages untouched, got %+v", original.Messages)
+	}
+	if original.Tools[0].Function.Name != "t" || original.Metadata["team"] != "search" {
+		t.Errorf("Expected original tools and metadata untouched, got %+v %+v", original.Tools, original.Metadata)
+	}
+}
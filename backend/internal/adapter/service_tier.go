@@ -0,0 +1,44 @@
+package adapter
+
+// OpenAI service_tier values accepted on the unified request
+const (
+	ServiceTierAuto     = "auto"
+	ServiceTierDefault  = "default"
+	ServiceTierFlex     = "flex"
+	ServiceTierPriority = "priority"
+)
+
+// EffectiveServiceTier returns the tier actually sent upstream by the adapter,
+// or "" when the provider has no equivalent and the field is dropped
+func EffectiveServiceTier(a Adapter, tier string) string {
+	if tier == "" {
+		return ""
+	}
+	if fallback, ok := a.(*KeyFallbackAdapter); ok {
+		a = fallback.Primary()
+	}
+	switch a.(type) {
+	case *OpenAIAdapter:
+		return tier
+	case *AnthropicAdapter:
+		return anthropicServiceTier(tier)
+	default:
+		return ""
+	}
+}
+
+// anthropicServiceTier maps OpenAI tiers to Anthropic's service_tier
+// Anthropic only distinguishes "auto" (priority capacity when available) and "standard_only";
+// flex and priority have no equivalent and are dropped
+func anthropicServiceTier(tier string) string {
+	switch tier {
+	case ServiceTierAuto:
+		return "auto"
+	case ServiceTierDefault:
+		return "standard_only"
+	default:
+		return ""
+	}
+}
+
+// Gemini and Kiro have no service tier, the field is not sent to them
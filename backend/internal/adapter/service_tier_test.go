@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+)
+
+func serviceTierRequest(tier string) *ChatRequest {
+	return &ChatRequest{
+		Model:       "m",
+		Messages:    []Message{{Role: "user", Content: "hi"}},
+		ServiceTier: tier,
+	}
+}
+
+func TestOpenAIAdapter_ForwardsServiceTier(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"id":"1","choices":[]}`, &body)
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	if _, err := a.Call(context.Background(), serviceTierRequest(ServiceTierFlex)); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["service_tier"] != ServiceTierFlex {
+		t.Errorf("Expected service_tier flex, got %v", body["service_tier"])
+	}
+}
+
+func TestAnthropicAdapter_MapsServiceTier(t *testing.T) {
+	tests := []struct {
+		tier string
+		want interface{}
+	}{
+		{ServiceTierAuto, "auto"},
+		{ServiceTierDefault, "standard_only"},
+		{ServiceTierFlex, nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var body map[string]interface{}
+		server := captureServer(t, `{"id":"1","type":"message","role":"assistant","content":[]}`, &body)
+
+		a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+		if _, err := a.Call(context.Background(), serviceTierRequest(tt.tier)); err != nil {
+			t.Fatalf("Tier %q: call failed: %v", tt.tier, err)
+		}
+		if body["service_tier"] != tt.want {
+			t.Errorf("Tier %q: expected service_tier %v, got %v", tt.tier, tt.want, body["service_tier"])
+		}
+		server.Close()
+	}
+}
+
+func TestGeminiAdapter_DropsServiceTier(t *testing.T) {
+	var body map[string]interface{}
+	server := captureServer(t, `{"candidates":[]}`, &body)
+	defer server.Close()
+
+	a := NewGeminiAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	a.Call(context.Background(), serviceTierRequest(ServiceTierPriority))
+	if _, ok := body["service_tier"]; ok {
+		t.Error("Expected service_tier not sent to Gemini")
+	}
+}
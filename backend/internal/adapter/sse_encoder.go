@@ -0,0 +1,153 @@
+package adapter
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// writeStreamChunk writes a chunk as an SSE data event.
+// Text-only chunks (role/content deltas) with valid UTF-8 are hand-encoded without reflection
+// since they dominate streaming traffic; anything else goes through json.Marshal.
+// Both paths produce byte-identical output.
+func writeStreamChunk(w io.Writer, chunk *ChatStreamChunk) error {
+	var buf []byte
+	if isSimpleStreamChunk(chunk) {
+		buf = make([]byte, 0, 160+len(chunk.Model)+streamChunkContentLen(chunk))
+		buf = append(buf, "data: "...)
+		buf = appendStreamChunk(buf, chunk)
+	} else {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		buf = make([]byte, 0, len(data)+8)
+		buf = append(buf, "data: "...)
+		buf = append(buf, data...)
+	}
+	buf = append(buf, '\n', '\n')
+	_, err := w.Write(buf)
+	return err
+}
+
+// isSimpleStreamChunk reports whether the chunk can take the hand-encoded fast path.
+// Invalid UTF-8 is left to json.Marshal, whose replacement output differs between Go versions.
+func isSimpleStreamChunk(chunk *ChatStreamChunk) bool {
+	if !utf8.ValidString(chunk.ID) || !utf8.ValidString(chunk.Object) || !utf8.ValidString(chunk.Model) {
+		return false
+	}
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		if len(choice.Delta.ToolCalls) > 0 ||
+			!utf8.ValidString(choice.Delta.Role) ||
+			!utf8.ValidString(choice.Delta.Content) ||
+			!utf8.ValidString(choice.FinishReason) {
+			return false
+		}
+	}
+	return true
+}
+
+func streamChunkContentLen(chunk *ChatStreamChunk) int {
+	n := 0
+	for i := range chunk.Choices {
+		n += len(chunk.Choices[i].Delta.Content)
+	}
+	return n
+}
+
+// appendStreamChunk appends the JSON encoding of a text-only chunk, mirroring the struct tags
+func appendStreamChunk(dst []byte, chunk *ChatStreamChunk) []byte {
+	dst = append(dst, `{"id":`...)
+	dst = appendJSONString(dst, chunk.ID)
+	dst = append(dst, `,"object":`...)
+	dst = appendJSONString(dst, chunk.Object)
+	dst = append(dst, `,"created":`...)
+	dst = strconv.AppendInt(dst, chunk.Created, 10)
+	dst = append(dst, `,"model":`...)
+	dst = appendJSONString(dst, chunk.Model)
+	dst = append(dst, `,"choices":`...)
+	if chunk.Choices == nil {
+		dst = append(dst, "null"...)
+		return append(dst, '}')
+	}
+
+	dst = append(dst, '[')
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, `{"index":`...)
+		dst = strconv.AppendInt(dst, int64(choice.Index), 10)
+		dst = append(dst, `,"delta":{`...)
+		if choice.Delta.Role != "" {
+			dst = append(dst, `"role":`...)
+			dst = appendJSONString(dst, choice.Delta.Role)
+		}
+		if choice.Delta.Content != "" {
+			if choice.Delta.Role != "" {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `"content":`...)
+			dst = appendJSONString(dst, choice.Delta.Content)
+		}
+		dst = append(dst, '}')
+		if choice.FinishReason != "" {
+			dst = append(dst, `,"finish_reason":`...)
+			dst = appendJSONString(dst, choice.FinishReason)
+		}
+		dst = append(dst, '}')
+	}
+	return append(dst, "]}"...)
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends s as a JSON string using the same escaping as encoding/json
+// (HTML-safe, U+2028/U+2029 escaped). s must be valid UTF-8.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,87 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"unicode/utf8"
+)
+
+// marshalStreamChunk is the reference json.Marshal encoding
+func marshalStreamChunk(chunk *ChatStreamChunk) []byte {
+	data, _ := json.Marshal(chunk)
+	return []byte(fmt.Sprintf("data: %s\n\n", data))
+}
+
+func textChunk(content string) ChatStreamChunk {
+	return ChatStreamChunk{
+		ID:      "chatcmpl-42",
+		Object:  "chat.completion.chunk",
+		Created: 1700000000,
+		Model:   "claude-sonnet-4",
+		Choices: []StreamChoice{{Index: 0, Delta: StreamDelta{Content: content}}},
+	}
+}
+
+func TestWriteStreamChunk_MatchesJSONMarshal(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk ChatStreamChunk
+	}{
+		{"plain text", textChunk("Hello, world")},
+		{"escapes", textChunk("quote \" backslash \\ newline \n tab \t cr \r bell \a bs \b ff \f nul \x00")},
+		{"html", textChunk("<script>a && b</script>")},
+		{"unicode", textChunk("你好 👋 line \u2028 para \u2029 é")},
+		{"invalid utf8", textChunk("bad \xff\xfe bytes \xe2\x82")},
+		{"empty delta with finish", ChatStreamChunk{ID: "x", Object: "chat.completion.chunk", Model: "m", Choices: []StreamChoice{{FinishReason: "stop"}}}},
+		{"role and content", ChatStreamChunk{ID: "x", Choices: []StreamChoice{{Index: 1, Delta: StreamDelta{Role: "assistant", Content: "hi"}}}}},
+		{"role only", ChatStreamChunk{ID: "x", Choices: []StreamChoice{{Delta: StreamDelta{Role: "assistant"}}}}},
+		{"multiple choices", ChatStreamChunk{ID: "x", Choices: []StreamChoice{{Index: 0, Delta: StreamDelta{Content: "a"}}, {Index: 1, Delta: StreamDelta{Content: "b"}}}}},
+		{"nil choices", ChatStreamChunk{ID: "x", Created: -1}},
+		{"empty choices", ChatStreamChunk{ID: "x", Choices: []StreamChoice{}}},
+		{"tool calls use generic path", ChatStreamChunk{ID: "x", Choices: []StreamChoice{{Delta: StreamDelta{ToolCalls: []ToolCall{
+			{ID: "t1", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"<Paris>"}`}},
+		}}}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeStreamChunk(&buf, &tt.chunk); err != nil {
+				t.Fatalf("writeStreamChunk failed: %v", err)
+			}
+			if want := marshalStreamChunk(&tt.chunk); !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("Output differs from json.Marshal\n got: %s\nwant: %s", buf.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestAppendJSONString_AllASCII(t *testing.T) {
+	for b := 0; b < utf8.RuneSelf; b++ {
+		s := string([]byte{'a', byte(b), 'z'})
+		want, _ := json.Marshal(s)
+		if got := appendJSONString(nil, s); !bytes.Equal(got, want) {
+			t.Errorf("Byte %#x: got %s, want %s", b, got, want)
+		}
+	}
+}
+
+func BenchmarkStreamChunk_JSONMarshal(b *testing.B) {
+	chunk := textChunk("The quick brown fox jumps over the lazy dog. ")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(io.Discard, "data: %s\n\n", string(data))
+	}
+}
+
+func BenchmarkStreamChunk_FastPath(b *testing.B) {
+	chunk := textChunk("The quick brown fox jumps over the lazy dog. ")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		writeStreamChunk(io.Discard, &chunk)
+	}
+}
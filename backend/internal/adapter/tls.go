@@ -0,0 +1,81 @@
+package adapter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TLSSettings holds custom TLS material for an upstream, all PEM encoded
+// Used for self-hosted or enterprise upstreams that require mutual TLS or a private CA
+type TLSSettings struct {
+	ClientCert         string
+	ClientKey          string
+	CABundle           string
+	InsecureSkipVerify bool // development only
+}
+
+// IsEmpty reports whether the settings change nothing from the default TLS behavior
+func (s *TLSSettings) IsEmpty() bool {
+	return s == nil || (s.ClientCert == "" && s.ClientKey == "" && s.CABundle == "" && !s.InsecureSkipVerify)
+}
+
+// TLSConfigProvider is implemented by configs with custom TLS settings
+// ClientKey may be encrypted with the factory's SecretBox
+type TLSConfigProvider interface {
+	GetTLSSettings() *TLSSettings
+}
+
+// NewTLSConfig builds a client TLS config from the settings and validates the material
+// A CA bundle is trusted in addition to the system roots
+func NewTLSConfig(s *TLSSettings) (*tls.Config, error) {
+	if s.IsEmpty() {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+
+	if s.ClientCert != "" || s.ClientKey != "" {
+		if s.ClientCert == "" || s.ClientKey == "" {
+			return nil, fmt.Errorf("client certificate and key must be provided together")
+		}
+		cert, err := tls.X509KeyPair([]byte(s.ClientCert), []byte(s.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if s.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(s.CABundle)) {
+			return nil, fmt.Errorf("CA bundle contains no valid PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// newTLSClient creates an HTTP client using the TLS config, with the same timeout defaults as the adapters
+func newTLSClient(timeout int, tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	clientTimeout := 30 * time.Second
+	if timeout > 0 {
+		clientTimeout = time.Duration(timeout) * time.Second
+	}
+	return &http.Client{
+		Timeout:   clientTimeout,
+		Transport: transport,
+	}
+}
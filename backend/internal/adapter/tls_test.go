@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"api-aggregator/backend/pkg/crypto"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tlsTestConfig is a direct config with custom TLS settings
+type tlsTestConfig struct {
+	baseURL string
+	tls     *TLSSettings
+}
+
+func (c *tlsTestConfig) GetType() string              { return "openai" }
+func (c *tlsTestConfig) GetBaseURL() string           { return c.baseURL }
+func (c *tlsTestConfig) GetAPIKey() string            { return "sk-test" }
+func (c *tlsTestConfig) GetTimeout() int              { return 5 }
+func (c *tlsTestConfig) GetUserAgent() string         { return "" }
+func (c *tlsTestConfig) GetTLSSettings() *TLSSettings { return c.tls }
+
+// chatHandler answers every request with a minimal chat completion
+var chatHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":"1","choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+})
+
+// serverCAPEM returns the test server's self-signed certificate as a PEM CA bundle
+func serverCAPEM(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+// selfSignedClientCert generates a client certificate and key pair in PEM
+func selfSignedClientCert(t *testing.T) (certPEM, keyPEM string, cert *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "prism-client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ = x509.ParseCertificate(der)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+		cert
+}
+
+func callTLS(t *testing.T, factory *Factory, config *tlsTestConfig) error {
+	t.Helper()
+	a, err := factory.CreateAdapter(config)
+	if err != nil {
+		t.Fatalf("CreateAdapter failed: %v", err)
+	}
+	_, err = a.Call(context.Background(), metadataRequest())
+	return err
+}
+
+func TestFactory_CustomCADialsServer(t *testing.T) {
+	server := httptest.NewTLSServer(chatHandler)
+	defer server.Close()
+
+	err := callTLS(t, NewFactory(), &tlsTestConfig{baseURL: server.URL, tls: &TLSSettings{CABundle: serverCAPEM(server)}})
+	if err != nil {
+		t.Fatalf("Expected server trusted through the CA bundle, got %v", err)
+	}
+}
+
+func TestFactory_UntrustedServerRejectedWithoutInsecureSkip(t *testing.T) {
+	server := httptest.NewTLSServer(chatHandler)
+	defer server.Close()
+	// httptest servers share one certificate, so an unrelated self-signed cert stands in for another CA
+	otherCA, _, _ := selfSignedClientCert(t)
+
+	for name, settings := range map[string]*TLSSettings{
+		"no settings": nil,
+		"other CA":    {CABundle: otherCA},
+	} {
+		err := callTLS(t, NewFactory(), &tlsTestConfig{baseURL: server.URL, tls: settings})
+		if err == nil || !strings.Contains(err.Error(), "certificate") {
+			t.Errorf("%s: expected certificate verification failure, got %v", name, err)
+		}
+	}
+
+	if err := callTLS(t, NewFactory(), &tlsTestConfig{baseURL: server.URL, tls: &TLSSettings{InsecureSkipVerify: true}}); err != nil {
+		t.Errorf("Expected insecure skip to accept the server, got %v", err)
+	}
+}
+
+func TestFactory_PresentsEncryptedClientCertificate(t *testing.T) {
+	certPEM, keyPEM, clientCert := selfSignedClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(chatHandler)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	box, _ := crypto.NewSecretBox("test-secret")
+	encryptedKey, err := box.Encrypt(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tlsTestConfig{baseURL: server.URL, tls: &TLSSettings{
+		ClientCert: certPEM,
+		ClientKey:  encryptedKey,
+		CABundle:   serverCAPEM(server),
+	}}
+
+	if err := callTLS(t, NewFactory().WithSecretBox(box), config); err != nil {
+		t.Fatalf("Expected mutual TLS to succeed, got %v", err)
+	}
+
+	// Without a client certificate the server rejects the handshake
+	config.tls = &TLSSettings{CABundle: serverCAPEM(server)}
+	if err := callTLS(t, NewFactory(), config); err == nil {
+		t.Error("Expected handshake failure without a client certificate")
+	}
+
+	// A client key that cannot be decrypted fails adapter creation
+	config.tls = &TLSSettings{ClientCert: certPEM, ClientKey: encryptedKey}
+	if _, err := NewFactory().CreateAdapter(config); err == nil {
+		t.Error("Expected an error when the client key cannot be decrypted")
+	}
+}
+
+func TestNewTLSConfig_RejectsInvalidMaterial(t *testing.T) {
+	certPEM, keyPEM, _ := selfSignedClientCert(t)
+	_, otherKey, _ := selfSignedClientCert(t)
+
+	cases := map[string]*TLSSettings{
+		"cert without key": {ClientCert: certPEM},
+		"mismatched key":   {ClientCert: certPEM, ClientKey: otherKey},
+		"garbage CA":       {CABundle: "not a certificate"},
+	}
+	for name, settings := range cases {
+		if _, err := NewTLSConfig(settings); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	cfg, err := NewTLSConfig(&TLSSettings{ClientCert: certPEM, ClientKey: keyPEM})
+	if err != nil || len(cfg.Certificates) != 1 {
+		t.Errorf("Expected a valid client certificate, got %v", err)
+	}
+	if cfg, err := NewTLSConfig(nil); cfg != nil || err != nil {
+		t.Errorf("Expected no config for empty settings, got %v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,88 @@
+package adapter
+
+import "fmt"
+
+// failedToolResult is sent for tool calls the client never answered, as the Kiro sanitizer does,
+// since every provider rejects a tool call without a matching result
+const failedToolResult = "Tool execution failed"
+
+// ToolPairingError is returned when a tool result does not answer a tool call made earlier in the conversation
+type ToolPairingError struct {
+	Index      int // index of the offending message
+	ToolCallID string
+	Reason     string
+}
+
+func (e *ToolPairingError) Error() string {
+	return fmt.Sprintf("messages[%d]: %s", e.Index, e.Reason)
+}
+
+// ValidateToolPairing checks that every tool result references a tool call issued by an earlier
+// assistant message and that no tool call is answered twice
+func ValidateToolPairing(messages []Message) *ToolPairingError {
+	outstanding := make(map[string]int)
+	issued := make(map[string]bool)
+
+	for i, msg := range messages {
+		switch {
+		case msg.Role == "assistant":
+			for _, tc := range msg.ToolCalls {
+				outstanding[tc.ID]++
+				issued[tc.ID] = true
+			}
+		case msg.Role == "tool":
+			id := msg.ToolCallID
+			switch {
+			case id == "":
+				return &ToolPairingError{Index: i, Reason: "tool message is missing tool_call_id"}
+			case !issued[id]:
+				return &ToolPairingError{Index: i, ToolCallID: id, Reason: fmt.Sprintf("tool result references unknown tool_call_id %q, no earlier assistant message made this tool call", id)}
+			case outstanding[id] == 0:
+				return &ToolPairingError{Index: i, ToolCallID: id, Reason: fmt.Sprintf("duplicate tool result for tool_call_id %q", id)}
+			}
+			outstanding[id]--
+		}
+	}
+	return nil
+}
+
+// PairToolResults reorders tool results so each one directly follows the assistant message that
+// made the call, in call order, and fills in a failed result for calls the client never answered.
+// Results without a matching call are dropped; run ValidateToolPairing first to report them instead.
+func PairToolResults(messages []Message) []Message {
+	results := make(map[string][]Message)
+	calls := 0
+	for _, msg := range messages {
+		if msg.Role == "tool" {
+			results[msg.ToolCallID] = append(results[msg.ToolCallID], msg)
+		} else if msg.Role == "assistant" {
+			calls += len(msg.ToolCalls)
+		}
+	}
+	if calls == 0 && len(results) == 0 {
+		return messages
+	}
+
+	paired := make([]Message, 0, len(messages)+calls)
+	for _, msg := range messages {
+		if msg.Role == "tool" {
+			continue
+		}
+		paired = append(paired, msg)
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, tc := range msg.ToolCalls {
+			result := Message{Role: "tool", ToolCallID: tc.ID, Content: failedToolResult}
+			if queue := results[tc.ID]; len(queue) > 0 {
+				result, results[tc.ID] = queue[0], queue[1:]
+			}
+			// Gemini identifies function responses by name, which OpenAI clients usually omit
+			if result.Name == "" {
+				result.Name = tc.Function.Name
+			}
+			paired = append(paired, result)
+		}
+	}
+	return paired
+}
@@ -0,0 +1,142 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// toolContinuation is a follow-up request answering two parallel tool calls,
+// with the results sent out of order after an interleaved user message
+func toolContinuation() []Message {
+	return []Message{
+		{Role: "system", Content: "use tools"},
+		{Role: "user", Content: "weather in Paris and Rome?"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call_paris", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "call_rome", Type: "function", Function: FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+		}},
+		{Role: "tool", ToolCallID: "call_rome", Content: "rain"},
+		{Role: "user", Content: "also answer briefly"},
+		{Role: "tool", ToolCallID: "call_paris", Content: "sunny"},
+	}
+}
+
+func TestValidateToolPairing(t *testing.T) {
+	if err := ValidateToolPairing(toolContinuation()); err != nil {
+		t.Fatalf("Expected valid continuation, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		id       string
+		reason   string
+	}{
+		{"unknown id", append(toolContinuation(), Message{Role: "tool", ToolCallID: "call_tokyo", Content: "x"}), "call_tokyo", "unknown tool_call_id"},
+		{"duplicate result", append(toolContinuation(), Message{Role: "tool", ToolCallID: "call_rome", Content: "x"}), "call_rome", "duplicate"},
+		{"missing id", []Message{{Role: "user", Content: "hi"}, {Role: "tool", Content: "x"}}, "", "missing tool_call_id"},
+		{"result before call", []Message{{Role: "tool", ToolCallID: "call_1", Content: "x"}, {Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1"}}}}, "call_1", "unknown tool_call_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateToolPairing(tt.messages)
+			if err == nil {
+				t.Fatal("Expected a pairing error")
+			}
+			if err.ToolCallID != tt.id || !strings.Contains(err.Reason, tt.reason) {
+				t.Errorf("Expected %q for %q, got %+v", tt.reason, tt.id, err)
+			}
+		})
+	}
+}
+
+func TestPairToolResults_OrdersAndFillsResults(t *testing.T) {
+	paired := PairToolResults(toolContinuation())
+
+	roles := make([]string, len(paired))
+	for i, msg := range paired {
+		roles[i] = msg.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool,tool,user" {
+		t.Fatalf("Expected results right after the tool calls, got %s", got)
+	}
+	if paired[3].ToolCallID != "call_paris" || paired[4].ToolCallID != "call_rome" {
+		t.Errorf("Expected results in call order, got %s, %s", paired[3].ToolCallID, paired[4].ToolCallID)
+	}
+	if paired[3].Name != "get_weather" {
+		t.Errorf("Expected tool name filled from the call, got %q", paired[3].Name)
+	}
+
+	unanswered := PairToolResults(toolContinuation()[:3])
+	if len(unanswered) != 5 || unanswered[4].Content != failedToolResult {
+		t.Errorf("Expected failed results for unanswered calls, got %+v", unanswered)
+	}
+
+	plain := []Message{{Role: "user", Content: "hi"}}
+	if got := PairToolResults(plain); len(got) != 1 {
+		t.Errorf("Expected conversations without tools untouched, got %+v", got)
+	}
+}
+
+func TestAnthropicAdapter_StreamsToolResultContinuation(t *testing.T) {
+	var upstream anthropicRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &upstream); err != nil {
+			t.Errorf("Invalid upstream body: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Paris is sunny\"}}\n\n")
+		io.WriteString(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}))
+	defer server.Close()
+
+	a := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "k"})
+	req := &ChatRequest{Model: "claude-3", Stream: true, Messages: PairToolResults(toolContinuation())}
+	resp, err := a.CallStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CallStream failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "Paris is sunny") {
+		t.Errorf("Expected streamed continuation, got %s", body)
+	}
+
+	// user, assistant(tool_use x2), user(tool_result x2), user(text) - the parallel results share one turn
+	if len(upstream.Messages) != 4 {
+		t.Fatalf("Expected 4 upstream messages, got %d: %+v", len(upstream.Messages), upstream.Messages)
+	}
+	raw, _ := json.Marshal(upstream.Messages[2].Content)
+	var results []anthropicContent
+	json.Unmarshal(raw, &results)
+	if upstream.Messages[2].Role != "user" || len(results) != 2 {
+		t.Fatalf("Expected both tool results in one user turn, got %s", raw)
+	}
+	if results[0].Type != "tool_result" || results[0].ToolUseID != "call_paris" || results[1].ToolUseID != "call_rome" {
+		t.Errorf("Expected tool results paired with tool_use ids in call order, got %s", raw)
+	}
+}
+
+func TestGeminiAdapter_ToolResultsShareUserTurn(t *testing.T) {
+	contents, _ := (&GeminiAdapter{}).convertMessages(PairToolResults(toolContinuation()))
+
+	// user, model(functionCall x2), user(functionResponse x2), user(text)
+	if len(contents) != 4 {
+		t.Fatalf("Expected 4 contents, got %d: %+v", len(contents), contents)
+	}
+	responses := contents[2]
+	if responses.Role != "user" || len(responses.Parts) != 2 {
+		t.Fatalf("Expected both function responses in one user turn, got %+v", responses)
+	}
+	for _, part := range responses.Parts {
+		if part.FunctionResponse == nil || part.FunctionResponse.Name != "get_weather" {
+			t.Errorf("Expected named function response, got %+v", part)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package adapter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// screenshotToolMessage returns a tool result carrying text, an image and JSON
+func screenshotToolMessage() Message {
+	return Message{
+		Role:       "tool",
+		Name:       "take_screenshot",
+		ToolCallID: "call_1",
+		Content: []interface{}{
+			map[string]interface{}{"type": "text", "text": "screenshot taken"},
+			map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0KGgo="},
+			},
+			map[string]interface{}{"type": "json", "json": map[string]interface{}{"width": 800.0}},
+		},
+	}
+}
+
+func TestGetToolResultParts(t *testing.T) {
+	parts := GetToolResultParts(screenshotToolMessage().Content)
+	if len(parts) != 3 {
+		t.Fatalf("Expected 3 parts, got %d", len(parts))
+	}
+	if parts[0].Type != ToolResultPartText || parts[0].Text != "screenshot taken" {
+		t.Errorf("Unexpected text part: %+v", parts[0])
+	}
+	if parts[1].Type != ToolResultPartImage || parts[1].MediaType != "image/png" || parts[1].Data != "iVBORw0KGgo=" {
+		t.Errorf("Unexpected image part: %+v", parts[1])
+	}
+	if parts[2].Type != ToolResultPartJSON {
+		t.Errorf("Unexpected json part: %+v", parts[2])
+	}
+
+	if parts := GetToolResultParts("plain"); len(parts) != 1 || parts[0].Text != "plain" {
+		t.Errorf("Expected plain string to become a text part, got %+v", parts)
+	}
+}
+
+// Test image tool results survive to Anthropic as image blocks
+func TestAnthropicAdapter_ToolResultImage(t *testing.T) {
+	adapter := &AnthropicAdapter{}
+
+	converted, _ := adapter.convertMessages([]Message{screenshotToolMessage()})
+	if len(converted) != 1 || len(converted[0].Content.([]anthropicContent)) != 1 {
+		t.Fatalf("Expected a single tool_result message, got %+v", converted)
+	}
+
+	result := converted[0].Content.([]anthropicContent)[0]
+	if result.Type != "tool_result" || result.ToolUseID != "call_1" {
+		t.Errorf("Unexpected tool_result block: %+v", result)
+	}
+	blocks, ok := result.Content.([]anthropicContent)
+	if !ok || len(blocks) != 3 {
+		t.Fatalf("Expected 3 tool_result content blocks, got %#v", result.Content)
+	}
+	if blocks[1].Type != "image" || blocks[1].Source == nil || blocks[1].Source.Data != "iVBORw0KGgo=" {
+		t.Errorf("Expected image block to be preserved, got %+v", blocks[1])
+	}
+	if blocks[2].Type != "text" || blocks[2].Text != `{"width":800}` {
+		t.Errorf("Expected JSON serialized as text, got %+v", blocks[2])
+	}
+
+	// Text-only tool results stay a plain string
+	converted, _ = adapter.convertMessages([]Message{{Role: "tool", ToolCallID: "call_2", Content: "ok"}})
+	if got := converted[0].Content.([]anthropicContent)[0].Content; got != "ok" {
+		t.Errorf("Expected plain string content, got %#v", got)
+	}
+}
+
+// Test image tool results are sent to Gemini as inline data next to the function response
+func TestGeminiAdapter_ToolResultImage(t *testing.T) {
+	adapter := &GeminiAdapter{}
+
+	converted, _ := adapter.convertMessages([]Message{screenshotToolMessage()})
+	if len(converted) != 1 || len(converted[0].Parts) != 2 {
+		t.Fatalf("Expected function response and inline image, got %+v", converted)
+	}
+
+	fr := converted[0].Parts[0].FunctionResponse
+	if fr == nil || fr.Name != "take_screenshot" {
+		t.Fatalf("Expected function response first, got %+v", converted[0].Parts[0])
+	}
+	if fr.Response["width"] != 800.0 || fr.Response["result"] != "screenshot taken" {
+		t.Errorf("Unexpected function response: %+v", fr.Response)
+	}
+	if inline := converted[0].Parts[1].InlineData; inline == nil || inline.MimeType != "image/png" {
+		t.Errorf("Expected inline image, got %+v", converted[0].Parts[1])
+	}
+}
+
+// Test OpenAI tool messages degrade to text with an image placeholder
+func TestOpenAIAdapter_ToolResultDegradesToText(t *testing.T) {
+	messages := []Message{{Role: "user", Content: "hi"}, screenshotToolMessage()}
+
+	converted := convertOpenAIMessages(messages)
+	content, ok := converted[1].Content.(string)
+	if !ok {
+		t.Fatalf("Expected tool content flattened to string, got %#v", converted[1].Content)
+	}
+	if !strings.Contains(content, "screenshot taken") || !strings.Contains(content, "[image omitted: image/png]") || !strings.Contains(content, `{"width":800}`) {
+		t.Errorf("Unexpected flattened content: %q", content)
+	}
+	if _, ok := messages[1].Content.([]interface{}); !ok {
+		t.Error("Original messages should not be modified")
+	}
+}
+
+// Test Kiro tool results keep JSON blocks and replace images with a placeholder
+func TestKiroToolResultContent(t *testing.T) {
+	contents := convertToKiroToolResultContent(GetToolResultParts(screenshotToolMessage().Content))
+
+	data, err := json.Marshal(contents)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := `[{"text":"screenshot taken"},{"text":"[image omitted: image/png]"},{"json":{"width":800}}]`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
+
+// Test tool results without a name are mapped back to functionResponse parts named after their call
+func TestGeminiAdapter_ToolResultNameFromToolCall(t *testing.T) {
+	adapter := &GeminiAdapter{}
+
+	converted, _ := adapter.convertMessages([]Message{
+		{Role: "user", Content: "weather?"},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call_1", Type: "function", Function: FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "call_2", Type: "function", Function: FunctionCall{Name: "time", Arguments: `{}`}},
+		}},
+		{Role: "tool", ToolCallID: "call_1", Content: `{"forecast":"Sunny"}`},
+		{Role: "tool", ToolCallID: "call_2", Content: "noon"},
+	})
+	if len(converted) != 3 || len(converted[2].Parts) != 2 {
+		t.Fatalf("Expected responses to parallel calls in one turn, got %+v", converted)
+	}
+	if fc := converted[1].Parts[0].FunctionCall; fc == nil || fc.Name != "weather" || fc.Args["city"] != "Paris" {
+		t.Errorf("Expected functionCall part, got %+v", converted[1].Parts[0])
+	}
+	weather, clock := converted[2].Parts[0].FunctionResponse, converted[2].Parts[1].FunctionResponse
+	if weather == nil || weather.Name != "weather" || weather.Response["forecast"] != "Sunny" {
+		t.Errorf("Unexpected weather response: %+v", weather)
+	}
+	if clock == nil || clock.Name != "time" || clock.Response["result"] != "noon" {
+		t.Errorf("Unexpected time response: %+v", clock)
+	}
+}
+
+// Test the dropped image count matches what each adapter's conversion replaces with a placeholder
+func TestDroppedToolResultImages(t *testing.T) {
+	remote := Message{Role: "tool", ToolCallID: "call_2", Content: []interface{}{
+		map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/a.png"}},
+	}}
+	req := &ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}, screenshotToolMessage(), remote}}
+
+	tests := []struct {
+		name    string
+		adapter Adapter
+		want    int
+	}{
+		{"openai flattens every image", NewOpenAIAdapter(&Config{}), 2},
+		{"anthropic keeps images", NewAnthropicAdapter(&Config{}), 0},
+		{"gemini drops remote urls only", NewGeminiAdapter(&Config{}), 1},
+		{"kiro drops every image", NewKiroAdapter(&Config{}, "", "", "", nil), 2},
+		{"key fallback follows the primary", NewKeyFallbackAdapter([]Adapter{NewGeminiAdapter(&Config{})}), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DroppedToolResultImages(tt.adapter, req); got != tt.want {
+				t.Errorf("Expected %d dropped images, got %d", tt.want, got)
+			}
+		})
+	}
+}
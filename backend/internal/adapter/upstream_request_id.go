@@ -0,0 +1,58 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// upstreamRequestIDHeaders lists the headers providers use for their request ID, in lookup order
+var upstreamRequestIDHeaders = []string{
+	"x-request-id",     // OpenAI and most OpenAI-compatible upstreams
+	"request-id",       // Anthropic
+	"x-amzn-requestid", // Kiro (AWS)
+}
+
+// UpstreamRequestID captures the provider request ID of the last upstream response
+type UpstreamRequestID struct {
+	mu sync.Mutex
+	id string
+}
+
+type upstreamRequestIDKey struct{}
+
+// WithUpstreamRequestID attaches a fresh request ID recorder to the context
+func WithUpstreamRequestID(ctx context.Context) (context.Context, *UpstreamRequestID) {
+	recorder := &UpstreamRequestID{}
+	return context.WithValue(ctx, upstreamRequestIDKey{}, recorder), recorder
+}
+
+// Value returns the recorded request ID, or "" if the upstream sent none
+func (r *UpstreamRequestID) Value() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id
+}
+
+// ParseUpstreamRequestID returns the provider request ID from response headers
+func ParseUpstreamRequestID(h http.Header) string {
+	for _, name := range upstreamRequestIDHeaders {
+		if id := h.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// recordUpstreamRequestID stores the response's request ID, if a recorder is attached
+func recordUpstreamRequestID(ctx context.Context, h http.Header) {
+	recorder, ok := ctx.Value(upstreamRequestIDKey{}).(*UpstreamRequestID)
+	if !ok {
+		return
+	}
+	if id := ParseUpstreamRequestID(h); id != "" {
+		recorder.mu.Lock()
+		recorder.id = id
+		recorder.mu.Unlock()
+	}
+}
@@ -0,0 +1,64 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseUpstreamRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"openai", http.Header{"X-Request-Id": {"req_abc"}}, "req_abc"},
+		{"anthropic", http.Header{"Request-Id": {"req_011"}}, "req_011"},
+		{"aws", http.Header{"X-Amzn-Requestid": {"5f1e"}}, "5f1e"},
+		{"openai preferred", http.Header{"X-Request-Id": {"first"}, "Request-Id": {"second"}}, "first"},
+		{"none", http.Header{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUpstreamRequestID(tt.header); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAnthropicAdapter_RecordsUpstreamRequestID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("request-id", "req_01XYZ")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	adapter := NewAnthropicAdapter(&Config{BaseURL: server.URL, APIKey: "test-key", Timeout: 30})
+	ctx, recorder := WithUpstreamRequestID(context.Background())
+	if _, err := adapter.Call(ctx, &ChatRequest{Model: "claude-3", Messages: []Message{{Role: "user", Content: "Hello"}}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := recorder.Value(); got != "req_01XYZ" {
+		t.Errorf("Expected recorded request ID req_01XYZ, got %q", got)
+	}
+}
+
+func TestOpenAIAdapter_RecordsUpstreamRequestIDOnError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-request-id", "req_failed")
+		http.Error(w, `{"error":"overloaded"}`, http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	adapter := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "test-key", Timeout: 30})
+	ctx, recorder := WithUpstreamRequestID(context.Background())
+	if _, err := adapter.Call(ctx, &ChatRequest{Model: "gpt-4", Messages: []Message{{Role: "user", Content: "Hello"}}}); err == nil {
+		t.Fatal("Expected the upstream error to be returned")
+	}
+	if got := recorder.Value(); got != "req_failed" {
+		t.Errorf("Expected the request ID of the failed call, got %q", got)
+	}
+}
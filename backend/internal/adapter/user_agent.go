@@ -0,0 +1,61 @@
+package adapter
+
+import (
+	"runtime"
+	"strings"
+
+	"api-aggregator/backend/pkg/utils"
+)
+
+// DefaultUserAgent is the user-agent template used when a config does not set one
+const DefaultUserAgent = "Prism-API/{version} ({os}; {arch})"
+
+// kiroVersion is the Kiro IDE version presented to the Kiro upstream
+const kiroVersion = "0.6.18"
+
+// DefaultKiroUserAgent matches the Kiro IDE user-agent, some Kiro endpoints reject unknown clients
+const DefaultKiroUserAgent = "aws-sdk-js/1.0.18 ua/2.1 os/windows lang/js md/nodejs#20.16.0 api/codewhispererstreaming#1.0.18 m/E KiroIDE-{kiro_version}-{machine_id}"
+
+// UserAgentSetter is implemented by adapters whose user-agent can be overridden after creation
+// Account pool adapters are created from credentials, so the config's template is applied afterwards
+type UserAgentSetter interface {
+	SetUserAgent(template string)
+}
+
+// ApplyUserAgent sets the user-agent template on the adapter if it supports it
+func ApplyUserAgent(a Adapter, template string) {
+	if template == "" {
+		return
+	}
+	if setter, ok := a.(UserAgentSetter); ok {
+		setter.SetUserAgent(template)
+	}
+}
+
+// ResolveUserAgent expands template variables in a user-agent template
+// Supported variables: {version}, {provider}, {os}, {arch}, {go_version}, plus any extra vars
+func ResolveUserAgent(template, provider string, extra map[string]string) string {
+	if template == "" {
+		template = DefaultUserAgent
+	}
+	if !strings.Contains(template, "{") {
+		return template
+	}
+
+	pairs := []string{
+		"{version}", utils.GetVersion(),
+		"{provider}", provider,
+		"{os}", runtime.GOOS,
+		"{arch}", runtime.GOARCH,
+		"{go_version}", runtime.Version(),
+	}
+	for k, v := range extra {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// userAgent resolves the config's user-agent for the given provider
+func (c *Config) userAgent(provider string) string {
+	return ResolveUserAgent(c.UserAgent, provider, nil)
+}
@@ -0,0 +1,74 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"api-aggregator/backend/pkg/utils"
+)
+
+func TestResolveUserAgent(t *testing.T) {
+	got := ResolveUserAgent("", "openai", nil)
+	want := "Prism-API/" + utils.GetVersion() + " (" + runtime.GOOS + "; " + runtime.GOARCH + ")"
+	if got != want {
+		t.Errorf("Expected default %q, got %q", want, got)
+	}
+
+	got = ResolveUserAgent("acme/{version} via {provider} {machine_id}", "gemini", map[string]string{"machine_id": "m-1"})
+	if got != "acme/"+utils.GetVersion()+" via gemini m-1" {
+		t.Errorf("Unexpected resolved user-agent: %q", got)
+	}
+
+	if got := ResolveUserAgent("static-client/1.0", "openai", nil); got != "static-client/1.0" {
+		t.Errorf("Expected static user-agent unchanged, got %q", got)
+	}
+}
+
+// Test the configured user-agent is sent upstream
+func TestOpenAIAdapter_SendsConfiguredUserAgent(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","choices":[]}`))
+	}))
+	defer server.Close()
+
+	a := NewOpenAIAdapter(&Config{BaseURL: server.URL, APIKey: "k", UserAgent: "my-gateway/{provider}"})
+	if _, err := a.Call(context.Background(), &ChatRequest{Model: "gpt-4", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if received != "my-gateway/openai" {
+		t.Errorf("Expected configured user-agent, got %q", received)
+	}
+
+	ApplyUserAgent(a, "override/{version}")
+	if _, err := a.Call(context.Background(), &ChatRequest{Model: "gpt-4", Messages: []Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if received != "override/"+utils.GetVersion() {
+		t.Errorf("Expected overridden user-agent, got %q", received)
+	}
+}
+
+// Test Kiro keeps its IDE user-agent by default and honours a configured template
+func TestKiroAdapter_UserAgent(t *testing.T) {
+	a := NewKiroAdapter(&Config{}, "token", "", "us-east-1", nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	a.setKiroHeaders(req)
+	ua := req.Header.Get("User-Agent")
+	if !strings.Contains(ua, "KiroIDE-"+kiroVersion+"-"+a.machineID) {
+		t.Errorf("Expected default Kiro user-agent, got %q", ua)
+	}
+
+	a.SetUserAgent("custom-kiro/{kiro_version} {machine_id}")
+	a.setKiroHeaders(req)
+	if ua := req.Header.Get("User-Agent"); ua != "custom-kiro/"+kiroVersion+" "+a.machineID {
+		t.Errorf("Expected configured Kiro user-agent, got %q", ua)
+	}
+}
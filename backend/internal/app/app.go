@@ -4,13 +4,17 @@ import (
 	"api-aggregator/backend/config"
 	"api-aggregator/backend/internal/adapter"
 	"api-aggregator/backend/internal/domain/accountpool"
+	"api-aggregator/backend/internal/domain/anomaly"
 	"api-aggregator/backend/internal/domain/apiconfig"
 	"api-aggregator/backend/internal/domain/apikey"
 	"api-aggregator/backend/internal/domain/auth"
 	"api-aggregator/backend/internal/domain/cache"
+	"api-aggregator/backend/internal/domain/configsync"
 	"api-aggregator/backend/internal/domain/loadbalancer"
 	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/internal/domain/modelmeta"
 	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/internal/domain/promptblock"
 	"api-aggregator/backend/internal/domain/proxy"
 	"api-aggregator/backend/internal/domain/quota"
 	"api-aggregator/backend/internal/domain/settings"
@@ -18,13 +22,21 @@ import (
 	"api-aggregator/backend/internal/domain/user"
 	"api-aggregator/backend/internal/middleware"
 	"api-aggregator/backend/internal/router"
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/archive"
 	pkgCache "api-aggregator/backend/pkg/cache"
+	"api-aggregator/backend/pkg/crypto"
 	"api-aggregator/backend/pkg/embedding"
 	"api-aggregator/backend/pkg/logger"
 	"api-aggregator/backend/pkg/runtime"
+	"api-aggregator/backend/pkg/tracing"
 	"context"
 	"database/sql"
+	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -41,6 +53,10 @@ type App struct {
 	Logger        *logger.Logger
 	Engine        *gin.Engine
 	RuntimeConfig *runtime.Manager
+	LogWriter     *log.BatchWriter
+	Archiver      *archive.Archiver
+
+	shutdownTracing func(context.Context) error
 }
 
 // New 创建应用实例
@@ -54,6 +70,11 @@ func New(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	// 初始化链路追踪
+	if err := app.initTracing(); err != nil {
+		return nil, err
+	}
+
 	// 初始化数据库
 	if err := app.initDatabase(); err != nil {
 		return nil, err
@@ -99,6 +120,27 @@ func (app *App) initLogger() error {
 	return nil
 }
 
+// initTracing 初始化链路追踪，未配置 OTLP 地址时不采集
+func (app *App) initTracing() error {
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		Endpoint:    app.Config.Tracing.Endpoint,
+		SampleRate:      app.Config.Tracing.SampleRate,
+		ErrorSampleRate: app.Config.Tracing.ErrorSampleRate,
+		ServiceName:     app.Config.Tracing.ServiceName,
+	})
+	if err != nil {
+		return err
+	}
+	app.shutdownTracing = shutdown
+	if app.Config.Tracing.Endpoint != "" {
+		app.Logger.Info("Tracing enabled",
+			logger.String("endpoint", app.Config.Tracing.Endpoint),
+			logger.Float64("sample_rate", app.Config.Tracing.SampleRate),
+			logger.Float64("error_sample_rate", app.Config.Tracing.ErrorSampleRate))
+	}
+	return nil
+}
+
 // initDatabase 初始化数据库
 func (app *App) initDatabase() error {
 	db, err := gorm.Open(postgres.Open(app.Config.Database.URL), &gorm.Config{
@@ -184,28 +226,55 @@ func (app *App) initRouter() error {
 	loadBalancerRepo := loadbalancer.NewRepository(app.DB)
 	accountPoolRepo := accountpool.NewRepository(app.DB)
 	settingsRepo := settings.NewRepository(app.DB)
+	anomalyRepo := anomaly.NewRepository(app.DB)
 
 // 初始化服务层
 	userService := user.NewService(userRepo, *app.Logger)
-	settingsService := settings.NewService(settingsRepo, userRepo)
+	settingsService := settings.NewService(settingsRepo, userRepo, app.RuntimeConfig)
 	authService := auth.NewService(authRepo, app.Config.JWT.Secret, settingsService, *app.Logger)
 	apiKeyService := apikey.NewService(apiKeyRepo, *app.Logger)
-	apiConfigService := apiconfig.NewService(apiConfigRepo, *app.Logger)
-	quotaService := quota.NewService(quotaRepo, *app.Logger)
+	secretBox, err := crypto.NewSecretBox(app.Config.Security.EncryptionKey)
+	if err != nil {
+		return err
+	}
+	apiConfigService := apiconfig.NewService(apiConfigRepo, secretBox, *app.Logger)
+	quotaService := quota.NewService(quotaRepo, app.RuntimeConfig, *app.Logger)
 	pricingService := pricing.NewService(pricingRepo, apiConfigRepo, *app.Logger)
-	logService := log.NewService(logRepo, userRepo, *app.Logger)
+
+	// 声明式配置：启动时把配置文件中的 API 配置和定价对齐到数据库
+	if app.Config.Declarative.Path != "" {
+		file, err := configsync.LoadFile(app.Config.Declarative.Path)
+		if err != nil {
+			return err
+		}
+		syncer := configsync.NewSyncer(apiConfigRepo, pricingRepo, *app.Logger)
+		if _, err := syncer.Apply(context.Background(), file, app.Config.Declarative.ReadOnly); err != nil {
+			return err
+		}
+	}
+	if app.Config.RequestLog.BatchSize > 1 {
+		app.LogWriter = log.NewBatchWriter(logRepo, app.Config.RequestLog.BatchSize, app.Config.RequestLog.QueueSize,
+			app.Config.RequestLog.FlushInterval, *app.Logger)
+		app.LogWriter.Start()
+	}
+	logService := log.NewService(logRepo, userRepo, app.LogWriter, *app.Logger)
 	statsService := stats.NewService(statsRepo, *app.Logger)
 	cacheService := cache.NewService(cacheRepo, *app.Logger)
 	loadBalancerService := loadbalancer.NewService(loadBalancerRepo, apiConfigRepo)
-	accountPoolService := accountpool.NewService(accountPoolRepo)
+	poolCapacity := accountpool.NewCapacityMonitor(accountPoolRepo, app.RuntimeConfig, alert.NewNotifier(10*time.Second), *app.Logger)
+	accountPoolService := accountpool.NewService(accountPoolRepo, poolCapacity)
+	anomalyService := anomaly.NewService(anomalyRepo, *app.Logger)
+	modelMetaService := modelmeta.NewService(modelmeta.NewRepository(app.DB), *app.Logger)
+	promptBlockService := promptblock.NewService(promptblock.NewRepository(app.DB), *app.Logger)
 	// 初始化 Adapter Factory
-	adapterFactory := adapter.NewFactory()
+	adapterFactory := adapter.NewFactory().WithSecretBox(secretBox).WithLogger(app.Logger)
 
 	// 初始化模型映射器（用于 Kiro）
 	modelMapper := apiconfig.NewModelMapper(apiConfigRepo)
 
 	// 初始化账号池管理器
-	poolManager := accountpool.NewPoolManager(accountPoolRepo, modelMapper)
+	poolManager := accountpool.NewPoolManager(accountPoolRepo, modelMapper, app.RuntimeConfig)
+	poolManager.SetCapacityMonitor(poolCapacity)
 	
 	// 初始化 Token 刷新调度器
 	refreshScheduler := accountpool.NewRefreshScheduler(
@@ -214,17 +283,35 @@ func (app *App) initRouter() error {
 		5*time.Minute, // 每5分钟检查一次
 		*app.Logger,
 	)
+	refreshScheduler.SetCapacityMonitor(poolCapacity)
+	refreshScheduler.SetRuntimeConfig(app.RuntimeConfig)
 	
 	// 启动刷新调度器
 	go refreshScheduler.Start(context.Background())
 
+	// 用量异常检测（策略由运行时配置 anomaly.* 控制，未启用时每轮直接跳过）
+	anomalyDetector := anomaly.NewDetector(anomalyRepo, app.RuntimeConfig, alert.NewNotifier(10*time.Second), *app.Logger)
+	go anomalyDetector.Start(context.Background())
+
+	// 释放到期未使用的配额预留
+	go quota.NewHoldExpirer(quotaService, *app.Logger).Start(context.Background())
+
+	// 软删除过期超过保留期的临时 API Key
+	go apikey.NewExpirySweeper(apiKeyRepo, app.RuntimeConfig, *app.Logger).Start(context.Background())
+
 	// 初始化 Embedding 客户端（如果启用）
 	var embeddingClient *embedding.Client
 	if app.Config.Embedding.Enabled {
 		embeddingClient = embedding.NewClient(
 			app.Config.Embedding.URL,
 			app.Config.Embedding.Timeout,
-		)
+		).WithRetry(app.Config.Embedding.MaxRetries, app.Config.Embedding.RetryBackoff)
+
+		// 补全写入时未能生成 embedding 的缓存，保持语义索引完整
+		if app.Config.Embedding.BackfillInterval > 0 {
+			backfiller := cache.NewEmbeddingBackfiller(cacheRepo, embeddingClient, app.Config.Embedding.BackfillInterval, *app.Logger)
+			go backfiller.Start(context.Background())
+		}
 	}
 
 	// 初始化 Proxy 服务
@@ -244,6 +331,23 @@ func (app *App) initRouter() error {
 		proxyService.SetEmbeddingClient(embeddingClient)
 	}
 
+	// 到期前刷新热门缓存（策略由运行时配置 cache_warming.* 控制，未启用时每轮直接跳过）
+	go cache.NewWarmer(cacheRepo, proxyService, app.RuntimeConfig, *app.Logger).Start(context.Background())
+
+	// 合规归档（只追加文件 + 哈希链），独立于请求日志
+	if app.Config.Archive.Path != "" {
+		sink, err := archive.OpenFile(app.Config.Archive.Path)
+		if err != nil {
+			return err
+		}
+		app.Archiver = archive.New(sink, archive.Options{
+			QueueSize:  app.Config.Archive.QueueSize,
+			FailClosed: app.Config.Archive.FailClosed,
+		}, *app.Logger)
+		app.Archiver.Start()
+		proxyService.SetArchiver(app.Archiver)
+	}
+
 	// 初始化处理器层
 	authHandler := auth.NewHandler(authService)
 	apiKeyHandler := apikey.NewHandler(apiKeyService)
@@ -258,6 +362,12 @@ func (app *App) initRouter() error {
 	accountPoolHandler := accountpool.NewHandler(accountPoolService)
 	settingsHandler := settings.NewHandler(settingsService)
 	proxyHandler := proxy.NewHandler(proxyService)
+	proxyHandler.SetPreferenceSource(userService)
+	proxyHandler.SetDeprecationSource(modelMetaService)
+	proxyHandler.SetPromptBlocklist(promptBlockService)
+	anomalyHandler := anomaly.NewHandler(anomalyService)
+	modelMetaHandler := modelmeta.NewHandler(modelMetaService)
+	promptBlockHandler := promptblock.NewHandler(promptBlockService)
 
 	// 初始化中间件管理器
 	mw := middleware.NewManager(&middleware.Config{
@@ -266,6 +376,7 @@ func (app *App) initRouter() error {
 		UserService:   userService,
 		Cache:         app.Cache,
 		Logger:        app.Logger,
+		RuntimeConfig: app.RuntimeConfig,
 		CORSConfig: &middleware.CORSConfig{
 			AllowOrigins:     []string{"*"},
 			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
@@ -275,7 +386,10 @@ func (app *App) initRouter() error {
 			MaxAge:           86400,
 		},
 		RequestTimeout: app.Config.Server.RequestTimeout,
+
+		MaxInFlightRequests: app.Config.MaxInFlightRequests(),
 	})
+	proxyService.SetRequestLimiter(mw.RateLimit)
 
 	// 初始化路由管理器
 	r := router.New(&router.Config{
@@ -294,6 +408,9 @@ func (app *App) initRouter() error {
 		AccountPoolHandler:  accountPoolHandler,
 		SettingsHandler:     settingsHandler,
 		ProxyHandler:        proxyHandler,
+		AnomalyHandler:      anomalyHandler,
+		ModelMetaHandler:    modelMetaHandler,
+		PromptBlockHandler:  promptBlockHandler,
 	})
 
 	// 设置路由
@@ -303,10 +420,38 @@ func (app *App) initRouter() error {
 	return nil
 }
 
-// Run 运行应用
+// Run 运行应用，收到 SIGINT/SIGTERM 后停止接收新连接并等待进行中的请求完成
 func (app *App) Run(addr string) error {
 	app.Logger.Info("Server starting", logger.String("addr", addr))
-	return app.Engine.Run(addr)
+	srv := &http.Server{Addr: addr, Handler: app.Engine}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	return app.drain(srv, 30*time.Second)
+}
+
+// drain 等待进行中的请求完成，超时后强制关闭剩余连接
+// 超时只记录日志并返回 nil，由调用方继续执行 Close 写完缓冲的请求日志和归档记录
+func (app *App) drain(srv *http.Server, timeout time.Duration) error {
+	app.Logger.Info("Shutting down server, draining in-flight requests")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		app.Logger.Warn("In-flight requests not drained before shutdown timeout", logger.Error(err))
+		srv.Close()
+	}
+	return nil
 }
 
 // Close 关闭应用
@@ -315,6 +460,33 @@ func (app *App) Close() error {
 	if app.RuntimeConfig != nil {
 		app.RuntimeConfig.Stop()
 	}
+
+	// 写完批量写入器中缓冲的请求日志
+	if app.LogWriter != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := app.LogWriter.Close(ctx); err != nil {
+			app.Logger.Warn("Request logs not fully flushed on shutdown", logger.Error(err))
+		}
+		cancel()
+	}
+
+	// 写完归档队列中剩余的记录
+	if app.Archiver != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := app.Archiver.Close(ctx); err != nil {
+			app.Logger.Warn("Compliance archive not fully flushed on shutdown", logger.Error(err))
+		}
+		cancel()
+	}
+
+	// 导出尚未发送的 span
+	if app.shutdownTracing != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := app.shutdownTracing(ctx); err != nil {
+			app.Logger.Warn("Traces not fully exported on shutdown", logger.Error(err))
+		}
+		cancel()
+	}
 	
 	if app.Logger != nil {
 		app.Logger.Sync()
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLogRepo 记录写入的请求日志条数
+type fakeLogRepo struct {
+	log.Repository
+	mu      sync.Mutex
+	written int
+}
+
+func (r *fakeLogRepo) Create(ctx context.Context, l *log.RequestLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written++
+	return nil
+}
+
+func (r *fakeLogRepo) CreateBatch(ctx context.Context, logs []*log.RequestLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.written += len(logs)
+	return nil
+}
+
+func TestDrain_TimeoutStillFlushesLogWriter(t *testing.T) {
+	repo := &fakeLogRepo{}
+	writer := log.NewBatchWriter(repo, 100, 100, time.Hour, *logger.NewNop())
+	writer.Start()
+	app := &App{Logger: logger.NewNop(), LogWriter: writer}
+
+	// 处理中的请求一直不返回，使排空超时
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+
+	writer.Write(&log.RequestLog{Model: "gpt-4"})
+	if err := app.drain(srv, 50*time.Millisecond); err != nil {
+		t.Fatalf("Expected drain timeout to be logged rather than returned, got %v", err)
+	}
+	app.Close()
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.written != 1 {
+		t.Errorf("Expected buffered request log flushed after drain timeout, got %d rows", repo.written)
+	}
+}
@@ -0,0 +1,152 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlertTypePoolCapacityLow 账号池健康凭据数低于阈值告警
+const AlertTypePoolCapacityLow = "pool_capacity_low"
+
+// defaultMinHealthyCredentials 未加载运行时配置时的健康凭据告警阈值
+const defaultMinHealthyCredentials = 2
+
+// PoolCapacity 账号池容量：健康凭据数与凭据总数
+type PoolCapacity struct {
+	PoolID    uint      `json:"pool_id"`
+	Healthy   int       `json:"healthy"`
+	Active    int       `json:"active"`
+	Total     int       `json:"total"`
+	Ratio     float64   `json:"ratio"` // healthy / total，没有凭据时为 0
+	Low       bool      `json:"low"`   // 健康凭据数低于告警阈值
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// measureCapacity 统计凭据的健康情况，threshold <= 0 时不判定容量不足
+func measureCapacity(poolID uint, creds []*AccountCredential, threshold int) *PoolCapacity {
+	capacity := &PoolCapacity{PoolID: poolID, Total: len(creds), UpdatedAt: time.Now()}
+	for _, cred := range creds {
+		if cred.IsActive {
+			capacity.Active++
+		}
+		if cred.IsHealthy() {
+			capacity.Healthy++
+		}
+	}
+	if capacity.Total > 0 {
+		capacity.Ratio = float64(capacity.Healthy) / float64(capacity.Total)
+	}
+	capacity.Low = threshold > 0 && capacity.Healthy < threshold
+	return capacity
+}
+
+// CapacityMonitor 统计各账号池的容量，健康凭据数跌破阈值时发送告警
+// 每次跌破只告警一次，恢复到阈值以上后再次跌破时重新告警
+type CapacityMonitor struct {
+	repo          Repository
+	runtimeConfig *runtime.Manager
+	notifier      *alert.Notifier
+	logger        logger.Logger
+
+	mu      sync.Mutex
+	alerted map[uint]bool // 已发送告警且尚未恢复的账号池
+}
+
+// NewCapacityMonitor 创建账号池容量监控
+func NewCapacityMonitor(repo Repository, runtimeConfig *runtime.Manager, notifier *alert.Notifier, logger logger.Logger) *CapacityMonitor {
+	return &CapacityMonitor{
+		repo:          repo,
+		runtimeConfig: runtimeConfig,
+		notifier:      notifier,
+		logger:        logger,
+		alerted:       make(map[uint]bool),
+	}
+}
+
+// threshold 健康凭据告警阈值，0 表示不告警
+func (m *CapacityMonitor) threshold() int {
+	if m.runtimeConfig == nil {
+		return defaultMinHealthyCredentials
+	}
+	return m.runtimeConfig.Get().GetPoolMinHealthyCredentials()
+}
+
+// Update 重新统计账号池的凭据健康情况，跌破阈值时发送告警
+func (m *CapacityMonitor) Update(ctx context.Context, poolID uint) (*PoolCapacity, error) {
+	creds, err := m.repo.FindCredentialsByPoolID(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	capacity := measureCapacity(poolID, creds, m.threshold())
+
+	m.mu.Lock()
+	notify := capacity.Low && !m.alerted[poolID]
+	m.alerted[poolID] = capacity.Low
+	m.mu.Unlock()
+
+	if notify {
+		m.notify(capacity)
+	}
+	return capacity, nil
+}
+
+// UpdateAll 重新统计所有账号池的容量
+func (m *CapacityMonitor) UpdateAll(ctx context.Context) ([]*PoolCapacity, error) {
+	pools, err := m.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	capacities := make([]*PoolCapacity, 0, len(pools))
+	for _, pool := range pools {
+		capacity, err := m.Update(ctx, pool.ID)
+		if err != nil {
+			return nil, err
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// notify 记录容量不足并在配置了告警 Webhook 时异步发送告警
+func (m *CapacityMonitor) notify(capacity *PoolCapacity) {
+	threshold := m.threshold()
+	m.logger.Warn("Account pool healthy credentials below threshold",
+		logger.Uint("pool_id", capacity.PoolID),
+		logger.Int("healthy", capacity.Healthy),
+		logger.Int("total", capacity.Total),
+		logger.Int("threshold", threshold))
+
+	if m.runtimeConfig == nil || m.notifier == nil {
+		return
+	}
+	url := m.runtimeConfig.Get().GetAlertWebhookURL()
+	if url == "" {
+		return
+	}
+
+	event := &alert.Event{
+		Type: AlertTypePoolCapacityLow,
+		Message: fmt.Sprintf("Account pool %d has %d healthy credentials of %d (threshold %d)",
+			capacity.PoolID, capacity.Healthy, capacity.Total, threshold),
+		Data: map[string]interface{}{
+			"pool_id":   capacity.PoolID,
+			"healthy":   capacity.Healthy,
+			"active":    capacity.Active,
+			"total":     capacity.Total,
+			"threshold": threshold,
+		},
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.notifier.Send(ctx, url, event); err != nil {
+			m.logger.Warn("Failed to send pool capacity alert", logger.Error(err))
+		}
+	}()
+}
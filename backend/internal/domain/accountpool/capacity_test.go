@@ -0,0 +1,130 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// statusRepo 在 fakeRepo 基础上支持停用凭据和列出账号池
+type statusRepo struct {
+	*fakeRepo
+}
+
+func (r *statusRepo) UpdateCredentialStatus(ctx context.Context, id uint, isActive bool) error {
+	r.creds[id].IsActive = isActive
+	return nil
+}
+
+func (r *statusRepo) FindAll(ctx context.Context) ([]*AccountPool, error) {
+	return []*AccountPool{r.pool}, nil
+}
+
+func newCapacityTestMonitor(t *testing.T, repo Repository, threshold int) (*CapacityMonitor, chan alert.Event) {
+	t.Helper()
+	events := make(chan alert.Event, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event alert.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+
+	rc := runtime.NewManager(nil)
+	rc.Get().PoolMinHealthyCredentials = threshold
+	rc.Get().AlertWebhookURL = server.URL
+	return NewCapacityMonitor(repo, rc, alert.NewNotifier(time.Second), *logger.NewNop()), events
+}
+
+func expectNoAlert(t *testing.T, events chan alert.Event) {
+	t.Helper()
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no alert, got %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestCapacityMonitor_AlertsWhenDisablingDropsBelowThreshold(t *testing.T) {
+	repo := &statusRepo{newFakeRepo(openAICredential(1), openAICredential(2), openAICredential(3))}
+	monitor, events := newCapacityTestMonitor(t, repo, 2)
+	svc := NewService(repo, monitor)
+	ctx := context.Background()
+
+	// 剩余 2 个健康凭据，未低于阈值
+	if _, err := svc.UpdateCredentialStatus(ctx, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	expectNoAlert(t, events)
+
+	if _, err := svc.UpdateCredentialStatus(ctx, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if event.Type != AlertTypePoolCapacityLow {
+			t.Errorf("Expected alert type %s, got %s", AlertTypePoolCapacityLow, event.Type)
+		}
+		if event.Data["pool_id"] != float64(1) || event.Data["healthy"] != float64(1) || event.Data["total"] != float64(3) {
+			t.Errorf("Unexpected alert data: %v", event.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a pool capacity alert")
+	}
+
+	// 已告警且未恢复，不重复告警
+	if _, err := svc.UpdateCredentialStatus(ctx, 3, false); err != nil {
+		t.Fatal(err)
+	}
+	expectNoAlert(t, events)
+
+	// 恢复后再次跌破阈值时重新告警
+	svc.UpdateCredentialStatus(ctx, 1, true)
+	svc.UpdateCredentialStatus(ctx, 2, true)
+	svc.UpdateCredentialStatus(ctx, 2, false)
+	select {
+	case <-events:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a new alert after the pool recovered and dropped again")
+	}
+}
+
+func TestCapacityMonitor_GaugeReflectsCredentialHealth(t *testing.T) {
+	repo := &statusRepo{newFakeRepo(openAICredential(1), openAICredential(2))}
+	monitor, events := newCapacityTestMonitor(t, repo, 0)
+	svc := NewService(repo, monitor)
+	pm := NewPoolManager(repo, nil, nil)
+	pm.SetCapacityMonitor(monitor)
+	ctx := context.Background()
+
+	capacities, err := svc.GetPoolCapacity(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(capacities) != 1 || capacities[0].Healthy != 2 || capacities[0].Total != 2 || capacities[0].Ratio != 1 {
+		t.Fatalf("Expected 2/2 healthy credentials, got %+v", capacities)
+	}
+
+	// 致命错误立即标记凭据不健康
+	pm.RecordError(ctx, 1, "401 unauthorized")
+	capacities, _ = svc.GetPoolCapacity(ctx)
+	if capacities[0].Healthy != 1 || capacities[0].Active != 2 || capacities[0].Ratio != 0.5 {
+		t.Errorf("Expected 1 of 2 credentials healthy, got %+v", capacities[0])
+	}
+	if capacities[0].Low {
+		t.Error("Expected no low-capacity flag when the threshold is disabled")
+	}
+
+	pm.RecordSuccess(ctx, 1)
+	capacities, _ = svc.GetPoolCapacity(ctx)
+	if capacities[0].Healthy != 1 {
+		t.Errorf("Expected a credential with a high error rate to stay unhealthy, got %+v", capacities[0])
+	}
+	expectNoAlert(t, events)
+}
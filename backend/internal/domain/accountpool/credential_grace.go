@@ -0,0 +1,82 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"fmt"
+	"time"
+)
+
+// AlertTypeCredentialDegraded 凭据过期且刷新失败、进入宽限期告警
+const AlertTypeCredentialDegraded = "credential_degraded"
+
+// defaultCredentialGracePeriod 未加载运行时配置时过期凭据刷新失败后的宽限期
+const defaultCredentialGracePeriod = 10 * time.Minute
+
+// credentialGracePeriod 过期凭据刷新失败后仍继续使用的时长
+func credentialGracePeriod(runtimeConfig *runtime.Manager) time.Duration {
+	if runtimeConfig == nil {
+		return defaultCredentialGracePeriod
+	}
+	return runtimeConfig.Get().GetPoolCredentialGracePeriod()
+}
+
+// GraceDeadline 凭据宽限期的截止时间，未设置过期时间时返回零值
+func (c *AccountCredential) GraceDeadline(grace time.Duration) time.Time {
+	if c.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return c.ExpiresAt.Add(grace)
+}
+
+// markRefreshFailed 记录刷新失败：宽限期内标记为 degraded 并返回 true（凭据仍可使用），
+// 宽限期已过则标记为不健康；从其他状态进入 degraded 时返回 entered 以便告警
+func (c *AccountCredential) markRefreshFailed(err error, grace time.Duration, now time.Time) (usable, entered bool) {
+	c.LastError = fmt.Sprintf("failed to refresh token: %v", err)
+	if c.ExpiresAt != nil && now.Before(c.GraceDeadline(grace)) {
+		entered = c.HealthStatus != HealthStatusDegraded
+		c.UpdateHealthStatus(HealthStatusDegraded)
+		return true, entered
+	}
+	c.UpdateHealthStatus(HealthStatusUnhealthy)
+	return false, false
+}
+
+// notifyDegraded 记录凭据进入宽限期并在配置了告警 Webhook 时异步发送告警
+func (m *CapacityMonitor) notifyDegraded(cred *AccountCredential, deadline time.Time) {
+	m.logger.Warn("Account credential refresh failed, using it until the grace period ends",
+		logger.Uint("pool_id", cred.PoolID),
+		logger.Uint("credential_id", cred.ID),
+		logger.String("grace_deadline", deadline.Format(time.RFC3339)),
+		logger.String("error", cred.LastError))
+
+	if m.runtimeConfig == nil || m.notifier == nil {
+		return
+	}
+	url := m.runtimeConfig.Get().GetAlertWebhookURL()
+	if url == "" {
+		return
+	}
+
+	event := &alert.Event{
+		Type: AlertTypeCredentialDegraded,
+		Message: fmt.Sprintf("Credential %d in account pool %d failed to refresh and will be disabled at %s",
+			cred.ID, cred.PoolID, deadline.Format(time.RFC3339)),
+		Data: map[string]interface{}{
+			"pool_id":        cred.PoolID,
+			"credential_id":  cred.ID,
+			"grace_deadline": deadline,
+			"error":          cred.LastError,
+		},
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := m.notifier.Send(ctx, url, event); err != nil {
+			m.logger.Warn("Failed to send credential degraded alert", logger.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,97 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"testing"
+	"time"
+)
+
+// expiringRepo 在 fakeRepo 基础上列出待刷新的凭据
+type expiringRepo struct {
+	*fakeRepo
+}
+
+func (r *expiringRepo) FindExpiringCredentials(ctx context.Context, providerType string, threshold time.Time) ([]*AccountCredential, error) {
+	var creds []*AccountCredential
+	for _, id := range r.order {
+		if cred := r.creds[id]; cred.ExpiresAt != nil && !cred.ExpiresAt.After(threshold) {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+// expiredKiroCredential 已过期且缺少 client_id，刷新必定失败
+func expiredKiroCredential(id uint, expiredFor time.Duration) *AccountCredential {
+	expiresAt := time.Now().Add(-expiredFor)
+	return &AccountCredential{
+		ID: id, PoolID: 1, Provider: "kiro", AuthType: AuthTypeOAuth, IsActive: true, Weight: 1,
+		AccessToken: "stale", RefreshToken: "refresh", ExpiresAt: &expiresAt, HealthStatus: HealthStatusHealthy,
+	}
+}
+
+func TestPoolManager_ExpiredCredentialWithinGraceStillTried(t *testing.T) {
+	repo := newFakeRepo(expiredKiroCredential(1, 5*time.Minute))
+	monitor, events := newCapacityTestMonitor(t, repo, 0)
+	monitor.runtimeConfig.Get().PoolCredentialGracePeriod = 10 * time.Minute
+	pm := NewPoolManager(repo, nil, monitor.runtimeConfig)
+	pm.SetCapacityMonitor(monitor)
+
+	_, credID, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4")
+	if err != nil || credID != 1 {
+		t.Fatalf("Expected the expired credential used within the grace period, got %d, %v", credID, err)
+	}
+	cred := repo.creds[1]
+	if cred.HealthStatus != HealthStatusDegraded || cred.LastError == "" {
+		t.Fatalf("Expected the credential flagged degraded with the refresh error, got %q %q", cred.HealthStatus, cred.LastError)
+	}
+	select {
+	case event := <-events:
+		if event.Type != AlertTypeCredentialDegraded {
+			t.Errorf("Expected a degraded credential alert, got %q", event.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected operators alerted when the credential entered the grace period")
+	}
+
+	// 再次选中时不再同步刷新，也不重复告警
+	if _, _, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4"); err != nil {
+		t.Fatalf("Expected the degraded credential still usable, got %v", err)
+	}
+	expectNoAlert(t, events)
+}
+
+func TestPoolManager_ExpiredCredentialDisabledAfterGrace(t *testing.T) {
+	repo := newFakeRepo(expiredKiroCredential(1, time.Hour))
+	monitor, _ := newCapacityTestMonitor(t, repo, 0)
+	monitor.runtimeConfig.Get().PoolCredentialGracePeriod = 10 * time.Minute
+	pm := NewPoolManager(repo, nil, monitor.runtimeConfig)
+
+	if _, _, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4"); err == nil {
+		t.Fatal("Expected a credential past its grace period to be rejected")
+	}
+	if status := repo.creds[1].HealthStatus; status != HealthStatusUnhealthy {
+		t.Errorf("Expected the credential disabled once grace elapsed, got %q", status)
+	}
+}
+
+func TestRefreshScheduler_DisablesDegradedCredentialWhenGraceElapses(t *testing.T) {
+	repo := &expiringRepo{newFakeRepo(expiredKiroCredential(1, 5*time.Minute))}
+	monitor, _ := newCapacityTestMonitor(t, repo, 0)
+	monitor.runtimeConfig.Get().PoolCredentialGracePeriod = 10 * time.Minute
+	scheduler := NewRefreshScheduler(repo, NewKiroRefreshService(), time.Minute, *logger.NewNop())
+	scheduler.SetRuntimeConfig(monitor.runtimeConfig)
+
+	scheduler.refreshExpiredTokens(context.Background())
+	if status := repo.creds[1].HealthStatus; status != HealthStatusDegraded {
+		t.Fatalf("Expected a failed background refresh within grace to leave the credential degraded, got %q", status)
+	}
+
+	// 宽限期结束仍未刷新成功
+	monitor.runtimeConfig.Get().PoolCredentialGracePeriod = time.Minute
+	scheduler.refreshExpiredTokens(context.Background())
+	if status := repo.creds[1].HealthStatus; status != HealthStatusUnhealthy {
+		t.Errorf("Expected the credential disabled after the grace period, got %q", status)
+	}
+}
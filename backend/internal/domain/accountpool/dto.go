@@ -12,6 +12,8 @@ type CreatePoolRequest struct {
 	Description         string `json:"description"`
 	Provider            string `json:"provider" binding:"required"`
 	Strategy            string `json:"strategy"`
+	RotationRequests    int    `json:"rotation_requests"`
+	RotationInterval    int    `json:"rotation_interval"`
 	HealthCheckInterval int    `json:"health_check_interval"`
 	HealthCheckTimeout  int    `json:"health_check_timeout"`
 	MaxRetries          int    `json:"max_retries"`
@@ -22,6 +24,8 @@ type UpdatePoolRequest struct {
 	Name                *string `json:"name"`
 	Description         *string `json:"description"`
 	Strategy            *string `json:"strategy"`
+	RotationRequests    *int    `json:"rotation_requests"`
+	RotationInterval    *int    `json:"rotation_interval"`
 	HealthCheckInterval *int    `json:"health_check_interval"`
 	HealthCheckTimeout  *int    `json:"health_check_timeout"`
 	MaxRetries          *int    `json:"max_retries"`
@@ -42,6 +46,8 @@ type PoolResponse struct {
 	Description         string    `json:"description,omitempty"`
 	Provider            string    `json:"provider"`
 	Strategy            string    `json:"strategy"`
+	RotationRequests    int       `json:"rotation_requests"`
+	RotationInterval    int       `json:"rotation_interval"`
 	HealthCheckInterval int       `json:"health_check_interval"`
 	HealthCheckTimeout  int       `json:"health_check_timeout"`
 	MaxRetries          int       `json:"max_retries"`
@@ -64,6 +70,9 @@ type PoolStatsResponse struct {
 	Provider      string  `json:"provider"`
 	TotalCreds    int     `json:"total_creds"`
 	ActiveCreds   int     `json:"active_creds"`
+	HealthyCreds  int     `json:"healthy_creds"`
+	Capacity      float64 `json:"capacity"`     // healthy_creds / total_creds
+	CapacityLow   bool    `json:"capacity_low"` // 健康凭据数低于告警阈值
 	TotalRequests int64   `json:"total_requests"`
 	TotalErrors   int64   `json:"total_errors"`
 	ErrorRate     float64 `json:"error_rate"`
@@ -121,6 +130,8 @@ func ToPoolResponse(pool *AccountPool) *PoolResponse {
 		Description:         pool.Description,
 		Provider:            pool.Provider,
 		Strategy:            pool.Strategy,
+		RotationRequests:    pool.RotationRequests,
+		RotationInterval:    pool.RotationInterval,
 		HealthCheckInterval: pool.HealthCheckInterval,
 		HealthCheckTimeout:  pool.HealthCheckTimeout,
 		MaxRetries:          pool.MaxRetries,
@@ -189,6 +200,8 @@ type CreateCredentialRequest struct {
 	AccountEmail string `json:"account_email"`
 	Weight       int    `json:"weight"`
 	RateLimit    int    `json:"rate_limit"`
+
+	AllowedModels []string `json:"allowed_models"` // 该凭据可用的模型，为空表示不限制
 }
 
 // UpdateCredentialRequest 更新凭据请求
@@ -202,6 +215,8 @@ type UpdateCredentialRequest struct {
 	Weight       *int    `json:"weight"`
 	IsActive     *bool   `json:"is_active"`
 	RateLimit    *int    `json:"rate_limit"`
+
+	AllowedModels *[]string `json:"allowed_models"` // 传空数组取消限制
 }
 
 // UpdateCredentialStatusRequest 更新凭据状态请求
@@ -238,6 +253,7 @@ type CredentialResponse struct {
 	IsExpired     bool       `json:"is_expired"`
 	RateLimit     int        `json:"rate_limit"`
 	CurrentUsage  int        `json:"current_usage"`
+	AllowedModels []string   `json:"allowed_models,omitempty"`
 	
 	// 订阅信息
 	SubscriptionType          string     `json:"subscription_type,omitempty"`
@@ -291,6 +307,8 @@ func ToCredentialResponse(cred *AccountCredential) *CredentialResponse {
 	if status == "" {
 		if banned, ok := cred.Metadata["banned"].(bool); ok && banned {
 			status = "banned"
+		} else if cred.HealthStatus == HealthStatusDegraded {
+			status = "degraded"
 		} else if cred.IsExpired() {
 			status = "expired"
 		} else if cred.HealthStatus == HealthStatusUnhealthy {
@@ -367,6 +385,7 @@ func ToCredentialResponse(cred *AccountCredential) *CredentialResponse {
 		IsExpired:     cred.IsExpired(),
 		RateLimit:     cred.RateLimit,
 		CurrentUsage:  cred.CurrentUsage,
+		AllowedModels: cred.AllowedModels,
 
 		// 订阅信息
 		SubscriptionType:          subscriptionType,
@@ -3,8 +3,11 @@ package accountpool
 import (
 	"api-aggregator/backend/pkg/query"
 	"api-aggregator/backend/pkg/response"
+	"bytes"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -214,6 +217,80 @@ func (h *Handler) GetPoolStats(c *gin.Context) {
 	response.Success(c, stats)
 }
 
+// GetPoolUsage 导出账号池用量
+// @Summary 导出账号池用量
+// @Description 按凭据汇总 [from, to) 内的请求数、错误数、token 和平均延迟，附账号池合计；from 默认 to 之前 7 天，to 默认当前时间
+// @Tags AccountPool
+// @Produce json,text/csv
+// @Param id path int true "账号池ID"
+// @Param from query string false "开始时间（RFC3339 或 2006-01-02）"
+// @Param to query string false "结束时间（RFC3339 或 2006-01-02）"
+// @Param format query string false "输出格式：json（默认）或 csv"
+// @Success 200 {object} PoolUsageResponse
+// @Router /api/admin/account-pools/{id}/usage [get]
+func (h *Handler) GetPoolUsage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "invalid pool id")
+		return
+	}
+
+	to, err := parseUsageTime(c.Query("to"), time.Now())
+	if err != nil {
+		response.BadRequest(c, "invalid to", err.Error())
+		return
+	}
+	from, err := parseUsageTime(c.Query("from"), to.Add(-defaultUsageRange))
+	if err != nil {
+		response.BadRequest(c, "invalid from", err.Error())
+		return
+	}
+	if !from.Before(to) {
+		response.BadRequest(c, "from must be before to")
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		response.BadRequest(c, "format must be json or csv")
+		return
+	}
+
+	usage, err := h.service.GetPoolUsage(c.Request.Context(), uint(id), from, to)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+
+	if format == "csv" {
+		var buf bytes.Buffer
+		if err := usage.WriteCSV(&buf); err != nil {
+			response.InternalErrorWithMessage(c, "Failed to write CSV", err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=pool_%d_usage_%s.csv", id, from.Format("20060102")))
+		c.Data(http.StatusOK, "text/csv", buf.Bytes())
+		return
+	}
+	response.Success(c, usage)
+}
+
+// GetPoolCapacity 获取所有账号池的容量
+// @Summary 获取账号池容量
+// @Description 每个账号池的健康凭据数与凭据总数，low 表示健康凭据数低于告警阈值
+// @Tags AccountPool
+// @Produce json
+// @Success 200 {array} PoolCapacity
+// @Router /api/v1/account-pools/capacity [get]
+func (h *Handler) GetPoolCapacity(c *gin.Context) {
+	capacities, err := h.service.GetPoolCapacity(c.Request.Context())
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+
+	response.Success(c, capacities)
+}
+
 // ListRequestLogs 查询请求日志列表
 // @Summary 查询请求日志列表
 // @Tags AccountPool
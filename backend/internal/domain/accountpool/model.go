@@ -28,6 +28,28 @@ func (j *JSONMap) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// StringArray 字符串数组类型（JSONB 存储）
+type StringArray []string
+
+func (s StringArray) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+func (s *StringArray) Scan(value interface{}) error {
+	if value == nil {
+		*s = []string{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
 // AccountPool 璐﹀彿姹犳ā鍨?
 type AccountPool struct {
 	ID        uint      `gorm:"primarykey" json:"id"`
@@ -44,6 +66,10 @@ type AccountPool struct {
 	// 杞绛栫暐
 	Strategy string `gorm:"not null;size:50;default:'round_robin'" json:"strategy"`
 
+	// 凭据轮换窗口：同一凭据连续处理满 RotationRequests 个请求或 RotationInterval 秒后换用下一个凭据（均为 0 表示每次请求按策略选择）
+	RotationRequests int `gorm:"not null;default:0" json:"rotation_requests"`
+	RotationInterval int `gorm:"not null;default:0" json:"rotation_interval"`
+
 	// 鍋ュ悍妫€鏌ラ厤缃?
 	HealthCheckInterval int `gorm:"not null;default:300" json:"health_check_interval"` // 绉?
 	HealthCheckTimeout  int `gorm:"not null;default:10" json:"health_check_timeout"`   // 绉?
@@ -190,6 +216,9 @@ type AccountCredential struct {
 	// 鏉冮噸锛堢敤浜庡姞鏉冭疆璇級
 	Weight int `gorm:"not null;default:1" json:"weight"`
 
+	// 该凭据可用的模型（为空表示不限制），例如部分账号没有高级模型的权限
+	AllowedModels StringArray `gorm:"type:jsonb" json:"allowed_models,omitempty"`
+
 	// 鐘舵€?
 	IsActive bool `gorm:"column:is_active;not null;default:true" json:"is_active"`
 
@@ -213,6 +242,19 @@ func (AccountCredential) TableName() string {
 	return "account_credentials"
 }
 
+// AllowsModel 凭据是否可以用于指定模型，未限制模型时对所有模型可用
+func (c *AccountCredential) AllowsModel(model string) bool {
+	if len(c.AllowedModels) == 0 {
+		return true
+	}
+	for _, m := range c.AllowedModels {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
 // Activate 婵€娲诲嚟鎹?
 func (c *AccountCredential) Activate() {
 	c.IsActive = true
@@ -289,6 +331,22 @@ func (c *AccountCredential) IncrementUsage() {
 	}
 }
 
+// IsNearRateLimit 当前窗口剩余请求数是否已不足上限的 percent%，窗口已重置或未设置上限时返回 false
+func (c *AccountCredential) IsNearRateLimit(percent int) bool {
+	if c.RateLimit == 0 || c.RateLimitResetAt == nil || time.Now().After(*c.RateLimitResetAt) {
+		return false
+	}
+	remaining := c.RateLimit - c.CurrentUsage
+	return remaining <= 0 || remaining*100 < c.RateLimit*percent
+}
+
+// ApplyUpstreamRateLimit 以上游响应头报告的限额为准更新速率限制状态
+func (c *AccountCredential) ApplyUpstreamRateLimit(limit, remaining int, resetAt time.Time) {
+	c.RateLimit = limit
+	c.CurrentUsage = limit - remaining
+	c.RateLimitResetAt = &resetAt
+}
+
 // 璁よ瘉绫诲瀷甯搁噺
 const (
 	AuthTypeAPIKey = "api_key"
@@ -316,4 +374,5 @@ const (
 	HealthStatusHealthy   = "healthy"
 	HealthStatusUnhealthy = "unhealthy"
 	HealthStatusUnknown   = "unknown"
+	HealthStatusDegraded  = "degraded" // 已过期且刷新失败，仍在宽限期内继续使用
 )
@@ -3,6 +3,7 @@ package accountpool
 import (
 	"api-aggregator/backend/internal/adapter"
 	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/runtime"
 	"context"
 	"fmt"
 	"math/rand"
@@ -11,29 +12,69 @@ import (
 	"time"
 )
 
+// defaultNearLimitPercent 未加载运行时配置时，凭据剩余请求数低于该百分比即优先使用其他凭据
+const defaultNearLimitPercent = 10
+
 // PoolManager 账号池管理器
 // 负责从账号池中选择凭据并创建适配器
 type PoolManager struct {
 	repo           Repository
 	modelMapper    adapter.KiroModelMapper
 	refreshService *KiroRefreshService
+	runtimeConfig  *runtime.Manager
+	capacity       *CapacityMonitor
 	mu             sync.RWMutex
 	roundRobinIdx  map[uint]int // 轮询索引，key为poolID
+	rotation       map[rotationKey]*rotationWindow
 }
 
 // NewPoolManager 创建账号池管理器
-func NewPoolManager(repo Repository, modelMapper adapter.KiroModelMapper) *PoolManager {
+func NewPoolManager(repo Repository, modelMapper adapter.KiroModelMapper, runtimeConfig *runtime.Manager) *PoolManager {
 	return &PoolManager{
 		repo:           repo,
 		modelMapper:    modelMapper,
 		refreshService: NewKiroRefreshService(),
+		runtimeConfig:  runtimeConfig,
 		roundRobinIdx:  make(map[uint]int),
+		rotation:       make(map[rotationKey]*rotationWindow),
+	}
+}
+
+// SetCapacityMonitor 设置账号池容量监控，凭据健康状态变化时重新统计容量
+func (pm *PoolManager) SetCapacityMonitor(monitor *CapacityMonitor) {
+	pm.capacity = monitor
+}
+
+// updateCapacity 凭据健康状态变化后重新统计所在账号池的容量
+func (pm *PoolManager) updateCapacity(ctx context.Context, poolID uint) {
+	if pm.capacity == nil {
+		return
 	}
+	pm.capacity.Update(ctx, poolID)
 }
 
 // GetAdapter 从账号池获取适配器
+// 配置引用多个账号池时按权重选择账号池，选中的账号池没有可用凭据时依次换用其余账号池
+// 只选择允许请求模型的凭据
 // 返回：适配器实例、凭据ID、错误
-func (pm *PoolManager) GetAdapter(ctx context.Context, poolID uint) (interface{}, uint, error) {
+func (pm *PoolManager) GetAdapter(ctx context.Context, pools []WeightedPool, model string) (interface{}, uint, error) {
+	if len(pools) == 0 {
+		return nil, 0, errors.New(500001, "no account pool configured")
+	}
+
+	var lastErr error
+	for _, poolID := range orderPools(pools) {
+		adapterInstance, credID, err := pm.getPoolAdapter(ctx, poolID, model)
+		if err == nil {
+			return adapterInstance, credID, nil
+		}
+		lastErr = err
+	}
+	return nil, 0, lastErr
+}
+
+// getPoolAdapter 从单个账号池选择凭据并创建适配器
+func (pm *PoolManager) getPoolAdapter(ctx context.Context, poolID uint, model string) (interface{}, uint, error) {
 	// 获取账号池
 	pool, err := pm.repo.FindByID(ctx, poolID)
 	if err != nil {
@@ -54,23 +95,36 @@ func (pm *PoolManager) GetAdapter(ctx context.Context, poolID uint) (interface{}
 		return nil, 0, errors.New(500001, "no active credentials in pool")
 	}
 
-	// 根据策略选择凭据
-	cred, err := pm.selectCredential(pool, creds)
+	// 只保留允许该模型的凭据
+	creds = credentialsForModel(creds, model)
+	if len(creds) == 0 {
+		return nil, 0, errors.New(500001, fmt.Sprintf("no active credential in pool %d allows model %s", poolID, model))
+	}
+
+	// 根据策略和轮换窗口选择凭据，接近速率上限的凭据只在没有其他凭据时使用
+	cred, err := pm.selectWithRotation(pool, model, pm.preferAvailable(creds), time.Now())
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 如果是 Kiro 凭据且已过期，尝试刷新
-	if cred.Provider == "kiro" && cred.IsExpired() {
+	// 宽限期内的 degraded 凭据直接使用，由刷新调度器在后台重试
+	grace := credentialGracePeriod(pm.runtimeConfig)
+	if cred.Provider == "kiro" && cred.IsExpired() &&
+		!(cred.HealthStatus == HealthStatusDegraded && time.Now().Before(cred.GraceDeadline(grace))) {
 		if err := pm.refreshService.RefreshKiroToken(ctx, cred); err != nil {
-			// 刷新失败，标记为不健康
-			cred.UpdateHealthStatus(HealthStatusUnhealthy)
-			cred.LastError = fmt.Sprintf("failed to refresh token: %v", err)
+			// 刷新失败：宽限期内标记为 degraded 继续使用，否则标记为不健康
+			usable, entered := cred.markRefreshFailed(err, grace, time.Now())
 			pm.repo.UpdateCredential(ctx, cred)
-			return nil, 0, errors.Wrap(err, 500001, "failed to refresh kiro token")
-		}
-		// 刷新成功，保存更新
-		if err := pm.repo.UpdateCredential(ctx, cred); err != nil {
+			pm.updateCapacity(ctx, cred.PoolID)
+			if entered && pm.capacity != nil {
+				pm.capacity.notifyDegraded(cred, cred.GraceDeadline(grace))
+			}
+			if !usable {
+				return nil, 0, errors.Wrap(err, 500001, "failed to refresh kiro token")
+			}
+		} else if err := pm.repo.UpdateCredential(ctx, cred); err != nil {
+			// 刷新成功，保存更新
 			return nil, 0, errors.Wrap(err, "failed to save refreshed credential")
 		}
 	}
@@ -93,6 +147,45 @@ func (pm *PoolManager) GetAdapter(ctx context.Context, poolID uint) (interface{}
 	return adapterInstance, cred.ID, nil
 }
 
+// IsPoolHealthy 账号池是否启用且错误率正常
+func (pm *PoolManager) IsPoolHealthy(ctx context.Context, poolID uint) bool {
+	pool, err := pm.repo.FindByID(ctx, poolID)
+	if err != nil {
+		return false
+	}
+	return pool.IsHealthy()
+}
+
+// credentialsForModel 过滤掉不允许该模型的凭据
+func credentialsForModel(creds []*AccountCredential, model string) []*AccountCredential {
+	allowed := make([]*AccountCredential, 0, len(creds))
+	for _, cred := range creds {
+		if cred.AllowsModel(model) {
+			allowed = append(allowed, cred)
+		}
+	}
+	return allowed
+}
+
+// preferAvailable 过滤掉已达到或接近速率上限的凭据；全部接近上限时返回原列表，由速率检查决定是否拒绝
+func (pm *PoolManager) preferAvailable(creds []*AccountCredential) []*AccountCredential {
+	percent := defaultNearLimitPercent
+	if pm.runtimeConfig != nil {
+		percent = pm.runtimeConfig.Get().GetPoolNearLimitPercent()
+	}
+
+	available := make([]*AccountCredential, 0, len(creds))
+	for _, cred := range creds {
+		if !cred.IsNearRateLimit(percent) {
+			available = append(available, cred)
+		}
+	}
+	if len(available) == 0 {
+		return creds
+	}
+	return available
+}
+
 // selectCredential 根据策略选择凭据
 func (pm *PoolManager) selectCredential(pool *AccountPool, creds []*AccountCredential) (*AccountCredential, error) {
 	switch pool.Strategy {
@@ -262,6 +355,7 @@ func (pm *PoolManager) RecordSuccess(ctx context.Context, credID uint) {
 		return
 	}
 
+	previousStatus := cred.HealthStatus
 	cred.IncrementRequests()
 	cred.LastError = "" // 清除错误信息
 	
@@ -274,6 +368,20 @@ func (pm *PoolManager) RecordSuccess(ctx context.Context, credID uint) {
 	}
 	
 	pm.repo.UpdateCredential(ctx, cred)
+	if cred.HealthStatus != previousStatus {
+		pm.updateCapacity(ctx, cred.PoolID)
+	}
+}
+
+// RecordRateLimit 按上游响应头报告的速率限制更新凭据的限额、已用量和重置时间
+func (pm *PoolManager) RecordRateLimit(ctx context.Context, credID uint, state adapter.RateLimitState) {
+	cred, err := pm.repo.FindCredentialByID(ctx, credID)
+	if err != nil {
+		return
+	}
+
+	cred.ApplyUpstreamRateLimit(state.Limit, state.Remaining, state.ResetAt)
+	pm.repo.UpdateCredential(ctx, cred)
 }
 
 // RecordError 记录失败请求
@@ -283,6 +391,7 @@ func (pm *PoolManager) RecordError(ctx context.Context, credID uint, errMsg stri
 		return
 	}
 
+	previousStatus := cred.HealthStatus
 	cred.IncrementRequests()
 	cred.IncrementErrors()
 	cred.LastError = errMsg
@@ -302,6 +411,9 @@ func (pm *PoolManager) RecordError(ctx context.Context, credID uint, errMsg stri
 	}
 
 	pm.repo.UpdateCredential(ctx, cred)
+	if cred.HealthStatus != previousStatus {
+		pm.updateCapacity(ctx, cred.PoolID)
+	}
 }
 
 func init() {
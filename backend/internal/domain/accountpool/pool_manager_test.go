@@ -0,0 +1,162 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRepo 内存中的账号池和凭据
+type fakeRepo struct {
+	Repository
+	pool  *AccountPool
+	creds map[uint]*AccountCredential
+	order []uint
+}
+
+func newFakeRepo(creds ...*AccountCredential) *fakeRepo {
+	r := &fakeRepo{pool: &AccountPool{ID: 1, IsActive: true, Strategy: StrategyRoundRobin}, creds: make(map[uint]*AccountCredential)}
+	for _, cred := range creds {
+		r.creds[cred.ID] = cred
+		r.order = append(r.order, cred.ID)
+	}
+	return r
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id uint) (*AccountPool, error) {
+	return r.pool, nil
+}
+
+func (r *fakeRepo) FindActiveCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error) {
+	creds := make([]*AccountCredential, 0, len(r.order))
+	for _, id := range r.order {
+		creds = append(creds, r.creds[id])
+	}
+	return creds, nil
+}
+
+func (r *fakeRepo) FindCredentialByID(ctx context.Context, id uint) (*AccountCredential, error) {
+	if cred, ok := r.creds[id]; ok {
+		return cred, nil
+	}
+	return nil, fmt.Errorf("credential %d not found", id)
+}
+
+func (r *fakeRepo) FindCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error) {
+	creds := make([]*AccountCredential, 0, len(r.order))
+	for _, id := range r.order {
+		if cred := r.creds[id]; cred.PoolID == poolID {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+func (r *fakeRepo) UpdateCredential(ctx context.Context, cred *AccountCredential) error {
+	r.creds[cred.ID] = cred
+	return nil
+}
+
+// singlePool 只引用账号池 1 的配置
+var singlePool = []WeightedPool{{PoolID: 1, Weight: 1}}
+
+func openAICredential(id uint) *AccountCredential {
+	return &AccountCredential{ID: id, PoolID: 1, Provider: "openai", AuthType: AuthTypeAPIKey, APIKey: "k", IsActive: true, Weight: 1}
+}
+
+func TestPoolManager_RecordRateLimitUpdatesCredential(t *testing.T) {
+	repo := newFakeRepo(openAICredential(1))
+	pm := NewPoolManager(repo, nil, nil)
+	resetAt := time.Now().Add(30 * time.Second)
+
+	pm.RecordRateLimit(context.Background(), 1, adapter.RateLimitState{Limit: 100, Remaining: 4, ResetAt: resetAt})
+
+	cred := repo.creds[1]
+	if cred.RateLimit != 100 || cred.CurrentUsage != 96 || cred.RateLimitResetAt == nil || !cred.RateLimitResetAt.Equal(resetAt) {
+		t.Fatalf("Expected limit 100, usage 96, reset %v; got %d, %d, %v", resetAt, cred.RateLimit, cred.CurrentUsage, cred.RateLimitResetAt)
+	}
+	if !cred.IsNearRateLimit(defaultNearLimitPercent) {
+		t.Error("Expected 4% remaining to count as near the limit")
+	}
+}
+
+func TestPoolManager_SelectionAvoidsNearLimitCredential(t *testing.T) {
+	near, fresh := openAICredential(1), openAICredential(2)
+	repo := newFakeRepo(near, fresh)
+	pm := NewPoolManager(repo, nil, nil)
+	pm.RecordRateLimit(context.Background(), 1, adapter.RateLimitState{Limit: 100, Remaining: 5, ResetAt: time.Now().Add(time.Minute)})
+
+	// 轮询策略下本应交替使用，接近上限的凭据应被跳过
+	for i := 0; i < 4; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4")
+		if err != nil {
+			t.Fatalf("GetAdapter: %v", err)
+		}
+		if credID != 2 {
+			t.Fatalf("Selection %d: expected the credential with headroom, got %d", i+1, credID)
+		}
+	}
+}
+
+func TestPoolManager_NearLimitCredentialUsedWhenNoAlternative(t *testing.T) {
+	repo := newFakeRepo(openAICredential(1))
+	pm := NewPoolManager(repo, nil, nil)
+	pm.RecordRateLimit(context.Background(), 1, adapter.RateLimitState{Limit: 100, Remaining: 5, ResetAt: time.Now().Add(time.Minute)})
+
+	if _, credID, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4"); err != nil || credID != 1 {
+		t.Fatalf("Expected the only credential still used while it has requests left, got %d, %v", credID, err)
+	}
+
+	// 上游报告已耗尽时拒绝，直到窗口重置
+	pm.RecordRateLimit(context.Background(), 1, adapter.RateLimitState{Limit: 100, Remaining: 0, ResetAt: time.Now().Add(time.Minute)})
+	if _, _, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4"); err == nil {
+		t.Error("Expected an exhausted credential to be rate limited")
+	}
+}
+
+func TestPoolManager_SelectsOnlyCredentialsAllowingModel(t *testing.T) {
+	limited, full := openAICredential(1), openAICredential(2)
+	limited.AllowedModels = StringArray{"claude-sonnet-4"}
+	repo := newFakeRepo(limited, full)
+	pm := NewPoolManager(repo, nil, nil)
+
+	// 轮询策略下本应交替使用，没有权限的凭据应被跳过
+	for i := 0; i < 4; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), singlePool, "claude-opus-4")
+		if err != nil {
+			t.Fatalf("GetAdapter: %v", err)
+		}
+		if credID != 2 {
+			t.Fatalf("Selection %d: expected the unrestricted credential, got %d", i+1, credID)
+		}
+	}
+
+	selected := map[uint]bool{}
+	for i := 0; i < 2; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), singlePool, "claude-sonnet-4")
+		if err != nil {
+			t.Fatalf("GetAdapter: %v", err)
+		}
+		selected[credID] = true
+	}
+	if !selected[1] || !selected[2] {
+		t.Errorf("Expected both credentials used for an allowed model, got %v", selected)
+	}
+}
+
+func TestPoolManager_ErrorsWhenNoCredentialAllowsModel(t *testing.T) {
+	cred := openAICredential(1)
+	cred.AllowedModels = StringArray{"claude-sonnet-4"}
+	pm := NewPoolManager(newFakeRepo(cred), nil, nil)
+
+	_, _, err := pm.GetAdapter(context.Background(), singlePool, "claude-opus-4")
+	if err == nil || !strings.Contains(err.Error(), "allows model claude-opus-4") {
+		t.Fatalf("Expected an error naming the unserved model, got %v", err)
+	}
+	if cred.TotalRequests != 0 {
+		t.Errorf("Expected the restricted credential left unused, got %d requests", cred.TotalRequests)
+	}
+}
@@ -2,8 +2,8 @@ package accountpool
 
 import (
 	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
 	"context"
-	"fmt"
 	"time"
 )
 
@@ -14,6 +14,8 @@ type RefreshScheduler struct {
 	interval       time.Duration
 	stopCh         chan struct{}
 	logger         logger.Logger
+	capacity       *CapacityMonitor
+	runtimeConfig  *runtime.Manager
 }
 
 // NewRefreshScheduler 创建刷新调度器
@@ -32,6 +34,16 @@ func NewRefreshScheduler(
 	}
 }
 
+// SetCapacityMonitor 设置账号池容量监控，每轮刷新后重新统计涉及的账号池容量
+func (s *RefreshScheduler) SetCapacityMonitor(monitor *CapacityMonitor) {
+	s.capacity = monitor
+}
+
+// SetRuntimeConfig 设置运行时配置，用于读取过期凭据刷新失败后的宽限期
+func (s *RefreshScheduler) SetRuntimeConfig(runtimeConfig *runtime.Manager) {
+	s.runtimeConfig = runtimeConfig
+}
+
 // Start 启动定时刷新任务
 func (s *RefreshScheduler) Start(ctx context.Context) {
 	ticker := time.NewTicker(s.interval)
@@ -76,15 +88,18 @@ func (s *RefreshScheduler) refreshExpiredTokens(ctx context.Context) {
 	s.logger.Info("Found expiring credentials", logger.Int("count", len(creds)))
 	
 	// 刷新每个凭据
+	grace := credentialGracePeriod(s.runtimeConfig)
+	pools := make(map[uint]bool)
 	for _, cred := range creds {
 		if err := s.refreshService.RefreshKiroToken(ctx, cred); err != nil {
 			s.logger.Error("Failed to refresh token",
 				logger.Uint("credential_id", cred.ID),
 				logger.Error(err))
 			
-			// 标记为不健康
-			cred.UpdateHealthStatus(HealthStatusUnhealthy)
-			cred.LastError = fmt.Sprintf("auto refresh failed: %v", err)
+			// 宽限期内标记为 degraded 继续使用并在下一轮重试，宽限期已过则标记为不健康
+			if _, entered := cred.markRefreshFailed(err, grace, time.Now()); entered && s.capacity != nil {
+				s.capacity.notifyDegraded(cred, cred.GraceDeadline(grace))
+			}
 		} else {
 			s.logger.Info("Token refreshed successfully",
 				logger.Uint("credential_id", cred.ID))
@@ -96,5 +111,15 @@ func (s *RefreshScheduler) refreshExpiredTokens(ctx context.Context) {
 		
 		// 保存更新
 		s.repo.UpdateCredential(ctx, cred)
+		pools[cred.PoolID] = true
+	}
+
+	if s.capacity == nil {
+		return
+	}
+	for poolID := range pools {
+		if _, err := s.capacity.Update(ctx, poolID); err != nil {
+			s.logger.Error("Failed to update pool capacity", logger.Uint("pool_id", poolID), logger.Error(err))
+		}
 	}
 }
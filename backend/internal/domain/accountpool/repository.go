@@ -26,6 +26,7 @@ type Repository interface {
 	DeleteCredential(ctx context.Context, id uint) error
 	FindCredentialByID(ctx context.Context, id uint) (*AccountCredential, error)
 	FindActiveCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error)
+	FindCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error)
 	ListCredentials(ctx context.Context, filter *CredentialFilter, opts *query.Options) ([]*AccountCredential, int64, error)
 	UpdateCredentialStatus(ctx context.Context, id uint, isActive bool) error
 	IncrementCredentialRequests(ctx context.Context, id uint) error
@@ -36,6 +37,7 @@ type Repository interface {
 	CreateRequestLog(ctx context.Context, log *AccountPoolRequestLog) error
 	ListRequestLogs(ctx context.Context, filter *RequestLogFilter, opts *query.Options) ([]*AccountPoolRequestLog, int64, error)
 	GetPoolRequestStats(ctx context.Context, poolID uint) (map[string]interface{}, error)
+	GetCredentialUsage(ctx context.Context, poolID uint, from, to time.Time) ([]*CredentialUsageRow, error)
 }
 
 type repository struct {
@@ -228,6 +230,25 @@ func (r *repository) GetPoolRequestStats(ctx context.Context, poolID uint) (map[
 	}, nil
 }
 
+// GetCredentialUsage 按凭据聚合账号池在 [from, to) 内的请求日志，走 (pool_id, created_at) 复合索引
+func (r *repository) GetCredentialUsage(ctx context.Context, poolID uint, from, to time.Time) ([]*CredentialUsageRow, error) {
+	var rows []*CredentialUsageRow
+	err := r.db.WithContext(ctx).
+		Model(&AccountPoolRequestLog{}).
+		Where("pool_id = ? AND created_at >= ? AND created_at < ?", poolID, from, to).
+		Select(`
+			credential_id,
+			COUNT(*) as requests,
+			SUM(CASE WHEN status_code >= 400 OR error_message != '' THEN 1 ELSE 0 END) as errors,
+			COALESCE(SUM(tokens_used), 0) as tokens,
+			COALESCE(SUM(response_time), 0) as latency_sum,
+			COUNT(response_time) as latency_count
+		`).
+		Group("credential_id").
+		Scan(&rows).Error
+	return rows, err
+}
+
 // CreateCredential 创建凭据
 func (r *repository) CreateCredential(ctx context.Context, cred *AccountCredential) error {
 	return r.db.WithContext(ctx).Create(cred).Error
@@ -343,3 +364,12 @@ func (r *repository) FindActiveCredentialsByPoolID(ctx context.Context, poolID u
 		Find(&creds).Error
 	return creds, err
 }
+
+// FindCredentialsByPoolID 查找账号池的所有凭据（含停用和不健康的凭据）
+func (r *repository) FindCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error) {
+	var creds []*AccountCredential
+	err := r.db.WithContext(ctx).
+		Where("pool_id = ?", poolID).
+		Find(&creds).Error
+	return creds, err
+}
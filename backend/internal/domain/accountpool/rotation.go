@@ -0,0 +1,88 @@
+package accountpool
+
+import "time"
+
+// rotationKey 轮换窗口按账号池和模型分别维护，不同模型可用的凭据可能不同
+type rotationKey struct {
+	poolID uint
+	model  string
+}
+
+// rotationWindow 当前窗口使用的凭据、已处理的请求数和窗口开始时间
+type rotationWindow struct {
+	credID  uint
+	served  int
+	started time.Time
+}
+
+// HasRotationWindow 账号池是否按请求数或时间窗口轮换凭据
+func (p *AccountPool) HasRotationWindow() bool {
+	return p.RotationRequests > 0 || p.RotationInterval > 0
+}
+
+// expired 当前凭据已处理满 RotationRequests 个请求或已使用 RotationInterval 秒
+func (w *rotationWindow) expired(pool *AccountPool, now time.Time) bool {
+	if pool.RotationRequests > 0 && w.served >= pool.RotationRequests {
+		return true
+	}
+	return pool.RotationInterval > 0 && now.Sub(w.started) >= time.Duration(pool.RotationInterval)*time.Second
+}
+
+// selectWithRotation 按轮换窗口选择凭据：窗口内继续使用当前凭据，即使请求都成功，窗口结束后按策略换用其他凭据
+// creds 已过滤掉接近速率上限的凭据，当前凭据不在其中（接近上限、被停用或不允许该模型）时立即换用
+func (pm *PoolManager) selectWithRotation(pool *AccountPool, model string, creds []*AccountCredential, now time.Time) (*AccountCredential, error) {
+	if !pool.HasRotationWindow() {
+		return pm.selectCredential(pool, creds)
+	}
+
+	key := rotationKey{poolID: pool.ID, model: model}
+	pm.mu.Lock()
+	window := pm.rotation[key]
+	if window != nil && !window.expired(pool, now) {
+		if cred := findCredential(creds, window.credID); cred != nil {
+			window.served++
+			pm.mu.Unlock()
+			return cred, nil
+		}
+	}
+	pm.mu.Unlock()
+
+	// 轮询本身会换用下一个凭据；其他策略跳过上一窗口的凭据，保证轮换后确实换用了凭据
+	candidates := creds
+	if window != nil && pool.Strategy != StrategyRoundRobin && pool.Strategy != "" {
+		candidates = withoutCredential(creds, window.credID)
+	}
+	cred, err := pm.selectCredential(pool, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.mu.Lock()
+	pm.rotation[key] = &rotationWindow{credID: cred.ID, served: 1, started: now}
+	pm.mu.Unlock()
+	return cred, nil
+}
+
+// findCredential 按 ID 查找凭据
+func findCredential(creds []*AccountCredential, id uint) *AccountCredential {
+	for _, cred := range creds {
+		if cred.ID == id {
+			return cred
+		}
+	}
+	return nil
+}
+
+// withoutCredential 去掉指定 ID 的凭据，去掉后为空时返回原列表
+func withoutCredential(creds []*AccountCredential, id uint) []*AccountCredential {
+	rest := make([]*AccountCredential, 0, len(creds))
+	for _, cred := range creds {
+		if cred.ID != id {
+			rest = append(rest, cred)
+		}
+	}
+	if len(rest) == 0 {
+		return creds
+	}
+	return rest
+}
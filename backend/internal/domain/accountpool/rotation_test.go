@@ -0,0 +1,165 @@
+package accountpool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// rotationCredentials 返回 n 个权重相同的凭据
+func rotationCredentials(n int) []*AccountCredential {
+	creds := make([]*AccountCredential, n)
+	for i := range creds {
+		creds[i] = openAICredential(uint(i + 1))
+	}
+	return creds
+}
+
+func TestSelectWithRotation_RotatesEveryNRequests(t *testing.T) {
+	pm := NewPoolManager(newFakeRepo(), nil, nil)
+	pool := &AccountPool{ID: 1, Strategy: StrategyRoundRobin, RotationRequests: 5}
+	creds := rotationCredentials(3)
+	now := time.Now()
+
+	var sequence []uint
+	counts := make(map[uint]int)
+	for i := 0; i < 30; i++ {
+		cred, err := pm.selectWithRotation(pool, "gpt-4", creds, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sequence = append(sequence, cred.ID)
+		counts[cred.ID]++
+	}
+
+	// 每个窗口内连续使用同一凭据，满 5 个请求后换用下一个
+	for i := 0; i < len(sequence); i += 5 {
+		for j := i + 1; j < i+5; j++ {
+			if sequence[j] != sequence[i] {
+				t.Fatalf("request %d used credential %d inside a window started by %d: %v", j, sequence[j], sequence[i], sequence)
+			}
+		}
+		if i > 0 && sequence[i] == sequence[i-1] {
+			t.Fatalf("window at request %d did not rotate away from credential %d: %v", i, sequence[i], sequence)
+		}
+	}
+	for _, cred := range creds {
+		if counts[cred.ID] != 10 {
+			t.Fatalf("expected usage spread evenly across credentials, got %v", counts)
+		}
+	}
+}
+
+func TestSelectWithRotation_RotatesAfterInterval(t *testing.T) {
+	pm := NewPoolManager(newFakeRepo(), nil, nil)
+	pool := &AccountPool{ID: 1, Strategy: StrategyRoundRobin, RotationInterval: 60}
+	creds := rotationCredentials(2)
+	start := time.Now()
+
+	counts := make(map[uint]int)
+	var previous uint
+	// 每 10 秒一个请求，持续 10 分钟
+	for i := 0; i < 60; i++ {
+		now := start.Add(time.Duration(i*10) * time.Second)
+		cred, err := pm.selectWithRotation(pool, "gpt-4", creds, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%6 != 0 && cred.ID != previous {
+			t.Fatalf("rotated to credential %d %v into a 60s window", cred.ID, now.Sub(start))
+		}
+		if i > 0 && i%6 == 0 && cred.ID == previous {
+			t.Fatalf("credential %d kept after its 60s window", cred.ID)
+		}
+		previous = cred.ID
+		counts[cred.ID]++
+	}
+	if counts[1] != 30 || counts[2] != 30 {
+		t.Fatalf("expected usage spread evenly across credentials, got %v", counts)
+	}
+}
+
+func TestSelectWithRotation_LeavesCredentialNearRateLimit(t *testing.T) {
+	pm := NewPoolManager(newFakeRepo(), nil, nil)
+	pool := &AccountPool{ID: 1, Strategy: StrategyRoundRobin, RotationRequests: 100}
+	creds := rotationCredentials(2)
+	now := time.Now()
+
+	first, _ := pm.selectWithRotation(pool, "gpt-4", creds, now)
+	// 当前凭据接近速率上限后被过滤，窗口未结束也立即换用
+	first.RateLimit, first.CurrentUsage = 100, 99
+	resetAt := now.Add(time.Minute)
+	first.RateLimitResetAt = &resetAt
+
+	next, err := pm.selectWithRotation(pool, "gpt-4", pm.preferAvailable(creds), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID == first.ID {
+		t.Fatalf("expected rotation away from credential %d near its rate limit", first.ID)
+	}
+}
+
+func TestSelectWithRotation_DisabledSelectsPerRequest(t *testing.T) {
+	pm := NewPoolManager(newFakeRepo(), nil, nil)
+	pool := &AccountPool{ID: 1, Strategy: StrategyRoundRobin}
+	creds := rotationCredentials(2)
+
+	a, _ := pm.selectWithRotation(pool, "gpt-4", creds, time.Now())
+	b, _ := pm.selectWithRotation(pool, "gpt-4", creds, time.Now())
+	if a.ID == b.ID {
+		t.Fatal("expected round robin to move to the next credential on every request without a rotation window")
+	}
+}
+
+func TestSelectWithRotation_WeightedStrategyMovesToAnotherCredential(t *testing.T) {
+	pm := NewPoolManager(newFakeRepo(), nil, nil)
+	pool := &AccountPool{ID: 1, Strategy: StrategyWeightedRoundRobin, RotationRequests: 3}
+	creds := rotationCredentials(3)
+	creds[0].Weight = 5
+	now := time.Now()
+
+	var previous uint
+	counts := make(map[uint]int)
+	for i := 0; i < 300; i++ {
+		cred, err := pm.selectWithRotation(pool, "gpt-4", creds, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i > 0 && i%3 == 0 && cred.ID == previous {
+			t.Fatalf("request %d: credential %d kept after its window", i, cred.ID)
+		}
+		previous = cred.ID
+		counts[cred.ID]++
+	}
+	for _, cred := range creds {
+		if counts[cred.ID] == 0 {
+			t.Fatalf("credential %d never selected: %v", cred.ID, counts)
+		}
+	}
+}
+
+func TestGetAdapter_RotationWindowSpreadsUsage(t *testing.T) {
+	repo := newFakeRepo(rotationCredentials(4)...)
+	repo.pool.RotationRequests = 10
+	pm := NewPoolManager(repo, nil, nil)
+
+	served := make(map[uint]int)
+	var previous uint
+	for i := 0; i < 400; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), singlePool, "gpt-4")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i%10 != 0 && credID != previous {
+			t.Fatalf("request %d switched credential inside a rotation window", i)
+		}
+		previous = credID
+		served[credID]++
+	}
+	for id := range repo.creds {
+		if served[id] != 100 {
+			t.Fatalf("expected 100 requests per credential, got %v", served)
+		}
+	}
+}
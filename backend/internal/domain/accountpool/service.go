@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 // Service 账号池服务接口
@@ -17,6 +18,8 @@ type Service interface {
 	ListPools(ctx context.Context, filter *PoolFilter, opts *query.Options) (*PoolListResponse, error)
 	UpdatePoolStatus(ctx context.Context, id uint, isActive bool) (*PoolResponse, error)
 	GetPoolStats(ctx context.Context, id uint) (*PoolStatsResponse, error)
+	GetPoolCapacity(ctx context.Context) ([]*PoolCapacity, error)
+	GetPoolUsage(ctx context.Context, id uint, from, to time.Time) (*PoolUsageResponse, error)
 	
 	// 凭据相关
 	CreateCredential(ctx context.Context, req *CreateCredentialRequest) (*CredentialResponse, error)
@@ -39,13 +42,16 @@ type Service interface {
 type service struct {
 	repo               Repository
 	kiroRefreshService *KiroRefreshService
+	capacity           *CapacityMonitor
 }
 
 // NewService 创建账号池服务实例
-func NewService(repo Repository) Service {
+// capacity 为 nil 时凭据变更不触发容量告警
+func NewService(repo Repository, capacity *CapacityMonitor) Service {
 	return &service{
 		repo:               repo,
 		kiroRefreshService: NewKiroRefreshService(),
+		capacity:           capacity,
 	}
 }
 
@@ -71,6 +77,11 @@ func (s *service) CreatePool(ctx context.Context, req *CreatePoolRequest) (*Pool
 			"strategy": "must be one of: round_robin, weighted_round_robin, least_connections, random",
 		})
 	}
+	if req.RotationRequests < 0 || req.RotationInterval < 0 {
+		return nil, errors.NewValidationError("invalid rotation window", map[string]string{
+			"rotation": "rotation_requests and rotation_interval must not be negative",
+		})
+	}
 
 	// 创建账号池
 	pool := &AccountPool{
@@ -78,6 +89,8 @@ func (s *service) CreatePool(ctx context.Context, req *CreatePoolRequest) (*Pool
 		Description:         req.Description,
 		Provider:            req.Provider,
 		Strategy:            req.Strategy,
+		RotationRequests:    req.RotationRequests,
+		RotationInterval:    req.RotationInterval,
 		HealthCheckInterval: req.HealthCheckInterval,
 		HealthCheckTimeout:  req.HealthCheckTimeout,
 		MaxRetries:          req.MaxRetries,
@@ -114,6 +127,22 @@ func (s *service) UpdatePool(ctx context.Context, id uint, req *UpdatePoolReques
 		}
 		pool.Strategy = *req.Strategy
 	}
+	if req.RotationRequests != nil {
+		if *req.RotationRequests < 0 {
+			return nil, errors.NewValidationError("invalid rotation window", map[string]string{
+				"rotation_requests": "must not be negative",
+			})
+		}
+		pool.RotationRequests = *req.RotationRequests
+	}
+	if req.RotationInterval != nil {
+		if *req.RotationInterval < 0 {
+			return nil, errors.NewValidationError("invalid rotation window", map[string]string{
+				"rotation_interval": "must not be negative",
+			})
+		}
+		pool.RotationInterval = *req.RotationInterval
+	}
 	if req.HealthCheckInterval != nil {
 		pool.HealthCheckInterval = *req.HealthCheckInterval
 	}
@@ -200,11 +229,21 @@ func (s *service) GetPoolStats(ctx context.Context, id uint) (*PoolStatsResponse
 		return nil, errors.Wrap(err, "failed to get pool request stats")
 	}
 
+	capacity, err := s.measureCapacity(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pool capacity")
+	}
+
 	// 构建响应
 	stats := &PoolStatsResponse{
 		PoolID:        pool.ID,
 		PoolName:      pool.Name,
 		Provider:      pool.Provider,
+		TotalCreds:    capacity.Total,
+		ActiveCreds:   capacity.Active,
+		HealthyCreds:  capacity.Healthy,
+		Capacity:      capacity.Ratio,
+		CapacityLow:   capacity.Low,
 		TotalRequests: pool.TotalRequests,
 		TotalErrors:   pool.TotalErrors,
 		ErrorRate:     pool.GetErrorRate(),
@@ -219,6 +258,51 @@ func (s *service) GetPoolStats(ctx context.Context, id uint) (*PoolStatsResponse
 	return stats, nil
 }
 
+// GetPoolCapacity 获取所有账号池的容量（健康凭据数 / 凭据总数）
+func (s *service) GetPoolCapacity(ctx context.Context) ([]*PoolCapacity, error) {
+	if s.capacity != nil {
+		capacities, err := s.capacity.UpdateAll(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get pool capacity")
+		}
+		return capacities, nil
+	}
+
+	pools, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list pools")
+	}
+	capacities := make([]*PoolCapacity, 0, len(pools))
+	for _, pool := range pools {
+		capacity, err := s.measureCapacity(ctx, pool.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get pool capacity")
+		}
+		capacities = append(capacities, capacity)
+	}
+	return capacities, nil
+}
+
+// measureCapacity 统计账号池容量，配置了容量监控时同时检查告警阈值
+func (s *service) measureCapacity(ctx context.Context, poolID uint) (*PoolCapacity, error) {
+	if s.capacity != nil {
+		return s.capacity.Update(ctx, poolID)
+	}
+	creds, err := s.repo.FindCredentialsByPoolID(ctx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	return measureCapacity(poolID, creds, 0), nil
+}
+
+// updateCapacity 凭据增删或启停后重新统计所在账号池的容量
+func (s *service) updateCapacity(ctx context.Context, poolID uint) {
+	if s.capacity == nil {
+		return
+	}
+	s.capacity.Update(ctx, poolID)
+}
+
 // CreateRequestLog 创建请求日志
 func (s *service) CreateRequestLog(ctx context.Context, log *AccountPoolRequestLog) error {
 	if err := s.repo.CreateRequestLog(ctx, log); err != nil {
@@ -302,11 +386,14 @@ func (s *service) CreateCredential(ctx context.Context, req *CreateCredentialReq
 		IsActive:     true,
 		HealthStatus: HealthStatusUnknown,
 		RateLimit:    req.RateLimit,
+
+		AllowedModels: StringArray(req.AllowedModels),
 	}
 
 	if err := s.repo.CreateCredential(ctx, cred); err != nil {
 		return nil, errors.Wrap(err, "failed to create credential")
 	}
+	s.updateCapacity(ctx, cred.PoolID)
 
 	return ToCredentialResponse(cred), nil
 }
@@ -361,11 +448,15 @@ func (s *service) UpdateCredential(ctx context.Context, id uint, req *UpdateCred
 	if req.RateLimit != nil {
 		cred.RateLimit = *req.RateLimit
 	}
+	if req.AllowedModels != nil {
+		cred.AllowedModels = StringArray(*req.AllowedModels)
+	}
 
 	// 保存更新
 	if err := s.repo.UpdateCredential(ctx, cred); err != nil {
 		return nil, errors.Wrap(err, "failed to update credential")
 	}
+	s.updateCapacity(ctx, cred.PoolID)
 
 	return ToCredentialResponse(cred), nil
 }
@@ -373,7 +464,7 @@ func (s *service) UpdateCredential(ctx context.Context, id uint, req *UpdateCred
 // DeleteCredential 删除凭据
 func (s *service) DeleteCredential(ctx context.Context, id uint) error {
 	// 检查凭据是否存在
-	_, err := s.repo.FindCredentialByID(ctx, id)
+	cred, err := s.repo.FindCredentialByID(ctx, id)
 	if err != nil {
 		return errors.NewNotFoundError("credential not found")
 	}
@@ -382,6 +473,7 @@ func (s *service) DeleteCredential(ctx context.Context, id uint) error {
 	if err := s.repo.DeleteCredential(ctx, id); err != nil {
 		return errors.Wrap(err, "failed to delete credential")
 	}
+	s.updateCapacity(ctx, cred.PoolID)
 
 	return nil
 }
@@ -418,6 +510,7 @@ func (s *service) UpdateCredentialStatus(ctx context.Context, id uint, isActive
 	}
 
 	cred.IsActive = isActive
+	s.updateCapacity(ctx, cred.PoolID)
 
 	return ToCredentialResponse(cred), nil
 }
@@ -0,0 +1,148 @@
+package accountpool
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultUsageRange 未指定 from 时统计的时间范围
+const defaultUsageRange = 7 * 24 * time.Hour
+
+// CredentialUsageRow 按凭据聚合的请求日志，CredentialID 为 nil 表示凭据已删除
+type CredentialUsageRow struct {
+	CredentialID *uint
+	Requests     int64
+	Errors       int64
+	Tokens       int64
+	LatencySum   int64 // response_time 之和（毫秒）
+	LatencyCount int64 // 记录了 response_time 的请求数
+}
+
+// UsageStats 一段时间内的请求量、错误、token 和平均延迟
+type UsageStats struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	Tokens       int64   `json:"tokens"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+
+	latencySum   int64
+	latencyCount int64
+}
+
+// CredentialUsage 单个凭据的用量，CredentialID 为 0 表示已删除的凭据
+type CredentialUsage struct {
+	CredentialID uint `json:"credential_id"`
+	UsageStats
+}
+
+// PoolUsageResponse 账号池在时间范围内的用量：按凭据明细及账号池合计
+type PoolUsageResponse struct {
+	PoolID      uint               `json:"pool_id"`
+	PoolName    string             `json:"pool_name"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Totals      UsageStats         `json:"totals"`
+	Credentials []*CredentialUsage `json:"credentials"`
+}
+
+// add 累加一行聚合结果并重新计算比率
+func (u *UsageStats) add(row *CredentialUsageRow) {
+	u.Requests += row.Requests
+	u.Errors += row.Errors
+	u.Tokens += row.Tokens
+	u.latencySum += row.LatencySum
+	u.latencyCount += row.LatencyCount
+	if u.Requests > 0 {
+		u.ErrorRate = float64(u.Errors) / float64(u.Requests)
+	}
+	if u.latencyCount > 0 {
+		u.AvgLatencyMs = float64(u.latencySum) / float64(u.latencyCount)
+	}
+}
+
+// GetPoolUsage 统计账号池在 [from, to) 内的用量，按凭据 ID 排序，已删除凭据的日志归入凭据 0
+func (s *service) GetPoolUsage(ctx context.Context, id uint, from, to time.Time) (*PoolUsageResponse, error) {
+	pool, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.NewNotFoundError("account pool not found")
+	}
+
+	rows, err := s.repo.GetCredentialUsage(ctx, id, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pool usage")
+	}
+
+	usage := &PoolUsageResponse{
+		PoolID:      pool.ID,
+		PoolName:    pool.Name,
+		From:        from,
+		To:          to,
+		Credentials: make([]*CredentialUsage, 0, len(rows)),
+	}
+	byCredential := make(map[uint]*CredentialUsage, len(rows))
+	for _, row := range rows {
+		var credentialID uint
+		if row.CredentialID != nil {
+			credentialID = *row.CredentialID
+		}
+		entry, ok := byCredential[credentialID]
+		if !ok {
+			entry = &CredentialUsage{CredentialID: credentialID}
+			byCredential[credentialID] = entry
+			usage.Credentials = append(usage.Credentials, entry)
+		}
+		entry.add(row)
+		usage.Totals.add(row)
+	}
+	sort.Slice(usage.Credentials, func(i, j int) bool {
+		return usage.Credentials[i].CredentialID < usage.Credentials[j].CredentialID
+	})
+	return usage, nil
+}
+
+// WriteCSV 按凭据逐行输出用量，最后一行为账号池合计
+func (u *PoolUsageResponse) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"Credential ID", "Requests", "Errors", "Error Rate", "Tokens", "Avg Latency (ms)"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, cred := range u.Credentials {
+		if err := writer.Write(usageCSVRow(strconv.FormatUint(uint64(cred.CredentialID), 10), &cred.UsageStats)); err != nil {
+			return err
+		}
+	}
+	if err := writer.Write(usageCSVRow("total", &u.Totals)); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func usageCSVRow(label string, stats *UsageStats) []string {
+	return []string{
+		label,
+		strconv.FormatInt(stats.Requests, 10),
+		strconv.FormatInt(stats.Errors, 10),
+		strconv.FormatFloat(stats.ErrorRate, 'f', 4, 64),
+		strconv.FormatInt(stats.Tokens, 10),
+		strconv.FormatFloat(stats.AvgLatencyMs, 'f', 1, 64),
+	}
+}
+
+// parseUsageTime 解析 from/to 参数，支持 RFC3339 和 2006-01-02，为空时返回 fallback
+func parseUsageTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,137 @@
+package accountpool
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// usageRepo 按与 SQL 相同的条件（pool_id、[from, to)）按凭据聚合预置的请求日志
+type usageRepo struct {
+	*fakeRepo
+	logs []*AccountPoolRequestLog
+}
+
+func (r *usageRepo) GetCredentialUsage(ctx context.Context, poolID uint, from, to time.Time) ([]*CredentialUsageRow, error) {
+	byCredential := make(map[uint]*CredentialUsageRow)
+	var rows []*CredentialUsageRow
+	for _, l := range r.logs {
+		if l.PoolID == nil || *l.PoolID != poolID || l.CreatedAt.Before(from) || !l.CreatedAt.Before(to) {
+			continue
+		}
+		var key uint
+		if l.CredentialID != nil {
+			key = *l.CredentialID
+		}
+		row, ok := byCredential[key]
+		if !ok {
+			row = &CredentialUsageRow{CredentialID: l.CredentialID}
+			byCredential[key] = row
+			rows = append(rows, row)
+		}
+		row.Requests++
+		if l.IsError() {
+			row.Errors++
+		}
+		row.Tokens += int64(l.TokensUsed)
+		row.LatencySum += int64(l.ResponseTime)
+		row.LatencyCount++
+	}
+	return rows, nil
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+func seededUsageService() *service {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(poolID uint, credentialID *uint, offset time.Duration, status, latency, tokens int) *AccountPoolRequestLog {
+		return &AccountPoolRequestLog{
+			PoolID: uintPtr(poolID), CredentialID: credentialID, CreatedAt: start.Add(offset),
+			StatusCode: status, ResponseTime: latency, TokensUsed: tokens,
+		}
+	}
+	repo := &usageRepo{fakeRepo: newFakeRepo(), logs: []*AccountPoolRequestLog{
+		entry(1, uintPtr(2), time.Hour, 200, 100, 50),
+		entry(1, uintPtr(2), 2*time.Hour, 500, 300, 0),
+		entry(1, uintPtr(1), 3*time.Hour, 200, 200, 40),
+		entry(1, nil, 4*time.Hour, 200, 400, 10),           // 凭据已删除
+		entry(1, uintPtr(1), -time.Hour, 200, 1000, 999),   // 早于范围
+		entry(1, uintPtr(1), 24*time.Hour, 200, 1000, 999), // 范围结束时刻，不包含
+		entry(2, uintPtr(3), time.Hour, 200, 1000, 999),    // 其他账号池
+	}}
+	repo.pool.Name = "kiro"
+	return &service{repo: repo}
+}
+
+func TestGetPoolUsage_AggregatesPerCredentialInRange(t *testing.T) {
+	svc := seededUsageService()
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	usage, err := svc.GetPoolUsage(context.Background(), 1, from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(usage.Credentials) != 3 {
+		t.Fatalf("Expected deleted, 1 and 2 credential rows, got %+v", usage.Credentials)
+	}
+	deleted, first, second := usage.Credentials[0], usage.Credentials[1], usage.Credentials[2]
+	if deleted.CredentialID != 0 || deleted.Requests != 1 || deleted.Tokens != 10 {
+		t.Errorf("Expected deleted credential logs under credential 0, got %+v", deleted)
+	}
+	if first.CredentialID != 1 || first.Requests != 1 || first.Errors != 0 || first.Tokens != 40 || first.AvgLatencyMs != 200 {
+		t.Errorf("Expected only the in-range request for credential 1, got %+v", first)
+	}
+	if second.CredentialID != 2 || second.Requests != 2 || second.Errors != 1 || second.ErrorRate != 0.5 || second.Tokens != 50 || second.AvgLatencyMs != 200 {
+		t.Errorf("Expected two requests with one error for credential 2, got %+v", second)
+	}
+
+	totals := usage.Totals
+	if totals.Requests != 4 || totals.Errors != 1 || totals.Tokens != 100 || totals.AvgLatencyMs != 250 || totals.ErrorRate != 0.25 {
+		t.Errorf("Expected pool totals over the range, got %+v", totals)
+	}
+	if usage.PoolName != "kiro" {
+		t.Errorf("Expected pool name, got %q", usage.PoolName)
+	}
+}
+
+func TestPoolUsage_WriteCSV(t *testing.T) {
+	svc := seededUsageService()
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	usage, err := svc.GetPoolUsage(context.Background(), 1, from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := usage.WriteCSV(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected header, 3 credentials and a total row, got %q", buf.String())
+	}
+	if lines[3] != "2,2,1,0.5000,50,200.0" {
+		t.Errorf("Expected credential 2 row, got %q", lines[3])
+	}
+	if lines[4] != "total,4,1,0.2500,100,250.0" {
+		t.Errorf("Expected pool total row, got %q", lines[4])
+	}
+}
+
+func TestParseUsageTime(t *testing.T) {
+	fallback := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, _ := parseUsageTime("", fallback); !got.Equal(fallback) {
+		t.Errorf("Expected fallback for empty value, got %v", got)
+	}
+	if got, err := parseUsageTime("2026-10-01", fallback); err != nil || got.Day() != 1 || got.Month() != 10 {
+		t.Errorf("Expected date parsed, got %v (%v)", got, err)
+	}
+	if got, err := parseUsageTime("2026-10-01T12:00:00Z", fallback); err != nil || got.Hour() != 12 {
+		t.Errorf("Expected RFC3339 parsed, got %v (%v)", got, err)
+	}
+	if _, err := parseUsageTime("yesterday", fallback); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,40 @@
+package accountpool
+
+import "math/rand"
+
+// WeightedPool 配置引用的账号池及其流量权重，权重为 0 的账号池只在其他账号池都不可用时使用
+type WeightedPool struct {
+	PoolID uint
+	Weight int
+}
+
+// orderPools 按权重随机排列账号池的尝试顺序：权重越高越可能排在前面，权重为 0 的按原顺序排在最后
+func orderPools(pools []WeightedPool) []uint {
+	weighted := make([]WeightedPool, 0, len(pools))
+	var backups []uint
+	for _, pool := range pools {
+		if pool.Weight > 0 {
+			weighted = append(weighted, pool)
+		} else {
+			backups = append(backups, pool.PoolID)
+		}
+	}
+
+	order := make([]uint, 0, len(pools))
+	for len(weighted) > 0 {
+		total := 0
+		for _, pool := range weighted {
+			total += pool.Weight
+		}
+		random := rand.Intn(total)
+		for i, pool := range weighted {
+			random -= pool.Weight
+			if random < 0 {
+				order = append(order, pool.PoolID)
+				weighted = append(weighted[:i], weighted[i+1:]...)
+				break
+			}
+		}
+	}
+	return append(order, backups...)
+}
@@ -0,0 +1,102 @@
+package accountpool
+
+import (
+	"context"
+	"testing"
+)
+
+// multiPoolRepo 多个账号池，凭据按所属账号池和健康状态筛选
+type multiPoolRepo struct {
+	*fakeRepo
+	pools map[uint]*AccountPool
+}
+
+func newMultiPoolRepo(creds ...*AccountCredential) *multiPoolRepo {
+	r := &multiPoolRepo{fakeRepo: newFakeRepo(creds...), pools: make(map[uint]*AccountPool)}
+	for _, cred := range creds {
+		r.pools[cred.PoolID] = &AccountPool{ID: cred.PoolID, IsActive: true, Strategy: StrategyRoundRobin}
+	}
+	return r
+}
+
+func (r *multiPoolRepo) FindByID(ctx context.Context, id uint) (*AccountPool, error) {
+	return r.pools[id], nil
+}
+
+func (r *multiPoolRepo) FindActiveCredentialsByPoolID(ctx context.Context, poolID uint) ([]*AccountCredential, error) {
+	var creds []*AccountCredential
+	for _, id := range r.order {
+		if cred := r.creds[id]; cred.PoolID == poolID && cred.IsActive && cred.HealthStatus != HealthStatusUnhealthy {
+			creds = append(creds, cred)
+		}
+	}
+	return creds, nil
+}
+
+func pooledCredential(id, poolID uint) *AccountCredential {
+	cred := openAICredential(id)
+	cred.PoolID = poolID
+	return cred
+}
+
+func TestPoolManager_GetAdapterRespectsPoolWeights(t *testing.T) {
+	repo := newMultiPoolRepo(pooledCredential(1, 1), pooledCredential(2, 2))
+	pm := NewPoolManager(repo, nil, nil)
+	pools := []WeightedPool{{PoolID: 1, Weight: 80}, {PoolID: 2, Weight: 20}}
+
+	counts := map[uint]int{}
+	for i := 0; i < 2000; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), pools, "gpt-4")
+		if err != nil {
+			t.Fatalf("GetAdapter: %v", err)
+		}
+		counts[credID]++
+	}
+	// 80/20 的权重，允许一定的随机偏差
+	if counts[1] < 1450 || counts[1] > 1750 {
+		t.Errorf("Expected about 80%% of selections from the primary pool, got %d of 2000 (backup %d)", counts[1], counts[2])
+	}
+}
+
+func TestPoolManager_GetAdapterFailsOverToBackupPool(t *testing.T) {
+	primary := pooledCredential(1, 1)
+	primary.HealthStatus = HealthStatusUnhealthy
+	repo := newMultiPoolRepo(primary, pooledCredential(2, 2))
+	pm := NewPoolManager(repo, nil, nil)
+
+	// 备用账号池权重为 0，主账号池没有健康凭据时才使用
+	pools := []WeightedPool{{PoolID: 1, Weight: 100}, {PoolID: 2, Weight: 0}}
+	for i := 0; i < 5; i++ {
+		_, credID, err := pm.GetAdapter(context.Background(), pools, "gpt-4")
+		if err != nil || credID != 2 {
+			t.Fatalf("Expected the backup pool's credential, got %d, %v", credID, err)
+		}
+	}
+
+	primary.HealthStatus = HealthStatusHealthy
+	if _, credID, err := pm.GetAdapter(context.Background(), pools, "gpt-4"); err != nil || credID != 1 {
+		t.Errorf("Expected the recovered primary pool used again, got %d, %v", credID, err)
+	}
+}
+
+func TestPoolManager_GetAdapterFailsWhenAllPoolsDepleted(t *testing.T) {
+	primary, backup := pooledCredential(1, 1), pooledCredential(2, 2)
+	primary.HealthStatus, backup.HealthStatus = HealthStatusUnhealthy, HealthStatusUnhealthy
+	pm := NewPoolManager(newMultiPoolRepo(primary, backup), nil, nil)
+
+	if _, _, err := pm.GetAdapter(context.Background(), []WeightedPool{{PoolID: 1, Weight: 1}, {PoolID: 2, Weight: 1}}, "gpt-4"); err == nil {
+		t.Error("Expected an error when no pool has a healthy credential")
+	}
+	if _, _, err := pm.GetAdapter(context.Background(), nil, "gpt-4"); err == nil {
+		t.Error("Expected an error when no pool is configured")
+	}
+}
+
+func TestOrderPools_ZeroWeightPoolsLast(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		order := orderPools([]WeightedPool{{PoolID: 3, Weight: 0}, {PoolID: 1, Weight: 5}, {PoolID: 2, Weight: 5}})
+		if len(order) != 3 || order[2] != 3 {
+			t.Fatalf("Expected the zero-weight pool tried last, got %v", order)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package anomaly
+
+import (
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// AlertTypeUsageAnomaly 用量异常告警
+const AlertTypeUsageAnomaly = "usage_anomaly"
+
+// detectInterval 检测间隔，检测窗口为截至检测时刻的滑动窗口
+const detectInterval = 5 * time.Minute
+
+// AlertSender 发送告警事件
+type AlertSender interface {
+	Send(ctx context.Context, url string, event *alert.Event) error
+}
+
+// subject 被检测的对象：API Key 或用户（用户级 apiKeyID 为 0）
+type subject struct {
+	scope    string
+	apiKeyID uint
+	userID   uint
+}
+
+// Detector 定期比较每个 API Key 和用户最近一个窗口的用量与其基线，超过倍率时标记
+// 基线为前 BaselineWindows 个窗口的平均值，每次检测都从 request_logs 和用量分桶重新计算
+type Detector struct {
+	repo          Repository
+	runtimeConfig *runtime.Manager
+	notifier      AlertSender
+	logger        logger.Logger
+}
+
+// NewDetector 创建用量异常检测器
+func NewDetector(repo Repository, runtimeConfig *runtime.Manager, notifier AlertSender, logger logger.Logger) *Detector {
+	return &Detector{
+		repo:          repo,
+		runtimeConfig: runtimeConfig,
+		notifier:      notifier,
+		logger:        logger,
+	}
+}
+
+// Start 按间隔运行检测，直到 ctx 取消；策略未启用时跳过
+func (d *Detector) Start(ctx context.Context) {
+	ticker := time.NewTicker(detectInterval)
+	defer ticker.Stop()
+
+	d.logger.Info("Usage anomaly detector started", logger.Duration("interval", detectInterval))
+
+	for {
+		select {
+		case <-ticker.C:
+			d.RunOnce(ctx, time.Now())
+		case <-ctx.Done():
+			d.logger.Info("Usage anomaly detector stopped")
+			return
+		}
+	}
+}
+
+// RunOnce 检测截至 now 的窗口，返回本轮新建的标记
+func (d *Detector) RunOnce(ctx context.Context, now time.Time) []*Flag {
+	policy := d.runtimeConfig.Get().GetAnomalyPolicy()
+	if !policy.Enabled || policy.Window <= 0 || policy.SpikeRatio <= 0 {
+		return nil
+	}
+	baselineWindows := policy.BaselineWindows
+	if baselineWindows < 1 {
+		baselineWindows = 1
+	}
+
+	windowStart := now.Add(-policy.Window)
+	current, err := d.repo.UsageByKey(ctx, windowStart, now)
+	if err != nil {
+		d.logger.Error("Failed to load usage for anomaly detection", logger.Error(err))
+		return nil
+	}
+	if len(current) == 0 {
+		return nil
+	}
+	baseline, err := d.repo.UsageByKey(ctx, windowStart.Add(-policy.Window*time.Duration(baselineWindows)), windowStart)
+	if err != nil {
+		d.logger.Error("Failed to load baseline usage for anomaly detection", logger.Error(err))
+		return nil
+	}
+
+	baselineUsage := aggregateUsage(baseline)
+	var flags []*Flag
+	for subj, usage := range aggregateUsage(current) {
+		base := baselineUsage[subj]
+		if base == nil {
+			base = &KeyUsage{}
+		}
+		checks := []struct {
+			metric   string
+			observed int64
+			baseline int64
+			min      int64
+		}{
+			{MetricRequests, usage.Requests, base.Requests, policy.MinRequests},
+			{MetricSpend, usage.Spend, base.Spend, policy.MinSpend},
+		}
+		for _, check := range checks {
+			if check.observed == 0 || check.observed < check.min {
+				continue
+			}
+			avg := float64(check.baseline) / float64(baselineWindows)
+			// 没有基线的对象（新建或长期未用的密钥）按每窗口 1 计算，由最小值门槛避免误报
+			ratio := float64(check.observed) / math.Max(avg, 1)
+			if ratio < policy.SpikeRatio {
+				continue
+			}
+			flag := &Flag{
+				Scope:       subj.scope,
+				APIKeyID:    subj.apiKeyID,
+				UserID:      subj.userID,
+				Metric:      check.metric,
+				Observed:    check.observed,
+				Baseline:    avg,
+				Ratio:       ratio,
+				WindowStart: windowStart,
+				WindowEnd:   now,
+			}
+			if d.raise(ctx, flag, policy.AutoSuspend) {
+				flags = append(flags, flag)
+			}
+		}
+	}
+	return flags
+}
+
+// aggregateUsage 汇总每个 API Key 和每个用户的用量；日志已匿名化（用户为 0）的只计入 Key
+func aggregateUsage(rows []KeyUsage) map[subject]*KeyUsage {
+	result := make(map[subject]*KeyUsage)
+	add := func(subj subject, row KeyUsage) {
+		u, ok := result[subj]
+		if !ok {
+			u = &KeyUsage{APIKeyID: subj.apiKeyID, UserID: subj.userID}
+			result[subj] = u
+		}
+		u.Requests += row.Requests
+		u.Spend += row.Spend
+	}
+	for _, row := range rows {
+		add(subject{scope: ScopeAPIKey, apiKeyID: row.APIKeyID, userID: row.UserID}, row)
+		if row.UserID != 0 {
+			add(subject{scope: ScopeUser, userID: row.UserID}, row)
+		}
+	}
+	return result
+}
+
+// raise 保存标记、按策略停用 API Key 并发送告警；已有未清除的同类标记时不重复标记
+func (d *Detector) raise(ctx context.Context, flag *Flag, autoSuspend bool) bool {
+	existing, err := d.repo.FindOpenFlag(ctx, flag.Scope, flag.APIKeyID, flag.UserID, flag.Metric)
+	if err != nil {
+		d.logger.Error("Failed to check existing anomaly flag", logger.Error(err))
+		return false
+	}
+	if existing != nil {
+		return false
+	}
+
+	if autoSuspend && flag.Scope == ScopeAPIKey {
+		if err := d.repo.SetAPIKeySuspended(ctx, flag.APIKeyID, true); err != nil {
+			d.logger.Error("Failed to suspend API key after usage anomaly",
+				logger.Uint("key_id", flag.APIKeyID),
+				logger.Error(err))
+		} else {
+			flag.Suspended = true
+		}
+	}
+
+	if err := d.repo.CreateFlag(ctx, flag); err != nil {
+		d.logger.Error("Failed to save anomaly flag", logger.Error(err))
+		return false
+	}
+
+	d.logger.Warn("Usage anomaly detected",
+		logger.String("scope", flag.Scope),
+		logger.Uint("key_id", flag.APIKeyID),
+		logger.Uint("user_id", flag.UserID),
+		logger.String("metric", flag.Metric),
+		logger.Int64("observed", flag.Observed),
+		logger.Float64("baseline", flag.Baseline),
+		logger.Bool("suspended", flag.Suspended))
+
+	d.notify(ctx, flag)
+	return true
+}
+
+// notify 配置了告警 Webhook 时发送告警
+func (d *Detector) notify(ctx context.Context, flag *Flag) {
+	url := d.runtimeConfig.Get().GetAlertWebhookURL()
+	if url == "" || d.notifier == nil {
+		return
+	}
+
+	event := &alert.Event{
+		Type: AlertTypeUsageAnomaly,
+		Message: fmt.Sprintf("%s usage of %s %d is %.1fx its baseline",
+			flag.Metric, flag.Scope, subjectID(flag), flag.Ratio),
+		Data: map[string]interface{}{
+			"flag_id":      flag.ID,
+			"scope":        flag.Scope,
+			"api_key_id":   flag.APIKeyID,
+			"user_id":      flag.UserID,
+			"metric":       flag.Metric,
+			"observed":     flag.Observed,
+			"baseline":     flag.Baseline,
+			"ratio":        flag.Ratio,
+			"window_start": flag.WindowStart,
+			"window_end":   flag.WindowEnd,
+			"suspended":    flag.Suspended,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := d.notifier.Send(ctx, url, event); err != nil {
+		d.logger.Warn("Failed to send usage anomaly alert", logger.Error(err))
+	}
+}
+
+func subjectID(flag *Flag) uint {
+	if flag.Scope == ScopeAPIKey {
+		return flag.APIKeyID
+	}
+	return flag.UserID
+}
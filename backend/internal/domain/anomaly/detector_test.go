@@ -0,0 +1,186 @@
+package anomaly
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+)
+
+// fakeRepo 按查询区间返回当前窗口或基线用量
+type fakeRepo struct {
+	Repository
+	windowStart time.Time
+	current     []KeyUsage
+	baseline    []KeyUsage
+	flags       []*Flag
+	suspended   map[uint]bool
+}
+
+func (r *fakeRepo) UsageByKey(ctx context.Context, start, end time.Time) ([]KeyUsage, error) {
+	if start.Equal(r.windowStart) {
+		return r.current, nil
+	}
+	return r.baseline, nil
+}
+
+func (r *fakeRepo) FindOpenFlag(ctx context.Context, scope string, apiKeyID, userID uint, metric string) (*Flag, error) {
+	for _, f := range r.flags {
+		if f.IsOpen() && f.Scope == scope && f.APIKeyID == apiKeyID && f.UserID == userID && f.Metric == metric {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) CreateFlag(ctx context.Context, flag *Flag) error {
+	flag.ID = uint(len(r.flags) + 1)
+	r.flags = append(r.flags, flag)
+	return nil
+}
+
+func (r *fakeRepo) FindFlagByID(ctx context.Context, id uint) (*Flag, error) {
+	for _, f := range r.flags {
+		if f.ID == id {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeRepo) UpdateFlag(ctx context.Context, flag *Flag) error {
+	return nil
+}
+
+func (r *fakeRepo) SetAPIKeySuspended(ctx context.Context, apiKeyID uint, suspended bool) error {
+	r.suspended[apiKeyID] = suspended
+	return nil
+}
+
+type fakeNotifier struct {
+	events []*alert.Event
+}
+
+func (n *fakeNotifier) Send(ctx context.Context, url string, event *alert.Event) error {
+	n.events = append(n.events, event)
+	return nil
+}
+
+var testNow = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestDetector(policy runtime.AnomalyPolicy, current, baseline []KeyUsage) (*Detector, *fakeRepo, *fakeNotifier) {
+	rc := runtime.NewManager(nil)
+	rc.Get().Anomaly = policy
+	rc.Get().AlertWebhookURL = "http://alerts.local/hook"
+	repo := &fakeRepo{
+		windowStart: testNow.Add(-policy.Window),
+		current:     current,
+		baseline:    baseline,
+		suspended:   map[uint]bool{},
+	}
+	notifier := &fakeNotifier{}
+	return NewDetector(repo, rc, notifier, *logger.NewNop()), repo, notifier
+}
+
+func testPolicy(autoSuspend bool) runtime.AnomalyPolicy {
+	return runtime.AnomalyPolicy{
+		Enabled:         true,
+		Window:          time.Hour,
+		BaselineWindows: 24,
+		SpikeRatio:      5,
+		MinRequests:     100,
+		MinSpend:        1 << 40, // 只检测请求数
+		AutoSuspend:     autoSuspend,
+	}
+}
+
+func TestDetector_FlagsSpikeAboveBaseline(t *testing.T) {
+	// 基线每小时 20 次（24 小时共 480 次），当前窗口 500 次
+	current := []KeyUsage{{APIKeyID: 7, UserID: 3, Requests: 500, Spend: 5000}}
+	baseline := []KeyUsage{{APIKeyID: 7, UserID: 3, Requests: 480, Spend: 4800}}
+	d, repo, notifier := newTestDetector(testPolicy(false), current, baseline)
+
+	flags := d.RunOnce(context.Background(), testNow)
+	if len(flags) != 2 {
+		t.Fatalf("Expected key and user flags, got %+v", flags)
+	}
+	for _, f := range flags {
+		if f.Metric != MetricRequests || f.Observed != 500 || f.Baseline != 20 || f.Ratio != 25 {
+			t.Errorf("Unexpected flag: %+v", f)
+		}
+		if f.Suspended {
+			t.Errorf("Expected no suspension without auto_suspend, got %+v", f)
+		}
+	}
+	if len(repo.suspended) != 0 {
+		t.Errorf("Expected no key state change, got %v", repo.suspended)
+	}
+	if len(notifier.events) != 2 || notifier.events[0].Type != AlertTypeUsageAnomaly {
+		t.Errorf("Expected a webhook per flag, got %+v", notifier.events)
+	}
+
+	// 未清除前不重复标记
+	if again := d.RunOnce(context.Background(), testNow); len(again) != 0 {
+		t.Errorf("Expected open flags to suppress duplicates, got %+v", again)
+	}
+}
+
+func TestDetector_AutoSuspendsFlaggedKey(t *testing.T) {
+	current := []KeyUsage{{APIKeyID: 7, UserID: 3, Requests: 500}}
+	d, repo, _ := newTestDetector(testPolicy(true), current, nil)
+
+	flags := d.RunOnce(context.Background(), testNow)
+	var keyFlag *Flag
+	for _, f := range flags {
+		if f.Scope == ScopeAPIKey {
+			keyFlag = f
+		} else if f.Suspended {
+			t.Errorf("Expected user-level flags not to suspend anything, got %+v", f)
+		}
+	}
+	if keyFlag == nil || !keyFlag.Suspended {
+		t.Fatalf("Expected a suspended key flag, got %+v", flags)
+	}
+	if !repo.suspended[7] {
+		t.Errorf("Expected key 7 suspended, got %v", repo.suspended)
+	}
+
+	// 清除时可以重新启用密钥
+	svc := NewService(repo, *logger.NewNop())
+	cleared, err := svc.ClearFlag(context.Background(), keyFlag.ID, 1, &ClearFlagRequest{ReactivateKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleared.IsOpen() || repo.suspended[7] {
+		t.Errorf("Expected flag cleared and key reactivated, got %+v / %v", cleared, repo.suspended)
+	}
+}
+
+func TestDetector_IgnoresUsageWithinBaselineOrBelowMinimum(t *testing.T) {
+	current := []KeyUsage{
+		{APIKeyID: 1, UserID: 1, Requests: 300}, // 基线每小时 100 次，3 倍不超过阈值
+		{APIKeyID: 2, UserID: 2, Requests: 50},  // 低于最小请求数
+	}
+	baseline := []KeyUsage{{APIKeyID: 1, UserID: 1, Requests: 2400}}
+	d, repo, _ := newTestDetector(testPolicy(true), current, baseline)
+
+	if flags := d.RunOnce(context.Background(), testNow); len(flags) != 0 {
+		t.Errorf("Expected no flags, got %+v", flags)
+	}
+	if len(repo.suspended) != 0 {
+		t.Errorf("Expected no suspensions, got %v", repo.suspended)
+	}
+}
+
+func TestDetector_DisabledPolicyDoesNothing(t *testing.T) {
+	policy := testPolicy(true)
+	policy.Enabled = false
+	d, _, _ := newTestDetector(policy, []KeyUsage{{APIKeyID: 7, UserID: 3, Requests: 5000}}, nil)
+
+	if flags := d.RunOnce(context.Background(), testNow); len(flags) != 0 {
+		t.Errorf("Expected detection disabled, got %+v", flags)
+	}
+}
@@ -0,0 +1,30 @@
+package anomaly
+
+// 标记查询状态
+const (
+	FlagStatusOpen    = "open"
+	FlagStatusCleared = "cleared"
+	FlagStatusAll     = "all"
+)
+
+// ListFlagsRequest 查询异常标记请求
+type ListFlagsRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=open cleared all"` // 默认 open
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// ListFlagsResponse 异常标记列表
+type ListFlagsResponse struct {
+	Flags    []*Flag `json:"flags"`
+	Total    int64   `json:"total"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"page_size"`
+}
+
+// ClearFlagRequest 清除异常标记请求
+type ClearFlagRequest struct {
+	// 解除自动停用；不解除时密钥保持停用，用户无法自行启用
+	ReactivateKey bool   `json:"reactivate_key"`
+	Note          string `json:"note" binding:"omitempty,max=1000"`
+}
@@ -0,0 +1,95 @@
+package anomaly
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 用量异常处理器
+type Handler struct {
+	service Service
+}
+
+// NewHandler 创建用量异常处理器
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ListFlags 查询异常标记
+// @Summary 查询用量异常标记
+// @Description 查询请求数或花费突增的 API Key 和用户（管理员）
+// @Tags Anomaly
+// @Produce json
+// @Param status query string false "状态过滤：open（默认）、cleared、all"
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Success 200 {object} response.Response{data=ListFlagsResponse}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/anomalies [get]
+func (h *Handler) ListFlags(c *gin.Context) {
+	var req ListFlagsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	resp, err := h.service.ListFlags(c.Request.Context(), &req)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// ClearFlag 清除异常标记
+// @Summary 清除用量异常标记
+// @Description 确认异常已处理，可同时重新启用被自动停用的 API Key（管理员）
+// @Tags Anomaly
+// @Accept json
+// @Produce json
+// @Param id path int true "标记ID"
+// @Param request body ClearFlagRequest false "清除选项"
+// @Success 200 {object} response.Response{data=Flag}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/anomalies/{id}/clear [post]
+func (h *Handler) ClearFlag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid flag ID", "Flag ID must be a valid number")
+		return
+	}
+
+	var req ClearFlagRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.BadRequest(c, "Invalid request parameters", err.Error())
+			return
+		}
+	}
+
+	var operatorID uint
+	if v, exists := c.Get("user_id"); exists {
+		operatorID, _ = v.(uint)
+	}
+
+	flag, err := h.service.ClearFlag(c.Request.Context(), uint(id), operatorID, &req)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			response.NotFound(c, "Anomaly flag not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, flag)
+}
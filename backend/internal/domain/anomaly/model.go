@@ -0,0 +1,60 @@
+package anomaly
+
+import (
+	"time"
+)
+
+// 标记对象
+const (
+	ScopeAPIKey = "api_key"
+	ScopeUser   = "user"
+)
+
+// 触发标记的指标
+const (
+	MetricRequests = "requests" // 窗口内请求数
+	MetricSpend    = "spend"    // 窗口内花费的配额
+)
+
+// Flag 用量异常标记，清除前同一对象、同一指标不重复标记
+type Flag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Scope    string `gorm:"not null;size:20" json:"scope"`
+	APIKeyID uint   `gorm:"not null;default:0" json:"api_key_id"` // 用户级标记为 0
+	UserID   uint   `gorm:"not null;default:0" json:"user_id"`
+	Metric   string `gorm:"not null;size:20" json:"metric"`
+
+	Observed    int64     `gorm:"not null" json:"observed"` // 检测窗口内的值
+	Baseline    float64   `gorm:"not null" json:"baseline"` // 基线窗口的平均值
+	Ratio       float64   `gorm:"not null" json:"ratio"`
+	WindowStart time.Time `gorm:"not null" json:"window_start"`
+	WindowEnd   time.Time `gorm:"not null" json:"window_end"`
+
+	// 标记时是否自动停用了 API Key
+	Suspended bool `gorm:"not null;default:false" json:"suspended"`
+
+	ClearedAt *time.Time `json:"cleared_at,omitempty"`
+	ClearedBy *uint      `json:"cleared_by,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
+// TableName 指定表名
+func (Flag) TableName() string {
+	return "anomaly_flags"
+}
+
+// IsOpen 是否未清除
+func (f *Flag) IsOpen() bool {
+	return f.ClearedAt == nil
+}
+
+// KeyUsage 一个 API Key 在时间段内的用量
+type KeyUsage struct {
+	APIKeyID uint  `json:"api_key_id"`
+	UserID   uint  `json:"user_id"`
+	Requests int64 `json:"requests"`
+	Spend    int64 `json:"spend"`
+}
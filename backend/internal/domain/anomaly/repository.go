@@ -0,0 +1,120 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository 用量异常仓储接口
+type Repository interface {
+	UsageByKey(ctx context.Context, start, end time.Time) ([]KeyUsage, error)
+	FindOpenFlag(ctx context.Context, scope string, apiKeyID, userID uint, metric string) (*Flag, error)
+	CreateFlag(ctx context.Context, flag *Flag) error
+	FindFlagByID(ctx context.Context, id uint) (*Flag, error)
+	UpdateFlag(ctx context.Context, flag *Flag) error
+	ListFlags(ctx context.Context, status string, page, pageSize int) ([]*Flag, int64, error)
+	SetAPIKeySuspended(ctx context.Context, apiKeyID uint, suspended bool) error
+}
+
+// repository 用量异常仓储实现
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建用量异常仓储
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// UsageByKey 按 API Key 汇总 [start, end) 内的请求数和花费
+// 记录日志的请求来自 request_logs，关闭了请求日志的请求来自 usage_buckets 的 5 分钟分桶
+func (r *repository) UsageByKey(ctx context.Context, start, end time.Time) ([]KeyUsage, error) {
+	var results []KeyUsage
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT api_key_id, user_id, SUM(requests) AS requests, SUM(spend) AS spend FROM (
+			SELECT api_key_id, COALESCE(user_id, 0) AS user_id, COUNT(*) AS requests, COALESCE(SUM(quota_cost), 0) AS spend
+			FROM request_logs
+			WHERE created_at >= ? AND created_at < ? AND api_key_id <> 0
+			GROUP BY api_key_id, user_id
+			UNION ALL
+			SELECT api_key_id, user_id, SUM(requests), SUM(quota_cost)
+			FROM usage_buckets
+			WHERE bucket_start >= ? AND bucket_start < ?
+			GROUP BY api_key_id, user_id
+		) usage
+		GROUP BY api_key_id, user_id`, start, end, start, end).
+		Scan(&results).Error
+	return results, err
+}
+
+// FindOpenFlag 查找对象在指定指标上未清除的标记
+func (r *repository) FindOpenFlag(ctx context.Context, scope string, apiKeyID, userID uint, metric string) (*Flag, error) {
+	var flag Flag
+	err := r.db.WithContext(ctx).
+		Where("scope = ? AND api_key_id = ? AND user_id = ? AND metric = ? AND cleared_at IS NULL", scope, apiKeyID, userID, metric).
+		First(&flag).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// CreateFlag 创建标记
+func (r *repository) CreateFlag(ctx context.Context, flag *Flag) error {
+	return r.db.WithContext(ctx).Create(flag).Error
+}
+
+// FindFlagByID 根据ID查找标记
+func (r *repository) FindFlagByID(ctx context.Context, id uint) (*Flag, error) {
+	var flag Flag
+	err := r.db.WithContext(ctx).First(&flag, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// UpdateFlag 更新标记
+func (r *repository) UpdateFlag(ctx context.Context, flag *Flag) error {
+	return r.db.WithContext(ctx).Save(flag).Error
+}
+
+// ListFlags 分页查询标记，status 为 open、cleared 或空（全部）
+func (r *repository) ListFlags(ctx context.Context, status string, page, pageSize int) ([]*Flag, int64, error) {
+	db := r.db.WithContext(ctx).Model(&Flag{})
+	switch status {
+	case FlagStatusOpen:
+		db = db.Where("cleared_at IS NULL")
+	case FlagStatusCleared:
+		db = db.Where("cleared_at IS NOT NULL")
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var flags []*Flag
+	err := db.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&flags).Error
+	return flags, total, err
+}
+
+// SetAPIKeySuspended 停用或解除停用 API Key，与用户可修改的 is_active 分开保存
+func (r *repository) SetAPIKeySuspended(ctx context.Context, apiKeyID uint, suspended bool) error {
+	return r.db.WithContext(ctx).
+		Table("api_keys").
+		Where("id = ?", apiKeyID).
+		Updates(map[string]interface{}{"suspended": suspended, "updated_at": time.Now()}).Error
+}
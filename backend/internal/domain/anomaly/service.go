@@ -0,0 +1,93 @@
+package anomaly
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"time"
+)
+
+// errFlagNotFound 标记不存在
+var errFlagNotFound = errors.ErrNotFound.WithDetails("anomaly flag not found")
+
+// Service 用量异常服务接口
+type Service interface {
+	ListFlags(ctx context.Context, req *ListFlagsRequest) (*ListFlagsResponse, error)
+	ClearFlag(ctx context.Context, id, operatorID uint, req *ClearFlagRequest) (*Flag, error)
+}
+
+// service 用量异常服务实现
+type service struct {
+	repo   Repository
+	logger logger.Logger
+}
+
+// NewService 创建用量异常服务
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// ListFlags 分页查询异常标记，默认只返回未清除的
+func (s *service) ListFlags(ctx context.Context, req *ListFlagsRequest) (*ListFlagsResponse, error) {
+	if req.Status == "" {
+		req.Status = FlagStatusOpen
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+
+	flags, total, err := s.repo.ListFlags(ctx, req.Status, req.Page, req.PageSize)
+	if err != nil {
+		s.logger.Error("Failed to list anomaly flags", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to list anomaly flags")
+	}
+	return &ListFlagsResponse{
+		Flags:    flags,
+		Total:    total,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+	}, nil
+}
+
+// ClearFlag 清除标记，可同时重新启用被自动停用的 API Key；已清除的标记原样返回
+func (s *service) ClearFlag(ctx context.Context, id, operatorID uint, req *ClearFlagRequest) (*Flag, error) {
+	flag, err := s.repo.FindFlagByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get anomaly flag", logger.Uint("flag_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get anomaly flag")
+	}
+	if flag == nil {
+		return nil, errFlagNotFound
+	}
+	if !flag.IsOpen() {
+		return flag, nil
+	}
+
+	if req.ReactivateKey && flag.Suspended {
+		if err := s.repo.SetAPIKeySuspended(ctx, flag.APIKeyID, false); err != nil {
+			s.logger.Error("Failed to reactivate API key", logger.Uint("key_id", flag.APIKeyID), logger.Error(err))
+			return nil, errors.Wrap(err, 500002, "Failed to reactivate API key")
+		}
+	}
+
+	now := time.Now()
+	flag.ClearedAt = &now
+	flag.ClearedBy = &operatorID
+	flag.Note = req.Note
+	if err := s.repo.UpdateFlag(ctx, flag); err != nil {
+		s.logger.Error("Failed to clear anomaly flag", logger.Uint("flag_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to clear anomaly flag")
+	}
+
+	s.logger.Info("Anomaly flag cleared",
+		logger.Uint("flag_id", id),
+		logger.Uint("operator_id", operatorID),
+		logger.Bool("key_reactivated", req.ReactivateKey && flag.Suspended))
+	return flag, nil
+}
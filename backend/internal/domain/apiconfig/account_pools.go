@@ -0,0 +1,45 @@
+package apiconfig
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// PoolWeight 配置引用的一个账号池及其流量权重
+type PoolWeight struct {
+	PoolID uint `json:"pool_id" binding:"required"`
+	Weight int  `json:"weight" binding:"min=0,max=100"` // 0 表示只在其他账号池都不可用时使用
+}
+
+// PoolWeights 配置引用的多个账号池（存储为 JSON），按权重分配流量，某个账号池不可用时换用其余账号池
+type PoolWeights []PoolWeight
+
+func (p PoolWeights) Value() (driver.Value, error) {
+	if p == nil {
+		return json.Marshal([]PoolWeight{})
+	}
+	return json.Marshal(p)
+}
+
+func (p *PoolWeights) Scan(value interface{}) error {
+	if value == nil {
+		*p = PoolWeights{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// PoolWeights 返回配置使用的账号池：设置了多个账号池时按权重使用，否则只使用 AccountPoolID
+func (c *APIConfig) PoolWeights() PoolWeights {
+	if len(c.AccountPools) > 0 {
+		return c.AccountPools
+	}
+	if c.AccountPoolID != nil && *c.AccountPoolID != 0 {
+		return PoolWeights{{PoolID: *c.AccountPoolID, Weight: 1}}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"time"
+)
+
+// CanaryShare 返回灰度配置当前应承接的流量百分比
+// 设置了爬坡时长时，从 CanaryPercent 开始随时间线性增长到 100；
+// 未处于灰度（未设置或已爬满）时返回 false，按正常权重参与选择
+func (c *APIConfig) CanaryShare(now time.Time) (float64, bool) {
+	if c.CanaryPercent <= 0 || c.CanaryPercent >= 100 {
+		return 0, false
+	}
+
+	share := float64(c.CanaryPercent)
+	if c.CanaryRampMinutes > 0 && c.CanaryStartedAt != nil {
+		elapsed := now.Sub(*c.CanaryStartedAt)
+		ramp := time.Duration(c.CanaryRampMinutes) * time.Minute
+		if elapsed >= ramp {
+			return 0, false
+		}
+		if elapsed > 0 {
+			share += (100 - share) * float64(elapsed) / float64(ramp)
+		}
+	}
+	return share, true
+}
+
+// setCanary 设置灰度比例，比例变化时重新开始爬坡计时
+func (c *APIConfig) setCanary(percent int, now time.Time) {
+	if percent == c.CanaryPercent && c.CanaryStartedAt != nil {
+		return
+	}
+	c.CanaryPercent = percent
+	c.CanaryStartedAt = nil
+	if percent > 0 && percent < 100 {
+		c.CanaryStartedAt = &now
+	}
+}
+
+// PromoteCanary 结束灰度，配置按正常权重承接流量
+func (s *service) PromoteCanary(ctx context.Context, id uint) (*ConfigResponse, error) {
+	config, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get config", logger.Uint("config_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get config")
+	}
+	if config == nil {
+		return nil, errors.ErrAPIConfigNotFound
+	}
+	if config.ReadOnly {
+		return nil, readOnlyError(config)
+	}
+
+	config.CanaryPercent = 0
+	config.CanaryRampMinutes = 0
+	config.CanaryStartedAt = nil
+
+	if err := s.repo.Update(ctx, config); err != nil {
+		s.logger.Error("Failed to promote canary config",
+			logger.Uint("config_id", id),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to update config")
+	}
+
+	s.logger.Info("Canary config promoted",
+		logger.Uint("config_id", id),
+		logger.String("name", config.Name))
+
+	return config.ToResponse(), nil
+}
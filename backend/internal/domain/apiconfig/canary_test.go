@@ -0,0 +1,59 @@
+package apiconfig
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCanaryShare_RampsToFullTraffic(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := &APIConfig{CanaryPercent: 20, CanaryRampMinutes: 100, CanaryStartedAt: &started}
+
+	cases := []struct {
+		elapsed time.Duration
+		share   float64
+		canary  bool
+	}{
+		{0, 20, true},
+		{25 * time.Minute, 40, true},
+		{100 * time.Minute, 0, false},
+	}
+	for _, tc := range cases {
+		share, ok := c.CanaryShare(started.Add(tc.elapsed))
+		if ok != tc.canary || math.Abs(share-tc.share) > 1e-9 {
+			t.Errorf("After %v expected (%v, %v), got (%v, %v)", tc.elapsed, tc.share, tc.canary, share, ok)
+		}
+	}
+
+	if _, ok := (&APIConfig{CanaryPercent: 100}).CanaryShare(started); ok {
+		t.Error("Expected a 100% canary to be treated as promoted")
+	}
+}
+
+func TestPromoteCanary_ClearsCanarySettings(t *testing.T) {
+	svc, repo := newCapabilityTestService(nil)
+	resp, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "new", Type: "openai", BaseURL: "https://example.com", Models: []string{"gpt-4"},
+		CanaryPercent: 5, CanaryRampMinutes: 120,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg := repo.configs[resp.ID]; cfg.CanaryPercent != 5 || cfg.CanaryStartedAt == nil {
+		t.Fatalf("Expected the canary to start on creation, got %+v", cfg)
+	}
+
+	resp, err = svc.PromoteCanary(context.Background(), resp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := repo.configs[resp.ID]
+	if cfg.CanaryPercent != 0 || cfg.CanaryRampMinutes != 0 || cfg.CanaryStartedAt != nil {
+		t.Errorf("Expected promotion to clear the canary, got %+v", cfg)
+	}
+	if _, ok := cfg.CanaryShare(time.Now()); ok {
+		t.Error("Expected the promoted config to take its full weight")
+	}
+}
@@ -0,0 +1,160 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// capabilityProbeTimeout 探测上游能力的超时时间，避免上游无响应拖住管理接口
+const capabilityProbeTimeout = 10 * time.Second
+
+// 可在 required_capabilities 中声明的能力
+const (
+	CapabilityStreaming = "streaming"
+	CapabilityTools     = "tools"
+	CapabilityVision    = "vision"
+	CapabilityJSONMode  = "json_mode"
+)
+
+// ProviderCapabilities 创建或测试配置时探测到的上游能力（存储为 JSON）
+type ProviderCapabilities struct {
+	Streaming bool      `json:"streaming"`
+	Tools     bool      `json:"tools"`
+	Vision    bool      `json:"vision"`
+	JSONMode  bool      `json:"json_mode"`
+	Reachable bool      `json:"reachable"`       // 探测请求是否成功
+	Error     string    `json:"error,omitempty"` // 探测失败原因
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func (p ProviderCapabilities) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *ProviderCapabilities) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Supports 判断是否具备指定能力，未知能力视为不支持
+func (p *ProviderCapabilities) Supports(capability string) bool {
+	switch capability {
+	case CapabilityStreaming:
+		return p.Streaming
+	case CapabilityTools:
+		return p.Tools
+	case CapabilityVision:
+		return p.Vision
+	case CapabilityJSONMode:
+		return p.JSONMode
+	default:
+		return false
+	}
+}
+
+// CapabilityProber 探测上游支持的能力
+type CapabilityProber interface {
+	Probe(ctx context.Context, config *APIConfig) (*ProviderCapabilities, error)
+}
+
+// adapterProber 通过适配器的能力接口探测：Capabilities 给出能力集合，HealthCheck 确认上游可达
+type adapterProber struct {
+	factory *adapter.Factory
+}
+
+// NewAdapterProber 创建基于适配器的能力探测器
+func NewAdapterProber(factory *adapter.Factory) CapabilityProber {
+	return &adapterProber{factory: factory}
+}
+
+func (p *adapterProber) Probe(ctx context.Context, config *APIConfig) (*ProviderCapabilities, error) {
+	a, err := p.factory.CreateAdapter(config)
+	if err != nil {
+		return nil, err
+	}
+	caps := adapter.GetCapabilities(a)
+	result := &ProviderCapabilities{
+		Streaming: caps.Streaming,
+		Tools:     caps.Tools,
+		Vision:    caps.Vision,
+		JSONMode:  caps.JSONMode,
+	}
+	return result, adapter.HealthCheck(ctx, a)
+}
+
+// probeCapabilities 探测并记录配置的上游能力；账号池配置没有固定上游，不探测
+// 探测失败不阻止创建，失败原因记录在 Error 中
+func (s *service) probeCapabilities(ctx context.Context, config *APIConfig) {
+	if config.IsAccountPool() || s.prober == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, capabilityProbeTimeout)
+	defer cancel()
+
+	caps, err := s.prober.Probe(ctx, config)
+	if caps == nil {
+		caps = &ProviderCapabilities{}
+	}
+	caps.Reachable = err == nil
+	caps.Error = ""
+	if err != nil {
+		caps.Error = err.Error()
+		s.logger.Warn("Failed to probe provider capabilities",
+			logger.String("name", config.Name),
+			logger.String("type", config.Type),
+			logger.Error(err))
+	}
+	caps.CheckedAt = time.Now()
+	config.Capabilities = caps
+}
+
+// capabilityWarnings 列出上游不支持的已声明能力及受影响的模型
+func capabilityWarnings(config *APIConfig) []string {
+	if config.Capabilities == nil {
+		return nil
+	}
+	var warnings []string
+	for _, capability := range config.RequiredCapabilities {
+		if !config.Capabilities.Supports(capability) {
+			warnings = append(warnings, fmt.Sprintf("provider does not support %s, required by models: %s",
+				capability, strings.Join(config.Models, ", ")))
+		}
+	}
+	return warnings
+}
+
+// TestConfig 重新探测配置的上游能力并保存结果
+func (s *service) TestConfig(ctx context.Context, id uint) (*ConfigResponse, error) {
+	config, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get config", logger.Uint("config_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get config")
+	}
+	if config == nil {
+		return nil, errors.ErrAPIConfigNotFound
+	}
+
+	s.probeCapabilities(ctx, config)
+
+	if err := s.repo.Update(ctx, config); err != nil {
+		s.logger.Error("Failed to save config capabilities",
+			logger.Uint("config_id", id),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to update config")
+	}
+	return config.ToResponse(), nil
+}
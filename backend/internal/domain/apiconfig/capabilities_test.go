@@ -0,0 +1,151 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeRepo struct {
+	Repository
+	configs map[uint]*APIConfig
+	nextID  uint
+}
+
+func (r *fakeRepo) Create(ctx context.Context, config *APIConfig) error {
+	r.nextID++
+	config.ID = r.nextID
+	r.configs[config.ID] = config
+	return nil
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id uint) (*APIConfig, error) {
+	return r.configs[id], nil
+}
+
+func (r *fakeRepo) Update(ctx context.Context, config *APIConfig) error {
+	r.configs[config.ID] = config
+	return nil
+}
+
+type fakeProber struct {
+	caps  ProviderCapabilities
+	err   error
+	calls int
+}
+
+func (p *fakeProber) Probe(ctx context.Context, config *APIConfig) (*ProviderCapabilities, error) {
+	p.calls++
+	caps := p.caps
+	return &caps, p.err
+}
+
+func newCapabilityTestService(prober CapabilityProber) (*service, *fakeRepo) {
+	repo := &fakeRepo{configs: make(map[uint]*APIConfig)}
+	return &service{repo: repo, prober: prober, logger: *logger.NewNop()}, repo
+}
+
+func TestCreateConfig_PopulatesCapabilitiesFromProbe(t *testing.T) {
+	prober := &fakeProber{caps: ProviderCapabilities{Streaming: true, Tools: true, JSONMode: true}}
+	svc, repo := newCapabilityTestService(prober)
+
+	resp, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "upstream", Type: "openai", BaseURL: "https://example.com", Models: []string{"gpt-4", "gpt-4o"},
+		RequiredCapabilities: []string{CapabilityTools, CapabilityVision},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caps := repo.configs[resp.ID].Capabilities
+	if caps == nil || !caps.Streaming || !caps.Tools || !caps.JSONMode || caps.Vision || !caps.Reachable || caps.CheckedAt.IsZero() {
+		t.Fatalf("Expected probed capabilities stored on the config, got %+v", caps)
+	}
+	if resp.Capabilities == nil || !resp.Capabilities.Tools {
+		t.Errorf("Expected capabilities in the response, got %+v", resp.Capabilities)
+	}
+	want := "provider does not support vision, required by models: gpt-4, gpt-4o"
+	if len(resp.CapabilityWarnings) != 1 || resp.CapabilityWarnings[0] != want {
+		t.Errorf("Expected a warning for the missing vision capability, got %v", resp.CapabilityWarnings)
+	}
+}
+
+func TestCreateConfig_ProbeFailureDoesNotBlockCreation(t *testing.T) {
+	prober := &fakeProber{caps: ProviderCapabilities{Streaming: true}, err: errors.New("health check failed (status 401)")}
+	svc, repo := newCapabilityTestService(prober)
+
+	resp, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "upstream", Type: "openai", BaseURL: "https://example.com", Models: []string{"gpt-4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caps := repo.configs[resp.ID].Capabilities
+	if caps.Reachable || caps.Error != "health check failed (status 401)" || !caps.Streaming {
+		t.Errorf("Expected the probe failure recorded, got %+v", caps)
+	}
+}
+
+func TestCreateConfig_SkipsProbeForAccountPool(t *testing.T) {
+	prober := &fakeProber{}
+	svc, _ := newCapabilityTestService(prober)
+	poolID := uint(3)
+
+	resp, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "pool", Type: "kiro", ConfigType: "account_pool", AccountPoolID: &poolID, Models: []string{"claude"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prober.calls != 0 || resp.Capabilities != nil {
+		t.Errorf("Expected account pool configs not to be probed, calls %d caps %+v", prober.calls, resp.Capabilities)
+	}
+}
+
+func TestTestConfig_RefreshesCapabilities(t *testing.T) {
+	prober := &fakeProber{caps: ProviderCapabilities{Streaming: true}}
+	svc, repo := newCapabilityTestService(prober)
+	resp, _ := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "upstream", Type: "openai", BaseURL: "https://example.com", Models: []string{"gpt-4"},
+		RequiredCapabilities: []string{CapabilityTools},
+	})
+	if len(resp.CapabilityWarnings) != 1 {
+		t.Fatalf("Expected a tools warning before the provider gained tools, got %v", resp.CapabilityWarnings)
+	}
+
+	prober.caps.Tools = true
+	resp, err := svc.TestConfig(context.Background(), resp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repo.configs[resp.ID].Capabilities.Tools || len(resp.CapabilityWarnings) != 0 {
+		t.Errorf("Expected the re-probe to store tools support and clear the warning, got %+v %v", resp.Capabilities, resp.CapabilityWarnings)
+	}
+
+	if _, err := svc.TestConfig(context.Background(), 99); err == nil {
+		t.Error("Expected an error for a missing config")
+	}
+}
+
+func TestAdapterProber_UsesAdapterCapabilities(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("Unexpected probe path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer upstream.Close()
+
+	prober := NewAdapterProber(adapter.NewFactory())
+	caps, err := prober.Probe(context.Background(), &APIConfig{Type: "anthropic", BaseURL: upstream.URL, Timeout: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Streaming || !caps.Tools || !caps.Vision || caps.JSONMode {
+		t.Errorf("Expected the Anthropic adapter's capabilities, got %+v", caps)
+	}
+}
@@ -0,0 +1,36 @@
+package apiconfig
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ModelWeights 模型到并发权重的映射（存储为 JSON），未列出的模型权重为 1
+type ModelWeights map[string]int
+
+func (w ModelWeights) Value() (driver.Value, error) {
+	if w == nil {
+		return json.Marshal(map[string]int{})
+	}
+	return json.Marshal(w)
+}
+
+func (w *ModelWeights) Scan(value interface{}) error {
+	if value == nil {
+		*w = ModelWeights{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, w)
+}
+
+// ConcurrencyWeight 模型在共享并发预算中的权重，未设置或不合法时为 1
+func (c *APIConfig) ConcurrencyWeight(model string) int {
+	if weight := c.ConcurrencyWeights[model]; weight > 0 {
+		return weight
+	}
+	return 1
+}
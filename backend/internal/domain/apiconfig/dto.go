@@ -17,6 +17,44 @@ type CreateConfigRequest struct {
 	Weight        int                    `json:"weight" binding:"omitempty,min=1,max=100"`
 	MaxRPS        int                    `json:"max_rps" binding:"omitempty,min=0"`
 	Timeout       int                    `json:"timeout" binding:"omitempty,min=1,max=300"`
+	LogRequests   *bool                  `json:"log_requests" binding:"omitempty"` // 默认 true
+	UserAgent     string                 `json:"user_agent" binding:"omitempty,max=512"`
+
+	ContextTruncation string `json:"context_truncation" binding:"omitempty,oneof=drop_oldest summarize"`
+	ContextWindow     int    `json:"context_window" binding:"omitempty,min=0"`
+	StreamIdleTimeout int    `json:"stream_idle_timeout" binding:"omitempty,min=0,max=600"`
+
+	ToolResultMaxTokens int    `json:"tool_result_max_tokens" binding:"omitempty,min=0"`
+	ToolResultPolicy    string `json:"tool_result_policy" binding:"omitempty,oneof=truncate summarize"`
+	PrefixCaching     bool   `json:"prefix_caching"`
+	MaxTools          int    `json:"max_tools" binding:"omitempty,min=0"`
+	ToolLimitPolicy   string `json:"tool_limit_policy" binding:"omitempty,oneof=reject truncate-extra merge"`
+
+	MaxHistoryMessages int    `json:"max_history_messages" binding:"omitempty,min=0"`
+	HistoryLimitPolicy string `json:"history_limit_policy" binding:"omitempty,oneof=reject truncate"`
+
+	RetryEmptyResponse bool `json:"retry_empty_response"`
+
+	HealthWeightDecay bool `json:"health_weight_decay"`
+
+	ModelAliases ModelAliases `json:"model_aliases" binding:"omitempty,dive"`
+
+	RequiredCapabilities []string `json:"required_capabilities" binding:"omitempty,dive,oneof=streaming tools vision json_mode"`
+
+	AccountPools PoolWeights `json:"account_pools" binding:"omitempty,dive"`
+
+	CanaryPercent     int `json:"canary_percent" binding:"omitempty,min=0,max=100"`
+	CanaryRampMinutes int `json:"canary_ramp_minutes" binding:"omitempty,min=0"`
+
+	MaxConcurrency     int          `json:"max_concurrency" binding:"omitempty,min=0"`
+	ConcurrencyWeights ModelWeights `json:"concurrency_weights" binding:"omitempty,dive,min=1,max=100"`
+
+	ForwardHeaderAllowlist []string `json:"forward_header_allowlist" binding:"omitempty,dive,min=1,max=128"`
+	ForwardHeaderDenylist  []string `json:"forward_header_denylist" binding:"omitempty,dive,min=1,max=128"`
+
+	TLS *TLSRequest `json:"tls" binding:"omitempty"`
+
+	TraceSampleRate *float64 `json:"trace_sample_rate" binding:"omitempty,min=0,max=1"`
 }
 
 // UpdateConfigRequest 更新配置请求
@@ -35,6 +73,44 @@ type UpdateConfigRequest struct {
 	MaxRPS        *int                   `json:"max_rps" binding:"omitempty,min=0"`
 	Timeout       *int                   `json:"timeout" binding:"omitempty,min=1,max=300"`
 	IsActive      *bool                  `json:"is_active" binding:"omitempty"`
+	LogRequests   *bool                  `json:"log_requests" binding:"omitempty"`
+	UserAgent     *string                `json:"user_agent" binding:"omitempty,max=512"` // 传空字符串恢复默认
+
+	ContextTruncation *string `json:"context_truncation" binding:"omitempty,oneof='' drop_oldest summarize"` // 传空字符串关闭截断
+	ContextWindow     *int    `json:"context_window" binding:"omitempty,min=0"`
+	StreamIdleTimeout *int    `json:"stream_idle_timeout" binding:"omitempty,min=0,max=600"` // 传 0 关闭空闲超时
+
+	ToolResultMaxTokens *int    `json:"tool_result_max_tokens" binding:"omitempty,min=0"` // 传 0 关闭
+	ToolResultPolicy    *string `json:"tool_result_policy" binding:"omitempty,oneof='' truncate summarize"`
+	PrefixCaching     *bool   `json:"prefix_caching" binding:"omitempty"`
+	MaxTools          *int    `json:"max_tools" binding:"omitempty,min=0"` // 传 0 取消网关上限
+	ToolLimitPolicy   *string `json:"tool_limit_policy" binding:"omitempty,oneof='' reject truncate-extra merge"`
+
+	MaxHistoryMessages *int    `json:"max_history_messages" binding:"omitempty,min=0"` // 传 0 取消上限
+	HistoryLimitPolicy *string `json:"history_limit_policy" binding:"omitempty,oneof='' reject truncate"`
+
+	RetryEmptyResponse *bool `json:"retry_empty_response" binding:"omitempty"`
+
+	HealthWeightDecay *bool `json:"health_weight_decay" binding:"omitempty"`
+
+	ModelAliases ModelAliases `json:"model_aliases" binding:"omitempty,dive"` // 传空对象清除
+
+	RequiredCapabilities []string `json:"required_capabilities" binding:"omitempty,dive,oneof=streaming tools vision json_mode"` // 传空数组清除
+
+	AccountPools PoolWeights `json:"account_pools" binding:"omitempty,dive"` // 传空数组清除，恢复使用 account_pool_id
+
+	CanaryPercent     *int `json:"canary_percent" binding:"omitempty,min=0,max=100"` // 传 0 或 100 结束灰度
+	CanaryRampMinutes *int `json:"canary_ramp_minutes" binding:"omitempty,min=0"`
+
+	MaxConcurrency     *int         `json:"max_concurrency" binding:"omitempty,min=0"`                  // 传 0 取消并发限制
+	ConcurrencyWeights ModelWeights `json:"concurrency_weights" binding:"omitempty,dive,min=1,max=100"` // 传空对象清除
+
+	ForwardHeaderAllowlist []string `json:"forward_header_allowlist" binding:"omitempty,dive,min=1,max=128"` // 传空数组恢复默认白名单
+	ForwardHeaderDenylist  []string `json:"forward_header_denylist" binding:"omitempty,dive,min=1,max=128"`  // 传空数组清除
+
+	TLS *TLSRequest `json:"tls" binding:"omitempty"` // 传空对象清除
+
+	TraceSampleRate *float64 `json:"trace_sample_rate" binding:"omitempty,min=-1,max=1"` // 传 -1 恢复全局采样比例
 }
 
 // GetConfigsRequest 获取配置列表请求
@@ -68,8 +144,53 @@ type ConfigResponse struct {
 	Weight        int                    `json:"weight"`
 	MaxRPS        int                    `json:"max_rps"`
 	Timeout       int                    `json:"timeout"`
+	LogRequests   bool                   `json:"log_requests"`
+	UserAgent     string                 `json:"user_agent,omitempty"`
 	CreatedAt     time.Time              `json:"created_at"`
 	UpdatedAt     time.Time              `json:"updated_at"`
+
+	ContextTruncation string `json:"context_truncation,omitempty"`
+	ContextWindow     int    `json:"context_window"`
+	StreamIdleTimeout int    `json:"stream_idle_timeout"`
+
+	ToolResultMaxTokens int    `json:"tool_result_max_tokens"`
+	ToolResultPolicy    string `json:"tool_result_policy,omitempty"`
+	PrefixCaching     bool   `json:"prefix_caching"`
+	MaxTools          int    `json:"max_tools"`
+	ToolLimitPolicy   string `json:"tool_limit_policy,omitempty"`
+
+	MaxHistoryMessages int    `json:"max_history_messages"`
+	HistoryLimitPolicy string `json:"history_limit_policy,omitempty"`
+
+	RetryEmptyResponse bool `json:"retry_empty_response"`
+
+	HealthWeightDecay bool `json:"health_weight_decay"`
+
+	ReadOnly bool `json:"read_only"`
+
+	ModelAliases ModelAliases `json:"model_aliases,omitempty"`
+
+	Capabilities         *ProviderCapabilities `json:"capabilities,omitempty"`
+	RequiredCapabilities []string              `json:"required_capabilities,omitempty"`
+	CapabilityWarnings   []string              `json:"capability_warnings,omitempty"`
+
+	AccountPools PoolWeights `json:"account_pools,omitempty"`
+
+	CanaryPercent     int        `json:"canary_percent"`
+	CanaryRampMinutes int        `json:"canary_ramp_minutes"`
+	CanaryStartedAt   *time.Time `json:"canary_started_at,omitempty"`
+
+	MaxConcurrency     int          `json:"max_concurrency"`
+	ConcurrencyWeights ModelWeights `json:"concurrency_weights,omitempty"`
+
+	ForwardHeaderAllowlist []string `json:"forward_header_allowlist,omitempty"`
+	ForwardHeaderDenylist  []string `json:"forward_header_denylist,omitempty"`
+
+	UpstreamKeys []*UpstreamKeyResponse `json:"upstream_keys,omitempty"`
+
+	TLS *TLSResponse `json:"tls,omitempty"`
+
+	TraceSampleRate *float64 `json:"trace_sample_rate,omitempty"`
 }
 
 // ConfigListResponse 配置列表响应
@@ -104,8 +225,53 @@ func (c *APIConfig) ToResponse() *ConfigResponse {
 		Weight:        c.Weight,
 		MaxRPS:        c.MaxRPS,
 		Timeout:       c.Timeout,
+		LogRequests:   c.LogRequests,
+		UserAgent:     c.UserAgent,
 		CreatedAt:     c.CreatedAt,
 		UpdatedAt:     c.UpdatedAt,
+
+		ContextTruncation: c.ContextTruncation,
+		ContextWindow:     c.ContextWindow,
+		StreamIdleTimeout: c.StreamIdleTimeout,
+
+		ToolResultMaxTokens: c.ToolResultMaxTokens,
+		ToolResultPolicy:    c.ToolResultPolicy,
+		PrefixCaching:     c.PrefixCaching,
+		MaxTools:          c.MaxTools,
+		ToolLimitPolicy:   c.ToolLimitPolicy,
+
+		MaxHistoryMessages: c.MaxHistoryMessages,
+		HistoryLimitPolicy: c.HistoryLimitPolicy,
+
+		RetryEmptyResponse: c.RetryEmptyResponse,
+
+		HealthWeightDecay: c.HealthWeightDecay,
+
+		ReadOnly: c.ReadOnly,
+
+		ModelAliases: c.ModelAliases,
+
+		Capabilities:         c.Capabilities,
+		RequiredCapabilities: c.RequiredCapabilities,
+		CapabilityWarnings:   capabilityWarnings(c),
+
+		AccountPools: c.AccountPools,
+
+		CanaryPercent:     c.CanaryPercent,
+		CanaryRampMinutes: c.CanaryRampMinutes,
+		CanaryStartedAt:   c.CanaryStartedAt,
+
+		MaxConcurrency:     c.MaxConcurrency,
+		ConcurrencyWeights: c.ConcurrencyWeights,
+
+		ForwardHeaderAllowlist: c.ForwardHeaderAllowlist,
+		ForwardHeaderDenylist:  c.ForwardHeaderDenylist,
+
+		UpstreamKeys: toUpstreamKeyResponses(c.UpstreamKeys),
+
+		TLS: toTLSResponse(c.TLS),
+
+		TraceSampleRate: c.TraceSampleRate,
 	}
 }
 
@@ -118,6 +284,37 @@ func ToResponseList(configs []*APIConfig) []*ConfigResponse {
 	return responses
 }
 
+// AddUpstreamKeyRequest 添加上游 Key 请求
+type AddUpstreamKeyRequest struct {
+	APIKey  string `json:"api_key" binding:"required"`
+	Primary bool   `json:"primary"` // 为 true 时直接作为主 Key，否则作为备用 Key
+}
+
+// UpstreamKeyResponse 上游 Key 响应，不包含密钥本身
+type UpstreamKeyResponse struct {
+	ID        string    `json:"id"`
+	Hint      string    `json:"hint"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// toUpstreamKeyResponses 转换 Key 集合为响应列表，第一个为主 Key
+func toUpstreamKeyResponses(keys UpstreamKeys) []*UpstreamKeyResponse {
+	if len(keys) == 0 {
+		return nil
+	}
+	responses := make([]*UpstreamKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = &UpstreamKeyResponse{
+			ID:        key.ID,
+			Hint:      key.Hint,
+			Primary:   i == 0,
+			CreatedAt: key.CreatedAt,
+		}
+	}
+	return responses
+}
+
 // FetchModelsRequest 获取模型列表请求
 type FetchModelsRequest struct {
 	Provider string `json:"provider" binding:"required"` // openai, anthropic, gemini, etc.
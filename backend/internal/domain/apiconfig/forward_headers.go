@@ -0,0 +1,8 @@
+package apiconfig
+
+import "api-aggregator/backend/internal/adapter"
+
+// GetHeaderPolicy 返回转发客户端请求头的黑白名单（实现 adapter.HeaderPolicyProvider）
+func (c *APIConfig) GetHeaderPolicy() adapter.HeaderPolicy {
+	return adapter.HeaderPolicy{Allow: c.ForwardHeaderAllowlist, Deny: c.ForwardHeaderDenylist}
+}
@@ -42,6 +42,10 @@ func (h *Handler) CreateConfig(c *gin.Context) {
 
 	config, err := h.service.CreateConfig(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, err.Error(), "")
+			return
+		}
 		response.InternalError(c, err)
 		return
 	}
@@ -84,6 +88,68 @@ func (h *Handler) GetConfig(c *gin.Context) {
 	response.Success(c, gin.H{"config": config})
 }
 
+// TestConfig 测试配置
+// @Summary 测试API配置
+// @Description 重新探测上游可达性与能力（工具调用、视觉、流式、JSON 模式）并保存到配置（管理员）
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Success 200 {object} ConfigResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/test [post]
+func (h *Handler) TestConfig(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.service.TestConfig(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, errors.ErrAPIConfigNotFound) {
+			response.NotFound(c, "Configuration not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"config": config})
+}
+
+// PromoteCanary 结束灰度
+// @Summary 结束灰度发布
+// @Description 清除配置的灰度比例，之后按负载均衡权重承接全部应得流量（管理员）
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Success 200 {object} ConfigResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/promote [post]
+func (h *Handler) PromoteCanary(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	config, err := h.service.PromoteCanary(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, errors.ErrAPIConfigNotFound) {
+			response.NotFound(c, "Configuration not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"config": config})
+}
+
 // GetConfigs 获取配置列表
 // @Summary 获取API配置列表
 // @Description 获取API配置列表（管理员）
@@ -200,6 +266,10 @@ func (h *Handler) UpdateConfig(c *gin.Context) {
 			response.NotFound(c, "Configuration not found")
 			return
 		}
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, err.Error(), "")
+			return
+		}
 		response.InternalError(c, err)
 		return
 	}
@@ -439,3 +509,139 @@ func (h *Handler) GetAvailableModels(c *gin.Context) {
 
 	response.Success(c, models)
 }
+
+// parseConfigID 解析路径中的配置 ID
+func parseConfigID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid config ID", "Config ID must be a valid number")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeUpstreamKeyError 输出上游 Key 管理接口的错误
+func writeUpstreamKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errors.ErrAPIConfigNotFound):
+		response.NotFound(c, "Configuration not found")
+	case errors.Is(err, errors.ErrNotFound):
+		response.NotFound(c, "Upstream key not found")
+	case errors.Is(err, errors.ErrInvalidParam):
+		response.BadRequest(c, "Invalid upstream key operation", err.(*errors.AppError).Details)
+	default:
+		response.InternalError(c, err)
+	}
+}
+
+// ListUpstreamKeys 获取上游 Key 列表
+// @Summary 获取上游 Key 列表
+// @Description 获取配置的上游 API Key 列表，只返回末 4 位提示（管理员）
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Success 200 {array} UpstreamKeyResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/keys [get]
+func (h *Handler) ListUpstreamKeys(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListUpstreamKeys(c.Request.Context(), id)
+	if err != nil {
+		writeUpstreamKeyError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"keys": keys})
+}
+
+// AddUpstreamKey 添加上游 Key
+// @Summary 添加上游 Key
+// @Description 为配置添加上游 API Key（加密存储），默认作为备用 Key（管理员）
+// @Tags APIConfig
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Param request body AddUpstreamKeyRequest true "添加请求"
+// @Success 200 {array} UpstreamKeyResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/keys [post]
+func (h *Handler) AddUpstreamKey(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	var req AddUpstreamKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	keys, err := h.service.AddUpstreamKey(c.Request.Context(), id, &req)
+	if err != nil {
+		writeUpstreamKeyError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"keys": keys})
+}
+
+// PromoteUpstreamKey 提升为主 Key
+// @Summary 提升上游 Key
+// @Description 将指定 Key 提升为主 Key（管理员）
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Param key_id path string true "Key ID"
+// @Success 200 {array} UpstreamKeyResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/keys/{key_id}/promote [post]
+func (h *Handler) PromoteUpstreamKey(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.PromoteUpstreamKey(c.Request.Context(), id, c.Param("key_id"))
+	if err != nil {
+		writeUpstreamKeyError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"keys": keys})
+}
+
+// RetireUpstreamKey 停用上游 Key
+// @Summary 停用上游 Key
+// @Description 删除指定 Key，配置至少保留一个 Key（管理员）
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Param key_id path string true "Key ID"
+// @Success 200 {array} UpstreamKeyResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/api-configs/{id}/keys/{key_id} [delete]
+func (h *Handler) RetireUpstreamKey(c *gin.Context) {
+	id, ok := parseConfigID(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.RetireUpstreamKey(c.Request.Context(), id, c.Param("key_id"))
+	if err != nil {
+		writeUpstreamKeyError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"keys": keys})
+}
@@ -73,6 +73,8 @@ type APIConfig struct {
 	
 	// 璐﹀彿姹犻厤缃?
 	AccountPoolID *uint `gorm:"index" json:"account_pool_id,omitempty"`
+	// 多个账号池按权重分配流量，某个账号池没有可用凭据时换用其余账号池；设置后取代 AccountPoolID
+	AccountPools PoolWeights `gorm:"type:jsonb" json:"account_pools,omitempty"`
 	
 	Models   StringArray `gorm:"type:jsonb;not null;default:'[]'" json:"models"`
 	Headers  JSONMap     `gorm:"type:jsonb" json:"headers,omitempty"`
@@ -82,6 +84,76 @@ type APIConfig struct {
 	Weight   int         `gorm:"not null;default:1" json:"weight"`
 	MaxRPS   int         `gorm:"not null;default:0" json:"max_rps"`
 	Timeout  int         `gorm:"not null;default:30" json:"timeout"`
+
+	// 关闭后不写 request_logs 明细，只保留计费所需的聚合计数
+	// 不设置 gorm default，保证创建时显式写入 false
+	LogRequests bool `gorm:"not null" json:"log_requests"`
+
+	// 上游请求的 User-Agent 模板，支持 {version}、{provider} 等变量，为空使用默认值
+	UserAgent string `gorm:"size:512" json:"user_agent,omitempty"`
+
+	// 对话超出上下文窗口时的自动截断策略：drop_oldest、summarize，为空不截断
+	ContextTruncation string `gorm:"size:20" json:"context_truncation,omitempty"`
+	// 上下文窗口 token 数，0 表示按模型名取内置默认值
+	ContextWindow int `gorm:"not null;default:0" json:"context_window"`
+	// 单个工具结果的 token 上限，超出时按 ToolResultPolicy 截断（truncate，默认）或保留首尾摘要（summarize），0 表示不限制
+	ToolResultMaxTokens int    `gorm:"not null;default:0" json:"tool_result_max_tokens"`
+	ToolResultPolicy    string `gorm:"size:20;not null;default:''" json:"tool_result_policy,omitempty"`
+
+	// 流式响应空闲超时（秒），超过该时长未收到上游数据即中止并按已下发内容计费，0 表示不限制
+	StreamIdleTimeout int `gorm:"not null;default:0" json:"stream_idle_timeout"`
+
+	// 是否缓存重复出现的长对话前缀：支持提示词缓存的上游标记缓存断点，其余上游复用本地摘要
+	PrefixCaching bool `gorm:"not null;default:false" json:"prefix_caching"`
+
+	// 单次请求的工具数上限（0 表示只受供应商已知上限约束），以及超出时的处理策略：reject、truncate-extra、merge，为空时拒绝
+	MaxTools        int    `gorm:"not null;default:0" json:"max_tools"`
+	ToolLimitPolicy string `gorm:"size:20" json:"tool_limit_policy,omitempty"`
+
+	// 对话历史消息数上限（不含 system 消息，0 表示不限制），以及超出时的处理策略：reject、truncate，为空时拒绝
+	MaxHistoryMessages int    `gorm:"not null;default:0" json:"max_history_messages"`
+	HistoryLimitPolicy string `gorm:"size:20" json:"history_limit_policy,omitempty"`
+
+	// 上游返回空内容（无工具调用）时是否视为软失败重试一次，空响应不计费
+	RetryEmptyResponse bool `gorm:"not null;default:false" json:"retry_empty_response"`
+
+	// 按近期调用的成败衰减负载均衡权重：错误率上升时逐步减少分到的流量，恢复成功后回升，不会完全摘除
+	HealthWeightDecay bool `gorm:"not null;default:false" json:"health_weight_decay"`
+
+	// 由声明式配置文件管理且启用了只读：只能通过修改文件并重启变更，管理接口拒绝修改和删除
+	ReadOnly bool `gorm:"not null;default:false" json:"read_only"`
+
+	// 虚拟模型别名，如 support-bot、coder：改写为实际模型并附带各自的默认 system 提示词
+	ModelAliases ModelAliases `gorm:"type:jsonb" json:"model_aliases,omitempty"`
+
+	// 多个上游 Key（加密存储），主 Key 认证失败时依次尝试备用 Key；为空时使用 APIKey
+	UpstreamKeys UpstreamKeys `gorm:"type:jsonb" json:"-"`
+
+	// 创建或测试配置时探测到的上游能力，账号池配置为空
+	Capabilities *ProviderCapabilities `gorm:"type:jsonb" json:"capabilities,omitempty"`
+	// 管理员声明模型需要的能力，上游不支持时在响应中给出警告
+	RequiredCapabilities StringArray `gorm:"type:jsonb" json:"required_capabilities,omitempty"`
+
+	// 灰度发布：只把该百分比的请求路由到此配置（不受负载均衡权重影响），0 表示不灰度
+	// 设置爬坡时长后比例随时间线性增长，到期自动按正常权重承接流量
+	CanaryPercent     int        `gorm:"not null;default:0" json:"canary_percent"`
+	CanaryRampMinutes int        `gorm:"not null;default:0" json:"canary_ramp_minutes"`
+	CanaryStartedAt   *time.Time `json:"canary_started_at,omitempty"`
+
+	// 上游并发预算：该配置的所有模型共享，按模型权重公平分配，账号池配置按每个凭据计算；0 表示不限制
+	MaxConcurrency     int          `gorm:"not null;default:0" json:"max_concurrency"`
+	ConcurrencyWeights ModelWeights `gorm:"type:jsonb" json:"concurrency_weights,omitempty"`
+
+	// 转发给上游的客户端请求头：白名单为空时使用内置的安全白名单，黑名单优先；支持 x-trace-* 前缀匹配
+	// 认证、Cookie 等敏感头和传输相关的头始终不转发
+	ForwardHeaderAllowlist StringArray `gorm:"type:jsonb" json:"forward_header_allowlist,omitempty"`
+	ForwardHeaderDenylist  StringArray `gorm:"type:jsonb" json:"forward_header_denylist,omitempty"`
+
+	// 自定义 TLS：客户端证书、私有 CA 或跳过校验（私钥加密存储），为空使用系统默认
+	TLS *TLSSettings `gorm:"column:tls_settings;type:jsonb" json:"-"`
+
+	// 路由到此配置的请求的链路追踪采样比例（0-1），为空使用全局采样比例；失败请求另按全局的失败采样比例采样
+	TraceSampleRate *float64 `json:"trace_sample_rate,omitempty"`
 }
 
 // TableName 鎸囧畾琛ㄥ悕
@@ -186,6 +258,11 @@ func (c *APIConfig) GetTimeout() int {
 	return c.Timeout
 }
 
+// GetUserAgent 获取 User-Agent 模板（实现 adapter.APIConfigInterface）
+func (c *APIConfig) GetUserAgent() string {
+	return c.UserAgent
+}
+
 // IsDirect 鏄惁鏄洿鎺ヨ皟鐢?
 func (c *APIConfig) IsDirect() bool {
 	return c.ConfigType == ConfigTypeDirect
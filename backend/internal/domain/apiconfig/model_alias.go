@@ -0,0 +1,48 @@
+package apiconfig
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// 客户端自带 system 消息时默认 system 提示词的处理策略
+const (
+	SystemPolicyOverride = "override" // 客户端的 system 消息替代默认提示词
+	SystemPolicyPrepend  = "prepend"  // 默认提示词放在客户端 system 消息之前
+)
+
+// ModelAlias 虚拟模型：客户端请求别名，网关改写为实际模型并附带默认 system 提示词
+// 别名需同时出现在配置的 Models 中才会被路由到该配置，定价和日志按别名记录
+type ModelAlias struct {
+	Model        string `json:"model,omitempty"`                                                    // 发往上游的实际模型，为空时沿用别名
+	SystemPrompt string `json:"system_prompt,omitempty"`                                            // 默认 system 提示词
+	SystemPolicy string `json:"system_policy,omitempty" binding:"omitempty,oneof=override prepend"` // 为空时按 override 处理
+}
+
+// ModelAliases 别名到虚拟模型定义的映射（存储为 JSON）
+type ModelAliases map[string]ModelAlias
+
+func (a ModelAliases) Value() (driver.Value, error) {
+	if a == nil {
+		return json.Marshal(map[string]ModelAlias{})
+	}
+	return json.Marshal(a)
+}
+
+func (a *ModelAliases) Scan(value interface{}) error {
+	if value == nil {
+		*a = ModelAliases{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, a)
+}
+
+// FindModelAlias 查找别名定义
+func (c *APIConfig) FindModelAlias(name string) (ModelAlias, bool) {
+	alias, ok := c.ModelAliases[name]
+	return alias, ok
+}
@@ -0,0 +1,40 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+)
+
+// readOnlyError 只读配置拒绝通过管理接口修改
+func readOnlyError(config *APIConfig) error {
+	return errors.ErrForbidden.WithDetails(fmt.Sprintf("config %q is managed by the declarative config file and is read-only", config.Name))
+}
+
+// findWritableConfig 查找可通过管理接口修改的配置
+func (s *service) findWritableConfig(ctx context.Context, id uint) (*APIConfig, error) {
+	config, err := s.findConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if config.ReadOnly {
+		return nil, readOnlyError(config)
+	}
+	return config, nil
+}
+
+// ensureWritable 批量操作前检查，任一配置只读时整批拒绝
+func (s *service) ensureWritable(ctx context.Context, ids []uint) error {
+	for _, id := range ids {
+		config, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			s.logger.Error("Failed to get config", logger.Uint("config_id", id), logger.Error(err))
+			return errors.Wrap(err, 500002, "Failed to get config")
+		}
+		if config != nil && config.ReadOnly {
+			return readOnlyError(config)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"testing"
+)
+
+func TestReadOnlyConfig_RejectsAdminChanges(t *testing.T) {
+	svc, repo := newCapabilityTestService(&fakeProber{})
+	repo.Create(context.Background(), &APIConfig{Name: "from-file", Type: "openai", IsActive: true, ReadOnly: true})
+	repo.Create(context.Background(), &APIConfig{Name: "from-api", Type: "openai", IsActive: true})
+
+	if _, err := svc.UpdateConfig(context.Background(), 1, &UpdateConfigRequest{Name: "renamed"}); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected updating a read-only config to be forbidden, got %v", err)
+	}
+	if err := svc.DeleteConfig(context.Background(), 1); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected deleting a read-only config to be forbidden, got %v", err)
+	}
+	if err := svc.DeactivateConfig(context.Background(), 1); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected deactivating a read-only config to be forbidden, got %v", err)
+	}
+	if _, err := svc.BatchDeleteConfigs(context.Background(), []uint{2, 1}); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected a batch containing a read-only config to be forbidden, got %v", err)
+	}
+	if _, err := svc.AddUpstreamKey(context.Background(), 1, &AddUpstreamKeyRequest{APIKey: "sk-new"}); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected adding a key to a read-only config to be forbidden, got %v", err)
+	}
+	if repo.configs[1].Name != "from-file" || !repo.configs[1].IsActive {
+		t.Error("Expected the read-only config left unchanged")
+	}
+
+	if _, err := svc.UpdateConfig(context.Background(), 2, &UpdateConfigRequest{Name: "renamed"}); err != nil {
+		t.Errorf("Expected API-managed configs to stay editable, got %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package apiconfig
 
 import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/crypto"
 	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/logger"
 	"api-aggregator/backend/pkg/query"
@@ -31,19 +33,31 @@ type Service interface {
 	BatchActivateConfigs(ctx context.Context, ids []uint) (*BatchOperationResponse, error)
 	BatchDeactivateConfigs(ctx context.Context, ids []uint) (*BatchOperationResponse, error)
 	FetchModels(ctx context.Context, req *FetchModelsRequest) (*FetchModelsResponse, error)
+	TestConfig(ctx context.Context, id uint) (*ConfigResponse, error)
+	PromoteCanary(ctx context.Context, id uint) (*ConfigResponse, error)
+
+	// 上游 Key 轮换
+	ListUpstreamKeys(ctx context.Context, id uint) ([]*UpstreamKeyResponse, error)
+	AddUpstreamKey(ctx context.Context, id uint, req *AddUpstreamKeyRequest) ([]*UpstreamKeyResponse, error)
+	PromoteUpstreamKey(ctx context.Context, id uint, keyID string) ([]*UpstreamKeyResponse, error)
+	RetireUpstreamKey(ctx context.Context, id uint, keyID string) ([]*UpstreamKeyResponse, error)
 }
 
 // service API配置服务实现
 type service struct {
-	repo   Repository
-	logger logger.Logger
+	repo    Repository
+	secrets *crypto.SecretBox
+	prober  CapabilityProber
+	logger  logger.Logger
 }
 
-// NewService 创建API配置服务
-func NewService(repo Repository, logger logger.Logger) Service {
+// NewService 创建API配置服务，secrets 用于加密上游 Key
+func NewService(repo Repository, secrets *crypto.SecretBox, logger logger.Logger) Service {
 	return &service{
-		repo:   repo,
-		logger: logger,
+		repo:    repo,
+		secrets: secrets,
+		prober:  NewAdapterProber(adapter.NewFactory().WithSecretBox(secrets)),
+		logger:  logger,
 	}
 }
 
@@ -60,9 +74,9 @@ func (s *service) CreateConfig(ctx context.Context, req *CreateConfigRequest) (*
 	
 	// 如果是账号池类型，不需要 base_url
 	if configType == "account_pool" {
-		if req.AccountPoolID == nil || *req.AccountPoolID == 0 {
-			return nil, errors.NewValidationError("account_pool_id is required for account_pool type", map[string]string{
-				"account_pool_id": "required when config_type is account_pool",
+		if (req.AccountPoolID == nil || *req.AccountPoolID == 0) && len(req.AccountPools) == 0 {
+			return nil, errors.NewValidationError("account_pool_id or account_pools is required for account_pool type", map[string]string{
+				"account_pool_id": "required when config_type is account_pool and account_pools is empty",
 			})
 		}
 		// 账号池类型不需要 base_url
@@ -102,6 +116,10 @@ func (s *service) CreateConfig(ctx context.Context, req *CreateConfigRequest) (*
 	if timeout == 0 {
 		timeout = 30
 	}
+	logRequests := true
+	if req.LogRequests != nil {
+		logRequests = *req.LogRequests
+	}
 
 	// 创建配置
 	config := &APIConfig{
@@ -119,8 +137,55 @@ func (s *service) CreateConfig(ctx context.Context, req *CreateConfigRequest) (*
 		Weight:        weight,
 		MaxRPS:        req.MaxRPS,
 		Timeout:       timeout,
+		LogRequests:   logRequests,
+		UserAgent:     req.UserAgent,
+
+		ContextTruncation: req.ContextTruncation,
+		ContextWindow:     req.ContextWindow,
+		StreamIdleTimeout: req.StreamIdleTimeout,
+
+		ToolResultMaxTokens: req.ToolResultMaxTokens,
+		ToolResultPolicy:    req.ToolResultPolicy,
+		PrefixCaching:     req.PrefixCaching,
+		MaxTools:          req.MaxTools,
+		ToolLimitPolicy:   req.ToolLimitPolicy,
+
+		MaxHistoryMessages: req.MaxHistoryMessages,
+		HistoryLimitPolicy: req.HistoryLimitPolicy,
+
+		RetryEmptyResponse: req.RetryEmptyResponse,
+
+		HealthWeightDecay: req.HealthWeightDecay,
+
+		ModelAliases: req.ModelAliases,
+
+		RequiredCapabilities: req.RequiredCapabilities,
+
+		AccountPools: req.AccountPools,
+
+		CanaryRampMinutes: req.CanaryRampMinutes,
+
+		MaxConcurrency:     req.MaxConcurrency,
+		ConcurrencyWeights: req.ConcurrencyWeights,
+
+		ForwardHeaderAllowlist: req.ForwardHeaderAllowlist,
+		ForwardHeaderDenylist:  req.ForwardHeaderDenylist,
+
+		TraceSampleRate: req.TraceSampleRate,
+	}
+	config.setCanary(req.CanaryPercent, time.Now())
+
+	if req.TLS != nil {
+		tlsSettings, err := s.buildTLSSettings(req.TLS, nil)
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsSettings
 	}
 
+	// 探测上游能力，不支持已声明能力时通过响应中的 capability_warnings 提示
+	s.probeCapabilities(ctx, config)
+
 	if err := s.repo.Create(ctx, config); err != nil {
 		s.logger.Error("Failed to create config",
 			logger.String("name", req.Name),
@@ -259,6 +324,9 @@ func (s *service) UpdateConfig(ctx context.Context, id uint, req *UpdateConfigRe
 	if config == nil {
 		return nil, errors.ErrAPIConfigNotFound
 	}
+	if config.ReadOnly {
+		return nil, readOnlyError(config)
+	}
 
 	// 更新字段
 	if req.Name != "" {
@@ -277,6 +345,10 @@ func (s *service) UpdateConfig(ctx context.Context, id uint, req *UpdateConfigRe
 		config.BaseURL = req.BaseURL
 	}
 	if req.APIKey != "" {
+		// 配置已使用上游 Key 集合时 api_key 不再生效，需通过上游 Key 接口添加并提升为主 Key
+		if len(config.UpstreamKeys) > 0 {
+			return nil, errors.ErrInvalidParam.WithDetails("Config uses upstream keys, add or promote the key through the upstream keys API")
+		}
 		config.APIKey = req.APIKey
 	}
 	if len(req.Models) > 0 {
@@ -303,6 +375,88 @@ func (s *service) UpdateConfig(ctx context.Context, id uint, req *UpdateConfigRe
 	if req.IsActive != nil {
 		config.IsActive = *req.IsActive
 	}
+	if req.LogRequests != nil {
+		config.LogRequests = *req.LogRequests
+	}
+	if req.UserAgent != nil {
+		config.UserAgent = *req.UserAgent
+	}
+	if req.ContextTruncation != nil {
+		config.ContextTruncation = *req.ContextTruncation
+	}
+	if req.ContextWindow != nil {
+		config.ContextWindow = *req.ContextWindow
+	}
+	if req.ToolResultMaxTokens != nil {
+		config.ToolResultMaxTokens = *req.ToolResultMaxTokens
+	}
+	if req.ToolResultPolicy != nil {
+		config.ToolResultPolicy = *req.ToolResultPolicy
+	}
+	if req.StreamIdleTimeout != nil {
+		config.StreamIdleTimeout = *req.StreamIdleTimeout
+	}
+	if req.PrefixCaching != nil {
+		config.PrefixCaching = *req.PrefixCaching
+	}
+	if req.MaxTools != nil {
+		config.MaxTools = *req.MaxTools
+	}
+	if req.ToolLimitPolicy != nil {
+		config.ToolLimitPolicy = *req.ToolLimitPolicy
+	}
+	if req.MaxHistoryMessages != nil {
+		config.MaxHistoryMessages = *req.MaxHistoryMessages
+	}
+	if req.HistoryLimitPolicy != nil {
+		config.HistoryLimitPolicy = *req.HistoryLimitPolicy
+	}
+	if req.RetryEmptyResponse != nil {
+		config.RetryEmptyResponse = *req.RetryEmptyResponse
+	}
+	if req.HealthWeightDecay != nil {
+		config.HealthWeightDecay = *req.HealthWeightDecay
+	}
+	if req.ModelAliases != nil {
+		config.ModelAliases = req.ModelAliases
+	}
+	if req.RequiredCapabilities != nil {
+		config.RequiredCapabilities = req.RequiredCapabilities
+	}
+	if req.AccountPools != nil {
+		config.AccountPools = req.AccountPools
+	}
+	if req.CanaryRampMinutes != nil {
+		config.CanaryRampMinutes = *req.CanaryRampMinutes
+	}
+	if req.CanaryPercent != nil {
+		config.setCanary(*req.CanaryPercent, time.Now())
+	}
+	if req.MaxConcurrency != nil {
+		config.MaxConcurrency = *req.MaxConcurrency
+	}
+	if req.ConcurrencyWeights != nil {
+		config.ConcurrencyWeights = req.ConcurrencyWeights
+	}
+	if req.ForwardHeaderAllowlist != nil {
+		config.ForwardHeaderAllowlist = req.ForwardHeaderAllowlist
+	}
+	if req.ForwardHeaderDenylist != nil {
+		config.ForwardHeaderDenylist = req.ForwardHeaderDenylist
+	}
+	if req.TLS != nil {
+		tlsSettings, err := s.buildTLSSettings(req.TLS, config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		config.TLS = tlsSettings
+	}
+	if req.TraceSampleRate != nil {
+		config.TraceSampleRate = req.TraceSampleRate
+		if *req.TraceSampleRate < 0 {
+			config.TraceSampleRate = nil
+		}
+	}
 
 	// 保存更新
 	if err := s.repo.Update(ctx, config); err != nil {
@@ -330,6 +484,9 @@ func (s *service) DeleteConfig(ctx context.Context, id uint) error {
 	if config == nil {
 		return errors.ErrAPIConfigNotFound
 	}
+	if config.ReadOnly {
+		return readOnlyError(config)
+	}
 
 	// 删除配置
 	if err := s.repo.Delete(ctx, id); err != nil {
@@ -357,6 +514,9 @@ func (s *service) ActivateConfig(ctx context.Context, id uint) error {
 	if config == nil {
 		return errors.ErrAPIConfigNotFound
 	}
+	if config.ReadOnly {
+		return readOnlyError(config)
+	}
 
 	// 激活配置
 	if err := s.repo.UpdateStatus(ctx, id, true); err != nil {
@@ -384,6 +544,9 @@ func (s *service) DeactivateConfig(ctx context.Context, id uint) error {
 	if config == nil {
 		return errors.ErrAPIConfigNotFound
 	}
+	if config.ReadOnly {
+		return readOnlyError(config)
+	}
 
 	// 停用配置
 	if err := s.repo.UpdateStatus(ctx, id, false); err != nil {
@@ -405,6 +568,9 @@ func (s *service) BatchDeleteConfigs(ctx context.Context, ids []uint) (*BatchOpe
 	if len(ids) == 0 {
 		return nil, errors.ErrInvalidParam.WithDetails("IDs array cannot be empty")
 	}
+	if err := s.ensureWritable(ctx, ids); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.BatchDelete(ctx, ids); err != nil {
 		s.logger.Error("Failed to batch delete configs",
@@ -426,6 +592,9 @@ func (s *service) BatchActivateConfigs(ctx context.Context, ids []uint) (*BatchO
 	if len(ids) == 0 {
 		return nil, errors.ErrInvalidParam.WithDetails("IDs array cannot be empty")
 	}
+	if err := s.ensureWritable(ctx, ids); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.BatchUpdateStatus(ctx, ids, true); err != nil {
 		s.logger.Error("Failed to batch activate configs",
@@ -447,6 +616,9 @@ func (s *service) BatchDeactivateConfigs(ctx context.Context, ids []uint) (*Batc
 	if len(ids) == 0 {
 		return nil, errors.ErrInvalidParam.WithDetails("IDs array cannot be empty")
 	}
+	if err := s.ensureWritable(ctx, ids); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.BatchUpdateStatus(ctx, ids, false); err != nil {
 		s.logger.Error("Failed to batch deactivate configs",
@@ -633,15 +805,18 @@ func (s *service) fetchOpenAIModels(ctx context.Context, req *FetchModelsRequest
 	// 转换为 ModelInfo
 	models := make([]*ModelInfo, 0, len(result.Data))
 	for _, m := range result.Data {
-		// 只返回 GPT 和 O1 模型
-		if strings.HasPrefix(m.ID, "gpt-") || strings.HasPrefix(m.ID, "o1") {
+		// 只返回 GPT 和推理模型
+		if strings.HasPrefix(m.ID, "gpt-") || adapter.SupportsReasoningEffort(m.ID) || strings.HasPrefix(m.ID, "o1") {
 			capabilities := []string{"chat", "completion"}
 			if strings.Contains(m.ID, "vision") || strings.Contains(m.ID, "4o") {
 				capabilities = append(capabilities, "vision")
 			}
-			if strings.HasPrefix(m.ID, "o1") {
+			if strings.HasPrefix(m.ID, "o1") || adapter.SupportsReasoningEffort(m.ID) {
 				capabilities = append(capabilities, "reasoning")
 			}
+			if adapter.SupportsReasoningEffort(m.ID) {
+				capabilities = append(capabilities, "reasoning_effort")
+			}
 
 			models = append(models, &ModelInfo{
 				ID:           m.ID,
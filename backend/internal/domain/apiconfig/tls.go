@@ -0,0 +1,131 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/crypto"
+	"api-aggregator/backend/pkg/errors"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/json"
+	"encoding/pem"
+	"time"
+)
+
+// TLSSettings 上游连接的自定义 TLS 配置（存储为 JSON），用于双向 TLS 或私有 CA
+// 证书和 CA 为 PEM 明文，客户端私钥为 SecretBox 加密后的密文
+type TLSSettings struct {
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+	CABundle           string `json:"ca_bundle,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+func (t TLSSettings) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+func (t *TLSSettings) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, t)
+}
+
+// GetTLSSettings 返回 TLS 配置（实现 adapter.TLSConfigProvider），私钥由适配器工厂解密
+func (c *APIConfig) GetTLSSettings() *adapter.TLSSettings {
+	if c.TLS == nil {
+		return nil
+	}
+	return &adapter.TLSSettings{
+		ClientCert:         c.TLS.ClientCert,
+		ClientKey:          c.TLS.ClientKey,
+		CABundle:           c.TLS.CABundle,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+	}
+}
+
+// TLSRequest 设置 TLS 配置请求，所有字段为空时清除
+type TLSRequest struct {
+	ClientCert         string `json:"client_cert"`
+	ClientKey          string `json:"client_key"` // 证书不变时可省略，沿用已保存的私钥
+	CABundle           string `json:"ca_bundle"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 仅用于开发环境
+}
+
+// TLSResponse TLS 配置摘要，不包含证书和私钥
+type TLSResponse struct {
+	HasClientCert        bool       `json:"has_client_cert"`
+	HasCABundle          bool       `json:"has_ca_bundle"`
+	InsecureSkipVerify   bool       `json:"insecure_skip_verify"`
+	ClientCertExpiresAt  *time.Time `json:"client_cert_expires_at,omitempty"`
+	ClientCertCommonName string     `json:"client_cert_common_name,omitempty"`
+}
+
+// toTLSResponse 转换为摘要，未配置时返回 nil
+func toTLSResponse(t *TLSSettings) *TLSResponse {
+	if t == nil {
+		return nil
+	}
+	resp := &TLSResponse{
+		HasClientCert:      t.ClientCert != "",
+		HasCABundle:        t.CABundle != "",
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if block, _ := pem.Decode([]byte(t.ClientCert)); block != nil {
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			resp.ClientCertExpiresAt = &cert.NotAfter
+			resp.ClientCertCommonName = cert.Subject.CommonName
+		}
+	}
+	return resp
+}
+
+// buildTLSSettings 校验并加密 TLS 配置，current 为已保存的配置（创建时为 nil）
+// 证书或 CA 无效时返回 ErrInvalidParam，请求字段全部为空时返回 nil 表示清除
+func (s *service) buildTLSSettings(req *TLSRequest, current *TLSSettings) (*TLSSettings, error) {
+	plain := &adapter.TLSSettings{
+		ClientCert:         req.ClientCert,
+		ClientKey:          req.ClientKey,
+		CABundle:           req.CABundle,
+		InsecureSkipVerify: req.InsecureSkipVerify,
+	}
+	if plain.IsEmpty() {
+		return nil, nil
+	}
+
+	encryptedKey := ""
+	if plain.ClientKey == "" && plain.ClientCert != "" && current != nil && current.ClientCert == plain.ClientCert {
+		encryptedKey = current.ClientKey
+		if crypto.IsEncrypted(encryptedKey) && s.secrets != nil {
+			if key, err := s.secrets.Decrypt(encryptedKey); err == nil {
+				plain.ClientKey = key
+			}
+		}
+	}
+
+	if _, err := adapter.NewTLSConfig(plain); err != nil {
+		return nil, errors.Wrap(err, 400001, "Invalid TLS settings")
+	}
+
+	if encryptedKey == "" && plain.ClientKey != "" {
+		if s.secrets == nil {
+			return nil, errors.ErrEncryption.WithDetails("Encryption key not configured")
+		}
+		encrypted, err := s.secrets.Encrypt(plain.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, 500005, "Failed to encrypt TLS client key")
+		}
+		encryptedKey = encrypted
+	}
+
+	return &TLSSettings{
+		ClientCert:         plain.ClientCert,
+		ClientKey:          encryptedKey,
+		CABundle:           plain.CABundle,
+		InsecureSkipVerify: plain.InsecureSkipVerify,
+	}, nil
+}
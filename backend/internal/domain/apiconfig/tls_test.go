@@ -0,0 +1,115 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/crypto"
+	pkgerrors "api-aggregator/backend/pkg/errors"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testClientCert 生成自签的客户端证书和私钥（PEM）
+func testClientCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func newTLSTestService(t *testing.T) (*service, *fakeRepo) {
+	svc, repo := newCapabilityTestService(&fakeProber{})
+	box, err := crypto.NewSecretBox("test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.secrets = box
+	return svc, repo
+}
+
+func TestCreateConfig_EncryptsTLSClientKey(t *testing.T) {
+	svc, repo := newTLSTestService(t)
+	certPEM, keyPEM := testClientCert(t)
+
+	resp, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+		Name: "mtls", Type: "openai", BaseURL: "https://internal.example.com", Models: []string{"gpt-4"},
+		TLS: &TLSRequest{ClientCert: certPEM, ClientKey: keyPEM, CABundle: certPEM},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	stored := repo.configs[resp.ID].TLS
+	if stored == nil || !crypto.IsEncrypted(stored.ClientKey) || stored.ClientCert != certPEM {
+		t.Fatalf("Expected the client key stored encrypted, got %+v", stored)
+	}
+	if plain, _ := svc.secrets.Decrypt(stored.ClientKey); plain != keyPEM {
+		t.Error("Expected the stored key to decrypt to the submitted key")
+	}
+	if resp.TLS == nil || !resp.TLS.HasClientCert || !resp.TLS.HasCABundle || resp.TLS.ClientCertCommonName != "gateway" {
+		t.Errorf("Unexpected TLS summary: %+v", resp.TLS)
+	}
+
+	// 证书不变时省略私钥沿用已保存的私钥
+	updated, err := svc.UpdateConfig(context.Background(), resp.ID, &UpdateConfigRequest{
+		TLS: &TLSRequest{ClientCert: certPEM},
+	})
+	if err != nil {
+		t.Fatalf("Expected stored key reused, got %v", err)
+	}
+	if repo.configs[resp.ID].TLS.ClientKey != stored.ClientKey || updated.TLS.HasCABundle {
+		t.Errorf("Expected key kept and CA bundle replaced, got %+v", repo.configs[resp.ID].TLS)
+	}
+
+	// 传空对象清除
+	if _, err := svc.UpdateConfig(context.Background(), resp.ID, &UpdateConfigRequest{TLS: &TLSRequest{}}); err != nil {
+		t.Fatal(err)
+	}
+	if repo.configs[resp.ID].TLS != nil {
+		t.Errorf("Expected TLS settings cleared, got %+v", repo.configs[resp.ID].TLS)
+	}
+}
+
+func TestCreateConfig_RejectsInvalidTLSMaterial(t *testing.T) {
+	svc, repo := newTLSTestService(t)
+	certPEM, _ := testClientCert(t)
+	_, otherKey := testClientCert(t)
+
+	cases := map[string]*TLSRequest{
+		"invalid CA":     {CABundle: "-----BEGIN CERTIFICATE-----\nbroken\n-----END CERTIFICATE-----"},
+		"missing key":    {ClientCert: certPEM},
+		"mismatched key": {ClientCert: certPEM, ClientKey: otherKey},
+	}
+	for name, tlsReq := range cases {
+		_, err := svc.CreateConfig(context.Background(), &CreateConfigRequest{
+			Name: name, Type: "openai", BaseURL: "https://internal.example.com", Models: []string{"gpt-4"},
+			TLS: tlsReq,
+		})
+		if !pkgerrors.Is(err, pkgerrors.ErrInvalidParam) {
+			t.Errorf("%s: expected invalid parameter error, got %v", name, err)
+		}
+	}
+	if len(repo.configs) != 0 {
+		t.Errorf("Expected no configs saved, got %d", len(repo.configs))
+	}
+}
@@ -0,0 +1,189 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/crypto"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// UpstreamKey 上游 API Key，Key 为 SecretBox 加密后的密文
+type UpstreamKey struct {
+	ID        string    `json:"id"`
+	Key       string    `json:"key"`
+	Hint      string    `json:"hint"` // 明文末 4 位，便于管理员辨认
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UpstreamKeys 按优先级排列的上游 Key 集合（存储为 JSON），第一个为主 Key
+// 主 Key 认证失败时依次尝试后续 Key，轮换时先添加新 Key、提升为主 Key，再停用旧 Key
+type UpstreamKeys []UpstreamKey
+
+func (k UpstreamKeys) Value() (driver.Value, error) {
+	if k == nil {
+		return json.Marshal([]UpstreamKey{})
+	}
+	return json.Marshal(k)
+}
+
+func (k *UpstreamKeys) Scan(value interface{}) error {
+	if value == nil {
+		*k = UpstreamKeys{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, k)
+}
+
+// GetAPIKeys 返回加密存储的 Key 集合（实现 adapter.MultiKeyConfig），由适配器工厂解密
+func (c *APIConfig) GetAPIKeys() []string {
+	keys := make([]string, len(c.UpstreamKeys))
+	for i, key := range c.UpstreamKeys {
+		keys[i] = key.Key
+	}
+	return keys
+}
+
+// findUpstreamKey 查找 Key 的位置，不存在时返回 -1
+func (c *APIConfig) findUpstreamKey(id string) int {
+	for i, key := range c.UpstreamKeys {
+		if key.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// keyHint 取 Key 末 4 位作为提示
+func keyHint(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "..." + key[len(key)-4:]
+}
+
+// newUpstreamKey 加密并生成一个新的 Key 条目
+func (s *service) newUpstreamKey(plain string) (UpstreamKey, error) {
+	if s.secrets == nil {
+		return UpstreamKey{}, errors.ErrEncryption.WithDetails("Encryption key not configured")
+	}
+	encrypted, err := s.secrets.Encrypt(plain)
+	if err != nil {
+		return UpstreamKey{}, errors.Wrap(err, 500005, "Failed to encrypt API key")
+	}
+	id, err := crypto.GenerateRandomString(12)
+	if err != nil {
+		return UpstreamKey{}, errors.Wrap(err, 500001, "Failed to generate key ID")
+	}
+	return UpstreamKey{ID: id, Key: encrypted, Hint: keyHint(plain), CreatedAt: time.Now()}, nil
+}
+
+// findConfig 查找配置，不存在时返回 ErrAPIConfigNotFound
+func (s *service) findConfig(ctx context.Context, id uint) (*APIConfig, error) {
+	config, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get config", logger.Uint("config_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get config")
+	}
+	if config == nil {
+		return nil, errors.ErrAPIConfigNotFound
+	}
+	return config, nil
+}
+
+// saveUpstreamKeys 保存 Key 集合并返回最新列表
+func (s *service) saveUpstreamKeys(ctx context.Context, config *APIConfig, action string) ([]*UpstreamKeyResponse, error) {
+	if err := s.repo.Update(ctx, config); err != nil {
+		s.logger.Error("Failed to update upstream keys",
+			logger.Uint("config_id", config.ID),
+			logger.String("action", action),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to update upstream keys")
+	}
+	s.logger.Info("Upstream keys updated",
+		logger.Uint("config_id", config.ID),
+		logger.String("action", action),
+		logger.Int("keys", len(config.UpstreamKeys)))
+	return toUpstreamKeyResponses(config.UpstreamKeys), nil
+}
+
+// ListUpstreamKeys 获取配置的上游 Key 列表（只返回提示，不返回密钥）
+func (s *service) ListUpstreamKeys(ctx context.Context, id uint) ([]*UpstreamKeyResponse, error) {
+	config, err := s.findConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toUpstreamKeyResponses(config.UpstreamKeys), nil
+}
+
+// AddUpstreamKey 添加上游 Key，默认作为备用 Key 追加到末尾
+// 首次添加时原有的 api_key 会加密迁入集合作为主 Key，之后不再以明文保存
+func (s *service) AddUpstreamKey(ctx context.Context, id uint, req *AddUpstreamKeyRequest) ([]*UpstreamKeyResponse, error) {
+	config, err := s.findWritableConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(config.UpstreamKeys) == 0 && config.APIKey != "" {
+		legacy, err := s.newUpstreamKey(config.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		config.UpstreamKeys = UpstreamKeys{legacy}
+		config.APIKey = ""
+	}
+
+	key, err := s.newUpstreamKey(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	if req.Primary {
+		config.UpstreamKeys = append(UpstreamKeys{key}, config.UpstreamKeys...)
+	} else {
+		config.UpstreamKeys = append(config.UpstreamKeys, key)
+	}
+	return s.saveUpstreamKeys(ctx, config, "add")
+}
+
+// PromoteUpstreamKey 将 Key 提升为主 Key，其余 Key 保持原有顺序
+func (s *service) PromoteUpstreamKey(ctx context.Context, id uint, keyID string) ([]*UpstreamKeyResponse, error) {
+	config, err := s.findWritableConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := config.findUpstreamKey(keyID)
+	if i < 0 {
+		return nil, errors.ErrNotFound.WithDetails("Upstream key not found")
+	}
+
+	key := config.UpstreamKeys[i]
+	promoted := append(UpstreamKeys{key}, config.UpstreamKeys[:i]...)
+	config.UpstreamKeys = append(promoted, config.UpstreamKeys[i+1:]...)
+	return s.saveUpstreamKeys(ctx, config, "promote")
+}
+
+// RetireUpstreamKey 停用并删除 Key，配置至少保留一个 Key
+func (s *service) RetireUpstreamKey(ctx context.Context, id uint, keyID string) ([]*UpstreamKeyResponse, error) {
+	config, err := s.findWritableConfig(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	i := config.findUpstreamKey(keyID)
+	if i < 0 {
+		return nil, errors.ErrNotFound.WithDetails("Upstream key not found")
+	}
+	if len(config.UpstreamKeys) == 1 {
+		return nil, errors.ErrInvalidParam.WithDetails("Cannot retire the only upstream key, add a replacement first")
+	}
+
+	retired := make(UpstreamKeys, 0, len(config.UpstreamKeys)-1)
+	retired = append(retired, config.UpstreamKeys[:i]...)
+	config.UpstreamKeys = append(retired, config.UpstreamKeys[i+1:]...)
+	return s.saveUpstreamKeys(ctx, config, "retire")
+}
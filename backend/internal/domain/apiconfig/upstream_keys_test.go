@@ -0,0 +1,28 @@
+package apiconfig
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"testing"
+)
+
+func TestUpdateConfig_RejectsAPIKeyOnceUpstreamKeysExist(t *testing.T) {
+	svc, repo := newCapabilityTestService(&fakeProber{})
+	repo.Create(context.Background(), &APIConfig{Name: "rotated", Type: "openai", IsActive: true,
+		UpstreamKeys: UpstreamKeys{{ID: "k1", Key: "enc:primary"}}})
+	repo.Create(context.Background(), &APIConfig{Name: "legacy", Type: "openai", IsActive: true, APIKey: "sk-old"})
+
+	if _, err := svc.UpdateConfig(context.Background(), 1, &UpdateConfigRequest{APIKey: "sk-new"}); !errors.Is(err, errors.ErrInvalidParam) {
+		t.Errorf("Expected api_key updates rejected for a config using upstream keys, got %v", err)
+	}
+	if repo.configs[1].APIKey != "" {
+		t.Errorf("Expected the ignored api_key not to be stored, got %q", repo.configs[1].APIKey)
+	}
+
+	if _, err := svc.UpdateConfig(context.Background(), 2, &UpdateConfigRequest{APIKey: "sk-new"}); err != nil {
+		t.Fatalf("Expected legacy configs to accept api_key updates, got %v", err)
+	}
+	if repo.configs[2].APIKey != "sk-new" {
+		t.Errorf("Expected the legacy api_key replaced, got %q", repo.configs[2].APIKey)
+	}
+}
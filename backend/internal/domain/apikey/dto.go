@@ -4,8 +4,34 @@ import "time"
 
 // CreateAPIKeyRequest 创建API密钥请求
 type CreateAPIKeyRequest struct {
-	Name      string `json:"name" binding:"required,min=1,max=100"`
-	RateLimit int    `json:"rate_limit" binding:"omitempty,min=1,max=10000"`
+	Name              string            `json:"name" binding:"required,min=1,max=100"`
+	RateLimit         int               `json:"rate_limit" binding:"omitempty,min=1,max=10000"`
+	RedactionEnabled  bool              `json:"redaction_enabled"`
+	RedactionPatterns []string          `json:"redaction_patterns" binding:"omitempty,max=50"`
+	LogRequests       *bool             `json:"log_requests" binding:"omitempty"` // 默认 true
+	Metadata          map[string]string `json:"metadata" binding:"omitempty,max=16"`
+	LengthRouting     []LengthRoute     `json:"length_routing" binding:"omitempty,max=10,dive"`
+	RateLimitPerHour  int               `json:"rate_limit_per_hour" binding:"omitempty,min=0,max=1000000"`
+	RateLimitPerDay   int               `json:"rate_limit_per_day" binding:"omitempty,min=0,max=10000000"`
+	StreamPacingTPS   int               `json:"stream_pacing_tps" binding:"omitempty,min=0,max=10000"`
+	RoutingMode       string            `json:"routing_mode" binding:"omitempty,oneof=cost latency"`
+
+	SemanticCacheDisabled bool `json:"semantic_cache_disabled"`
+
+	MaxCandidates int `json:"max_candidates" binding:"omitempty,min=0,max=8"`
+
+	// 临时密钥：expires_at 指定过期时间，或 expires_in 指定有效期（秒），两者都为空表示永不过期
+	ExpiresAt *time.Time `json:"expires_at" binding:"omitempty"`
+	ExpiresIn int        `json:"expires_in" binding:"omitempty,min=60,max=31536000"`
+
+	MaxHistoryMessages int    `json:"max_history_messages" binding:"omitempty,min=0,max=10000"`
+	HistoryLimitPolicy string `json:"history_limit_policy" binding:"omitempty,oneof=reject truncate"`
+
+	MaxContextTokens  int    `json:"max_context_tokens" binding:"omitempty,min=0"`
+	ContextTruncation string `json:"context_truncation" binding:"omitempty,oneof=drop_oldest summarize"`
+
+	ErrorFormat   string `json:"error_format" binding:"omitempty,oneof=openai anthropic gemini custom"`
+	ErrorTemplate string `json:"error_template" binding:"omitempty,max=4096"` // error_format 为 custom 时必填
 }
 
 // UpdateAPIKeyRequest 更新API密钥请求
@@ -13,6 +39,29 @@ type UpdateAPIKeyRequest struct {
 	Name      string `json:"name" binding:"omitempty,min=1,max=100"`
 	RateLimit int    `json:"rate_limit" binding:"omitempty,min=1,max=10000"`
 	IsActive  *bool  `json:"is_active" binding:"omitempty"`
+
+	RedactionEnabled  *bool             `json:"redaction_enabled" binding:"omitempty"`
+	RedactionPatterns []string          `json:"redaction_patterns" binding:"omitempty,max=50"`
+	LogRequests       *bool             `json:"log_requests" binding:"omitempty"`
+	Metadata          map[string]string `json:"metadata" binding:"omitempty,max=16"`            // 传空对象清除
+	LengthRouting     []LengthRoute     `json:"length_routing" binding:"omitempty,max=10,dive"` // 传空数组关闭
+	RateLimitPerHour  *int              `json:"rate_limit_per_hour" binding:"omitempty,min=0,max=1000000"` // 传 0 恢复系统默认值
+	RateLimitPerDay   *int              `json:"rate_limit_per_day" binding:"omitempty,min=0,max=10000000"`
+	StreamPacingTPS   *int              `json:"stream_pacing_tps" binding:"omitempty,min=0,max=10000"` // 传 0 关闭
+	RoutingMode       *string           `json:"routing_mode" binding:"omitempty,oneof=cost latency"`  // 传空字符串恢复负载均衡策略
+
+	SemanticCacheDisabled *bool `json:"semantic_cache_disabled" binding:"omitempty"`
+
+	MaxCandidates *int `json:"max_candidates" binding:"omitempty,min=0,max=8"` // 传 0 关闭多候选采样
+
+	MaxHistoryMessages *int    `json:"max_history_messages" binding:"omitempty,min=0,max=10000"` // 传 0 取消上限
+	HistoryLimitPolicy *string `json:"history_limit_policy" binding:"omitempty,oneof='' reject truncate"`
+
+	MaxContextTokens  *int    `json:"max_context_tokens" binding:"omitempty,min=0"`                          // 传 0 取消上限
+	ContextTruncation *string `json:"context_truncation" binding:"omitempty,oneof='' drop_oldest summarize"` // 传空字符串沿用配置
+
+	ErrorFormat   *string `json:"error_format" binding:"omitempty,oneof='' openai anthropic gemini custom"` // 传空字符串恢复默认格式
+	ErrorTemplate *string `json:"error_template" binding:"omitempty,max=4096"`
 }
 
 // GetAPIKeysRequest 获取API密钥列表请求
@@ -30,8 +79,41 @@ type APIKeyResponse struct {
 	IsActive   bool       `json:"is_active"`
 	RateLimit  int        `json:"rate_limit"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+
+	RedactionEnabled  bool              `json:"redaction_enabled"`
+	RedactionPatterns []string          `json:"redaction_patterns"`
+	LogRequests       bool              `json:"log_requests"`
+	Metadata          map[string]string `json:"metadata,omitempty"`
+	LengthRouting     []LengthRoute     `json:"length_routing,omitempty"`
+	RateLimitPerHour  int               `json:"rate_limit_per_hour"`
+	RateLimitPerDay   int               `json:"rate_limit_per_day"`
+	ParamOverrides    ParamOverrides    `json:"param_overrides,omitempty"`
+	StreamPacingTPS   int               `json:"stream_pacing_tps"`
+	RoutingMode       string            `json:"routing_mode,omitempty"`
+
+	SemanticCacheDisabled bool `json:"semantic_cache_disabled"`
+
+	MaxCandidates int `json:"max_candidates"`
+
+	MaxHistoryMessages int    `json:"max_history_messages"`
+	HistoryLimitPolicy string `json:"history_limit_policy,omitempty"`
+
+	MaxContextTokens  int    `json:"max_context_tokens"`
+	ContextTruncation string `json:"context_truncation,omitempty"`
+
+	ErrorFormat   string `json:"error_format,omitempty"`
+	ErrorTemplate string `json:"error_template,omitempty"`
+
+	Suspended bool `json:"suspended"`
+}
+
+// SetParamOverridesRequest 设置参数覆盖请求，整体替换（管理员锁定的规则除外）
+type SetParamOverridesRequest struct {
+	ParamOverrides ParamOverrides `json:"param_overrides" binding:"omitempty,max=3,dive"`
 }
 
 // APIKeyListResponse API密钥列表响应
@@ -56,8 +138,36 @@ func (k *APIKey) ToResponse() *APIKeyResponse {
 		IsActive:   k.IsActive,
 		RateLimit:  k.RateLimit,
 		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+		Expired:    k.IsExpired(time.Now()),
 		CreatedAt:  k.CreatedAt,
 		UpdatedAt:  k.UpdatedAt,
+
+		RedactionEnabled:  k.RedactionEnabled,
+		RedactionPatterns: k.RedactionPatterns,
+		LogRequests:       k.LogRequests,
+		Metadata:          k.Metadata,
+		LengthRouting:     k.LengthRouting,
+		RateLimitPerHour:  k.RateLimitPerHour,
+		RateLimitPerDay:   k.RateLimitPerDay,
+		ParamOverrides:    k.ParamOverrides,
+		StreamPacingTPS:   k.StreamPacingTPS,
+		RoutingMode:       k.RoutingMode,
+
+		SemanticCacheDisabled: k.SemanticCacheDisabled,
+
+		MaxCandidates: k.MaxCandidates,
+
+		MaxHistoryMessages: k.MaxHistoryMessages,
+		HistoryLimitPolicy: k.HistoryLimitPolicy,
+
+		MaxContextTokens:  k.MaxContextTokens,
+		ContextTruncation: k.ContextTruncation,
+
+		ErrorFormat:   k.ErrorFormat,
+		ErrorTemplate: k.ErrorTemplate,
+
+		Suspended: k.Suspended,
 	}
 }
 
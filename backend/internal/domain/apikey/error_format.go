@@ -0,0 +1,55 @@
+package apikey
+
+import (
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/errors"
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateErrorFormat 自定义错误格式必须提供能渲染为合法 JSON 的模板
+func validateErrorFormat(format, template string) error {
+	if format != ErrorFormatCustom {
+		return nil
+	}
+	if template == "" {
+		return errors.ErrInvalidParam.WithDetails("error_template is required when error_format is custom")
+	}
+	if err := protocol.ValidateErrorTemplate(template); err != nil {
+		return errors.ErrInvalidParam.WithDetails(err.Error())
+	}
+	return nil
+}
+
+// RenderError 按 Key 配置的错误格式渲染错误响应体，未配置格式或模板渲染失败时返回 false
+func (k *APIKey) RenderError(apiErr *protocol.APIError) ([]byte, bool) {
+	switch k.ErrorFormat {
+	case "":
+		return nil, false
+	case ErrorFormatCustom:
+		body, err := protocol.RenderErrorTemplate(k.ErrorTemplate, apiErr)
+		return body, err == nil
+	}
+	body, err := json.Marshal(protocol.ErrorBody(protocol.Protocol(k.ErrorFormat), apiErr))
+	return body, err == nil
+}
+
+// WriteFormattedError 当前请求的 API Key 配置了错误格式时按该格式输出错误，返回是否已输出
+// 未配置时由调用方按各接口的默认格式输出，保证同一个 Key 在所有代理接口上收到一致的错误结构
+func WriteFormattedError(c *gin.Context, apiErr *protocol.APIError) bool {
+	info, ok := c.Get("api_key_info")
+	if !ok {
+		return false
+	}
+	key, ok := info.(*APIKey)
+	if !ok {
+		return false
+	}
+	body, ok := key.RenderError(apiErr)
+	if !ok {
+		return false
+	}
+	c.Data(apiErr.Status, "application/json; charset=utf-8", body)
+	return true
+}
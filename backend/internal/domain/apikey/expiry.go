@@ -0,0 +1,77 @@
+package apikey
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"time"
+)
+
+// expirySweepInterval 过期密钥清理间隔
+const expirySweepInterval = time.Hour
+
+// resolveExpiry 根据创建请求确定密钥的过期时间，expires_at 与 expires_in 只能指定一个，都未指定时返回 nil（永不过期）
+func resolveExpiry(expiresAt *time.Time, expiresIn int, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && expiresIn > 0:
+		return nil, errors.ErrInvalidParam.WithDetails("Specify either expires_at or expires_in, not both")
+	case expiresIn > 0:
+		at := now.Add(time.Duration(expiresIn) * time.Second)
+		return &at, nil
+	case expiresAt != nil && !expiresAt.After(now):
+		return nil, errors.ErrInvalidParam.WithDetails("expires_at must be in the future")
+	}
+	return expiresAt, nil
+}
+
+// ExpirySweeper 定期软删除过期超过保留期的密钥，保留期由运行时配置 runtime.expired_key_retention_days 控制
+type ExpirySweeper struct {
+	repo          Repository
+	runtimeConfig *runtime.Manager
+	logger        logger.Logger
+}
+
+// NewExpirySweeper 创建过期密钥清理任务
+func NewExpirySweeper(repo Repository, runtimeConfig *runtime.Manager, logger logger.Logger) *ExpirySweeper {
+	return &ExpirySweeper{
+		repo:          repo,
+		runtimeConfig: runtimeConfig,
+		logger:        logger,
+	}
+}
+
+// Start 按间隔清理过期密钥，直到 ctx 取消
+func (s *ExpirySweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+
+	s.logger.Info("Expired API key sweeper started", logger.Duration("interval", expirySweepInterval))
+
+	for {
+		select {
+		case <-ticker.C:
+			s.RunOnce(ctx, time.Now())
+		case <-ctx.Done():
+			s.logger.Info("Expired API key sweeper stopped")
+			return
+		}
+	}
+}
+
+// RunOnce 软删除截至 now 过期超过保留期的密钥，返回删除的数量；保留期为 0 时不清理
+func (s *ExpirySweeper) RunOnce(ctx context.Context, now time.Time) int64 {
+	retention := s.runtimeConfig.Get().GetExpiredKeyRetention()
+	if retention <= 0 {
+		return 0
+	}
+	deleted, err := s.repo.SoftDeleteExpiredBefore(ctx, now.Add(-retention))
+	if err != nil {
+		s.logger.Error("Failed to sweep expired API keys", logger.Error(err))
+		return 0
+	}
+	if deleted > 0 {
+		s.logger.Info("Soft-deleted expired API keys", logger.Int64("count", deleted))
+	}
+	return deleted
+}
@@ -0,0 +1,161 @@
+package apikey
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/query"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"testing"
+	"time"
+)
+
+// expiryRepo 按创建顺序保存密钥，软删除的密钥不再返回
+type expiryRepo struct {
+	Repository
+	keys    []*APIKey
+	deleted map[uint]bool
+}
+
+func (r *expiryRepo) Create(ctx context.Context, apiKey *APIKey) error {
+	apiKey.ID = uint(len(r.keys) + 1)
+	r.keys = append(r.keys, apiKey)
+	return nil
+}
+
+func (r *expiryRepo) FindByKey(ctx context.Context, key string) (*APIKey, error) {
+	for _, k := range r.keys {
+		if k.Key == key && !r.deleted[k.ID] {
+			return k, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *expiryRepo) List(ctx context.Context, userID uint, filters []query.Filter, sorts []query.Sort, pagination *query.Pagination) ([]*APIKey, int64, error) {
+	var found []*APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID && !r.deleted[k.ID] {
+			found = append(found, k)
+		}
+	}
+	return found, int64(len(found)), nil
+}
+
+func (r *expiryRepo) UpdateLastUsedAt(ctx context.Context, id uint) error {
+	return nil
+}
+
+func (r *expiryRepo) SoftDeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var deleted int64
+	for _, k := range r.keys {
+		if k.ExpiresAt != nil && k.ExpiresAt.Before(cutoff) && !r.deleted[k.ID] {
+			r.deleted[k.ID] = true
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newExpiryTestService() (*service, *expiryRepo) {
+	repo := &expiryRepo{deleted: map[uint]bool{}}
+	return &service{repo: repo, logger: *logger.NewNop()}, repo
+}
+
+func TestValidateAPIKey_RejectsExpiredKeys(t *testing.T) {
+	svc, repo := newExpiryTestService()
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	repo.keys = []*APIKey{
+		{ID: 1, Key: "sk-expired", IsActive: true, ExpiresAt: &past},
+		{ID: 2, Key: "sk-valid", IsActive: true, ExpiresAt: &future},
+		{ID: 3, Key: "sk-permanent", IsActive: true},
+	}
+	ctx := context.Background()
+
+	if _, err := svc.ValidateAPIKey(ctx, "sk-expired"); !errors.Is(err, errors.ErrAPIKeyExpired) {
+		t.Errorf("Expected a key past its expires_at to fail validation, got %v", err)
+	}
+	for _, key := range []string{"sk-valid", "sk-permanent"} {
+		if _, err := svc.ValidateAPIKey(ctx, key); err != nil {
+			t.Errorf("Expected %s to validate, got %v", key, err)
+		}
+	}
+}
+
+func TestUpdateAPIKey_OwnerCannotLiftSuspension(t *testing.T) {
+	svc, repo := newOverridesTestService()
+	repo.keys[1].Suspended = true
+
+	active := true
+	if err := svc.UpdateAPIKey(context.Background(), 7, 1, &UpdateAPIKeyRequest{IsActive: &active}); err != nil {
+		t.Fatal(err)
+	}
+	if key := repo.keys[1]; !key.IsActive || !key.Suspended || key.IsValid() {
+		t.Errorf("Expected the key to stay suspended after the owner re-enabled it, got %+v", key)
+	}
+}
+
+func TestCreateAPIKey_ExpiresInIsReturnedInListing(t *testing.T) {
+	svc, _ := newExpiryTestService()
+	ctx := context.Background()
+
+	before := time.Now()
+	created, err := svc.CreateAPIKey(ctx, 7, &CreateAPIKeyRequest{Name: "ci", ExpiresIn: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CreateAPIKey(ctx, 7, &CreateAPIKeyRequest{Name: "permanent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt := created.APIKey.ExpiresAt
+	if expiresAt == nil || expiresAt.Before(before.Add(time.Hour)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("Expected the key to expire an hour after creation, got %v", expiresAt)
+	}
+
+	list, err := svc.GetAPIKeys(ctx, 7, &GetAPIKeysRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Keys) != 2 || list.Keys[0].ExpiresAt == nil || !list.Keys[0].ExpiresAt.Equal(*expiresAt) || list.Keys[0].Expired {
+		t.Errorf("Expected the expiry returned in the key listing, got %+v", list.Keys[0])
+	}
+	if list.Keys[1].ExpiresAt != nil {
+		t.Errorf("Expected no expiry on a permanent key, got %v", list.Keys[1].ExpiresAt)
+	}
+}
+
+func TestCreateAPIKey_RejectsInvalidExpiry(t *testing.T) {
+	svc, _ := newExpiryTestService()
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	for name, req := range map[string]*CreateAPIKeyRequest{
+		"past expires_at": {Name: "k", ExpiresAt: &past},
+		"both options":    {Name: "k", ExpiresAt: &future, ExpiresIn: 3600},
+	} {
+		if _, err := svc.CreateAPIKey(context.Background(), 7, req); !errors.Is(err, errors.ErrInvalidParam) {
+			t.Errorf("%s: expected invalid parameter, got %v", name, err)
+		}
+	}
+}
+
+func TestExpirySweeper_SoftDeletesKeysPastRetention(t *testing.T) {
+	now := time.Now()
+	longAgo, recently := now.Add(-40*24*time.Hour), now.Add(-time.Hour)
+	repo := &expiryRepo{deleted: map[uint]bool{}, keys: []*APIKey{
+		{ID: 1, ExpiresAt: &longAgo},
+		{ID: 2, ExpiresAt: &recently},
+		{ID: 3},
+	}}
+	rc := runtime.NewManager(nil)
+	sweeper := NewExpirySweeper(repo, rc, *logger.NewNop())
+
+	if deleted := sweeper.RunOnce(context.Background(), now); deleted != 0 {
+		t.Errorf("Expected no sweeping without a retention period, got %d", deleted)
+	}
+
+	rc.Get().ExpiredKeyRetention = 30 * 24 * time.Hour
+	if deleted := sweeper.RunOnce(context.Background(), now); deleted != 1 || !repo.deleted[1] {
+		t.Errorf("Expected only the long-expired key soft-deleted, got %d deleted: %v", deleted, repo.deleted)
+	}
+}
@@ -49,6 +49,10 @@ func (h *Handler) CreateAPIKey(c *gin.Context) {
 
 	resp, err := h.service.CreateAPIKey(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, "Invalid redaction pattern", err.(*errors.AppError).Details)
+			return
+		}
 		response.InternalError(c, err)
 		return
 	}
@@ -187,6 +191,11 @@ func (h *Handler) UpdateAPIKey(c *gin.Context) {
 			response.Forbidden(c, err.Error())
 			return
 		}
+		// 脱敏正则或错误响应格式不合法
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, "Invalid API key settings", err.(*errors.AppError).Details)
+			return
+		}
 		response.InternalError(c, err)
 		return
 	}
@@ -239,3 +248,97 @@ func (h *Handler) DeleteAPIKey(c *gin.Context) {
 
 	response.SuccessWithMessage(c, "API key deleted successfully", nil)
 }
+
+// SetParamOverrides 设置参数覆盖
+// @Summary 设置API密钥参数覆盖
+// @Description 为自己的API密钥设置 temperature、top_p、max_tokens 覆盖规则（force 总是生效，default 仅在客户端未传时生效，clamp 限制取值范围），管理员锁定的规则不能修改
+// @Tags APIKey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "密钥ID"
+// @Param request body SetParamOverridesRequest true "覆盖规则"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/apikeys/{id}/param-overrides [put]
+func (h *Handler) SetParamOverrides(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	id, req, ok := bindParamOverrides(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.service.SetParamOverrides(c.Request.Context(), userID.(uint), id, req.ParamOverrides)
+	if err != nil {
+		writeParamOverridesError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"api_key": key})
+}
+
+// AdminSetParamOverrides 管理员设置参数覆盖
+// @Summary 管理员设置API密钥参数覆盖
+// @Description 为任意API密钥设置参数覆盖规则，设置的规则对密钥所有者锁定（管理员）
+// @Tags APIKey
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "密钥ID"
+// @Param request body SetParamOverridesRequest true "覆盖规则"
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/apikeys/{id}/param-overrides [put]
+func (h *Handler) AdminSetParamOverrides(c *gin.Context) {
+	id, req, ok := bindParamOverrides(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.service.AdminSetParamOverrides(c.Request.Context(), id, req.ParamOverrides)
+	if err != nil {
+		writeParamOverridesError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"api_key": key})
+}
+
+// bindParamOverrides 解析路径中的密钥 ID 和请求体
+func bindParamOverrides(c *gin.Context) (uint, *SetParamOverridesRequest, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid API key ID", "API key ID must be a valid number")
+		return 0, nil, false
+	}
+
+	var req SetParamOverridesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return 0, nil, false
+	}
+	return uint(id), &req, true
+}
+
+// writeParamOverridesError 输出参数覆盖接口的错误
+func writeParamOverridesError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errors.ErrAPIKeyNotFound):
+		response.NotFound(c, "API key not found")
+	case errors.Is(err, errors.ErrForbidden):
+		response.Forbidden(c, err.Error())
+	case errors.Is(err, errors.ErrInvalidParam):
+		response.BadRequest(c, "Invalid param overrides", err.(*errors.AppError).Details)
+	default:
+		response.InternalError(c, err)
+	}
+}
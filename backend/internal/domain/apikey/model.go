@@ -1,9 +1,85 @@
 package apikey
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// StringArray 字符串数组类型（JSONB 存储）
+type StringArray []string
+
+func (s StringArray) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+func (s *StringArray) Scan(value interface{}) error {
+	if value == nil {
+		*s = []string{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// StringMap 字符串键值对类型（JSONB 存储）
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return json.Marshal(map[string]string{})
+	}
+	return json.Marshal(m)
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = StringMap{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// LengthRoute 按提示词长度路由规则：估算输入 token 不超过 MaxPromptTokens 时改用 Model，0 表示不限长度
+type LengthRoute struct {
+	MaxPromptTokens int    `json:"max_prompt_tokens" binding:"min=0"`
+	Model           string `json:"model" binding:"required,max=255"`
+}
+
+// LengthRoutes 按长度路由规则列表（JSONB 存储）
+type LengthRoutes []LengthRoute
+
+func (r LengthRoutes) Value() (driver.Value, error) {
+	if r == nil {
+		return json.Marshal([]LengthRoute{})
+	}
+	return json.Marshal(r)
+}
+
+func (r *LengthRoutes) Scan(value interface{}) error {
+	if value == nil {
+		*r = LengthRoutes{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, r)
+}
+
 // APIKey API瀵嗛挜妯″瀷
 type APIKey struct {
 	ID         uint           `gorm:"primarykey" json:"id"`
@@ -15,8 +91,73 @@ type APIKey struct {
 	IsActive   bool           `gorm:"not null;default:true" json:"is_active"`
 	RateLimit  int            `gorm:"not null;default:60" json:"rate_limit"`
 	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+
+	// 过期时间，为空表示永不过期；过期的密钥校验失败，过期超过保留期后由清理任务软删除
+	ExpiresAt *time.Time     `gorm:"index" json:"expires_at,omitempty"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 每小时、每日请求数上限，0 表示使用系统默认值（default_rate_limit.per_hour / per_day）
+	RateLimitPerHour int `gorm:"not null;default:0" json:"rate_limit_per_hour"`
+	RateLimitPerDay  int `gorm:"not null;default:0" json:"rate_limit_per_day"`
+
+	// 响应脱敏：启用后对返回内容应用内置检测器和自定义正则
+	RedactionEnabled  bool        `gorm:"not null;default:false" json:"redaction_enabled"`
+	RedactionPatterns StringArray `gorm:"type:jsonb" json:"redaction_patterns"`
+
+	// 关闭后不写 request_logs 明细（不设置 gorm default，保证创建时显式写入 false）
+	LogRequests bool `gorm:"not null" json:"log_requests"`
+
+	// 透传给上游的请求元数据（如 user_id），请求中的同名字段优先
+	Metadata StringMap `gorm:"type:jsonb" json:"metadata,omitempty"`
+
+	// 按提示词长度自动选择模型（为空不启用），短提示走快速模型、长提示走大上下文模型
+	LengthRouting LengthRoutes `gorm:"type:jsonb" json:"length_routing,omitempty"`
+
+	// 请求参数覆盖（temperature、top_p、max_tokens），在读取客户端参数后、估算费用前应用
+	ParamOverrides ParamOverrides `gorm:"type:jsonb" json:"param_overrides,omitempty"`
+
+	// 流式输出速率上限（tokens/秒），上游突发的数据块缓冲后匀速转发，0 表示不限速
+	StreamPacingTPS int `gorm:"not null;default:0" json:"stream_pacing_tps"`
+
+	// 多个配置提供同一模型时的选择方式：cost 优先最便宜的配置，latency 优先响应最快的配置，为空按负载均衡策略
+	RoutingMode string `gorm:"size:20;not null;default:''" json:"routing_mode"`
+
+	// 关闭语义缓存，只返回请求完全相同的缓存响应；请求头 X-Prism-Semantic-Cache 可按请求覆盖
+	SemanticCacheDisabled bool `gorm:"not null;default:false" json:"semantic_cache_disabled"`
+
+	// 多候选采样上限：请求体 candidates 参数最多把一次请求按权重分发给几个配置，0 表示不允许多候选
+	MaxCandidates int `gorm:"not null;default:0" json:"max_candidates"`
+
+	// 对话历史消息数上限（不含 system 消息），0 表示不限制；超出时按 HistoryLimitPolicy 拒绝（默认）或只保留最近的消息
+	MaxHistoryMessages int    `gorm:"not null;default:0" json:"max_history_messages"`
+	HistoryLimitPolicy string `gorm:"size:20;not null;default:''" json:"history_limit_policy"`
+
+	// 上下文 token 上限，0 表示沿用配置或模型的上下文窗口；超出时按 ContextTruncation（drop_oldest、summarize）截断，未设置策略时沿用配置
+	MaxContextTokens  int    `gorm:"not null;default:0" json:"max_context_tokens"`
+	ContextTruncation string `gorm:"size:20;not null;default:''" json:"context_truncation"`
+
+	// 代理接口的错误响应格式：openai、anthropic、gemini 或 custom（按 ErrorTemplate 渲染），为空时各接口使用默认格式
+	ErrorFormat   string `gorm:"size:20;not null;default:''" json:"error_format"`
+	ErrorTemplate string `gorm:"type:text" json:"error_template,omitempty"`
+
+	// 用量异常时被自动停用，与 IsActive 独立：用户启用密钥不会解除，只有管理员清除异常标记时解除
+	Suspended bool `gorm:"not null;default:false" json:"suspended"`
 }
 
+// 路由模式
+const (
+	RoutingModeCost    = "cost"
+	RoutingModeLatency = "latency"
+)
+
+// 错误响应格式
+const (
+	ErrorFormatOpenAI    = "openai"
+	ErrorFormatAnthropic = "anthropic"
+	ErrorFormatGemini    = "gemini"
+	ErrorFormatCustom    = "custom"
+)
+
 // TableName 鎸囧畾琛ㄥ悕
 func (APIKey) TableName() string {
 	return "api_keys"
@@ -24,7 +165,12 @@ func (APIKey) TableName() string {
 
 // IsValid 妫€鏌ュ瘑閽ユ槸鍚︽湁鏁?
 func (k *APIKey) IsValid() bool {
-	return k.IsActive
+	return k.IsActive && !k.Suspended
+}
+
+// IsExpired 密钥在 now 时是否已过期
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
 }
 
 // Activate 婵€娲诲瘑閽?
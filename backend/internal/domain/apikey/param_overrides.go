@@ -0,0 +1,185 @@
+package apikey
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// 参数覆盖模式
+const (
+	ParamOverrideForce   = "force"   // 总是使用 Value，忽略客户端传值
+	ParamOverrideDefault = "default" // 仅在客户端未传时使用 Value
+	ParamOverrideClamp   = "clamp"   // 将客户端传值限制在 [Min, Max] 内
+)
+
+// 支持覆盖的请求参数
+const (
+	ParamTemperature = "temperature"
+	ParamTopP        = "top_p"
+	ParamMaxTokens   = "max_tokens"
+)
+
+// ParamOverride 单个请求参数的覆盖规则
+type ParamOverride struct {
+	Mode  string   `json:"mode" binding:"required,oneof=force default clamp"`
+	Value *float64 `json:"value,omitempty"` // force、default 模式使用
+	Min   *float64 `json:"min,omitempty"`   // clamp 模式的下限，为空不限
+	Max   *float64 `json:"max,omitempty"`   // clamp 模式的上限，为空不限
+
+	// 由管理员设置的规则，用户不能修改或删除
+	Locked bool `json:"locked"`
+}
+
+// ParamOverrides 参数名到覆盖规则的映射（JSONB 存储）
+type ParamOverrides map[string]ParamOverride
+
+func (o ParamOverrides) Value() (driver.Value, error) {
+	if o == nil {
+		return json.Marshal(map[string]ParamOverride{})
+	}
+	return json.Marshal(o)
+}
+
+func (o *ParamOverrides) Scan(value interface{}) error {
+	if value == nil {
+		*o = ParamOverrides{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, o)
+}
+
+// validateParamOverrides 检查参数名和取值范围
+func validateParamOverrides(overrides ParamOverrides) error {
+	for param, o := range overrides {
+		if param != ParamTemperature && param != ParamTopP && param != ParamMaxTokens {
+			return fmt.Errorf("%s: unsupported parameter", param)
+		}
+		switch o.Mode {
+		case ParamOverrideForce, ParamOverrideDefault:
+			if o.Value == nil {
+				return fmt.Errorf("%s: value is required for %s mode", param, o.Mode)
+			}
+			if err := validateParamValue(param, *o.Value); err != nil {
+				return err
+			}
+		case ParamOverrideClamp:
+			if o.Min == nil && o.Max == nil {
+				return fmt.Errorf("%s: min or max is required for clamp mode", param)
+			}
+			for _, bound := range []*float64{o.Min, o.Max} {
+				if bound == nil {
+					continue
+				}
+				if err := validateParamValue(param, *bound); err != nil {
+					return err
+				}
+			}
+			if o.Min != nil && o.Max != nil && *o.Min > *o.Max {
+				return fmt.Errorf("%s: min must not exceed max", param)
+			}
+		default:
+			return fmt.Errorf("%s: mode must be one of force, default, clamp", param)
+		}
+	}
+	return nil
+}
+
+// validateParamValue 检查单个取值：采样参数不能为负，max_tokens 必须是正整数
+func validateParamValue(param string, value float64) error {
+	if param == ParamMaxTokens {
+		if value < 1 || value != float64(int(value)) {
+			return fmt.Errorf("%s: must be a positive integer", param)
+		}
+		return nil
+	}
+	if value < 0 {
+		return fmt.Errorf("%s: must not be negative", param)
+	}
+	return nil
+}
+
+// SetParamOverrides 用户设置自己密钥的参数覆盖，管理员锁定的规则保持不变
+func (s *service) SetParamOverrides(ctx context.Context, userID uint, id uint, overrides ParamOverrides) (*APIKeyResponse, error) {
+	apiKey, err := s.findAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.UserID != userID {
+		return nil, errors.ErrForbidden.WithDetails("You don't have permission to update this API key")
+	}
+
+	merged := make(ParamOverrides, len(overrides))
+	for param, o := range apiKey.ParamOverrides {
+		if o.Locked {
+			merged[param] = o
+		}
+	}
+	for param, o := range overrides {
+		if existing, ok := merged[param]; ok && existing.Locked {
+			return nil, errors.ErrForbidden.WithDetails(fmt.Sprintf("%s override is locked by an administrator", param))
+		}
+		o.Locked = false
+		merged[param] = o
+	}
+	return s.saveParamOverrides(ctx, apiKey, merged)
+}
+
+// AdminSetParamOverrides 管理员设置任意密钥的参数覆盖，设置的规则对用户锁定
+func (s *service) AdminSetParamOverrides(ctx context.Context, id uint, overrides ParamOverrides) (*APIKeyResponse, error) {
+	apiKey, err := s.findAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(ParamOverrides, len(overrides))
+	for param, o := range apiKey.ParamOverrides {
+		if !o.Locked {
+			merged[param] = o
+		}
+	}
+	for param, o := range overrides {
+		o.Locked = true
+		merged[param] = o
+	}
+	return s.saveParamOverrides(ctx, apiKey, merged)
+}
+
+func (s *service) findAPIKey(ctx context.Context, id uint) (*APIKey, error) {
+	apiKey, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get API key", logger.Uint("key_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get API key")
+	}
+	if apiKey == nil {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	return apiKey, nil
+}
+
+func (s *service) saveParamOverrides(ctx context.Context, apiKey *APIKey, overrides ParamOverrides) (*APIKeyResponse, error) {
+	if err := validateParamOverrides(overrides); err != nil {
+		return nil, errors.ErrInvalidParam.WithDetails(err.Error())
+	}
+	apiKey.ParamOverrides = overrides
+
+	if err := s.repo.Update(ctx, apiKey); err != nil {
+		s.logger.Error("Failed to update API key param overrides",
+			logger.Uint("key_id", apiKey.ID),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to update API key")
+	}
+
+	s.logger.Info("API key param overrides updated",
+		logger.Uint("key_id", apiKey.ID),
+		logger.Int("count", len(overrides)))
+
+	return apiKey.ToResponse(), nil
+}
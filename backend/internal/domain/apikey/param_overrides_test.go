@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"testing"
+)
+
+type fakeRepo struct {
+	Repository
+	keys map[uint]*APIKey
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id uint) (*APIKey, error) {
+	return r.keys[id], nil
+}
+
+func (r *fakeRepo) Update(ctx context.Context, apiKey *APIKey) error {
+	r.keys[apiKey.ID] = apiKey
+	return nil
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func newOverridesTestService() (*service, *fakeRepo) {
+	repo := &fakeRepo{keys: map[uint]*APIKey{1: {ID: 1, UserID: 7}}}
+	return &service{repo: repo, logger: *logger.NewNop()}, repo
+}
+
+func TestSetParamOverrides_AdminRulesAreLockedForUsers(t *testing.T) {
+	svc, repo := newOverridesTestService()
+	ctx := context.Background()
+
+	_, err := svc.AdminSetParamOverrides(ctx, 1, ParamOverrides{
+		ParamTemperature: {Mode: ParamOverrideForce, Value: float64Ptr(0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 用户不能修改锁定的规则
+	_, err = svc.SetParamOverrides(ctx, 7, 1, ParamOverrides{
+		ParamTemperature: {Mode: ParamOverrideForce, Value: float64Ptr(1)},
+	})
+	if !errors.Is(err, errors.ErrForbidden) {
+		t.Fatalf("Expected locked override to be rejected, got %v", err)
+	}
+
+	// 替换自己的规则时保留锁定的规则
+	resp, err := svc.SetParamOverrides(ctx, 7, 1, ParamOverrides{
+		ParamMaxTokens: {Mode: ParamOverrideDefault, Value: float64Ptr(512)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := repo.keys[1].ParamOverrides
+	if len(overrides) != 2 || !overrides[ParamTemperature].Locked || overrides[ParamMaxTokens].Locked || len(resp.ParamOverrides) != 2 {
+		t.Errorf("Expected the locked temperature kept next to the user's max_tokens, got %+v", overrides)
+	}
+
+	if _, err := svc.SetParamOverrides(ctx, 8, 1, nil); !errors.Is(err, errors.ErrForbidden) {
+		t.Errorf("Expected other users to be rejected, got %v", err)
+	}
+}
+
+func TestSetParamOverrides_Validation(t *testing.T) {
+	svc, _ := newOverridesTestService()
+
+	invalid := []ParamOverrides{
+		{"seed": {Mode: ParamOverrideForce, Value: float64Ptr(1)}},
+		{ParamTemperature: {Mode: ParamOverrideForce}},
+		{ParamTemperature: {Mode: ParamOverrideClamp}},
+		{ParamTopP: {Mode: ParamOverrideClamp, Min: float64Ptr(0.9), Max: float64Ptr(0.1)}},
+		{ParamMaxTokens: {Mode: ParamOverrideDefault, Value: float64Ptr(10.5)}},
+		{ParamTemperature: {Mode: ParamOverrideDefault, Value: float64Ptr(-1)}},
+	}
+	for _, overrides := range invalid {
+		if _, err := svc.SetParamOverrides(context.Background(), 7, 1, overrides); !errors.Is(err, errors.ErrInvalidParam) {
+			t.Errorf("Expected %+v to be rejected, got %v", overrides, err)
+		}
+	}
+}
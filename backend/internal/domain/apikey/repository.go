@@ -21,6 +21,7 @@ type Repository interface {
 	UpdateLastUsedAt(ctx context.Context, id uint) error
 	UpdateStatus(ctx context.Context, id uint, isActive bool) error
 	CountByUserID(ctx context.Context, userID uint) (int64, error)
+	SoftDeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error)
 }
 
 // repository API密钥仓储实现
@@ -137,3 +138,11 @@ func (r *repository) CountByUserID(ctx context.Context, userID uint) (int64, err
 		Count(&count).Error
 	return count, err
 }
+
+// SoftDeleteExpiredBefore 软删除在 cutoff 之前过期的API密钥，返回删除的数量
+func (r *repository) SoftDeleteExpiredBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("expires_at IS NOT NULL AND expires_at < ?", cutoff).
+		Delete(&APIKey{})
+	return result.RowsAffected, result.Error
+}
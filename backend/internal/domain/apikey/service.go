@@ -5,7 +5,9 @@ import (
 	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/logger"
 	"api-aggregator/backend/pkg/query"
+	"api-aggregator/backend/pkg/redact"
 	"context"
+	"time"
 )
 
 // Service API密钥服务接口
@@ -15,7 +17,11 @@ type Service interface {
 	GetAPIKeyByID(ctx context.Context, userID uint, id uint) (*APIKeyResponse, error)
 	UpdateAPIKey(ctx context.Context, userID uint, id uint, req *UpdateAPIKeyRequest) error
 	DeleteAPIKey(ctx context.Context, userID uint, id uint) error
-	ValidateAPIKey(ctx context.Context, key string) (userID uint, apiKeyID uint, err error)
+	ValidateAPIKey(ctx context.Context, key string) (*APIKey, error)
+
+	// 请求参数覆盖
+	SetParamOverrides(ctx context.Context, userID uint, id uint, overrides ParamOverrides) (*APIKeyResponse, error)
+	AdminSetParamOverrides(ctx context.Context, id uint, overrides ParamOverrides) (*APIKeyResponse, error)
 }
 
 // service API密钥服务实现
@@ -41,6 +47,20 @@ func (s *service) CreateAPIKey(ctx context.Context, userID uint, req *CreateAPIK
 		return nil, errors.Wrap(err, 500005, "Failed to generate API key")
 	}
 
+	// 校验脱敏正则
+	if err := redact.Validate(req.RedactionPatterns); err != nil {
+		return nil, errors.ErrInvalidParam.WithDetails(err.Error())
+	}
+
+	if err := validateErrorFormat(req.ErrorFormat, req.ErrorTemplate); err != nil {
+		return nil, err
+	}
+
+	expiresAt, err := resolveExpiry(req.ExpiresAt, req.ExpiresIn, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	// 设置默认速率限制
 	rateLimit := req.RateLimit
 	if rateLimit == 0 {
@@ -54,6 +74,31 @@ func (s *service) CreateAPIKey(ctx context.Context, userID uint, req *CreateAPIK
 		Name:      req.Name,
 		IsActive:  true,
 		RateLimit: rateLimit,
+
+		RedactionEnabled:  req.RedactionEnabled,
+		RedactionPatterns: req.RedactionPatterns,
+		LogRequests:       req.LogRequests == nil || *req.LogRequests,
+		Metadata:          req.Metadata,
+		LengthRouting:     req.LengthRouting,
+		RateLimitPerHour:  req.RateLimitPerHour,
+		RateLimitPerDay:   req.RateLimitPerDay,
+		StreamPacingTPS:   req.StreamPacingTPS,
+		RoutingMode:       req.RoutingMode,
+
+		SemanticCacheDisabled: req.SemanticCacheDisabled,
+
+		MaxCandidates: req.MaxCandidates,
+
+		ExpiresAt: expiresAt,
+
+		MaxHistoryMessages: req.MaxHistoryMessages,
+		HistoryLimitPolicy: req.HistoryLimitPolicy,
+
+		MaxContextTokens:  req.MaxContextTokens,
+		ContextTruncation: req.ContextTruncation,
+
+		ErrorFormat:   req.ErrorFormat,
+		ErrorTemplate: req.ErrorTemplate,
 	}
 
 	if err := s.repo.Create(ctx, apiKey); err != nil {
@@ -166,6 +211,67 @@ func (s *service) UpdateAPIKey(ctx context.Context, userID uint, id uint, req *U
 	if req.IsActive != nil {
 		apiKey.IsActive = *req.IsActive
 	}
+	if req.RedactionEnabled != nil {
+		apiKey.RedactionEnabled = *req.RedactionEnabled
+	}
+	if req.RedactionPatterns != nil {
+		if err := redact.Validate(req.RedactionPatterns); err != nil {
+			return errors.ErrInvalidParam.WithDetails(err.Error())
+		}
+		apiKey.RedactionPatterns = req.RedactionPatterns
+	}
+	if req.LogRequests != nil {
+		apiKey.LogRequests = *req.LogRequests
+	}
+	if req.Metadata != nil {
+		apiKey.Metadata = req.Metadata
+	}
+	if req.LengthRouting != nil {
+		apiKey.LengthRouting = req.LengthRouting
+	}
+	if req.RateLimitPerHour != nil {
+		apiKey.RateLimitPerHour = *req.RateLimitPerHour
+	}
+	if req.RateLimitPerDay != nil {
+		apiKey.RateLimitPerDay = *req.RateLimitPerDay
+	}
+	if req.StreamPacingTPS != nil {
+		apiKey.StreamPacingTPS = *req.StreamPacingTPS
+	}
+	if req.RoutingMode != nil {
+		apiKey.RoutingMode = *req.RoutingMode
+	}
+	if req.SemanticCacheDisabled != nil {
+		apiKey.SemanticCacheDisabled = *req.SemanticCacheDisabled
+	}
+	if req.MaxCandidates != nil {
+		apiKey.MaxCandidates = *req.MaxCandidates
+	}
+	if req.MaxHistoryMessages != nil {
+		apiKey.MaxHistoryMessages = *req.MaxHistoryMessages
+	}
+	if req.HistoryLimitPolicy != nil {
+		apiKey.HistoryLimitPolicy = *req.HistoryLimitPolicy
+	}
+	if req.MaxContextTokens != nil {
+		apiKey.MaxContextTokens = *req.MaxContextTokens
+	}
+	if req.ContextTruncation != nil {
+		apiKey.ContextTruncation = *req.ContextTruncation
+	}
+	if req.ErrorFormat != nil || req.ErrorTemplate != nil {
+		format, template := apiKey.ErrorFormat, apiKey.ErrorTemplate
+		if req.ErrorFormat != nil {
+			format = *req.ErrorFormat
+		}
+		if req.ErrorTemplate != nil {
+			template = *req.ErrorTemplate
+		}
+		if err := validateErrorFormat(format, template); err != nil {
+			return err
+		}
+		apiKey.ErrorFormat, apiKey.ErrorTemplate = format, template
+	}
 
 	// 保存更新
 	if err := s.repo.Update(ctx, apiKey); err != nil {
@@ -214,20 +320,26 @@ func (s *service) DeleteAPIKey(ctx context.Context, userID uint, id uint) error
 	return nil
 }
 
-// ValidateAPIKey 验证API密钥并返回用户ID和API Key ID
-func (s *service) ValidateAPIKey(ctx context.Context, key string) (userID uint, apiKeyID uint, err error) {
+// ValidateAPIKey 验证API密钥并返回密钥信息
+func (s *service) ValidateAPIKey(ctx context.Context, key string) (*APIKey, error) {
 	apiKey, err := s.repo.FindByKey(ctx, key)
 	if err != nil {
 		s.logger.Error("Failed to find API key", logger.Error(err))
-		return 0, 0, errors.Wrap(err, 500002, "Failed to find API key")
+		return nil, errors.Wrap(err, 500002, "Failed to find API key")
 	}
 	if apiKey == nil {
-		return 0, 0, errors.ErrAPIKeyNotFound
+		return nil, errors.ErrAPIKeyNotFound
 	}
 
 	// 检查密钥是否有效
+	if apiKey.Suspended {
+		return nil, errors.New(403001, "API key is suspended due to a usage anomaly")
+	}
 	if !apiKey.IsValid() {
-		return 0, 0, errors.New(403001, "API key is inactive or deleted")
+		return nil, errors.New(403001, "API key is inactive or deleted")
+	}
+	if apiKey.IsExpired(time.Now()) {
+		return nil, errors.ErrAPIKeyExpired
 	}
 
 	// 更新最后使用时间（异步，不影响主流程）
@@ -239,5 +351,5 @@ func (s *service) ValidateAPIKey(ctx context.Context, key string) (userID uint,
 		}
 	}()
 
-	return apiKey.UserID, apiKey.ID, nil
+	return apiKey, nil
 }
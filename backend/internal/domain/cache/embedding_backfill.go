@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/utils"
+	"context"
+	"time"
+)
+
+// embeddingBackfillBatch 每轮补全的最大缓存条数
+const embeddingBackfillBatch = 100
+
+// Embedder 生成文本向量
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+}
+
+// EmbeddingBackfiller 定期为缺少 embedding 的缓存补生成向量
+// 写入缓存时 embedding 服务不可用会留下无法参与语义匹配的记录，由此任务补全
+type EmbeddingBackfiller struct {
+	repo     Repository
+	embedder Embedder
+	interval time.Duration
+	logger   logger.Logger
+}
+
+// NewEmbeddingBackfiller 创建 embedding 补全任务
+func NewEmbeddingBackfiller(repo Repository, embedder Embedder, interval time.Duration, logger logger.Logger) *EmbeddingBackfiller {
+	return &EmbeddingBackfiller{
+		repo:     repo,
+		embedder: embedder,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start 按间隔运行补全，直到 ctx 取消
+func (b *EmbeddingBackfiller) Start(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	b.logger.Info("Embedding backfill started", logger.Duration("interval", b.interval))
+
+	for {
+		select {
+		case <-ticker.C:
+			b.RunOnce(ctx)
+		case <-ctx.Done():
+			b.logger.Info("Embedding backfill stopped")
+			return
+		}
+	}
+}
+
+// RunOnce 补全一批缺少 embedding 的缓存，返回成功补全的条数
+// 单条失败只记录日志，留待下一轮重试
+func (b *EmbeddingBackfiller) RunOnce(ctx context.Context) int {
+	caches, err := b.repo.FindMissingEmbedding(ctx, embeddingBackfillBatch)
+	if err != nil {
+		b.logger.Error("Failed to find caches missing embeddings", logger.Error(err))
+		return 0
+	}
+
+	filled := 0
+	for _, c := range caches {
+		if ctx.Err() != nil {
+			break
+		}
+		vec, err := b.embedder.Embed(ctx, c.QueryText)
+		if err != nil {
+			b.logger.Warn("Failed to backfill cache embedding",
+				logger.Uint("cache_id", c.ID),
+				logger.Error(err))
+			continue
+		}
+		embeddingJSON, err := utils.VectorToJSON(vec)
+		if err != nil {
+			continue
+		}
+		if err := b.repo.UpdateEmbedding(ctx, c.ID, embeddingJSON); err != nil {
+			b.logger.Warn("Failed to save backfilled embedding",
+				logger.Uint("cache_id", c.ID),
+				logger.Error(err))
+			continue
+		}
+		filled++
+	}
+
+	if filled > 0 {
+		b.logger.Info("Backfilled cache embeddings", logger.Int("count", filled))
+	}
+	return filled
+}
@@ -0,0 +1,76 @@
+package cache
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"errors"
+	"testing"
+)
+
+type backfillRepo struct {
+	Repository
+	caches []*RequestCache
+}
+
+func (r *backfillRepo) FindMissingEmbedding(ctx context.Context, limit int) ([]*RequestCache, error) {
+	var missing []*RequestCache
+	for _, c := range r.caches {
+		if !c.HasEmbedding() && c.QueryText != "" && len(missing) < limit {
+			missing = append(missing, c)
+		}
+	}
+	return missing, nil
+}
+
+func (r *backfillRepo) UpdateEmbedding(ctx context.Context, id uint, embedding string) error {
+	for _, c := range r.caches {
+		if c.ID == id {
+			c.Embedding = embedding
+		}
+	}
+	return nil
+}
+
+// fakeEmbedder 对 failFor 中的文本返回错误
+type fakeEmbedder struct {
+	failFor map[string]bool
+}
+
+func (e *fakeEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	if e.failFor[text] {
+		return nil, errors.New("embedding service unavailable")
+	}
+	return []float64{float64(len(text)), 1}, nil
+}
+
+func TestEmbeddingBackfill_PopulatesMissingEmbeddings(t *testing.T) {
+	repo := &backfillRepo{caches: []*RequestCache{
+		{ID: 1, QueryText: "hello"},
+		{ID: 2, QueryText: "already", Embedding: "[0.5,0.5]"},
+		{ID: 3, QueryText: "flaky"},
+		{ID: 4, QueryText: "world", Embedding: "null"},
+	}}
+	embedder := &fakeEmbedder{failFor: map[string]bool{"flaky": true}}
+	backfiller := NewEmbeddingBackfiller(repo, embedder, 0, *logger.NewNop())
+
+	if filled := backfiller.RunOnce(context.Background()); filled != 2 {
+		t.Errorf("Expected 2 embeddings backfilled, got %d", filled)
+	}
+	for _, id := range []int{0, 3} {
+		if !repo.caches[id].HasEmbedding() {
+			t.Errorf("Expected cache %d to have an embedding", repo.caches[id].ID)
+		}
+	}
+	if repo.caches[1].Embedding != "[0.5,0.5]" {
+		t.Errorf("Existing embedding was overwritten: %s", repo.caches[1].Embedding)
+	}
+	if repo.caches[2].HasEmbedding() {
+		t.Error("Failed embedding should stay missing")
+	}
+
+	// 下一轮 embedding 恢复后补全剩余记录
+	embedder.failFor = nil
+	if filled := backfiller.RunOnce(context.Background()); filled != 1 || !repo.caches[2].HasEmbedding() {
+		t.Errorf("Expected the failed row to be backfilled on the next run, got %d", filled)
+	}
+}
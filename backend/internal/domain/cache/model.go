@@ -6,19 +6,23 @@ import (
 
 // RequestCache 请求缓存模型
 type RequestCache struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `gorm:"index;not null" json:"user_id"`
-	CacheKey    string    `gorm:"type:varchar(32);uniqueIndex;not null" json:"cache_key"`
-	QueryText   string    `gorm:"type:text" json:"query_text"`
-	Embedding   string    `gorm:"type:text" json:"embedding"`
-	Model       string    `gorm:"type:varchar(100);index;not null" json:"model"`
-	Request     string    `gorm:"type:text;not null" json:"request"`
-	Response    string    `gorm:"type:text;not null" json:"response"`
-	TokensSaved int       `gorm:"not null;default:0" json:"tokens_saved"`
-	HitCount    int       `gorm:"not null;default:0" json:"hit_count"`
-	ExpiresAt   time.Time `gorm:"index;not null" json:"expires_at"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	UserID           uint       `gorm:"index;not null" json:"user_id"`
+	CacheKey         string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"cache_key"`
+	QueryText        string     `gorm:"type:text" json:"query_text"`
+	Embedding        string     `gorm:"type:text" json:"embedding"`
+	Model            string     `gorm:"type:varchar(100);index;not null" json:"model"`
+	Request          string     `gorm:"type:text;not null" json:"request"`
+	Response         string     `gorm:"type:text;not null" json:"response"`
+	TokensSaved      int        `gorm:"not null;default:0" json:"tokens_saved"`
+	PromptTokens     int        `gorm:"not null;default:0" json:"prompt_tokens"` // 原始响应的 token 用量，缓存命中时随响应返回
+	CompletionTokens int        `gorm:"not null;default:0" json:"completion_tokens"`
+	TotalTokens      int        `gorm:"not null;default:0" json:"total_tokens"`
+	HitCount         int        `gorm:"not null;default:0" json:"hit_count"`
+	ExpiresAt        time.Time  `gorm:"index;not null" json:"expires_at"`
+	StaleUntil       *time.Time `gorm:"index" json:"stale_until,omitempty"` // 过期后仍可返回旧响应并后台刷新的截止时间
+	CreatedAt        time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -26,6 +30,11 @@ func (RequestCache) TableName() string {
 	return "request_caches"
 }
 
+// IsStale 缓存已过期但仍处于 stale-while-revalidate 窗口内
+func (c *RequestCache) IsStale(now time.Time) bool {
+	return !now.Before(c.ExpiresAt) && c.StaleUntil != nil && now.Before(*c.StaleUntil)
+}
+
 // IsExpired 检查是否过期
 func (c *RequestCache) IsExpired() bool {
 	return time.Now().After(c.ExpiresAt)
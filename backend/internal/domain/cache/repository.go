@@ -16,11 +16,15 @@ type Repository interface {
 	Delete(ctx context.Context, id uint) error
 	FindByID(ctx context.Context, id uint) (*RequestCache, error)
 	FindByCacheKey(ctx context.Context, cacheKey string) (*RequestCache, error)
+	FindByCacheKeyAllowStale(ctx context.Context, cacheKey string) (*RequestCache, error)
 	FindByUserID(ctx context.Context, userID uint) ([]*RequestCache, error)
 	FindByModel(ctx context.Context, model string, limit int) ([]*RequestCache, error)
 	FindByUserAndModel(ctx context.Context, userID uint, model string) ([]*RequestCache, error)
 	List(ctx context.Context, filters []query.Filter, sorts []query.Sort, pagination *query.Pagination) ([]*RequestCache, int64, error)
 	IncrementHitCount(ctx context.Context, id uint) error
+	FindMissingEmbedding(ctx context.Context, limit int) ([]*RequestCache, error)
+	FindWarmCandidates(ctx context.Context, minHits int, expiresBefore time.Time, limit int) ([]*RequestCache, error)
+	UpdateEmbedding(ctx context.Context, id uint, embedding string) error
 	GetStats(ctx context.Context, userID *uint) (*CacheStatsResponse, error)
 	DeleteExpired(ctx context.Context) (int64, error)
 	DeleteByUserID(ctx context.Context, userID uint) (int64, error)
@@ -50,6 +54,7 @@ func (r *repository) Create(ctx context.Context, cache *RequestCache) error {
 		// 已存在，更新响应和过期时间
 		existing.Response = cache.Response
 		existing.ExpiresAt = cache.ExpiresAt
+		existing.StaleUntil = cache.StaleUntil
 		if cache.Embedding != "" {
 			existing.Embedding = cache.Embedding
 		}
@@ -98,6 +103,22 @@ func (r *repository) FindByCacheKey(ctx context.Context, cacheKey string) (*Requ
 	return &cache, nil
 }
 
+// FindByCacheKeyAllowStale 根据缓存键查找缓存，包含已过期但仍在 stale 窗口内的记录
+func (r *repository) FindByCacheKeyAllowStale(ctx context.Context, cacheKey string) (*RequestCache, error) {
+	var cache RequestCache
+	now := time.Now()
+	err := r.db.WithContext(ctx).
+		Where("cache_key = ? AND (expires_at > ? OR stale_until > ?)", cacheKey, now, now).
+		First(&cache).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cache, nil
+}
+
 // FindByUserID 根据用户ID查找所有缓存
 func (r *repository) FindByUserID(ctx context.Context, userID uint) ([]*RequestCache, error) {
 	var caches []*RequestCache
@@ -164,6 +185,41 @@ func (r *repository) IncrementHitCount(ctx context.Context, id uint) error {
 		UpdateColumn("hit_count", gorm.Expr("hit_count + ?", 1)).Error
 }
 
+// FindMissingEmbedding 查找有查询文本但没有 embedding 的未过期缓存
+func (r *repository) FindMissingEmbedding(ctx context.Context, limit int) ([]*RequestCache, error) {
+	var caches []*RequestCache
+	err := r.db.WithContext(ctx).
+		Where("expires_at > ? AND query_text <> '' AND (embedding IS NULL OR embedding = '' OR embedding = 'null')", time.Now()).
+		Order("id ASC").
+		Limit(limit).
+		Find(&caches).Error
+	if err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+// FindWarmCandidates 查找命中次数达到 minHits、将在 expiresBefore 之前到期的未过期缓存，命中次数多的在前
+func (r *repository) FindWarmCandidates(ctx context.Context, minHits int, expiresBefore time.Time, limit int) ([]*RequestCache, error) {
+	var caches []*RequestCache
+	err := r.db.WithContext(ctx).
+		Where("hit_count >= ? AND expires_at > ? AND expires_at <= ?", minHits, time.Now(), expiresBefore).
+		Order("hit_count DESC, expires_at ASC").
+		Limit(limit).
+		Find(&caches).Error
+	if err != nil {
+		return nil, err
+	}
+	return caches, nil
+}
+
+// UpdateEmbedding 只更新 embedding 列，避免覆盖并发写入的其他字段
+func (r *repository) UpdateEmbedding(ctx context.Context, id uint, embedding string) error {
+	return r.db.WithContext(ctx).Model(&RequestCache{}).
+		Where("id = ?", id).
+		UpdateColumn("embedding", embedding).Error
+}
+
 // GetStats 获取缓存统计
 func (r *repository) GetStats(ctx context.Context, userID *uint) (*CacheStatsResponse, error) {
 	query := r.db.WithContext(ctx).Model(&RequestCache{}).
@@ -195,10 +251,11 @@ func (r *repository) GetStats(ctx context.Context, userID *uint) (*CacheStatsRes
 	}, nil
 }
 
-// DeleteExpired 删除过期缓存
+// DeleteExpired 删除过期缓存（保留仍在 stale 窗口内的记录）
 func (r *repository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
 	result := r.db.WithContext(ctx).
-		Where("expires_at < ?", time.Now()).
+		Where("expires_at < ? AND (stale_until IS NULL OR stale_until < ?)", now, now).
 		Delete(&RequestCache{})
 	return result.RowsAffected, result.Error
 }
@@ -18,6 +18,7 @@ type Service interface {
 	
 	// 缓存查询和存储
 	FindByCacheKey(ctx context.Context, cacheKey string) (*RequestCache, error)
+	FindByCacheKeyAllowStale(ctx context.Context, cacheKey string) (*RequestCache, error)
 	FindByUserAndModel(ctx context.Context, userID uint, model string) ([]*RequestCache, error)
 	IncrementHitCount(ctx context.Context, id uint) error
 	CreateCacheWithEmbedding(ctx context.Context, cache *RequestCache, embedding []float64) error
@@ -167,6 +168,11 @@ func (s *service) FindByCacheKey(ctx context.Context, cacheKey string) (*Request
 	return s.repo.FindByCacheKey(ctx, cacheKey)
 }
 
+// FindByCacheKeyAllowStale 根据缓存键查找缓存（包含 stale 窗口内的记录）
+func (s *service) FindByCacheKeyAllowStale(ctx context.Context, cacheKey string) (*RequestCache, error) {
+	return s.repo.FindByCacheKeyAllowStale(ctx, cacheKey)
+}
+
 // FindByUserAndModel 根据用户和模型查找缓存
 func (s *service) FindByUserAndModel(ctx context.Context, userID uint, model string) ([]*RequestCache, error) {
 	return s.repo.FindByUserAndModel(ctx, userID, model)
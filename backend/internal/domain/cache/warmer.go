@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"sync"
+	"time"
+)
+
+// warmInterval 预热检查间隔，应小于预热提前量，保证热门缓存在到期前至少被检查一次
+const warmInterval = 5 * time.Minute
+
+// Refresher 重新请求上游并刷新一条缓存
+type Refresher interface {
+	WarmCache(ctx context.Context, entry *RequestCache, billingUserID uint) error
+}
+
+// Warmer 定期刷新即将到期的热门缓存，使常用提示词始终命中缓存
+// 只在非高峰时段运行，每天的预热调用数受预算限制，策略由运行时配置 cache_warming.* 控制
+type Warmer struct {
+	repo          Repository
+	refresher     Refresher
+	runtimeConfig *runtime.Manager
+	logger        logger.Logger
+
+	mu       sync.Mutex
+	budgetOn string // 已用预算所属的日期
+	spent    int
+}
+
+// NewWarmer 创建缓存预热任务
+func NewWarmer(repo Repository, refresher Refresher, runtimeConfig *runtime.Manager, logger logger.Logger) *Warmer {
+	return &Warmer{
+		repo:          repo,
+		refresher:     refresher,
+		runtimeConfig: runtimeConfig,
+		logger:        logger,
+	}
+}
+
+// Start 按间隔运行预热，直到 ctx 取消；策略未启用时跳过
+func (w *Warmer) Start(ctx context.Context) {
+	ticker := time.NewTicker(warmInterval)
+	defer ticker.Stop()
+
+	w.logger.Info("Cache warming started", logger.Duration("interval", warmInterval))
+
+	for {
+		select {
+		case <-ticker.C:
+			w.RunOnce(ctx, time.Now())
+		case <-ctx.Done():
+			w.logger.Info("Cache warming stopped")
+			return
+		}
+	}
+}
+
+// RunOnce 刷新截至 now 即将到期的热门缓存，返回成功刷新的条数
+// 失败的调用同样消耗预算，避免上游故障时反复重试
+func (w *Warmer) RunOnce(ctx context.Context, now time.Time) int {
+	policy := w.runtimeConfig.Get().GetCacheWarmingPolicy()
+	if !policy.Enabled || policy.DailyBudget <= 0 || !inOffPeak(now, policy.OffPeakStartHour, policy.OffPeakEndHour) {
+		return 0
+	}
+	remaining := w.remainingBudget(now, policy.DailyBudget)
+	if remaining <= 0 {
+		return 0
+	}
+
+	candidates, err := w.repo.FindWarmCandidates(ctx, policy.MinHits, now.Add(policy.LeadTime), remaining)
+	if err != nil {
+		w.logger.Error("Failed to find cache entries to warm", logger.Error(err))
+		return 0
+	}
+
+	warmed := 0
+	for _, entry := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		w.spend()
+		if err := w.refresher.WarmCache(ctx, entry, policy.BillingUserID); err != nil {
+			w.logger.Warn("Failed to warm cache entry",
+				logger.Uint("cache_id", entry.ID),
+				logger.String("model", entry.Model),
+				logger.Error(err))
+			continue
+		}
+		warmed++
+	}
+
+	if warmed > 0 {
+		w.logger.Info("Warmed popular cache entries", logger.Int("count", warmed))
+	}
+	return warmed
+}
+
+// remainingBudget 返回当天剩余的预热调用数，跨天时重置
+func (w *Warmer) remainingBudget(now time.Time, daily int) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if day := now.Format("2006-01-02"); day != w.budgetOn {
+		w.budgetOn = day
+		w.spent = 0
+	}
+	return daily - w.spent
+}
+
+func (w *Warmer) spend() {
+	w.mu.Lock()
+	w.spent++
+	w.mu.Unlock()
+}
+
+// inOffPeak 判断 now 是否在 [start, end) 小时内，start > end 时跨零点，两者相等表示全天
+func inOffPeak(now time.Time, start, end int) bool {
+	if start == end {
+		return true
+	}
+	hour := now.Hour()
+	if start < end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+type warmRepo struct {
+	Repository
+	caches []*RequestCache
+}
+
+func (r *warmRepo) FindWarmCandidates(ctx context.Context, minHits int, expiresBefore time.Time, limit int) ([]*RequestCache, error) {
+	var found []*RequestCache
+	for _, c := range r.caches {
+		if c.HitCount >= minHits && !c.ExpiresAt.After(expiresBefore) {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].HitCount > found[j].HitCount })
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// fakeRefresher 刷新时把缓存的到期时间顺延一小时
+type fakeRefresher struct {
+	warmed  []uint
+	billing []uint
+}
+
+func (f *fakeRefresher) WarmCache(ctx context.Context, entry *RequestCache, billingUserID uint) error {
+	f.warmed = append(f.warmed, entry.ID)
+	f.billing = append(f.billing, billingUserID)
+	entry.ExpiresAt = entry.ExpiresAt.Add(time.Hour)
+	return nil
+}
+
+func newTestWarmer(repo Repository, refresher Refresher, policy runtime.CacheWarmingPolicy) *Warmer {
+	rc := runtime.NewManager(nil)
+	rc.Get().CacheWarming = policy
+	return NewWarmer(repo, refresher, rc, *logger.NewNop())
+}
+
+func TestWarmer_RefreshesPopularEntriesAheadOfExpiryWithinBudget(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 55, 0, 0, time.Local)
+	soon := now.Add(5 * time.Minute)
+	repo := &warmRepo{caches: []*RequestCache{
+		{ID: 1, HitCount: 50, ExpiresAt: soon},
+		{ID: 2, HitCount: 2, ExpiresAt: soon},                    // 命中次数不足
+		{ID: 3, HitCount: 80, ExpiresAt: now.Add(2 * time.Hour)}, // 离到期还远
+		{ID: 4, HitCount: 30, ExpiresAt: soon},
+		{ID: 5, HitCount: 10, ExpiresAt: now.Add(14 * time.Minute)},
+	}}
+	refresher := &fakeRefresher{}
+	w := newTestWarmer(repo, refresher, runtime.CacheWarmingPolicy{
+		Enabled:          true,
+		LeadTime:         20 * time.Minute,
+		MinHits:          5,
+		DailyBudget:      2,
+		OffPeakStartHour: 22,
+		OffPeakEndHour:   6,
+		BillingUserID:    99,
+	})
+
+	if warmed := w.RunOnce(context.Background(), now); warmed != 2 {
+		t.Fatalf("Expected 2 entries warmed within the budget, got %d", warmed)
+	}
+	if len(refresher.warmed) != 2 || refresher.warmed[0] != 1 || refresher.warmed[1] != 4 {
+		t.Errorf("Expected the most hit entries expiring soon to be warmed first, got %v", refresher.warmed)
+	}
+	if refresher.billing[0] != 99 {
+		t.Errorf("Expected warming billed to the configured system account, got %d", refresher.billing[0])
+	}
+	if !repo.caches[0].ExpiresAt.After(soon) {
+		t.Error("Expected the warmed entry refreshed before expiry")
+	}
+
+	// 当天预算已用完，剩余候选在零点预算重置后刷新，仍早于其到期时间
+	if warmed := w.RunOnce(context.Background(), now.Add(time.Minute)); warmed != 0 {
+		t.Errorf("Expected no warming once the daily budget is spent, got %d", warmed)
+	}
+	if warmed := w.RunOnce(context.Background(), now.Add(10*time.Minute)); warmed != 1 || refresher.warmed[2] != 5 {
+		t.Errorf("Expected the budget to reset the next day, got %d warmed: %v", warmed, refresher.warmed)
+	}
+}
+
+func TestWarmer_SkipsWhenDisabledOrOutsideOffPeak(t *testing.T) {
+	now := time.Date(2026, 3, 1, 14, 0, 0, 0, time.Local)
+	repo := &warmRepo{caches: []*RequestCache{{ID: 1, HitCount: 50, ExpiresAt: now.Add(time.Minute)}}}
+	policy := runtime.CacheWarmingPolicy{LeadTime: 10 * time.Minute, MinHits: 5, DailyBudget: 10}
+
+	refresher := &fakeRefresher{}
+	if warmed := newTestWarmer(repo, refresher, policy).RunOnce(context.Background(), now); warmed != 0 {
+		t.Errorf("Expected warming to be opt-in, got %d warmed", warmed)
+	}
+
+	policy.Enabled = true
+	policy.OffPeakStartHour, policy.OffPeakEndHour = 22, 6
+	if warmed := newTestWarmer(repo, refresher, policy).RunOnce(context.Background(), now); warmed != 0 {
+		t.Errorf("Expected no warming during peak hours, got %d warmed", warmed)
+	}
+	if warmed := newTestWarmer(repo, refresher, policy).RunOnce(context.Background(), now.Add(10*time.Hour)); warmed != 1 {
+		t.Errorf("Expected warming in an off-peak window spanning midnight, got %d warmed", warmed)
+	}
+}
@@ -0,0 +1,197 @@
+package configsync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File 声明式配置文件（YAML 或 JSON），描述期望存在的 API 配置及其定价
+type File struct {
+	Configs []ConfigSpec `yaml:"configs"`
+}
+
+// ConfigSpec 单个 API 配置，按名称与数据库中的配置对应
+// APIKey 支持 ${ENV} 引用环境变量，也可以用 APIKeyFile 从挂载的密钥文件（如 /run/secrets/...）读取
+type ConfigSpec struct {
+	Name          string            `yaml:"name"`
+	Type          string            `yaml:"type"`
+	ConfigType    string            `yaml:"config_type"`
+	AccountPoolID *uint             `yaml:"account_pool_id"`
+	BaseURL       string            `yaml:"base_url"`
+	APIKey        string            `yaml:"api_key"`
+	APIKeyFile    string            `yaml:"api_key_file"`
+	Models        []string          `yaml:"models"`
+	Headers       map[string]string `yaml:"headers"`
+	Priority      int               `yaml:"priority"`
+	Weight        int               `yaml:"weight"`
+	MaxRPS        int               `yaml:"max_rps"`
+	Timeout       int               `yaml:"timeout"`
+	IsActive      *bool             `yaml:"is_active"`
+	Pricing       []PricingSpec     `yaml:"pricing"`
+}
+
+// PricingSpec 配置下单个模型的定价
+type PricingSpec struct {
+	Model       string  `yaml:"model"`
+	InputPrice  float64 `yaml:"input_price"`
+	OutputPrice float64 `yaml:"output_price"`
+	Unit        int     `yaml:"unit"`
+	Currency    string  `yaml:"currency"`
+}
+
+var (
+	configTypes = map[string]bool{"openai": true, "anthropic": true, "gemini": true, "kiro": true, "custom": true}
+	configKinds = map[string]bool{"direct": true, "account_pool": true}
+)
+
+// LoadFile 读取并解析声明式配置文件，解析 Key 引用并校验内容
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse 解析声明式配置（JSON 是 YAML 的子集，两种格式共用一个解析器），未知字段视为错误
+func Parse(data []byte) (*File, error) {
+	var file File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := file.resolveSecrets(); err != nil {
+		return nil, err
+	}
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// resolveSecrets 展开 api_key 和请求头中的 ${ENV} 引用，读取 api_key_file
+// 引用的环境变量不存在时报错，避免以空 Key 创建配置
+func (f *File) resolveSecrets() error {
+	for i := range f.Configs {
+		spec := &f.Configs[i]
+		if spec.APIKeyFile != "" {
+			if spec.APIKey != "" {
+				return fmt.Errorf("configs[%d]: api_key and api_key_file are mutually exclusive", i)
+			}
+			data, err := os.ReadFile(spec.APIKeyFile)
+			if err != nil {
+				return fmt.Errorf("configs[%d]: failed to read api_key_file: %w", i, err)
+			}
+			spec.APIKey = strings.TrimSpace(string(data))
+		}
+
+		key, err := expandEnv(spec.APIKey)
+		if err != nil {
+			return fmt.Errorf("configs[%d].api_key: %w", i, err)
+		}
+		spec.APIKey = key
+		for name, value := range spec.Headers {
+			expanded, err := expandEnv(value)
+			if err != nil {
+				return fmt.Errorf("configs[%d].headers.%s: %w", i, name, err)
+			}
+			spec.Headers[name] = expanded
+		}
+	}
+	return nil
+}
+
+// expandEnv 展开 ${NAME} 形式的环境变量引用
+func expandEnv(value string) (string, error) {
+	var missing string
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set", missing)
+	}
+	return expanded, nil
+}
+
+// Validate 校验文件内容，返回所有问题的汇总
+func (f *File) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	names := make(map[string]bool, len(f.Configs))
+	for i, spec := range f.Configs {
+		field := fmt.Sprintf("configs[%d]", i)
+		switch {
+		case strings.TrimSpace(spec.Name) == "":
+			add("%s.name: required", field)
+		case names[spec.Name]:
+			add("%s.name: duplicate name %q", field, spec.Name)
+		}
+		names[spec.Name] = true
+
+		if !configTypes[spec.Type] {
+			add("%s.type: must be one of openai, anthropic, gemini, kiro, custom", field)
+		}
+		if spec.ConfigType != "" && !configKinds[spec.ConfigType] {
+			add("%s.config_type: must be direct or account_pool", field)
+		}
+		if spec.ConfigType == "account_pool" {
+			if spec.AccountPoolID == nil || *spec.AccountPoolID == 0 {
+				add("%s.account_pool_id: required for account_pool configs", field)
+			}
+		} else if spec.Type != "kiro" {
+			if !strings.HasPrefix(spec.BaseURL, "http://") && !strings.HasPrefix(spec.BaseURL, "https://") {
+				add("%s.base_url: must be a valid HTTP or HTTPS URL", field)
+			}
+		}
+		if len(spec.Models) == 0 {
+			add("%s.models: at least one model is required", field)
+		}
+		if spec.Priority < 0 || spec.Priority > 1000 {
+			add("%s.priority: must be between 1 and 1000", field)
+		}
+		if spec.Weight < 0 || spec.Weight > 100 {
+			add("%s.weight: must be between 1 and 100", field)
+		}
+		if spec.MaxRPS < 0 {
+			add("%s.max_rps: must not be negative", field)
+		}
+		if spec.Timeout < 0 || spec.Timeout > 300 {
+			add("%s.timeout: must be between 1 and 300", field)
+		}
+
+		models := make(map[string]bool, len(spec.Pricing))
+		for j, price := range spec.Pricing {
+			priceField := fmt.Sprintf("%s.pricing[%d]", field, j)
+			switch {
+			case price.Model == "":
+				add("%s.model: required", priceField)
+			case models[price.Model]:
+				add("%s.model: duplicate pricing for %q", priceField, price.Model)
+			}
+			models[price.Model] = true
+			if price.InputPrice < 0 || price.OutputPrice < 0 {
+				add("%s: prices must not be negative", priceField)
+			}
+			if price.Unit < 0 {
+				add("%s.unit: must not be negative", priceField)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config file:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
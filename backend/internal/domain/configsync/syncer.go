@@ -0,0 +1,155 @@
+package configsync
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+)
+
+// Result 一次对齐的结果
+type Result struct {
+	Created  int
+	Updated  int
+	Pricings int
+}
+
+// Syncer 启动时把声明式配置文件对齐到数据库
+// 配置按名称对应：不存在则创建，存在则以文件内容覆盖文件中声明的字段；
+// 文件中没有的配置保持不变，不会被删除
+type Syncer struct {
+	configs  apiconfig.Repository
+	pricings pricing.Repository
+	logger   logger.Logger
+}
+
+// NewSyncer 创建声明式配置同步器
+func NewSyncer(configs apiconfig.Repository, pricings pricing.Repository, logger logger.Logger) *Syncer {
+	return &Syncer{configs: configs, pricings: pricings, logger: logger}
+}
+
+// Apply 对齐文件中的配置和定价，readOnly 为 true 时这些配置通过管理接口只读
+func (s *Syncer) Apply(ctx context.Context, file *File, readOnly bool) (*Result, error) {
+	existing, err := s.configs.FindAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing configs: %w", err)
+	}
+	byName := make(map[string]*apiconfig.APIConfig, len(existing))
+	for _, config := range existing {
+		if _, dup := byName[config.Name]; dup {
+			s.logger.Warn("Multiple configs share a name, the first is reconciled", logger.String("name", config.Name))
+			continue
+		}
+		byName[config.Name] = config
+	}
+
+	result := &Result{}
+	for _, spec := range file.Configs {
+		config, found := byName[spec.Name]
+		if !found {
+			config = &apiconfig.APIConfig{LogRequests: true}
+		} else if spec.APIKey != "" && len(config.UpstreamKeys) > 0 {
+			s.logger.Warn("Declared api_key replaces the config's upstream key set",
+				logger.String("name", spec.Name),
+				logger.Int("upstream_keys", len(config.UpstreamKeys)))
+		}
+		spec.applyTo(config)
+		config.ReadOnly = readOnly
+
+		if found {
+			if err := s.configs.Update(ctx, config); err != nil {
+				return result, fmt.Errorf("failed to update config %q: %w", spec.Name, err)
+			}
+			result.Updated++
+		} else {
+			if err := s.configs.Create(ctx, config); err != nil {
+				return result, fmt.Errorf("failed to create config %q: %w", spec.Name, err)
+			}
+			result.Created++
+		}
+
+		for _, price := range spec.Pricing {
+			if err := s.applyPricing(ctx, config.ID, price); err != nil {
+				return result, fmt.Errorf("failed to apply pricing %s/%s: %w", spec.Name, price.Model, err)
+			}
+			result.Pricings++
+		}
+	}
+
+	s.logger.Info("Declarative configs reconciled",
+		logger.Int("created", result.Created),
+		logger.Int("updated", result.Updated),
+		logger.Int("pricings", result.Pricings),
+		logger.Bool("read_only", readOnly))
+	return result, nil
+}
+
+// applyTo 把声明的字段写入配置，未声明的数值字段使用与管理接口相同的默认值
+func (spec *ConfigSpec) applyTo(config *apiconfig.APIConfig) {
+	config.Name = spec.Name
+	config.Type = spec.Type
+	config.ConfigType = spec.ConfigType
+	if config.ConfigType == "" {
+		config.ConfigType = apiconfig.ConfigTypeDirect
+	}
+	config.AccountPoolID = spec.AccountPoolID
+	config.BaseURL = spec.BaseURL
+	if config.ConfigType == apiconfig.ConfigTypeAccountPool {
+		config.BaseURL = ""
+	} else if spec.Type == "kiro" && config.BaseURL == "" {
+		config.BaseURL = "https://q.us-east-1.amazonaws.com"
+	}
+	config.APIKey = spec.APIKey
+	// 上游 Key 集合非空时 api_key 不生效，文件声明了 api_key 时以它为准
+	if spec.APIKey != "" {
+		config.UpstreamKeys = nil
+	}
+	config.Models = spec.Models
+
+	config.Headers = nil
+	if len(spec.Headers) > 0 {
+		config.Headers = make(apiconfig.JSONMap, len(spec.Headers))
+		for name, value := range spec.Headers {
+			config.Headers[name] = value
+		}
+	}
+
+	config.Priority = valueOr(spec.Priority, 100)
+	config.Weight = valueOr(spec.Weight, 1)
+	config.Timeout = valueOr(spec.Timeout, 30)
+	config.MaxRPS = spec.MaxRPS
+	config.IsActive = spec.IsActive == nil || *spec.IsActive
+}
+
+// applyPricing 创建或更新配置下某个模型的定价
+func (s *Syncer) applyPricing(ctx context.Context, configID uint, spec PricingSpec) error {
+	price, err := s.pricings.FindByModelAndAPIConfig(ctx, spec.Model, configID)
+	if err != nil {
+		return err
+	}
+	found := price != nil
+	if !found {
+		price = &pricing.Pricing{APIConfigID: configID, ModelName: spec.Model}
+	}
+	price.InputPrice = spec.InputPrice
+	price.OutputPrice = spec.OutputPrice
+	price.Unit = valueOr(spec.Unit, 1000)
+	price.Currency = spec.Currency
+	if price.Currency == "" {
+		price.Currency = "credits"
+	}
+	price.IsActive = true
+
+	if found {
+		return s.pricings.Update(ctx, price)
+	}
+	return s.pricings.Create(ctx, price)
+}
+
+func valueOr(value, fallback int) int {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,201 @@
+package configsync
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeConfigRepo 内存中的 API 配置表
+type fakeConfigRepo struct {
+	apiconfig.Repository
+	configs []*apiconfig.APIConfig
+}
+
+func (r *fakeConfigRepo) FindAll(ctx context.Context) ([]*apiconfig.APIConfig, error) {
+	return r.configs, nil
+}
+
+func (r *fakeConfigRepo) Create(ctx context.Context, config *apiconfig.APIConfig) error {
+	config.ID = uint(len(r.configs) + 1)
+	r.configs = append(r.configs, config)
+	return nil
+}
+
+func (r *fakeConfigRepo) Update(ctx context.Context, config *apiconfig.APIConfig) error {
+	r.configs[config.ID-1] = config
+	return nil
+}
+
+// fakePricingRepo 内存中的定价表
+type fakePricingRepo struct {
+	pricing.Repository
+	pricings []*pricing.Pricing
+}
+
+func (r *fakePricingRepo) FindByModelAndAPIConfig(ctx context.Context, model string, configID uint) (*pricing.Pricing, error) {
+	for _, p := range r.pricings {
+		if p.ModelName == model && p.APIConfigID == configID {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakePricingRepo) Create(ctx context.Context, p *pricing.Pricing) error {
+	p.ID = uint(len(r.pricings) + 1)
+	r.pricings = append(r.pricings, p)
+	return nil
+}
+
+func (r *fakePricingRepo) Update(ctx context.Context, p *pricing.Pricing) error {
+	r.pricings[p.ID-1] = p
+	return nil
+}
+
+const sampleFile = `
+configs:
+  - name: openai-main
+    type: openai
+    base_url: https://api.openai.com/v1
+    api_key: ${SYNC_TEST_OPENAI_KEY}
+    models: [gpt-4o, gpt-4o-mini]
+    priority: 10
+    pricing:
+      - model: gpt-4o
+        input_price: 2.5
+        output_price: 10
+  - name: claude
+    type: anthropic
+    base_url: https://api.anthropic.com
+    api_key_file: %s
+    models: [claude-3-5-sonnet]
+    is_active: false
+`
+
+func writeSample(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "anthropic_key")
+	os.WriteFile(secret, []byte("sk-ant-secret\n"), 0o600)
+	path := filepath.Join(dir, "configs.yaml")
+	os.WriteFile(path, []byte(strings.Replace(content, "%s", secret, 1)), 0o600)
+	return path
+}
+
+func TestSyncer_CreatesThenUpdatesConfigsFromFile(t *testing.T) {
+	t.Setenv("SYNC_TEST_OPENAI_KEY", "sk-openai")
+	configs, pricings := &fakeConfigRepo{}, &fakePricingRepo{}
+	syncer := NewSyncer(configs, pricings, *logger.NewNop())
+
+	file, err := LoadFile(writeSample(t, sampleFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err := syncer.Apply(context.Background(), file, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Created != 2 || result.Updated != 0 || result.Pricings != 1 {
+		t.Fatalf("Expected 2 configs and 1 pricing created, got %+v", result)
+	}
+	openai, claude := configs.configs[0], configs.configs[1]
+	if openai.APIKey != "sk-openai" || openai.Priority != 10 || openai.Weight != 1 || openai.Timeout != 30 || !openai.IsActive || openai.ReadOnly {
+		t.Errorf("Expected the env key and defaults applied, got %+v", openai)
+	}
+	if claude.APIKey != "sk-ant-secret" || claude.IsActive {
+		t.Errorf("Expected the key read from the secret file and the config inactive, got %+v", claude)
+	}
+	if p := pricings.pricings[0]; p.APIConfigID != openai.ID || p.Unit != 1000 || p.Currency != "credits" || p.OutputPrice != 10 {
+		t.Errorf("Expected the pricing created with defaults, got %+v", p)
+	}
+
+	// 修改文件后再次启动：同名配置原地更新，定价更新而不是重复创建
+	t.Setenv("SYNC_TEST_OPENAI_KEY", "sk-rotated")
+	updated := strings.Replace(strings.Replace(sampleFile, "priority: 10", "priority: 20", 1), "output_price: 10", "output_price: 12", 1)
+	file, err = LoadFile(writeSample(t, updated))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, err = syncer.Apply(context.Background(), file, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Created != 0 || result.Updated != 2 || len(configs.configs) != 2 || len(pricings.pricings) != 1 {
+		t.Fatalf("Expected the configs updated in place, got %+v with %d configs", result, len(configs.configs))
+	}
+	openai = configs.configs[0]
+	if openai.Priority != 20 || openai.APIKey != "sk-rotated" || pricings.pricings[0].OutputPrice != 12 {
+		t.Errorf("Expected the file changes applied, got %+v", openai)
+	}
+	if !openai.ReadOnly || !configs.configs[1].ReadOnly {
+		t.Error("Expected file-defined configs marked read-only")
+	}
+}
+
+func TestSyncer_LeavesUnlistedConfigsAlone(t *testing.T) {
+	t.Setenv("SYNC_TEST_OPENAI_KEY", "sk-openai")
+	configs := &fakeConfigRepo{configs: []*apiconfig.APIConfig{{ID: 1, Name: "manual", Type: "openai", Priority: 5}}}
+	syncer := NewSyncer(configs, &fakePricingRepo{}, *logger.NewNop())
+
+	file, err := LoadFile(writeSample(t, sampleFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := syncer.Apply(context.Background(), file, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if manual := configs.configs[0]; manual.Priority != 5 || manual.ReadOnly {
+		t.Errorf("Expected a config missing from the file left unchanged, got %+v", manual)
+	}
+}
+
+func TestSyncer_DeclaredKeyReplacesUpstreamKeys(t *testing.T) {
+	t.Setenv("SYNC_TEST_OPENAI_KEY", "sk-openai")
+	configs := &fakeConfigRepo{configs: []*apiconfig.APIConfig{{ID: 1, Name: "openai-main", Type: "openai",
+		UpstreamKeys: apiconfig.UpstreamKeys{{ID: "k1", Key: "enc:old"}}}}}
+	syncer := NewSyncer(configs, &fakePricingRepo{}, *logger.NewNop())
+
+	file, err := LoadFile(writeSample(t, sampleFile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := syncer.Apply(context.Background(), file, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if openai := configs.configs[0]; openai.APIKey != "sk-openai" || len(openai.UpstreamKeys) != 0 {
+		t.Errorf("Expected the declared key to take effect instead of the stale key set, got %+v", openai)
+	}
+}
+
+func TestParse_JSONFile(t *testing.T) {
+	file, err := Parse([]byte(`{"configs":[{"name":"g","type":"gemini","base_url":"https://example.com","models":["gemini-pro"]}]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(file.Configs) != 1 || file.Configs[0].Type != "gemini" {
+		t.Errorf("Expected the JSON file parsed, got %+v", file.Configs)
+	}
+}
+
+func TestParse_ValidatesSchema(t *testing.T) {
+	cases := map[string]string{
+		"unknown field": `{"configs":[{"name":"a","type":"openai","base_url":"https://x","models":["m"],"colour":"red"}]}`,
+		"bad type":      `{"configs":[{"name":"a","type":"mystery","base_url":"https://x","models":["m"]}]}`,
+		"no models":     `{"configs":[{"name":"a","type":"openai","base_url":"https://x"}]}`,
+		"bad url":       `{"configs":[{"name":"a","type":"openai","base_url":"ftp://x","models":["m"]}]}`,
+		"duplicate":     `{"configs":[{"name":"a","type":"openai","base_url":"https://x","models":["m"]},{"name":"a","type":"openai","base_url":"https://x","models":["m"]}]}`,
+		"missing env":   `{"configs":[{"name":"a","type":"openai","base_url":"https://x","models":["m"],"api_key":"${SYNC_TEST_UNSET_KEY}"}]}`,
+		"bad pricing":   `{"configs":[{"name":"a","type":"openai","base_url":"https://x","models":["m"],"pricing":[{"model":"m","input_price":-1}]}]}`,
+	}
+	for name, content := range cases {
+		if _, err := Parse([]byte(content)); err == nil {
+			t.Errorf("%s: expected the file rejected", name)
+		}
+	}
+}
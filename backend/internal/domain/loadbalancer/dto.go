@@ -57,6 +57,32 @@ type AvailableModelsResponse struct {
 	Total  int      `json:"total"`
 }
 
+// SimulateRequest 负载均衡分布模拟请求
+type SimulateRequest struct {
+	ModelName string       `json:"model_name" binding:"required"`
+	Strategy  string       `json:"strategy"`                                // 为空时使用模型当前的负载均衡策略
+	Weights   map[uint]int `json:"weights"`                                 // 按配置ID覆盖权重，未指定的使用当前权重
+	Samples   int          `json:"samples" binding:"omitempty,min=1,max=100000"` // 默认 10000
+}
+
+// SimulateItem 单个候选配置的模拟结果
+type SimulateItem struct {
+	ConfigID           uint    `json:"config_id"`
+	ConfigName         string  `json:"config_name"`
+	Weight             int     `json:"weight"`
+	Count              int     `json:"count"`
+	Percentage         float64 `json:"percentage"`
+	ExpectedPercentage float64 `json:"expected_percentage"`
+}
+
+// SimulateResponse 负载均衡分布模拟响应
+type SimulateResponse struct {
+	ModelName    string          `json:"model_name"`
+	Strategy     string          `json:"strategy"`
+	Samples      int             `json:"samples"`
+	Distribution []*SimulateItem `json:"distribution"`
+}
+
 // ConfigFilter 配置过滤器
 type ConfigFilter struct {
 	ModelName *string
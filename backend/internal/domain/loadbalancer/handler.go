@@ -264,3 +264,28 @@ func (h *Handler) GetAvailableModels(c *gin.Context) {
 
 	response.Success(c, gin.H{"models": models})
 }
+
+// Simulate 模拟负载均衡分布
+// @Summary 模拟负载均衡分布
+// @Description 按给定策略和权重预览候选配置的选择分布，不发送真实请求
+// @Tags LoadBalancer
+// @Accept json
+// @Produce json
+// @Param request body SimulateRequest true "模拟请求"
+// @Success 200 {object} SimulateResponse
+// @Router /api/v1/admin/load-balancer/simulate [post]
+func (h *Handler) Simulate(c *gin.Context) {
+	var req SimulateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	result, err := h.service.Simulate(c.Request.Context(), &req)
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
@@ -0,0 +1,139 @@
+package loadbalancer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Selector 负载均衡选择器
+// 代理请求与分布模拟共用同一套选择逻辑
+type Selector struct {
+	mu       sync.Mutex
+	counters map[string]uint64
+	rng      *rand.Rand
+}
+
+// NewSelector 创建负载均衡选择器
+func NewSelector() *Selector {
+	return NewSelectorWithSeed(time.Now().UnixNano())
+}
+
+// NewSelectorWithSeed 使用固定随机种子创建选择器（用于模拟和测试）
+func NewSelectorWithSeed(seed int64) *Selector {
+	return &Selector{
+		counters: make(map[string]uint64),
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Select 根据策略从候选中选择一个，返回其下标
+// key 用于区分轮询计数（通常为模型名），weights 与候选一一对应
+func (s *Selector) Select(key, strategy string, weights []int) int {
+	if len(weights) <= 1 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strategy {
+	case StrategyRoundRobin:
+		index := s.counters[key] % uint64(len(weights))
+		s.counters[key]++
+		return int(index)
+	case StrategyWeightedRoundRobin:
+		return s.selectByWeight(weights)
+	case StrategyRandom:
+		return s.rng.Intn(len(weights))
+	default:
+		// 未配置策略或暂不支持的策略（如 least_connections）使用第一个候选
+		return 0
+	}
+}
+
+// selectByWeight 按权重随机选择，权重全为 0 时返回第一个
+func (s *Selector) selectByWeight(weights []int) int {
+	totalWeight := 0
+	for _, w := range weights {
+		if w > 0 {
+			totalWeight += w
+		}
+	}
+	if totalWeight == 0 {
+		return 0
+	}
+
+	random := s.rng.Intn(totalWeight)
+	for i, w := range weights {
+		if w <= 0 {
+			continue
+		}
+		random -= w
+		if random < 0 {
+			return i
+		}
+	}
+	return 0
+}
+
+// SelectCanary 按灰度比例决定本次请求是否交给灰度候选
+// percents 为各灰度候选的流量百分比，总和超过 100 时按 100 处理；返回选中的下标，未命中返回 -1
+func (s *Selector) SelectCanary(percents []float64) int {
+	if len(percents) == 0 {
+		return -1
+	}
+
+	s.mu.Lock()
+	random := s.rng.Float64() * 100
+	s.mu.Unlock()
+
+	for i, p := range percents {
+		if p <= 0 {
+			continue
+		}
+		random -= p
+		if random < 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// ExpectedShares 返回各候选在给定策略下的理论占比
+func ExpectedShares(strategy string, weights []int) []float64 {
+	shares := make([]float64, len(weights))
+	if len(weights) == 0 {
+		return shares
+	}
+	if len(weights) == 1 {
+		shares[0] = 1
+		return shares
+	}
+
+	switch strategy {
+	case StrategyRoundRobin, StrategyRandom:
+		for i := range shares {
+			shares[i] = 1 / float64(len(weights))
+		}
+	case StrategyWeightedRoundRobin:
+		totalWeight := 0
+		for _, w := range weights {
+			if w > 0 {
+				totalWeight += w
+			}
+		}
+		if totalWeight == 0 {
+			shares[0] = 1
+			break
+		}
+		for i, w := range weights {
+			if w > 0 {
+				shares[i] = float64(w) / float64(totalWeight)
+			}
+		}
+	default:
+		shares[0] = 1
+	}
+	return shares
+}
@@ -19,8 +19,12 @@ type Service interface {
 	GetAvailableModels(ctx context.Context) ([]string, error)
 	ActivateConfig(ctx context.Context, id uint) error
 	DeactivateConfig(ctx context.Context, id uint) error
+	Simulate(ctx context.Context, req *SimulateRequest) (*SimulateResponse, error)
 }
 
+// DefaultSimulateSamples 默认模拟次数
+const DefaultSimulateSamples = 10000
+
 type service struct {
 	repo            Repository
 	apiConfigRepo   apiconfig.Repository
@@ -245,3 +249,68 @@ func (s *service) GetAvailableModels(ctx context.Context) ([]string, error) {
 
 	return models, nil
 }
+
+// Simulate 模拟负载均衡分布，不发送任何真实请求
+func (s *service) Simulate(ctx context.Context, req *SimulateRequest) (*SimulateResponse, error) {
+	strategy := req.Strategy
+	if strategy != "" && !IsValidStrategy(strategy) {
+		return nil, errors.NewValidationError("invalid strategy", map[string]string{
+			"strategy": "must be one of: round_robin, weighted_round_robin, least_connections, random",
+		})
+	}
+
+	configs, err := s.apiConfigRepo.FindByModel(ctx, req.ModelName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get API configs")
+	}
+	if len(configs) == 0 {
+		return nil, errors.NewNotFoundError("no API configuration found for this model")
+	}
+
+	// 未指定策略时使用模型当前配置（与代理选择逻辑一致）
+	if strategy == "" {
+		if config, err := s.repo.FindByModel(ctx, req.ModelName); err == nil && config != nil {
+			strategy = config.Strategy
+		}
+	}
+
+	samples := req.Samples
+	if samples <= 0 {
+		samples = DefaultSimulateSamples
+	}
+
+	weights := make([]int, len(configs))
+	for i, config := range configs {
+		weights[i] = config.Weight
+		if w, ok := req.Weights[config.ID]; ok {
+			weights[i] = w
+		}
+	}
+
+	// 使用独立的选择器，避免影响线上轮询计数
+	selector := NewSelectorWithSeed(int64(samples))
+	counts := make([]int, len(configs))
+	for i := 0; i < samples; i++ {
+		counts[selector.Select(req.ModelName, strategy, weights)]++
+	}
+
+	shares := ExpectedShares(strategy, weights)
+	distribution := make([]*SimulateItem, len(configs))
+	for i, config := range configs {
+		distribution[i] = &SimulateItem{
+			ConfigID:           config.ID,
+			ConfigName:         config.Name,
+			Weight:             weights[i],
+			Count:              counts[i],
+			Percentage:         float64(counts[i]) * 100 / float64(samples),
+			ExpectedPercentage: shares[i] * 100,
+		}
+	}
+
+	return &SimulateResponse{
+		ModelName:    req.ModelName,
+		Strategy:     strategy,
+		Samples:      samples,
+		Distribution: distribution,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package loadbalancer
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"math"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// fakeAPIConfigRepo 返回固定候选配置
+type fakeAPIConfigRepo struct {
+	apiconfig.Repository
+	configs []*apiconfig.APIConfig
+}
+
+func (f *fakeAPIConfigRepo) FindByModel(ctx context.Context, model string) ([]*apiconfig.APIConfig, error) {
+	return f.configs, nil
+}
+
+// fakeRepo 返回固定的负载均衡配置
+type fakeRepo struct {
+	Repository
+	config *LoadBalancerConfig
+}
+
+func (f *fakeRepo) FindByModel(ctx context.Context, modelName string) (*LoadBalancerConfig, error) {
+	if f.config == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return f.config, nil
+}
+
+func newSimulateService(lb *LoadBalancerConfig, weights ...int) Service {
+	configs := make([]*apiconfig.APIConfig, len(weights))
+	for i, w := range weights {
+		configs[i] = &apiconfig.APIConfig{ID: uint(i + 1), Name: "cfg", Weight: w}
+	}
+	return NewService(&fakeRepo{config: lb}, &fakeAPIConfigRepo{configs: configs})
+}
+
+func TestSimulate_WeightedMatchesExpectedProportions(t *testing.T) {
+	svc := newSimulateService(nil, 1, 1, 1)
+
+	// 覆盖权重为 6:3:1
+	resp, err := svc.Simulate(context.Background(), &SimulateRequest{
+		ModelName: "gpt-4",
+		Strategy:  StrategyWeightedRoundRobin,
+		Weights:   map[uint]int{1: 6, 2: 3, 3: 1},
+		Samples:   20000,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []float64{60, 30, 10}
+	total := 0
+	for i, item := range resp.Distribution {
+		total += item.Count
+		if item.ExpectedPercentage != expected[i] {
+			t.Errorf("Config %d: expected share %.1f%%, got %.1f%%", item.ConfigID, expected[i], item.ExpectedPercentage)
+		}
+		if math.Abs(item.Percentage-expected[i]) > 2 {
+			t.Errorf("Config %d: simulated %.2f%% too far from %.1f%%", item.ConfigID, item.Percentage, expected[i])
+		}
+	}
+	if total != 20000 {
+		t.Errorf("Expected 20000 samples, got %d", total)
+	}
+}
+
+func TestSimulate_RoundRobinIsEven(t *testing.T) {
+	svc := newSimulateService(nil, 5, 1, 1, 1)
+
+	resp, err := svc.Simulate(context.Background(), &SimulateRequest{
+		ModelName: "gpt-4",
+		Strategy:  StrategyRoundRobin,
+		Samples:   400,
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, item := range resp.Distribution {
+		if item.Count != 100 {
+			t.Errorf("Config %d: expected 100 selections, got %d", item.ConfigID, item.Count)
+		}
+	}
+}
+
+func TestSimulate_UsesConfiguredStrategyAndCurrentWeights(t *testing.T) {
+	svc := newSimulateService(&LoadBalancerConfig{Strategy: StrategyWeightedRoundRobin}, 3, 0, 1)
+
+	resp, err := svc.Simulate(context.Background(), &SimulateRequest{ModelName: "gpt-4"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Strategy != StrategyWeightedRoundRobin || resp.Samples != DefaultSimulateSamples {
+		t.Fatalf("Unexpected strategy/samples: %s/%d", resp.Strategy, resp.Samples)
+	}
+	if resp.Distribution[1].Count != 0 {
+		t.Errorf("Expected zero-weight config to never be selected, got %d", resp.Distribution[1].Count)
+	}
+	if math.Abs(resp.Distribution[0].Percentage-75) > 2 {
+		t.Errorf("Expected ~75%% for weight 3, got %.2f%%", resp.Distribution[0].Percentage)
+	}
+}
+
+func TestSimulate_InvalidStrategy(t *testing.T) {
+	svc := newSimulateService(nil, 1, 1)
+
+	if _, err := svc.Simulate(context.Background(), &SimulateRequest{ModelName: "gpt-4", Strategy: "bogus"}); err == nil {
+		t.Fatal("Expected error for invalid strategy")
+	}
+}
@@ -0,0 +1,142 @@
+package log
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchWriter 请求日志批量写入器
+// 日志先进入有界队列，由后台协程攒满一批或到达刷新间隔时一次插入；
+// 队列满时退化为同步写入，宁可增加延迟也不丢日志
+type BatchWriter struct {
+	repo          Repository
+	batchSize     int
+	flushInterval time.Duration
+	logger        logger.Logger
+
+	queue   chan *RequestLog
+	mu      sync.RWMutex // 保护 closed，关闭后不再向 queue 发送
+	closed  bool
+	done    chan struct{}
+	started bool
+}
+
+// NewBatchWriter 创建批量写入器，需调用 Start 启动后台刷新
+func NewBatchWriter(repo Repository, batchSize, queueSize int, flushInterval time.Duration, logger logger.Logger) *BatchWriter {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	if queueSize < batchSize {
+		queueSize = batchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	return &BatchWriter{
+		repo:          repo,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		logger:        logger,
+		queue:         make(chan *RequestLog, queueSize),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start 启动后台刷新协程
+func (w *BatchWriter) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started || w.closed {
+		return
+	}
+	w.started = true
+	go w.run()
+}
+
+// Write 将日志放入队列；写入器已关闭或队列已满时同步插入
+func (w *BatchWriter) Write(log *RequestLog) {
+	w.mu.RLock()
+	if !w.closed {
+		select {
+		case w.queue <- log:
+			w.mu.RUnlock()
+			return
+		default:
+		}
+	}
+	w.mu.RUnlock()
+
+	if err := w.repo.Create(context.Background(), log); err != nil {
+		w.logger.Error("Failed to create log", logger.String("model", log.Model), logger.Error(err))
+	}
+}
+
+// Close 停止接收新日志并写完队列中剩余的日志，ctx 超时则放弃等待
+func (w *BatchWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	started := w.started
+	w.mu.Unlock()
+
+	// 未启动时没有后台协程，直接在当前协程写完
+	if !started {
+		go w.run()
+	}
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 攒批并定时刷新，队列关闭后写完剩余日志退出
+func (w *BatchWriter) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*RequestLog, 0, w.batchSize)
+	for {
+		select {
+		case log, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, log)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = make([]*RequestLog, 0, w.batchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = make([]*RequestLog, 0, w.batchSize)
+			}
+		}
+	}
+}
+
+// flush 一次插入一批日志
+func (w *BatchWriter) flush(batch []*RequestLog) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := w.repo.CreateBatch(ctx, batch); err != nil {
+		w.logger.Error("Failed to write request log batch",
+			logger.Int("count", len(batch)),
+			logger.Error(err))
+	}
+}
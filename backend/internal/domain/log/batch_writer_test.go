@@ -0,0 +1,122 @@
+package log
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRepo 记录单条插入和批量插入
+type fakeRepo struct {
+	Repository
+	mu      sync.Mutex
+	single  int
+	batches []int
+}
+
+func (r *fakeRepo) Create(ctx context.Context, log *RequestLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.single++
+	return nil
+}
+
+func (r *fakeRepo) CreateBatch(ctx context.Context, logs []*RequestLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(logs))
+	return nil
+}
+
+func (r *fakeRepo) written() (single, batched int, batches []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.batches {
+		batched += n
+	}
+	return r.single, batched, append([]int(nil), r.batches...)
+}
+
+func TestBatchWriter_BatchesRapidWrites(t *testing.T) {
+	repo := &fakeRepo{}
+	w := NewBatchWriter(repo, 50, 1000, time.Hour, *logger.NewNop())
+	w.Start()
+
+	for i := 0; i < 500; i++ {
+		w.Write(&RequestLog{Model: "gpt-4"})
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	single, batched, batches := repo.written()
+	if single != 0 || batched != 500 {
+		t.Fatalf("Expected all 500 logs written in batches, got %d single and %d batched", single, batched)
+	}
+	if len(batches) != 10 {
+		t.Errorf("Expected 10 inserts of 50 logs, got %v", batches)
+	}
+}
+
+func TestBatchWriter_FlushesOnInterval(t *testing.T) {
+	repo := &fakeRepo{}
+	w := NewBatchWriter(repo, 100, 1000, 10*time.Millisecond, *logger.NewNop())
+	w.Start()
+	defer w.Close(context.Background())
+
+	for i := 0; i < 3; i++ {
+		w.Write(&RequestLog{Model: "gpt-4"})
+	}
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, batched, _ := repo.written(); batched == 3 {
+			return
+		}
+	}
+	t.Error("Expected a partial batch flushed after the interval")
+}
+
+func TestBatchWriter_CloseFlushesBufferedLogs(t *testing.T) {
+	repo := &fakeRepo{}
+	w := NewBatchWriter(repo, 100, 1000, time.Hour, *logger.NewNop())
+	w.Start()
+
+	for i := 0; i < 7; i++ {
+		w.Write(&RequestLog{Model: "gpt-4"})
+	}
+	if _, batched, _ := repo.written(); batched != 0 {
+		t.Fatalf("Expected logs buffered before shutdown, got %d written", batched)
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, batched, _ := repo.written(); batched != 7 {
+		t.Errorf("Expected shutdown to flush all 7 buffered logs, got %d", batched)
+	}
+
+	// 关闭后写入的日志直接同步插入，不会丢失
+	w.Write(&RequestLog{Model: "gpt-4"})
+	if single, _, _ := repo.written(); single != 1 {
+		t.Errorf("Expected a write after shutdown inserted directly, got %d", single)
+	}
+}
+
+func TestBatchWriter_FullQueueWritesSynchronously(t *testing.T) {
+	repo := &fakeRepo{}
+	// 未启动的写入器不消费队列，用来模拟队列写满
+	w := NewBatchWriter(repo, 2, 2, time.Hour, *logger.NewNop())
+
+	for i := 0; i < 5; i++ {
+		w.Write(&RequestLog{Model: "gpt-4"})
+	}
+	if single, _, _ := repo.written(); single != 3 {
+		t.Errorf("Expected the 3 logs beyond queue capacity inserted directly, got %d", single)
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, batched, _ := repo.written(); batched != 2 {
+		t.Errorf("Expected the queued logs flushed on close, got %d", batched)
+	}
+}
@@ -4,16 +4,24 @@ import "time"
 
 // CreateLogRequest 创建日志请求
 type CreateLogRequest struct {
-	UserID       uint   `json:"user_id" binding:"required"`
-	APIKeyID     uint   `json:"api_key_id" binding:"required"`
-	APIConfigID  uint   `json:"api_config_id" binding:"required"`
-	Model        string `json:"model" binding:"required"`
-	Method       string `json:"method" binding:"required"`
-	Path         string `json:"path" binding:"required"`
-	StatusCode   int    `json:"status_code" binding:"required"`
-	ResponseTime int    `json:"response_time" binding:"required,min=0"`
-	TokensUsed   int    `json:"tokens_used" binding:"omitempty,min=0"`
-	ErrorMsg     string `json:"error_msg" binding:"omitempty"`
+	UserID            uint   `json:"user_id" binding:"required"`
+	APIKeyID          uint   `json:"api_key_id" binding:"required"`
+	APIConfigID       uint   `json:"api_config_id" binding:"required"`
+	Model             string `json:"model" binding:"required"`
+	Method            string `json:"method" binding:"required"`
+	Path              string `json:"path" binding:"required"`
+	StatusCode        int    `json:"status_code" binding:"required"`
+	ResponseTime      int    `json:"response_time" binding:"required,min=0"`
+	TokensUsed        int    `json:"tokens_used" binding:"omitempty,min=0"`
+	QuotaCost         int64  `json:"quota_cost" binding:"omitempty,min=0"`
+	RequestBytes      int64  `json:"request_bytes" binding:"omitempty,min=0"`
+	ResponseBytes     int64  `json:"response_bytes" binding:"omitempty,min=0"`
+	Provider          string `json:"provider" binding:"omitempty,max=50"`
+	ServiceTier       string `json:"service_tier" binding:"omitempty,max=20"`
+	ToolsDropped      int    `json:"tools_dropped" binding:"omitempty,min=0"`
+	HistoryDropped    int    `json:"history_dropped" binding:"omitempty,min=0"`
+	UpstreamRequestID string `json:"upstream_request_id" binding:"omitempty,max=255"`
+	ErrorMsg          string `json:"error_msg" binding:"omitempty"`
 }
 
 // GetLogsRequest 获取日志列表请求
@@ -29,20 +37,28 @@ type GetLogsRequest struct {
 
 // LogResponse 日志响应
 type LogResponse struct {
-	ID           uint      `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UserID       uint      `json:"user_id"`
-	Username     string    `json:"username,omitempty"`
-	APIKeyID     uint      `json:"api_key_id"`
-	APIConfigID  uint      `json:"api_config_id"`
-	Model        string    `json:"model"`
-	Method       string    `json:"method"`
-	Path         string    `json:"path"`
-	StatusCode   int       `json:"status_code"`
-	Status       string    `json:"status"`
-	ResponseTime int       `json:"response_time"`
-	TokensUsed   int       `json:"tokens_used"`
-	ErrorMsg     string    `json:"error_msg,omitempty"`
+	ID                uint      `json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UserID            uint      `json:"user_id"`
+	Username          string    `json:"username,omitempty"`
+	APIKeyID          uint      `json:"api_key_id"`
+	APIConfigID       uint      `json:"api_config_id"`
+	Model             string    `json:"model"`
+	Method            string    `json:"method"`
+	Path              string    `json:"path"`
+	StatusCode        int       `json:"status_code"`
+	Status            string    `json:"status"`
+	ResponseTime      int       `json:"response_time"`
+	TokensUsed        int       `json:"tokens_used"`
+	QuotaCost         int64     `json:"quota_cost"`
+	RequestBytes      int64     `json:"request_bytes"`
+	ResponseBytes     int64     `json:"response_bytes"`
+	Provider          string    `json:"provider,omitempty"`
+	ServiceTier       string    `json:"service_tier,omitempty"`
+	ToolsDropped      int       `json:"tools_dropped,omitempty"`
+	HistoryDropped    int       `json:"history_dropped,omitempty"`
+	UpstreamRequestID string    `json:"upstream_request_id,omitempty"`
+	ErrorMsg          string    `json:"error_msg,omitempty"`
 }
 
 // LogListResponse 日志列表响应
@@ -65,18 +81,26 @@ type LogStatsResponse struct {
 // ToResponse 转换为响应对象
 func (l *RequestLog) ToResponse() *LogResponse {
 	return &LogResponse{
-		ID:           l.ID,
-		CreatedAt:    l.CreatedAt,
-		UserID:       l.UserID,
-		APIKeyID:     l.APIKeyID,
-		APIConfigID:  l.APIConfigID,
-		Model:        l.Model,
-		Method:       l.Method,
-		Path:         l.Path,
-		StatusCode:   l.StatusCode,
-		ResponseTime: l.ResponseTime,
-		TokensUsed:   l.TokensUsed,
-		ErrorMsg:     l.ErrorMsg,
+		ID:                l.ID,
+		CreatedAt:         l.CreatedAt,
+		UserID:            l.UserID,
+		APIKeyID:          l.APIKeyID,
+		APIConfigID:       l.APIConfigID,
+		Model:             l.Model,
+		Method:            l.Method,
+		Path:              l.Path,
+		StatusCode:        l.StatusCode,
+		ResponseTime:      l.ResponseTime,
+		TokensUsed:        l.TokensUsed,
+		QuotaCost:         l.QuotaCost,
+		RequestBytes:      l.RequestBytes,
+		ResponseBytes:     l.ResponseBytes,
+		Provider:          l.Provider,
+		ServiceTier:       l.ServiceTier,
+		ToolsDropped:      l.ToolsDropped,
+		HistoryDropped:    l.HistoryDropped,
+		UpstreamRequestID: l.UpstreamRequestID,
+		ErrorMsg:          l.ErrorMsg,
 	}
 }
 
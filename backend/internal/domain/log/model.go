@@ -6,18 +6,26 @@ import (
 
 // RequestLog 璇锋眰鏃ュ織妯″瀷
 type RequestLog struct {
-	ID           uint           `gorm:"primarykey" json:"id"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UserID       uint           `gorm:"not null;index" json:"user_id"`
-	APIKeyID     uint           `gorm:"not null;index" json:"api_key_id"`
-	APIConfigID  uint           `gorm:"not null;index" json:"api_config_id"`
-	Model        string         `gorm:"not null;size:255;index" json:"model"`
-	Method       string         `gorm:"not null;size:10" json:"method"`
-	Path         string         `gorm:"not null;type:text" json:"path"`
-	StatusCode   int            `gorm:"not null;index" json:"status_code"`
-	ResponseTime int            `gorm:"not null" json:"response_time"`
-	TokensUsed   int            `gorm:"not null;default:0" json:"tokens_used"`
-	ErrorMsg     string         `gorm:"type:text" json:"error_msg,omitempty"`
+	ID                uint      `gorm:"primarykey" json:"id"`
+	CreatedAt         time.Time `json:"created_at"`
+	UserID            uint      `gorm:"index" json:"user_id"` // 数据清除后置为 NULL（读取为 0）
+	APIKeyID          uint      `gorm:"not null;index" json:"api_key_id"`
+	APIConfigID       uint      `gorm:"not null;index" json:"api_config_id"`
+	Model             string    `gorm:"not null;size:255;index" json:"model"`
+	Method            string    `gorm:"not null;size:10" json:"method"`
+	Path              string    `gorm:"not null;type:text" json:"path"`
+	StatusCode        int       `gorm:"not null;index" json:"status_code"`
+	ResponseTime      int       `gorm:"not null" json:"response_time"`
+	TokensUsed        int       `gorm:"not null;default:0" json:"tokens_used"`
+	QuotaCost         int64     `gorm:"not null;default:0" json:"quota_cost"`
+	RequestBytes      int64     `gorm:"not null;default:0" json:"request_bytes"`
+	ResponseBytes     int64     `gorm:"not null;default:0" json:"response_bytes"`
+	Provider          string    `gorm:"size:50" json:"provider,omitempty"`                   // 实际处理请求的配置类型
+	ServiceTier       string    `gorm:"size:20" json:"service_tier,omitempty"`               // 实际发送给上游的 service_tier
+	ToolsDropped      int       `gorm:"not null;default:0" json:"tools_dropped,omitempty"`   // 超出工具数上限被丢弃或合并的工具数
+	HistoryDropped    int       `gorm:"not null;default:0" json:"history_dropped,omitempty"` // 超出对话历史上限被截断的消息数
+	UpstreamRequestID string    `gorm:"size:255;index" json:"upstream_request_id,omitempty"` // 上游返回的请求 ID，便于向供应商提交工单
+	ErrorMsg          string    `gorm:"type:text" json:"error_msg,omitempty"`
 }
 
 // TableName 鎸囧畾琛ㄥ悕
@@ -44,3 +52,42 @@ func (l *RequestLog) IsServerError() bool {
 func (l *RequestLog) IsClientError() bool {
 	return l.StatusCode >= 400 && l.StatusCode < 500
 }
+
+// UsageCounter 按天聚合的用量计数
+// 关闭请求日志的配置或密钥不写 request_logs，只累加该计数
+type UsageCounter struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Date        time.Time `gorm:"type:date;not null;uniqueIndex:idx_usage_counter_key" json:"date"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_usage_counter_key" json:"user_id"`
+	APIKeyID    uint      `gorm:"not null;uniqueIndex:idx_usage_counter_key" json:"api_key_id"`
+	APIConfigID uint      `gorm:"not null;uniqueIndex:idx_usage_counter_key" json:"api_config_id"`
+	Model       string    `gorm:"not null;size:255;uniqueIndex:idx_usage_counter_key" json:"model"`
+	Requests    int64     `gorm:"not null;default:0" json:"requests"`
+	Errors      int64     `gorm:"not null;default:0" json:"errors"`
+	Tokens      int64     `gorm:"not null;default:0" json:"tokens"`
+	QuotaCost   int64     `gorm:"not null;default:0" json:"quota_cost"`
+}
+
+// TableName 指定表名
+func (UsageCounter) TableName() string {
+	return "usage_counters"
+}
+
+// usageBucketSize 用量分桶的时间粒度，与用量异常检测的间隔一致
+const usageBucketSize = 5 * time.Minute
+
+// UsageBucket 按 API Key 每 5 分钟聚合的用量计数
+// 关闭请求日志的流量没有 request_logs 明细，用量异常检测从这里取得这部分请求数和花费
+type UsageBucket struct {
+	BucketStart time.Time `gorm:"not null;uniqueIndex:idx_usage_bucket_key" json:"bucket_start"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_usage_bucket_key" json:"user_id"`
+	APIKeyID    uint      `gorm:"not null;uniqueIndex:idx_usage_bucket_key" json:"api_key_id"`
+	Requests    int64     `gorm:"not null;default:0" json:"requests"`
+	QuotaCost   int64     `gorm:"not null;default:0" json:"quota_cost"`
+}
+
+// TableName 指定表名
+func (UsageBucket) TableName() string {
+	return "usage_buckets"
+}
@@ -6,11 +6,13 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository 日志仓储接口
 type Repository interface {
 	Create(ctx context.Context, log *RequestLog) error
+	CreateBatch(ctx context.Context, logs []*RequestLog) error
 	FindByID(ctx context.Context, id uint) (*RequestLog, error)
 	FindByUserID(ctx context.Context, userID uint, limit, offset int) ([]*RequestLog, error)
 	List(ctx context.Context, filters []query.Filter, sorts []query.Sort, pagination *query.Pagination) ([]*RequestLog, int64, error)
@@ -21,6 +23,10 @@ type Repository interface {
 	GetDailyUsage(ctx context.Context, userID uint, startDate, endDate time.Time) (map[string]int, error)
 	GetStats(ctx context.Context, startDate, endDate *time.Time) (*LogStatsResponse, error)
 	DeleteOldLogs(ctx context.Context, before time.Time) (int64, error)
+	GetLatencyPercentiles(ctx context.Context, since time.Time, percentile float64, minSamples int) (map[string]float64, error)
+	IncrementUsageCounter(ctx context.Context, counter *UsageCounter) error
+	IncrementUsageBucket(ctx context.Context, bucket *UsageBucket) error
+	DeleteOldUsageBuckets(ctx context.Context, before time.Time) (int64, error)
 }
 
 // repository 日志仓储实现
@@ -38,6 +44,11 @@ func (r *repository) Create(ctx context.Context, log *RequestLog) error {
 	return r.db.WithContext(ctx).Create(log).Error
 }
 
+// CreateBatch 一条语句插入多条日志
+func (r *repository) CreateBatch(ctx context.Context, logs []*RequestLog) error {
+	return r.db.WithContext(ctx).Create(&logs).Error
+}
+
 // FindByID 根据ID查找日志
 func (r *repository) FindByID(ctx context.Context, id uint) (*RequestLog, error) {
 	var log RequestLog
@@ -211,3 +222,63 @@ func (r *repository) DeleteOldLogs(ctx context.Context, before time.Time) (int64
 		Delete(&RequestLog{})
 	return result.RowsAffected, result.Error
 }
+
+// GetLatencyPercentiles 按模型统计 since 之后成功请求响应时间（毫秒）的分位数，样本数不足 minSamples 的模型不返回
+func (r *repository) GetLatencyPercentiles(ctx context.Context, since time.Time, percentile float64, minSamples int) (map[string]float64, error) {
+	var results []struct {
+		Model   string
+		Latency float64
+	}
+
+	err := r.db.WithContext(ctx).
+		Table("request_logs").
+		Select("model, percentile_cont(?) WITHIN GROUP (ORDER BY response_time) as latency", percentile).
+		Where("created_at >= ? AND status_code >= ? AND status_code < ?", since, 200, 300).
+		Group("model").
+		Having("COUNT(*) >= ?", minSamples).
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	latencies := make(map[string]float64, len(results))
+	for _, r := range results {
+		latencies[r.Model] = r.Latency
+	}
+	return latencies, nil
+}
+
+// IncrementUsageCounter 累加当天的聚合计数（不存在时创建）
+func (r *repository) IncrementUsageCounter(ctx context.Context, counter *UsageCounter) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "date"}, {Name: "user_id"}, {Name: "api_key_id"}, {Name: "api_config_id"}, {Name: "model"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":   gorm.Expr("usage_counters.requests + EXCLUDED.requests"),
+			"errors":     gorm.Expr("usage_counters.errors + EXCLUDED.errors"),
+			"tokens":     gorm.Expr("usage_counters.tokens + EXCLUDED.tokens"),
+			"quota_cost": gorm.Expr("usage_counters.quota_cost + EXCLUDED.quota_cost"),
+			"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(counter).Error
+}
+
+// IncrementUsageBucket 累加 API Key 在分桶内的请求数和花费（不存在时创建）
+func (r *repository) IncrementUsageBucket(ctx context.Context, bucket *UsageBucket) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bucket_start"}, {Name: "user_id"}, {Name: "api_key_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":   gorm.Expr("usage_buckets.requests + EXCLUDED.requests"),
+			"quota_cost": gorm.Expr("usage_buckets.quota_cost + EXCLUDED.quota_cost"),
+		}),
+	}).Create(bucket).Error
+}
+
+// DeleteOldUsageBuckets 删除旧的用量分桶
+func (r *repository) DeleteOldUsageBuckets(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("bucket_start < ?", before).
+		Delete(&UsageBucket{})
+	return result.RowsAffected, result.Error
+}
@@ -11,52 +11,79 @@ import (
 
 // Service 日志服务接口
 type Service interface {
-	CreateLog(ctx context.Context, req *CreateLogRequest) error
+	CreateLog(ctx context.Context, req *CreateLogRequest) (*RequestLog, error)
+	CreateLogAsync(req *CreateLogRequest)
 	GetLogs(ctx context.Context, req *GetLogsRequest) (*LogListResponse, error)
 	GetLogStats(ctx context.Context, startDate, endDate *time.Time) (*LogStatsResponse, error)
 	DeleteOldLogs(ctx context.Context, days int) (int64, error)
+	RecordUsage(ctx context.Context, req *CreateLogRequest) error
+	GetLatencyPercentiles(ctx context.Context, since time.Time, percentile float64, minSamples int) (map[string]time.Duration, error)
 }
 
 // service 日志服务实现
 type service struct {
 	repo     Repository
 	userRepo user.Repository
+	writer   *BatchWriter
 	logger   logger.Logger
 }
 
-// NewService 创建日志服务
-func NewService(repo Repository, userRepo user.Repository, logger logger.Logger) Service {
+// NewService 创建日志服务，writer 为 nil 时 CreateLogAsync 同步写入
+func NewService(repo Repository, userRepo user.Repository, writer *BatchWriter, logger logger.Logger) Service {
 	return &service{
 		repo:     repo,
 		userRepo: userRepo,
+		writer:   writer,
 		logger:   logger,
 	}
 }
 
 // CreateLog 创建日志
-func (s *service) CreateLog(ctx context.Context, req *CreateLogRequest) error {
-	log := &RequestLog{
-		UserID:       req.UserID,
-		APIKeyID:     req.APIKeyID,
-		APIConfigID:  req.APIConfigID,
-		Model:        req.Model,
-		Method:       req.Method,
-		Path:         req.Path,
-		StatusCode:   req.StatusCode,
-		ResponseTime: req.ResponseTime,
-		TokensUsed:   req.TokensUsed,
-		ErrorMsg:     req.ErrorMsg,
-	}
+func (s *service) CreateLog(ctx context.Context, req *CreateLogRequest) (*RequestLog, error) {
+	log := newRequestLog(req)
 
 	if err := s.repo.Create(ctx, log); err != nil {
 		s.logger.Error("Failed to create log",
 			logger.Uint("user_id", req.UserID),
 			logger.String("model", req.Model),
 			logger.Error(err))
-		return errors.Wrap(err, 500002, "Failed to create log")
+		return nil, errors.Wrap(err, 500002, "Failed to create log")
 	}
 
-	return nil
+	return log, nil
+}
+
+// CreateLogAsync 交给批量写入器写入日志，不需要日志 ID 的调用方使用，不阻塞请求路径
+func (s *service) CreateLogAsync(req *CreateLogRequest) {
+	if s.writer == nil {
+		s.CreateLog(context.Background(), req)
+		return
+	}
+	s.writer.Write(newRequestLog(req))
+}
+
+// newRequestLog 由创建请求构造日志记录
+func newRequestLog(req *CreateLogRequest) *RequestLog {
+	return &RequestLog{
+		UserID:            req.UserID,
+		APIKeyID:          req.APIKeyID,
+		APIConfigID:       req.APIConfigID,
+		Model:             req.Model,
+		Method:            req.Method,
+		Path:              req.Path,
+		StatusCode:        req.StatusCode,
+		ResponseTime:      req.ResponseTime,
+		TokensUsed:        req.TokensUsed,
+		QuotaCost:         req.QuotaCost,
+		RequestBytes:      req.RequestBytes,
+		ResponseBytes:     req.ResponseBytes,
+		Provider:          req.Provider,
+		ServiceTier:       req.ServiceTier,
+		ToolsDropped:      req.ToolsDropped,
+		HistoryDropped:    req.HistoryDropped,
+		UpstreamRequestID: req.UpstreamRequestID,
+		ErrorMsg:          req.ErrorMsg,
+	}
 }
 
 // GetLogs 获取日志列表
@@ -162,6 +189,10 @@ func (s *service) DeleteOldLogs(ctx context.Context, days int) (int64, error) {
 		return 0, errors.Wrap(err, 500002, "Failed to delete old logs")
 	}
 
+	if _, err := s.repo.DeleteOldUsageBuckets(ctx, before); err != nil {
+		s.logger.Warn("Failed to delete old usage buckets", logger.Error(err))
+	}
+
 	s.logger.Info("Old logs deleted successfully",
 		logger.Int("days", days),
 		logger.Int64("deleted", deleted))
@@ -169,6 +200,21 @@ func (s *service) DeleteOldLogs(ctx context.Context, days int) (int64, error) {
 	return deleted, nil
 }
 
+// GetLatencyPercentiles 获取各模型近期成功请求响应时间的分位数
+func (s *service) GetLatencyPercentiles(ctx context.Context, since time.Time, percentile float64, minSamples int) (map[string]time.Duration, error) {
+	latencies, err := s.repo.GetLatencyPercentiles(ctx, since, percentile, minSamples)
+	if err != nil {
+		s.logger.Error("Failed to get latency percentiles", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get latency percentiles")
+	}
+
+	result := make(map[string]time.Duration, len(latencies))
+	for model, ms := range latencies {
+		result[model] = time.Duration(ms * float64(time.Millisecond))
+	}
+	return result, nil
+}
+
 
 // toResponseListWithUserInfo 转换为响应列表并加载用户信息
 func (s *service) toResponseListWithUserInfo(ctx context.Context, logs []*RequestLog) []*LogResponse {
@@ -219,3 +265,41 @@ func (s *service) toResponseListWithUserInfo(ctx context.Context, logs []*Reques
 
 	return responses
 }
+
+// RecordUsage 只累加聚合计数，不保存请求明细（用于关闭请求日志的流量）
+func (s *service) RecordUsage(ctx context.Context, req *CreateLogRequest) error {
+	now := time.Now()
+	counter := &UsageCounter{
+		UpdatedAt:   now,
+		Date:        time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		UserID:      req.UserID,
+		APIKeyID:    req.APIKeyID,
+		APIConfigID: req.APIConfigID,
+		Model:       req.Model,
+		Requests:    1,
+		Tokens:      int64(req.TokensUsed),
+		QuotaCost:   req.QuotaCost,
+	}
+	if req.StatusCode >= 400 {
+		counter.Errors = 1
+	}
+
+	if err := s.repo.IncrementUsageCounter(ctx, counter); err != nil {
+		return errors.Wrap(err, 500002, "Failed to record usage")
+	}
+
+	// 用量异常检测的窗口小于一天，另按 5 分钟分桶累加每个 API Key 的用量
+	if req.APIKeyID != 0 {
+		bucket := &UsageBucket{
+			BucketStart: now.Truncate(usageBucketSize),
+			UserID:      req.UserID,
+			APIKeyID:    req.APIKeyID,
+			Requests:    1,
+			QuotaCost:   req.QuotaCost,
+		}
+		if err := s.repo.IncrementUsageBucket(ctx, bucket); err != nil {
+			return errors.Wrap(err, 500002, "Failed to record usage")
+		}
+	}
+	return nil
+}
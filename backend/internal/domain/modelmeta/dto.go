@@ -0,0 +1,17 @@
+package modelmeta
+
+import "time"
+
+// SetModelMetadataRequest 设置模型元数据请求，整体替换该模型已有的设置
+type SetModelMetadataRequest struct {
+	Model              string     `json:"model" binding:"required,max=255"`
+	Deprecated         bool       `json:"deprecated"`
+	Replacement        string     `json:"replacement" binding:"omitempty,max=255"`
+	DeprecationMessage string     `json:"deprecation_message" binding:"omitempty,max=1000"`
+	SunsetAt           *time.Time `json:"sunset_at"`
+}
+
+// DeleteModelMetadataRequest 删除模型元数据请求（模型名可能包含斜杠，通过查询参数传递）
+type DeleteModelMetadataRequest struct {
+	Model string `form:"model" binding:"required"`
+}
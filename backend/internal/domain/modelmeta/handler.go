@@ -0,0 +1,99 @@
+package modelmeta
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 模型元数据处理器
+type Handler struct {
+	service Service
+}
+
+// NewHandler 创建模型元数据处理器
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// List 查询模型元数据
+// @Summary 查询模型元数据
+// @Description 列出所有设置了元数据（如弃用状态）的模型（管理员）
+// @Tags ModelMetadata
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]ModelMetadata}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/model-metadata [get]
+func (h *Handler) List(c *gin.Context) {
+	metas, err := h.service.List(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, metas)
+}
+
+// Set 设置模型元数据
+// @Summary 设置模型元数据
+// @Description 标记模型弃用并指定推荐的替代模型；弃用模型的请求仍会成功，响应附带 X-Prism-Deprecation 头（管理员）
+// @Tags ModelMetadata
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SetModelMetadataRequest true "模型元数据"
+// @Success 200 {object} response.Response{data=ModelMetadata}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/model-metadata [put]
+func (h *Handler) Set(c *gin.Context) {
+	var req SetModelMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	meta, err := h.service.Set(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, err.Error(), "")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, meta)
+}
+
+// Delete 删除模型元数据
+// @Summary 删除模型元数据
+// @Description 删除模型的元数据，同时取消弃用标记（管理员）
+// @Tags ModelMetadata
+// @Produce json
+// @Security BearerAuth
+// @Param model query string true "模型名"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/model-metadata [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	var req DeleteModelMetadataRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), req.Model); err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			response.NotFound(c, "Model metadata not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "Model metadata deleted", nil)
+}
@@ -0,0 +1,23 @@
+package modelmeta
+
+import (
+	"time"
+)
+
+// ModelMetadata 按模型名维护的元数据，目前用于标记弃用模型及推荐替代模型
+type ModelMetadata struct {
+	Model     string    `gorm:"primaryKey;size:255" json:"model"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// 弃用的模型仍可正常请求，响应附带弃用提示
+	Deprecated         bool       `gorm:"not null;default:false" json:"deprecated"`
+	Replacement        string     `gorm:"size:255" json:"replacement,omitempty"`
+	DeprecationMessage string     `gorm:"type:text" json:"deprecation_message,omitempty"`
+	SunsetAt           *time.Time `json:"sunset_at,omitempty"` // 计划停止服务的时间，仅用于提示
+}
+
+// TableName 指定表名
+func (ModelMetadata) TableName() string {
+	return "model_metadata"
+}
@@ -0,0 +1,56 @@
+package modelmeta
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Repository 模型元数据仓储接口
+type Repository interface {
+	Find(ctx context.Context, model string) (*ModelMetadata, error)
+	List(ctx context.Context) ([]*ModelMetadata, error)
+	Save(ctx context.Context, meta *ModelMetadata) error
+	Delete(ctx context.Context, model string) (bool, error)
+}
+
+// repository 模型元数据仓储实现
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建模型元数据仓储
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Find 查找模型元数据，不存在时返回 nil
+func (r *repository) Find(ctx context.Context, model string) (*ModelMetadata, error) {
+	var meta ModelMetadata
+	if err := r.db.WithContext(ctx).Where("model = ?", model).First(&meta).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// List 按模型名列出全部元数据
+func (r *repository) List(ctx context.Context) ([]*ModelMetadata, error) {
+	var metas []*ModelMetadata
+	err := r.db.WithContext(ctx).Order("model ASC").Find(&metas).Error
+	return metas, err
+}
+
+// Save 创建或更新模型元数据
+func (r *repository) Save(ctx context.Context, meta *ModelMetadata) error {
+	return r.db.WithContext(ctx).Save(meta).Error
+}
+
+// Delete 删除模型元数据，不存在时返回 false
+func (r *repository) Delete(ctx context.Context, model string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("model = ?", model).Delete(&ModelMetadata{})
+	return result.RowsAffected > 0, result.Error
+}
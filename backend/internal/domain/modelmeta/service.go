@@ -0,0 +1,98 @@
+package modelmeta
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+)
+
+// errMetadataNotFound 模型元数据不存在
+var errMetadataNotFound = errors.ErrNotFound.WithDetails("model metadata not found")
+
+// Service 模型元数据服务接口
+type Service interface {
+	List(ctx context.Context) ([]*ModelMetadata, error)
+	Set(ctx context.Context, req *SetModelMetadataRequest) (*ModelMetadata, error)
+	Delete(ctx context.Context, model string) error
+
+	// GetDeprecation 返回弃用模型的元数据，模型未弃用时返回 nil
+	GetDeprecation(ctx context.Context, model string) (*ModelMetadata, error)
+}
+
+// service 模型元数据服务实现
+type service struct {
+	repo   Repository
+	logger logger.Logger
+}
+
+// NewService 创建模型元数据服务
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// List 列出全部模型元数据
+func (s *service) List(ctx context.Context) ([]*ModelMetadata, error) {
+	metas, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list model metadata", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to list model metadata")
+	}
+	return metas, nil
+}
+
+// Set 设置模型的弃用状态和替代模型
+func (s *service) Set(ctx context.Context, req *SetModelMetadataRequest) (*ModelMetadata, error) {
+	if req.Replacement != "" && req.Replacement == req.Model {
+		return nil, errors.ErrInvalidParam.WithDetails("replacement must differ from the deprecated model")
+	}
+
+	meta, err := s.repo.Find(ctx, req.Model)
+	if err != nil {
+		return nil, errors.Wrap(err, 500002, "Failed to find model metadata")
+	}
+	if meta == nil {
+		meta = &ModelMetadata{Model: req.Model}
+	}
+	meta.Deprecated = req.Deprecated
+	meta.Replacement = req.Replacement
+	meta.DeprecationMessage = req.DeprecationMessage
+	meta.SunsetAt = req.SunsetAt
+
+	if err := s.repo.Save(ctx, meta); err != nil {
+		s.logger.Error("Failed to save model metadata", logger.String("model", req.Model), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to save model metadata")
+	}
+
+	s.logger.Info("Model metadata updated",
+		logger.String("model", meta.Model),
+		logger.Bool("deprecated", meta.Deprecated),
+		logger.String("replacement", meta.Replacement))
+	return meta, nil
+}
+
+// Delete 删除模型元数据
+func (s *service) Delete(ctx context.Context, model string) error {
+	deleted, err := s.repo.Delete(ctx, model)
+	if err != nil {
+		return errors.Wrap(err, 500002, "Failed to delete model metadata")
+	}
+	if !deleted {
+		return errMetadataNotFound
+	}
+	return nil
+}
+
+// GetDeprecation 返回弃用模型的元数据，模型未弃用时返回 nil
+func (s *service) GetDeprecation(ctx context.Context, model string) (*ModelMetadata, error) {
+	meta, err := s.repo.Find(ctx, model)
+	if err != nil {
+		return nil, errors.Wrap(err, 500002, "Failed to find model metadata")
+	}
+	if meta == nil || !meta.Deprecated {
+		return nil, nil
+	}
+	return meta, nil
+}
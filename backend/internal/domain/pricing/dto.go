@@ -86,6 +86,43 @@ type CostCalculationResponse struct {
 	TotalCost    float64 `json:"total_cost"`
 	Currency     string  `json:"currency"`
 	Unit         int     `json:"unit"`
+
+	Pricing  *PricingResponse         `json:"pricing,omitempty"`  // 实际使用的定价记录
+	Template *PricingTemplateResponse `json:"template,omitempty"` // 配置没有单独定价时使用的模型默认定价
+}
+
+// CreatePricingTemplateRequest 创建模型默认定价请求
+type CreatePricingTemplateRequest struct {
+	ModelName   string  `json:"model_name" binding:"required,min=1,max=255"`
+	InputPrice  float64 `json:"input_price" binding:"required,min=0"`
+	OutputPrice float64 `json:"output_price" binding:"required,min=0"`
+	Currency    string  `json:"currency" binding:"omitempty,oneof=credits usd cny eur"`
+	Unit        int     `json:"unit" binding:"omitempty,min=1"`
+	Description string  `json:"description" binding:"omitempty,max=500"`
+}
+
+// UpdatePricingTemplateRequest 更新模型默认定价请求
+type UpdatePricingTemplateRequest struct {
+	InputPrice  *float64 `json:"input_price" binding:"omitempty,min=0"`
+	OutputPrice *float64 `json:"output_price" binding:"omitempty,min=0"`
+	Currency    string   `json:"currency" binding:"omitempty,oneof=credits usd cny eur"`
+	Unit        *int     `json:"unit" binding:"omitempty,min=1"`
+	IsActive    *bool    `json:"is_active" binding:"omitempty"`
+	Description string   `json:"description" binding:"omitempty,max=500"`
+}
+
+// PricingTemplateResponse 模型默认定价响应
+type PricingTemplateResponse struct {
+	ID          uint      `json:"id"`
+	ModelName   string    `json:"model_name"`
+	InputPrice  float64   `json:"input_price"`
+	OutputPrice float64   `json:"output_price"`
+	Currency    string    `json:"currency"`
+	Unit        int       `json:"unit"`
+	IsActive    bool      `json:"is_active"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // BatchCreatePricingResponse 批量创建定价响应
@@ -120,3 +157,19 @@ func ToResponseList(pricings []*Pricing) []*PricingResponse {
 	}
 	return responses
 }
+
+// ToResponse 转换为响应对象
+func (t *PricingTemplate) ToResponse() *PricingTemplateResponse {
+	return &PricingTemplateResponse{
+		ID:          t.ID,
+		ModelName:   t.ModelName,
+		InputPrice:  t.InputPrice,
+		OutputPrice: t.OutputPrice,
+		Currency:    t.Currency,
+		Unit:        t.Unit,
+		IsActive:    t.IsActive,
+		Description: t.Description,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+}
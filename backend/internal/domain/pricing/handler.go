@@ -352,3 +352,138 @@ func (h *Handler) BatchCreatePricings(c *gin.Context) {
 
 	response.Success(c, result)
 }
+
+// CreateTemplate 创建模型默认定价
+// @Summary 创建模型默认定价
+// @Description 创建模型级默认定价，服务该模型的配置没有单独定价时使用（管理员）
+// @Tags Pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreatePricingTemplateRequest true "创建请求"
+// @Success 201 {object} PricingTemplateResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/pricings/templates [post]
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	var req CreatePricingTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	template, err := h.service.CreateTemplate(c.Request.Context(), &req)
+	if err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if ok && appErr.Code == 409001 {
+			response.Conflict(c, appErr.Message, "")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Created(c, template)
+}
+
+// GetTemplates 获取模型默认定价列表
+// @Summary 获取模型默认定价列表
+// @Description 获取所有模型级默认定价（管理员）
+// @Tags Pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} object{templates=[]PricingTemplateResponse,total=int}
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/pricings/templates [get]
+func (h *Handler) GetTemplates(c *gin.Context) {
+	templates, err := h.service.GetTemplates(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{
+		"templates": templates,
+		"total":     len(templates),
+	})
+}
+
+// UpdateTemplate 更新模型默认定价
+// @Summary 更新模型默认定价
+// @Description 更新指定ID的模型默认定价（管理员）
+// @Tags Pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Param request body UpdatePricingTemplateRequest true "更新请求"
+// @Success 200 {object} PricingTemplateResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/pricings/templates/{id} [put]
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid template ID", "Template ID must be a valid number")
+		return
+	}
+
+	var req UpdatePricingTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	template, err := h.service.UpdateTemplate(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if ok && appErr.Code == 404001 {
+			response.NotFound(c, "Pricing template not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"template": template})
+}
+
+// DeleteTemplate 删除模型默认定价
+// @Summary 删除模型默认定价
+// @Description 删除指定ID的模型默认定价（管理员）
+// @Tags Pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/pricings/templates/{id} [delete]
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid template ID", "Template ID must be a valid number")
+		return
+	}
+
+	if err := h.service.DeleteTemplate(c.Request.Context(), uint(id)); err != nil {
+		appErr, ok := err.(*errors.AppError)
+		if ok && appErr.Code == 404001 {
+			response.NotFound(c, "Pricing template not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Pricing template deleted successfully", nil)
+}
@@ -59,3 +59,36 @@ func (p *Pricing) CalculateOutputCost(tokens int64) float64 {
 func (p *Pricing) CalculateTotalCost(inputTokens, outputTokens int64) float64 {
 	return p.CalculateInputCost(inputTokens) + p.CalculateOutputCost(outputTokens)
 }
+
+// PricingTemplate 模型级默认定价，服务该模型的配置没有单独定价时使用
+type PricingTemplate struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ModelName   string    `gorm:"not null;size:255;uniqueIndex" json:"model_name"`
+	InputPrice  float64   `gorm:"not null;default:0" json:"input_price"`
+	OutputPrice float64   `gorm:"not null;default:0" json:"output_price"`
+	Currency    string    `gorm:"not null;default:'credits';size:20" json:"currency"`
+	Unit        int       `gorm:"not null;default:1000" json:"unit"`
+	IsActive    bool      `gorm:"not null;default:true" json:"is_active"`
+	Description string    `gorm:"size:500" json:"description"`
+}
+
+// TableName 指定表名
+func (PricingTemplate) TableName() string {
+	return "pricing_templates"
+}
+
+// ForAPIConfig 把模板展开为指定配置的定价，用于计费
+func (t *PricingTemplate) ForAPIConfig(apiConfigID uint) *Pricing {
+	return &Pricing{
+		APIConfigID: apiConfigID,
+		ModelName:   t.ModelName,
+		InputPrice:  t.InputPrice,
+		OutputPrice: t.OutputPrice,
+		Currency:    t.Currency,
+		Unit:        t.Unit,
+		IsActive:    t.IsActive,
+		Description: t.Description,
+	}
+}
@@ -24,6 +24,14 @@ type Repository interface {
 	UpdateStatus(ctx context.Context, id uint, isActive bool) error
 	CountAll(ctx context.Context) (int64, error)
 	CountByAPIConfig(ctx context.Context, apiConfigID uint) (int64, error)
+
+	// 模型默认定价
+	CreateTemplate(ctx context.Context, template *PricingTemplate) error
+	UpdateTemplate(ctx context.Context, template *PricingTemplate) error
+	DeleteTemplate(ctx context.Context, id uint) error
+	FindTemplateByID(ctx context.Context, id uint) (*PricingTemplate, error)
+	FindTemplateByModel(ctx context.Context, modelName string) (*PricingTemplate, error)
+	FindAllTemplates(ctx context.Context) ([]*PricingTemplate, error)
 }
 
 // repository 定价仓储实现
@@ -187,3 +195,58 @@ func (r *repository) CountByAPIConfig(ctx context.Context, apiConfigID uint) (in
 		Count(&count).Error
 	return count, err
 }
+
+// CreateTemplate 创建模型默认定价
+func (r *repository) CreateTemplate(ctx context.Context, template *PricingTemplate) error {
+	return r.db.WithContext(ctx).Create(template).Error
+}
+
+// UpdateTemplate 更新模型默认定价
+func (r *repository) UpdateTemplate(ctx context.Context, template *PricingTemplate) error {
+	return r.db.WithContext(ctx).Save(template).Error
+}
+
+// DeleteTemplate 删除模型默认定价
+func (r *repository) DeleteTemplate(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&PricingTemplate{}, id).Error
+}
+
+// FindTemplateByID 根据ID查找模型默认定价
+func (r *repository) FindTemplateByID(ctx context.Context, id uint) (*PricingTemplate, error) {
+	var template PricingTemplate
+	err := r.db.WithContext(ctx).First(&template, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// FindTemplateByModel 根据模型查找默认定价
+func (r *repository) FindTemplateByModel(ctx context.Context, modelName string) (*PricingTemplate, error) {
+	var template PricingTemplate
+	err := r.db.WithContext(ctx).
+		Where("model_name = ?", modelName).
+		First(&template).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &template, nil
+}
+
+// FindAllTemplates 查找所有模型默认定价
+func (r *repository) FindAllTemplates(ctx context.Context) ([]*PricingTemplate, error) {
+	var templates []*PricingTemplate
+	err := r.db.WithContext(ctx).
+		Order("model_name ASC").
+		Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
@@ -21,6 +21,12 @@ type Service interface {
 	DeletePricing(ctx context.Context, id uint) error
 	CalculateCost(ctx context.Context, req *CalculateCostRequest) (*CostCalculationResponse, error)
 	BatchCreatePricings(ctx context.Context, req *BatchCreatePricingRequest) (*BatchCreatePricingResponse, error)
+
+	// 模型默认定价
+	CreateTemplate(ctx context.Context, req *CreatePricingTemplateRequest) (*PricingTemplateResponse, error)
+	GetTemplates(ctx context.Context) ([]*PricingTemplateResponse, error)
+	UpdateTemplate(ctx context.Context, id uint, req *UpdatePricingTemplateRequest) (*PricingTemplateResponse, error)
+	DeleteTemplate(ctx context.Context, id uint) error
 }
 
 // service 定价服务实现
@@ -306,8 +312,21 @@ func (s *service) CalculateCost(ctx context.Context, req *CalculateCostRequest)
 			logger.Error(err))
 		return nil, errors.Wrap(err, 500002, "Failed to find pricing")
 	}
+
+	// 配置没有单独定价时使用模型默认定价
+	var template *PricingTemplate
 	if pricing == nil {
-		return nil, errors.New(404001, "Pricing not found for this model and API config")
+		template, err = s.repo.FindTemplateByModel(ctx, req.ModelName)
+		if err != nil {
+			s.logger.Error("Failed to find pricing template",
+				logger.String("model", req.ModelName),
+				logger.Error(err))
+			return nil, errors.Wrap(err, 500002, "Failed to find pricing template")
+		}
+		if template == nil || !template.IsActive {
+			return nil, errors.New(404001, "Pricing not found for this model and API config")
+		}
+		pricing = template.ForAPIConfig(req.APIConfigID)
 	}
 
 	// 计算成本
@@ -315,7 +334,7 @@ func (s *service) CalculateCost(ctx context.Context, req *CalculateCostRequest)
 	outputCost := pricing.CalculateOutputCost(req.OutputTokens)
 	totalCost := inputCost + outputCost
 
-	return &CostCalculationResponse{
+	resp := &CostCalculationResponse{
 		ModelName:    req.ModelName,
 		InputTokens:  req.InputTokens,
 		OutputTokens: req.OutputTokens,
@@ -324,7 +343,12 @@ func (s *service) CalculateCost(ctx context.Context, req *CalculateCostRequest)
 		TotalCost:    totalCost,
 		Currency:     pricing.Currency,
 		Unit:         pricing.Unit,
-	}, nil
+		Pricing:      pricing.ToResponse(),
+	}
+	if template != nil {
+		resp.Template = template.ToResponse()
+	}
+	return resp, nil
 }
 
 // BatchCreatePricings 批量创建定价
@@ -354,6 +378,136 @@ func (s *service) BatchCreatePricings(ctx context.Context, req *BatchCreatePrici
 	}, nil
 }
 
+// CreateTemplate 创建模型默认定价
+func (s *service) CreateTemplate(ctx context.Context, req *CreatePricingTemplateRequest) (*PricingTemplateResponse, error) {
+	existing, err := s.repo.FindTemplateByModel(ctx, req.ModelName)
+	if err != nil {
+		s.logger.Error("Failed to check existing pricing template",
+			logger.String("model", req.ModelName),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to check existing pricing template")
+	}
+	if existing != nil {
+		return nil, errors.New(409001, "Pricing template already exists for this model")
+	}
+
+	// 设置默认值
+	currency := req.Currency
+	if currency == "" {
+		currency = "credits"
+	}
+	unit := req.Unit
+	if unit == 0 {
+		unit = 1000
+	}
+
+	template := &PricingTemplate{
+		ModelName:   req.ModelName,
+		InputPrice:  req.InputPrice,
+		OutputPrice: req.OutputPrice,
+		Currency:    currency,
+		Unit:        unit,
+		IsActive:    true,
+		Description: req.Description,
+	}
+	if err := s.repo.CreateTemplate(ctx, template); err != nil {
+		s.logger.Error("Failed to create pricing template",
+			logger.String("model", req.ModelName),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to create pricing template")
+	}
+
+	s.logger.Info("Pricing template created successfully",
+		logger.Uint("template_id", template.ID),
+		logger.String("model", template.ModelName))
+
+	return template.ToResponse(), nil
+}
+
+// GetTemplates 获取所有模型默认定价
+func (s *service) GetTemplates(ctx context.Context) ([]*PricingTemplateResponse, error) {
+	templates, err := s.repo.FindAllTemplates(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get pricing templates", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get pricing templates")
+	}
+
+	responses := make([]*PricingTemplateResponse, len(templates))
+	for i, template := range templates {
+		responses[i] = template.ToResponse()
+	}
+	return responses, nil
+}
+
+// UpdateTemplate 更新模型默认定价
+func (s *service) UpdateTemplate(ctx context.Context, id uint, req *UpdatePricingTemplateRequest) (*PricingTemplateResponse, error) {
+	template, err := s.repo.FindTemplateByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get pricing template", logger.Uint("template_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get pricing template")
+	}
+	if template == nil {
+		return nil, errors.New(404001, "Pricing template not found")
+	}
+
+	// 更新字段
+	if req.InputPrice != nil {
+		template.InputPrice = *req.InputPrice
+	}
+	if req.OutputPrice != nil {
+		template.OutputPrice = *req.OutputPrice
+	}
+	if req.Currency != "" {
+		template.Currency = req.Currency
+	}
+	if req.Unit != nil {
+		template.Unit = *req.Unit
+	}
+	if req.IsActive != nil {
+		template.IsActive = *req.IsActive
+	}
+	if req.Description != "" {
+		template.Description = req.Description
+	}
+
+	if err := s.repo.UpdateTemplate(ctx, template); err != nil {
+		s.logger.Error("Failed to update pricing template",
+			logger.Uint("template_id", id),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to update pricing template")
+	}
+
+	s.logger.Info("Pricing template updated successfully",
+		logger.Uint("template_id", id),
+		logger.String("model", template.ModelName))
+
+	return template.ToResponse(), nil
+}
+
+// DeleteTemplate 删除模型默认定价
+func (s *service) DeleteTemplate(ctx context.Context, id uint) error {
+	template, err := s.repo.FindTemplateByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get pricing template", logger.Uint("template_id", id), logger.Error(err))
+		return errors.Wrap(err, 500002, "Failed to get pricing template")
+	}
+	if template == nil {
+		return errors.New(404001, "Pricing template not found")
+	}
+
+	if err := s.repo.DeleteTemplate(ctx, id); err != nil {
+		s.logger.Error("Failed to delete pricing template",
+			logger.Uint("template_id", id),
+			logger.Error(err))
+		return errors.Wrap(err, 500002, "Failed to delete pricing template")
+	}
+
+	s.logger.Info("Pricing template deleted successfully",
+		logger.Uint("template_id", id),
+		logger.String("model", template.ModelName))
+
+	return nil
+}
 
 // toResponseListWithAPIConfig 转换为响应列表并加载 API 配置信息
 func (s *service) toResponseListWithAPIConfig(ctx context.Context, pricings []*Pricing) []*PricingResponse {
@@ -0,0 +1,90 @@
+package pricing
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"testing"
+)
+
+// memRepo 内存中的定价与模型默认定价
+type memRepo struct {
+	Repository
+	pricings  []*Pricing
+	templates []*PricingTemplate
+}
+
+func (r *memRepo) FindByModelAndAPIConfig(ctx context.Context, modelName string, apiConfigID uint) (*Pricing, error) {
+	for _, p := range r.pricings {
+		if p.ModelName == modelName && p.APIConfigID == apiConfigID {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memRepo) FindTemplateByModel(ctx context.Context, modelName string) (*PricingTemplate, error) {
+	for _, t := range r.templates {
+		if t.ModelName == modelName {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memRepo) CreateTemplate(ctx context.Context, template *PricingTemplate) error {
+	template.ID = uint(len(r.templates) + 1)
+	r.templates = append(r.templates, template)
+	return nil
+}
+
+func TestCalculateCost_FallsBackToModelTemplate(t *testing.T) {
+	repo := &memRepo{}
+	svc := NewService(repo, nil, *logger.NewNop())
+	ctx := context.Background()
+
+	if _, err := svc.CreateTemplate(ctx, &CreatePricingTemplateRequest{ModelName: "gpt-4", InputPrice: 10, OutputPrice: 20}); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+
+	resp, err := svc.CalculateCost(ctx, &CalculateCostRequest{ModelName: "gpt-4", APIConfigID: 3, InputTokens: 1000, OutputTokens: 2000})
+	if err != nil {
+		t.Fatalf("Expected the model template to price a config without its own pricing, got %v", err)
+	}
+	if resp.TotalCost != 50 || resp.Unit != 1000 || resp.Currency != "credits" {
+		t.Errorf("Expected template pricing with defaults applied, got %+v", resp)
+	}
+	if resp.Template == nil || resp.Pricing == nil || resp.Pricing.APIConfigID != 3 {
+		t.Errorf("Expected the applied template reported for config 3, got template %+v pricing %+v", resp.Template, resp.Pricing)
+	}
+
+	if _, err := svc.CreateTemplate(ctx, &CreatePricingTemplateRequest{ModelName: "gpt-4", InputPrice: 1, OutputPrice: 1}); err == nil {
+		t.Error("Expected a duplicate template for the same model to be rejected")
+	}
+}
+
+func TestCalculateCost_ConfigPricingOverridesTemplate(t *testing.T) {
+	repo := &memRepo{
+		pricings:  []*Pricing{{ID: 7, APIConfigID: 1, ModelName: "gpt-4", InputPrice: 1, OutputPrice: 2, Unit: 1000, IsActive: true}},
+		templates: []*PricingTemplate{{ID: 1, ModelName: "gpt-4", InputPrice: 10, OutputPrice: 20, Unit: 1000, IsActive: true}},
+	}
+	svc := NewService(repo, nil, *logger.NewNop())
+
+	resp, err := svc.CalculateCost(context.Background(), &CalculateCostRequest{ModelName: "gpt-4", APIConfigID: 1, InputTokens: 1000, OutputTokens: 1000})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.TotalCost != 3 || resp.Pricing.ID != 7 || resp.Template != nil {
+		t.Errorf("Expected the config-specific pricing to win over the template, got %+v", resp)
+	}
+}
+
+func TestCalculateCost_IgnoresInactiveTemplate(t *testing.T) {
+	repo := &memRepo{templates: []*PricingTemplate{{ID: 1, ModelName: "gpt-4", InputPrice: 10, Unit: 1000}}}
+	svc := NewService(repo, nil, *logger.NewNop())
+
+	_, err := svc.CalculateCost(context.Background(), &CalculateCostRequest{ModelName: "gpt-4", APIConfigID: 1})
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != 404001 {
+		t.Errorf("Expected a disabled template to leave the model unpriced, got %v", err)
+	}
+}
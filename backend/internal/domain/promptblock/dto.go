@@ -0,0 +1,10 @@
+package promptblock
+
+// CreatePromptBlockRequest 创建屏蔽规则请求
+// hash 类型可以直接传指纹（pattern），也可以传提示词原文（prompt）由服务端计算指纹
+type CreatePromptBlockRequest struct {
+	Type    string `json:"type" binding:"required,oneof=hash regex"`
+	Pattern string `json:"pattern" binding:"omitempty,max=10000"`
+	Prompt  string `json:"prompt" binding:"omitempty"`
+	Reason  string `json:"reason" binding:"omitempty,max=1000"`
+}
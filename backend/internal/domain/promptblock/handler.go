@@ -0,0 +1,99 @@
+package promptblock
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler 提示词屏蔽规则处理器
+type Handler struct {
+	service Service
+}
+
+// NewHandler 创建提示词屏蔽规则处理器
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// List 查询屏蔽规则
+// @Summary 查询提示词屏蔽规则
+// @Description 列出所有提示词屏蔽规则及命中次数（管理员）
+// @Tags PromptBlocklist
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]PromptBlock}
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/prompt-blocklist [get]
+func (h *Handler) List(c *gin.Context) {
+	blocks, err := h.service.List(c.Request.Context())
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+	response.Success(c, blocks)
+}
+
+// Create 创建屏蔽规则
+// @Summary 创建提示词屏蔽规则
+// @Description 按提示词指纹（精确匹配）或正则表达式屏蔽请求，命中的请求在调用上游前返回 403（管理员）
+// @Tags PromptBlocklist
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreatePromptBlockRequest true "屏蔽规则"
+// @Success 201 {object} response.Response{data=PromptBlock}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/prompt-blocklist [post]
+func (h *Handler) Create(c *gin.Context) {
+	var req CreatePromptBlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	block, err := h.service.Create(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, "Invalid prompt block rule", err.(*errors.AppError).Details)
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+	response.Created(c, block)
+}
+
+// Delete 删除屏蔽规则
+// @Summary 删除提示词屏蔽规则
+// @Tags PromptBlocklist
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "规则ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/admin/prompt-blocklist/{id} [delete]
+func (h *Handler) Delete(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID", "rule ID must be a valid number")
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			response.NotFound(c, "Prompt block rule not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "Prompt block rule deleted", nil)
+}
@@ -0,0 +1,32 @@
+package promptblock
+
+import (
+	"time"
+)
+
+// 屏蔽规则类型
+const (
+	TypeHash  = "hash"  // 规范化后提示词的 SHA-256 指纹精确匹配
+	TypeRegex = "regex" // 正则表达式匹配提示词原文
+)
+
+// PromptBlock 提示词屏蔽规则，命中的请求在调用上游前被拒绝
+type PromptBlock struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Type     string `gorm:"size:10;not null" json:"type"`
+	Pattern  string `gorm:"type:text;not null" json:"pattern"` // hash 类型为小写十六进制指纹
+	Reason   string `gorm:"type:text" json:"reason,omitempty"` // 返回给客户端的策略说明
+	IsActive bool   `gorm:"not null;default:true" json:"is_active"`
+
+	// 命中统计
+	HitCount  int64      `gorm:"not null;default:0" json:"hit_count"`
+	LastHitAt *time.Time `json:"last_hit_at,omitempty"`
+}
+
+// TableName 指定表名
+func (PromptBlock) TableName() string {
+	return "prompt_blocklist"
+}
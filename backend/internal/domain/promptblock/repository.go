@@ -0,0 +1,60 @@
+package promptblock
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository 提示词屏蔽规则仓储接口
+type Repository interface {
+	List(ctx context.Context) ([]*PromptBlock, error)
+	FindActive(ctx context.Context) ([]*PromptBlock, error)
+	Create(ctx context.Context, block *PromptBlock) error
+	Delete(ctx context.Context, id uint) (bool, error)
+	RecordHit(ctx context.Context, id uint) error
+}
+
+// repository 提示词屏蔽规则仓储实现
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建提示词屏蔽规则仓储
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// List 按创建顺序列出全部规则
+func (r *repository) List(ctx context.Context) ([]*PromptBlock, error) {
+	var blocks []*PromptBlock
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&blocks).Error
+	return blocks, err
+}
+
+// FindActive 查询启用的规则
+func (r *repository) FindActive(ctx context.Context) ([]*PromptBlock, error) {
+	var blocks []*PromptBlock
+	err := r.db.WithContext(ctx).Where("is_active = ?", true).Order("id ASC").Find(&blocks).Error
+	return blocks, err
+}
+
+// Create 创建规则
+func (r *repository) Create(ctx context.Context, block *PromptBlock) error {
+	return r.db.WithContext(ctx).Create(block).Error
+}
+
+// Delete 删除规则，不存在时返回 false
+func (r *repository) Delete(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Delete(&PromptBlock{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RecordHit 累加命中次数并记录最近命中时间
+func (r *repository) RecordHit(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&PromptBlock{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"hit_count":   gorm.Expr("hit_count + 1"),
+		"last_hit_at": time.Now(),
+	}).Error
+}
@@ -0,0 +1,207 @@
+package promptblock
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// matcherRefresh 内存中的规则定期从数据库刷新，使其他实例的修改生效
+const matcherRefresh = time.Minute
+
+// errBlockNotFound 屏蔽规则不存在
+var errBlockNotFound = errors.ErrNotFound.WithDetails("prompt block rule not found")
+
+// hashPattern 指纹格式：SHA-256 小写十六进制
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Service 提示词屏蔽服务接口
+type Service interface {
+	List(ctx context.Context) ([]*PromptBlock, error)
+	Create(ctx context.Context, req *CreatePromptBlockRequest) (*PromptBlock, error)
+	Delete(ctx context.Context, id uint) error
+
+	// Check 检查提示词是否命中屏蔽规则，命中时返回规则并记录命中，未命中返回 nil
+	Check(ctx context.Context, prompts []string) (*PromptBlock, error)
+}
+
+// matcher 启用规则的内存索引：指纹走哈希查找，正则依次匹配
+type matcher struct {
+	hashes   map[string]*PromptBlock
+	patterns []compiledPattern
+	loadedAt time.Time
+}
+
+type compiledPattern struct {
+	re    *regexp.Regexp
+	block *PromptBlock
+}
+
+// service 提示词屏蔽服务实现
+type service struct {
+	repo   Repository
+	logger logger.Logger
+
+	mu      sync.RWMutex
+	matcher *matcher
+}
+
+// NewService 创建提示词屏蔽服务
+func NewService(repo Repository, logger logger.Logger) Service {
+	return &service{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Fingerprint 计算提示词指纹：忽略大小写、合并空白后取 SHA-256
+func Fingerprint(prompt string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// List 列出全部屏蔽规则
+func (s *service) List(ctx context.Context) ([]*PromptBlock, error) {
+	blocks, err := s.repo.List(ctx)
+	if err != nil {
+		s.logger.Error("Failed to list prompt block rules", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to list prompt block rules")
+	}
+	return blocks, nil
+}
+
+// Create 创建屏蔽规则，hash 类型传提示词原文时由服务端计算指纹
+func (s *service) Create(ctx context.Context, req *CreatePromptBlockRequest) (*PromptBlock, error) {
+	block := &PromptBlock{Type: req.Type, Pattern: req.Pattern, Reason: req.Reason, IsActive: true}
+	switch req.Type {
+	case TypeHash:
+		if req.Prompt != "" {
+			block.Pattern = Fingerprint(req.Prompt)
+		}
+		block.Pattern = strings.ToLower(block.Pattern)
+		if !hashPattern.MatchString(block.Pattern) {
+			return nil, errors.ErrInvalidParam.WithDetails("hash rules need a SHA-256 hex pattern or the prompt to fingerprint")
+		}
+	case TypeRegex:
+		if _, err := regexp.Compile(block.Pattern); err != nil || block.Pattern == "" {
+			return nil, errors.ErrInvalidParam.WithDetails("invalid regex pattern")
+		}
+	}
+
+	if err := s.repo.Create(ctx, block); err != nil {
+		s.logger.Error("Failed to create prompt block rule", logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to create prompt block rule")
+	}
+	s.invalidate()
+
+	s.logger.Info("Prompt block rule created",
+		logger.Uint("id", block.ID),
+		logger.String("type", block.Type))
+	return block, nil
+}
+
+// Delete 删除屏蔽规则
+func (s *service) Delete(ctx context.Context, id uint) error {
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, 500002, "Failed to delete prompt block rule")
+	}
+	if !deleted {
+		return errBlockNotFound
+	}
+	s.invalidate()
+	return nil
+}
+
+// Check 检查提示词是否命中屏蔽规则，先按指纹查找再匹配正则
+func (s *service) Check(ctx context.Context, prompts []string) (*PromptBlock, error) {
+	m, err := s.loadMatcher(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.hashes) == 0 && len(m.patterns) == 0 {
+		return nil, nil
+	}
+
+	for _, prompt := range prompts {
+		if block, ok := m.hashes[Fingerprint(prompt)]; ok {
+			s.recordHit(block)
+			return block, nil
+		}
+		for _, p := range m.patterns {
+			if p.re.MatchString(prompt) {
+				s.recordHit(p.block)
+				return p.block, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// loadMatcher 返回内存中的规则索引，过期或规则变更后重新加载
+func (s *service) loadMatcher(ctx context.Context) (*matcher, error) {
+	s.mu.RLock()
+	m := s.matcher
+	s.mu.RUnlock()
+	if m != nil && time.Since(m.loadedAt) < matcherRefresh {
+		return m, nil
+	}
+
+	blocks, err := s.repo.FindActive(ctx)
+	if err != nil {
+		if m != nil {
+			// 刷新失败时继续使用旧规则
+			s.logger.Warn("Failed to refresh prompt block rules", logger.Error(err))
+			return m, nil
+		}
+		return nil, errors.Wrap(err, 500002, "Failed to load prompt block rules")
+	}
+
+	m = &matcher{hashes: make(map[string]*PromptBlock), loadedAt: time.Now()}
+	for _, block := range blocks {
+		switch block.Type {
+		case TypeHash:
+			m.hashes[block.Pattern] = block
+		case TypeRegex:
+			re, err := regexp.Compile(block.Pattern)
+			if err != nil {
+				s.logger.Warn("Skipping invalid prompt block regex", logger.Uint("id", block.ID), logger.Error(err))
+				continue
+			}
+			m.patterns = append(m.patterns, compiledPattern{re: re, block: block})
+		}
+	}
+
+	s.mu.Lock()
+	s.matcher = m
+	s.mu.Unlock()
+	return m, nil
+}
+
+// invalidate 规则变更后丢弃内存索引，下次检查时重新加载
+func (s *service) invalidate() {
+	s.mu.Lock()
+	s.matcher = nil
+	s.mu.Unlock()
+}
+
+// recordHit 异步累加规则命中次数，不影响请求响应时间
+func (s *service) recordHit(block *PromptBlock) {
+	s.logger.Warn("Prompt blocked by policy",
+		logger.Uint("block_id", block.ID),
+		logger.String("type", block.Type))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.repo.RecordHit(ctx, block.ID); err != nil {
+			s.logger.Warn("Failed to record prompt block hit", logger.Uint("block_id", block.ID), logger.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,16 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/accountpool"
+	"api-aggregator/backend/internal/domain/apiconfig"
+)
+
+// configPools 配置使用的账号池及权重，未设置多个账号池时只有 AccountPoolID
+func configPools(cfg *apiconfig.APIConfig) []accountpool.WeightedPool {
+	weights := cfg.PoolWeights()
+	pools := make([]accountpool.WeightedPool, len(weights))
+	for i, w := range weights {
+		pools[i] = accountpool.WeightedPool{PoolID: w.PoolID, Weight: w.Weight}
+	}
+	return pools
+}
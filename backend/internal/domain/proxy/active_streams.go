@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxActiveStreams 最多跟踪的活跃流数量，超出后新流照常处理但不出现在列表中
+const maxActiveStreams = 10000
+
+// StreamIDHeader 流式响应头，返回活跃流 ID，客户端可凭此调用 /v1/cancel/:stream_id 取消该流
+const StreamIDHeader = "X-Prism-Stream-Id"
+
+// streamCancelledEvent 客户端取消流时追加到流末尾的事件（OpenAI SSE 格式）
+var streamCancelledEvent = []byte("data: {\"error\":{\"message\":\"Stream cancelled by client\",\"type\":\"cancelled\",\"code\":\"stream_cancelled\"}}\n\ndata: [DONE]\n\n")
+
+// streamTerminatedEvent 管理员终止流时追加到流末尾的终止事件（OpenAI SSE 格式，由协议转换器统一处理）
+var streamTerminatedEvent = []byte("data: {\"error\":{\"message\":\"Stream terminated by administrator\",\"type\":\"server_error\",\"code\":\"stream_terminated\"}}\n\ndata: [DONE]\n\n")
+
+// ActiveStream 活跃流式请求的快照
+type ActiveStream struct {
+	ID            string    `json:"id"`
+	RequestID     string    `json:"request_id,omitempty"`
+	UserID        uint      `json:"user_id"`
+	APIKeyID      uint      `json:"api_key_id"`
+	APIConfigID   uint      `json:"api_config_id"`
+	Model         string    `json:"model"`
+	Protocol      string    `json:"protocol"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    int64     `json:"duration_ms"`
+	BytesStreamed int64     `json:"bytes_streamed"`
+}
+
+// activeStream 注册表中的一条流，bytes 由流处理协程更新
+type activeStream struct {
+	info      ActiveStream
+	bytes     atomic.Int64
+	terminate func()
+	cancel    func()
+}
+
+// addBytes 累计已下发给客户端的字节数，未被跟踪的流为 nil
+func (a *activeStream) addBytes(n int) {
+	if a != nil {
+		a.bytes.Add(int64(n))
+	}
+}
+
+// streamRegistry 活跃流注册表，流开始时注册、结束时注销
+type streamRegistry struct {
+	mu      sync.RWMutex
+	streams map[string]*activeStream
+	now     func() time.Time
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{streams: make(map[string]*activeStream), now: time.Now}
+}
+
+// register 登记一条流并分配 ID，terminate 用于管理员强制结束该流，cancel 用于客户端取消；注册表已满时返回 nil
+func (r *streamRegistry) register(info ActiveStream, terminate, cancel func()) *activeStream {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.streams) >= maxActiveStreams {
+		return nil
+	}
+	info.ID = uuid.New().String()
+	info.StartedAt = r.now()
+	entry := &activeStream{info: info, terminate: terminate, cancel: cancel}
+	r.streams[info.ID] = entry
+	return entry
+}
+
+// deregister 注销流，未被跟踪的流为 nil
+func (r *streamRegistry) deregister(entry *activeStream) {
+	if r == nil || entry == nil {
+		return
+	}
+	r.mu.Lock()
+	delete(r.streams, entry.info.ID)
+	r.mu.Unlock()
+}
+
+// list 返回所有活跃流的快照，按开始时间排序
+func (r *streamRegistry) list() []*ActiveStream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := r.now()
+	streams := make([]*ActiveStream, 0, len(r.streams))
+	for _, entry := range r.streams {
+		snapshot := entry.info
+		snapshot.DurationMs = now.Sub(snapshot.StartedAt).Milliseconds()
+		snapshot.BytesStreamed = entry.bytes.Load()
+		streams = append(streams, &snapshot)
+	}
+	sort.Slice(streams, func(i, j int) bool {
+		return streams[i].StartedAt.Before(streams[j].StartedAt)
+	})
+	return streams
+}
+
+// terminate 强制结束指定流，流不存在时返回 false；流随后在自己的协程中结束并注销
+func (r *streamRegistry) terminate(id string) bool {
+	r.mu.RLock()
+	entry, ok := r.streams[id]
+	r.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	entry.terminate()
+	return true
+}
+
+// cancel 客户端取消自己的流，流不存在或属于其他用户时返回 false
+func (r *streamRegistry) cancel(id string, userID uint) bool {
+	r.mu.RLock()
+	entry, ok := r.streams[id]
+	r.mu.RUnlock()
+	if !ok || entry.info.UserID != userID {
+		return false
+	}
+	entry.cancel()
+	return true
+}
+
+// Cancel 客户端取消流：与强制结束相同地关闭上游并按已下发内容计费，输出取消事件
+func (w *StreamWrapper) Cancel() {
+	w.cancelRequested.Store(true)
+	w.Terminate()
+}
+
+// Terminate 强制结束流：关闭上游流，已下发内容按估算计费后输出终止事件
+func (w *StreamWrapper) Terminate() {
+	if w.terminateFired.Swap(true) {
+		return
+	}
+	w.stopIdleTimer()
+	w.stopMaxDurationTimer()
+	// 关闭上游使阻塞中的 Read 立即返回
+	w.reader.Close()
+}
+
+// ActiveStreams 返回当前活跃的流式请求
+func (s *service) ActiveStreams() []*ActiveStream {
+	if s.streams == nil {
+		return []*ActiveStream{}
+	}
+	return s.streams.list()
+}
+
+// TerminateStream 强制结束指定的流式请求
+func (s *service) TerminateStream(id string) error {
+	if s.streams == nil || !s.streams.terminate(id) {
+		return errors.ErrNotFound.WithDetails("Active stream not found")
+	}
+	s.logger.Warn("Active stream terminated by administrator", logger.String("stream_id", id))
+	return nil
+}
+
+// CancelStream 客户端取消自己的流式请求
+func (s *service) CancelStream(userID uint, id string) error {
+	if s.streams == nil || !s.streams.cancel(id, userID) {
+		return errors.ErrNotFound.WithDetails("Active stream not found")
+	}
+	s.logger.Info("Active stream cancelled by client",
+		logger.String("stream_id", id),
+		logger.Uint("user_id", userID))
+	return nil
+}
@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamRegistry_TracksLifecycle(t *testing.T) {
+	r := newStreamRegistry()
+	start := time.Unix(1_700_000_000, 0)
+	r.now = func() time.Time { return start }
+
+	entry := r.register(ActiveStream{UserID: 3, Model: "gpt-4"}, func() {}, func() {})
+	entry.addBytes(120)
+	entry.addBytes(30)
+
+	r.now = func() time.Time { return start.Add(1500 * time.Millisecond) }
+	streams := r.list()
+	if len(streams) != 1 || streams[0].ID == "" || streams[0].UserID != 3 {
+		t.Fatalf("Expected the registered stream listed, got %+v", streams)
+	}
+	if streams[0].BytesStreamed != 150 || streams[0].DurationMs != 1500 {
+		t.Errorf("Expected 150 bytes over 1500ms, got %d bytes over %dms", streams[0].BytesStreamed, streams[0].DurationMs)
+	}
+
+	r.deregister(entry)
+	if len(r.list()) != 0 {
+		t.Error("Expected the stream removed after completion")
+	}
+	if r.terminate(entry.info.ID) {
+		t.Error("Expected terminating a finished stream to report not found")
+	}
+}
+
+func TestStreamRegistry_Bounded(t *testing.T) {
+	r := newStreamRegistry()
+	for i := 0; i < maxActiveStreams; i++ {
+		r.register(ActiveStream{}, func() {}, func() {})
+	}
+	overflow := r.register(ActiveStream{}, func() {}, func() {})
+	if overflow != nil {
+		t.Fatal("Expected registration to be refused once the registry is full")
+	}
+	// 未被跟踪的流仍可安全地计数和注销
+	overflow.addBytes(10)
+	r.deregister(overflow)
+	if len(r.list()) != maxActiveStreams {
+		t.Errorf("Expected %d tracked streams, got %d", maxActiveStreams, len(r.list()))
+	}
+}
+
+func TestStreamWrapper_TerminateBillsDeliveredTokens(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	chunk := fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", strings.Repeat("x", 40))
+	upstream := &stallingReader{chunks: []string{chunk, chunk}, closed: make(chan struct{})}
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	w := NewStreamWrapper(upstream, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(w)
+		done <- out
+	}()
+	time.Sleep(20 * time.Millisecond)
+	w.Terminate()
+
+	var out []byte
+	select {
+	case out = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the terminated stream to end")
+	}
+	w.Close()
+
+	if !strings.HasSuffix(string(out), string(streamTerminatedEvent)) {
+		t.Errorf("Expected terminal event at end of stream, got %q", out)
+	}
+	if q.deducted != 20 {
+		t.Errorf("Expected billing for 20 delivered tokens, deducted %d", q.deducted)
+	}
+}
+
+func TestHandler_StreamRegisteredUntilTerminated(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"partial\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	svc.pricingService = &perTokenPricing{}
+	svc.streams = newStreamRegistry()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("user_id", uint(5))
+		c.Set("api_key_id", uint(9))
+		c.Next()
+	}, NewHandler(svc).ChatCompletionsOpenAI)
+	gateway := httptest.NewServer(engine)
+	defer gateway.Close()
+
+	type result struct {
+		body string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+			strings.NewReader(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		done <- result{body: string(body), err: err}
+	}()
+
+	var streams []*ActiveStream
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if streams = svc.ActiveStreams(); len(streams) == 1 && streams[0].BytesStreamed > 0 {
+			break
+		}
+	}
+	if len(streams) != 1 {
+		t.Fatalf("Expected the running stream in the registry, got %+v", streams)
+	}
+	if s := streams[0]; s.UserID != 5 || s.APIKeyID != 9 || s.APIConfigID != 1 || s.Model != "gpt-4" || s.Protocol != "openai" {
+		t.Errorf("Unexpected stream snapshot %+v", s)
+	}
+
+	if err := svc.TerminateStream(streams[0].ID); err != nil {
+		t.Fatalf("TerminateStream: %v", err)
+	}
+	select {
+	case res := <-done:
+		if res.err != nil {
+			t.Fatalf("Client read failed: %v", res.err)
+		}
+		if !strings.Contains(res.body, "partial") || !strings.Contains(res.body, "stream_terminated") {
+			t.Errorf("Expected delivered content followed by the termination event, got %q", res.body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the stream to end after termination")
+	}
+
+	if got := svc.ActiveStreams(); len(got) != 0 {
+		t.Errorf("Expected the stream deregistered after completion, got %+v", got)
+	}
+	if err := svc.TerminateStream(streams[0].ID); err == nil {
+		t.Error("Expected terminating an unknown stream to fail")
+	}
+}
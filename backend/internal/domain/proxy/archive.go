@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/archive"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// AlertTypeArchiveFailure 合规归档写入失败告警
+const AlertTypeArchiveFailure = "compliance_archive_failure"
+
+// SetArchiver 启用合规归档：脱敏后的请求和响应异步写入只追加的归档，与请求日志及其保留策略无关
+func (s *service) SetArchiver(archiver *archive.Archiver) {
+	s.archiver = archiver
+	archiver.OnFailure(s.alertArchiveFailure)
+}
+
+// checkArchive 归档配置为故障关闭且当前不可用时拒绝请求
+func (s *service) checkArchive() error {
+	if s.archiver == nil || s.archiver.Available() {
+		return nil
+	}
+	return errors.ErrServiceUnavailable.WithDetails("compliance archive is unavailable")
+}
+
+// archiveResponse 归档一次非流式请求及其（脱敏后的）响应或错误
+func (s *service) archiveResponse(req *ProxyRequest, resp *adapter.ChatResponse, callErr error) {
+	if s.archiver == nil {
+		return
+	}
+	record := s.archiveRecord(req)
+	if resp != nil {
+		record.Response, _ = json.Marshal(resp)
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+	s.submitArchive(record)
+}
+
+// archiveStream 归档一次流式请求，响应为脱敏后转发的 OpenAI 格式 SSE 数据
+func (s *service) archiveStream(req *ProxyRequest, apiConfigID uint, body []byte) {
+	if s.archiver == nil {
+		return
+	}
+	record := s.archiveRecord(req)
+	record.APIConfigID = apiConfigID
+	record.Stream = true
+	record.Response, _ = json.Marshal(string(body))
+	s.submitArchive(record)
+}
+
+// archiveRecord 构建请求部分的归档记录
+func (s *service) archiveRecord(req *ProxyRequest) *archive.Record {
+	request, _ := json.Marshal(req.ChatRequest)
+	return &archive.Record{
+		Timestamp: time.Now().UTC(),
+		UserID:    req.UserID,
+		APIKeyID:  req.APIKeyID,
+		Model:     req.Model,
+		Endpoint:  req.Endpoint,
+		Request:   request,
+	}
+}
+
+// submitArchive 将记录放入归档队列，失败由归档器标记并告警
+func (s *service) submitArchive(record *archive.Record) {
+	if err := s.archiver.Archive(record); err != nil {
+		s.logger.Error("Failed to queue compliance archive record",
+			logger.Uint("user_id", record.UserID),
+			logger.String("model", record.Model),
+			logger.Error(err))
+	}
+}
+
+// alertArchiveFailure 归档由健康变为不健康时发送告警
+func (s *service) alertArchiveFailure(err error) {
+	url := s.runtimeConfig.Get().GetAlertWebhookURL()
+	if url == "" {
+		return
+	}
+
+	event := &alert.Event{
+		Type:    AlertTypeArchiveFailure,
+		Message: "Compliance archive write failed: " + err.Error(),
+		Data: map[string]interface{}{
+			"error":   err.Error(),
+			"dropped": s.archiver.Dropped(),
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if sendErr := s.alertNotifier.Send(ctx, url, event); sendErr != nil {
+		s.log(ctx).Warn("Failed to send archive failure alert", logger.Error(sendErr))
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"api-aggregator/backend/pkg/archive"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/redact"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingUpstream 返回固定内容并记录调用次数
+func countingUpstream(content string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON(content))
+	}))
+}
+
+// unwritableSink 始终写入失败的归档存储
+type unwritableSink struct{}
+
+func (unwritableSink) Append(line []byte) error { return os.ErrPermission }
+func (unwritableSink) Close() error             { return nil }
+
+func TestChatCompletions_ArchivesRedactedExchange(t *testing.T) {
+	var calls int32
+	server := countingUpstream("card 4111-1111-1111-1111 on file", &calls)
+	defer server.Close()
+	svc, _ := newFailoverTestService(0, failoverConfig(1, server.URL, 0))
+
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	sink, err := archive.OpenFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	archiver := archive.New(sink, archive.Options{}, *logger.NewNop())
+	archiver.Start()
+	svc.SetArchiver(archiver)
+
+	redactor, _ := redact.New([]string{`\d{4}-\d{4}-\d{4}-\d{4}`})
+	for i := 0; i < 2; i++ {
+		req := failoverRequest()
+		req.APIKeyID = 9
+		req.Redactor = redactor
+		if _, err := svc.ChatCompletions(context.Background(), req); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := archiver.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if count, err := archive.Verify(bytes.NewReader(data)); err != nil || count != 2 {
+		t.Fatalf("Expected 2 hash-chained records, got %d (%v)", count, err)
+	}
+	var rec archive.Record
+	json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &rec)
+	if rec.APIKeyID != 9 || rec.Model != "gpt-4" || len(rec.Hash) != 64 || !strings.Contains(string(rec.Request), "hello") {
+		t.Errorf("Expected the request archived with its key, model and hash, got %+v", rec)
+	}
+	if strings.Contains(string(rec.Response), "4111") {
+		t.Errorf("Expected the archived response to be redacted, got %s", rec.Response)
+	}
+}
+
+func TestChatCompletions_FailClosedArchiveBlocksRequests(t *testing.T) {
+	var calls int32
+	server := countingUpstream("ok", &calls)
+	defer server.Close()
+	svc, _ := newFailoverTestService(0, failoverConfig(1, server.URL, 0))
+
+	archiver := archive.New(unwritableSink{}, archive.Options{FailClosed: true, RetryInterval: time.Millisecond}, *logger.NewNop())
+	archiver.Start()
+	svc.SetArchiver(archiver)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		archiver.Close(ctx)
+	}()
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("Expected the first request to pass while the archive is healthy, got %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for archiver.Available() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := svc.ChatCompletions(context.Background(), failoverRequest())
+	if !errors.Is(err, errors.ErrServiceUnavailable) {
+		t.Fatalf("Expected requests rejected while the archive is down, got %v", err)
+	}
+	if _, err := svc.ChatCompletionsStream(context.Background(), failoverRequest()); !errors.Is(err, errors.ErrServiceUnavailable) {
+		t.Errorf("Expected stream requests rejected while the archive is down, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expected no upstream call while the archive is down, got %d calls", n)
+	}
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// 自动续写次数：请求未指定时的默认值与允许的最大值
+const (
+	defaultMaxContinuations = 3
+	maxContinuationsLimit   = 10
+)
+
+// continuationPrompt 不支持 assistant 预填充的上游用这条 user 消息要求模型接着输出
+const continuationPrompt = "Continue exactly where you left off. Do not repeat any previous text."
+
+// autoContinueParams 请求体中的自动续写扩展参数
+type autoContinueParams struct {
+	AutoContinue     bool `json:"auto_continue"`
+	MaxContinuations int  `json:"max_continuations"` // 最多续写次数，默认 3
+}
+
+// parseAutoContinueParams 从原始请求体解析续写次数上限，未开启或解析失败时返回 0
+func parseAutoContinueParams(rawBody []byte) int {
+	var params autoContinueParams
+	if json.Unmarshal(rawBody, &params) != nil || !params.AutoContinue {
+		return 0
+	}
+	switch {
+	case params.MaxContinuations <= 0:
+		return defaultMaxContinuations
+	case params.MaxContinuations > maxContinuationsLimit:
+		return maxContinuationsLimit
+	}
+	return params.MaxContinuations
+}
+
+// truncatedContent 响应因 max_tokens 截断且只有文本输出时返回已生成的内容，否则返回 false
+func truncatedContent(resp *adapter.ChatResponse) (string, bool) {
+	if resp == nil || len(resp.Choices) != 1 {
+		return "", false
+	}
+	choice := resp.Choices[0]
+	if choice.FinishReason != "length" || len(choice.Message.ToolCalls) > 0 {
+		return "", false
+	}
+	content := adapter.GetContentAsString(choice.Message.Content)
+	return content, content != ""
+}
+
+// chatCompletionWithContinuation 响应因 max_tokens 截断时继续请求上游并拼接输出，直到自然结束或达到续写次数上限
+// 每次续写都是一次完整的请求：单独计费、单独记录请求日志；续写失败时返回已拼接的内容
+// 脱敏在拼接完成后统一进行，避免把脱敏后的文本作为续写上下文发给上游
+func (s *service) chatCompletionWithContinuation(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	base := adapter.CloneChatRequest(req.ChatRequest)
+	redactor := req.Redactor
+	req.Redactor = nil
+
+	resp, err := s.chatCompletion(ctx, req)
+	if err != nil {
+		req.Redactor = redactor
+		return nil, err
+	}
+
+	merged := *resp
+	merged.Choices = append([]adapter.ChatChoice(nil), resp.Choices...)
+	content, truncated := truncatedContent(resp)
+	continuations := 0
+	for truncated && continuations < req.MaxContinuations && ctx.Err() == nil {
+		next, err := s.chatCompletion(ctx, continuationRequest(req, base, content))
+		if err != nil {
+			s.log(ctx).Warn("Auto-continuation failed, returning truncated output",
+				logger.Int("continuations", continuations),
+				logger.Error(err))
+			break
+		}
+		continuations++
+
+		part := ""
+		if len(next.Choices) > 0 {
+			part = adapter.GetContentAsString(next.Choices[0].Message.Content)
+			merged.Choices[0].FinishReason = next.Choices[0].FinishReason
+		}
+		content += part
+		merged.Usage.PromptTokens += next.Usage.PromptTokens
+		merged.Usage.CompletionTokens += next.Usage.CompletionTokens
+		merged.Usage.TotalTokens += next.Usage.TotalTokens
+		_, truncated = truncatedContent(next)
+		if part == "" {
+			break
+		}
+	}
+
+	if continuations > 0 {
+		merged.Choices[0].Message.Content = content
+		merged.Cached = false
+		s.log(ctx).Info("✓ Truncated response auto-continued",
+			logger.String("model", req.Model),
+			logger.Int("continuations", continuations),
+			logger.String("finish_reason", merged.Choices[0].FinishReason))
+	}
+	req.Redactor = redactor
+	return s.applyRedaction(ctx, req, &merged), nil
+}
+
+// continuationRequest 构建续写请求：原始对话加上已生成的内容，不读写响应缓存
+// 续写提示固定不变，缓存会把其他对话的续写内容当作语义命中拼接进来
+// Anthropic 支持 assistant 预填充，模型直接接着最后一条 assistant 消息输出；其余上游追加一条要求继续的 user 消息
+func continuationRequest(req *ProxyRequest, base *adapter.ChatRequest, content string) *ProxyRequest {
+	chatReq := adapter.CloneChatRequest(base)
+	chatReq.Model = req.Model
+	if req.Provider == "anthropic" {
+		// Anthropic 不接受以空白结尾的预填充内容
+		chatReq.Messages = append(chatReq.Messages, adapter.Message{Role: "assistant", Content: strings.TrimRight(content, " \t\r\n")})
+	} else {
+		chatReq.Messages = append(chatReq.Messages,
+			adapter.Message{Role: "assistant", Content: content},
+			adapter.Message{Role: "user", Content: continuationPrompt})
+	}
+
+	return &ProxyRequest{
+		UserID:             req.UserID,
+		APIKeyID:           req.APIKeyID,
+		Model:              req.Model,
+		ChatRequest:        chatReq,
+		NoLog:              req.NoLog,
+		ProviderPreference: req.ProviderPreference,
+		RoutingMode:        req.RoutingMode,
+		MaxHistory:         req.MaxHistory,
+		HistoryPolicy:      req.HistoryPolicy,
+		MaxContextTokens:   req.MaxContextTokens,
+		ContextTruncation:  req.ContextTruncation,
+		NoSemanticCache:    req.NoSemanticCache,
+		NoCache:            true,
+		RequestID:          req.RequestID,
+		Endpoint:           req.Endpoint,
+	}
+}
@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// truncatingUpstream 依次返回 parts 中的内容，除最后一段外都以 length 结束
+type truncatingUpstream struct {
+	parts  []string
+	bodies []adapter.ChatRequest
+}
+
+func (u *truncatingUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body adapter.ChatRequest
+	data, _ := io.ReadAll(r.Body)
+	json.Unmarshal(data, &body)
+	u.bodies = append(u.bodies, body)
+
+	i := len(u.bodies) - 1
+	finish := "length"
+	if i >= len(u.parts)-1 {
+		i, finish = len(u.parts)-1, "stop"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-1",
+		"model":   "gpt-4",
+		"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": u.parts[i]}, "finish_reason": finish}},
+		"usage":   map[string]int{"prompt_tokens": 5, "completion_tokens": 5, "total_tokens": 10},
+	})
+}
+
+func continuationRequestFor(maxContinuations int) *ProxyRequest {
+	req := historyRequest(1)
+	req.MaxContinuations = maxContinuations
+	return req
+}
+
+func TestParseAutoContinueParams(t *testing.T) {
+	cases := map[string]int{
+		`{"model":"m"}`:                                 0,
+		`{"auto_continue":true}`:                        defaultMaxContinuations,
+		`{"auto_continue":true,"max_continuations":2}`:  2,
+		`{"auto_continue":true,"max_continuations":99}`: maxContinuationsLimit,
+		`{"auto_continue":false,"max_continuations":5}`: 0,
+	}
+	for body, want := range cases {
+		if got := parseAutoContinueParams([]byte(body)); got != want {
+			t.Errorf("%s: expected %d, got %d", body, want, got)
+		}
+	}
+}
+
+func TestChatCompletions_AutoContinueConcatenates(t *testing.T) {
+	upstream := &truncatingUpstream{parts: []string{"Once upon ", "a time ", "the end."}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	svc, l := newFailoverTestService(0, failoverConfig(1, server.URL, 0))
+
+	resp, err := svc.ChatCompletions(context.Background(), continuationRequestFor(5))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != "Once upon a time the end." {
+		t.Errorf("Expected the parts concatenated, got %q", got)
+	}
+	if resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens != 30 {
+		t.Errorf("Expected finish_reason stop and usage summed to 30, got %s / %d", resp.Choices[0].FinishReason, resp.Usage.TotalTokens)
+	}
+
+	// 每次续写单独计费和记录日志，并把已生成的内容作为上下文
+	if len(upstream.bodies) != 3 || len(l.created) != 3 {
+		t.Fatalf("Expected 3 upstream calls and 3 request logs, got %d and %d", len(upstream.bodies), len(l.created))
+	}
+	last := upstream.bodies[2].Messages
+	if n := len(last); n != 4 || adapter.GetContentAsString(last[2].Content) != "Once upon a time " || last[3].Content != continuationPrompt {
+		t.Errorf("Expected the generated text and a continuation prompt appended, got %+v", last)
+	}
+}
+
+func TestChatCompletions_AutoContinueStopsAtCap(t *testing.T) {
+	upstream := &truncatingUpstream{parts: []string{"a", "b", "c", "d", "e"}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	svc, _ := newFailoverTestService(0, failoverConfig(1, server.URL, 0))
+
+	resp, err := svc.ChatCompletions(context.Background(), continuationRequestFor(2))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != "abc" || len(upstream.bodies) != 3 {
+		t.Errorf("Expected 2 continuations (abc from 3 calls), got %q from %d calls", got, len(upstream.bodies))
+	}
+	if resp.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected the capped response to keep finish_reason length, got %s", resp.Choices[0].FinishReason)
+	}
+}
+
+func TestChatCompletions_NoContinuationWhenDisabled(t *testing.T) {
+	upstream := &truncatingUpstream{parts: []string{"a", "b"}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	svc, _ := newFailoverTestService(0, failoverConfig(1, server.URL, 0))
+
+	resp, err := svc.ChatCompletions(context.Background(), continuationRequestFor(0))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(upstream.bodies) != 1 || resp.Choices[0].FinishReason != "length" {
+		t.Errorf("Expected a single call returning the truncated response, got %d calls", len(upstream.bodies))
+	}
+}
+
+func TestContinuationRequest_AnthropicPrefill(t *testing.T) {
+	req := continuationRequestFor(1)
+	req.Provider = "anthropic"
+
+	next := continuationRequest(req, req.ChatRequest, "partial answer \n")
+	last := next.ChatRequest.Messages[len(next.ChatRequest.Messages)-1]
+	if last.Role != "assistant" || last.Content != "partial answer" {
+		t.Errorf("Expected a trimmed assistant prefill as the last message, got %+v", last)
+	}
+	if len(req.ChatRequest.Messages) != 2 {
+		t.Errorf("Expected the original request left unchanged, got %d messages", len(req.ChatRequest.Messages))
+	}
+}
+
+func TestChatCompletions_AutoContinueSkipsResponseCache(t *testing.T) {
+	upstream := &truncatingUpstream{parts: []string{"Once upon ", "a time ", "the end."}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	svc, c := newCacheUsageTestService(server.URL)
+	c.stored = make(chan *cache.RequestCache, 4)
+
+	if _, err := svc.ChatCompletions(context.Background(), continuationRequestFor(5)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	// 只有原始请求写入缓存，续写请求不读写缓存
+	if n := len(c.stored); n != 1 {
+		t.Fatalf("Expected only the original request cached, got %d entries", n)
+	}
+	if len(upstream.bodies) != 3 {
+		t.Errorf("Expected every continuation to reach the upstream, got %d calls", len(upstream.bodies))
+	}
+}
+
+func TestContinuationRequest_CarriesKeySettings(t *testing.T) {
+	req := continuationRequestFor(1)
+	req.NoSemanticCache, req.MaxContextTokens, req.ContextTruncation, req.RequestID = true, 4000, TruncationSummarize, "req-1"
+
+	next := continuationRequest(req, req.ChatRequest, "partial")
+	if !next.NoCache || !next.NoSemanticCache || next.MaxContextTokens != 4000 || next.ContextTruncation != TruncationSummarize || next.RequestID != "req-1" {
+		t.Errorf("Expected the continuation to skip the cache and keep the key settings, got %+v", next)
+	}
+}
+
+func TestHandler_RejectsAutoContinueForStreams(t *testing.T) {
+	gateway := deprecationGateway(t)
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4","stream":true,"auto_continue":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for auto_continue on a stream, got %d", resp.StatusCode)
+	}
+}
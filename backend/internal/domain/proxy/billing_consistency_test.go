@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"testing"
+)
+
+// 非流式扣费、流式结算（有无预扣）、费用估算和管理端定价预览必须得到相同的扣费额
+func TestBilling_SameRequestChargesIdenticallyOnEveryPath(t *testing.T) {
+	rc := runtime.NewManager(nil)
+	rc.Get().FlexTierPriceMultiplier = 0.5
+	rc.Get().PriorityTierPriceMultiplier = 1.75
+
+	newService := func(q *fakeQuota) *service {
+		return &service{
+			apiConfigRepo:  &configByID{configs: map[uint]*apiconfig.APIConfig{1: {ID: 1, Name: "main", Models: apiconfig.StringArray{"gpt-4"}}}},
+			pricingService: &ratePricing{rates: map[string][2]float64{"1/gpt-4": {0.3, 1.1}}},
+			quotaService:   q,
+			logService:     &fakeLog{},
+			runtimeConfig:  rc,
+			logger:         *logger.NewNop(),
+		}
+	}
+	usage := adapter.UsageInfo{PromptTokens: 1234, CompletionTokens: 567, TotalTokens: 1801}
+
+	for _, tier := range []string{"", adapter.ServiceTierFlex, "priority"} {
+		ctx := context.Background()
+
+		direct := &fakeQuota{}
+		charged, err := newService(direct).calculateAndDeductCost(ctx, 1, 1, "gpt-4", tier, usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		streamed := &fakeQuota{}
+		streamCost, err := newService(streamed).settleStreamCost(ctx, prechargeRequest(0, tier), 1, usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reserved := &fakeQuota{}
+		svc := newService(reserved)
+		req := prechargeRequest(4000, tier)
+		if err := svc.reserveStreamQuota(ctx, req, 1); err != nil || req.Reserved == 0 {
+			t.Fatalf("Expected a reservation for tier %q, got %d (%v)", tier, req.Reserved, err)
+		}
+		reservedCost, err := svc.settleStreamCost(ctx, req, 1, usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		estimated, err := newService(&fakeQuota{}).estimateCost(ctx, 1, "gpt-4", tier, usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		preview, err := newService(&fakeQuota{}).PreviewPricing(ctx, 1, &PricingPreviewRequest{
+			Model: "gpt-4", InputTokens: int64(usage.PromptTokens), OutputTokens: int64(usage.CompletionTokens), ServiceTier: tier,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := int64(charged)
+		got := map[string]int64{
+			"non-streaming deduction": direct.deducted,
+			"stream settlement":       int64(streamCost),
+			"stream net deduction":    streamed.deducted,
+			"reserved stream cost":    int64(reservedCost),
+			"reserved net deduction":  reserved.deducted - reserved.released,
+			"estimate":                estimated,
+			"pricing preview":         preview.Credits,
+		}
+		for path, amount := range got {
+			if amount != want {
+				t.Errorf("tier %q: %s charged %d, non-streaming charged %d", tier, path, amount, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 行内连续空白（不含行首缩进）
+	inlineSpaceRun = regexp.MustCompile(`(\S)[ \t]+`)
+	// 三个及以上连续换行
+	blankLineRun = regexp.MustCompile(`\n{3,}`)
+)
+
+// normalizeText 规范化文本中不影响语义的空白
+// 保留行首缩进（代码块中有意义），只合并行内空白、去除行尾空白和多余空行
+func normalizeText(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		lines[i] = inlineSpaceRun.ReplaceAllString(line, "$1 ")
+	}
+	text = strings.Join(lines, "\n")
+	text = blankLineRun.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// normalizeContent 规范化消息内容（字符串或多部分内容）
+func normalizeContent(content interface{}) interface{} {
+	switch v := content.(type) {
+	case string:
+		return normalizeText(v)
+	case []interface{}:
+		parts := make([]interface{}, len(v))
+		for i, part := range v {
+			partMap, ok := part.(map[string]interface{})
+			if !ok {
+				parts[i] = part
+				continue
+			}
+			normalized := make(map[string]interface{}, len(partMap))
+			for k, val := range partMap {
+				normalized[k] = val
+			}
+			if text, ok := partMap["text"].(string); ok {
+				normalized["text"] = normalizeText(text)
+			}
+			parts[i] = normalized
+		}
+		return parts
+	default:
+		return content
+	}
+}
+
+// normalizeMessages 规范化消息列表
+// 开头连续的 system 消息合并为一条，其余消息顺序保持不变
+func normalizeMessages(messages []adapter.Message) []adapter.Message {
+	result := make([]adapter.Message, 0, len(messages))
+	var systemParts []string
+	i := 0
+	for ; i < len(messages); i++ {
+		msg := messages[i]
+		if strings.ToLower(msg.Role) != "system" || len(msg.ToolCalls) > 0 {
+			break
+		}
+		if _, ok := msg.Content.(string); !ok && msg.Content != nil {
+			break
+		}
+		if text := normalizeText(adapter.GetContentAsString(msg.Content)); text != "" {
+			systemParts = append(systemParts, text)
+		}
+	}
+	if len(systemParts) > 0 {
+		result = append(result, adapter.Message{Role: "system", Content: strings.Join(systemParts, "\n\n")})
+	}
+
+	for _, msg := range messages[i:] {
+		msg.Role = strings.ToLower(msg.Role)
+		msg.Content = normalizeContent(msg.Content)
+		result = append(result, msg)
+	}
+	return result
+}
+
+// unsetParam 未设置的采样参数参与哈希的值，与任何显式数值都不同
+const unsetParam = "default"
+
+// paramValue 采样参数参与哈希的值，未设置时为 unsetParam，不与显式传 0 混淆
+func paramValue(value *float64) interface{} {
+	if value == nil {
+		return unsetParam
+	}
+	return *value
+}
+
+// defaultedParam 显式值等于上游默认值时视为未设置
+func defaultedParam(value *float64, defaultValue float64) interface{} {
+	if value != nil && *value == defaultValue {
+		return unsetParam
+	}
+	return paramValue(value)
+}
+
+// cacheKeyPayload 构建参与缓存键哈希的请求内容
+// prompt_cache_key、tools 和 tool_choice 参与哈希，使网关缓存与供应商侧缓存按相同的键划分；未设置时不影响已有缓存键
+func cacheKeyPayload(req *adapter.ChatRequest, normalize bool) map[string]interface{} {
+	var payload map[string]interface{}
+	if !normalize {
+		payload = map[string]interface{}{
+			"model":       req.Model,
+			"messages":    req.Messages,
+			"temperature": paramValue(req.Temperature),
+			"top_p":       paramValue(req.TopP),
+			"max_tokens":  req.MaxTokens,
+		}
+	} else {
+		payload = map[string]interface{}{
+			"model":       strings.TrimSpace(req.Model),
+			"messages":    normalizeMessages(req.Messages),
+			"temperature": defaultedParam(req.Temperature, 1),
+			"top_p":       defaultedParam(req.TopP, 1),
+			"max_tokens":  req.MaxTokens,
+		}
+	}
+
+	if req.PromptCacheKey != "" {
+		payload["prompt_cache_key"] = req.PromptCacheKey
+	}
+	// 工具定义和 tool_choice 决定上游能否返回工具调用，工具集不同的请求不能共用缓存；未设置时不影响已有缓存键
+	if len(req.Tools) > 0 {
+		payload["tools"] = req.Tools
+	}
+	if req.ToolChoice != nil {
+		payload["tool_choice"] = req.ToolChoice
+	}
+	return payload
+}
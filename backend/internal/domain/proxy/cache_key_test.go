@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"testing"
+)
+
+func newCacheKeyTestService(normalize bool) *service {
+	svc := newBillingTestService(&fakeQuota{}, &fakeLog{})
+	svc.runtimeConfig.Get().CacheKeyNormalize = normalize
+	return svc
+}
+
+func TestGenerateCacheKey_NormalizesTrivialDifferences(t *testing.T) {
+	svc := newCacheKeyTestService(true)
+
+	base := &adapter.ChatRequest{
+		Model: "gpt-4",
+		Messages: []adapter.Message{
+			{Role: "system", Content: "You are helpful."},
+			{Role: "user", Content: "What is   the capital of France?"},
+		},
+	}
+	variant := &adapter.ChatRequest{
+		Model:       "gpt-4",
+		Temperature: float64Ptr(1),
+		TopP:        float64Ptr(1),
+		Messages: []adapter.Message{
+			{Role: "system", Content: "  You are helpful.\r\n"},
+			{Role: "User", Content: "What is the capital of France?  \n\n\n"},
+		},
+	}
+
+	if svc.generateCacheKey(base) != svc.generateCacheKey(variant) {
+		t.Error("Expected requests differing only in whitespace/defaulted params to share a cache key")
+	}
+}
+
+func TestGenerateCacheKey_MergesLeadingSystemMessages(t *testing.T) {
+	svc := newCacheKeyTestService(true)
+
+	split := &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{
+		{Role: "system", Content: "Rule one."},
+		{Role: "system", Content: "Rule two."},
+		{Role: "user", Content: "Hi"},
+	}}
+	merged := &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{
+		{Role: "system", Content: "Rule one.\n\nRule two."},
+		{Role: "user", Content: "Hi"},
+	}}
+
+	if svc.generateCacheKey(split) != svc.generateCacheKey(merged) {
+		t.Error("Expected consecutive leading system messages to normalize to one")
+	}
+}
+
+func TestGenerateCacheKey_KeepsMeaningfulDifferences(t *testing.T) {
+	svc := newCacheKeyTestService(true)
+	key := func(req *adapter.ChatRequest) string { return svc.generateCacheKey(req) }
+	msgs := func(contents ...string) []adapter.Message {
+		out := make([]adapter.Message, len(contents))
+		for i, c := range contents {
+			out[i] = adapter.Message{Role: "user", Content: c}
+		}
+		return out
+	}
+
+	base := key(&adapter.ChatRequest{Model: "gpt-4", Messages: msgs("a", "b")})
+	cases := map[string]*adapter.ChatRequest{
+		"temperature": {Model: "gpt-4", Temperature: float64Ptr(0.2), Messages: msgs("a", "b")},
+		"model":       {Model: "gpt-4o", Messages: msgs("a", "b")},
+		"order":       {Model: "gpt-4", Messages: msgs("b", "a")},
+		"content":     {Model: "gpt-4", Messages: msgs("a", "c")},
+	}
+
+	// 行首缩进在代码中有意义，不做规范化
+	if key(&adapter.ChatRequest{Model: "gpt-4", Messages: msgs("x\n  y")}) == key(&adapter.ChatRequest{Model: "gpt-4", Messages: msgs("x\ny")}) {
+		t.Error("Expected leading indentation to be preserved")
+	}
+
+	for name, req := range cases {
+		if key(req) == base {
+			t.Errorf("Expected %s difference to change the cache key", name)
+		}
+	}
+}
+
+func TestGenerateCacheKey_NormalizationDisabled(t *testing.T) {
+	svc := newCacheKeyTestService(false)
+
+	a := &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{{Role: "user", Content: "hi"}}}
+	b := &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{{Role: "user", Content: "hi "}}}
+
+	if svc.generateCacheKey(a) == svc.generateCacheKey(b) {
+		t.Error("Expected raw hashing when normalization is disabled")
+	}
+}
+
+func TestGenerateCacheKey_ExplicitZeroSamplingParams(t *testing.T) {
+	for _, normalize := range []bool{true, false} {
+		svc := newCacheKeyTestService(normalize)
+		key := func(temperature, topP *float64) string {
+			return svc.generateCacheKey(&adapter.ChatRequest{Model: "gpt-4", Temperature: temperature, TopP: topP, Messages: []adapter.Message{{Role: "user", Content: "Hi"}}})
+		}
+
+		// 确定性请求（temperature 0）不能命中采样请求的缓存
+		if key(float64Ptr(0), nil) == key(float64Ptr(1), nil) {
+			t.Errorf("normalize=%v: expected temperature 0 and 1 to produce different cache keys", normalize)
+		}
+		if key(float64Ptr(0), nil) == key(nil, nil) {
+			t.Errorf("normalize=%v: expected temperature 0 and an omitted temperature to produce different cache keys", normalize)
+		}
+		if key(nil, float64Ptr(0)) == key(nil, float64Ptr(1)) {
+			t.Errorf("normalize=%v: expected top_p 0 and 1 to produce different cache keys", normalize)
+		}
+		if key(nil, float64Ptr(0)) == key(nil, nil) {
+			t.Errorf("normalize=%v: expected top_p 0 and an omitted top_p to produce different cache keys", normalize)
+		}
+	}
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestGenerateCacheKey_PromptCacheKey(t *testing.T) {
+	for _, normalize := range []bool{true, false} {
+		svc := newCacheKeyTestService(normalize)
+		req := func(key string) *adapter.ChatRequest {
+			return &adapter.ChatRequest{Model: "gpt-4", PromptCacheKey: key, Messages: []adapter.Message{{Role: "user", Content: "Hi"}}}
+		}
+
+		if svc.generateCacheKey(req("tenant-a")) == svc.generateCacheKey(req("tenant-b")) {
+			t.Errorf("normalize=%v: expected different prompt_cache_key values to produce different cache keys", normalize)
+		}
+		if svc.generateCacheKey(req("tenant-a")) != svc.generateCacheKey(req("tenant-a")) {
+			t.Errorf("normalize=%v: expected the same prompt_cache_key to share a cache key", normalize)
+		}
+		if svc.generateCacheKey(req("")) == svc.generateCacheKey(req("tenant-a")) {
+			t.Errorf("normalize=%v: expected a request without prompt_cache_key to use a separate cache key", normalize)
+		}
+	}
+}
+
+func TestGenerateCacheKey_Tools(t *testing.T) {
+	weather := adapter.Tool{Type: "function", Function: adapter.ToolFunction{Name: "get_weather", Parameters: map[string]interface{}{"type": "object"}}}
+	search := adapter.Tool{Type: "function", Function: adapter.ToolFunction{Name: "search", Parameters: map[string]interface{}{"type": "object"}}}
+	for _, normalize := range []bool{true, false} {
+		svc := newCacheKeyTestService(normalize)
+		req := func(toolChoice interface{}, tools ...adapter.Tool) *adapter.ChatRequest {
+			return &adapter.ChatRequest{Model: "gpt-4", Tools: tools, ToolChoice: toolChoice, Messages: []adapter.Message{{Role: "user", Content: "Weather in Paris?"}}}
+		}
+
+		if svc.generateCacheKey(req(nil)) == svc.generateCacheKey(req(nil, weather)) {
+			t.Errorf("normalize=%v: expected a request with tools to use a separate cache key", normalize)
+		}
+		if svc.generateCacheKey(req(nil, weather)) == svc.generateCacheKey(req(nil, search)) {
+			t.Errorf("normalize=%v: expected different tool sets to produce different cache keys", normalize)
+		}
+		if svc.generateCacheKey(req(nil, weather)) != svc.generateCacheKey(req(nil, weather)) {
+			t.Errorf("normalize=%v: expected the same tool set to share a cache key", normalize)
+		}
+		if svc.generateCacheKey(req("auto", weather)) == svc.generateCacheKey(req("none", weather)) {
+			t.Errorf("normalize=%v: expected different tool_choice values to produce different cache keys", normalize)
+		}
+	}
+}
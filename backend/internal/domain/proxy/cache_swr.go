@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+)
+
+// revalidateCache 在后台重新请求上游并刷新过期缓存
+// 调用方已在 revalidating 中占用该缓存键，完成后释放
+func (s *service) revalidateCache(req *ProxyRequest, cacheKey string) {
+	defer s.revalidating.Delete(cacheKey)
+
+	chatReq := *req.ChatRequest
+	refreshReq := &ProxyRequest{
+		UserID:      req.UserID,
+		APIKeyID:    req.APIKeyID,
+		Model:       req.Model,
+		ChatRequest: &chatReq,
+		NoLog:       req.NoLog,
+		Revalidate:  true,
+
+		ProviderPreference: req.ProviderPreference,
+	}
+
+	if _, err := s.ChatCompletions(context.Background(), refreshReq); err != nil {
+		s.logger.Warn("Background cache refresh failed",
+			logger.String("cache_key", cacheKey),
+			logger.Error(err))
+	}
+}
+
+// WarmCache 按缓存记录保存的请求重新请求上游，在到期前刷新热门缓存
+// billingUserID 为 0 时不计费（配额检查仍按缓存所属用户），否则计入该系统账户；该键正在后台刷新时直接跳过
+func (s *service) WarmCache(ctx context.Context, entry *cache.RequestCache, billingUserID uint) error {
+	if _, loaded := s.revalidating.LoadOrStore(entry.CacheKey, struct{}{}); loaded {
+		return nil
+	}
+	defer s.revalidating.Delete(entry.CacheKey)
+
+	var chatReq adapter.ChatRequest
+	if err := json.Unmarshal([]byte(entry.Request), &chatReq); err != nil {
+		return errors.Wrap(err, 500002, "Failed to decode cached request")
+	}
+	userID := entry.UserID
+	if billingUserID > 0 {
+		userID = billingUserID
+	}
+	_, err := s.ChatCompletions(ctx, &ProxyRequest{
+		UserID:      userID,
+		Model:       entry.Model,
+		ChatRequest: &chatReq,
+		Revalidate:  true,
+		Warming:     true,
+		CacheKey:    entry.CacheKey,
+	})
+	return err
+}
+
+// refreshBilled 后台刷新是否计费：预热按 cache_warming.billing_user_id，过期刷新按 runtime.cache_refresh_billed
+func (s *service) refreshBilled(req *ProxyRequest) bool {
+	if req.Warming {
+		return s.runtimeConfig.Get().GetCacheWarmingPolicy().BillingUserID > 0
+	}
+	return s.runtimeConfig.Get().IsCacheRefreshBilled()
+}
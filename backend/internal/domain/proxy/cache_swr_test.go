@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/cache"
+	"api-aggregator/backend/internal/domain/quota"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// swrCache 内存缓存，只保存一条记录
+type swrCache struct {
+	cache.Service
+	mu     sync.Mutex
+	item   *cache.RequestCache
+	stored chan *cache.RequestCache
+}
+
+func (c *swrCache) FindByCacheKeyAllowStale(ctx context.Context, cacheKey string) (*cache.RequestCache, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.item, nil
+}
+
+func (c *swrCache) CreateCacheWithEmbedding(ctx context.Context, item *cache.RequestCache, embedding []float64) error {
+	c.mu.Lock()
+	c.item = item
+	c.mu.Unlock()
+	c.stored <- item
+	return nil
+}
+
+// openQuota 配额充足，streamPrecharge 为用户的流式预扣设置
+type openQuota struct {
+	fakeQuota
+	streamPrecharge bool
+}
+
+func (q *openQuota) GetQuotaInfo(ctx context.Context, userID uint) (*quota.QuotaInfoResponse, error) {
+	return &quota.QuotaInfoResponse{TotalQuota: 1000000, StreamPrecharge: q.streamPrecharge}, nil
+}
+
+func chatResponseJSON(content string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":      "chatcmpl-1",
+		"model":   "gpt-4",
+		"choices": []map[string]interface{}{{"index": 0, "message": map[string]string{"role": "assistant", "content": content}, "finish_reason": "stop"}},
+		"usage":   map[string]int{"prompt_tokens": 5, "completion_tokens": 5, "total_tokens": 10},
+	})
+	return data
+}
+
+func newSWRTestService(t *testing.T, upstreamURL string, billed bool) (*service, *swrCache, *openQuota) {
+	t.Helper()
+	staleResp, _ := json.Marshal(&adapter.ChatResponse{
+		Choices: []adapter.ChatChoice{{Message: adapter.Message{Role: "assistant", Content: "stale answer"}}},
+	})
+	expiredAt := time.Now().Add(-time.Minute)
+	staleUntil := time.Now().Add(time.Hour)
+	c := &swrCache{
+		item:   &cache.RequestCache{CacheKey: "k", Response: string(staleResp), ExpiresAt: expiredAt, StaleUntil: &staleUntil},
+		stored: make(chan *cache.RequestCache, 4),
+	}
+	q := &openQuota{}
+
+	rc := runtime.NewManager(nil)
+	cfg := rc.Get()
+	cfg.CacheEnabled = true
+	cfg.CacheTTL = time.Hour
+	cfg.CacheStaleWhileRevalidate = 10 * time.Minute
+	cfg.CacheRefreshBilled = billed
+
+	svc := &service{
+		adapterFactory: adapter.NewFactory(),
+		apiConfigRepo: &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{
+			"gpt-4": {{ID: 1, Type: "openai", ConfigType: apiconfig.ConfigTypeDirect, BaseURL: upstreamURL, LogRequests: true}},
+		}},
+		cacheService:   c,
+		quotaService:   q,
+		pricingService: &fakePricing{cost: 42},
+		logService:     &fakeLog{},
+		runtimeConfig:  rc,
+		logger:         *logger.NewNop(),
+	}
+	return svc, c, q
+}
+
+func swrRequest() *ProxyRequest {
+	return &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		ChatRequest: &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+}
+
+func TestCheckCache_ServesStaleAndRefreshesOnce(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("fresh answer"))
+	}))
+	defer server.Close()
+
+	svc, c, q := newSWRTestService(t, server.URL, true)
+
+	// 过期窗口内的并发请求都立即拿到旧响应，只触发一次后台刷新
+	for i := 0; i < 5; i++ {
+		resp, err := svc.checkCache(context.Background(), swrRequest(), "k")
+		if err != nil || resp == nil {
+			t.Fatalf("Expected stale response, got %v (%v)", resp, err)
+		}
+		if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != "stale answer" {
+			t.Fatalf("Expected stale answer, got %q", got)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&hits) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+
+	select {
+	case item := <-c.stored:
+		var refreshed adapter.ChatResponse
+		json.Unmarshal([]byte(item.Response), &refreshed)
+		if got := adapter.GetContentAsString(refreshed.Choices[0].Message.Content); got != "fresh answer" {
+			t.Errorf("Expected cache refreshed with fresh answer, got %q", got)
+		}
+		if item.StaleUntil == nil || !item.StaleUntil.After(item.ExpiresAt) {
+			t.Errorf("Expected refreshed entry to carry a stale window, got %+v", item.StaleUntil)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Background refresh did not update the cache")
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Errorf("Expected a single upstream refresh, got %d", n)
+	}
+	if q.deducted != 42 {
+		t.Errorf("Expected refresh billed 42, got %d", q.deducted)
+	}
+}
+
+func TestCheckCache_FreeRefreshAndFreshEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("fresh answer"))
+	}))
+	defer server.Close()
+
+	svc, c, q := newSWRTestService(t, server.URL, false)
+
+	if _, err := svc.checkCache(context.Background(), swrRequest(), "k"); err != nil {
+		t.Fatalf("checkCache failed: %v", err)
+	}
+	select {
+	case <-c.stored:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Background refresh did not update the cache")
+	}
+	if q.deducted != 0 {
+		t.Errorf("Expected free refresh, got %d deducted", q.deducted)
+	}
+
+	// 刷新后的记录未过期，不再触发后台刷新
+	if _, err := svc.checkCache(context.Background(), swrRequest(), "k"); err != nil {
+		t.Fatalf("checkCache failed: %v", err)
+	}
+	select {
+	case <-c.stored:
+		t.Error("Fresh entries must not be refreshed")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWarmCache_RefreshesEntryUnderItsKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("fresh answer"))
+	}))
+	defer server.Close()
+
+	reqJSON, _ := json.Marshal(swrRequest().ChatRequest)
+	for _, tc := range []struct {
+		name          string
+		billingUserID uint
+		wantDeducted  int64
+		wantLogUser   uint
+	}{
+		{name: "free", billingUserID: 0, wantDeducted: 0, wantLogUser: 1},
+		{name: "system account", billingUserID: 99, wantDeducted: 42, wantLogUser: 99},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// 过期刷新计费开启，不影响预热的计费方式
+			svc, c, q := newSWRTestService(t, server.URL, true)
+			svc.runtimeConfig.Get().CacheWarming.BillingUserID = tc.billingUserID
+			entry := &cache.RequestCache{UserID: 1, CacheKey: "popular", Model: "gpt-4", Request: string(reqJSON)}
+
+			if err := svc.WarmCache(context.Background(), entry, tc.billingUserID); err != nil {
+				t.Fatalf("WarmCache failed: %v", err)
+			}
+			select {
+			case item := <-c.stored:
+				if item.CacheKey != "popular" || !item.ExpiresAt.After(time.Now()) {
+					t.Errorf("Expected the entry refreshed under its own key, got %s expiring %v", item.CacheKey, item.ExpiresAt)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Warming did not update the cache")
+			}
+			if q.deducted != tc.wantDeducted {
+				t.Errorf("Expected %d deducted, got %d", tc.wantDeducted, q.deducted)
+			}
+			l := svc.logService.(*fakeLog)
+			logs := append(l.created, l.usage...)
+			if len(logs) != 1 || logs[0].UserID != tc.wantLogUser {
+				t.Errorf("Expected the warming call logged for user %d, got %+v", tc.wantLogUser, logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"encoding/json"
+)
+
+// decodeCachedResponse 解析缓存的响应，并带回原始响应的 token 用量
+// 缓存记录单独保存了用量，响应 JSON 中的用量为空时以单独保存的为准
+func decodeCachedResponse(item *cache.RequestCache) (*adapter.ChatResponse, error) {
+	var resp adapter.ChatResponse
+	if err := json.Unmarshal([]byte(item.Response), &resp); err != nil {
+		return nil, err
+	}
+	if resp.Usage.TotalTokens == 0 && item.TotalTokens > 0 {
+		resp.Usage = adapter.UsageInfo{
+			PromptTokens:     item.PromptTokens,
+			CompletionTokens: item.CompletionTokens,
+			TotalTokens:      item.TotalTokens,
+		}
+	}
+	return &resp, nil
+}
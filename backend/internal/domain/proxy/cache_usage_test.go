@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// usageCache 内存缓存，按缓存键保存写入的记录
+type usageCache struct {
+	cache.Service
+	items  map[string]*cache.RequestCache
+	stored chan *cache.RequestCache
+}
+
+func (c *usageCache) FindByCacheKey(ctx context.Context, cacheKey string) (*cache.RequestCache, error) {
+	return c.items[cacheKey], nil
+}
+
+func (c *usageCache) CreateCacheWithEmbedding(ctx context.Context, item *cache.RequestCache, embedding []float64) error {
+	c.stored <- item
+	return nil
+}
+
+func newCacheUsageTestService(upstreamURL string) (*service, *usageCache) {
+	c := &usageCache{items: make(map[string]*cache.RequestCache), stored: make(chan *cache.RequestCache, 1)}
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstreamURL, 0))
+	svc.runtimeConfig.Get().CacheEnabled = true
+	svc.runtimeConfig.Get().CacheTTL = time.Hour
+	svc.cacheService = c
+	return svc, c
+}
+
+func TestChatCompletions_CacheHitKeepsOriginalUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("cached answer"))
+	}))
+	defer server.Close()
+	svc, c := newCacheUsageTestService(server.URL)
+
+	if _, err := svc.ChatCompletions(context.Background(), swrRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var item *cache.RequestCache
+	select {
+	case item = <-c.stored:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Response was not cached")
+	}
+	if item.PromptTokens != 5 || item.CompletionTokens != 5 || item.TotalTokens != 10 {
+		t.Fatalf("Expected usage stored alongside the cached response, got %d/%d/%d", item.PromptTokens, item.CompletionTokens, item.TotalTokens)
+	}
+	c.items[item.CacheKey] = item
+
+	resp, err := svc.ChatCompletions(context.Background(), swrRequest())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.Cached {
+		t.Fatal("Expected the second request served from cache")
+	}
+
+	// 三种响应格式都带回原始用量
+	formatted := map[protocol.Protocol]string{}
+	factory := protocol.NewConverterFactory()
+	for _, p := range []protocol.Protocol{protocol.ProtocolOpenAI, protocol.ProtocolAnthropic, protocol.ProtocolGemini} {
+		out, err := factory.GetConverter(p).FormatResponse(resp)
+		if err != nil {
+			t.Fatalf("%s: format failed: %v", p, err)
+		}
+		data, _ := json.Marshal(out)
+		formatted[p] = string(data)
+	}
+	var openai struct {
+		Usage adapter.UsageInfo `json:"usage"`
+	}
+	json.Unmarshal([]byte(formatted[protocol.ProtocolOpenAI]), &openai)
+	if openai.Usage.PromptTokens != 5 || openai.Usage.CompletionTokens != 5 || openai.Usage.TotalTokens != 10 {
+		t.Errorf("Expected OpenAI usage 5/5/10, got %s", formatted[protocol.ProtocolOpenAI])
+	}
+	var anthropic protocol.AnthropicResponse
+	json.Unmarshal([]byte(formatted[protocol.ProtocolAnthropic]), &anthropic)
+	if anthropic.Usage.InputTokens != 5 || anthropic.Usage.OutputTokens != 5 {
+		t.Errorf("Expected Anthropic usage 5/5, got %s", formatted[protocol.ProtocolAnthropic])
+	}
+	var gemini protocol.GeminiResponse
+	json.Unmarshal([]byte(formatted[protocol.ProtocolGemini]), &gemini)
+	if gemini.UsageMetadata.PromptTokenCount != 5 || gemini.UsageMetadata.CandidatesTokenCount != 5 || gemini.UsageMetadata.TotalTokenCount != 10 {
+		t.Errorf("Expected Gemini usageMetadata 5/5/10, got %s", formatted[protocol.ProtocolGemini])
+	}
+}
+
+func TestChatCompletions_CacheNotSharedAcrossToolSets(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("answer"))
+	}))
+	defer server.Close()
+	svc, c := newCacheUsageTestService(server.URL)
+
+	withTool := func(name string) *ProxyRequest {
+		req := swrRequest()
+		req.ChatRequest.Tools = []adapter.Tool{{Type: "function", Function: adapter.ToolFunction{Name: name, Parameters: map[string]interface{}{"type": "object"}}}}
+		return req
+	}
+
+	if _, err := svc.ChatCompletions(context.Background(), withTool("get_weather")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	select {
+	case item := <-c.stored:
+		c.items[item.CacheKey] = item
+	case <-time.After(2 * time.Second):
+		t.Fatal("Response was not cached")
+	}
+
+	for _, req := range []*ProxyRequest{withTool("search"), swrRequest()} {
+		resp, err := svc.ChatCompletions(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.Cached {
+			t.Errorf("Expected a request with tools %v not served the response cached for another tool set", req.ChatRequest.Tools)
+		}
+		select {
+		case <-c.stored:
+		case <-time.After(2 * time.Second):
+		}
+	}
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Errorf("Expected every tool set to reach the upstream, got %d upstream calls", n)
+	}
+
+	resp, err := svc.ChatCompletions(context.Background(), withTool("get_weather"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !resp.Cached {
+		t.Error("Expected the same tool set served from cache")
+	}
+}
+
+func TestDecodeCachedResponse_RestoresStoredUsage(t *testing.T) {
+	// 响应 JSON 中的用量为空时，使用缓存记录单独保存的用量
+	item := &cache.RequestCache{
+		Response:         `{"id":"chatcmpl-1","model":"gpt-4","choices":[],"usage":{"prompt_tokens":0,"completion_tokens":0,"total_tokens":0}}`,
+		PromptTokens:     7,
+		CompletionTokens: 3,
+		TotalTokens:      10,
+	}
+	resp, err := decodeCachedResponse(item)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.Usage.PromptTokens != 7 || resp.Usage.CompletionTokens != 3 || resp.Usage.TotalTokens != 10 {
+		t.Errorf("Expected the stored usage restored, got %+v", resp.Usage)
+	}
+}
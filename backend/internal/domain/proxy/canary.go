@@ -0,0 +1,31 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"time"
+)
+
+// splitCanaryConfigs 按灰度比例抽样：命中时返回被选中的灰度配置，
+// 否则返回剔除灰度配置后的候选；没有稳定配置时灰度配置照常参与选择
+func (s *service) splitCanaryConfigs(configs []*apiconfig.APIConfig) ([]*apiconfig.APIConfig, *apiconfig.APIConfig) {
+	now := time.Now()
+	var canaries []*apiconfig.APIConfig
+	var shares []float64
+	stable := make([]*apiconfig.APIConfig, 0, len(configs))
+	for _, cfg := range configs {
+		if share, ok := cfg.CanaryShare(now); ok {
+			canaries = append(canaries, cfg)
+			shares = append(shares, share)
+			continue
+		}
+		stable = append(stable, cfg)
+	}
+
+	if len(canaries) == 0 || len(stable) == 0 {
+		return configs, nil
+	}
+	if i := s.selector.SelectCanary(shares); i >= 0 {
+		return nil, canaries[i]
+	}
+	return stable, nil
+}
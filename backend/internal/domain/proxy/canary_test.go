@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/loadbalancer"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// fixedLBConfig 固定负载均衡策略
+type fixedLBConfig struct {
+	loadbalancer.Service
+	strategy string
+}
+
+func (f fixedLBConfig) GetConfigByModel(ctx context.Context, model string) (*loadbalancer.ConfigResponse, error) {
+	return &loadbalancer.ConfigResponse{ModelName: model, Strategy: f.strategy, IsActive: true}, nil
+}
+
+func newCanaryTestService(configs ...*apiconfig.APIConfig) *service {
+	return &service{
+		apiConfigRepo:   &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"gpt-4": configs}},
+		loadBalancerSvc: fixedLBConfig{strategy: loadbalancer.StrategyWeightedRoundRobin},
+		selector:        loadbalancer.NewSelectorWithSeed(7),
+		runtimeConfig:   runtime.NewManager(nil),
+		logger:          *logger.NewNop(),
+	}
+}
+
+// canaryShare 统计 samples 次选择中命中 id 的比例
+func canaryShare(t *testing.T, svc *service, id uint, samples int) float64 {
+	t.Helper()
+	hits := 0
+	for i := 0; i < samples; i++ {
+		cfg, err := svc.selectAPIConfig(context.Background(), "gpt-4", nil, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.ID == id {
+			hits++
+		}
+	}
+	return float64(hits) / float64(samples)
+}
+
+func TestSelectAPIConfig_CanaryReceivesItsPercentRegardlessOfWeight(t *testing.T) {
+	stable := &apiconfig.APIConfig{ID: 1, Weight: 1}
+	canary := &apiconfig.APIConfig{ID: 2, Weight: 100, CanaryPercent: 5}
+	svc := newCanaryTestService(stable, canary)
+
+	if share := canaryShare(t, svc, 2, 20000); math.Abs(share-0.05) > 0.01 {
+		t.Errorf("Expected the 5%% canary to receive about 5%% of traffic, got %.2f%%", share*100)
+	}
+
+	// 结束灰度后按权重 1:100 承接流量
+	canary.CanaryPercent = 0
+	if share := canaryShare(t, svc, 2, 20000); math.Abs(share-100.0/101) > 0.01 {
+		t.Errorf("Expected the promoted config to receive its full weight share, got %.2f%%", share*100)
+	}
+}
+
+func TestSelectAPIConfig_CanaryRampsOverTime(t *testing.T) {
+	started := time.Now().Add(-30 * time.Minute)
+	stable := &apiconfig.APIConfig{ID: 1, Weight: 1}
+	canary := &apiconfig.APIConfig{ID: 2, Weight: 1, CanaryPercent: 10, CanaryRampMinutes: 60, CanaryStartedAt: &started}
+	svc := newCanaryTestService(stable, canary)
+
+	// 爬坡过半：10% + 90% * 0.5 = 55%
+	if share := canaryShare(t, svc, 2, 20000); math.Abs(share-0.55) > 0.02 {
+		t.Errorf("Expected about 55%% halfway through the ramp, got %.2f%%", share*100)
+	}
+}
+
+func TestSelectAPIConfig_CanaryOnlyConfigStillServes(t *testing.T) {
+	canary := &apiconfig.APIConfig{ID: 2, Weight: 1, CanaryPercent: 5}
+	svc := newCanaryTestService(canary)
+
+	if share := canaryShare(t, svc, 2, 100); share != 1 {
+		t.Errorf("Expected the only config to serve every request even as a canary, got %.2f", share)
+	}
+}
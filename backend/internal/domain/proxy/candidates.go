@@ -0,0 +1,197 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+)
+
+// candidateParams 请求体中的多候选采样扩展参数
+type candidateParams struct {
+	Candidates int `json:"candidates"`
+}
+
+// parseCandidateCount 从原始请求体解析请求的候选数，未指定或解析失败时返回 0
+func parseCandidateCount(rawBody []byte) int {
+	var params candidateParams
+	if json.Unmarshal(rawBody, &params) != nil || params.Candidates < 0 {
+		return 0
+	}
+	return params.Candidates
+}
+
+// resolveCandidates 按 API Key 的上限确定实际采样的候选数，不超过 1 个时返回 0（普通请求）
+// API Key 未开启多候选采样时返回错误，超过上限时按上限采样
+func resolveCandidates(requested, keyMax int) (int, error) {
+	if requested <= 1 {
+		return 0, nil
+	}
+	if keyMax <= 1 {
+		return 0, fmt.Errorf("multiple candidates are not enabled for this API key")
+	}
+	return min(requested, keyMax), nil
+}
+
+// CandidateResult 多候选采样中一个配置的结果，Error 不为空时 Response 为 nil
+type CandidateResult struct {
+	Index    int
+	ConfigID uint
+	Provider string
+	Model    string
+	Response *adapter.ChatResponse
+	Error    string
+}
+
+// chatCompletionCandidates 把一次请求按权重分发给多个提供该模型的配置，每个候选都是一次完整的请求：
+// 单独计费、单独记录请求日志，不读写缓存、失败时不换用其他配置
+// 返回第一个成功的候选作为主响应，全部候选写入 req.CandidateResults；所有候选都失败时返回第一个错误
+func (s *service) chatCompletionCandidates(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	configs, err := s.sampleCandidateConfigs(ctx, req.Model, req.Candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		primary  *adapter.ChatResponse
+		firstErr error
+	)
+	results := make([]*CandidateResult, 0, len(configs))
+	for i, cfg := range configs {
+		if ctx.Err() != nil {
+			break
+		}
+		sub := candidateRequest(req, cfg.ID, i == 0)
+		resp, err := s.chatCompletion(ctx, sub)
+		result := &CandidateResult{Index: i, ConfigID: cfg.ID, Provider: cfg.Type, Model: sub.Model, Response: resp}
+		if err != nil {
+			result.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
+			}
+			s.log(ctx).Warn("Candidate request failed",
+				logger.Int("candidate", i),
+				logger.Uint("config_id", cfg.ID),
+				logger.Error(err))
+		} else if primary == nil {
+			primary = resp
+			req.Provider, req.ServiceTier, req.UpstreamRequestID = sub.Provider, sub.ServiceTier, sub.UpstreamRequestID
+			req.ConfigNoLog = sub.ConfigNoLog
+			req.Routing = sub.Routing
+		}
+		results = append(results, result)
+	}
+	if primary == nil {
+		if firstErr == nil {
+			firstErr = ctx.Err()
+		}
+		return nil, firstErr
+	}
+
+	req.CandidateResults = results
+	s.log(ctx).Info("✓ Candidate responses collected",
+		logger.String("model", req.Model),
+		logger.Int("candidates", len(results)))
+	return primary, nil
+}
+
+// sampleCandidateConfigs 按权重不放回地抽取 n 个提供该模型的配置，配置数不足 n 个时抽完后重新开始一轮
+// 权重小于 1 的配置按 1 计算，保证每个配置都有机会被采样
+func (s *service) sampleCandidateConfigs(ctx context.Context, model string, n int) ([]*apiconfig.APIConfig, error) {
+	configs, err := s.apiConfigRepo.FindByModel(ctx, model)
+	if err != nil {
+		return nil, errors.Wrap(err, 500006, "Failed to find API configs")
+	}
+	if len(configs) == 0 {
+		return nil, errors.New(404002, fmt.Sprintf("No API configuration found for model: %s", model))
+	}
+
+	sampled := make([]*apiconfig.APIConfig, 0, n)
+	var pool []*apiconfig.APIConfig
+	for len(sampled) < n {
+		if len(pool) == 0 {
+			pool = append([]*apiconfig.APIConfig(nil), configs...)
+		}
+		weights := s.effectiveWeights(pool)
+		total := 0
+		for i := range weights {
+			weights[i] = max(weights[i], 1)
+			total += weights[i]
+		}
+		pick, r := 0, rand.Intn(total)
+		for ; r >= weights[pick]; pick++ {
+			r -= weights[pick]
+		}
+		sampled = append(sampled, pool[pick])
+		pool = append(pool[:pick], pool[pick+1:]...)
+	}
+	return sampled, nil
+}
+
+// candidateRequest 构建固定使用一个配置的候选请求；配额预留只由第一个候选引用
+func candidateRequest(req *ProxyRequest, configID uint, first bool) *ProxyRequest {
+	sub := *req
+	sub.ChatRequest = adapter.CloneChatRequest(req.ChatRequest)
+	sub.Sizes = nil
+	sub.Candidates = 0
+	sub.CandidateResults = nil
+	sub.MaxContinuations = 0
+	sub.PinnedConfigID = configID
+	sub.ToolsDropped, sub.ToolsMerged, sub.HistoryDropped = 0, false, 0
+	if !first {
+		sub.HoldID = ""
+	}
+	return &sub
+}
+
+// pinnedAPIConfig 返回候选请求固定使用的配置，该配置已失败或不再提供该模型时不再重试
+func (s *service) pinnedAPIConfig(ctx context.Context, model string, configID uint, exclude map[uint]bool) (*apiconfig.APIConfig, error) {
+	if exclude[configID] {
+		return nil, errNoFailoverConfig
+	}
+	configs, err := s.apiConfigRepo.FindByModel(ctx, model)
+	if err != nil {
+		return nil, errors.Wrap(err, 500006, "Failed to find API configs")
+	}
+	for _, cfg := range configs {
+		if cfg.ID == configID {
+			return cfg, nil
+		}
+	}
+	return nil, errors.New(404002, fmt.Sprintf("API config %d no longer serves model: %s", configID, model))
+}
+
+// candidateOutput 非流式响应扩展字段 prism_candidates 中的一个候选，响应按请求的协议格式化
+type candidateOutput struct {
+	Index    int         `json:"index"`
+	Provider string      `json:"provider"`
+	Model    string      `json:"model"`
+	Response interface{} `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// withCandidates 在格式化后的响应中加入 prism_candidates 扩展字段，没有候选时原样返回
+func withCandidates(formatted interface{}, converter protocol.Converter, results []*CandidateResult) interface{} {
+	if len(results) == 0 {
+		return formatted
+	}
+	outputs := make([]candidateOutput, 0, len(results))
+	for _, result := range results {
+		output := candidateOutput{Index: result.Index, Provider: result.Provider, Model: result.Model, Error: result.Error}
+		if result.Response != nil {
+			resp, err := converter.FormatResponse(result.Response)
+			if err != nil {
+				output.Error = err.Error()
+			} else {
+				output.Response = resp
+			}
+		}
+		outputs = append(outputs, output)
+	}
+	return withExtension(formatted, "prism_candidates", outputs)
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// candidateUpstream 返回带有上游名称的响应，便于区分各候选
+func candidateUpstream(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("from " + name))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatCompletions_CandidatesReturnsAndBillsEachResponse(t *testing.T) {
+	a, b, c := candidateUpstream(t, "a"), candidateUpstream(t, "b"), candidateUpstream(t, "c")
+	svc, logs := newFailoverTestService(0, failoverConfig(1, a.URL, 0), failoverConfig(2, b.URL, 0), failoverConfig(3, c.URL, 0))
+	q := &openQuota{}
+	svc.quotaService = q
+
+	req := failoverRequest()
+	req.Candidates = 3
+	resp, err := svc.ChatCompletions(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Expected candidate request to succeed, got %v", err)
+	}
+	if len(req.CandidateResults) != 3 {
+		t.Fatalf("Expected 3 candidate responses, got %d", len(req.CandidateResults))
+	}
+
+	contents := map[string]bool{}
+	for _, result := range req.CandidateResults {
+		if result.Error != "" || result.Response == nil {
+			t.Fatalf("Expected every candidate to succeed, got %+v", result)
+		}
+		contents[adapter.GetContentAsString(result.Response.Choices[0].Message.Content)] = true
+	}
+	if len(contents) != 3 {
+		t.Errorf("Expected each candidate sampled from a different config, got %v", contents)
+	}
+	if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != adapter.GetContentAsString(req.CandidateResults[0].Response.Choices[0].Message.Content) {
+		t.Errorf("Expected the first candidate as the primary response, got %q", got)
+	}
+
+	if q.deducted != 3*42 {
+		t.Errorf("Expected each candidate billed, deducted %d", q.deducted)
+	}
+	if len(logs.created) != 3 {
+		t.Errorf("Expected a request log per candidate, got %d", len(logs.created))
+	}
+}
+
+func TestChatCompletions_CandidatesRepeatConfigsWhenFewerThanRequested(t *testing.T) {
+	a := candidateUpstream(t, "a")
+	svc, _ := newFailoverTestService(0, failoverConfig(1, a.URL, 0))
+	q := &openQuota{}
+	svc.quotaService = q
+
+	req := failoverRequest()
+	req.Candidates = 2
+	if _, err := svc.ChatCompletions(context.Background(), req); err != nil {
+		t.Fatalf("Expected candidate request to succeed, got %v", err)
+	}
+	if len(req.CandidateResults) != 2 || req.CandidateResults[1].ConfigID != 1 {
+		t.Fatalf("Expected the only config sampled twice, got %+v", req.CandidateResults)
+	}
+	if q.deducted != 2*42 {
+		t.Errorf("Expected both candidates billed, deducted %d", q.deducted)
+	}
+}
+
+func TestResolveCandidates_RequiresKeyOptIn(t *testing.T) {
+	if n, err := resolveCandidates(3, 0); err == nil {
+		t.Errorf("Expected candidates rejected when the key has not opted in, got %d", n)
+	}
+	if n, err := resolveCandidates(5, 3); err != nil || n != 3 {
+		t.Errorf("Expected candidates capped at the key limit, got %d, %v", n, err)
+	}
+	if n, err := resolveCandidates(1, 0); err != nil || n != 0 {
+		t.Errorf("Expected a single candidate handled as a normal request, got %d, %v", n, err)
+	}
+}
+
+func TestWithCandidates_AddsFormattedResponses(t *testing.T) {
+	results := []*CandidateResult{
+		{Index: 0, Provider: "openai", Model: "gpt-4", Response: &adapter.ChatResponse{ID: "chatcmpl-1", Model: "gpt-4"}},
+		{Index: 1, Provider: "anthropic", Model: "gpt-4", Error: "upstream unavailable"},
+	}
+	out := withCandidates(map[string]interface{}{"id": "chatcmpl-1"}, protocol.NewOpenAIConverter(), results)
+
+	data, _ := json.Marshal(out)
+	var body struct {
+		ID         string `json:"id"`
+		Candidates []struct {
+			Index    int             `json:"index"`
+			Provider string          `json:"provider"`
+			Response json.RawMessage `json:"response"`
+			Error    string          `json:"error"`
+		} `json:"prism_candidates"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ID != "chatcmpl-1" || len(body.Candidates) != 2 {
+		t.Fatalf("Expected the response with 2 candidates, got %s", data)
+	}
+	if len(body.Candidates[0].Response) == 0 || body.Candidates[1].Error == "" {
+		t.Errorf("Expected a formatted response and an error entry, got %s", data)
+	}
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 上下文截断策略
+const (
+	TruncationDropOldest = "drop_oldest" // 丢弃最早的非 system 消息
+	TruncationSummarize  = "summarize"   // 将丢弃的消息压缩为一条摘要
+)
+
+// summaryLineChars 摘要中每条被丢弃消息保留的字符数
+const summaryLineChars = 200
+
+// defaultContextWindows 常见模型的上下文窗口，按最长前缀匹配
+var defaultContextWindows = map[string]int{
+	"gpt-3.5-turbo": 16385,
+	"gpt-4":         8192,
+	"gpt-4-32k":     32768,
+	"gpt-4-turbo":   128000,
+	"gpt-4o":        128000,
+	"gpt-4.1":       1047576,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+	"claude":        200000,
+	"gemini-1.5":    1048576,
+	"gemini-2":      1048576,
+}
+
+// contextWindowFor 返回模型的上下文窗口，配置优先，未知模型返回 0
+func contextWindowFor(cfg *apiconfig.APIConfig, model string) int {
+	if cfg.ContextWindow > 0 {
+		return cfg.ContextWindow
+	}
+	window, matched := 0, 0
+	for prefix, size := range defaultContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			window, matched = size, len(prefix)
+		}
+	}
+	return window
+}
+
+// contextLimitFor 返回生效的上下文上限和截断策略：API Key 与配置（或模型默认）的上限取较小值，
+// API Key 设置的策略优先；只有 API Key 设置了上限而两者都没有策略时按 drop_oldest 截断，策略为空表示不截断
+func contextLimitFor(cfg *apiconfig.APIConfig, req *ProxyRequest) (int, string) {
+	window, strategy := contextWindowFor(cfg, req.ChatRequest.Model), cfg.ContextTruncation
+	if req.ContextTruncation != "" {
+		strategy = req.ContextTruncation
+	}
+	if req.MaxContextTokens > 0 && (window <= 0 || req.MaxContextTokens < window) {
+		window = req.MaxContextTokens
+		if strategy == "" {
+			strategy = TruncationDropOldest
+		}
+	}
+	return window, strategy
+}
+
+// truncateContext 按 API Key 或配置的策略截断超出上下文上限的对话
+// 保留所有 system 消息和最后一条 user 消息及其之后的消息，从最早的消息开始丢弃
+func (s *service) truncateContext(ctx context.Context, cfg *apiconfig.APIConfig, proxyReq *ProxyRequest) error {
+	window, strategy := contextLimitFor(cfg, proxyReq)
+	if strategy == "" || window <= 0 {
+		return nil
+	}
+	req := proxyReq.ChatRequest
+	reserved := req.MaxTokens
+	if reserved <= 0 {
+		reserved = defaultEstimateOutputTokens
+	}
+	budget := window - reserved
+
+	tokens := make([]int, len(req.Messages))
+	total := 0
+	for i, msg := range req.Messages {
+		tokens[i] = estimateMessageTokens(msg)
+		total += tokens[i]
+	}
+	if total <= budget {
+		return nil
+	}
+
+	// 必须保留的部分：system 消息和最后一条 user 消息起的所有消息
+	tail := len(req.Messages)
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			tail = i
+			break
+		}
+	}
+	used := 0
+	for i, msg := range req.Messages {
+		if i >= tail || msg.Role == "system" {
+			used += tokens[i]
+		}
+	}
+	if used > budget {
+		return errors.New(400001, fmt.Sprintf("Conversation exceeds the context window of %d tokens even after truncation", window))
+	}
+
+	// 从最近的消息向前保留，直到预算用完；摘要策略为摘要预留四分之一的剩余预算
+	keepBudget := budget
+	if strategy == TruncationSummarize {
+		keepBudget = used + (budget-used)*3/4
+	}
+	cut := tail
+	for cut > 0 {
+		msg := req.Messages[cut-1]
+		if msg.Role != "system" {
+			if used+tokens[cut-1] > keepBudget {
+				break
+			}
+			used += tokens[cut-1]
+		}
+		cut--
+	}
+	// 工具结果不能脱离对应的 tool_calls 单独保留
+	for cut < tail && req.Messages[cut].Role == "tool" {
+		used -= tokens[cut]
+		cut++
+	}
+
+	kept := make([]adapter.Message, 0, len(req.Messages))
+	var dropped []adapter.Message
+	for i, msg := range req.Messages {
+		if i >= cut || msg.Role == "system" {
+			kept = append(kept, msg)
+		} else {
+			dropped = append(dropped, msg)
+		}
+	}
+
+	if strategy == TruncationSummarize {
+		kept = insertSummary(kept, dropped, budget-used)
+	}
+
+	s.log(ctx).Info("✓ Conversation truncated to fit context window",
+		logger.String("model", req.Model),
+		logger.String("strategy", strategy),
+		logger.Int("context_window", window),
+		logger.Int("dropped_messages", len(dropped)))
+
+	req.Messages = kept
+	return nil
+}
+
+// insertSummary 将被丢弃的消息压缩为一条 system 摘要，放在开头的 system 消息之后
+// 摘要超出剩余预算时优先舍弃最早的内容
+func insertSummary(kept, dropped []adapter.Message, remaining int) []adapter.Message {
+	lines := summaryLines(dropped)
+	for len(lines) > 0 {
+		summary := adapter.Message{
+			Role:    "system",
+			Content: "Summary of earlier conversation (truncated to fit the context window):\n" + strings.Join(lines, "\n"),
+		}
+		if estimateMessageTokens(summary) <= remaining {
+			pos := 0
+			for pos < len(kept) && kept[pos].Role == "system" {
+				pos++
+			}
+			result := make([]adapter.Message, 0, len(kept)+1)
+			result = append(result, kept[:pos]...)
+			result = append(result, summary)
+			return append(result, kept[pos:]...)
+		}
+		lines = lines[1:]
+	}
+	return kept
+}
+
+// summaryLines 将消息压缩为每条一行的摘要，空消息跳过，过长的内容截断
+func summaryLines(messages []adapter.Message) []string {
+	lines := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		text := strings.Join(strings.Fields(adapter.GetContentAsString(msg.Content)), " ")
+		if text == "" {
+			continue
+		}
+		if runes := []rune(text); len(runes) > summaryLineChars {
+			text = string(runes[:summaryLineChars]) + "..."
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", msg.Role, text))
+	}
+	return lines
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"strings"
+	"testing"
+)
+
+// longConversation 每条消息约 104 token
+func longConversation() []adapter.Message {
+	text := strings.Repeat("a", 400)
+	return []adapter.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "first " + text},
+		{Role: "assistant", Content: text},
+		{Role: "user", Content: text},
+		{Role: "assistant", ToolCalls: []adapter.ToolCall{{ID: "c1", Type: "function", Function: adapter.FunctionCall{Name: "f", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "c1", Content: text},
+		{Role: "assistant", Content: text},
+		{Role: "user", Content: "latest question"},
+	}
+}
+
+func totalTokens(messages []adapter.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
+
+func TestTruncateContext_DropOldest(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{ContextTruncation: TruncationDropOldest, ContextWindow: 400}
+	req := &adapter.ChatRequest{Model: "gpt-4", MaxTokens: 100, Messages: longConversation()}
+
+	if err := svc.truncateContext(context.Background(), cfg, &ProxyRequest{ChatRequest: req}); err != nil {
+		t.Fatalf("truncateContext failed: %v", err)
+	}
+	if got := totalTokens(req.Messages); got > 300 {
+		t.Errorf("Expected conversation to fit 300 tokens, got %d", got)
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].Content != "be brief" {
+		t.Errorf("Expected system message kept first, got %+v", req.Messages[0])
+	}
+	if last := req.Messages[len(req.Messages)-1]; last.Content != "latest question" {
+		t.Errorf("Expected latest user message kept, got %+v", last)
+	}
+	if req.Messages[1].Role == "tool" {
+		t.Error("Tool result must not be kept without its tool call")
+	}
+	for _, msg := range req.Messages {
+		if s, ok := msg.Content.(string); ok && strings.HasPrefix(s, "first") {
+			t.Error("Expected oldest user message dropped")
+		}
+	}
+}
+
+func TestTruncateContext_Summarize(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{ContextTruncation: TruncationSummarize, ContextWindow: 500}
+	req := &adapter.ChatRequest{Model: "gpt-4", MaxTokens: 100, Messages: longConversation()}
+
+	if err := svc.truncateContext(context.Background(), cfg, &ProxyRequest{ChatRequest: req}); err != nil {
+		t.Fatalf("truncateContext failed: %v", err)
+	}
+	if got := totalTokens(req.Messages); got > 400 {
+		t.Errorf("Expected conversation to fit 400 tokens, got %d", got)
+	}
+	summary, ok := req.Messages[1].Content.(string)
+	if req.Messages[1].Role != "system" || !ok || !strings.Contains(summary, "Summary of earlier conversation") {
+		t.Fatalf("Expected summary after system message, got %+v", req.Messages[1])
+	}
+	if last := req.Messages[len(req.Messages)-1]; last.Content != "latest question" {
+		t.Errorf("Expected latest user message kept, got %+v", last)
+	}
+}
+
+func TestTruncateContext_NoOpAndOverflow(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+
+	// 未开启截断或窗口足够时不修改请求
+	for _, cfg := range []*apiconfig.APIConfig{{ContextWindow: 400}, {ContextTruncation: TruncationDropOldest}} {
+		req := &adapter.ChatRequest{Model: "gpt-4o", Messages: longConversation()}
+		if err := svc.truncateContext(context.Background(), cfg, &ProxyRequest{ChatRequest: req}); err != nil || len(req.Messages) != 8 {
+			t.Errorf("Expected untouched conversation, got %d messages (%v)", len(req.Messages), err)
+		}
+	}
+
+	// 必须保留的消息本身超出窗口时返回错误
+	cfg := &apiconfig.APIConfig{ContextTruncation: TruncationDropOldest, ContextWindow: 150}
+	req := &adapter.ChatRequest{Model: "gpt-4", MaxTokens: 140, Messages: longConversation()}
+	if err := svc.truncateContext(context.Background(), cfg, &ProxyRequest{ChatRequest: req}); err == nil {
+		t.Error("Expected error when required messages exceed the window")
+	}
+
+	if got := contextWindowFor(&apiconfig.APIConfig{}, "gpt-4o-mini"); got != 128000 {
+		t.Errorf("Expected longest prefix match for gpt-4o-mini, got %d", got)
+	}
+}
+
+func TestTruncateContext_KeyLimitAppliesBelowConfigWindow(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	// 配置未开启截断且模型窗口足够，API Key 的上限仍按 drop_oldest 截断
+	cfg := &apiconfig.APIConfig{}
+	req := &adapter.ChatRequest{Model: "gpt-4o", MaxTokens: 100, Messages: longConversation()}
+
+	if err := svc.truncateContext(context.Background(), cfg, &ProxyRequest{ChatRequest: req, MaxContextTokens: 400}); err != nil {
+		t.Fatalf("truncateContext failed: %v", err)
+	}
+	if got := totalTokens(req.Messages); got > 300 {
+		t.Errorf("Expected conversation to fit the key's 300-token budget, got %d", got)
+	}
+	if last := req.Messages[len(req.Messages)-1]; last.Content != "latest question" {
+		t.Errorf("Expected latest user message kept, got %+v", last)
+	}
+}
+
+func TestContextLimitFor_KeyAndConfig(t *testing.T) {
+	cfg := &apiconfig.APIConfig{ContextTruncation: TruncationSummarize, ContextWindow: 1000}
+	chat := &adapter.ChatRequest{Model: "gpt-4"}
+
+	tests := []struct {
+		name         string
+		req          *ProxyRequest
+		wantWindow   int
+		wantStrategy string
+	}{
+		{"config only", &ProxyRequest{ChatRequest: chat}, 1000, TruncationSummarize},
+		{"smaller key limit wins", &ProxyRequest{ChatRequest: chat, MaxContextTokens: 500}, 500, TruncationSummarize},
+		{"larger key limit ignored", &ProxyRequest{ChatRequest: chat, MaxContextTokens: 5000}, 1000, TruncationSummarize},
+		{"key strategy wins", &ProxyRequest{ChatRequest: chat, ContextTruncation: TruncationDropOldest}, 1000, TruncationDropOldest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, strategy := contextLimitFor(cfg, tt.req)
+			if window != tt.wantWindow || strategy != tt.wantStrategy {
+				t.Errorf("Expected %d/%s, got %d/%s", tt.wantWindow, tt.wantStrategy, window, strategy)
+			}
+		})
+	}
+}
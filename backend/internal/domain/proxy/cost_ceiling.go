@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/response"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultEstimateOutputTokens 请求未设置 max_tokens 时预估的输出 token 数
+const defaultEstimateOutputTokens = 1024
+
+// costCeilingParams 请求体中的成本上限扩展参数
+type costCeilingParams struct {
+	MaxCost *float64 `json:"max_cost"`
+	Models  []string `json:"models"` // 候选模型，按质量从高到低排列
+}
+
+// parseCostCeilingParams 从原始请求体解析成本上限参数，解析失败视为未设置
+func parseCostCeilingParams(rawBody []byte) costCeilingParams {
+	var params costCeilingParams
+	json.Unmarshal(rawBody, &params)
+	return params
+}
+
+// CostCeilingError 所有候选模型的预估费用都超过 max_cost
+type CostCeilingError struct {
+	MaxCost       float64
+	CheapestModel string
+	EstimatedCost float64
+}
+
+func (e *CostCeilingError) Error() string {
+	return fmt.Sprintf("no model fits max_cost %.4f, cheapest is %s at %.4f", e.MaxCost, e.CheapestModel, e.EstimatedCost)
+}
+
+// costCeilingErrorDetail 402 响应中附带最便宜候选的预估费用
+type costCeilingErrorDetail struct {
+	response.ErrorDetail
+	MaxCost       float64 `json:"max_cost"`
+	CheapestModel string  `json:"cheapest_model"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+// writeCostCeilingError 返回 402 Payment Required
+func writeCostCeilingError(c *gin.Context, e *CostCeilingError) {
+	_ = c.Error(e)
+	c.JSON(http.StatusPaymentRequired, gin.H{
+		"error": costCeilingErrorDetail{
+			ErrorDetail: response.ErrorDetail{
+				Code:    402001,
+				Message: "Estimated cost exceeds max_cost",
+				Details: e.Error(),
+			},
+			MaxCost:       e.MaxCost,
+			CheapestModel: e.CheapestModel,
+			EstimatedCost: e.EstimatedCost,
+		},
+	})
+}
+
+// estimateRequestUsage 按共享的估算方式估算输入 token，输出 token 取 max_tokens 或默认值
+// 未设置 max_tokens 时，推理模型按 reasoning_effort 加上预估的推理 token（设置时 max_tokens 已包含推理 token）
+func estimateRequestUsage(req *adapter.ChatRequest, model string) adapter.UsageInfo {
+	output := req.MaxTokens
+	if output <= 0 {
+		output = defaultEstimateOutputTokens + adapter.EstimatedReasoningTokens(model, req.ReasoningEffort)
+	}
+	return adapter.UsageInfo{
+		PromptTokens:     estimatePromptTokens(req),
+		CompletionTokens: output,
+	}
+}
+
+// applyCostCeiling 按候选顺序选出预估费用不超过 max_cost 的第一个模型，并改写请求模型
+func (s *service) applyCostCeiling(ctx context.Context, req *ProxyRequest) error {
+	if req.MaxCost == nil {
+		return nil
+	}
+
+	candidates := req.Models
+	if len(candidates) == 0 {
+		candidates = []string{req.Model}
+	}
+	var cheapest *CostCeilingError
+	for _, model := range candidates {
+		cost, ok := s.estimateModelCost(ctx, model, estimateRequestUsage(req.ChatRequest, model))
+		if !ok {
+			continue
+		}
+		if cost <= *req.MaxCost {
+			if model != req.Model {
+				s.log(ctx).Info("✓ Model selected by cost ceiling",
+					logger.String("requested_model", req.Model),
+					logger.String("model", model),
+					logger.Float64("estimated_cost", cost),
+					logger.Float64("max_cost", *req.MaxCost))
+			}
+			req.Model = model
+			req.ChatRequest.Model = model
+			return nil
+		}
+		if cheapest == nil || cost < cheapest.EstimatedCost {
+			cheapest = &CostCeilingError{MaxCost: *req.MaxCost, CheapestModel: model, EstimatedCost: cost}
+		}
+	}
+
+	if cheapest == nil {
+		return errors.New(404002, "No priced API configuration found for the candidate models")
+	}
+	return cheapest
+}
+
+// estimateModelCost 取支持该模型的所有配置中最高的预估费用
+// 负载均衡无论选中哪个配置，实际费用都不会超过该估算
+func (s *service) estimateModelCost(ctx context.Context, model string, usage adapter.UsageInfo) (float64, bool) {
+	configs, err := s.apiConfigRepo.FindByModel(ctx, model)
+	if err != nil || len(configs) == 0 {
+		return 0, false
+	}
+
+	maxCost, priced := 0.0, false
+	for _, cfg := range configs {
+		costResp, err := s.pricingService.CalculateCost(ctx, &pricing.CalculateCostRequest{
+			APIConfigID:  cfg.ID,
+			ModelName:    model,
+			InputTokens:  int64(usage.PromptTokens),
+			OutputTokens: int64(usage.CompletionTokens),
+		})
+		if err != nil {
+			continue
+		}
+		if !priced || costResp.TotalCost > maxCost {
+			maxCost = costResp.TotalCost
+		}
+		priced = true
+	}
+	return maxCost, priced
+}
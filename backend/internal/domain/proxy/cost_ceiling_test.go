@@ -0,0 +1,218 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeConfigRepo 按模型返回配置
+type fakeConfigRepo struct {
+	apiconfig.Repository
+	byModel map[string][]*apiconfig.APIConfig
+}
+
+func (r *fakeConfigRepo) FindByModel(ctx context.Context, model string) ([]*apiconfig.APIConfig, error) {
+	return r.byModel[model], nil
+}
+
+// ratePricing 按 (配置, 模型) 的每 token 单价计费
+type ratePricing struct {
+	pricing.Service
+	rates map[string][2]float64 // "configID/model" -> {input, output}
+}
+
+func (p *ratePricing) CalculateCost(ctx context.Context, req *pricing.CalculateCostRequest) (*pricing.CostCalculationResponse, error) {
+	rate, ok := p.rates[fmt.Sprintf("%d/%s", req.APIConfigID, req.ModelName)]
+	if !ok {
+		return nil, fmt.Errorf("pricing not found")
+	}
+	return &pricing.CostCalculationResponse{
+		TotalCost: float64(req.InputTokens)*rate[0] + float64(req.OutputTokens)*rate[1],
+	}, nil
+}
+
+func newCostCeilingTestService(rates map[string][2]float64) *service {
+	return &service{
+		apiConfigRepo: &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{
+			"large":  {{ID: 1}, {ID: 2}},
+			"medium": {{ID: 3}},
+			"small":  {{ID: 4}},
+		}},
+		pricingService: &ratePricing{rates: rates},
+		logger:         *logger.NewNop(),
+	}
+}
+
+func costCeilingRequest(maxCost float64, maxTokens int) *ProxyRequest {
+	return &ProxyRequest{
+		Model:   "large",
+		MaxCost: &maxCost,
+		Models:  []string{"large", "medium", "small"},
+		ChatRequest: &adapter.ChatRequest{
+			Model:     "large",
+			MaxTokens: maxTokens,
+			Messages:  []adapter.Message{{Role: "user", Content: strings.Repeat("a", 384)}}, // 96 + 4 消息开销 = 100 tokens
+		},
+	}
+}
+
+func TestApplyCostCeiling_SelectsBestAffordableModel(t *testing.T) {
+	// 100 输入 + 100 输出 token
+	tests := []struct {
+		name    string
+		rates   map[string][2]float64
+		maxCost float64
+		want    string
+	}{
+		{
+			name:    "best model fits",
+			rates:   map[string][2]float64{"1/large": {1, 1}, "2/large": {1, 1}, "3/medium": {0.5, 0.5}, "4/small": {0.1, 0.1}},
+			maxCost: 200,
+			want:    "large",
+		},
+		{
+			name:    "falls back to medium",
+			rates:   map[string][2]float64{"1/large": {2, 2}, "2/large": {2, 2}, "3/medium": {0.5, 0.5}, "4/small": {0.1, 0.1}},
+			maxCost: 150,
+			want:    "medium",
+		},
+		{
+			name:    "most expensive config of a model counts",
+			rates:   map[string][2]float64{"1/large": {0.1, 0.1}, "2/large": {3, 3}, "3/medium": {1, 2}, "4/small": {0.1, 0.1}},
+			maxCost: 100,
+			want:    "small",
+		},
+		{
+			name:    "unpriced models are skipped",
+			rates:   map[string][2]float64{"4/small": {0.2, 0.2}},
+			maxCost: 50,
+			want:    "small",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newCostCeilingTestService(tt.rates)
+			req := costCeilingRequest(tt.maxCost, 100)
+			if err := svc.applyCostCeiling(context.Background(), req); err != nil {
+				t.Fatalf("applyCostCeiling failed: %v", err)
+			}
+			if req.Model != tt.want || req.ChatRequest.Model != tt.want {
+				t.Errorf("Expected model %s, got %s/%s", tt.want, req.Model, req.ChatRequest.Model)
+			}
+		})
+	}
+}
+
+func TestApplyCostCeiling_NoneFitReturnsCheapestEstimate(t *testing.T) {
+	svc := newCostCeilingTestService(map[string][2]float64{
+		"1/large": {2, 2}, "2/large": {2, 2}, "3/medium": {1, 1}, "4/small": {0.5, 0.5},
+	})
+	req := costCeilingRequest(10, 100)
+
+	err := svc.applyCostCeiling(context.Background(), req)
+	ceilingErr, ok := err.(*CostCeilingError)
+	if !ok {
+		t.Fatalf("Expected CostCeilingError, got %v", err)
+	}
+	if ceilingErr.CheapestModel != "small" || ceilingErr.EstimatedCost != 100 {
+		t.Errorf("Expected small at 100, got %+v", ceilingErr)
+	}
+	if req.Model != "large" {
+		t.Error("Request model must not change when nothing fits")
+	}
+}
+
+func TestApplyCostCeiling_UsesDefaultOutputEstimate(t *testing.T) {
+	svc := newCostCeilingTestService(map[string][2]float64{"1/large": {0, 1}, "2/large": {0, 1}, "4/small": {0, 0.01}})
+
+	// 未设置 max_tokens 时按默认输出 token 数估算
+	req := costCeilingRequest(500, 0)
+	if err := svc.applyCostCeiling(context.Background(), req); err != nil {
+		t.Fatalf("applyCostCeiling failed: %v", err)
+	}
+	if req.Model != "small" {
+		t.Errorf("Expected small with %d estimated output tokens, got %s", defaultEstimateOutputTokens, req.Model)
+	}
+
+	// 未设置 max_cost 不做任何处理
+	req = costCeilingRequest(0, 0)
+	req.MaxCost = nil
+	if err := svc.applyCostCeiling(context.Background(), req); err != nil || req.Model != "large" {
+		t.Errorf("Expected no-op without max_cost, got %s (%v)", req.Model, err)
+	}
+}
+
+func TestEstimateRequestUsage_ReasoningEffortAddsReasoningTokens(t *testing.T) {
+	req := &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}}
+
+	base := estimateRequestUsage(req, "o3-mini").CompletionTokens
+	req.ReasoningEffort = adapter.ReasoningEffortLow
+	low := estimateRequestUsage(req, "o3-mini").CompletionTokens
+	req.ReasoningEffort = adapter.ReasoningEffortHigh
+	high := estimateRequestUsage(req, "o3-mini").CompletionTokens
+
+	if !(base < low && low < high) {
+		t.Errorf("Expected higher effort to raise the estimate, got none=%d low=%d high=%d", base, low, high)
+	}
+	if got := estimateRequestUsage(req, "gpt-4o").CompletionTokens; got != base {
+		t.Errorf("Non-reasoning models drop the effort, expected %d, got %d", base, got)
+	}
+
+	// max_tokens 已包含推理 token，不再额外估算
+	req.MaxTokens = 500
+	if got := estimateRequestUsage(req, "o3-mini").CompletionTokens; got != 500 {
+		t.Errorf("Expected max_tokens to bound the estimate, got %d", got)
+	}
+}
+
+func TestEstimateRequestUsage_HonorsMaxCompletionTokens(t *testing.T) {
+	req, err := protocol.NewOpenAIConverter().ParseRequest(
+		[]byte(`{"model":"o3-mini","messages":[{"role":"user","content":"hi"}],"max_completion_tokens":300}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := estimateRequestUsage(req, "o3-mini").CompletionTokens; got != 300 {
+		t.Errorf("Expected max_completion_tokens to bound the estimate, got %d", got)
+	}
+}
+
+func TestApplyCostCeiling_HighReasoningEffortCostsMore(t *testing.T) {
+	svc := &service{
+		apiConfigRepo: &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{
+			"o3":      {{ID: 1}},
+			"o3-mini": {{ID: 2}},
+		}},
+		pricingService: &ratePricing{rates: map[string][2]float64{"1/o3": {0, 0.01}, "2/o3-mini": {0, 0.001}}},
+		logger:         *logger.NewNop(),
+	}
+	newReq := func(effort string) *ProxyRequest {
+		maxCost := 100.0
+		return &ProxyRequest{
+			Model:   "o3",
+			MaxCost: &maxCost,
+			Models:  []string{"o3", "o3-mini"},
+			ChatRequest: &adapter.ChatRequest{
+				Model:           "o3",
+				ReasoningEffort: effort,
+				Messages:        []adapter.Message{{Role: "user", Content: "hi"}},
+			},
+		}
+	}
+
+	low := newReq(adapter.ReasoningEffortLow)
+	if err := svc.applyCostCeiling(context.Background(), low); err != nil || low.Model != "o3" {
+		t.Fatalf("Expected o3 to fit at low effort, got %s, %v", low.Model, err)
+	}
+	high := newReq(adapter.ReasoningEffortHigh)
+	if err := svc.applyCostCeiling(context.Background(), high); err != nil || high.Model != "o3-mini" {
+		t.Errorf("Expected high effort to push o3 over max_cost, got %s, %v", high.Model, err)
+	}
+}
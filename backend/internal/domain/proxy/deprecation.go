@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/modelmeta"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecationHeader 请求弃用模型时返回的响应头，包含推荐的替代模型
+const DeprecationHeader = "X-Prism-Deprecation"
+
+// DeprecationSource 查询模型的弃用信息，模型未弃用时返回 nil
+type DeprecationSource interface {
+	GetDeprecation(ctx context.Context, model string) (*modelmeta.ModelMetadata, error)
+}
+
+// SetDeprecationSource 设置模型弃用信息来源，未设置时不返回弃用提示
+func (h *Handler) SetDeprecationSource(source DeprecationSource) {
+	h.deprecations = source
+}
+
+// ResponseWarning 非流式响应扩展字段 prism_warnings 中的一条提示
+type ResponseWarning struct {
+	Type        string     `json:"type"`
+	Model       string     `json:"model"`
+	Replacement string     `json:"replacement,omitempty"`
+	Message     string     `json:"message"`
+	SunsetAt    *time.Time `json:"sunset_at,omitempty"`
+}
+
+// checkDeprecation 请求的模型已弃用时写入 X-Prism-Deprecation 响应头并返回提示
+// 查询失败时不提示，不影响请求
+func (h *Handler) checkDeprecation(c *gin.Context, model string) *ResponseWarning {
+	if h.deprecations == nil {
+		return nil
+	}
+	meta, err := h.deprecations.GetDeprecation(c.Request.Context(), model)
+	if err != nil || meta == nil {
+		return nil
+	}
+
+	warning := &ResponseWarning{
+		Type:        "model_deprecated",
+		Model:       model,
+		Replacement: meta.Replacement,
+		Message:     meta.DeprecationMessage,
+		SunsetAt:    meta.SunsetAt,
+	}
+	if warning.Message == "" {
+		warning.Message = fmt.Sprintf("Model %s is deprecated", model)
+		if meta.Replacement != "" {
+			warning.Message += fmt.Sprintf(", use %s instead", meta.Replacement)
+		}
+	}
+
+	header := fmt.Sprintf("model=%q", model)
+	if meta.Replacement != "" {
+		header += fmt.Sprintf("; replacement=%q", meta.Replacement)
+	}
+	if meta.SunsetAt != nil {
+		header += fmt.Sprintf("; sunset=%q", meta.SunsetAt.UTC().Format(time.RFC3339))
+	}
+	c.Header(DeprecationHeader, header)
+	return warning
+}
+
+// withWarnings 在协议格式的响应中追加 prism_warnings 扩展字段，无法追加时原样返回
+func withWarnings(formatted interface{}, warnings ...*ResponseWarning) interface{} {
+	if len(warnings) == 0 {
+		return formatted
+	}
+	return withExtension(formatted, "prism_warnings", warnings)
+}
+
+// withExtension 在协议格式的响应中追加一个 prism_* 扩展字段，无法追加时原样返回
+func withExtension(formatted interface{}, name string, value interface{}) interface{} {
+	data, err := json.Marshal(formatted)
+	if err != nil {
+		return formatted
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return formatted
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return formatted
+	}
+	fields[name] = encoded
+	return fields
+}
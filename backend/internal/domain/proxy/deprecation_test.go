@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/modelmeta"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeDeprecations map[string]*modelmeta.ModelMetadata
+
+func (f fakeDeprecations) GetDeprecation(ctx context.Context, model string) (*modelmeta.ModelMetadata, error) {
+	return f[model], nil
+}
+
+// okService 对所有聊天请求返回固定回复
+type okService struct {
+	Service
+}
+
+func (s *okService) ChatCompletions(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	return &adapter.ChatResponse{
+		ID:      "chatcmpl-1",
+		Model:   req.Model,
+		Choices: []adapter.ChatChoice{{Message: adapter.Message{Role: "assistant", Content: "hello"}, FinishReason: "stop"}},
+	}, nil
+}
+
+func deprecationGateway(t *testing.T) *httptest.Server {
+	t.Helper()
+	sunset := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+	h := NewHandler(&okService{})
+	h.SetDeprecationSource(fakeDeprecations{
+		"gpt-4-0613": {Model: "gpt-4-0613", Deprecated: true, Replacement: "gpt-4o", SunsetAt: &sunset},
+	})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Next()
+	}, h.ChatCompletionsOpenAI)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+func TestHandler_DeprecatedModelCarriesWarning(t *testing.T) {
+	gateway := deprecationGateway(t)
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4-0613","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the deprecated model to still be served, got %d", resp.StatusCode)
+	}
+	header := resp.Header.Get(DeprecationHeader)
+	if !strings.Contains(header, `replacement="gpt-4o"`) || !strings.Contains(header, `sunset="2026-12-01T00:00:00Z"`) {
+		t.Errorf("Expected the deprecation header to suggest the replacement, got %q", header)
+	}
+
+	var body struct {
+		Choices  []json.RawMessage `json:"choices"`
+		Warnings []ResponseWarning `json:"prism_warnings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Choices) != 1 {
+		t.Errorf("Expected the original response fields kept, got %d choices", len(body.Choices))
+	}
+	if len(body.Warnings) != 1 || body.Warnings[0].Type != "model_deprecated" || body.Warnings[0].Replacement != "gpt-4o" {
+		t.Errorf("Expected a model_deprecated warning pointing to gpt-4o, got %+v", body.Warnings)
+	}
+}
+
+func TestHandler_ActiveModelHasNoWarning(t *testing.T) {
+	gateway := deprecationGateway(t)
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if header := resp.Header.Get(DeprecationHeader); header != "" {
+		t.Errorf("Expected no deprecation header, got %q", header)
+	}
+	var body map[string]json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&body)
+	if _, ok := body["prism_warnings"]; ok {
+		t.Errorf("Expected no prism_warnings for an active model")
+	}
+}
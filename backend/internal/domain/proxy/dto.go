@@ -1,6 +1,11 @@
 package proxy
 
-import "api-aggregator/backend/internal/adapter"
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/redact"
+	"encoding/json"
+)
 
 // ProxyRequest 代理请求
 type ProxyRequest struct {
@@ -9,4 +14,77 @@ type ProxyRequest struct {
 	Model       string                `json:"model" binding:"required"`
 	Stream      bool                  `json:"stream"`
 	ChatRequest *adapter.ChatRequest  `json:"-"` // 完整的请求对象
+	Redactor    *redact.Redactor      `json:"-"` // 响应脱敏器（API Key 未启用时为 nil）
+	Sizes       *adapter.PayloadSizes `json:"-"` // 上游请求/响应体积（由适配器层记录）
+	NoLog       bool                  `json:"-"` // API Key 关闭了请求日志
+	ConfigNoLog bool                  `json:"-"` // 本次尝试选中的配置关闭了请求日志，故障转移时按新配置重新设置
+	MaxCost     *float64              `json:"-"` // 单次请求预估费用上限（配额单位）
+	Models      []string              `json:"-"` // 配合 MaxCost 使用的候选模型，按质量从高到低排列
+	Revalidate  bool                  `json:"-"` // 后台刷新过期缓存：跳过缓存查询，按配置决定是否计费
+	Warming     bool                  `json:"-"` // 后台预热热门缓存（同时设置 Revalidate），按 cache_warming 配置决定是否计费
+	CacheKey    string                `json:"-"` // 预热时沿用缓存记录的键，为空时按请求生成
+
+	NoSemanticCache bool `json:"-"` // 只做缓存键精确匹配，不返回语义相近的缓存响应
+	NoCache         bool `json:"-"` // 不查询也不写入响应缓存（自动续写的子请求）
+
+	ProviderPreference []string `json:"-"` // 请求头指定的供应商偏好，按优先级排列
+	Provider           string   `json:"-"` // 实际选中配置的类型，写入请求日志
+	ServiceTier        string   `json:"-"` // 实际发送给上游的 service_tier，影响计费并写入请求日志
+	ToolsDropped       int      `json:"-"` // 超出工具数上限被丢弃或合并的工具数，写入请求日志
+	ToolsMerged        bool     `json:"-"` // 超出上限的工具已合并为分发工具，响应中的调用需要还原
+	MaxHistory         int      `json:"-"` // API Key 配置的对话历史消息数上限，0 表示不限制
+	HistoryPolicy      string   `json:"-"` // API Key 配置的对话历史超限策略（reject / truncate）
+	HistoryDropped     int      `json:"-"` // 超出对话历史上限被截断的消息数，写入请求日志
+	MaxContextTokens   int      `json:"-"` // API Key 配置的上下文 token 上限，0 表示沿用配置或模型的上下文窗口
+	ContextTruncation  string   `json:"-"` // API Key 配置的上下文截断策略（drop_oldest / summarize），为空时沿用配置
+	UpstreamRequestID  string   `json:"-"` // 上游返回的请求 ID，写入请求日志并通过响应头返回客户端
+	RequestID          string   `json:"-"` // 网关为请求分配的 ID（X-Request-ID），写入链路追踪的 span 属性
+	Reserved           int64    `json:"-"` // 流式请求按 max_tokens 预扣或引用预留得到的配额，结束时按实际用量结算
+	HoldID             string   `json:"-"` // 请求头引用的配额预留 ID
+	StreamPrecharge    bool     `json:"-"` // 配额检查得到的用户设置：流式请求开始前按 max_tokens 预扣配额
+	PacingTPS          int      `json:"-"` // API Key 配置的流式输出速率（tokens/秒），0 表示不限速
+	Endpoint           string   `json:"-"` // 非对话接口的请求路径，写入请求日志，为空时为 /v1/chat/completions
+	RoutingMode        string   `json:"-"` // 多个配置提供同一模型时的选择方式（cost / latency），为空按负载均衡策略
+	MaxContinuations   int      `json:"-"` // 响应因 max_tokens 截断时的自动续写次数上限，0 表示不续写（流式请求不支持）
+	Candidates         int      `json:"-"` // 按权重分发给多个配置的候选数，0 表示普通请求（流式请求不支持）
+	MaxCandidates      int      `json:"-"` // API Key 允许的候选数上限，0 表示不允许多候选
+	PinnedConfigID     uint     `json:"-"` // 候选请求固定使用的配置，失败时不换用其他配置
+	RoutingDiagnostics bool     `json:"-"` // 请求通过 X-Prism-Routing 头要求返回路由诊断信息
+
+	LengthRoutes []apikey.LengthRoute `json:"-"` // API Key 配置的按提示词长度路由规则
+
+	CandidateResults []*CandidateResult `json:"-"` // 多候选采样的全部结果，由服务写入，处理器输出为 prism_candidates
+	Routing          *RoutingInfo       `json:"-"` // 路由诊断信息，由服务在允许时写入，处理器输出为 prism_routing
+}
+
+// MessageBatchRequest Anthropic Message Batches 创建请求
+type MessageBatchRequest struct {
+	Requests []MessageBatchRequestItem `json:"requests"`
+}
+
+// MessageBatchRequestItem 批次中的一条请求，params 为完整的 Messages 请求体
+type MessageBatchRequestItem struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// messageBatchResultLine 结果文件（JSONL）中的一行
+type messageBatchResultLine struct {
+	CustomID string             `json:"custom_id"`
+	Result   messageBatchResult `json:"result"`
+}
+
+type messageBatchResult struct {
+	Type    string                 `json:"type"` // succeeded / errored
+	Message interface{}            `json:"message,omitempty"`
+	Error   *messageBatchItemError `json:"error,omitempty"`
+}
+
+// messageBatchItemError 与 Anthropic 错误响应结构一致
+type messageBatchItemError struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
 }
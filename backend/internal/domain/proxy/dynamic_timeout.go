@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// modelLatencyWindow 统计模型 p99 响应时间的日志时间窗口
+	modelLatencyWindow = 24 * time.Hour
+	// modelLatencyRefresh 模型 p99 响应时间的刷新间隔
+	modelLatencyRefresh = 5 * time.Minute
+	// modelLatencyMinSamples 样本数不足时不采用动态超时，避免少量慢请求决定超时
+	modelLatencyMinSamples = 20
+	// modelLatencyPercentile 动态超时参照的响应时间分位数
+	modelLatencyPercentile = 0.99
+)
+
+// modelLatencyStore 缓存各模型近期成功请求的 p99 响应时间，过期后在后台从请求日志刷新
+type modelLatencyStore struct {
+	mu       sync.RWMutex
+	p99      map[string]time.Duration // 模型 -> p99 响应时间
+	loadedAt time.Time
+	loading  bool
+}
+
+func newModelLatencyStore() *modelLatencyStore {
+	return &modelLatencyStore{p99: make(map[string]time.Duration)}
+}
+
+// P99 返回模型的 p99 响应时间，尚无足够样本时返回 false
+func (st *modelLatencyStore) P99(model string) (time.Duration, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	d, ok := st.p99[model]
+	return d, ok && d > 0
+}
+
+// Set 替换全部模型的 p99 响应时间
+func (st *modelLatencyStore) Set(p99 map[string]time.Duration, now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.p99 = p99
+	st.loadedAt = now
+	st.loading = false
+}
+
+// claimRefresh 数据已过期且没有正在进行的刷新时占用刷新，调用方负责完成后调用 Set 或 releaseRefresh
+func (st *modelLatencyStore) claimRefresh(now time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.loading || now.Sub(st.loadedAt) < modelLatencyRefresh {
+		return false
+	}
+	st.loading = true
+	return true
+}
+
+// releaseRefresh 刷新失败时释放占用并推迟到下一个刷新间隔重试
+func (st *modelLatencyStore) releaseRefresh(now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.loadedAt = now
+	st.loading = false
+}
+
+// refreshModelLatency 从请求日志重新统计各模型的 p99 响应时间
+func (s *service) refreshModelLatency(ctx context.Context) {
+	now := time.Now()
+	p99, err := s.logService.GetLatencyPercentiles(ctx, now.Add(-modelLatencyWindow), modelLatencyPercentile, modelLatencyMinSamples)
+	if err != nil {
+		s.logger.Warn("Failed to refresh model latency percentiles", logger.Error(err))
+		s.modelLatency.releaseRefresh(now)
+		return
+	}
+	s.modelLatency.Set(p99, now)
+}
+
+// dynamicTimeout 由 p99 响应时间乘以倍数得到上游超时，并限制在 floor~ceiling 之间（上下限为 0 表示不限制）
+func dynamicTimeout(p99 time.Duration, multiplier float64, floor, ceiling time.Duration) time.Duration {
+	timeout := time.Duration(float64(p99) * multiplier)
+	if floor > 0 && timeout < floor {
+		timeout = floor
+	}
+	if ceiling > 0 && timeout > ceiling {
+		timeout = ceiling
+	}
+	return timeout
+}
+
+// upstreamTimeout 返回模型的动态上游超时，未开启或该模型尚无足够历史数据时返回 false，沿用配置的超时
+func (s *service) upstreamTimeout(model string) (time.Duration, bool) {
+	if s.runtimeConfig == nil || s.modelLatency == nil || !s.runtimeConfig.Get().IsDynamicTimeoutEnabled() {
+		return 0, false
+	}
+	if s.modelLatency.claimRefresh(time.Now()) {
+		go s.refreshModelLatency(context.Background())
+	}
+	p99, ok := s.modelLatency.P99(model)
+	if !ok {
+		return 0, false
+	}
+	multiplier, floor, ceiling := s.runtimeConfig.Get().GetDynamicTimeoutBounds()
+	return dynamicTimeout(p99, multiplier, floor, ceiling), true
+}
+
+// withUpstreamTimeout 按动态超时调整直连配置的 HTTP 客户端超时（向上取整到秒），返回的配置为副本
+// 账号池适配器使用固定的客户端超时，只受调用 context 的截止时间约束
+func withUpstreamTimeout(apiConfig *apiconfig.APIConfig, timeout time.Duration) *apiconfig.APIConfig {
+	cfg := *apiConfig
+	cfg.Timeout = int((timeout + time.Second - 1) / time.Second)
+	return &cfg
+}
@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// latencyLog 返回固定的模型 p99 响应时间并记录查询参数
+type latencyLog struct {
+	fakeLog
+	p99        map[string]time.Duration
+	since      time.Time
+	percentile float64
+	minSamples int
+}
+
+func (l *latencyLog) GetLatencyPercentiles(ctx context.Context, since time.Time, percentile float64, minSamples int) (map[string]time.Duration, error) {
+	l.since, l.percentile, l.minSamples = since, percentile, minSamples
+	return l.p99, nil
+}
+
+// newDynamicTimeoutService 开启动态超时（3 倍 p99，10s~600s）并预置模型 p99 响应时间
+func newDynamicTimeoutService(p99 map[string]time.Duration) *service {
+	svc, _ := newFailoverTestService(0)
+	cfg := svc.runtimeConfig.Get()
+	cfg.DynamicTimeoutEnabled = true
+	cfg.DynamicTimeoutMultiplier = 3
+	cfg.DynamicTimeoutMin = 10 * time.Second
+	cfg.DynamicTimeoutMax = 600 * time.Second
+	svc.modelLatency = newModelLatencyStore()
+	svc.modelLatency.Set(p99, time.Now())
+	return svc
+}
+
+func TestUpstreamTimeout_SlowModelGetsLongerTimeout(t *testing.T) {
+	svc := newDynamicTimeoutService(map[string]time.Duration{
+		"fast-model": 5 * time.Second,
+		"slow-model": 90 * time.Second,
+	})
+
+	fast, ok := svc.upstreamTimeout("fast-model")
+	if !ok {
+		t.Fatal("fast model should use a dynamic timeout")
+	}
+	slow, ok := svc.upstreamTimeout("slow-model")
+	if !ok {
+		t.Fatal("slow model should use a dynamic timeout")
+	}
+	if slow <= fast {
+		t.Fatalf("slow model timeout %v should exceed fast model timeout %v", slow, fast)
+	}
+	if fast != 15*time.Second || slow != 270*time.Second {
+		t.Fatalf("timeouts = %v, %v, want 15s and 270s", fast, slow)
+	}
+}
+
+func TestUpstreamTimeout_ClampedToBounds(t *testing.T) {
+	svc := newDynamicTimeoutService(map[string]time.Duration{
+		"instant-model": 100 * time.Millisecond,
+		"glacial-model": 10 * time.Minute,
+	})
+
+	if got, _ := svc.upstreamTimeout("instant-model"); got != 10*time.Second {
+		t.Fatalf("instant model timeout = %v, want floor 10s", got)
+	}
+	if got, _ := svc.upstreamTimeout("glacial-model"); got != 600*time.Second {
+		t.Fatalf("glacial model timeout = %v, want ceiling 600s", got)
+	}
+}
+
+func TestUpstreamTimeout_FallsBackWithoutDataOrWhenDisabled(t *testing.T) {
+	svc := newDynamicTimeoutService(map[string]time.Duration{"gpt-4": 20 * time.Second})
+
+	if _, ok := svc.upstreamTimeout("unknown-model"); ok {
+		t.Fatal("model without latency history should keep the configured timeout")
+	}
+	svc.runtimeConfig.Get().DynamicTimeoutEnabled = false
+	if _, ok := svc.upstreamTimeout("gpt-4"); ok {
+		t.Fatal("disabled mode should keep the configured timeout")
+	}
+}
+
+func TestRefreshModelLatency_LoadsRecentP99(t *testing.T) {
+	svc, _ := newFailoverTestService(0)
+	logs := &latencyLog{p99: map[string]time.Duration{"gpt-4": 4 * time.Second}}
+	svc.logService = logs
+	svc.modelLatency = newModelLatencyStore()
+
+	svc.refreshModelLatency(context.Background())
+
+	if got, ok := svc.modelLatency.P99("gpt-4"); !ok || got != 4*time.Second {
+		t.Fatalf("p99 = %v, %v, want 4s", got, ok)
+	}
+	if logs.percentile != modelLatencyPercentile || logs.minSamples != modelLatencyMinSamples {
+		t.Fatalf("queried percentile %v with %d samples", logs.percentile, logs.minSamples)
+	}
+	if window := time.Since(logs.since); window < modelLatencyWindow || window > modelLatencyWindow+time.Minute {
+		t.Fatalf("queried window %v, want %v", window, modelLatencyWindow)
+	}
+	if svc.modelLatency.claimRefresh(time.Now()) {
+		t.Fatal("freshly loaded latencies should not be refreshed again")
+	}
+	if !svc.modelLatency.claimRefresh(time.Now().Add(modelLatencyRefresh)) {
+		t.Fatal("latencies should be refreshed after the refresh interval")
+	}
+}
+
+func TestCallUpstream_DynamicTimeoutCutsOffSlowUpstream(t *testing.T) {
+	done := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-done:
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("too late"))
+	}))
+	defer upstream.Close()
+	defer close(done)
+
+	svc := newDynamicTimeoutService(map[string]time.Duration{"gpt-4": 50 * time.Millisecond})
+	svc.apiConfigRepo = &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"gpt-4": {failoverConfig(1, upstream.URL, 0)}}}
+	cfg := svc.runtimeConfig.Get()
+	cfg.DynamicTimeoutMin, cfg.DynamicTimeoutMax = 100*time.Millisecond, 200*time.Millisecond
+
+	start := time.Now()
+	attempt, err := svc.callUpstream(context.Background(), failoverRequest(), map[uint]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.err == nil {
+		t.Fatal("upstream slower than the dynamic timeout should fail")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("call took %v, dynamic timeout was not applied", elapsed)
+	}
+}
@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+)
+
+// isEchoModel 判断请求是否由内置 prism-echo 测试模型处理（需在运行时配置中启用）
+func (s *service) isEchoModel(model string) bool {
+	return model == adapter.EchoModel && s.runtimeConfig.Get().IsEchoModelEnabled()
+}
+
+// isEchoCall 内置 echo 配置没有持久化 ID，也没有定价，按零费用处理
+func isEchoCall(apiConfigID uint, model string) bool {
+	return apiConfigID == 0 && model == adapter.EchoModel
+}
+
+// echoAPIConfig 内置 echo 模型使用的虚拟配置，不落库
+func echoAPIConfig() *apiconfig.APIConfig {
+	return &apiconfig.APIConfig{
+		Name:        adapter.EchoModel,
+		Type:        adapter.EchoType,
+		ConfigType:  apiconfig.ConfigTypeDirect,
+		Models:      apiconfig.StringArray{adapter.EchoModel},
+		IsActive:    true,
+		LogRequests: true,
+	}
+}
+
+// newEchoAdapter 按运行时配置创建 echo 适配器
+func (s *service) newEchoAdapter() adapter.Adapter {
+	latency, transform, completionTokens := s.runtimeConfig.Get().GetEchoModelOptions()
+	return adapter.NewEchoAdapter(adapter.EchoOptions{
+		Latency:          latency,
+		Transform:        transform,
+		CompletionTokens: completionTokens,
+	})
+}
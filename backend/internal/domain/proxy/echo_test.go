@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func newEchoTestService(enabled bool, transform string) (*service, *openQuota, *fakeLog) {
+	rc := runtime.NewManager(nil)
+	cfg := rc.Get()
+	cfg.EchoModelEnabled = enabled
+	cfg.EchoModelTransform = transform
+
+	q, l := &openQuota{}, &fakeLog{}
+	return &service{
+		adapterFactory: adapter.NewFactory(),
+		apiConfigRepo:  &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{}},
+		quotaService:   q,
+		pricingService: &fakePricing{cost: 42},
+		logService:     l,
+		runtimeConfig:  rc,
+		logger:         *logger.NewNop(),
+	}, q, l
+}
+
+func echoRequest(stream bool) *ProxyRequest {
+	return &ProxyRequest{
+		UserID: 1,
+		Model:  adapter.EchoModel,
+		ChatRequest: &adapter.ChatRequest{
+			Model:  adapter.EchoModel,
+			Stream: stream,
+			Messages: []adapter.Message{
+				{Role: "system", Content: "ignored"},
+				{Role: "user", Content: "hello echo world"},
+			},
+		},
+	}
+}
+
+func TestEchoModel_ReturnsPromptThroughPipeline(t *testing.T) {
+	svc, q, l := newEchoTestService(true, adapter.EchoTransformUpper)
+
+	resp, err := svc.ChatCompletions(context.Background(), echoRequest(false))
+	if err != nil {
+		t.Fatalf("ChatCompletions failed: %v", err)
+	}
+	if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != "HELLO ECHO WORLD" {
+		t.Errorf("Expected transformed prompt, got %q", got)
+	}
+	if resp.Usage.CompletionTokens != 4 || resp.Usage.TotalTokens == 0 {
+		t.Errorf("Unexpected usage %+v", resp.Usage)
+	}
+	if len(l.created) != 1 || l.created[0].Model != adapter.EchoModel || l.created[0].QuotaCost != 0 {
+		t.Errorf("Expected one free request log, got %+v", l.created)
+	}
+	if q.deducted != 0 {
+		t.Errorf("Echo model must not cost quota, deducted %d", q.deducted)
+	}
+}
+
+func TestEchoModel_Streams(t *testing.T) {
+	svc, q, l := newEchoTestService(true, "")
+	req := echoRequest(true)
+
+	streamResp, err := svc.ChatCompletionsStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletionsStream failed: %v", err)
+	}
+	w := NewStreamWrapper(streamResp.Response.Body, context.Background(), svc, req, streamResp.APIConfigID, 0, protocol.ProtocolOpenAI)
+	body, err := io.ReadAll(w)
+	w.Close()
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+
+	for _, piece := range []string{"hello ", "echo ", "world"} {
+		if !strings.Contains(string(body), `"content":"`+piece+`"`) {
+			t.Errorf("Expected stream chunk %q in %s", piece, body)
+		}
+	}
+	if !strings.HasSuffix(string(body), "data: [DONE]\n\n") {
+		t.Error("Expected stream to end with [DONE]")
+	}
+	if len(l.created) != 1 || l.created[0].TokensUsed == 0 || q.deducted != 0 {
+		t.Errorf("Expected usage logged without charge, got logs %+v deducted %d", l.created, q.deducted)
+	}
+}
+
+func TestEchoModel_DisabledByDefault(t *testing.T) {
+	svc, _, _ := newEchoTestService(false, "")
+	if _, err := svc.ChatCompletions(context.Background(), echoRequest(false)); err == nil {
+		t.Fatal("Expected prism-echo to be unavailable when disabled")
+	}
+}
@@ -0,0 +1,253 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/response"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxEmbeddingInputs   = 100000 // 单次请求最多包含的输入条数
+	embeddingConcurrency = 4      // 单次请求发往上游的并发批次数
+)
+
+// EmbeddingsRequest OpenAI /v1/embeddings 请求，input 为字符串或字符串数组
+type EmbeddingsRequest struct {
+	Model          string      `json:"model" binding:"required"`
+	Input          interface{} `json:"input" binding:"required"`
+	Dimensions     int         `json:"dimensions,omitempty"`
+	EncodingFormat string      `json:"encoding_format,omitempty"` // 仅支持 float
+	User           string      `json:"user,omitempty"`
+}
+
+// EmbeddingsUsage 各批次用量之和
+type EmbeddingsUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// EmbeddingFailure 上游调用失败的输入，Index 为在请求 input 中的位置
+type EmbeddingFailure struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// EmbeddingsResponse OpenAI 格式的 embeddings 响应
+// 部分批次失败时 data 只包含成功的输入，失败的输入列在 failed 中，只按成功部分计费
+type EmbeddingsResponse struct {
+	Object string              `json:"object"`
+	Data   []adapter.Embedding `json:"data"`
+	Model  string              `json:"model"`
+	Usage  EmbeddingsUsage     `json:"usage"`
+	Failed []EmbeddingFailure  `json:"failed,omitempty"`
+}
+
+// embeddingInputs 解析 input，只接受字符串或字符串数组
+func embeddingInputs(input interface{}) ([]string, error) {
+	switch v := input.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		inputs := make([]string, len(v))
+		for i, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("input.%d: must be a string", i)
+			}
+			inputs[i] = text
+		}
+		if len(inputs) == 0 || len(inputs) > maxEmbeddingInputs {
+			return nil, fmt.Errorf("input: must contain between 1 and %d items", maxEmbeddingInputs)
+		}
+		return inputs, nil
+	default:
+		return nil, fmt.Errorf("input: must be a string or an array of strings")
+	}
+}
+
+// Embeddings 处理 embeddings 请求（OpenAI 格式）
+// @Summary 生成 embeddings
+// @Description 输入超过供应商单次上限时自动分批并发调用，按原顺序合并结果并累加用量；部分批次失败时在 failed 中列出失败的输入位置
+// @Tags Proxy
+// @Accept json
+// @Produce json
+// @Param request body EmbeddingsRequest true "Embeddings 请求"
+// @Success 200 {object} EmbeddingsResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 402 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /v1/embeddings [post]
+func (h *Handler) Embeddings(c *gin.Context) {
+	var req EmbeddingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeEmbeddingsValidationError(c, err.Error())
+		return
+	}
+	inputs, err := embeddingInputs(req.Input)
+	if err != nil {
+		writeEmbeddingsValidationError(c, err.Error())
+		return
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" {
+		writeEmbeddingsValidationError(c, "encoding_format: only float is supported")
+		return
+	}
+
+	userID, apiKeyID := c.GetUint("user_id"), c.GetUint("api_key_id")
+	if userID == 0 || apiKeyID == 0 {
+		response.Error(c, http.StatusUnauthorized, 401001, "User ID not found in context", nil)
+		return
+	}
+
+	proxyReq := &ProxyRequest{UserID: userID, APIKeyID: apiKeyID, Model: req.Model, Endpoint: "/v1/embeddings"}
+	if header := c.GetHeader(ProviderPreferenceHeader); header != "" {
+		proxyReq.ProviderPreference = parseProviderPreference(header)
+	}
+
+	resp, err := h.service.Embeddings(c.Request.Context(), proxyReq, &adapter.EmbeddingRequest{
+		Model:      req.Model,
+		Input:      inputs,
+		Dimensions: req.Dimensions,
+		User:       req.User,
+	})
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeEmbeddingsValidationError 以 OpenAI 错误格式返回请求校验失败
+func writeEmbeddingsValidationError(c *gin.Context, message string) {
+	writeValidationError(c, protocol.ProtocolOpenAI, &protocol.ValidationError{Message: message})
+}
+
+// Embeddings 选择支持 embeddings 的配置，按供应商单次上限分批并发调用并合并结果
+// 全部批次失败时返回错误；部分失败时返回成功部分，只按成功部分的用量计费
+func (s *service) Embeddings(ctx context.Context, req *ProxyRequest, embedReq *adapter.EmbeddingRequest) (*EmbeddingsResponse, error) {
+	startTime := time.Now()
+	scopeRequestLog(ctx, req)
+
+	if _, err := s.checkQuota(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	apiConfig, err := s.selectAPIConfig(ctx, req.Model, req.ProviderPreference, req.RoutingMode, nil)
+	if err != nil {
+		return nil, err
+	}
+	scopeConfigLog(ctx, apiConfig)
+	req.Provider = apiConfig.Type
+	req.ConfigNoLog = !apiConfig.LogRequests
+	if err := s.validatePricing(ctx, apiConfig.ID, req.Model); err != nil {
+		return nil, errors.Wrap(err, 400001, "Pricing not configured for this model")
+	}
+
+	unsupported := &adapter.CapabilityError{AdapterType: apiConfig.Type, Feature: "embeddings"}
+	if !apiConfig.IsDirect() {
+		return nil, unsupported
+	}
+	adapterInstance, err := s.adapterFactory.CreateAdapter(apiConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, 500003, "Failed to create adapter")
+	}
+	embedder, ok := adapterInstance.(adapter.EmbeddingAdapter)
+	if !ok || !adapter.GetCapabilities(adapterInstance).Embeddings {
+		return nil, unsupported
+	}
+
+	ctx, upstreamID := adapter.WithUpstreamRequestID(ctx)
+	resp := embedInBatches(ctx, embedder, embedReq)
+	req.UpstreamRequestID = upstreamID.Value()
+
+	if len(resp.Data) == 0 {
+		err := fmt.Errorf("all %d embedding inputs failed: %s", len(resp.Failed), resp.Failed[0].Error)
+		s.logRequest(ctx, req, apiConfig.ID, 0, 0, time.Since(startTime), err, false)
+		return nil, errors.Wrap(err, 500004, "Failed to call upstream API")
+	}
+	if len(resp.Failed) > 0 {
+		s.log(ctx).Warn("Embedding batches partially failed",
+			logger.Uint("api_config_id", apiConfig.ID),
+			logger.Int("failed_inputs", len(resp.Failed)),
+			logger.Int("succeeded_inputs", len(resp.Data)))
+	}
+
+	usage := adapter.UsageInfo{PromptTokens: resp.Usage.PromptTokens, TotalTokens: resp.Usage.TotalTokens}
+	cost, err := s.calculateAndDeductCost(ctx, req.UserID, apiConfig.ID, req.Model, req.ServiceTier, usage)
+	if err != nil {
+		s.log(ctx).Error("CRITICAL: Embeddings succeeded but billing failed - manual intervention required",
+			logger.Uint("user_id", req.UserID),
+			logger.Uint("api_config_id", apiConfig.ID),
+			logger.String("model", req.Model),
+			logger.Int("prompt_tokens", usage.PromptTokens),
+			logger.Error(err))
+	}
+	s.logRequest(ctx, req, apiConfig.ID, usage.TotalTokens, cost, time.Since(startTime), nil, false)
+
+	resp.Model = req.Model
+	return resp, nil
+}
+
+// embedInBatches 按供应商单次上限切分输入，最多 embeddingConcurrency 个批次并发调用
+// 结果按输入顺序重新编号，用量为成功批次之和，失败批次的每条输入记入 Failed
+func embedInBatches(ctx context.Context, embedder adapter.EmbeddingAdapter, req *adapter.EmbeddingRequest) *EmbeddingsResponse {
+	size := embedder.MaxEmbeddingBatch()
+	if size <= 0 {
+		size = len(req.Input)
+	}
+
+	type batch struct {
+		start, end int
+		resp       *adapter.EmbeddingResponse
+		err        error
+	}
+	var batches []*batch
+	for start := 0; start < len(req.Input); start += size {
+		batches = append(batches, &batch{start: start, end: min(start+size, len(req.Input))})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, embeddingConcurrency)
+	for _, b := range batches {
+		batchReq := *req
+		batchReq.Input = req.Input[b.start:b.end]
+
+		wg.Add(1)
+		go func(b *batch, batchReq *adapter.EmbeddingRequest) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			b.resp, b.err = embedder.Embed(ctx, batchReq)
+		}(b, &batchReq)
+	}
+	wg.Wait()
+
+	merged := &EmbeddingsResponse{Object: "list", Data: make([]adapter.Embedding, 0, len(req.Input))}
+	for _, b := range batches {
+		if b.err != nil {
+			for idx := b.start; idx < b.end; idx++ {
+				merged.Failed = append(merged.Failed, EmbeddingFailure{Index: idx, Error: b.err.Error()})
+			}
+			continue
+		}
+		for _, e := range b.resp.Data {
+			e.Object = "embedding"
+			e.Index += b.start
+			merged.Data = append(merged.Data, e)
+		}
+		merged.Usage.PromptTokens += b.resp.Usage.PromptTokens
+		merged.Usage.TotalTokens += b.resp.Usage.TotalTokens
+	}
+	sort.Slice(merged.Data, func(i, j int) bool { return merged.Data[i].Index < merged.Data[j].Index })
+	return merged
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEmbedder 每个输入返回以其数值为内容的向量，数据倒序返回以验证重新排序
+type fakeEmbedder struct {
+	maxBatch int
+	mu       sync.Mutex
+	batches  [][]string
+}
+
+func (f *fakeEmbedder) MaxEmbeddingBatch() int { return f.maxBatch }
+
+func (f *fakeEmbedder) Embed(ctx context.Context, req *adapter.EmbeddingRequest) (*adapter.EmbeddingResponse, error) {
+	f.mu.Lock()
+	f.batches = append(f.batches, req.Input)
+	f.mu.Unlock()
+
+	resp := &adapter.EmbeddingResponse{Usage: adapter.UsageInfo{PromptTokens: 2 * len(req.Input), TotalTokens: 2 * len(req.Input)}}
+	for i := len(req.Input) - 1; i >= 0; i-- {
+		if req.Input[i] == "fail" {
+			return nil, fmt.Errorf("upstream rejected the batch")
+		}
+		n, _ := strconv.Atoi(req.Input[i])
+		resp.Data = append(resp.Data, adapter.Embedding{Index: i, Embedding: []float64{float64(n)}})
+	}
+	return resp, nil
+}
+
+func numberedInputs(n int) []string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = strconv.Itoa(i)
+	}
+	return inputs
+}
+
+func TestEmbedInBatches_SplitsAndReassemblesInOrder(t *testing.T) {
+	embedder := &fakeEmbedder{maxBatch: 3}
+	resp := embedInBatches(context.Background(), embedder, &adapter.EmbeddingRequest{Model: "text-embedding-3-small", Input: numberedInputs(8)})
+
+	if len(embedder.batches) != 3 {
+		t.Fatalf("Expected 8 inputs split into 3 batches, got %d", len(embedder.batches))
+	}
+	for _, batch := range embedder.batches {
+		if len(batch) > 3 {
+			t.Errorf("Expected batches within the provider limit, got %d inputs", len(batch))
+		}
+	}
+	if len(resp.Data) != 8 || len(resp.Failed) != 0 {
+		t.Fatalf("Expected 8 embeddings and no failures, got %d and %v", len(resp.Data), resp.Failed)
+	}
+	for i, e := range resp.Data {
+		if e.Index != i || e.Embedding[0] != float64(i) || e.Object != "embedding" {
+			t.Errorf("Expected embedding %d in input order, got index %d value %v", i, e.Index, e.Embedding)
+		}
+	}
+	if resp.Usage.PromptTokens != 16 || resp.Usage.TotalTokens != 16 {
+		t.Errorf("Expected usage summed across batches, got %+v", resp.Usage)
+	}
+}
+
+func TestEmbedInBatches_ReportsFailedIndices(t *testing.T) {
+	inputs := numberedInputs(6)
+	inputs[4] = "fail"
+	resp := embedInBatches(context.Background(), &fakeEmbedder{maxBatch: 2}, &adapter.EmbeddingRequest{Input: inputs})
+
+	if len(resp.Failed) != 2 || resp.Failed[0].Index != 4 || resp.Failed[1].Index != 5 {
+		t.Fatalf("Expected the batch holding inputs 4 and 5 reported as failed, got %+v", resp.Failed)
+	}
+	if len(resp.Data) != 4 || resp.Data[3].Index != 3 {
+		t.Errorf("Expected the other 4 embeddings returned, got %+v", resp.Data)
+	}
+	if resp.Usage.PromptTokens != 8 {
+		t.Errorf("Expected usage from successful batches only, got %d", resp.Usage.PromptTokens)
+	}
+}
+
+func TestEmbeddings_SplitsAboveOpenAIBatchLimit(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		sizes []int
+	)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req adapter.EmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		sizes = append(sizes, len(req.Input))
+		mu.Unlock()
+
+		resp := adapter.EmbeddingResponse{Model: req.Model, Usage: adapter.UsageInfo{PromptTokens: len(req.Input)}}
+		for i := range req.Input {
+			resp.Data = append(resp.Data, adapter.Embedding{Object: "embedding", Index: i, Embedding: []float64{0.5}})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer upstream.Close()
+
+	svc, logs := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	req := &ProxyRequest{UserID: 1, APIKeyID: 1, Model: "gpt-4", Endpoint: "/v1/embeddings"}
+	resp, err := svc.Embeddings(context.Background(), req, &adapter.EmbeddingRequest{Model: "gpt-4", Input: numberedInputs(2050)})
+	if err != nil {
+		t.Fatalf("Embeddings failed: %v", err)
+	}
+
+	if len(sizes) != 2 || sizes[0]+sizes[1] != 2050 || (sizes[0] != 2048 && sizes[1] != 2048) {
+		t.Errorf("Expected a 2048 + 2 split, got %v", sizes)
+	}
+	if len(resp.Data) != 2050 || resp.Data[2049].Index != 2049 || resp.Usage.PromptTokens != 2050 {
+		t.Errorf("Expected 2050 embeddings with summed usage, got %d and %+v", len(resp.Data), resp.Usage)
+	}
+	if len(logs.created) != 1 || logs.created[0].Path != "/v1/embeddings" || logs.created[0].TokensUsed != 2050 {
+		t.Errorf("Expected one embeddings request logged, got %+v", logs.created)
+	}
+	if q := svc.quotaService.(*openQuota); q.deducted == 0 {
+		t.Error("Expected the embeddings charged")
+	}
+}
+
+func TestEmbeddingInputs_RejectsNonStrings(t *testing.T) {
+	if inputs, err := embeddingInputs("hello"); err != nil || len(inputs) != 1 {
+		t.Errorf("Expected a single string accepted, got %v, %v", inputs, err)
+	}
+	for _, input := range []interface{}{[]interface{}{1.0, 2.0}, []interface{}{}, map[string]interface{}{}} {
+		if _, err := embeddingInputs(input); err == nil || !strings.HasPrefix(err.Error(), "input") {
+			t.Errorf("Expected %v rejected, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// writeValidationError 输出请求校验错误：API Key 配置了错误格式时使用该格式，否则按接口协议
+func writeValidationError(c *gin.Context, proto protocol.Protocol, verr *protocol.ValidationError) {
+	if apikey.WriteFormattedError(c, verr.APIError(proto)) {
+		return
+	}
+	c.JSON(http.StatusBadRequest, protocol.ValidationErrorBody(proto, verr))
+}
+
+// serviceAPIError 将代理服务错误转换为与协议无关的错误描述
+// AppError 的 HTTP 状态码取错误码的前三位（如 429001 → 429），无法识别的错误按 500 处理
+func serviceAPIError(err error) *protocol.APIError {
+	switch e := err.(type) {
+	case *CostCeilingError:
+		return &protocol.APIError{Status: http.StatusPaymentRequired, Message: e.Error()}
+	case *HistoryLimitError:
+		return &protocol.APIError{Status: http.StatusBadRequest, Message: e.Error()}
+	case *adapter.CapabilityError:
+		return &protocol.APIError{Status: http.StatusBadRequest, Message: e.Error()}
+	case *errors.AppError:
+		status := e.Code / 1000
+		if status < 400 || status > 599 {
+			status = http.StatusInternalServerError
+		}
+		message := e.Message
+		if e.Details != "" {
+			message += ": " + e.Details
+		}
+		return &protocol.APIError{Status: status, Message: message}
+	}
+	return &protocol.APIError{Status: http.StatusInternalServerError, Message: err.Error()}
+}
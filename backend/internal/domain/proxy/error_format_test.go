@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quotaExceededService 所有请求都返回配额不足
+type quotaExceededService struct {
+	Service
+}
+
+func (s *quotaExceededService) ChatCompletions(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	return nil, errors.ErrQuotaExceeded
+}
+
+func (s *quotaExceededService) Embeddings(ctx context.Context, req *ProxyRequest, embedReq *adapter.EmbeddingRequest) (*EmbeddingsResponse, error) {
+	return nil, errors.ErrQuotaExceeded
+}
+
+// errorFormatGateway 所有代理接口使用同一个配置了错误格式的 API Key
+func errorFormatGateway(t *testing.T, key *apikey.APIKey) *httptest.Server {
+	t.Helper()
+	h := NewHandler(&quotaExceededService{})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	v1 := engine.Group("/v1", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Set("api_key_info", key)
+		c.Next()
+	})
+	v1.POST("/chat/completions", h.ChatCompletionsOpenAI)
+	v1.POST("/messages", h.ChatCompletionsAnthropic)
+	v1.POST("/models/*action", h.ChatCompletionsGemini)
+	v1.POST("/embeddings", h.Embeddings)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+var errorFormatEndpoints = []struct {
+	path, body string
+}{
+	{"/v1/chat/completions", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`},
+	{"/v1/messages", `{"model":"claude-3","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`},
+	{"/v1/models/gemini-pro:generateContent", `{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`},
+	{"/v1/embeddings", `{"model":"text-embedding-3-small","input":"hi"}`},
+}
+
+func postError(t *testing.T, url, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestServiceError_RenderedInKeyFormatOnEveryEndpoint(t *testing.T) {
+	gateway := errorFormatGateway(t, &apikey.APIKey{ErrorFormat: apikey.ErrorFormatAnthropic})
+
+	want := `{"type":"error","error":{"type":"rate_limit_error","message":"Quota exceeded"}}`
+	for _, endpoint := range errorFormatEndpoints {
+		status, body := postError(t, gateway.URL+endpoint.path, endpoint.body)
+		if status != http.StatusTooManyRequests || body != want {
+			t.Errorf("%s: expected 429 %s, got %d %s", endpoint.path, want, status, body)
+		}
+	}
+}
+
+func TestServiceError_CustomTemplate(t *testing.T) {
+	gateway := errorFormatGateway(t, &apikey.APIKey{
+		ErrorFormat:   apikey.ErrorFormatCustom,
+		ErrorTemplate: `{"ok":false,"reason":"{{message}}","kind":"{{type}}","http":{{status}}}`,
+	})
+
+	for _, endpoint := range errorFormatEndpoints {
+		status, body := postError(t, gateway.URL+endpoint.path, endpoint.body)
+		var got map[string]interface{}
+		json.Unmarshal([]byte(body), &got)
+		if status != http.StatusTooManyRequests || got["reason"] != "Quota exceeded" || got["kind"] != "rate_limit_error" || got["http"] != float64(429) {
+			t.Errorf("%s: expected the custom envelope, got %d %s", endpoint.path, status, body)
+		}
+	}
+}
+
+func TestValidationError_RenderedInKeyFormat(t *testing.T) {
+	gateway := errorFormatGateway(t, &apikey.APIKey{ErrorFormat: apikey.ErrorFormatGemini})
+
+	status, body := postError(t, gateway.URL+"/v1/embeddings", `{"model":"m","input":[]}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, `"status":"INVALID_ARGUMENT"`) {
+		t.Errorf("Expected an OpenAI-only endpoint to use the key's Gemini format, got %d %s", status, body)
+	}
+}
+
+func TestServiceError_DefaultFormatWithoutKeySetting(t *testing.T) {
+	gateway := errorFormatGateway(t, &apikey.APIKey{})
+
+	_, body := postError(t, gateway.URL+"/v1/messages", errorFormatEndpoints[1].body)
+	if !strings.Contains(body, `"code":429001`) {
+		t.Errorf("Expected the default error envelope when the key has no format, got %s", body)
+	}
+}
@@ -0,0 +1,37 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/errors"
+	"strings"
+)
+
+// errNoFailoverConfig 故障转移时已没有未尝试过的配置
+var errNoFailoverConfig = errors.New(404002, "No remaining API configuration to fail over to")
+
+// maxFailoverRetries 上游故障时最多换用几个其他配置重试，0 表示不重试
+func (s *service) maxFailoverRetries() int {
+	if s.runtimeConfig == nil {
+		return 0
+	}
+	return s.runtimeConfig.Get().GetMaxRetries()
+}
+
+// errEmptyCompletion 上游返回 200 但没有任何内容，记录在被重试的那次请求日志中
+var errEmptyCompletion = errors.New(500004, "Upstream returned an empty completion")
+
+// isEmptyCompletion 判断响应是否没有工具调用且内容为空或只有空白，内容策略拒绝不算空响应
+func isEmptyCompletion(resp *adapter.ChatResponse) bool {
+	if resp == nil || len(resp.Choices) == 0 {
+		return true
+	}
+	if resp.IsRefusal() {
+		return false
+	}
+	for _, choice := range resp.Choices {
+		if len(choice.Message.ToolCalls) > 0 || strings.TrimSpace(adapter.GetContentAsString(choice.Message.Content)) != "" {
+			return false
+		}
+	}
+	return true
+}
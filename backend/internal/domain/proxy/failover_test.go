@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingUpstream 记录收到的完整请求体，按 status 返回
+type recordingUpstream struct {
+	status int
+	bodies [][]byte
+}
+
+func (u *recordingUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	u.bodies = append(u.bodies, body)
+	if u.status != http.StatusOK {
+		http.Error(w, `{"error":"upstream unavailable"}`, u.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(chatResponseJSON("from second config"))
+}
+
+func newFailoverTestService(maxRetries int, configs ...*apiconfig.APIConfig) (*service, *fakeLog) {
+	rc := runtime.NewManager(nil)
+	rc.Get().MaxRetries = maxRetries
+	l := &fakeLog{}
+	return &service{
+		adapterFactory:  adapter.NewFactory(),
+		apiConfigRepo:   &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"gpt-4": configs}},
+		loadBalancerSvc: noLBConfig{},
+		quotaService:    &openQuota{},
+		pricingService:  &fakePricing{cost: 42},
+		logService:      l,
+		runtimeConfig:   rc,
+		logger:          *logger.NewNop(),
+	}, l
+}
+
+func failoverConfig(id uint, url string, maxTools int) *apiconfig.APIConfig {
+	return &apiconfig.APIConfig{
+		ID: id, Type: "openai", ConfigType: apiconfig.ConfigTypeDirect, BaseURL: url, APIKey: "k",
+		LogRequests: true, MaxTools: maxTools, ToolLimitPolicy: ToolLimitTruncate,
+	}
+}
+
+func failoverRequest() *ProxyRequest {
+	return &ProxyRequest{UserID: 1, Model: "gpt-4", ChatRequest: &adapter.ChatRequest{
+		Model:    "gpt-4",
+		Messages: []adapter.Message{{Role: "system", Content: "be brief"}, {Role: "user", Content: "hello"}},
+		Tools: []adapter.Tool{
+			{Type: "function", Function: adapter.ToolFunction{Name: "first", Parameters: map[string]interface{}{"type": "object"}}},
+			{Type: "function", Function: adapter.ToolFunction{Name: "second", Parameters: map[string]interface{}{"type": "object"}}},
+		},
+	}}
+}
+
+func TestChatCompletions_FailoverSendsCompleteBody(t *testing.T) {
+	failing := &recordingUpstream{status: http.StatusServiceUnavailable}
+	healthy := &recordingUpstream{status: http.StatusOK}
+	s1, s2 := httptest.NewServer(failing), httptest.NewServer(healthy)
+	defer s1.Close()
+	defer s2.Close()
+
+	// 第一个配置只允许一个工具，它的裁剪不能带到第二个配置的请求中
+	svc, l := newFailoverTestService(1, failoverConfig(1, s1.URL, 1), failoverConfig(2, s2.URL, 0))
+	resp, err := svc.ChatCompletions(context.Background(), failoverRequest())
+	if err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+	if adapter.GetContentAsString(resp.Choices[0].Message.Content) != "from second config" {
+		t.Errorf("Expected response from the second config, got %+v", resp.Choices[0].Message)
+	}
+
+	if len(failing.bodies) != 1 || len(healthy.bodies) != 1 {
+		t.Fatalf("Expected one attempt per config, got %d and %d", len(failing.bodies), len(healthy.bodies))
+	}
+	var sent adapter.ChatRequest
+	if err := json.Unmarshal(healthy.bodies[0], &sent); err != nil {
+		t.Fatalf("Second config received an incomplete body %q: %v", healthy.bodies[0], err)
+	}
+	if len(sent.Messages) != 2 || len(sent.Tools) != 2 {
+		t.Errorf("Expected the original request re-sent in full, got %d messages and %d tools", len(sent.Messages), len(sent.Tools))
+	}
+
+	if len(l.created) != 2 || l.created[0].APIConfigID != 1 || l.created[0].ErrorMsg == "" || l.created[1].APIConfigID != 2 {
+		t.Errorf("Expected failed and successful attempts logged, got %+v", l.created)
+	}
+}
+
+func TestChatCompletions_FailoverLogsPerConfig(t *testing.T) {
+	failing := &recordingUpstream{status: http.StatusServiceUnavailable}
+	healthy := &recordingUpstream{status: http.StatusOK}
+	s1, s2 := httptest.NewServer(failing), httptest.NewServer(healthy)
+	defer s1.Close()
+	defer s2.Close()
+
+	// 第一个配置关闭了请求日志，故障转移到记录日志的配置后仍应写入日志
+	noLog := failoverConfig(1, s1.URL, 0)
+	noLog.LogRequests = false
+	svc, l := newFailoverTestService(1, noLog, failoverConfig(2, s2.URL, 0))
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("Expected failover to succeed, got %v", err)
+	}
+
+	if len(l.created) != 1 || l.created[0].APIConfigID != 2 {
+		t.Errorf("Expected only the attempt on the logging config written, got %+v", l.created)
+	}
+	if len(l.usage) != 1 || l.usage[0].APIConfigID != 1 {
+		t.Errorf("Expected the no-log attempt counted without a row, got %+v", l.usage)
+	}
+}
+
+func TestChatCompletions_NoFailoverOnClientError(t *testing.T) {
+	rejecting := &recordingUpstream{status: http.StatusBadRequest}
+	healthy := &recordingUpstream{status: http.StatusOK}
+	s1, s2 := httptest.NewServer(rejecting), httptest.NewServer(healthy)
+	defer s1.Close()
+	defer s2.Close()
+
+	svc, _ := newFailoverTestService(3, failoverConfig(1, s1.URL, 0), failoverConfig(2, s2.URL, 0))
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err == nil {
+		t.Fatal("Expected the client error to be returned")
+	}
+	if len(healthy.bodies) != 0 {
+		t.Error("Expected no failover for a request every upstream would reject")
+	}
+}
+
+func TestChatCompletions_FailoverDisabledWithoutRetries(t *testing.T) {
+	failing := &recordingUpstream{status: http.StatusBadGateway}
+	healthy := &recordingUpstream{status: http.StatusOK}
+	s1, s2 := httptest.NewServer(failing), httptest.NewServer(healthy)
+	defer s1.Close()
+	defer s2.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, s1.URL, 0), failoverConfig(2, s2.URL, 0))
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err == nil {
+		t.Fatal("Expected the upstream error with retries disabled")
+	}
+	if len(healthy.bodies) != 0 {
+		t.Error("Expected no failover with max_retries 0")
+	}
+}
+
+// sequencedUpstream 依次返回给定内容，用完后重复最后一个
+type sequencedUpstream struct {
+	contents []string
+	calls    int
+}
+
+func (u *sequencedUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	content := u.contents[len(u.contents)-1]
+	if u.calls < len(u.contents) {
+		content = u.contents[u.calls]
+	}
+	u.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(chatResponseJSON(content))
+}
+
+func TestChatCompletions_RetriesEmptyCompletionOnce(t *testing.T) {
+	upstream := &sequencedUpstream{contents: []string{"  \n", "finally"}}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	cfg := failoverConfig(1, srv.URL, 0)
+	cfg.RetryEmptyResponse = true
+	svc, l := newFailoverTestService(0, cfg)
+	q := svc.quotaService.(*openQuota)
+
+	resp, err := svc.ChatCompletions(context.Background(), failoverRequest())
+	if err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+	if got := adapter.GetContentAsString(resp.Choices[0].Message.Content); got != "finally" {
+		t.Errorf("Expected the non-empty retry result, got %q", got)
+	}
+	if upstream.calls != 2 {
+		t.Errorf("Expected exactly one retry, got %d upstream calls", upstream.calls)
+	}
+	if q.deducted != 42 || q.refundCalls != 0 {
+		t.Errorf("Expected a single charge of 42 and no refund, got %d charged and %d refunds", q.deducted, q.refundCalls)
+	}
+	if len(l.created) != 2 || l.created[0].ErrorMsg == "" || l.created[0].QuotaCost != 0 || l.created[1].QuotaCost != 42 {
+		t.Errorf("Expected an unbilled empty attempt followed by the billed one, got %+v", l.created)
+	}
+}
+
+func TestChatCompletions_EmptyCompletionRetriedAtMostOnce(t *testing.T) {
+	upstream := &sequencedUpstream{contents: []string{""}}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	cfg := failoverConfig(1, srv.URL, 0)
+	cfg.RetryEmptyResponse = true
+	svc, _ := newFailoverTestService(0, cfg)
+	q := svc.quotaService.(*openQuota)
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+	if upstream.calls != 2 {
+		t.Errorf("Expected the empty completion retried once, got %d upstream calls", upstream.calls)
+	}
+	// 重试仍为空时按原有逻辑扣费后自动退款
+	if q.refundCalls != 1 {
+		t.Errorf("Expected the final empty response refunded, got %d refunds", q.refundCalls)
+	}
+}
+
+func TestChatCompletions_EmptyCompletionNotRetriedByDefault(t *testing.T) {
+	upstream := &sequencedUpstream{contents: []string{"", "never sent"}}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, srv.URL, 0))
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+	if upstream.calls != 1 {
+		t.Errorf("Expected no retry without retry_empty_response, got %d upstream calls", upstream.calls)
+	}
+}
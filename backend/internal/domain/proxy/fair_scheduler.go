@@ -0,0 +1,266 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fairQueueTimeout 等待共享并发名额的最长时间，超时返回 503
+const fairQueueTimeout = 30 * time.Second
+
+// ModelConcurrency 单个模型在共享上游并发预算中的分配情况
+type ModelConcurrency struct {
+	Model     string  `json:"model"`
+	Weight    int     `json:"weight"`
+	InFlight  int     `json:"in_flight"`
+	Waiting   int     `json:"waiting"`
+	FairShare float64 `json:"fair_share"` // 按权重在当前活跃模型间分得的名额
+	Granted   int64   `json:"granted_total"`
+}
+
+// UpstreamConcurrency 共享同一上游（配置或账号池凭据）的并发预算及各模型的分配
+type UpstreamConcurrency struct {
+	Upstream string              `json:"upstream"` // config:<id> 或 credential:<id>
+	Limit    int                 `json:"limit"`
+	InFlight int                 `json:"in_flight"`
+	Waiting  int                 `json:"waiting"`
+	Models   []*ModelConcurrency `json:"models"`
+}
+
+// fairScheduler 按上游分配并发名额：名额空闲时直接放行，占满后按模型排队，
+// 名额释放时交给「在途数 / 权重」最小的模型（加权公平），避免单个热门模型占满共享上游
+type fairScheduler struct {
+	mu        sync.Mutex
+	upstreams map[string]*upstreamQueue
+	seq       uint64
+	timeout   time.Duration
+}
+
+type upstreamQueue struct {
+	limit    int
+	inFlight int
+	models   map[string]*modelShare
+}
+
+type modelShare struct {
+	weight   int
+	inFlight int
+	granted  int64
+	waiters  []*fairWaiter
+}
+
+type fairWaiter struct {
+	seq     uint64
+	ready   chan struct{}
+	granted bool
+}
+
+func newFairScheduler() *fairScheduler {
+	return &fairScheduler{upstreams: make(map[string]*upstreamQueue), timeout: fairQueueTimeout}
+}
+
+// acquire 为模型申请上游并发名额，返回的 release 须在上游调用结束后调用（可重复调用）
+// limit <= 0 表示不限制；等待超时或 ctx 取消时返回错误
+func (f *fairScheduler) acquire(ctx context.Context, upstream string, limit int, model string, weight int) (func(), error) {
+	if f == nil || limit <= 0 {
+		return func() {}, nil
+	}
+	if weight < 1 {
+		weight = 1
+	}
+
+	f.mu.Lock()
+	q, ok := f.upstreams[upstream]
+	if !ok {
+		q = &upstreamQueue{models: make(map[string]*modelShare)}
+		f.upstreams[upstream] = q
+	}
+	q.limit = limit
+	share, ok := q.models[model]
+	if !ok {
+		share = &modelShare{}
+		q.models[model] = share
+	}
+	share.weight = weight
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			q.inFlight--
+			share.inFlight--
+			q.dispatch()
+		})
+	}
+
+	if q.inFlight < q.limit && !q.hasWaiters() {
+		q.grant(share)
+		f.mu.Unlock()
+		return release, nil
+	}
+	f.seq++
+	w := &fairWaiter{seq: f.seq, ready: make(chan struct{})}
+	share.waiters = append(share.waiters, w)
+	f.mu.Unlock()
+
+	timer := time.NewTimer(f.timeout)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return release, nil
+	case <-ctx.Done():
+		f.abandon(share, w, release)
+		return nil, ctx.Err()
+	case <-timer.C:
+		f.abandon(share, w, release)
+		return nil, errors.ErrServiceUnavailable.WithDetails("Upstream concurrency limit reached, please retry shortly")
+	}
+}
+
+// abandon 放弃排队；已在放弃前分得名额时归还
+func (f *fairScheduler) abandon(share *modelShare, w *fairWaiter, release func()) {
+	f.mu.Lock()
+	if w.granted {
+		f.mu.Unlock()
+		release()
+		return
+	}
+	for i, waiter := range share.waiters {
+		if waiter == w {
+			share.waiters = append(share.waiters[:i], share.waiters[i+1:]...)
+			break
+		}
+	}
+	f.mu.Unlock()
+}
+
+func (q *upstreamQueue) grant(share *modelShare) {
+	q.inFlight++
+	share.inFlight++
+	share.granted++
+}
+
+func (q *upstreamQueue) hasWaiters() bool {
+	for _, share := range q.models {
+		if len(share.waiters) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 把空闲名额依次交给在途数相对权重最少的排队模型，同等时先到先得
+func (q *upstreamQueue) dispatch() {
+	for q.inFlight < q.limit {
+		var next *modelShare
+		for _, share := range q.models {
+			if len(share.waiters) == 0 {
+				continue
+			}
+			if next == nil {
+				next = share
+				continue
+			}
+			// 比较 inFlight/weight，交叉相乘避免浮点
+			lhs, rhs := share.inFlight*next.weight, next.inFlight*share.weight
+			if lhs < rhs || (lhs == rhs && share.waiters[0].seq < next.waiters[0].seq) {
+				next = share
+			}
+		}
+		if next == nil {
+			return
+		}
+		w := next.waiters[0]
+		next.waiters = next.waiters[1:]
+		w.granted = true
+		q.grant(next)
+		close(w.ready)
+	}
+}
+
+// stats 返回各上游的并发分配快照，按上游名排序
+func (f *fairScheduler) stats() []*UpstreamConcurrency {
+	result := []*UpstreamConcurrency{}
+	if f == nil {
+		return result
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, q := range f.upstreams {
+		entry := &UpstreamConcurrency{Upstream: name, Limit: q.limit, InFlight: q.inFlight, Models: []*ModelConcurrency{}}
+		activeWeight := 0
+		for _, share := range q.models {
+			entry.Waiting += len(share.waiters)
+			if share.inFlight > 0 || len(share.waiters) > 0 {
+				activeWeight += share.weight
+			}
+		}
+		for model, share := range q.models {
+			mc := &ModelConcurrency{
+				Model:    model,
+				Weight:   share.weight,
+				InFlight: share.inFlight,
+				Waiting:  len(share.waiters),
+				Granted:  share.granted,
+			}
+			if activeWeight > 0 && (share.inFlight > 0 || len(share.waiters) > 0) {
+				mc.FairShare = float64(q.limit) * float64(share.weight) / float64(activeWeight)
+			}
+			entry.Models = append(entry.Models, mc)
+		}
+		sort.Slice(entry.Models, func(i, j int) bool { return entry.Models[i].Model < entry.Models[j].Model })
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Upstream < result[j].Upstream })
+	return result
+}
+
+// upstreamConcurrencyKey 并发预算的归属：账号池按凭据（每个凭据是独立的上游账号），直连按配置
+func upstreamConcurrencyKey(apiConfig *apiconfig.APIConfig, credentialID uint) string {
+	if credentialID > 0 {
+		return fmt.Sprintf("credential:%d", credentialID)
+	}
+	return fmt.Sprintf("config:%d", apiConfig.ID)
+}
+
+// acquireUpstreamSlot 按配置的并发预算为请求模型申请上游名额，配置未设置上限时直接放行
+func (s *service) acquireUpstreamSlot(ctx context.Context, apiConfig *apiconfig.APIConfig, credentialID uint, model string) (func(), error) {
+	if apiConfig.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	upstream := upstreamConcurrencyKey(apiConfig, credentialID)
+	release, err := s.scheduler.acquire(ctx, upstream, apiConfig.MaxConcurrency, model, apiConfig.ConcurrencyWeight(model))
+	if err != nil {
+		s.log(ctx).Warn("✗ Upstream concurrency slot not acquired",
+			logger.String("upstream", upstream),
+			logger.Int("limit", apiConfig.MaxConcurrency),
+			logger.Error(err))
+		return nil, err
+	}
+	return release, nil
+}
+
+// releaseOnClose 流式响应体关闭时归还上游并发名额
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
+
+// ConcurrencyAllocations 返回各共享上游的并发预算及按模型的分配
+func (s *service) ConcurrencyAllocations() []*UpstreamConcurrency {
+	return s.scheduler.stats()
+}
@@ -0,0 +1,160 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForWaiting 等待上游排队数达到 n
+func waitForWaiting(t *testing.T, f *fairScheduler, upstream string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, u := range f.stats() {
+			if u.Upstream == upstream && u.Waiting == n {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d waiting requests on %s", n, upstream)
+}
+
+func TestFairScheduler_FreedSlotGoesToStarvedModel(t *testing.T) {
+	f := newFairScheduler()
+	ctx := context.Background()
+
+	// 热门模型占满全部名额后继续排队，冷门模型随后排队
+	var held []func()
+	for i := 0; i < 2; i++ {
+		release, err := f.acquire(ctx, "config:1", 2, "hot", 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		held = append(held, release)
+	}
+	granted := make(chan string, 2)
+	for i, model := range []string{"hot", "cold"} {
+		go func(model string) {
+			if _, err := f.acquire(ctx, "config:1", 2, model, 1); err == nil {
+				granted <- model
+			}
+		}(model)
+		waitForWaiting(t, f, "config:1", i+1)
+	}
+
+	held[0]()
+	if got := <-granted; got != "cold" {
+		t.Errorf("Expected the freed slot to go to the model with no requests in flight, got %s", got)
+	}
+	held[1]()
+	if got := <-granted; got != "hot" {
+		t.Errorf("Expected the next slot to go to the remaining waiter, got %s", got)
+	}
+}
+
+func TestFairScheduler_WeightedShareUnderContention(t *testing.T) {
+	f := newFairScheduler()
+	ctx := context.Background()
+	const limit = 4
+
+	// 两个模型都有远超名额的并发请求，权重 3:1，名额应大致按 3:1 分配
+	var (
+		wg      sync.WaitGroup
+		samples = map[string]int{}
+		stop    = make(chan struct{})
+	)
+	worker := func(model string, weight int) {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			release, err := f.acquire(ctx, "credential:7", limit, model, weight)
+			if err != nil {
+				return
+			}
+			time.Sleep(2 * time.Millisecond)
+			release()
+		}
+	}
+	for i := 0; i < 12; i++ {
+		wg.Add(2)
+		go worker("heavy", 3)
+		go worker("light", 1)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 50; i++ {
+		for _, u := range f.stats() {
+			for _, m := range u.Models {
+				samples[m.Model] += m.InFlight
+			}
+		}
+		time.Sleep(3 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	ratio := float64(samples["heavy"]) / float64(samples["light"])
+	if ratio < 2 || ratio > 4.5 {
+		t.Errorf("Expected in-flight requests split roughly 3:1, got heavy=%d light=%d", samples["heavy"], samples["light"])
+	}
+
+	u := f.stats()[0]
+	if u.InFlight != 0 || u.Waiting != 0 {
+		t.Errorf("Expected all slots released, got in_flight=%d waiting=%d", u.InFlight, u.Waiting)
+	}
+}
+
+func TestFairScheduler_WaitTimesOutAndCancels(t *testing.T) {
+	f := newFairScheduler()
+	f.timeout = 20 * time.Millisecond
+	release, _ := f.acquire(context.Background(), "config:1", 1, "gpt-4", 1)
+
+	if _, err := f.acquire(context.Background(), "config:1", 1, "gpt-4", 1); err == nil {
+		t.Error("Expected an error when no slot frees up in time")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.acquire(ctx, "config:1", 1, "gpt-4", 1); err != context.Canceled {
+		t.Errorf("Expected context cancellation, got %v", err)
+	}
+	if waiting := f.stats()[0].Waiting; waiting != 0 {
+		t.Errorf("Expected abandoned waiters removed from the queue, got %d", waiting)
+	}
+
+	release()
+	if _, err := f.acquire(context.Background(), "config:1", 1, "gpt-4", 1); err != nil {
+		t.Errorf("Expected the released slot to be reusable, got %v", err)
+	}
+}
+
+func TestChatCompletions_ConcurrencyBudgetTracksModel(t *testing.T) {
+	server := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer server.Close()
+	cfg := failoverConfig(1, server.URL, 0)
+	cfg.MaxConcurrency = 2
+	svc, _ := newFailoverTestService(0, cfg)
+	svc.scheduler = newFairScheduler()
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	allocations := svc.ConcurrencyAllocations()
+	if len(allocations) != 1 || allocations[0].Upstream != "config:1" || allocations[0].Limit != 2 {
+		t.Fatalf("Expected the config's concurrency budget, got %+v", allocations)
+	}
+	models := allocations[0].Models
+	if len(models) != 1 || models[0].Model != "gpt-4" || models[0].Granted != 1 || models[0].InFlight != 0 {
+		t.Errorf("Expected one completed gpt-4 request, got %+v", models)
+	}
+}
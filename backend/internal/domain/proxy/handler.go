@@ -1,11 +1,21 @@
 package proxy
 
 import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
 	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/redact"
 	"api-aggregator/backend/pkg/response"
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +25,9 @@ import (
 type Handler struct {
 	service          Service
 	converterFactory *protocol.ConverterFactory
+	preferences      PreferenceSource
+	deprecations     DeprecationSource
+	blocklist        PromptBlockSource
 }
 
 // NewHandler 创建代理处理器
@@ -60,6 +73,21 @@ func (h *Handler) ChatCompletionsAnthropic(c *gin.Context) {
 	h.handleRequest(c, protocol.ProtocolAnthropic, "")
 }
 
+// Responses OpenAI Responses API 格式的请求
+// @Summary Responses API
+// @Description 处理 OpenAI Responses API 格式的请求（instructions/input）
+// @Tags Proxy
+// @Accept json
+// @Produce json
+// @Param request body protocol.ResponsesRequest true "Responses 请求"
+// @Success 200 {object} protocol.ResponsesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /v1/responses [post]
+func (h *Handler) Responses(c *gin.Context) {
+	h.handleRequest(c, protocol.ProtocolResponses, "")
+}
+
 // ChatCompletionsGemini Gemini 格式的聊天补全
 // @Summary Gemini 聊天补全
 // @Description 处理 Gemini 格式的聊天补全请求
@@ -101,6 +129,9 @@ func (h *Handler) handleRequest(c *gin.Context, proto protocol.Protocol, model s
 		return
 	}
 
+	// 1.1. 客户端请求头随上下文传给适配器，按配置的黑白名单转发给上游
+	c.Request = c.Request.WithContext(adapter.WithInboundHeaders(c.Request.Context(), c.Request.Header))
+
 	// 2. 读取原始请求体
 	rawBody, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -109,9 +140,16 @@ func (h *Handler) handleRequest(c *gin.Context, proto protocol.Protocol, model s
 	}
 
 	// 3. 使用转换器解析请求
+	// 请求体已由 RequestSchema 中间件校验，这里的解析错误同样按协议格式返回
 	chatReq, err := converter.ParseRequest(rawBody, model)
 	if err != nil {
-		response.Error(c, http.StatusBadRequest, 400001, "Failed to parse request", err)
+		writeValidationError(c, proto, &protocol.ValidationError{Message: err.Error()})
+		return
+	}
+
+	// 3.1. 工具结果必须对应之前助手消息中的工具调用
+	if pairErr := adapter.ValidateToolPairing(chatReq.Messages); pairErr != nil {
+		writeValidationError(c, proto, &protocol.ValidationError{Message: pairErr.Reason})
 		return
 	}
 
@@ -135,6 +173,58 @@ func (h *Handler) handleRequest(c *gin.Context, proto protocol.Protocol, model s
 		Model:       chatReq.Model,
 		Stream:      chatReq.Stream,
 		ChatRequest: chatReq,
+		RequestID:   c.GetString("request_id"),
+	}
+
+	// 5.1. 成本上限参数（max_cost + 候选模型列表）
+	if params := parseCostCeilingParams(rawBody); params.MaxCost != nil {
+		proxyReq.MaxCost = params.MaxCost
+		proxyReq.Models = params.Models
+	}
+
+	// 5.2. 请求级供应商偏好，无效值忽略
+	if header := c.GetHeader(ProviderPreferenceHeader); header != "" {
+		proxyReq.ProviderPreference = parseProviderPreference(header)
+	}
+	proxyReq.HoldID = parseQuotaHold(c)
+
+	// 5.25. 自动续写因 max_tokens 截断的响应、多候选采样，目前只支持非流式请求
+	candidates := 0
+	if !chatReq.Stream {
+		proxyReq.MaxContinuations = parseAutoContinueParams(rawBody)
+		candidates = parseCandidateCount(rawBody)
+	} else if parseAutoContinueParams(rawBody) > 0 {
+		writeValidationError(c, proto, &protocol.ValidationError{Path: []interface{}{"auto_continue"}, Message: "is not supported for streaming requests"})
+		return
+	}
+
+	// 5.3. 请求未指定的模型、供应商偏好和参数使用用户偏好
+	if !h.applyUserPreferences(c, proto, proxyReq) {
+		return
+	}
+
+	// 5.5. 按 API Key 配置启用响应脱敏、关闭请求日志
+	if err := applyAPIKeySettings(c, proxyReq); err != nil {
+		response.Error(c, http.StatusBadRequest, 400001, "Invalid redaction pattern on API key", err)
+		return
+	}
+	proxyReq.RoutingMode = resolveRoutingMode(c.GetHeader(RequestPriorityHeader), proxyReq.RoutingMode)
+	proxyReq.NoSemanticCache = resolveSemanticCacheDisabled(c.GetHeader(SemanticCacheHeader), proxyReq.NoSemanticCache)
+	proxyReq.RoutingDiagnostics = parseRoutingDiagnostics(c.GetHeader(RoutingDiagnosticsHeader))
+	if proxyReq.Candidates, err = resolveCandidates(candidates, proxyReq.MaxCandidates); err != nil {
+		response.BadRequest(c, "Multiple candidates not allowed", err.Error())
+		return
+	}
+
+	// 5.55. 命中提示词屏蔽规则的请求直接拒绝
+	if h.checkPromptBlock(c, proxyReq) {
+		return
+	}
+
+	// 5.6. 请求弃用模型时返回弃用提示，请求照常处理
+	var warnings []*ResponseWarning
+	if warning := h.checkDeprecation(c, proxyReq.Model); warning != nil {
+		warnings = append(warnings, warning)
 	}
 
 	// 6. 处理流式请求
@@ -145,8 +235,9 @@ func (h *Handler) handleRequest(c *gin.Context, proto protocol.Protocol, model s
 
 	// 7. 处理非流式请求
 	resp, err := h.service.ChatCompletions(c.Request.Context(), proxyReq)
+	setUpstreamRequestIDHeader(c, proxyReq)
 	if err != nil {
-		response.ErrorFromError(c, err)
+		writeServiceError(c, err)
 		return
 	}
 
@@ -157,16 +248,334 @@ func (h *Handler) handleRequest(c *gin.Context, proto protocol.Protocol, model s
 		return
 	}
 
-	// 9. 返回响应 - 所有协议都直接返回原始格式，不使用包装器
-	c.JSON(http.StatusOK, formattedResp)
+	// 9. 返回响应 - 所有协议都直接返回原始格式，不使用包装器；内容策略拒绝在各协议中统一以 prism_refusal 描述
+	formatted := withWarnings(formattedResp, warnings...)
+	if resp.Refusal != nil {
+		formatted = withExtension(formatted, "prism_refusal", resp.Refusal)
+	}
+	if proxyReq.Routing != nil {
+		formatted = withExtension(formatted, "prism_routing", proxyReq.Routing)
+	}
+	c.JSON(http.StatusOK, withCandidates(formatted, converter, proxyReq.CandidateResults))
+}
+
+// ListActiveStreams 获取当前活跃的流式请求
+// @Summary 活跃流列表
+// @Description 返回正在进行的流式请求（模型、用户、配置、时长、已下发字节数）
+// @Tags Streams
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]ActiveStream}
+// @Failure 401 {object} response.ErrorResponse
+// @Router /api/admin/streams/active [get]
+func (h *Handler) ListActiveStreams(c *gin.Context) {
+	response.Success(c, h.service.ActiveStreams())
+}
+
+// ListConcurrency 获取共享上游的并发分配
+// @Summary 上游并发分配
+// @Description 返回设置了并发上限的上游（配置或账号池凭据）的在途数、排队数及各模型的公平分配
+// @Tags APIConfig
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=[]UpstreamConcurrency}
+// @Failure 401 {object} response.ErrorResponse
+// @Router /api/admin/api-configs/concurrency [get]
+func (h *Handler) ListConcurrency(c *gin.Context) {
+	response.Success(c, h.service.ConcurrencyAllocations())
+}
+
+// TerminateStream 强制结束指定的流式请求
+// @Summary 终止活跃流
+// @Description 关闭上游流，已下发内容按估算计费，客户端收到终止事件
+// @Tags Streams
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "流ID"
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/admin/streams/{id} [delete]
+func (h *Handler) TerminateStream(c *gin.Context) {
+	if err := h.service.TerminateStream(c.Param("id")); err != nil {
+		response.ErrorFromError(c, err)
+		return
+	}
+	response.SuccessWithMessage(c, "Stream terminated", nil)
+}
+
+// CancelStream 取消自己的流式请求
+// @Summary 取消流式请求
+// @Description 按响应头 X-Prism-Stream-Id 返回的流 ID 中止上游请求，流以取消事件结束，已下发内容按估算计费
+// @Tags Proxy
+// @Produce json
+// @Param stream_id path string true "流ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} response.ErrorResponse
+// @Router /v1/cancel/{stream_id} [post]
+func (h *Handler) CancelStream(c *gin.Context) {
+	id := c.Param("stream_id")
+	if err := h.service.CancelStream(c.GetUint("user_id"), id); err != nil {
+		response.NotFound(c, "Active stream not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "stream.cancel", "cancelled": true})
+}
+
+// PricingPreview 预览配置的计费
+// @Summary 定价预览
+// @Description 按示例 token 数计算配置在指定模型上的扣费积分，返回使用的定价记录和 service_tier 倍率；缺少定价时 pricing_found 为 false
+// @Tags API Configs
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "配置ID"
+// @Param model query string true "模型名称"
+// @Param input_tokens query int false "输入 token 数"
+// @Param output_tokens query int false "输出 token 数"
+// @Param service_tier query string false "服务层级"
+// @Success 200 {object} response.Response{data=PricingPreview}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/admin/api-configs/{id}/pricing-preview [get]
+func (h *Handler) PricingPreview(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid config ID")
+		return
+	}
+	var req PricingPreviewRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	preview, err := h.service.PreviewPricing(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		if errors.Is(err, errors.ErrAPIConfigNotFound) {
+			response.NotFound(c, "API config not found")
+			return
+		}
+		response.ErrorFromError(c, err)
+		return
+	}
+	response.Success(c, preview)
+}
+
+// CreateMessageBatch 创建 Anthropic 消息批次
+// @Summary 创建消息批次
+// @Description 提交多条 Messages 请求在后台处理，返回批次对象供轮询；每条请求完成后单独计费
+// @Tags Proxy
+// @Accept json
+// @Produce json
+// @Param request body MessageBatchRequest true "批次请求"
+// @Success 200 {object} MessageBatch
+// @Failure 400 {object} response.ErrorResponse
+// @Router /v1/messages/batches [post]
+func (h *Handler) CreateMessageBatch(c *gin.Context) {
+	var req MessageBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBatchValidationError(c, err.Error())
+		return
+	}
+	if len(req.Requests) == 0 || len(req.Requests) > maxBatchRequests {
+		writeBatchValidationError(c, fmt.Sprintf("requests: must contain between 1 and %d items", maxBatchRequests))
+		return
+	}
+
+	userID, apiKeyID := c.GetUint("user_id"), c.GetUint("api_key_id")
+	if userID == 0 || apiKeyID == 0 {
+		response.Error(c, http.StatusUnauthorized, 401001, "User ID not found in context", nil)
+		return
+	}
+
+	prefs, overrides := h.loadUserPreferences(c, userID)
+
+	converter := h.converterFactory.GetConverter(protocol.ProtocolAnthropic)
+	seen := make(map[string]bool, len(req.Requests))
+	items := make([]*BatchItem, 0, len(req.Requests))
+	for i, entry := range req.Requests {
+		if entry.CustomID == "" || seen[entry.CustomID] {
+			writeBatchValidationError(c, fmt.Sprintf("requests.%d.custom_id: must be present and unique within the batch", i))
+			return
+		}
+		seen[entry.CustomID] = true
+
+		chatReq, err := converter.ParseRequest(entry.Params, "")
+		if err != nil {
+			writeBatchValidationError(c, fmt.Sprintf("requests.%d.params: %s", i, err.Error()))
+			return
+		}
+		if chatReq.Stream {
+			writeBatchValidationError(c, fmt.Sprintf("requests.%d.params.stream: streaming is not supported in batches", i))
+			return
+		}
+		if pairErr := adapter.ValidateToolPairing(chatReq.Messages); pairErr != nil {
+			writeBatchValidationError(c, fmt.Sprintf("requests.%d.params: %s", i, pairErr.Reason))
+			return
+		}
+
+		proxyReq := &ProxyRequest{UserID: userID, APIKeyID: apiKeyID, Model: chatReq.Model, ChatRequest: chatReq}
+		mergeUserPreferences(proxyReq, prefs, overrides)
+		if proxyReq.Model == "" {
+			writeBatchValidationError(c, fmt.Sprintf("requests.%d.params.model: field is required", i))
+			return
+		}
+		if err := applyAPIKeySettings(c, proxyReq); err != nil {
+			response.Error(c, http.StatusBadRequest, 400001, "Invalid redaction pattern on API key", err)
+			return
+		}
+		proxyReq.NoSemanticCache = resolveSemanticCacheDisabled(c.GetHeader(SemanticCacheHeader), proxyReq.NoSemanticCache)
+		if h.checkPromptBlock(c, proxyReq) {
+			return
+		}
+		items = append(items, &BatchItem{CustomID: entry.CustomID, Request: proxyReq})
+	}
+
+	// 批次中的每条请求按提交批次的 API Key 单独计入速率限制
+	var key *apikey.APIKey
+	if info, ok := c.Get("api_key_info"); ok {
+		key, _ = info.(*apikey.APIKey)
+	}
+	batch, err := h.service.CreateMessageBatch(userID, key, items)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetMessageBatch 查询消息批次状态
+// @Summary 查询消息批次
+// @Description 返回批次处理状态（in_progress / ended）和各状态请求数
+// @Tags Proxy
+// @Produce json
+// @Param id path string true "批次ID"
+// @Success 200 {object} MessageBatch
+// @Failure 404 {object} response.ErrorResponse
+// @Router /v1/messages/batches/{id} [get]
+func (h *Handler) GetMessageBatch(c *gin.Context) {
+	batch, err := h.service.GetMessageBatch(c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetMessageBatchResults 获取已结束批次的结果
+// @Summary 消息批次结果
+// @Description 以 JSONL 返回每条请求的结果，每行包含 custom_id 和 succeeded/errored 结果
+// @Tags Proxy
+// @Produce plain
+// @Param id path string true "批次ID"
+// @Success 200 {string} string "JSONL"
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /v1/messages/batches/{id}/results [get]
+func (h *Handler) GetMessageBatchResults(c *gin.Context) {
+	results, err := h.service.MessageBatchResults(c.GetUint("user_id"), c.Param("id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	converter := h.converterFactory.GetConverter(protocol.ProtocolAnthropic)
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for _, result := range results {
+		line := messageBatchResultLine{CustomID: result.CustomID, Result: messageBatchResult{Type: "succeeded"}}
+		itemErr := result.Err
+		if itemErr == nil {
+			line.Result.Message, itemErr = converter.FormatResponse(result.Response)
+		}
+		if itemErr != nil {
+			line.Result = messageBatchResult{Type: "errored", Error: &messageBatchItemError{Type: "error"}}
+			line.Result.Error.Error.Type = "api_error"
+			line.Result.Error.Error.Message = itemErr.Error()
+		}
+		encoder.Encode(line)
+	}
+}
+
+// writeBatchValidationError 以 Anthropic 错误格式返回批次请求校验失败
+func writeBatchValidationError(c *gin.Context, message string) {
+	writeValidationError(c, protocol.ProtocolAnthropic, &protocol.ValidationError{Message: message})
+}
+
+// applyAPIKeySettings 按 API Key 配置启用响应脱敏、关闭请求日志、合并元数据和长度路由，并应用参数覆盖
+// 返回的错误只来自无效的脱敏规则
+func applyAPIKeySettings(c *gin.Context, proxyReq *ProxyRequest) error {
+	info, ok := c.Get("api_key_info")
+	if !ok {
+		return nil
+	}
+	key, ok := info.(*apikey.APIKey)
+	if !ok {
+		return nil
+	}
+	if key.RedactionEnabled {
+		redactor, err := redact.New(key.RedactionPatterns)
+		if err != nil {
+			return err
+		}
+		proxyReq.Redactor = redactor
+	}
+	proxyReq.NoLog = !key.LogRequests
+	adapter.MergeMetadata(proxyReq.ChatRequest, key.Metadata)
+	proxyReq.LengthRoutes = key.LengthRouting
+	proxyReq.PacingTPS = key.StreamPacingTPS
+	proxyReq.RoutingMode = key.RoutingMode
+	proxyReq.NoSemanticCache = key.SemanticCacheDisabled
+	proxyReq.MaxCandidates = key.MaxCandidates
+	proxyReq.MaxHistory, proxyReq.HistoryPolicy = key.MaxHistoryMessages, key.HistoryLimitPolicy
+	proxyReq.MaxContextTokens, proxyReq.ContextTruncation = key.MaxContextTokens, key.ContextTruncation
+	applyParamOverrides(proxyReq.ChatRequest, key.ParamOverrides)
+	return nil
+}
+
+// writeServiceError 输出代理服务错误，成本上限不满足时返回 402，对话历史超出上限时返回 400
+// API Key 配置了错误格式时统一按该格式输出
+func writeServiceError(c *gin.Context, err error) {
+	if apikey.WriteFormattedError(c, serviceAPIError(err)) {
+		_ = c.Error(err)
+		return
+	}
+	if ceilingErr, ok := err.(*CostCeilingError); ok {
+		writeCostCeilingError(c, ceilingErr)
+		return
+	}
+	if historyErr, ok := err.(*HistoryLimitError); ok {
+		response.BadRequest(c, "Conversation history too long", historyErr.Error())
+		return
+	}
+	if capErr, ok := err.(*adapter.CapabilityError); ok {
+		response.BadRequest(c, "Request not supported by target provider", capErr.Error())
+		return
+	}
+	if errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		response.Conflict(c, "Quota hold not found, expired or already used")
+		return
+	}
+	if errors.Is(err, errors.ErrServiceUnavailable) {
+		response.Error(c, http.StatusServiceUnavailable, errors.ErrServiceUnavailable.Code, errors.ErrServiceUnavailable.Message, err)
+		return
+	}
+	response.ErrorFromError(c, err)
 }
 
 // handleStream 处理流式请求
 func (h *Handler) handleStream(c *gin.Context, req *ProxyRequest, converter protocol.Converter) {
+	// 上游请求使用可取消的 context，客户端可通过 /v1/cancel/:stream_id 中止
+	upstreamCtx, cancelUpstream := context.WithCancel(c.Request.Context())
+	defer cancelUpstream()
+
 	// 调用服务
-	streamResp, err := h.service.ChatCompletionsStream(c.Request.Context(), req)
+	streamResp, err := h.service.ChatCompletionsStream(upstreamCtx, req)
+	// 响应头必须在流开始写出之前设置
+	setUpstreamRequestIDHeader(c, req)
 	if err != nil {
-		response.ErrorFromError(c, err)
+		writeServiceError(c, err)
 		return
 	}
 
@@ -196,10 +605,30 @@ func (h *Handler) handleStream(c *gin.Context, req *ProxyRequest, converter prot
 		streamResp.CredentialID,
 		converter.GetProtocol(),
 	)
+	wrappedReader.SetIdleTimeout(streamResp.IdleTimeout)
+	wrappedReader.SetMaxDuration(streamResp.MaxDuration)
+	wrappedReader.SetTrace(streamResp.trace)
 	defer wrappedReader.Close()
 
-	// 根据协议设置不同的响应头
+	// 登记为活跃流，供管理员查看和强制结束、客户端按流 ID 取消
 	proto := converter.GetProtocol()
+	tracked := svc.streams.register(ActiveStream{
+		RequestID:   c.GetString("request_id"),
+		UserID:      req.UserID,
+		APIKeyID:    req.APIKeyID,
+		APIConfigID: streamResp.APIConfigID,
+		Model:       req.Model,
+		Protocol:    string(proto),
+	}, wrappedReader.Terminate, func() {
+		wrappedReader.Cancel()
+		cancelUpstream()
+	})
+	defer svc.streams.deregister(tracked)
+	if tracked != nil {
+		c.Header(StreamIDHeader, tracked.info.ID)
+	}
+
+	// 根据协议设置不同的响应头
 	if proto == protocol.ProtocolGemini {
 		// Gemini 使用普通 JSON 流，不是 SSE
 		c.Header("Content-Type", "application/json")
@@ -211,10 +640,44 @@ func (h *Handler) handleStream(c *gin.Context, req *ProxyRequest, converter prot
 	c.Header("Connection", "keep-alive")
 	c.Header("Transfer-Encoding", "chunked")
 
+	// 启用脱敏时逐行处理 OpenAI 格式数据块
+	var redactor *sseRedactor
+	if req.Redactor != nil {
+		redactor = newSSERedactor(req.Redactor)
+		defer func() {
+			if n := redactor.Count(); n > 0 {
+				svc.log(c.Request.Context()).Info("Response content redacted",
+					logger.Uint("api_key_id", req.APIKeyID),
+					logger.Int("redactions", n),
+					logger.Bool("stream", true))
+			}
+		}()
+	}
+
+	// 需要跨数据块保持状态的协议使用独立的流会话
+	formatChunk := converter.FormatStreamChunk
+	if provider, ok := converter.(protocol.StreamSessionProvider); ok {
+		formatChunk = provider.NewStreamSession().FormatStreamChunk
+	}
+
+	// 启用合规归档时记录脱敏后转发的数据，流结束后归档
+	var archived *bytes.Buffer
+	if svc.archiver != nil {
+		archived = &bytes.Buffer{}
+		defer func() { svc.archiveStream(req, streamResp.APIConfigID, archived.Bytes()) }()
+	}
+
+	// 配置了输出速率时按速率转发，上游仍按原速读取
+	upstream := newPacedReader(c.Request.Context(), wrappedReader, req.PacingTPS)
+	if paced, ok := upstream.(*pacedReader); ok {
+		// 先于 wrappedReader.Close 执行：关闭上游并等待后台读取退出，避免与流结算并发解析
+		defer paced.stop(func() { streamResp.Response.Body.Close() })
+	}
+
 	// 复制响应流
 	c.Stream(func(w io.Writer) bool {
 		// 使用 bufio.Reader 逐行读取
-		reader := bufio.NewReader(wrappedReader)
+		reader := bufio.NewReader(upstream)
 		for {
 			line, err := reader.ReadBytes('\n')
 			if err != nil {
@@ -227,21 +690,33 @@ func (h *Handler) handleStream(c *gin.Context, req *ProxyRequest, converter prot
 				return false
 			}
 
-			// 使用转换器格式化流式数据块
-			formattedChunk, err := converter.FormatStreamChunk(line)
-			if err != nil {
-				// 格式化失败，跳过这个块
-				continue
+			lines := [][]byte{line}
+			if redactor != nil {
+				lines = redactor.Process(line)
 			}
 
-			// 跳过空块
-			if len(formattedChunk) == 0 {
-				continue
-			}
+			for _, l := range lines {
+				if archived != nil {
+					archived.Write(l)
+				}
 
-			// 写入响应
-			if _, err := w.Write(formattedChunk); err != nil {
-				return false
+				// 使用转换器格式化流式数据块
+				formattedChunk, err := formatChunk(l)
+				if err != nil {
+					// 格式化失败，跳过这个块
+					continue
+				}
+
+				// 跳过空块
+				if len(formattedChunk) == 0 {
+					continue
+				}
+
+				// 写入响应
+				if _, err := w.Write(formattedChunk); err != nil {
+					return false
+				}
+				tracked.addBytes(len(formattedChunk))
 			}
 
 			// 刷新缓冲区
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"math"
+	"sync"
+)
+
+const (
+	// healthSmoothing 健康系数指数移动平均中最新一次调用的权重
+	healthSmoothing = 0.2
+	// minHealthFactor 健康系数下限，持续失败的配置仍保留少量流量以便探测恢复
+	minHealthFactor = 0.05
+	// healthWeightScale 权重放大倍数，使衰减后的权重保留足够精度
+	healthWeightScale = 100
+)
+
+// healthTracker 记录各配置近期调用成败的指数移动平均，作为负载均衡权重的健康系数
+type healthTracker struct {
+	mu     sync.RWMutex
+	factor map[uint]float64 // 配置 ID -> 健康系数（minHealthFactor~1）
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{factor: make(map[uint]float64)}
+}
+
+// Observe 记录一次调用结果：成功时系数向 1 回升，失败时向 0 衰减
+func (t *healthTracker) Observe(apiConfigID uint, success bool) {
+	target := 0.0
+	if success {
+		target = 1
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	factor, ok := t.factor[apiConfigID]
+	if !ok {
+		factor = 1
+	}
+	t.factor[apiConfigID] = math.Max(minHealthFactor, factor+healthSmoothing*(target-factor))
+}
+
+// Factor 返回配置的健康系数，尚无记录时为 1
+func (t *healthTracker) Factor(apiConfigID uint) float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if factor, ok := t.factor[apiConfigID]; ok {
+		return factor
+	}
+	return 1
+}
+
+// observeHealth 记录配置的一次上游调用结果，客户端取消的请求不计入
+func (s *service) observeHealth(ctx context.Context, apiConfigID uint, err error) {
+	if s.health == nil || ctx.Err() != nil {
+		return
+	}
+	s.health.Observe(apiConfigID, err == nil)
+}
+
+// effectiveWeights 负载均衡使用的权重：配置权重乘以健康系数（未开启健康衰减的配置系数为 1）
+// 所有权重统一放大，开启衰减的配置至少保留 1，不会被完全摘除
+func (s *service) effectiveWeights(configs []*apiconfig.APIConfig) []int {
+	weights := make([]int, len(configs))
+	for i, cfg := range configs {
+		weights[i] = cfg.Weight * healthWeightScale
+		if cfg.HealthWeightDecay && s.health != nil && cfg.Weight > 0 {
+			decayed := int(math.Round(float64(weights[i]) * s.health.Factor(cfg.ID)))
+			weights[i] = max(decayed, 1)
+		}
+	}
+	return weights
+}
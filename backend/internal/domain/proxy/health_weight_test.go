@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+var errUpstream = errors.New("upstream failed")
+
+func newHealthTestService(configs ...*apiconfig.APIConfig) *service {
+	svc := newCanaryTestService(configs...)
+	svc.health = newHealthTracker()
+	return svc
+}
+
+// observeN 为配置记录 n 次相同结果的调用
+func observeN(svc *service, id uint, n int, err error) {
+	for i := 0; i < n; i++ {
+		svc.observeHealth(context.Background(), id, err)
+	}
+}
+
+func TestSelectAPIConfig_RisingErrorsShiftTrafficAway(t *testing.T) {
+	degrading := &apiconfig.APIConfig{ID: 1, Weight: 1, HealthWeightDecay: true}
+	stable := &apiconfig.APIConfig{ID: 2, Weight: 1}
+	svc := newHealthTestService(degrading, stable)
+
+	previous := canaryShare(t, svc, 1, 10000)
+	if math.Abs(previous-0.5) > 0.03 {
+		t.Fatalf("Expected an even split before any errors, got %.2f%%", previous*100)
+	}
+
+	// 错误持续出现时分到的流量逐步减少
+	for round := 1; round <= 3; round++ {
+		observeN(svc, 1, 3, errUpstream)
+		share := canaryShare(t, svc, 1, 10000)
+		if share >= previous {
+			t.Fatalf("Round %d: expected less traffic as errors rise, got %.2f%% (was %.2f%%)", round, share*100, previous*100)
+		}
+		previous = share
+	}
+
+	// 持续失败也不会被完全摘除
+	observeN(svc, 1, 50, errUpstream)
+	if share := canaryShare(t, svc, 1, 20000); share == 0 || share > 0.08 {
+		t.Errorf("Expected a small but non-zero share at the health floor, got %.2f%%", share*100)
+	}
+}
+
+func TestSelectAPIConfig_RecoversWhenErrorsSubside(t *testing.T) {
+	degrading := &apiconfig.APIConfig{ID: 1, Weight: 1, HealthWeightDecay: true}
+	stable := &apiconfig.APIConfig{ID: 2, Weight: 1}
+	svc := newHealthTestService(degrading, stable)
+
+	observeN(svc, 1, 20, errUpstream)
+	low := canaryShare(t, svc, 1, 10000)
+
+	observeN(svc, 1, 5, nil)
+	partial := canaryShare(t, svc, 1, 10000)
+	if partial <= low {
+		t.Fatalf("Expected traffic to recover after successes, got %.2f%% (was %.2f%%)", partial*100, low*100)
+	}
+
+	observeN(svc, 1, 40, nil)
+	if share := canaryShare(t, svc, 1, 10000); math.Abs(share-0.5) > 0.03 {
+		t.Errorf("Expected the full share restored, got %.2f%%", share*100)
+	}
+}
+
+func TestSelectAPIConfig_HealthDecayIsOptIn(t *testing.T) {
+	failing := &apiconfig.APIConfig{ID: 1, Weight: 1}
+	stable := &apiconfig.APIConfig{ID: 2, Weight: 1}
+	svc := newHealthTestService(failing, stable)
+
+	observeN(svc, 1, 20, errUpstream)
+	if share := canaryShare(t, svc, 1, 10000); math.Abs(share-0.5) > 0.03 {
+		t.Errorf("Expected weights unchanged without health decay, got %.2f%%", share*100)
+	}
+
+	// 客户端取消的请求不计入健康系数
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	svc.observeHealth(ctx, 2, errUpstream)
+	if factor := svc.health.Factor(2); factor != 1 {
+		t.Errorf("Expected a cancelled call not to count, got factor %.2f", factor)
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+)
+
+// 对话历史消息数超出上限时的处理策略
+const (
+	HistoryLimitReject   = "reject"   // 拒绝请求
+	HistoryLimitTruncate = "truncate" // 只保留最近的消息
+)
+
+// HistoryLimitError 对话历史消息数超出上限且策略为拒绝，返回 400
+type HistoryLimitError struct {
+	Messages int
+	Limit    int
+}
+
+func (e *HistoryLimitError) Error() string {
+	return fmt.Sprintf("Conversation has %d messages, the limit is %d", e.Messages, e.Limit)
+}
+
+// historyLimitFor 返回生效的消息数上限和策略：API Key 与配置上限取较小值，策略跟随生效的上限，0 表示不限制
+func historyLimitFor(cfg *apiconfig.APIConfig, req *ProxyRequest) (int, string) {
+	limit, policy := cfg.MaxHistoryMessages, cfg.HistoryLimitPolicy
+	if req.MaxHistory > 0 && (limit <= 0 || req.MaxHistory < limit) {
+		limit, policy = req.MaxHistory, req.HistoryPolicy
+	}
+	return limit, policy
+}
+
+// applyHistoryLimit 按策略处理超出上限的对话历史，只统计非 system 消息
+// 截断时保留所有 system 消息和最近的消息，被截断的消息数写入请求日志；拒绝时同样记录一条请求日志
+func (s *service) applyHistoryLimit(ctx context.Context, cfg *apiconfig.APIConfig, req *ProxyRequest) error {
+	limit, policy := historyLimitFor(cfg, req)
+	if limit <= 0 {
+		return nil
+	}
+	messages := req.ChatRequest.Messages
+	count := 0
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			count++
+		}
+	}
+	if count <= limit {
+		return nil
+	}
+
+	if policy != HistoryLimitTruncate {
+		err := &HistoryLimitError{Messages: count, Limit: limit}
+		s.log(ctx).Warn("Conversation history limit exceeded, request rejected",
+			logger.String("model", req.Model),
+			logger.Int("messages", count),
+			logger.Int("limit", limit))
+		s.logRequest(ctx, req, cfg.ID, 0, 0, 0, err, false)
+		return err
+	}
+
+	req.ChatRequest.Messages, req.HistoryDropped = truncateHistory(messages, limit)
+	s.log(ctx).Info("✓ Conversation history limit applied",
+		logger.String("model", req.Model),
+		logger.Int("limit", limit),
+		logger.Int("dropped", req.HistoryDropped))
+	return nil
+}
+
+// truncateHistory 保留所有 system 消息和最近 limit 条非 system 消息，返回截断后的消息和被丢弃的消息数
+// 保留部分开头的工具结果已失去对应的工具调用，一并丢弃
+func truncateHistory(messages []adapter.Message, limit int) ([]adapter.Message, int) {
+	skip := -limit
+	for _, msg := range messages {
+		if msg.Role != "system" {
+			skip++
+		}
+	}
+
+	kept := make([]adapter.Message, 0, len(messages))
+	leading := true
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			kept = append(kept, msg)
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if leading && msg.Role == "tool" {
+			continue
+		}
+		leading = false
+		kept = append(kept, msg)
+	}
+	return kept, len(messages) - len(kept)
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// historyRequest 一条 system 消息加 turns 条交替的 user/assistant 消息
+func historyRequest(turns int) *ProxyRequest {
+	messages := []adapter.Message{{Role: "system", Content: "be brief"}}
+	for i := 0; i < turns; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, adapter.Message{Role: role, Content: fmt.Sprintf("m%d", i)})
+	}
+	return &ProxyRequest{UserID: 1, Model: "gpt-4", ChatRequest: &adapter.ChatRequest{Model: "gpt-4", Messages: messages}}
+}
+
+func messageContents(messages []adapter.Message) string {
+	contents := make([]string, len(messages))
+	for i, msg := range messages {
+		contents[i] = adapter.GetContentAsString(msg.Content)
+	}
+	return fmt.Sprint(contents)
+}
+
+func TestApplyHistoryLimit_RejectAtBoundary(t *testing.T) {
+	svc, l := newFailoverTestService(0)
+	cfg := &apiconfig.APIConfig{ID: 7, LogRequests: true, MaxHistoryMessages: 4}
+
+	// system 消息不计入上限
+	if err := svc.applyHistoryLimit(context.Background(), cfg, historyRequest(4)); err != nil {
+		t.Fatalf("Expected 4 messages to pass a limit of 4, got %v", err)
+	}
+	if len(l.created) != 0 {
+		t.Fatalf("Expected nothing logged within the limit, got %d logs", len(l.created))
+	}
+
+	err := svc.applyHistoryLimit(context.Background(), cfg, historyRequest(5))
+	historyErr, ok := err.(*HistoryLimitError)
+	if !ok || historyErr.Messages != 5 || historyErr.Limit != 4 {
+		t.Fatalf("Expected a history limit error for 5 messages, got %v", err)
+	}
+	if len(l.created) != 1 || l.created[0].StatusCode != 400 || l.created[0].APIConfigID != 7 {
+		t.Fatalf("Expected the rejection recorded as a 400 request log, got %+v", l.created)
+	}
+}
+
+func TestApplyHistoryLimit_TruncateAtBoundary(t *testing.T) {
+	svc, _ := newFailoverTestService(0)
+	cfg := &apiconfig.APIConfig{MaxHistoryMessages: 4, HistoryLimitPolicy: HistoryLimitTruncate}
+
+	req := historyRequest(4)
+	if err := svc.applyHistoryLimit(context.Background(), cfg, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(req.ChatRequest.Messages) != 5 || req.HistoryDropped != 0 {
+		t.Fatalf("Expected 4 messages left untouched, got %d (%d dropped)", len(req.ChatRequest.Messages), req.HistoryDropped)
+	}
+
+	req = historyRequest(5)
+	if err := svc.applyHistoryLimit(context.Background(), cfg, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := messageContents(req.ChatRequest.Messages); got != "[be brief m1 m2 m3 m4]" {
+		t.Errorf("Expected the system message and the 4 most recent messages, got %s", got)
+	}
+	if req.HistoryDropped != 1 {
+		t.Errorf("Expected 1 dropped message recorded, got %d", req.HistoryDropped)
+	}
+}
+
+func TestApplyHistoryLimit_KeyLimitOverridesConfig(t *testing.T) {
+	svc, _ := newFailoverTestService(0)
+	cfg := &apiconfig.APIConfig{MaxHistoryMessages: 10}
+
+	// API Key 的上限更严格，策略跟随 API Key
+	req := historyRequest(6)
+	req.MaxHistory, req.HistoryPolicy = 3, HistoryLimitTruncate
+	if err := svc.applyHistoryLimit(context.Background(), cfg, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := messageContents(req.ChatRequest.Messages); got != "[be brief m3 m4 m5]" || req.HistoryDropped != 3 {
+		t.Errorf("Expected the key's limit of 3 applied, got %s (%d dropped)", got, req.HistoryDropped)
+	}
+
+	// 配置的上限更严格时使用配置的拒绝策略
+	req = historyRequest(6)
+	req.MaxHistory, req.HistoryPolicy = 8, HistoryLimitTruncate
+	cfg.MaxHistoryMessages = 5
+	if err := svc.applyHistoryLimit(context.Background(), cfg, req); err == nil {
+		t.Error("Expected the config's stricter limit to reject")
+	}
+}
+
+func TestTruncateHistory_DropsOrphanedToolResults(t *testing.T) {
+	messages := []adapter.Message{
+		{Role: "system", Content: "s"},
+		{Role: "user", Content: "u1"},
+		{Role: "assistant", ToolCalls: []adapter.ToolCall{{ID: "c1", Type: "function", Function: adapter.FunctionCall{Name: "f"}}}},
+		{Role: "tool", ToolCallID: "c1", Content: "r1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "u2"},
+	}
+
+	kept, dropped := truncateHistory(messages, 3)
+	if got := messageContents(kept); got != "[s a1 u2]" || dropped != 3 {
+		t.Errorf("Expected the tool result without its call dropped, got %s (%d dropped)", got, dropped)
+	}
+}
+
+func TestChatCompletions_HistoryTruncationRecordedInLog(t *testing.T) {
+	upstream := &recordingUpstream{status: http.StatusOK}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+
+	cfg := failoverConfig(1, server.URL, 0)
+	cfg.MaxHistoryMessages, cfg.HistoryLimitPolicy = 2, HistoryLimitTruncate
+	svc, l := newFailoverTestService(0, cfg)
+
+	if _, err := svc.ChatCompletions(context.Background(), historyRequest(3)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(l.created) != 1 || l.created[0].HistoryDropped != 1 {
+		t.Fatalf("Expected 1 dropped message recorded in the request log, got %+v", l.created)
+	}
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+)
+
+// matchLengthRoute 返回第一个能容纳该长度的规则的模型
+// 规则按 MaxPromptTokens 从小到大匹配，0 表示不限长度；没有匹配的规则时返回空字符串
+func matchLengthRoute(routes []apikey.LengthRoute, promptTokens int) string {
+	var model string
+	best := 0
+	for _, route := range routes {
+		if route.Model == "" {
+			continue
+		}
+		if route.MaxPromptTokens == 0 {
+			if model == "" {
+				model = route.Model
+			}
+			continue
+		}
+		if promptTokens <= route.MaxPromptTokens && (best == 0 || route.MaxPromptTokens < best) {
+			model, best = route.Model, route.MaxPromptTokens
+		}
+	}
+	return model
+}
+
+// applyLengthRouting 按 API Key 配置的长度路由规则改写请求模型，计费和日志按实际使用的模型记录
+func (s *service) applyLengthRouting(ctx context.Context, req *ProxyRequest) {
+	if len(req.LengthRoutes) == 0 || req.ChatRequest == nil {
+		return
+	}
+	promptTokens := estimatePromptTokens(req.ChatRequest)
+	model := matchLengthRoute(req.LengthRoutes, promptTokens)
+	if model == "" || model == req.Model {
+		return
+	}
+
+	s.log(ctx).Info("Routed request by prompt length",
+		logger.String("requested_model", req.Model),
+		logger.String("model", model),
+		logger.Int("prompt_tokens", promptTokens))
+	req.Model = model
+	req.ChatRequest.Model = model
+}
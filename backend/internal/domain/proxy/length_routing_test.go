@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"strings"
+	"testing"
+)
+
+func lengthRoutingRequest(chars int) *ProxyRequest {
+	return &ProxyRequest{
+		Model: "auto",
+		LengthRoutes: []apikey.LengthRoute{
+			{MaxPromptTokens: 0, Model: "long-context"},
+			{MaxPromptTokens: 1000, Model: "fast"},
+			{MaxPromptTokens: 8000, Model: "medium"},
+		},
+		ChatRequest: &adapter.ChatRequest{
+			Model:    "auto",
+			Messages: []adapter.Message{{Role: "user", Content: strings.Repeat("a", chars)}},
+		},
+	}
+}
+
+func TestApplyLengthRouting_RoutesByPromptTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		chars int
+		want  string
+	}{
+		{"short prompt", 40, "fast"},
+		{"at the short boundary", 3984, "fast"}, // 996 + 4 消息开销 = 1000
+		{"just over the short boundary", 3985, "medium"},
+		{"long prompt", 40000, "long-context"},
+	}
+
+	svc := &service{logger: *logger.NewNop()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := lengthRoutingRequest(tt.chars)
+			svc.applyLengthRouting(context.Background(), req)
+			if req.Model != tt.want || req.ChatRequest.Model != tt.want {
+				t.Errorf("Expected model %s, got %s/%s", tt.want, req.Model, req.ChatRequest.Model)
+			}
+		})
+	}
+}
+
+func TestApplyLengthRouting_OptIn(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+
+	req := lengthRoutingRequest(40)
+	req.LengthRoutes = nil
+	svc.applyLengthRouting(context.Background(), req)
+	if req.Model != "auto" {
+		t.Errorf("Expected no routing without rules, got %s", req.Model)
+	}
+
+	// 没有不限长度的兜底规则时，超长提示保持原模型
+	req = lengthRoutingRequest(40000)
+	req.LengthRoutes = []apikey.LengthRoute{{MaxPromptTokens: 1000, Model: "fast"}}
+	svc.applyLengthRouting(context.Background(), req)
+	if req.Model != "auto" {
+		t.Errorf("Expected requested model kept when no rule matches, got %s", req.Model)
+	}
+}
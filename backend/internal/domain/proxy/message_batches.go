@@ -0,0 +1,337 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxBatchRequests        = 10000          // 单个批次最多包含的请求数
+	maxMessageBatches       = 1000           // 内存中最多保留的批次数
+	maxUserMessageBatches   = 20             // 单个用户同时处理中的批次数
+	maxUserBatchItems       = 20000          // 单个用户所有处理中批次的待处理请求总数
+	messageBatchConcurrency = 4              // 所有批次共享的上游并发数
+	messageBatchRetention   = 24 * time.Hour // 批次结束后结果保留时长
+)
+
+// Anthropic 批次处理状态
+const (
+	BatchStatusInProgress = "in_progress"
+	BatchStatusEnded      = "ended"
+)
+
+var (
+	errMessageBatchNotFound = errors.ErrNotFound.WithDetails("message batch not found")
+	errMessageBatchPending  = errors.New(409006, "Message batch is still in progress")
+	errTooManyMessageBatch  = errors.New(429003, "Too many message batches, retry after earlier batches expire")
+	errTooManyUserBatches   = errors.New(429003, "Too many message batches in progress for this user, retry after earlier batches end")
+)
+
+// RequestLimiter 按 API Key 和用户的速率限制为不经过 HTTP 中间件的请求计数（消息批次中逐条处理的请求）
+type RequestLimiter interface {
+	// Allow 检查并计数一次请求，超限时返回需等待的时长
+	Allow(ctx context.Context, key *apikey.APIKey, requestBytes int64) (time.Duration, error)
+}
+
+// BatchRequestCounts 批次内各状态的请求数
+type BatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// MessageBatch Anthropic Message Batch 对象
+type MessageBatch struct {
+	ID                string             `json:"id"`
+	Type              string             `json:"type"`
+	ProcessingStatus  string             `json:"processing_status"`
+	RequestCounts     BatchRequestCounts `json:"request_counts"`
+	EndedAt           *time.Time         `json:"ended_at"`
+	CreatedAt         time.Time          `json:"created_at"`
+	ExpiresAt         time.Time          `json:"expires_at"`
+	CancelInitiatedAt *time.Time         `json:"cancel_initiated_at"`
+	ResultsURL        *string            `json:"results_url"`
+}
+
+// BatchItem 批次中的一条请求
+type BatchItem struct {
+	CustomID string
+	Request  *ProxyRequest
+}
+
+// BatchItemResult 一条请求的处理结果，Response 与 Err 二选一
+type BatchItemResult struct {
+	CustomID string
+	Response *adapter.ChatResponse
+	Err      error
+}
+
+// messageBatch 存储中的一个批次，results 与 items 下标一一对应
+type messageBatch struct {
+	info    MessageBatch
+	userID  uint
+	key     *apikey.APIKey // 提交批次的 API Key，逐条请求按其速率限制计数
+	items   []*BatchItem
+	results []*BatchItemResult
+}
+
+// batchTask 批次中待处理的一条请求
+type batchTask struct {
+	batch *messageBatch
+	idx   int
+}
+
+// messageBatchStore 批次存储（仅保存在内存中，进程重启后丢失）
+// 固定数量的 worker 限制后台发往上游的并发请求数，按用户轮流取出待处理请求，
+// 单个用户的大批次不会阻塞其他用户
+type messageBatchStore struct {
+	mu          sync.RWMutex
+	batches     map[string]*messageBatch
+	queues      map[uint][]batchTask // 用户 -> 待处理的请求
+	users       []uint               // 有待处理请求的用户，按轮转顺序
+	next        int
+	pending     map[uint]int // 用户 -> 处理中批次的未完成请求数（含等待限流重试的请求）
+	ready       *sync.Cond
+	concurrency int
+	workers     sync.Once
+	now         func() time.Time
+}
+
+func newMessageBatchStore(concurrency int) *messageBatchStore {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	st := &messageBatchStore{
+		batches:     make(map[string]*messageBatch),
+		queues:      make(map[uint][]batchTask),
+		pending:     make(map[uint]int),
+		concurrency: concurrency,
+		now:         time.Now,
+	}
+	st.ready = sync.NewCond(&st.mu)
+	return st
+}
+
+// add 登记新批次，先清理过期批次；存储已满或用户处理中的批次、请求数超出上限时返回错误
+func (st *messageBatchStore) add(userID uint, items []*BatchItem) (*messageBatch, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := st.now()
+	open := 0
+	for id, b := range st.batches {
+		if b.info.EndedAt != nil && now.Sub(*b.info.EndedAt) > messageBatchRetention {
+			delete(st.batches, id)
+			continue
+		}
+		if b.userID == userID && b.info.EndedAt == nil {
+			open++
+		}
+	}
+	if len(st.batches) >= maxMessageBatches {
+		return nil, errTooManyMessageBatch
+	}
+	if open >= maxUserMessageBatches || st.pending[userID]+len(items) > maxUserBatchItems {
+		return nil, errTooManyUserBatches
+	}
+
+	b := &messageBatch{
+		info: MessageBatch{
+			ID:               "msgbatch_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+			Type:             "message_batch",
+			ProcessingStatus: BatchStatusInProgress,
+			RequestCounts:    BatchRequestCounts{Processing: len(items)},
+			CreatedAt:        now,
+			ExpiresAt:        now.Add(messageBatchRetention),
+		},
+		userID:  userID,
+		items:   items,
+		results: make([]*BatchItemResult, len(items)),
+	}
+	st.batches[b.info.ID] = b
+	st.pending[userID] += len(items)
+	return b, nil
+}
+
+// enqueue 把批次的全部请求加入用户队列
+func (st *messageBatchStore) enqueue(b *messageBatch) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for i := range b.items {
+		st.pushLocked(batchTask{batch: b, idx: i})
+	}
+}
+
+// requeue 把因限流推迟的请求重新加入用户队列
+func (st *messageBatchStore) requeue(task batchTask) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.pushLocked(task)
+}
+
+func (st *messageBatchStore) pushLocked(task batchTask) {
+	userID := task.batch.userID
+	if len(st.queues[userID]) == 0 {
+		st.users = append(st.users, userID)
+	}
+	st.queues[userID] = append(st.queues[userID], task)
+	st.ready.Signal()
+}
+
+// take 按用户轮流取出下一条待处理请求，没有请求时阻塞
+func (st *messageBatchStore) take() batchTask {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for len(st.users) == 0 {
+		st.ready.Wait()
+	}
+
+	if st.next >= len(st.users) {
+		st.next = 0
+	}
+	userID := st.users[st.next]
+	queue := st.queues[userID]
+	task := queue[0]
+	if len(queue) == 1 {
+		delete(st.queues, userID)
+		st.users = append(st.users[:st.next], st.users[st.next+1:]...)
+	} else {
+		st.queues[userID] = queue[1:]
+		st.next++
+	}
+	return task
+}
+
+// get 查找用户自己的批次，其他用户的批次视为不存在
+func (st *messageBatchStore) get(userID uint, id string) (*messageBatch, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	b, ok := st.batches[id]
+	if !ok || b.userID != userID {
+		return nil, false
+	}
+	return b, true
+}
+
+// snapshot 返回批次对象的副本
+func (st *messageBatchStore) snapshot(b *messageBatch) *MessageBatch {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	info := b.info
+	return &info
+}
+
+// complete 记录一条请求的结果，全部完成时将批次标记为已结束
+func (st *messageBatchStore) complete(b *messageBatch, idx int, result *BatchItemResult) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	b.results[idx] = result
+	if st.pending[b.userID]--; st.pending[b.userID] <= 0 {
+		delete(st.pending, b.userID)
+	}
+	b.info.RequestCounts.Processing--
+	if result.Err != nil {
+		b.info.RequestCounts.Errored++
+	} else {
+		b.info.RequestCounts.Succeeded++
+	}
+	if b.info.RequestCounts.Processing == 0 {
+		endedAt := st.now()
+		resultsURL := "/v1/messages/batches/" + b.info.ID + "/results"
+		b.info.ProcessingStatus = BatchStatusEnded
+		b.info.EndedAt = &endedAt
+		b.info.ResultsURL = &resultsURL
+	}
+}
+
+// SetRequestLimiter 设置消息批次逐条请求使用的速率限制
+func (s *service) SetRequestLimiter(limiter RequestLimiter) {
+	s.requestLimiter = limiter
+}
+
+// CreateMessageBatch 登记批次并在后台逐条处理，每条请求按普通请求单独计费，并按 key 的速率限制单独计数
+func (s *service) CreateMessageBatch(userID uint, key *apikey.APIKey, items []*BatchItem) (*MessageBatch, error) {
+	b, err := s.batches.add(userID, items)
+	if err != nil {
+		return nil, err
+	}
+	b.key = key
+	s.batches.workers.Do(func() {
+		for i := 0; i < s.batches.concurrency; i++ {
+			go s.runMessageBatchWorker()
+		}
+	})
+	s.batches.enqueue(b)
+	return s.batches.snapshot(b), nil
+}
+
+// GetMessageBatch 查询批次状态
+func (s *service) GetMessageBatch(userID uint, id string) (*MessageBatch, error) {
+	b, ok := s.batches.get(userID, id)
+	if !ok {
+		return nil, errMessageBatchNotFound
+	}
+	return s.batches.snapshot(b), nil
+}
+
+// MessageBatchResults 返回已结束批次的逐条结果，顺序与提交时一致
+func (s *service) MessageBatchResults(userID uint, id string) ([]*BatchItemResult, error) {
+	b, ok := s.batches.get(userID, id)
+	if !ok {
+		return nil, errMessageBatchNotFound
+	}
+	s.batches.mu.RLock()
+	defer s.batches.mu.RUnlock()
+	if b.info.ProcessingStatus != BatchStatusEnded {
+		return nil, errMessageBatchPending
+	}
+	return append([]*BatchItemResult(nil), b.results...), nil
+}
+
+// runMessageBatchWorker 持续取出待处理请求并发往上游
+func (s *service) runMessageBatchWorker() {
+	for {
+		s.processBatchTask(s.batches.take())
+	}
+}
+
+// processBatchTask 处理批次中的一条请求；超出 key 或用户速率限制时推迟到限制重置后重新排队，不占用 worker
+func (s *service) processBatchTask(task batchTask) {
+	b, item := task.batch, task.batch.items[task.idx]
+	if s.requestLimiter != nil && b.key != nil {
+		retryAfter, err := s.requestLimiter.Allow(context.Background(), b.key, batchItemBytes(item))
+		if err != nil {
+			s.logger.Warn("Failed to check rate limit for batch item",
+				logger.String("batch_id", b.info.ID),
+				logger.String("custom_id", item.CustomID),
+				logger.Error(err))
+		} else if retryAfter > 0 {
+			time.AfterFunc(retryAfter, func() { s.batches.requeue(task) })
+			return
+		}
+	}
+
+	resp, err := s.ChatCompletions(context.Background(), item.Request)
+	s.batches.complete(b, task.idx, &BatchItemResult{CustomID: item.CustomID, Response: resp, Err: err})
+}
+
+// batchItemBytes 批次中一条请求的大小，按 token 计量的用户聚合限流据此估算消耗
+func batchItemBytes(item *BatchItem) int64 {
+	data, err := json.Marshal(item.Request.ChatRequest)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
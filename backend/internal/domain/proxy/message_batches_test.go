@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/errors"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newMessageBatchGateway(t *testing.T) (*httptest.Server, *service) {
+	t.Helper()
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	t.Cleanup(upstream.Close)
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	svc.batches = newMessageBatchStore(1)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	group := engine.Group("/v1", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Next()
+	})
+	h := NewHandler(svc)
+	group.POST("/messages/batches", h.CreateMessageBatch)
+	group.GET("/messages/batches/:id", h.GetMessageBatch)
+	group.GET("/messages/batches/:id/results", h.GetMessageBatchResults)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway, svc
+}
+
+func getMessageBatch(t *testing.T, url string) MessageBatch {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var batch MessageBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		t.Fatalf("Invalid batch object: %v", err)
+	}
+	return batch
+}
+
+func TestMessageBatches_SubmitPollAndResults(t *testing.T) {
+	gateway, svc := newMessageBatchGateway(t)
+
+	body := `{"requests":[
+		{"custom_id":"first","params":{"model":"gpt-4","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}},
+		{"custom_id":"second","params":{"model":"gpt-4","max_tokens":16,"messages":[{"role":"user","content":"hello"}]}},
+		{"custom_id":"missing","params":{"model":"no-such-model","max_tokens":16,"messages":[{"role":"user","content":"hi"}]}}
+	]}`
+	resp, err := http.Post(gateway.URL+"/v1/messages/batches", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created MessageBatch
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || created.Type != "message_batch" || !strings.HasPrefix(created.ID, "msgbatch_") {
+		t.Fatalf("Unexpected create response %d: %+v", resp.StatusCode, created)
+	}
+	if created.ProcessingStatus != BatchStatusInProgress || created.RequestCounts.Processing != 3 {
+		t.Errorf("Expected a new batch in progress with 3 requests, got %+v", created)
+	}
+
+	var batch MessageBatch
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if batch = getMessageBatch(t, gateway.URL+"/v1/messages/batches/"+created.ID); batch.ProcessingStatus == BatchStatusEnded {
+			break
+		}
+	}
+	if batch.ProcessingStatus != BatchStatusEnded || batch.EndedAt == nil || batch.ResultsURL == nil {
+		t.Fatalf("Expected the batch to end, got %+v", batch)
+	}
+	if c := batch.RequestCounts; c.Succeeded != 2 || c.Errored != 1 || c.Processing != 0 {
+		t.Errorf("Unexpected request counts %+v", c)
+	}
+
+	resp, err = http.Get(gateway.URL + *batch.ResultsURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var lines []messageBatchResultLine
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line messageBatchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid result line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 || lines[0].CustomID != "first" || lines[1].CustomID != "second" || lines[2].CustomID != "missing" {
+		t.Fatalf("Expected one result per request in submission order, got %+v", lines)
+	}
+	if lines[0].Result.Type != "succeeded" || lines[0].Result.Message == nil {
+		t.Errorf("Expected a succeeded result with a message, got %+v", lines[0].Result)
+	}
+	if msg, _ := lines[0].Result.Message.(map[string]interface{}); msg["type"] != "message" {
+		t.Errorf("Expected the message in Anthropic format, got %+v", lines[0].Result.Message)
+	}
+	if lines[2].Result.Type != "errored" || lines[2].Result.Error == nil || lines[2].Result.Error.Error.Message == "" {
+		t.Errorf("Expected an errored result, got %+v", lines[2].Result)
+	}
+
+	// 每条完成的请求单独计费，失败的请求不计费
+	if deducted := svc.quotaService.(*openQuota).deducted; deducted != 84 {
+		t.Errorf("Expected 2 completed items billed 42 each, deducted %d", deducted)
+	}
+}
+
+func TestMessageBatches_RejectsInvalidRequests(t *testing.T) {
+	gateway, _ := newMessageBatchGateway(t)
+
+	for name, body := range map[string]string{
+		"empty":     `{"requests":[]}`,
+		"duplicate": `{"requests":[{"custom_id":"a","params":{"model":"gpt-4","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}},{"custom_id":"a","params":{"model":"gpt-4","max_tokens":1,"messages":[{"role":"user","content":"hi"}]}}]}`,
+		"stream":    `{"requests":[{"custom_id":"a","params":{"model":"gpt-4","max_tokens":1,"stream":true,"messages":[{"role":"user","content":"hi"}]}}]}`,
+	} {
+		resp, err := http.Post(gateway.URL+"/v1/messages/batches", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestMessageBatchResults_PendingAndOwnership(t *testing.T) {
+	svc := &service{batches: newMessageBatchStore(1)}
+	b, err := svc.batches.add(1, []*BatchItem{{CustomID: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.MessageBatchResults(1, b.info.ID); !errors.Is(err, errMessageBatchPending) {
+		t.Errorf("Expected results of an unfinished batch to be refused, got %v", err)
+	}
+	if _, err := svc.GetMessageBatch(2, b.info.ID); err == nil {
+		t.Error("Expected another user's batch to be hidden")
+	}
+
+	svc.batches.complete(b, 0, &BatchItemResult{CustomID: "a", Err: errEmptyCompletion})
+	results, err := svc.MessageBatchResults(1, b.info.ID)
+	if err != nil || len(results) != 1 || results[0].Err == nil {
+		t.Errorf("Expected the errored result once ended, got %+v (%v)", results, err)
+	}
+}
+
+func TestMessageBatchStore_PerUserCaps(t *testing.T) {
+	st := newMessageBatchStore(1)
+	for i := 0; i < maxUserMessageBatches; i++ {
+		if _, err := st.add(1, []*BatchItem{{CustomID: "a"}}); err != nil {
+			t.Fatalf("batch %d: %v", i, err)
+		}
+	}
+	if _, err := st.add(1, []*BatchItem{{CustomID: "a"}}); !errors.Is(err, errTooManyUserBatches) {
+		t.Errorf("Expected the user's open batch cap to be enforced, got %v", err)
+	}
+	if _, err := st.add(2, []*BatchItem{{CustomID: "a"}}); err != nil {
+		t.Errorf("Expected another user to still submit batches, got %v", err)
+	}
+
+	items := make([]*BatchItem, maxUserBatchItems)
+	for i := range items {
+		items[i] = &BatchItem{CustomID: "x"}
+	}
+	if _, err := st.add(3, items); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.add(3, []*BatchItem{{CustomID: "y"}}); !errors.Is(err, errTooManyUserBatches) {
+		t.Errorf("Expected the user's pending item cap to be enforced, got %v", err)
+	}
+}
+
+func TestMessageBatchStore_TakesRoundRobinAcrossUsers(t *testing.T) {
+	st := newMessageBatchStore(1)
+	items := func(n int) []*BatchItem {
+		out := make([]*BatchItem, n)
+		for i := range out {
+			out[i] = &BatchItem{CustomID: "x"}
+		}
+		return out
+	}
+	big, _ := st.add(1, items(5))
+	small, _ := st.add(2, items(2))
+	st.enqueue(big)
+	st.enqueue(small)
+
+	var order []uint
+	for i := 0; i < 7; i++ {
+		order = append(order, st.take().batch.userID)
+	}
+	want := []uint{1, 2, 1, 2, 1, 1, 1}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("Expected users served in turn %v, got %v", want, order)
+		}
+	}
+}
+
+// fakeLimiter 前 allow 次请求放行，之后每次返回 retryAfter 并恢复一次额度
+type fakeLimiter struct {
+	mu         sync.Mutex
+	allow      int
+	retryAfter time.Duration
+	calls      int
+	keys       map[uint]int
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, key *apikey.APIKey, requestBytes int64) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls++
+	if l.allow > 0 {
+		l.allow--
+		l.keys[key.ID]++
+		return 0, nil
+	}
+	l.allow = 1
+	return l.retryAfter, nil
+}
+
+func TestMessageBatches_ItemsCountAgainstKeyRateLimit(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer upstream.Close()
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	svc.batches = newMessageBatchStore(1)
+	limiter := &fakeLimiter{allow: 2, retryAfter: 10 * time.Millisecond, keys: make(map[uint]int)}
+	svc.SetRequestLimiter(limiter)
+
+	items := make([]*BatchItem, 5)
+	for i := range items {
+		items[i] = &BatchItem{CustomID: fmt.Sprintf("item-%d", i), Request: failoverRequest()}
+	}
+	created, err := svc.CreateMessageBatch(1, &apikey.APIKey{ID: 7, UserID: 1}, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch *MessageBatch
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if batch, _ = svc.GetMessageBatch(1, created.ID); batch.ProcessingStatus == BatchStatusEnded {
+			break
+		}
+	}
+	if batch.ProcessingStatus != BatchStatusEnded || batch.RequestCounts.Succeeded != 5 {
+		t.Fatalf("Expected every item to complete after waiting out the rate limit, got %+v", batch)
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.keys[7] != 5 {
+		t.Errorf("Expected each item counted once against the key, got %d", limiter.keys[7])
+	}
+	if limiter.calls <= 5 {
+		t.Errorf("Expected rate-limited items to be retried, got %d checks", limiter.calls)
+	}
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+)
+
+// applyModelAlias 请求的模型是配置中的别名时，改写为实际模型并按策略注入默认 system 提示词
+// 只改写发往上游的 ChatRequest，ProxyRequest.Model 保留别名用于定价和日志
+func applyModelAlias(cfg *apiconfig.APIConfig, chatReq *adapter.ChatRequest) {
+	alias, ok := cfg.FindModelAlias(chatReq.Model)
+	if !ok {
+		return
+	}
+	if alias.Model != "" {
+		chatReq.Model = alias.Model
+	}
+	if alias.SystemPrompt == "" {
+		return
+	}
+
+	idx := firstSystemMessage(chatReq.Messages)
+	if idx < 0 {
+		chatReq.Messages = append([]adapter.Message{{Role: "system", Content: alias.SystemPrompt}}, chatReq.Messages...)
+		return
+	}
+	if alias.SystemPolicy != apiconfig.SystemPolicyPrepend {
+		return
+	}
+
+	// 客户端 system 为纯文本时合并为一条，避免部分上游不接受多条 system 消息
+	if content, isText := chatReq.Messages[idx].Content.(string); isText {
+		chatReq.Messages[idx].Content = alias.SystemPrompt + "\n\n" + content
+		return
+	}
+	messages := make([]adapter.Message, 0, len(chatReq.Messages)+1)
+	messages = append(messages, chatReq.Messages[:idx]...)
+	messages = append(messages, adapter.Message{Role: "system", Content: alias.SystemPrompt})
+	chatReq.Messages = append(messages, chatReq.Messages[idx:]...)
+}
+
+// firstSystemMessage 返回第一条 system 消息的下标，不存在时返回 -1
+func firstSystemMessage(messages []adapter.Message) int {
+	for i, msg := range messages {
+		if msg.Role == "system" {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const supportPrompt = "You are a friendly support agent."
+
+func aliasConfig(policy string) *apiconfig.APIConfig {
+	return &apiconfig.APIConfig{ModelAliases: apiconfig.ModelAliases{
+		"support-bot": {Model: "gpt-4o", SystemPrompt: supportPrompt, SystemPolicy: policy},
+		"coder":       {Model: "gpt-4o", SystemPrompt: "You write idiomatic code."},
+	}}
+}
+
+func TestApplyModelAlias_DefaultPromptApplied(t *testing.T) {
+	req := &adapter.ChatRequest{Model: "support-bot", Messages: []adapter.Message{{Role: "user", Content: "hi"}}}
+	applyModelAlias(aliasConfig(""), req)
+
+	if req.Model != "gpt-4o" {
+		t.Errorf("Expected upstream model gpt-4o, got %q", req.Model)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[0].Content != supportPrompt {
+		t.Errorf("Expected the alias prompt as leading system message, got %+v", req.Messages)
+	}
+}
+
+func TestApplyModelAlias_ClientSystemOverrides(t *testing.T) {
+	req := &adapter.ChatRequest{Model: "support-bot", Messages: []adapter.Message{
+		{Role: "system", Content: "Answer in French."}, {Role: "user", Content: "hi"},
+	}}
+	applyModelAlias(aliasConfig(apiconfig.SystemPolicyOverride), req)
+
+	if len(req.Messages) != 2 || req.Messages[0].Content != "Answer in French." {
+		t.Errorf("Expected the client system message to replace the default, got %+v", req.Messages)
+	}
+}
+
+func TestApplyModelAlias_PrependPolicy(t *testing.T) {
+	req := &adapter.ChatRequest{Model: "support-bot", Messages: []adapter.Message{
+		{Role: "system", Content: "Answer in French."}, {Role: "user", Content: "hi"},
+	}}
+	applyModelAlias(aliasConfig(apiconfig.SystemPolicyPrepend), req)
+
+	want := supportPrompt + "\n\nAnswer in French."
+	if len(req.Messages) != 2 || req.Messages[0].Content != want {
+		t.Errorf("Expected merged system message %q, got %+v", want, req.Messages)
+	}
+
+	// 多模态 system 消息无法合并，默认提示词作为单独一条放在前面
+	blocks := []adapter.ContentBlock{{Type: "text", Text: "Answer in French."}}
+	req = &adapter.ChatRequest{Model: "support-bot", Messages: []adapter.Message{
+		{Role: "system", Content: blocks}, {Role: "user", Content: "hi"},
+	}}
+	applyModelAlias(aliasConfig(apiconfig.SystemPolicyPrepend), req)
+	if len(req.Messages) != 3 || req.Messages[0].Content != supportPrompt || req.Messages[1].Role != "system" {
+		t.Errorf("Expected a separate default system message first, got %+v", req.Messages)
+	}
+}
+
+func TestApplyModelAlias_NonAliasUntouched(t *testing.T) {
+	req := &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{{Role: "user", Content: "hi"}}}
+	applyModelAlias(aliasConfig(""), req)
+
+	if req.Model != "gpt-4" || len(req.Messages) != 1 {
+		t.Errorf("Expected a non-alias request untouched, got %+v", req)
+	}
+}
+
+func TestChatCompletions_ModelAliasRewritesUpstreamRequest(t *testing.T) {
+	upstream := &recordingUpstream{status: http.StatusOK}
+	srv := httptest.NewServer(upstream)
+	defer srv.Close()
+
+	cfg := failoverConfig(1, srv.URL, 0)
+	cfg.Models = []string{"support-bot"}
+	cfg.ModelAliases = aliasConfig("").ModelAliases
+	svc, l := newFailoverTestService(0)
+	svc.apiConfigRepo = &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"support-bot": {cfg}}}
+
+	req := &ProxyRequest{UserID: 1, Model: "support-bot", ChatRequest: &adapter.ChatRequest{
+		Model: "support-bot", Messages: []adapter.Message{{Role: "user", Content: "hi"}},
+	}}
+	if _, err := svc.ChatCompletions(context.Background(), req); err != nil {
+		t.Fatalf("ChatCompletions failed: %v", err)
+	}
+
+	var sent adapter.ChatRequest
+	if err := json.Unmarshal(upstream.bodies[0], &sent); err != nil {
+		t.Fatalf("Invalid upstream body %q: %v", upstream.bodies[0], err)
+	}
+	if sent.Model != "gpt-4o" || len(sent.Messages) != 2 || sent.Messages[0].Content != supportPrompt {
+		t.Errorf("Expected aliased model and default prompt upstream, got %+v", sent)
+	}
+	if len(l.created) != 1 || l.created[0].Model != "support-bot" {
+		t.Errorf("Expected the log to record the alias, got %+v", l.created)
+	}
+}
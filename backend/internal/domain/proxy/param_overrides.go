@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+)
+
+// applyParamOverrides 按 API Key 的覆盖规则调整客户端传入的 temperature、top_p、max_tokens
+func applyParamOverrides(req *adapter.ChatRequest, overrides apikey.ParamOverrides) {
+	if req == nil {
+		return
+	}
+	for param, o := range overrides {
+		switch param {
+		case apikey.ParamTemperature:
+			req.Temperature = overrideSampling(req.Temperature, o)
+		case apikey.ParamTopP:
+			req.TopP = overrideSampling(req.TopP, o)
+		case apikey.ParamMaxTokens:
+			req.MaxTokens = overrideMaxTokens(req.MaxTokens, o)
+		}
+	}
+}
+
+// overrideSampling 采样参数未传（nil）时由上游使用默认值，clamp 只约束客户端显式传入的值
+func overrideSampling(value *float64, o apikey.ParamOverride) *float64 {
+	switch o.Mode {
+	case apikey.ParamOverrideForce:
+		return copyFloat(o.Value)
+	case apikey.ParamOverrideDefault:
+		if value == nil {
+			return copyFloat(o.Value)
+		}
+	case apikey.ParamOverrideClamp:
+		if value != nil {
+			clamped := clampParam(*value, o)
+			return &clamped
+		}
+	}
+	return value
+}
+
+// overrideMaxTokens max_tokens 为 0 表示未传；clamp 设置了上限时未传也按上限处理，避免绕过限制
+func overrideMaxTokens(value int, o apikey.ParamOverride) int {
+	switch o.Mode {
+	case apikey.ParamOverrideForce:
+		if o.Value != nil {
+			return int(*o.Value)
+		}
+	case apikey.ParamOverrideDefault:
+		if value == 0 && o.Value != nil {
+			return int(*o.Value)
+		}
+	case apikey.ParamOverrideClamp:
+		if value == 0 {
+			if o.Max != nil {
+				return int(*o.Max)
+			}
+			return value
+		}
+		return int(clampParam(float64(value), o))
+	}
+	return value
+}
+
+func clampParam(value float64, o apikey.ParamOverride) float64 {
+	if o.Min != nil && value < *o.Min {
+		value = *o.Min
+	}
+	if o.Max != nil && value > *o.Max {
+		value = *o.Max
+	}
+	return value
+}
+
+func copyFloat(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApplyParamOverrides_ForceIgnoresClientValue(t *testing.T) {
+	overrides := apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideForce, Value: float64Ptr(0)},
+		apikey.ParamMaxTokens:   {Mode: apikey.ParamOverrideForce, Value: float64Ptr(256)},
+	}
+
+	for _, client := range []*float64{nil, float64Ptr(0.9)} {
+		req := &adapter.ChatRequest{Temperature: client, MaxTokens: 4000}
+		applyParamOverrides(req, overrides)
+		if req.Temperature == nil || *req.Temperature != 0 || req.MaxTokens != 256 {
+			t.Errorf("Expected forced temperature 0 and max_tokens 256, got %v and %d", req.Temperature, req.MaxTokens)
+		}
+	}
+
+	// 强制的 0 必须写入上游请求，不能被 omitempty 丢掉
+	req := &adapter.ChatRequest{Temperature: float64Ptr(0.9)}
+	applyParamOverrides(req, overrides)
+	body, _ := json.Marshal(req)
+	var decoded map[string]interface{}
+	json.Unmarshal(body, &decoded)
+	if v, ok := decoded["temperature"]; !ok || v != float64(0) {
+		t.Errorf("Expected temperature 0 in the upstream body, got %s", body)
+	}
+}
+
+func TestApplyParamOverrides_DefaultOnlyWhenOmitted(t *testing.T) {
+	overrides := apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideDefault, Value: float64Ptr(0.2)},
+		apikey.ParamTopP:        {Mode: apikey.ParamOverrideDefault, Value: float64Ptr(0.5)},
+		apikey.ParamMaxTokens:   {Mode: apikey.ParamOverrideDefault, Value: float64Ptr(512)},
+	}
+
+	omitted := &adapter.ChatRequest{}
+	applyParamOverrides(omitted, overrides)
+	if *omitted.Temperature != 0.2 || *omitted.TopP != 0.5 || omitted.MaxTokens != 512 {
+		t.Errorf("Expected defaults applied when omitted, got %v %v %d", *omitted.Temperature, *omitted.TopP, omitted.MaxTokens)
+	}
+
+	explicit := &adapter.ChatRequest{Temperature: float64Ptr(0), TopP: float64Ptr(0.9), MaxTokens: 100}
+	applyParamOverrides(explicit, overrides)
+	if *explicit.Temperature != 0 || *explicit.TopP != 0.9 || explicit.MaxTokens != 100 {
+		t.Errorf("Expected client values kept, got %v %v %d", *explicit.Temperature, *explicit.TopP, explicit.MaxTokens)
+	}
+}
+
+func TestApplyParamOverrides_Clamp(t *testing.T) {
+	overrides := apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideClamp, Min: float64Ptr(0.1), Max: float64Ptr(0.7)},
+		apikey.ParamMaxTokens:   {Mode: apikey.ParamOverrideClamp, Max: float64Ptr(1000)},
+	}
+
+	high := &adapter.ChatRequest{Temperature: float64Ptr(1.5), MaxTokens: 8000}
+	applyParamOverrides(high, overrides)
+	if *high.Temperature != 0.7 || high.MaxTokens != 1000 {
+		t.Errorf("Expected values clamped to the maximum, got %v %d", *high.Temperature, high.MaxTokens)
+	}
+
+	omitted := &adapter.ChatRequest{}
+	applyParamOverrides(omitted, overrides)
+	if omitted.Temperature != nil || omitted.MaxTokens != 1000 {
+		t.Errorf("Expected omitted temperature left alone and max_tokens capped, got %v %d", omitted.Temperature, omitted.MaxTokens)
+	}
+}
+
+func TestApplyAPIKeySettings_AppliesParamOverrides(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("api_key_info", &apikey.APIKey{LogRequests: true, ParamOverrides: apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideForce, Value: float64Ptr(0), Locked: true},
+	}})
+
+	req := &ProxyRequest{ChatRequest: &adapter.ChatRequest{Temperature: float64Ptr(1)}}
+	if err := applyAPIKeySettings(c, req); err != nil {
+		t.Fatal(err)
+	}
+	if *req.ChatRequest.Temperature != 0 {
+		t.Errorf("Expected the key's forced temperature, got %v", *req.ChatRequest.Temperature)
+	}
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"time"
+)
+
+// AlertTypePayloadSize 单次请求体积超限告警
+const AlertTypePayloadSize = "payload_size_exceeded"
+
+// payloadExceeded 请求或响应体积是否超过告警阈值
+func (s *service) payloadExceeded(entry *log.CreateLogRequest) bool {
+	threshold := s.runtimeConfig.Get().GetPayloadAlertBytes()
+	return threshold > 0 && (entry.RequestBytes > threshold || entry.ResponseBytes > threshold)
+}
+
+// checkPayloadSize 请求或响应体积超过阈值时异步发送告警（仅包含大小与元数据，不含内容）
+func (s *service) checkPayloadSize(entry *log.CreateLogRequest, logID uint) {
+	if !s.payloadExceeded(entry) {
+		return
+	}
+	cfg := s.runtimeConfig.Get()
+	threshold := cfg.GetPayloadAlertBytes()
+
+	s.logger.Warn("Upstream payload size exceeded threshold",
+		logger.Uint("user_id", entry.UserID),
+		logger.String("model", entry.Model),
+		logger.Int64("request_bytes", entry.RequestBytes),
+		logger.Int64("response_bytes", entry.ResponseBytes),
+		logger.Int64("threshold", threshold))
+
+	url := cfg.GetAlertWebhookURL()
+	if url == "" {
+		return
+	}
+
+	event := &alert.Event{
+		Type:    AlertTypePayloadSize,
+		Message: fmt.Sprintf("Payload size exceeded %d bytes for model %s", threshold, entry.Model),
+		Data: map[string]interface{}{
+			"user_id":        entry.UserID,
+			"api_key_id":     entry.APIKeyID,
+			"api_config_id":  entry.APIConfigID,
+			"model":          entry.Model,
+			"request_log_id": logID,
+			"request_bytes":  entry.RequestBytes,
+			"response_bytes": entry.ResponseBytes,
+			"threshold":      threshold,
+		},
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.alertNotifier.Send(ctx, url, event); err != nil {
+			s.log(ctx).Warn("Failed to send payload size alert", logger.Error(err))
+		}
+	}()
+}
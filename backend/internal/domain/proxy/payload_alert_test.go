@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/pkg/alert"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAlertTestService(t *testing.T, threshold int64) (*service, chan alert.Event) {
+	t.Helper()
+	events := make(chan alert.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event alert.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	t.Cleanup(server.Close)
+
+	svc := newBillingTestService(&fakeQuota{}, &fakeLog{})
+	svc.alertNotifier = alert.NewNotifier(time.Second)
+	cfg := svc.runtimeConfig.Get()
+	cfg.PayloadAlertBytes = threshold
+	cfg.AlertWebhookURL = server.URL
+	return svc, events
+}
+
+func TestCheckPayloadSize_FiresAboveThreshold(t *testing.T) {
+	svc, events := newAlertTestService(t, 1000)
+
+	svc.checkPayloadSize(&log.CreateLogRequest{UserID: 1, Model: "gpt-4", RequestBytes: 200, ResponseBytes: 5000}, 7)
+
+	select {
+	case event := <-events:
+		if event.Type != AlertTypePayloadSize {
+			t.Errorf("Expected alert type %s, got %s", AlertTypePayloadSize, event.Type)
+		}
+		if event.Data["response_bytes"] != float64(5000) || event.Data["request_log_id"] != float64(7) {
+			t.Errorf("Unexpected alert data: %v", event.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected alert to be sent")
+	}
+}
+
+func TestCheckPayloadSize_SilentAtOrBelowThreshold(t *testing.T) {
+	svc, events := newAlertTestService(t, 1000)
+
+	svc.checkPayloadSize(&log.CreateLogRequest{UserID: 1, Model: "gpt-4", RequestBytes: 1000, ResponseBytes: 10}, 1)
+
+	select {
+	case event := <-events:
+		t.Fatalf("Expected no alert, got %v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 未加载运行时配置时使用的前缀缓存策略
+const (
+	defaultPrefixCacheMinTokens = 1024
+	defaultPrefixCacheTTL       = 5 * time.Minute
+
+	// maxPrefixEntries 最多记录的前缀数，超出后不再记录新前缀，直到有前缀过期
+	maxPrefixEntries = 10000
+)
+
+// prefixEntry 已出现过的对话前缀，summary 为本地摘要（上游支持提示词缓存时为空）
+type prefixEntry struct {
+	summary   *adapter.Message
+	expiresAt time.Time
+}
+
+// prefixStore 对话前缀的内存记录，按前缀哈希索引
+type prefixStore struct {
+	mu      sync.Mutex
+	entries map[string]*prefixEntry
+	now     func() time.Time
+}
+
+func newPrefixStore() *prefixStore {
+	return &prefixStore{entries: make(map[string]*prefixEntry), now: time.Now}
+}
+
+// lookup 查找未过期的前缀，命中时顺延过期时间
+func (p *prefixStore) lookup(key string, ttl time.Duration) (*prefixEntry, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	if !ok {
+		return nil, false
+	}
+	now := p.now()
+	if now.After(entry.expiresAt) {
+		delete(p.entries, key)
+		return nil, false
+	}
+	entry.expiresAt = now.Add(ttl)
+	return entry, true
+}
+
+// store 记录前缀，容量已满时先清理过期记录
+func (p *prefixStore) store(key string, summary *adapter.Message, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if len(p.entries) >= maxPrefixEntries {
+		for k, entry := range p.entries {
+			if now.After(entry.expiresAt) {
+				delete(p.entries, k)
+			}
+		}
+		if len(p.entries) >= maxPrefixEntries {
+			return
+		}
+	}
+	p.entries[key] = &prefixEntry{summary: summary, expiresAt: now.Add(ttl)}
+}
+
+// prefixCacheKey 对话前缀（除最后一条外的所有消息）的哈希
+func prefixCacheKey(model string, prefix []adapter.Message) string {
+	data, _ := json.Marshal(prefix)
+	sum := sha256.Sum256(append([]byte(model+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// prefixCacheOptions 获取前缀缓存的最小 token 数和过期时长
+func (s *service) prefixCacheOptions() (int, time.Duration) {
+	if s.runtimeConfig == nil {
+		return defaultPrefixCacheMinTokens, defaultPrefixCacheTTL
+	}
+	return s.runtimeConfig.Get().GetPrefixCacheOptions()
+}
+
+// applyPrefixCaching 复用重复出现的长对话前缀
+// 上游支持提示词缓存时，前缀再次出现即标记缓存断点，由上游按缓存价格计费；
+// 否则首次出现时生成本地摘要，之后同一前缀加新消息的请求用摘要替换前缀中的非 system 消息
+func (s *service) applyPrefixCaching(ctx context.Context, cfg *apiconfig.APIConfig, adapterInstance adapter.Adapter, req *adapter.ChatRequest) {
+	if !cfg.PrefixCaching || s.prefixes == nil || len(req.Messages) < 2 {
+		return
+	}
+	minTokens, ttl := s.prefixCacheOptions()
+	if ttl <= 0 {
+		return
+	}
+
+	last := req.Messages[len(req.Messages)-1]
+	prefix := req.Messages[:len(req.Messages)-1]
+	prefixTokens := 0
+	for _, msg := range prefix {
+		prefixTokens += estimateMessageTokens(msg)
+	}
+	if prefixTokens < minTokens {
+		return
+	}
+
+	key := prefixCacheKey(req.Model, prefix)
+	entry, seen := s.prefixes.lookup(key, ttl)
+
+	if adapter.GetCapabilities(adapterInstance).PromptCaching {
+		if !seen {
+			s.prefixes.store(key, nil, ttl)
+			return
+		}
+		req.CachePrefix = true
+		s.log(ctx).Info("✓ Repeated conversation prefix marked for upstream prompt caching",
+			logger.String("model", req.Model),
+			logger.Int("prefix_tokens", prefixTokens))
+		return
+	}
+
+	// 摘要只替换历史，最后一条必须是独立的 user 消息（工具结果不能脱离对应的 tool_calls）
+	if last.Role != "user" {
+		return
+	}
+	if !seen {
+		if summary := summarizePrefix(prefix, prefixTokens/4); summary != nil {
+			s.prefixes.store(key, summary, ttl)
+		}
+		return
+	}
+	if entry.summary == nil {
+		return
+	}
+
+	messages := make([]adapter.Message, 0, len(prefix)+2)
+	for _, msg := range prefix {
+		if msg.Role == "system" {
+			messages = append(messages, msg)
+		}
+	}
+	messages = append(messages, *entry.summary, last)
+
+	s.log(ctx).Info("✓ Repeated conversation prefix replaced with cached summary",
+		logger.String("model", req.Model),
+		logger.Int("prefix_tokens", prefixTokens),
+		logger.Int("summary_tokens", estimateMessageTokens(*entry.summary)))
+
+	req.Messages = messages
+}
+
+// summarizePrefix 将前缀中的非 system 消息压缩为一条 system 摘要，超出预算时优先舍弃最早的内容
+func summarizePrefix(prefix []adapter.Message, budget int) *adapter.Message {
+	var history []adapter.Message
+	for _, msg := range prefix {
+		if msg.Role != "system" {
+			history = append(history, msg)
+		}
+	}
+
+	lines := summaryLines(history)
+	for len(lines) > 0 {
+		summary := adapter.Message{
+			Role:    "system",
+			Content: "Summary of earlier conversation:\n" + strings.Join(lines, "\n"),
+		}
+		if estimateMessageTokens(summary) <= budget {
+			return &summary
+		}
+		lines = lines[1:]
+	}
+	return nil
+}
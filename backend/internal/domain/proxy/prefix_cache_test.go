@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"strings"
+	"testing"
+)
+
+// longPrefixConversation 前缀约 1500 token，超过默认的最小缓存阈值
+func longPrefixConversation(question string) []adapter.Message {
+	text := strings.Repeat("context ", 250)
+	return []adapter.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "document one " + text},
+		{Role: "assistant", Content: "noted " + text},
+		{Role: "user", Content: "document two " + text},
+		{Role: "user", Content: question},
+	}
+}
+
+func newPrefixTestService() *service {
+	return &service{logger: *logger.NewNop(), prefixes: newPrefixStore()}
+}
+
+func TestApplyPrefixCaching_MarksUpstreamCacheOnRepeat(t *testing.T) {
+	svc := newPrefixTestService()
+	cfg := &apiconfig.APIConfig{PrefixCaching: true}
+	a := adapter.NewAnthropicAdapter(&adapter.Config{})
+
+	first := &adapter.ChatRequest{Model: "claude-3", Messages: longPrefixConversation("first question")}
+	svc.applyPrefixCaching(context.Background(), cfg, a, first)
+	if first.CachePrefix {
+		t.Fatal("Expected no cache breakpoint for a prefix seen for the first time")
+	}
+
+	second := &adapter.ChatRequest{Model: "claude-3", Messages: longPrefixConversation("second question")}
+	svc.applyPrefixCaching(context.Background(), cfg, a, second)
+	if !second.CachePrefix {
+		t.Fatal("Expected cache breakpoint when the prefix repeats with a new final message")
+	}
+	if len(second.Messages) != 5 {
+		t.Errorf("Expected messages untouched for upstream caching, got %d", len(second.Messages))
+	}
+}
+
+func TestApplyPrefixCaching_ReusesLocalSummary(t *testing.T) {
+	svc := newPrefixTestService()
+	cfg := &apiconfig.APIConfig{PrefixCaching: true}
+	a := adapter.NewGeminiAdapter(&adapter.Config{})
+
+	first := &adapter.ChatRequest{Model: "gemini-2.0", Messages: longPrefixConversation("first question")}
+	svc.applyPrefixCaching(context.Background(), cfg, a, first)
+	if len(first.Messages) != 5 {
+		t.Fatalf("Expected first request sent in full, got %d messages", len(first.Messages))
+	}
+
+	second := &adapter.ChatRequest{Model: "gemini-2.0", Messages: longPrefixConversation("second question")}
+	svc.applyPrefixCaching(context.Background(), cfg, a, second)
+	if len(second.Messages) != 3 {
+		t.Fatalf("Expected system, summary and final message, got %d: %+v", len(second.Messages), second.Messages)
+	}
+	if second.Messages[0].Content != "be brief" {
+		t.Errorf("Expected original system message kept first, got %v", second.Messages[0].Content)
+	}
+	summary := adapter.GetContentAsString(second.Messages[1].Content)
+	if second.Messages[1].Role != "system" || !strings.Contains(summary, "document two") {
+		t.Errorf("Expected cached summary of the prefix, got %+v", second.Messages[1])
+	}
+	if second.Messages[2].Content != "second question" {
+		t.Errorf("Expected new final message preserved, got %v", second.Messages[2].Content)
+	}
+	if totalTokens(second.Messages) >= totalTokens(longPrefixConversation("second question"))/2 {
+		t.Errorf("Expected summarized request to be much smaller, got %d tokens", totalTokens(second.Messages))
+	}
+
+	// 前缀不同的对话不会误用摘要
+	other := longPrefixConversation("second question")
+	other[1].Content = "another document"
+	req := &adapter.ChatRequest{Model: "gemini-2.0", Messages: other}
+	svc.applyPrefixCaching(context.Background(), cfg, a, req)
+	if len(req.Messages) != 5 {
+		t.Errorf("Expected different prefix left untouched, got %d messages", len(req.Messages))
+	}
+}
+
+func TestApplyPrefixCaching_SkipsWhenDisabledOrShort(t *testing.T) {
+	svc := newPrefixTestService()
+	a := adapter.NewAnthropicAdapter(&adapter.Config{})
+
+	for i := 0; i < 2; i++ {
+		req := &adapter.ChatRequest{Model: "claude-3", Messages: longPrefixConversation("q")}
+		svc.applyPrefixCaching(context.Background(), &apiconfig.APIConfig{}, a, req)
+		if req.CachePrefix {
+			t.Fatal("Expected no caching when the config has prefix caching disabled")
+		}
+	}
+
+	cfg := &apiconfig.APIConfig{PrefixCaching: true}
+	for i := 0; i < 2; i++ {
+		req := &adapter.ChatRequest{Model: "claude-3", Messages: []adapter.Message{
+			{Role: "user", Content: "short"}, {Role: "assistant", Content: "ok"}, {Role: "user", Content: "again"},
+		}}
+		svc.applyPrefixCaching(context.Background(), cfg, a, req)
+		if req.CachePrefix {
+			t.Fatal("Expected prefixes below the token threshold not to be cached")
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"fmt"
+)
+
+// PricingPreviewRequest 定价预览参数
+type PricingPreviewRequest struct {
+	Model        string `form:"model" binding:"required"`
+	InputTokens  int64  `form:"input_tokens" binding:"min=0"`
+	OutputTokens int64  `form:"output_tokens" binding:"min=0"`
+	ServiceTier  string `form:"service_tier" binding:"omitempty,oneof=auto default flex priority"`
+}
+
+// PricingPreview 按示例用量计算的费用，与真实请求的扣费逻辑一致
+type PricingPreview struct {
+	APIConfigID    uint                     `json:"api_config_id"`
+	Model          string                   `json:"model"`
+	InputTokens    int64                    `json:"input_tokens"`
+	OutputTokens   int64                    `json:"output_tokens"`
+	PricingFound   bool                     `json:"pricing_found"`
+	Pricing        *pricing.PricingResponse `json:"pricing,omitempty"`
+	ServiceTier    string                   `json:"service_tier,omitempty"`
+	TierMultiplier float64                  `json:"tier_multiplier"`
+	InputCost      float64                  `json:"input_cost"`
+	OutputCost     float64                  `json:"output_cost"`
+	Credits        int64                    `json:"credits"` // 实际扣除的积分
+	Currency       string                   `json:"currency,omitempty"`
+	Warnings       []string                 `json:"warnings,omitempty"`
+}
+
+// PreviewPricing 预览配置对示例请求的计费，缺少定价时在结果中标记而不是报错
+func (s *service) PreviewPricing(ctx context.Context, apiConfigID uint, req *PricingPreviewRequest) (*PricingPreview, error) {
+	config, err := s.apiConfigRepo.FindByID(ctx, apiConfigID)
+	if err != nil {
+		return nil, errors.Wrap(err, 500006, "Failed to get API config")
+	}
+	if config == nil {
+		return nil, errors.ErrAPIConfigNotFound
+	}
+
+	preview := &PricingPreview{
+		APIConfigID:    apiConfigID,
+		Model:          req.Model,
+		InputTokens:    req.InputTokens,
+		OutputTokens:   req.OutputTokens,
+		ServiceTier:    req.ServiceTier,
+		TierMultiplier: s.serviceTierPriceMultiplier(req.ServiceTier),
+	}
+	if !config.HasModel(req.Model) {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("config %s does not serve model %s", config.Name, req.Model))
+	}
+
+	costResp, err := s.pricingService.CalculateCost(ctx, &pricing.CalculateCostRequest{
+		APIConfigID:  apiConfigID,
+		ModelName:    req.Model,
+		InputTokens:  req.InputTokens,
+		OutputTokens: req.OutputTokens,
+	})
+	if err != nil {
+		if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != 404001 {
+			return nil, err
+		}
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("no pricing for model %s on this config, requests will be rejected", req.Model))
+		return preview, nil
+	}
+
+	preview.PricingFound = true
+	preview.Pricing = costResp.Pricing
+	preview.InputCost = costResp.InputCost
+	preview.OutputCost = costResp.OutputCost
+	preview.Credits = s.chargedCredits(costResp.TotalCost, req.ServiceTier)
+	preview.Currency = costResp.Currency
+	return preview, nil
+}
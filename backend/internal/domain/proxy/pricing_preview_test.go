@@ -0,0 +1,138 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pricingRows 按 (配置, 模型) 存储定价记录，供真实定价服务使用
+type pricingRows struct {
+	pricing.Repository
+	rows      []*pricing.Pricing
+	templates []*pricing.PricingTemplate
+}
+
+func (r *pricingRows) FindByModelAndAPIConfig(ctx context.Context, model string, apiConfigID uint) (*pricing.Pricing, error) {
+	for _, p := range r.rows {
+		if p.ModelName == model && p.APIConfigID == apiConfigID {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *pricingRows) FindTemplateByModel(ctx context.Context, model string) (*pricing.PricingTemplate, error) {
+	for _, t := range r.templates {
+		if t.ModelName == model {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+
+type configByID struct {
+	apiconfig.Repository
+	configs map[uint]*apiconfig.APIConfig
+}
+
+func (r *configByID) FindByID(ctx context.Context, id uint) (*apiconfig.APIConfig, error) {
+	return r.configs[id], nil
+}
+
+func newPricingPreviewService(quotaSvc *fakeQuota) *service {
+	rc := runtime.NewManager(nil)
+	rc.Get().FlexTierPriceMultiplier = 0.5
+	return &service{
+		apiConfigRepo: &configByID{configs: map[uint]*apiconfig.APIConfig{
+			1: {ID: 1, Name: "primary", Models: apiconfig.StringArray{"gpt-4", "gpt-4o"}},
+		}},
+		pricingService: pricing.NewService(&pricingRows{rows: []*pricing.Pricing{
+			{ID: 7, APIConfigID: 1, ModelName: "gpt-4", InputPrice: 30, OutputPrice: 60, Unit: 1000, Currency: "credits", IsActive: true},
+		}}, nil, *logger.NewNop()),
+		quotaService:  quotaSvc,
+		runtimeConfig: rc,
+		logger:        *logger.NewNop(),
+	}
+}
+
+func TestPreviewPricing_MatchesActualDeduction(t *testing.T) {
+	for _, tier := range []string{"", "flex"} {
+		quotaSvc := &fakeQuota{}
+		svc := newPricingPreviewService(quotaSvc)
+
+		preview, err := svc.PreviewPricing(context.Background(), 1, &PricingPreviewRequest{
+			Model: "gpt-4", InputTokens: 1200, OutputTokens: 800, ServiceTier: tier,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		charged, err := svc.calculateAndDeductCost(context.Background(), 1, 1, "gpt-4", tier,
+			adapter.UsageInfo{PromptTokens: 1200, CompletionTokens: 800})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		if !preview.PricingFound || preview.Credits != int64(charged) || quotaSvc.deducted != preview.Credits {
+			t.Errorf("tier %q: preview %d credits, deducted %d", tier, preview.Credits, quotaSvc.deducted)
+		}
+		if preview.Pricing == nil || preview.Pricing.ID != 7 || preview.Currency != "credits" {
+			t.Errorf("tier %q: expected applied pricing row 7, got %+v", tier, preview.Pricing)
+		}
+		if len(preview.Warnings) != 0 {
+			t.Errorf("tier %q: unexpected warnings %v", tier, preview.Warnings)
+		}
+	}
+}
+
+func TestPreviewPricing_ReportsMissingPricing(t *testing.T) {
+	svc := newPricingPreviewService(&fakeQuota{})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/api-configs/:id/pricing-preview", NewHandler(svc).PricingPreview)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api-configs/1/pricing-preview?model=gpt-4o&input_tokens=10&output_tokens=10", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Data PricingPreview `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if body.Data.PricingFound || body.Data.Credits != 0 || len(body.Data.Warnings) != 1 {
+		t.Errorf("Expected missing pricing to be flagged, got %+v", body.Data)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api-configs/99/pricing-preview?model=gpt-4", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown config, got %d", w.Code)
+	}
+}
+
+func TestValidatePricing_AcceptsModelTemplate(t *testing.T) {
+	rows := &pricingRows{templates: []*pricing.PricingTemplate{
+		{ID: 1, ModelName: "gpt-4o", InputPrice: 5, OutputPrice: 15, Unit: 1000, Currency: "credits", IsActive: true},
+	}}
+	svc := &service{pricingService: pricing.NewService(rows, nil, *logger.NewNop()), logger: *logger.NewNop()}
+
+	if err := svc.validatePricing(context.Background(), 1, "gpt-4o"); err != nil {
+		t.Errorf("Expected a model template to satisfy pricing validation, got %v", err)
+	}
+	if err := svc.validatePricing(context.Background(), 1, "gpt-3.5"); err == nil {
+		t.Error("Expected a model with neither config pricing nor template to be rejected")
+	}
+}
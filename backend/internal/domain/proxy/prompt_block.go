@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/promptblock"
+	"api-aggregator/backend/pkg/response"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBlockReason 屏蔽规则未填写说明时返回给客户端的提示
+const defaultBlockReason = "This request matches a blocked prompt and violates the usage policy"
+
+// PromptBlockSource 检查提示词是否命中屏蔽规则
+type PromptBlockSource interface {
+	Check(ctx context.Context, prompts []string) (*promptblock.PromptBlock, error)
+}
+
+// SetPromptBlocklist 设置提示词屏蔽规则来源，未设置时不检查
+func (h *Handler) SetPromptBlocklist(source PromptBlockSource) {
+	h.blocklist = source
+}
+
+// blockedPrompts 参与屏蔽检查的提示词：system 和 user 消息的文本
+func blockedPrompts(req *adapter.ChatRequest) []string {
+	prompts := make([]string, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		if msg.Role != "system" && msg.Role != "user" {
+			continue
+		}
+		if text := adapter.GetContentAsString(msg.Content); text != "" {
+			prompts = append(prompts, text)
+		}
+	}
+	return prompts
+}
+
+// checkPromptBlock 提示词命中屏蔽规则时返回 403 并返回 true，请求不会发往上游
+// 命中的规则记录到请求错误中，供日志中间件输出；检查失败时放行，不影响正常请求
+func (h *Handler) checkPromptBlock(c *gin.Context, req *ProxyRequest) bool {
+	if h.blocklist == nil {
+		return false
+	}
+	block, err := h.blocklist.Check(c.Request.Context(), blockedPrompts(req.ChatRequest))
+	if err != nil || block == nil {
+		return false
+	}
+	_ = c.Error(fmt.Errorf("prompt blocked by rule %d (user %d, api key %d)", block.ID, req.UserID, req.APIKeyID))
+
+	reason := block.Reason
+	if reason == "" {
+		reason = defaultBlockReason
+	}
+	c.JSON(http.StatusForbidden, response.ErrorResponse{
+		Error: response.ErrorDetail{
+			Code:    403003,
+			Message: "Prompt blocked by content policy",
+			Details: reason,
+		},
+	})
+	return true
+}
@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"context"
+	"strings"
+)
+
+// ProviderPreferenceHeader 请求级供应商偏好，逗号分隔的配置类型，按优先级从高到低
+const ProviderPreferenceHeader = "X-Prism-Provider-Preference"
+
+// knownProviderTypes 可在偏好中使用的配置类型
+var knownProviderTypes = map[string]bool{
+	"openai":    true,
+	"anthropic": true,
+	"gemini":    true,
+	"kiro":      true,
+	"custom":    true,
+}
+
+// parseProviderPreference 解析供应商偏好，忽略未知类型和重复项
+func parseProviderPreference(header string) []string {
+	var preference []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		provider := strings.ToLower(strings.TrimSpace(part))
+		if !knownProviderTypes[provider] || seen[provider] {
+			continue
+		}
+		seen[provider] = true
+		preference = append(preference, provider)
+	}
+	return preference
+}
+
+// isConfigHealthy 配置是否可用：已启用，账号池配置还要求至少一个账号池健康
+func (s *service) isConfigHealthy(ctx context.Context, cfg *apiconfig.APIConfig) bool {
+	if !cfg.IsValid() {
+		return false
+	}
+	pools := configPools(cfg)
+	if !cfg.IsAccountPool() || len(pools) == 0 || s.poolManager == nil {
+		return true
+	}
+	for _, pool := range pools {
+		if s.poolManager.IsPoolHealthy(ctx, pool.PoolID) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferredConfigs 按偏好顺序返回第一个有健康配置的供应商的全部健康配置
+// 没有任何偏好供应商可用时返回 nil
+func (s *service) preferredConfigs(ctx context.Context, configs []*apiconfig.APIConfig, preference []string) []*apiconfig.APIConfig {
+	for _, provider := range preference {
+		var matched []*apiconfig.APIConfig
+		for _, cfg := range configs {
+			if cfg.Type == provider && s.isConfigHealthy(ctx, cfg) {
+				matched = append(matched, cfg)
+			}
+		}
+		if len(matched) > 0 {
+			return matched
+		}
+	}
+	return nil
+}
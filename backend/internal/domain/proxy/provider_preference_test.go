@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/loadbalancer"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// noLBConfig 没有负载均衡配置，多个候选时取第一个
+type noLBConfig struct {
+	loadbalancer.Service
+}
+
+func (noLBConfig) GetConfigByModel(ctx context.Context, model string) (*loadbalancer.ConfigResponse, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+func newPreferenceTestService(configs ...*apiconfig.APIConfig) *service {
+	return &service{
+		apiConfigRepo:   &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"claude": configs}},
+		loadBalancerSvc: noLBConfig{},
+		logger:          *logger.NewNop(),
+	}
+}
+
+func TestParseProviderPreference(t *testing.T) {
+	got := parseProviderPreference(" Anthropic, bedrock ,gemini,anthropic,,OPENAI")
+	want := []string{"anthropic", "gemini", "openai"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := parseProviderPreference("bogus"); got != nil {
+		t.Errorf("Expected invalid preference ignored, got %v", got)
+	}
+}
+
+func TestSelectAPIConfig_ProviderPreference(t *testing.T) {
+	openai := &apiconfig.APIConfig{ID: 1, Type: "openai", IsActive: true}
+	anthropic := &apiconfig.APIConfig{ID: 2, Type: "anthropic", IsActive: true}
+	gemini := &apiconfig.APIConfig{ID: 3, Type: "gemini", IsActive: true}
+	downAnthropic := &apiconfig.APIConfig{ID: 4, Type: "anthropic", IsActive: false}
+
+	tests := []struct {
+		name       string
+		configs    []*apiconfig.APIConfig
+		preference []string
+		want       uint
+	}{
+		{"no preference uses default order", []*apiconfig.APIConfig{openai, anthropic, gemini}, nil, 1},
+		{"preference reorders selection", []*apiconfig.APIConfig{openai, anthropic, gemini}, []string{"anthropic", "gemini"}, 2},
+		{"falls through when preferred provider is down", []*apiconfig.APIConfig{openai, downAnthropic, gemini}, []string{"anthropic", "gemini"}, 3},
+		{"falls back to default when no preferred provider is available", []*apiconfig.APIConfig{openai, downAnthropic}, []string{"anthropic", "kiro"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newPreferenceTestService(tt.configs...)
+			cfg, err := svc.selectAPIConfig(context.Background(), "claude", tt.preference, "", nil)
+			if err != nil {
+				t.Fatalf("selectAPIConfig failed: %v", err)
+			}
+			if cfg.ID != tt.want {
+				t.Errorf("Expected config %d, got %d (%s)", tt.want, cfg.ID, cfg.Type)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuotaHoldHeader 请求头引用 POST /api/v1/user/quota/hold 创建的配额预留
+// 请求使用预留的配额代替按 max_tokens 的预扣，结束时按实际用量多退少补
+const QuotaHoldHeader = "X-Prism-Quota-Hold"
+
+// parseQuotaHold 读取请求引用的预留 ID
+func parseQuotaHold(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader(QuotaHoldHeader))
+}
+
+// consumeQuotaHold 使用请求引用的预留作为本次请求的预扣配额
+// 预留只能被一个请求使用，不可用时返回 ErrQuotaHoldUnavailable
+func (s *service) consumeQuotaHold(ctx context.Context, req *ProxyRequest) error {
+	amount, err := s.quotaService.ConsumeHold(ctx, req.UserID, req.HoldID)
+	if err != nil {
+		return err
+	}
+	req.Reserved = amount
+	s.log(ctx).Info("✓ Quota hold consumed",
+		logger.Uint("user_id", req.UserID),
+		logger.String("hold_id", req.HoldID),
+		logger.Int64("reserved", amount))
+	return nil
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/quota"
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// holdQuota 记录可用的配额预留，预留只能被使用一次
+type holdQuota struct {
+	openQuota
+	holds map[string]int64
+}
+
+func (q *holdQuota) GetQuotaInfo(ctx context.Context, userID uint) (*quota.QuotaInfoResponse, error) {
+	// 剩余配额已全部被预留占用
+	return &quota.QuotaInfoResponse{TotalQuota: 100, UsedQuota: 100}, nil
+}
+
+func (q *holdQuota) ConsumeHold(ctx context.Context, userID uint, holdID string) (int64, error) {
+	amount, ok := q.holds[holdID]
+	if !ok {
+		return 0, errors.ErrQuotaHoldUnavailable
+	}
+	delete(q.holds, holdID)
+	return amount, nil
+}
+
+func TestChatCompletions_ConsumesQuotaHoldAndReleasesUnused(t *testing.T) {
+	healthy := &recordingUpstream{status: http.StatusOK}
+	upstream := httptest.NewServer(healthy)
+	defer upstream.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	q := &holdQuota{holds: map[string]int64{"hold_1": 100}}
+	svc.quotaService = q
+
+	req := failoverRequest()
+	req.HoldID = "hold_1"
+	if _, err := svc.ChatCompletions(context.Background(), req); err != nil {
+		t.Fatalf("Expected the hold to cover the request, got %v", err)
+	}
+	// 实际费用 42，预留的 100 退还 58
+	if q.deducted != 0 || q.released != 58 || req.Reserved != 0 {
+		t.Errorf("Expected 58 of the hold released, deducted %d released %d", q.deducted, q.released)
+	}
+
+	reuse := failoverRequest()
+	reuse.HoldID = "hold_1"
+	if _, err := svc.ChatCompletions(context.Background(), reuse); !errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		t.Errorf("Expected a used hold to be rejected, got %v", err)
+	}
+	if len(healthy.bodies) != 1 {
+		t.Errorf("Expected no upstream call for the rejected hold, got %d calls", len(healthy.bodies))
+	}
+}
+
+func TestChatCompletions_ReleasesQuotaHoldOnUpstreamFailure(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusServiceUnavailable})
+	defer upstream.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	q := &holdQuota{holds: map[string]int64{"hold_1": 100}}
+	svc.quotaService = q
+
+	req := failoverRequest()
+	req.HoldID = "hold_1"
+	if _, err := svc.ChatCompletions(context.Background(), req); err == nil {
+		t.Fatal("Expected the upstream failure to be returned")
+	}
+	if q.released != 100 || q.deducted != 0 {
+		t.Errorf("Expected the whole hold released, deducted %d released %d", q.deducted, q.released)
+	}
+}
+
+func TestChatCompletionsStream_ConsumesQuotaHold(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusServiceUnavailable})
+	defer upstream.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	q := &holdQuota{holds: map[string]int64{"hold_1": 500}}
+	svc.quotaService = q
+
+	// 未启用预扣的层级也使用预留，开流失败时全部退还
+	req := prechargeRequest(2000, "")
+	req.HoldID = "hold_1"
+	if _, err := svc.ChatCompletionsStream(context.Background(), req); err == nil {
+		t.Fatal("Expected the upstream failure to be returned")
+	}
+	if _, ok := q.holds["hold_1"]; ok || q.released != 500 || q.deducted != 0 {
+		t.Errorf("Expected the hold consumed and released, deducted %d released %d", q.deducted, q.released)
+	}
+}
@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/redact"
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// redactResponse 对非流式响应的助手内容脱敏，返回副本和脱敏次数
+// 不修改原响应，避免与异步写缓存产生竞争
+func redactResponse(r *redact.Redactor, resp *adapter.ChatResponse) (*adapter.ChatResponse, int) {
+	if r == nil || resp == nil {
+		return resp, 0
+	}
+
+	out := *resp
+	out.Choices = make([]adapter.ChatChoice, len(resp.Choices))
+	total := 0
+	for i, choice := range resp.Choices {
+		content := adapter.GetContentAsString(choice.Message.Content)
+		if content != "" {
+			redacted, n := r.Redact(content)
+			if n > 0 {
+				choice.Message.Content = redacted
+				total += n
+			}
+		}
+		out.Choices[i] = choice
+	}
+	return &out, total
+}
+
+// sseRedactor 对 OpenAI 格式的 SSE 流逐行脱敏
+// 每个 choice 维护独立的缓冲，跨块的敏感信息在后续块中一并处理
+type sseRedactor struct {
+	redactor *redact.Redactor
+	streams  map[int]*redact.Stream
+}
+
+// newSSERedactor 创建 SSE 流脱敏器
+func newSSERedactor(r *redact.Redactor) *sseRedactor {
+	return &sseRedactor{
+		redactor: r,
+		streams:  make(map[int]*redact.Stream),
+	}
+}
+
+// Process 处理一行 SSE 数据，返回需要输出的行（可能为 0 行或多行）
+func (s *sseRedactor) Process(line []byte) [][]byte {
+	trimmed := bytes.TrimSpace(line)
+	if !bytes.HasPrefix(trimmed, []byte("data: ")) {
+		return [][]byte{line}
+	}
+	data := bytes.TrimPrefix(trimmed, []byte("data: "))
+
+	// 流结束前输出剩余缓冲
+	if string(data) == "[DONE]" {
+		if flush := s.flushChunk(); flush != nil {
+			return [][]byte{flush, []byte("\n"), line}
+		}
+		return [][]byte{line}
+	}
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return [][]byte{line}
+	}
+	choices, ok := chunk["choices"].([]interface{})
+	if !ok {
+		return [][]byte{line}
+	}
+
+	for i, raw := range choices {
+		choice, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		index := i
+		if idx, ok := choice["index"].(float64); ok {
+			index = int(idx)
+		}
+		stream := s.stream(index)
+
+		delta, _ := choice["delta"].(map[string]interface{})
+		content := ""
+		if delta != nil {
+			content, _ = delta["content"].(string)
+		}
+		out := stream.Write(content)
+		if choice["finish_reason"] != nil {
+			out += stream.Flush()
+		}
+		if content != "" || out != "" {
+			if delta == nil {
+				delta = map[string]interface{}{}
+				choice["delta"] = delta
+			}
+			delta["content"] = out
+		}
+	}
+
+	encoded, err := json.Marshal(chunk)
+	if err != nil {
+		return [][]byte{line}
+	}
+	return [][]byte{append(append([]byte("data: "), encoded...), '\n')}
+}
+
+// Count 返回累计脱敏次数
+func (s *sseRedactor) Count() int {
+	total := 0
+	for _, stream := range s.streams {
+		total += stream.Count()
+	}
+	return total
+}
+
+// stream 获取指定 choice 的流式脱敏器
+func (s *sseRedactor) stream(index int) *redact.Stream {
+	stream, ok := s.streams[index]
+	if !ok {
+		stream = s.redactor.NewStream()
+		s.streams[index] = stream
+	}
+	return stream
+}
+
+// flushChunk 将所有 choice 的剩余缓冲合成一个数据块
+func (s *sseRedactor) flushChunk() []byte {
+	indexes := make([]int, 0, len(s.streams))
+	for index, stream := range s.streams {
+		if stream.Pending() {
+			indexes = append(indexes, index)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	sort.Ints(indexes)
+
+	choices := make([]map[string]interface{}, 0, len(indexes))
+	for _, index := range indexes {
+		choices = append(choices, map[string]interface{}{
+			"index": index,
+			"delta": map[string]interface{}{"content": s.streams[index].Flush()},
+		})
+	}
+	encoded, err := json.Marshal(map[string]interface{}{
+		"object":  "chat.completion.chunk",
+		"choices": choices,
+	})
+	if err != nil {
+		return nil
+	}
+	return append(append([]byte("data: "), encoded...), '\n')
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/redact"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestApplyRedaction_NonStreaming(t *testing.T) {
+	r, _ := redact.New([]string{`ACCT-\d+`})
+	svc := &service{logger: *logger.NewNop()}
+	resp := &adapter.ChatResponse{Choices: []adapter.ChatChoice{{
+		Message: adapter.Message{Role: "assistant", Content: "Account ACCT-42 belongs to jane@corp.io"},
+	}}}
+
+	out := svc.applyRedaction(context.Background(), &ProxyRequest{Redactor: r}, resp)
+
+	got := out.Choices[0].Message.Content.(string)
+	if got != "Account "+redact.Mask+" belongs to "+redact.Mask {
+		t.Errorf("Unexpected redacted content: %s", got)
+	}
+	// 原响应不被修改（可能正在异步写入缓存）
+	if resp.Choices[0].Message.Content != "Account ACCT-42 belongs to jane@corp.io" {
+		t.Error("Expected original response to be left untouched")
+	}
+}
+
+func TestSSERedactor_Streaming(t *testing.T) {
+	r, _ := redact.New([]string{`ACCT-\d+`})
+	sr := newSSERedactor(r)
+
+	chunk := func(content string, finish interface{}) []byte {
+		b, _ := json.Marshal(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"index": 0, "delta": map[string]interface{}{"content": content}, "finish_reason": finish,
+			}},
+		})
+		return append(append([]byte("data: "), b...), '\n')
+	}
+
+	var lines [][]byte
+	lines = append(lines, sr.Process(chunk("Your account is ACC", nil))...)
+	lines = append(lines, sr.Process(chunk("T-9981, email me at sam@", nil))...)
+	lines = append(lines, sr.Process(chunk("mail.com.", "stop"))...)
+	lines = append(lines, sr.Process([]byte("data: [DONE]\n"))...)
+
+	var content strings.Builder
+	for _, line := range lines {
+		data := strings.TrimSpace(strings.TrimPrefix(string(line), "data: "))
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var c adapter.ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatalf("Invalid chunk %q: %v", data, err)
+		}
+		content.WriteString(c.Choices[0].Delta.Content)
+	}
+
+	want := "Your account is " + redact.Mask + ", email me at " + redact.Mask + "."
+	if content.String() != want {
+		t.Errorf("Unexpected streamed content:\n got %q\nwant %q", content.String(), want)
+	}
+	if sr.Count() != 2 {
+		t.Errorf("Expected 2 redactions, got %d", sr.Count())
+	}
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChatCompletions_RefusalBillingIsConfigurable(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"message":{"role":"assistant","content":null,"refusal":"I can't help with that."},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":5,"total_tokens":10}}`))
+	}))
+	defer upstream.Close()
+
+	for _, billed := range []bool{true, false} {
+		svc, logs := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+		svc.runtimeConfig.Get().BillRefusals = billed
+		q := &openQuota{}
+		svc.quotaService = q
+
+		resp, err := svc.ChatCompletions(context.Background(), failoverRequest())
+		if err != nil {
+			t.Fatalf("Expected the refusal returned as a response, got %v", err)
+		}
+		if resp.Refusal == nil || resp.Choices[0].FinishReason != "content_filter" {
+			t.Fatalf("Expected a content_filter refusal, got %+v", resp.Choices)
+		}
+		want := int64(0)
+		if billed {
+			want = 42
+		}
+		if q.deducted != want || q.refundCalls != 0 {
+			t.Errorf("billed=%v: expected %d deducted and no refund, got %d (%d refunds)", billed, want, q.deducted, q.refundCalls)
+		}
+		if len(logs.created) != 1 {
+			t.Errorf("billed=%v: expected the refusal logged once, got %d logs", billed, len(logs.created))
+		}
+	}
+}
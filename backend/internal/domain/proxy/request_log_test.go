@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestChatCompletions_LogsCarryRequestFields(t *testing.T) {
+	failing, healthy := httptest.NewServer(&recordingUpstream{status: http.StatusServiceUnavailable}), httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer failing.Close()
+	defer healthy.Close()
+	svc, _ := newFailoverTestService(1, failoverConfig(1, failing.URL, 0), failoverConfig(2, healthy.URL, 0))
+
+	// 与 RequestID、APIKey 中间件相同：请求日志带 request_id，认证后追加用户和密钥
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := logger.NewContext(context.Background(), logger.FromZap(zap.New(core)).With(logger.String("request_id", "req-7")))
+	logger.AddFields(ctx, logger.Uint("user_id", 1), logger.Uint("api_key_id", 5))
+
+	req := failoverRequest()
+	req.APIKeyID = 5
+	if _, err := svc.ChatCompletions(ctx, req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs.Len() == 0 {
+		t.Fatal("Expected the request to be logged through the request logger")
+	}
+
+	selected := false
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-7" || fields["user_id"] != uint64(1) || fields["api_key_id"] != uint64(5) || fields["model"] != "gpt-4" {
+			t.Errorf("%q: expected request, user, key and model fields, got %v", entry.Message, fields)
+		}
+		if entry.Message == "✓ API config selected" {
+			selected = true
+		}
+		if selected && fields["api_config_id"] == nil {
+			t.Errorf("%q: expected the selected config after selection, got %v", entry.Message, fields)
+		}
+	}
+
+	last := logs.All()[logs.Len()-1].ContextMap()
+	if last["api_config_id"] != uint64(2) {
+		t.Errorf("Expected the failover config on later lines, got %v", last["api_config_id"])
+	}
+}
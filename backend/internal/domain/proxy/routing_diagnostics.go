@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"sort"
+	"strings"
+)
+
+// RoutingDiagnosticsHeader 请求级路由诊断开关：为 true 且运行时配置 runtime.routing_diagnostics 开启时，响应附带 prism_routing
+const RoutingDiagnosticsHeader = "X-Prism-Routing"
+
+// 选择上游配置的策略，负载均衡和路由模式直接使用各自的策略名
+const (
+	RoutingStrategyEcho   = "echo"   // 内置测试模型
+	RoutingStrategyCanary = "canary" // 灰度配置
+	RoutingStrategySingle = "single" // 只有一个可用配置
+	RoutingStrategyFirst  = "first"  // 未配置负载均衡，使用第一个可用配置
+	RoutingStrategyPinned = "pinned" // 多候选请求固定使用的配置
+)
+
+// RoutingInfo 一次请求的路由诊断信息，以 prism_routing 扩展返回给调用方
+type RoutingInfo struct {
+	ConfigID        uint   `json:"config_id,omitempty"`
+	ConfigName      string `json:"config_name,omitempty"`
+	Provider        string `json:"provider,omitempty"`
+	Strategy        string `json:"strategy,omitempty"`
+	CacheHit        bool   `json:"cache_hit"`
+	Retries         int    `json:"retries"`
+	Fallback        bool   `json:"fallback"`
+	FailedConfigIDs []uint `json:"failed_config_ids,omitempty"`
+}
+
+// parseRoutingDiagnostics 请求头是否要求返回路由诊断信息，无效值视为不要求
+func parseRoutingDiagnostics(header string) bool {
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "true", "1", "on":
+		return true
+	}
+	return false
+}
+
+// recordRouting 请求要求且运行时配置允许时，记录路由诊断信息供处理器输出
+func (s *service) recordRouting(req *ProxyRequest, info *RoutingInfo) {
+	if !req.RoutingDiagnostics || !s.runtimeConfig.Get().IsRoutingDiagnosticsEnabled() {
+		return
+	}
+	req.Routing = info
+}
+
+// upstreamRouting 根据最终成功的上游调用生成路由诊断信息
+// 重试次数包括换用其他配置和空响应重试，失败过的配置按 ID 升序列出
+func upstreamRouting(attempt *upstreamAttempt, attempts int, tried map[uint]bool) *RoutingInfo {
+	info := &RoutingInfo{
+		ConfigID:   attempt.apiConfig.ID,
+		ConfigName: attempt.apiConfig.Name,
+		Provider:   attempt.apiConfig.Type,
+		Strategy:   attempt.strategy,
+		Retries:    attempts - 1,
+		Fallback:   len(tried) > 0,
+	}
+	for id := range tried {
+		info.FailedConfigIDs = append(info.FailedConfigIDs, id)
+	}
+	sort.Slice(info.FailedConfigIDs, func(i, j int) bool { return info.FailedConfigIDs[i] < info.FailedConfigIDs[j] })
+	return info
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routingGateway 以真实服务处理请求的网关，上游按配置顺序排列
+func routingGateway(t *testing.T, enabled bool, configs ...*apiconfig.APIConfig) *httptest.Server {
+	t.Helper()
+	svc, _ := newFailoverTestService(1, configs...)
+	svc.runtimeConfig.Get().RoutingDiagnostics = enabled
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Next()
+	}, NewHandler(svc).ChatCompletionsOpenAI)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway
+}
+
+// postWithRouting 发送聊天请求并返回响应中的 prism_routing，未返回时为 nil
+func postWithRouting(t *testing.T, gateway *httptest.Server, header string) *RoutingInfo {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, gateway.URL+"/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(RoutingDiagnosticsHeader, header)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the request served, got %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Choices []json.RawMessage `json:"choices"`
+		Routing *RoutingInfo      `json:"prism_routing"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Choices) != 1 {
+		t.Errorf("Expected the original response fields kept, got %d choices", len(body.Choices))
+	}
+	return body.Routing
+}
+
+func TestRoutingDiagnostics_PresentOnlyWhenRequestedAndEnabled(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer upstream.Close()
+	cfg := failoverConfig(1, upstream.URL, 0)
+	cfg.Name = "primary"
+
+	enabled := routingGateway(t, true, cfg)
+	routing := postWithRouting(t, enabled, "true")
+	if routing == nil {
+		t.Fatal("Expected prism_routing when requested and enabled")
+	}
+	if routing.ConfigID != 1 || routing.ConfigName != "primary" || routing.Provider != "openai" || routing.Strategy != RoutingStrategySingle {
+		t.Errorf("Expected the selected config described, got %+v", routing)
+	}
+	if routing.CacheHit || routing.Retries != 0 || routing.Fallback {
+		t.Errorf("Expected a first-try upstream call, got %+v", routing)
+	}
+	if routing := postWithRouting(t, enabled, ""); routing != nil {
+		t.Errorf("Expected no prism_routing without the header, got %+v", routing)
+	}
+
+	disabled := routingGateway(t, false, cfg)
+	if routing := postWithRouting(t, disabled, "true"); routing != nil {
+		t.Errorf("Expected no prism_routing while the setting is off, got %+v", routing)
+	}
+}
+
+func TestRoutingDiagnostics_ReflectsFailover(t *testing.T) {
+	s1 := httptest.NewServer(&recordingUpstream{status: http.StatusServiceUnavailable})
+	s2 := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer s1.Close()
+	defer s2.Close()
+
+	gateway := routingGateway(t, true, failoverConfig(1, s1.URL, 0), failoverConfig(2, s2.URL, 0))
+	routing := postWithRouting(t, gateway, "1")
+	if routing == nil {
+		t.Fatal("Expected prism_routing on a failed-over request")
+	}
+	if routing.ConfigID != 2 || routing.Retries != 1 || !routing.Fallback {
+		t.Errorf("Expected one retry falling over to config 2, got %+v", routing)
+	}
+	if len(routing.FailedConfigIDs) != 1 || routing.FailedConfigIDs[0] != 1 {
+		t.Errorf("Expected config 1 listed as failed, got %v", routing.FailedConfigIDs)
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestPriorityHeader 请求级优先级：low 按成本选择配置，high 按延迟选择配置，覆盖 API Key 的路由模式
+const RequestPriorityHeader = "X-Prism-Priority"
+
+// latencySmoothing 配置延迟指数移动平均中最新一次调用的权重
+const latencySmoothing = 0.2
+
+// resolveRoutingMode 确定请求的路由模式，请求头优先于 API Key 配置
+func resolveRoutingMode(priority, keyMode string) string {
+	switch strings.ToLower(strings.TrimSpace(priority)) {
+	case "low":
+		return apikey.RoutingModeCost
+	case "high":
+		return apikey.RoutingModeLatency
+	}
+	return keyMode
+}
+
+// latencyTracker 记录各配置非流式调用耗时的指数移动平均
+type latencyTracker struct {
+	mu      sync.RWMutex
+	average map[uint]float64 // 配置 ID -> 平均耗时（毫秒）
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{average: make(map[uint]float64)}
+}
+
+// Observe 记录一次成功调用的耗时
+func (t *latencyTracker) Observe(apiConfigID uint, d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if avg, ok := t.average[apiConfigID]; ok {
+		ms = avg + latencySmoothing*(ms-avg)
+	}
+	t.average[apiConfigID] = ms
+}
+
+// Average 返回配置的平均耗时，尚无记录时返回 false
+func (t *latencyTracker) Average(apiConfigID uint) (float64, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	avg, ok := t.average[apiConfigID]
+	return avg, ok
+}
+
+// healthyConfigs 返回健康的候选配置，全部不健康时返回原列表
+func (s *service) healthyConfigs(ctx context.Context, configs []*apiconfig.APIConfig) []*apiconfig.APIConfig {
+	healthy := make([]*apiconfig.APIConfig, 0, len(configs))
+	for _, cfg := range configs {
+		if s.isConfigHealthy(ctx, cfg) {
+			healthy = append(healthy, cfg)
+		}
+	}
+	if len(healthy) == 0 {
+		return configs
+	}
+	return healthy
+}
+
+// selectByRoutingMode 按路由模式在健康候选中选择配置，无法判断时返回 nil，由负载均衡策略选择
+func (s *service) selectByRoutingMode(ctx context.Context, model, mode string, configs []*apiconfig.APIConfig) *apiconfig.APIConfig {
+	switch mode {
+	case apikey.RoutingModeCost:
+		return s.cheapestConfig(ctx, model, s.healthyConfigs(ctx, configs))
+	case apikey.RoutingModeLatency:
+		return s.fastestConfig(s.healthyConfigs(ctx, configs))
+	default:
+		return nil
+	}
+}
+
+// cheapestConfig 返回每 token 价格（输入与输出单价之和）最低的配置，价格相同时保持候选顺序
+// 没有定价的配置不参与比较
+func (s *service) cheapestConfig(ctx context.Context, model string, configs []*apiconfig.APIConfig) *apiconfig.APIConfig {
+	pricings, err := s.pricingService.GetPricingsByModel(ctx, model)
+	if err != nil {
+		s.log(ctx).Warn("Failed to load pricing for cost routing", logger.String("model", model), logger.Error(err))
+		return nil
+	}
+	perToken := make(map[uint]float64, len(pricings))
+	for _, p := range pricings {
+		if !p.IsActive || p.Unit <= 0 {
+			continue
+		}
+		perToken[p.APIConfigID] = (p.InputPrice + p.OutputPrice) / float64(p.Unit)
+	}
+
+	var cheapest *apiconfig.APIConfig
+	var lowest float64
+	for _, cfg := range configs {
+		price, ok := perToken[cfg.ID]
+		if !ok {
+			continue
+		}
+		if cheapest == nil || price < lowest {
+			cheapest, lowest = cfg, price
+		}
+	}
+	return cheapest
+}
+
+// fastestConfig 返回平均耗时最低的配置
+// 尚无耗时记录的配置优先，以便取得第一次测量
+func (s *service) fastestConfig(configs []*apiconfig.APIConfig) *apiconfig.APIConfig {
+	if s.latency == nil {
+		return nil
+	}
+	var fastest *apiconfig.APIConfig
+	var lowest float64
+	for _, cfg := range configs {
+		avg, ok := s.latency.Average(cfg.ID)
+		if !ok {
+			return cfg
+		}
+		if fastest == nil || avg < lowest {
+			fastest, lowest = cfg, avg
+		}
+	}
+	return fastest
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"testing"
+	"time"
+)
+
+// modelPricing 按模型返回各配置的定价
+type modelPricing struct {
+	pricing.Service
+	pricings []*pricing.PricingResponse
+}
+
+func (p *modelPricing) GetPricingsByModel(ctx context.Context, modelName string) ([]*pricing.PricingResponse, error) {
+	return p.pricings, nil
+}
+
+func newRoutingTestService(configs []*apiconfig.APIConfig, pricings ...*pricing.PricingResponse) *service {
+	return &service{
+		apiConfigRepo:   &fakeConfigRepo{byModel: map[string][]*apiconfig.APIConfig{"gpt-4o": configs}},
+		loadBalancerSvc: noLBConfig{},
+		pricingService:  &modelPricing{pricings: pricings},
+		latency:         newLatencyTracker(),
+		logger:          *logger.NewNop(),
+	}
+}
+
+func price(configID uint, input, output float64) *pricing.PricingResponse {
+	return &pricing.PricingResponse{APIConfigID: configID, ModelName: "gpt-4o", InputPrice: input, OutputPrice: output, Unit: 1000, IsActive: true}
+}
+
+func TestSelectAPIConfig_CostModePicksCheapestHealthyConfig(t *testing.T) {
+	premium := &apiconfig.APIConfig{ID: 1, Type: "openai", IsActive: true}
+	cheapDown := &apiconfig.APIConfig{ID: 2, Type: "openai", IsActive: false}
+	budget := &apiconfig.APIConfig{ID: 3, Type: "custom", IsActive: true}
+	unpriced := &apiconfig.APIConfig{ID: 4, Type: "custom", IsActive: true}
+	svc := newRoutingTestService([]*apiconfig.APIConfig{premium, cheapDown, budget, unpriced},
+		price(1, 5, 15), price(2, 0.1, 0.2), price(3, 1, 3))
+
+	cfg, err := svc.selectAPIConfig(context.Background(), "gpt-4o", nil, apikey.RoutingModeCost, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ID != 3 {
+		t.Errorf("Expected the cheapest healthy config 3, got %d", cfg.ID)
+	}
+
+	// 不启用成本路由时按默认顺序选择
+	cfg, _ = svc.selectAPIConfig(context.Background(), "gpt-4o", nil, "", nil)
+	if cfg.ID != 1 {
+		t.Errorf("Expected default selection of config 1, got %d", cfg.ID)
+	}
+}
+
+func TestSelectAPIConfig_LatencyModePicksFastestConfig(t *testing.T) {
+	slow := &apiconfig.APIConfig{ID: 1, Type: "openai", IsActive: true}
+	fast := &apiconfig.APIConfig{ID: 2, Type: "openai", IsActive: true}
+	svc := newRoutingTestService([]*apiconfig.APIConfig{slow, fast}, price(1, 1, 1), price(2, 9, 9))
+
+	// 尚无测量的配置优先，取得第一次测量
+	svc.latency.Observe(1, 900*time.Millisecond)
+	if cfg, _ := svc.selectAPIConfig(context.Background(), "gpt-4o", nil, apikey.RoutingModeLatency, nil); cfg.ID != 2 {
+		t.Fatalf("Expected the unmeasured config first, got %d", cfg.ID)
+	}
+
+	svc.latency.Observe(2, 200*time.Millisecond)
+	if cfg, _ := svc.selectAPIConfig(context.Background(), "gpt-4o", nil, apikey.RoutingModeLatency, nil); cfg.ID != 2 {
+		t.Errorf("Expected the faster config 2, got %d", cfg.ID)
+	}
+	if cfg, _ := svc.selectAPIConfig(context.Background(), "gpt-4o", nil, apikey.RoutingModeCost, nil); cfg.ID != 1 {
+		t.Errorf("Expected cost mode to still pick the cheaper config 1, got %d", cfg.ID)
+	}
+}
+
+func TestResolveRoutingMode(t *testing.T) {
+	tests := []struct {
+		priority, keyMode, want string
+	}{
+		{"", "", ""},
+		{"", apikey.RoutingModeLatency, apikey.RoutingModeLatency},
+		{"low", "", apikey.RoutingModeCost},
+		{" LOW ", apikey.RoutingModeLatency, apikey.RoutingModeCost},
+		{"high", apikey.RoutingModeCost, apikey.RoutingModeLatency},
+		{"urgent", apikey.RoutingModeCost, apikey.RoutingModeCost},
+	}
+	for _, tt := range tests {
+		if got := resolveRoutingMode(tt.priority, tt.keyMode); got != tt.want {
+			t.Errorf("resolveRoutingMode(%q, %q) = %q, want %q", tt.priority, tt.keyMode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"encoding/json"
+	"strings"
+)
+
+// SemanticCacheHeader 请求级语义缓存开关：off 只返回请求完全相同的缓存响应，on 覆盖 API Key 的关闭设置
+const SemanticCacheHeader = "X-Prism-Semantic-Cache"
+
+// resolveSemanticCacheDisabled 确定请求是否跳过语义缓存匹配，请求头优先于 API Key 配置，无效值忽略
+func resolveSemanticCacheDisabled(header string, keyDisabled bool) bool {
+	switch strings.ToLower(strings.TrimSpace(header)) {
+	case "off":
+		return true
+	case "on":
+		return false
+	}
+	return keyDisabled
+}
+
+// usesTools 请求是否带有工具定义或 tool_choice
+// 语义匹配只比较最后一条用户消息，无法区分工具集，带工具的请求只走精确缓存
+func usesTools(req *adapter.ChatRequest) bool {
+	return len(req.Tools) > 0 || req.ToolChoice != nil
+}
+
+// cachedRequestUsesTools 缓存记录保存的请求是否带有工具定义或 tool_choice，其响应可能是工具调用，不作为语义匹配结果
+func cachedRequestUsesTools(item *cache.RequestCache) bool {
+	var stored struct {
+		Tools      []json.RawMessage `json:"tools"`
+		ToolChoice json.RawMessage   `json:"tool_choice"`
+	}
+	if item.Request == "" || json.Unmarshal([]byte(item.Request), &stored) != nil {
+		return false
+	}
+	return len(stored.Tools) > 0 || (len(stored.ToolChoice) > 0 && string(stored.ToolChoice) != "null")
+}
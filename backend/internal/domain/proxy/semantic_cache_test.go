@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/cache"
+	"api-aggregator/backend/pkg/embedding"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// semanticCache 内存缓存：按键精确匹配，语义匹配返回全部记录
+type semanticCache struct {
+	cache.Service
+	items map[string]*cache.RequestCache
+}
+
+func (c *semanticCache) FindByCacheKey(ctx context.Context, cacheKey string) (*cache.RequestCache, error) {
+	return c.items[cacheKey], nil
+}
+
+func (c *semanticCache) FindByUserAndModel(ctx context.Context, userID uint, model string) ([]*cache.RequestCache, error) {
+	var found []*cache.RequestCache
+	for _, item := range c.items {
+		found = append(found, item)
+	}
+	return found, nil
+}
+
+func (c *semanticCache) IncrementHitCount(ctx context.Context, id uint) error {
+	return nil
+}
+
+func semanticRequest(content string) *ProxyRequest {
+	return &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		ChatRequest: &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{{Role: "user", Content: content}}},
+	}
+}
+
+func TestCheckCache_SemanticCacheOffOnlyServesExactMatches(t *testing.T) {
+	// embedding 服务对任何文本返回同一向量，语义上所有提示词都相近
+	embedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(embedding.EmbedResponse{Embedding: []float64{1, 0}})
+	}))
+	defer embedServer.Close()
+
+	rc := runtime.NewManager(nil)
+	cfg := rc.Get()
+	cfg.CacheEnabled = true
+	cfg.SemanticEnabled = true
+	cfg.SemanticThreshold = 0.9
+	svc := &service{runtimeConfig: rc, logger: *logger.NewNop()}
+	svc.SetEmbeddingClient(embedding.NewClient(embedServer.URL, time.Second))
+
+	cachedResp, _ := json.Marshal(&adapter.ChatResponse{
+		Choices: []adapter.ChatChoice{{Message: adapter.Message{Role: "assistant", Content: "Paris"}}},
+	})
+	exactKey := svc.generateCacheKey(semanticRequest("What is the capital of France?").ChatRequest)
+	svc.cacheService = &semanticCache{items: map[string]*cache.RequestCache{
+		exactKey: {ID: 1, CacheKey: exactKey, Response: string(cachedResp), Embedding: "[1,0]", ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	check := func(content string, noSemantic bool) *adapter.ChatResponse {
+		req := semanticRequest(content)
+		req.NoSemanticCache = noSemantic
+		resp, err := svc.checkCache(context.Background(), req, svc.generateCacheKey(req.ChatRequest))
+		if err != nil {
+			t.Fatalf("checkCache failed: %v", err)
+		}
+		return resp
+	}
+
+	if check("What's the capital city of France?", false) == nil {
+		t.Fatal("Expected a similar prompt served from the semantic cache by default")
+	}
+	if check("What's the capital city of France?", true) != nil {
+		t.Error("Expected a similar but not identical prompt not served from cache with the semantic cache off")
+	}
+	if check("What is the capital of France?", true) == nil {
+		t.Error("Expected an identical prompt still served from the exact-match cache")
+	}
+}
+
+func TestCheckCache_SemanticCacheSkipsToolRequests(t *testing.T) {
+	embedServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(embedding.EmbedResponse{Embedding: []float64{1, 0}})
+	}))
+	defer embedServer.Close()
+
+	rc := runtime.NewManager(nil)
+	cfg := rc.Get()
+	cfg.CacheEnabled = true
+	cfg.SemanticEnabled = true
+	cfg.SemanticThreshold = 0.9
+	svc := &service{runtimeConfig: rc, logger: *logger.NewNop()}
+	svc.SetEmbeddingClient(embedding.NewClient(embedServer.URL, time.Second))
+
+	tools := []adapter.Tool{{Type: "function", Function: adapter.ToolFunction{Name: "get_weather"}}}
+	toolCallResp, _ := json.Marshal(&adapter.ChatResponse{
+		Choices: []adapter.ChatChoice{{Message: adapter.Message{Role: "assistant", ToolCalls: []adapter.ToolCall{{ID: "call_1", Type: "function"}}}}},
+	})
+	toolReq, _ := json.Marshal(&adapter.ChatRequest{Model: "gpt-4", Tools: tools, Messages: []adapter.Message{{Role: "user", Content: "Weather in Paris?"}}})
+	svc.cacheService = &semanticCache{items: map[string]*cache.RequestCache{
+		"tools": {ID: 1, CacheKey: "tools", Request: string(toolReq), Response: string(toolCallResp), Embedding: "[1,0]", ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	plain := semanticRequest("What's the weather in Paris?")
+	if resp, _ := svc.checkCache(context.Background(), plain, svc.generateCacheKey(plain.ChatRequest)); resp != nil {
+		t.Error("Expected a tool call cached for a request with tools not served to a request without tools")
+	}
+	withTools := semanticRequest("What's the weather like in Paris?")
+	withTools.ChatRequest.Tools = tools
+	if resp, _ := svc.checkCache(context.Background(), withTools, svc.generateCacheKey(withTools.ChatRequest)); resp != nil {
+		t.Error("Expected a request with tools served only from the exact-match cache")
+	}
+}
+
+func TestResolveSemanticCacheDisabled(t *testing.T) {
+	cases := []struct {
+		header      string
+		keyDisabled bool
+		want        bool
+	}{
+		{"", false, false},
+		{"", true, true},
+		{"off", false, true},
+		{" OFF ", false, true},
+		{"on", true, false},
+		{"maybe", true, true},
+	}
+	for _, tc := range cases {
+		if got := resolveSemanticCacheDisabled(tc.header, tc.keyDisabled); got != tc.want {
+			t.Errorf("resolveSemanticCacheDisabled(%q, %v) = %v, want %v", tc.header, tc.keyDisabled, got, tc.want)
+		}
+	}
+}
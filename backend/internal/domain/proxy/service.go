@@ -4,22 +4,31 @@ import (
 	"api-aggregator/backend/internal/adapter"
 	"api-aggregator/backend/internal/domain/accountpool"
 	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/domain/apikey"
 	"api-aggregator/backend/internal/domain/cache"
 	"api-aggregator/backend/internal/domain/loadbalancer"
 	"api-aggregator/backend/internal/domain/log"
 	"api-aggregator/backend/internal/domain/pricing"
 	"api-aggregator/backend/internal/domain/quota"
+	"api-aggregator/backend/pkg/alert"
+	"api-aggregator/backend/pkg/archive"
 	"api-aggregator/backend/pkg/embedding"
 	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/logger"
 	"api-aggregator/backend/pkg/runtime"
+	"api-aggregator/backend/pkg/tracing"
 	"api-aggregator/backend/pkg/utils"
 	"context"
 	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Service 代理服务接口
@@ -27,6 +36,18 @@ type Service interface {
 	ChatCompletions(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error)
 	ChatCompletionsStream(ctx context.Context, req *ProxyRequest) (*StreamResponse, error)
 	SetEmbeddingClient(client *embedding.Client)
+	SetArchiver(archiver *archive.Archiver)
+	ActiveStreams() []*ActiveStream
+	ConcurrencyAllocations() []*UpstreamConcurrency
+	TerminateStream(id string) error
+	CancelStream(userID uint, id string) error
+	SetRequestLimiter(limiter RequestLimiter)
+	CreateMessageBatch(userID uint, key *apikey.APIKey, items []*BatchItem) (*MessageBatch, error)
+	GetMessageBatch(userID uint, id string) (*MessageBatch, error)
+	MessageBatchResults(userID uint, id string) ([]*BatchItemResult, error)
+	PreviewPricing(ctx context.Context, apiConfigID uint, req *PricingPreviewRequest) (*PricingPreview, error)
+	Embeddings(ctx context.Context, req *ProxyRequest, embedReq *adapter.EmbeddingRequest) (*EmbeddingsResponse, error)
+	WarmCache(ctx context.Context, entry *cache.RequestCache, billingUserID uint) error
 }
 
 // StreamResponse 流式响应，包含响应体和元数据
@@ -34,6 +55,9 @@ type StreamResponse struct {
 	Response     *http.Response
 	APIConfigID  uint
 	CredentialID uint
+	IdleTimeout  time.Duration // 上游空闲超时，0 表示不限制
+	MaxDuration  time.Duration // 流的最长持续时间，0 表示不限制
+	trace        *streamTrace  // 流关闭时才结束的 span
 }
 
 type service struct {
@@ -41,12 +65,25 @@ type service struct {
 	apiConfigRepo   apiconfig.Repository
 	poolManager     *accountpool.PoolManager
 	loadBalancerSvc loadbalancer.Service
+	selector        *loadbalancer.Selector
 	cacheService    cache.Service
 	quotaService    quota.Service
 	pricingService  pricing.Service
 	logService      log.Service
 	runtimeConfig   *runtime.Manager
 	embeddingClient *embedding.Client
+	alertNotifier   *alert.Notifier
+	archiver        *archive.Archiver
+	revalidating    sync.Map // 正在后台刷新的缓存键
+	prefixes        *prefixStore
+	streams         *streamRegistry
+	batches         *messageBatchStore
+	requestLimiter  RequestLimiter
+	latency         *latencyTracker
+	modelLatency    *modelLatencyStore
+	health          *healthTracker
+	scheduler       *fairScheduler
+	tracer          trace.Tracer // 为空时使用全局 TracerProvider
 	logger          logger.Logger
 }
 
@@ -68,98 +105,343 @@ func NewService(
 		apiConfigRepo:   apiConfigRepo,
 		poolManager:     poolManager,
 		loadBalancerSvc: loadBalancerSvc,
+		selector:        loadbalancer.NewSelector(),
 		cacheService:    cacheService,
 		quotaService:    quotaService,
 		pricingService:  pricingService,
 		logService:      logService,
 		runtimeConfig:   runtimeConfig,
+		alertNotifier:   alert.NewNotifier(5 * time.Second),
+		prefixes:        newPrefixStore(),
+		streams:         newStreamRegistry(),
+		batches:         newMessageBatchStore(messageBatchConcurrency),
+		latency:         newLatencyTracker(),
+		modelLatency:    newModelLatencyStore(),
+		health:          newHealthTracker(),
+		scheduler:       newFairScheduler(),
 		logger:          logger,
 	}
 }
 
+// log 返回请求范围的日志（带 request_id、user_id、model 等字段），请求 context 中没有时使用服务日志
+func (s *service) log(ctx context.Context) *logger.Logger {
+	return logger.FromContext(ctx, &s.logger)
+}
+
+// scopeRequestLog 把请求的用户、密钥和模型记入请求日志
+func scopeRequestLog(ctx context.Context, req *ProxyRequest) {
+	logger.AddFields(ctx, logger.Uint("user_id", req.UserID), logger.Uint("api_key_id", req.APIKeyID), logger.String("model", req.Model))
+}
+
+// scopeConfigLog 把选中的 API 配置记入请求日志，故障转移后覆盖为新的配置
+func scopeConfigLog(ctx context.Context, apiConfig *apiconfig.APIConfig) {
+	logger.AddFields(ctx, logger.Uint("api_config_id", apiConfig.ID), logger.String("api_config", apiConfig.Name))
+}
+
 // SetEmbeddingClient 设置 embedding 客户端
 func (s *service) SetEmbeddingClient(client *embedding.Client) {
 	s.embeddingClient = client
 }
 
-// ChatCompletions 处理聊天补全请求
+// ChatCompletions 处理聊天补全请求，请求开启自动续写时继续请求因 max_tokens 截断的响应，
+// 请求多个候选时按权重分发给多个配置
 func (s *service) ChatCompletions(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	if err := s.checkArchive(); err != nil {
+		return nil, err
+	}
+
+	var resp *adapter.ChatResponse
+	var err error
+	switch {
+	case req.Candidates > 1:
+		resp, err = s.chatCompletionCandidates(ctx, req)
+	case req.MaxContinuations > 0:
+		resp, err = s.chatCompletionWithContinuation(ctx, req)
+	default:
+		resp, err = s.chatCompletion(ctx, req)
+	}
+	s.archiveResponse(req, resp, err)
+	return resp, err
+}
+
+// chatCompletion 在 proxy.chat_completion span 中处理一次聊天补全请求，配额检查、配置选择、上游调用和计费为其子 span
+func (s *service) chatCompletion(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	ctx, span := s.startSpan(ctx, SpanChatCompletion,
+		attribute.String(AttrRequestID, req.RequestID),
+		attribute.String(AttrModel, req.Model))
+	scopeTraceLog(ctx)
+	resp, err := s.serveChatCompletion(ctx, req)
+	tracing.EndSpan(span, err, attribute.Bool(AttrCacheHit, resp != nil && resp.Cached))
+	return resp, err
+}
+
+// serveChatCompletion 处理一次聊天补全请求：缓存、选择配置、调用上游、计费和记录日志
+func (s *service) serveChatCompletion(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
 	startTime := time.Now()
+	scopeRequestLog(ctx, req)
 	
-	s.logger.Info("=== Chat Completion Request Started ===",
-		logger.Uint("user_id", req.UserID),
-		logger.Uint("api_key_id", req.APIKeyID),
-		logger.String("model", req.Model))
+	s.log(ctx).Info("=== Chat Completion Request Started ===")
 	
-	// 1. 检查配额
-	if err := s.checkQuota(ctx, req.UserID); err != nil {
-		s.logger.Error("Quota check failed", logger.Error(err))
+	// 1. 检查配额（引用配额预留的请求由预留保证配额）
+	if req.HoldID == "" {
+		quotaCtx, quotaSpan := s.startSpan(ctx, SpanQuotaCheck)
+		_, err := s.checkQuota(quotaCtx, req.UserID)
+		tracing.EndSpan(quotaSpan, err)
+		if err != nil {
+			s.log(ctx).Error("Quota check failed", logger.Error(err))
+			return nil, err
+		}
+		s.log(ctx).Info("✓ Quota check passed")
+	}
+
+	// 1.4. 按提示词长度路由模型
+	s.applyLengthRouting(ctx, req)
+
+	// 1.5. 按成本上限选择模型
+	if err := s.applyCostCeiling(ctx, req); err != nil {
+		s.log(ctx).Warn("Cost ceiling not satisfied", logger.Error(err))
 		return nil, err
 	}
-	s.logger.Info("✓ Quota check passed")
 
 	// 2. 生成缓存键
-	cacheKey := s.generateCacheKey(req.ChatRequest)
+	cacheKey := req.CacheKey
+	if cacheKey == "" {
+		cacheKey = s.generateCacheKey(req.ChatRequest)
+	}
 	
-	// 3. 查询缓存
-	if s.runtimeConfig.Get().IsCacheEnabled() {
-		cachedResp, err := s.checkCache(ctx, req.UserID, req.Model, cacheKey, req.ChatRequest)
+	// 3. 查询缓存（候选请求要求各配置分别响应，续写请求的内容依赖之前的输出，都不读写缓存）
+	if s.runtimeConfig.Get().IsCacheEnabled() && !req.Revalidate && req.PinnedConfigID == 0 && !req.NoCache {
+		cachedResp, err := s.checkCache(ctx, req, cacheKey)
 		if err != nil {
-			s.logger.Warn("Failed to check cache", logger.Error(err))
+			s.log(ctx).Warn("Failed to check cache", logger.Error(err))
 		} else if cachedResp != nil {
 			// 缓存命中
-			s.logger.Info("✓ Cache hit - returning cached response",
+			s.log(ctx).Info("✓ Cache hit - returning cached response",
 				logger.String("cache_key", cacheKey))
 			
 			cachedResp.Cached = true
-			return cachedResp, nil
+			s.recordRouting(req, &RoutingInfo{CacheHit: true})
+			return s.applyRedaction(ctx, req, cachedResp), nil
+		}
+		s.log(ctx).Info("✓ Cache miss - proceeding with API call")
+	}
+
+	// 3.5. 使用引用的配额预留，请求失败或未计费时退还
+	if req.HoldID != "" {
+		if err := s.consumeQuotaHold(ctx, req); err != nil {
+			return nil, err
+		}
+		defer s.releaseStreamQuota(req)
+	}
+
+	// 4-7. 选择配置并调用上游，上游故障时换用其他配置重试
+	// 每次尝试都从原始请求重新构建，上一次按配置做的截断、摘要、工具裁剪不会带入下一次
+	original := adapter.CloneChatRequest(req.ChatRequest)
+	tried := make(map[uint]bool)
+	emptyRetried := false
+	attempts := 0
+	var (
+		attempt *upstreamAttempt
+		next    *upstreamAttempt
+		err     error
+	)
+	for {
+		next, err = s.callUpstream(ctx, req, tried)
+		if err == errNoFailoverConfig {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		attempt = next
+		attempts++
+		if attempt.err == nil {
+			// 配置开启时，空响应视为软失败再试一次（同一或其他配置），空响应本身不计费
+			if emptyRetried || !attempt.apiConfig.RetryEmptyResponse || !isEmptyCompletion(attempt.resp) || ctx.Err() != nil {
+				break
+			}
+			emptyRetried = true
+			s.logRequest(ctx, req, attempt.apiConfig.ID, 0, 0, time.Since(startTime), errEmptyCompletion, false)
+			s.log(ctx).Warn("→ Upstream returned an empty completion, retrying once",
+				logger.Uint("config_id", attempt.apiConfig.ID))
+			req.ChatRequest = adapter.CloneChatRequest(original)
+			req.ToolsDropped, req.ToolsMerged, req.HistoryDropped = 0, false, 0
+			continue
 		}
-		s.logger.Info("✓ Cache miss - proceeding with API call")
+
+		// 记录失败日志
+		s.logRequest(ctx, req, attempt.apiConfig.ID, 0, 0, time.Since(startTime), attempt.err, false)
+		tried[attempt.apiConfig.ID] = true
+		if len(tried) > s.maxFailoverRetries() || ctx.Err() != nil || !adapter.IsRetryableError(attempt.err) {
+			break
+		}
+		s.log(ctx).Warn("→ Failing over to another API config",
+			logger.Uint("failed_config_id", attempt.apiConfig.ID),
+			logger.Int("attempt", len(tried)+1))
+		req.ChatRequest = adapter.CloneChatRequest(original)
+		req.ToolsDropped, req.ToolsMerged, req.HistoryDropped = 0, false, 0
+	}
+	if attempt.err != nil {
+		return nil, errors.Wrap(attempt.err, 500004, "Failed to call upstream API")
+	}
+	apiConfig, credentialID, resp := attempt.apiConfig, attempt.credentialID, attempt.resp
+	trace.SpanFromContext(ctx).SetAttributes(configAttributes(apiConfig)...)
+	
+	// 如果是账号池，记录成功
+	if apiConfig.IsAccountPool() && credentialID > 0 {
+		s.poolManager.RecordSuccess(ctx, credentialID)
+	}
+	if req.ToolsMerged {
+		unmergeToolCalls(resp)
+	}
+	s.recordRouting(req, upstreamRouting(attempt, attempts, tried))
+	
+	s.log(ctx).Info("✓ Upstream API call succeeded",
+		logger.Int("prompt_tokens", resp.Usage.PromptTokens),
+		logger.Int("completion_tokens", resp.Usage.CompletionTokens),
+		logger.Int("total_tokens", resp.Usage.TotalTokens))
+
+	// 8. 计算费用并扣除配额（必须成功）；后台刷新缓存、内容策略拒绝可配置为不计费
+	s.log(ctx).Info("→ Calculating cost and deducting quota...")
+	billingCtx, billingSpan := s.startSpan(ctx, SpanBilling, configAttributes(apiConfig)...)
+	var cost int
+	var billingErr error
+	if req.Revalidate && !s.refreshBilled(req) {
+		s.log(ctx).Info("✓ Background cache refresh is free of charge")
+	} else if resp.IsRefusal() && !s.runtimeConfig.Get().IsRefusalBilled() {
+		s.log(ctx).Info("✓ Content-policy refusal is free of charge")
+	} else if cost, billingErr = s.settleStreamCost(billingCtx, req, apiConfig.ID, resp.Usage); billingErr != nil {
+		s.log(ctx).Error("✗ CRITICAL: Failed to calculate and deduct cost",
+			logger.Uint("user_id", req.UserID),
+			logger.String("model", req.Model),
+			logger.Error(billingErr))
+		// 扣费失败，记录日志但不返回错误（因为请求已经成功）
+		// 这种情况应该触发告警，需要人工介入
+		s.log(ctx).Error("CRITICAL: Request succeeded but billing failed - manual intervention required",
+			logger.Uint("user_id", req.UserID),
+			logger.Uint("api_config_id", apiConfig.ID),
+			logger.String("model", req.Model),
+			logger.Int("prompt_tokens", resp.Usage.PromptTokens),
+			logger.Int("completion_tokens", resp.Usage.CompletionTokens))
+	} else {
+		s.log(ctx).Info("✓ Cost calculated and quota deducted",
+			logger.Int("cost", cost))
+	}
+	tracing.EndSpan(billingSpan, billingErr, attribute.Int(AttrCost, cost))
+
+	// 8.5. 记录成功（如果使用账号池）
+	if apiConfig.IsAccountPool() && credentialID > 0 {
+		s.poolManager.RecordSuccess(ctx, credentialID)
+		s.log(ctx).Info("✓ Credential success recorded", logger.Uint("credential_id", credentialID))
 	}
 
-	// 4. 选择 API 配置（负载均衡）
-	apiConfig, err := s.selectAPIConfig(ctx, req.Model)
+	// 9. 记录请求日志（需要退款时同步写入以取得日志 ID）
+	degraded := isDegradedResponse(resp)
+	s.log(ctx).Info("→ Creating request log...")
+	logID := s.logRequest(ctx, req, apiConfig.ID, resp.Usage.TotalTokens, cost, time.Since(startTime), nil, degraded)
+	s.log(ctx).Info("✓ Request log created")
+
+	// 9.5. 上游返回空响应但已扣费时自动退款
+	if degraded {
+		s.refundFailedRequest(req, logID, cost, "upstream returned empty response")
+	}
+
+	// 10. 存储到缓存
+	if s.runtimeConfig.Get().IsCacheEnabled() && !req.Stream && !degraded && req.PinnedConfigID == 0 && !req.NoCache {
+		go s.storeCache(context.Background(), req.UserID, req.Model, cacheKey, req.ChatRequest, resp, cost)
+		s.log(ctx).Info("✓ Response cached")
+	}
+
+	s.log(ctx).Info("=== Chat Completion Request Completed ===",
+		logger.Duration("total_time", time.Since(startTime)))
+
+	return s.applyRedaction(ctx, req, resp), nil
+}
+
+// upstreamAttempt 一次上游调用的结果，err 为上游调用失败
+type upstreamAttempt struct {
+	apiConfig    *apiconfig.APIConfig
+	strategy     string // 选择该配置的策略，用于路由诊断
+	credentialID uint
+	resp         *adapter.ChatResponse
+	err          error
+}
+
+// callUpstream 选择配置、创建适配器并调用上游（非流式），exclude 中的配置不参与选择
+// 返回 error 表示请求无法发出，不应换配置重试；上游调用失败记录在 upstreamAttempt.err 中
+func (s *service) callUpstream(ctx context.Context, req *ProxyRequest, exclude map[uint]bool) (*upstreamAttempt, error) {
+	// 4. 选择 API 配置（负载均衡），候选请求固定使用采样到的配置
+	var apiConfig *apiconfig.APIConfig
+	var strategy string
+	var err error
+	selectCtx, selectSpan := s.startSpan(ctx, SpanSelectConfig)
+	if req.PinnedConfigID != 0 {
+		apiConfig, err = s.pinnedAPIConfig(selectCtx, req.Model, req.PinnedConfigID, exclude)
+		strategy = RoutingStrategyPinned
+	} else {
+		apiConfig, strategy, err = s.selectAPIConfigWithStrategy(selectCtx, req.Model, req.ProviderPreference, req.RoutingMode, exclude)
+	}
+	if err == errNoFailoverConfig {
+		tracing.EndSpan(selectSpan, nil)
+		return nil, err
+	}
+	tracing.EndSpan(selectSpan, err, append(configAttributes(apiConfig), attribute.String(AttrStrategy, strategy))...)
 	if err != nil {
-		s.logger.Error("Failed to select API config", logger.Error(err))
+		s.log(ctx).Error("Failed to select API config", logger.Error(err))
 		return nil, err
 	}
-	s.logger.Info("✓ API config selected",
+	scopeConfigLog(ctx, apiConfig)
+	s.log(ctx).Info("✓ API config selected",
 		logger.Uint("config_id", apiConfig.ID),
 		logger.String("config_name", apiConfig.Name),
-		logger.String("config_type", apiConfig.ConfigType))
+		logger.String("config_type", apiConfig.ConfigType),
+		logger.String("provider", apiConfig.Type))
+	req.Provider = apiConfig.Type
+	req.ConfigNoLog = !apiConfig.LogRequests
 
 	// 5. 验证定价策略是否存在（商用必须）
 	if err := s.validatePricing(ctx, apiConfig.ID, req.Model); err != nil {
-		s.logger.Error("Pricing validation failed",
+		s.log(ctx).Error("Pricing validation failed",
 			logger.Uint("api_config_id", apiConfig.ID),
 			logger.String("model", req.Model),
 			logger.Error(err))
 		return nil, errors.Wrap(err, 400001, "Pricing not configured for this model")
 	}
-	s.logger.Info("✓ Pricing validated")
+	s.log(ctx).Info("✓ Pricing validated")
+
+	// 动态超时按模型近期 p99 响应时间确定，未开启或无历史数据时沿用配置的超时
+	timeout, dynamic := s.upstreamTimeout(req.Model)
 
 	// 6. 根据配置类型创建适配器
 	var adapterInstance adapter.Adapter
 	var credentialID uint
 	
-	if apiConfig.IsDirect() {
+	if apiConfig.Type == adapter.EchoType {
+		// 内置 echo 测试模型，不访问上游
+		adapterInstance = s.newEchoAdapter()
+	} else if apiConfig.IsDirect() {
 		// 直接调用
-		adapterInstance, err = s.adapterFactory.CreateAdapter(apiConfig)
+		directConfig := apiConfig
+		if dynamic {
+			directConfig = withUpstreamTimeout(apiConfig, timeout)
+		}
+		adapterInstance, err = s.adapterFactory.CreateAdapter(directConfig)
 		if err != nil {
-			s.logger.Error("Failed to create adapter", logger.Error(err))
+			s.log(ctx).Error("Failed to create adapter", logger.Error(err))
 			return nil, errors.Wrap(err, 500003, "Failed to create adapter")
 		}
-		s.logger.Info("✓ Direct adapter created")
+		s.log(ctx).Info("✓ Direct adapter created")
 	} else if apiConfig.IsAccountPool() {
 		// 使用账号池
-		if apiConfig.AccountPoolID == nil {
+		pools := configPools(apiConfig)
+		if len(pools) == 0 {
 			return nil, errors.New(500001, "Account pool ID is required")
 		}
 		
 		var poolAdapter interface{}
-		poolAdapter, credentialID, err = s.poolManager.GetAdapter(ctx, *apiConfig.AccountPoolID)
+		poolAdapter, credentialID, err = s.poolManager.GetAdapter(ctx, pools, req.Model)
 		if err != nil {
-			s.logger.Error("Failed to get adapter from pool", logger.Error(err))
+			s.log(ctx).Error("Failed to get adapter from pool", logger.Error(err))
 			return nil, errors.Wrap(err, 500003, "Failed to get adapter from pool")
 		}
 		
@@ -169,142 +451,222 @@ func (s *service) ChatCompletions(ctx context.Context, req *ProxyRequest) (*adap
 		if !ok {
 			return nil, errors.New(500001, "Invalid adapter type from pool")
 		}
-		s.logger.Info("✓ Account pool adapter created",
-			logger.Uint("pool_id", *apiConfig.AccountPoolID),
+		// 账号池适配器由凭据创建，User-Agent 模板和请求头转发策略取自当前配置
+		adapter.ApplyUserAgent(adapterInstance, apiConfig.UserAgent)
+		adapter.ApplyHeaderPolicy(adapterInstance, apiConfig.GetHeaderPolicy())
+		s.log(ctx).Info("✓ Account pool adapter created",
+			logger.Int("pools", len(pools)),
 			logger.Uint("credential_id", credentialID))
 	} else {
 		return nil, errors.New(500001, "Invalid config type")
 	}
 
+	// 别名请求改写为实际模型并注入默认 system 提示词，须在截断和 token 估算之前
+	applyModelAlias(apiConfig, req.ChatRequest)
+
+	s.resolveServiceTier(ctx, req, adapterInstance)
+
+	// 工具结果紧跟对应的工具调用，所有上游都要求二者成对出现
+	req.ChatRequest.Messages = adapter.PairToolResults(req.ChatRequest.Messages)
+
+	// 对话历史消息数超出 API Key 或配置上限时按策略拒绝或只保留最近的消息
+	if err := s.applyHistoryLimit(ctx, apiConfig, req); err != nil {
+		return nil, err
+	}
+
+	// 超出配置上限的工具结果按策略截断或摘要
+	s.limitToolResults(ctx, apiConfig, req.ChatRequest)
+
+	// 按配置截断超出上下文窗口的对话
+	if err := s.truncateContext(ctx, apiConfig, req); err != nil {
+		return nil, err
+	}
+
+	// 重复出现的长对话前缀走上游提示词缓存或本地摘要
+	s.applyPrefixCaching(ctx, apiConfig, adapterInstance, req.ChatRequest)
+
+	// 工具数超出配置或供应商上限时按策略拒绝、截断或合并
+	if err := s.applyToolLimit(ctx, apiConfig, adapterInstance, req); err != nil {
+		return nil, err
+	}
+
+	// 供应商不支持的工具结果图片会替换为文本占位符
+	s.reportDroppedImages(ctx, apiConfig, adapterInstance, req.ChatRequest)
+
+	// 按适配器声明的能力预校验请求，避免把不支持的特性发往上游
+	if err := adapter.ValidateRequest(adapterInstance, req.ChatRequest); err != nil {
+		return nil, err
+	}
+
+	// 共享上游的并发预算按模型公平分配，名额占满时排队
+	release, err := s.acquireUpstreamSlot(ctx, apiConfig, credentialID, req.Model)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// 7. 调用上游 API
-	s.logger.Info("→ Calling upstream API...")
-	resp, err := adapterInstance.Call(ctx, req.ChatRequest)
+	s.log(ctx).Info("→ Calling upstream API...")
+	ctx, req.Sizes = adapter.WithPayloadSizes(ctx)
+	ctx, rateLimits := adapter.WithRateLimitRecorder(ctx)
+	ctx, upstreamID := adapter.WithUpstreamRequestID(ctx)
+	callStart := time.Now()
+	callCtx, callSpan := s.startSpan(ctx, SpanUpstreamCall, configAttributes(apiConfig)...)
+	if dynamic {
+		s.log(ctx).Info("Using dynamic upstream timeout", logger.Duration("timeout", timeout))
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(callCtx, timeout)
+		defer cancel()
+	}
+	resp, err := adapterInstance.Call(callCtx, req.ChatRequest)
+	tracing.EndSpan(callSpan, err)
+	s.recordPoolRateLimit(ctx, apiConfig, credentialID, rateLimits)
+	req.UpstreamRequestID = upstreamID.Value()
+	if err == nil && s.latency != nil {
+		s.latency.Observe(apiConfig.ID, time.Since(callStart))
+	}
+	s.observeHealth(ctx, apiConfig.ID, err)
 	if err != nil {
 		// 如果是账号池，记录错误
 		if apiConfig.IsAccountPool() && credentialID > 0 {
 			s.poolManager.RecordError(ctx, credentialID, err.Error())
 		}
-		
-		s.logger.Error("✗ Upstream API call failed", logger.Error(err))
-		// 记录失败日志
-		s.logRequest(ctx, req, apiConfig.ID, 0, time.Since(startTime), err)
-		return nil, errors.Wrap(err, 500004, "Failed to call upstream API")
+		s.log(ctx).Error("✗ Upstream API call failed", logger.Error(err))
 	}
-	
-	// 如果是账号池，记录成功
-	if apiConfig.IsAccountPool() && credentialID > 0 {
-		s.poolManager.RecordSuccess(ctx, credentialID)
-	}
-	
-	s.logger.Info("✓ Upstream API call succeeded",
-		logger.Int("prompt_tokens", resp.Usage.PromptTokens),
-		logger.Int("completion_tokens", resp.Usage.CompletionTokens),
-		logger.Int("total_tokens", resp.Usage.TotalTokens))
+	return &upstreamAttempt{apiConfig: apiConfig, strategy: strategy, credentialID: credentialID, resp: resp, err: err}, nil
+}
 
-	// 8. 计算费用并扣除配额（必须成功）
-	s.logger.Info("→ Calculating cost and deducting quota...")
-	cost, err := s.calculateAndDeductCost(ctx, req.UserID, apiConfig.ID, req.Model, resp.Usage)
-	if err != nil {
-		s.logger.Error("✗ CRITICAL: Failed to calculate and deduct cost",
-			logger.Uint("user_id", req.UserID),
-			logger.String("model", req.Model),
-			logger.Error(err))
-		// 扣费失败，记录日志但不返回错误（因为请求已经成功）
-		// 这种情况应该触发告警，需要人工介入
-		s.logger.Error("CRITICAL: Request succeeded but billing failed - manual intervention required",
-			logger.Uint("user_id", req.UserID),
-			logger.Uint("api_config_id", apiConfig.ID),
-			logger.String("model", req.Model),
-			logger.Int("prompt_tokens", resp.Usage.PromptTokens),
-			logger.Int("completion_tokens", resp.Usage.CompletionTokens))
-	} else {
-		s.logger.Info("✓ Cost calculated and quota deducted",
-			logger.Int("cost", cost))
+// recordPoolRateLimit 将上游响应头中的速率限制同步到账号池凭据，供后续选择凭据时避开接近上限的凭据
+func (s *service) recordPoolRateLimit(ctx context.Context, apiConfig *apiconfig.APIConfig, credentialID uint, recorder *adapter.RateLimitRecorder) {
+	if !apiConfig.IsAccountPool() || credentialID == 0 {
+		return
 	}
-
-	// 8.5. 记录成功（如果使用账号池）
-	if apiConfig.IsAccountPool() && credentialID > 0 {
-		s.poolManager.RecordSuccess(ctx, credentialID)
-		s.logger.Info("✓ Credential success recorded", logger.Uint("credential_id", credentialID))
+	if state, ok := recorder.State(); ok {
+		s.poolManager.RecordRateLimit(ctx, credentialID, state)
 	}
+}
 
-	// 9. 记录请求日志
-	s.logger.Info("→ Creating request log...")
-	s.logRequest(ctx, req, apiConfig.ID, resp.Usage.TotalTokens, time.Since(startTime), nil)
-	s.logger.Info("✓ Request log created")
-
-	// 10. 存储到缓存
-	if s.runtimeConfig.Get().IsCacheEnabled() && !req.Stream {
-		go s.storeCache(context.Background(), req.UserID, req.Model, cacheKey, req.ChatRequest, resp, cost)
-		s.logger.Info("✓ Response cached")
+// applyRedaction 按 API Key 配置对响应脱敏，只记录脱敏次数不记录内容
+func (s *service) applyRedaction(ctx context.Context, req *ProxyRequest, resp *adapter.ChatResponse) *adapter.ChatResponse {
+	if req.Redactor == nil {
+		return resp
 	}
+	redacted, n := redactResponse(req.Redactor, resp)
+	if n > 0 {
+		s.log(ctx).Info("Response content redacted",
+			logger.Uint("api_key_id", req.APIKeyID),
+			logger.Int("redactions", n),
+			logger.Bool("stream", false))
+	}
+	return redacted
+}
 
-	s.logger.Info("=== Chat Completion Request Completed ===",
-		logger.Duration("total_time", time.Since(startTime)))
-
+// ChatCompletionsStream 在 proxy.chat_completion span 中处理流式聊天补全请求
+// 请求 span 和上游调用子 span 随响应返回，由 StreamWrapper 在流关闭时结束，计费子 span 在流结算时记录
+func (s *service) ChatCompletionsStream(ctx context.Context, req *ProxyRequest) (*StreamResponse, error) {
+	ctx, span := s.startSpan(ctx, SpanChatCompletion,
+		attribute.String(AttrRequestID, req.RequestID),
+		attribute.String(AttrModel, req.Model))
+	scopeTraceLog(ctx)
+	resp, err := s.serveChatCompletionsStream(ctx, req)
+	if err != nil {
+		tracing.EndSpan(span, err)
+		return nil, err
+	}
+	resp.trace.chat = span
 	return resp, nil
 }
 
-// ChatCompletionsStream 处理流式聊天补全请求
-func (s *service) ChatCompletionsStream(ctx context.Context, req *ProxyRequest) (*StreamResponse, error) {
-	s.logger.Info("=== Starting stream request ===",
-		logger.Uint("user_id", req.UserID),
-		logger.String("model", req.Model))
+// serveChatCompletionsStream 处理一次流式聊天补全请求：选择配置、预扣配额并开启上游流
+func (s *service) serveChatCompletionsStream(ctx context.Context, req *ProxyRequest) (*StreamResponse, error) {
+	scopeRequestLog(ctx, req)
+	s.log(ctx).Info("=== Starting stream request ===")
+
+	if err := s.checkArchive(); err != nil {
+		return nil, err
+	}
 
 	// 流式请求不使用缓存
 	
 	// 1. 检查配额
-	s.logger.Info("→ Checking user quota...")
-	if err := s.checkQuota(ctx, req.UserID); err != nil {
-		s.logger.Error("✗ Quota check failed", logger.Error(err))
+	// 引用配额预留的请求在开流前使用预留，不再检查剩余配额
+	if req.HoldID == "" {
+		s.log(ctx).Info("→ Checking user quota...")
+		quotaCtx, quotaSpan := s.startSpan(ctx, SpanQuotaCheck)
+		quotaInfo, err := s.checkQuota(quotaCtx, req.UserID)
+		tracing.EndSpan(quotaSpan, err)
+		if err != nil {
+			s.log(ctx).Error("✗ Quota check failed", logger.Error(err))
+			return nil, err
+		}
+		req.StreamPrecharge = quotaInfo.StreamPrecharge
+		s.log(ctx).Info("✓ Quota check passed")
+	}
+
+	// 1.4. 按提示词长度路由模型
+	s.applyLengthRouting(ctx, req)
+
+	// 1.5. 按成本上限选择模型
+	if err := s.applyCostCeiling(ctx, req); err != nil {
+		s.log(ctx).Warn("✗ Cost ceiling not satisfied", logger.Error(err))
 		return nil, err
 	}
-	s.logger.Info("✓ Quota check passed")
 
 	// 2. 选择 API 配置
-	s.logger.Info("→ Selecting API config...", logger.String("model", req.Model))
-	apiConfig, err := s.selectAPIConfig(ctx, req.Model)
+	s.log(ctx).Info("→ Selecting API config...", logger.String("model", req.Model))
+	selectCtx, selectSpan := s.startSpan(ctx, SpanSelectConfig)
+	apiConfig, strategy, err := s.selectAPIConfigWithStrategy(selectCtx, req.Model, req.ProviderPreference, req.RoutingMode, nil)
+	tracing.EndSpan(selectSpan, err, append(configAttributes(apiConfig), attribute.String(AttrStrategy, strategy))...)
 	if err != nil {
-		s.logger.Error("✗ Failed to select API config", logger.Error(err))
+		s.log(ctx).Error("✗ Failed to select API config", logger.Error(err))
 		return nil, err
 	}
-	s.logger.Info("✓ API config selected",
+	scopeConfigLog(ctx, apiConfig)
+	trace.SpanFromContext(ctx).SetAttributes(configAttributes(apiConfig)...)
+	s.log(ctx).Info("✓ API config selected",
 		logger.Uint("api_config_id", apiConfig.ID),
-		logger.String("name", apiConfig.Name))
+		logger.String("name", apiConfig.Name),
+		logger.String("provider", apiConfig.Type))
+	req.Provider = apiConfig.Type
+	req.ConfigNoLog = !apiConfig.LogRequests
 
 	// 3. 验证定价策略是否存在（商用必须）
-	s.logger.Info("→ Validating pricing...")
+	s.log(ctx).Info("→ Validating pricing...")
 	if err := s.validatePricing(ctx, apiConfig.ID, req.Model); err != nil {
-		s.logger.Error("✗ Pricing validation failed",
+		s.log(ctx).Error("✗ Pricing validation failed",
 			logger.Uint("api_config_id", apiConfig.ID),
 			logger.String("model", req.Model),
 			logger.Error(err))
 		return nil, errors.Wrap(err, 400001, "Pricing not configured for this model")
 	}
-	s.logger.Info("✓ Pricing validated")
+	s.log(ctx).Info("✓ Pricing validated")
 
 	// 4. 根据配置类型创建适配器
 	var adapterInstance adapter.Adapter
 	var credentialID uint
 	
-	s.logger.Info("→ Creating adapter...", logger.String("type", apiConfig.Type))
-	if apiConfig.IsDirect() {
+	s.log(ctx).Info("→ Creating adapter...", logger.String("type", apiConfig.Type))
+	if apiConfig.Type == adapter.EchoType {
+		// 内置 echo 测试模型，不访问上游
+		adapterInstance = s.newEchoAdapter()
+	} else if apiConfig.IsDirect() {
 		// 直接调用
 		adapterInstance, err = s.adapterFactory.CreateAdapter(apiConfig)
 		if err != nil {
-			s.logger.Error("✗ Failed to create adapter", logger.Error(err))
+			s.log(ctx).Error("✗ Failed to create adapter", logger.Error(err))
 			return nil, errors.Wrap(err, 500003, "Failed to create adapter")
 		}
 	} else if apiConfig.IsAccountPool() {
 		// 使用账号池
-		if apiConfig.AccountPoolID == nil {
+		pools := configPools(apiConfig)
+		if len(pools) == 0 {
 			return nil, errors.New(500001, "Account pool ID is required")
 		}
 		
 		var poolAdapter interface{}
-		poolAdapter, credentialID, err = s.poolManager.GetAdapter(ctx, *apiConfig.AccountPoolID)
+		poolAdapter, credentialID, err = s.poolManager.GetAdapter(ctx, pools, req.Model)
 		if err != nil {
-			s.logger.Error("✗ Failed to get adapter from pool", logger.Error(err))
+			s.log(ctx).Error("✗ Failed to get adapter from pool", logger.Error(err))
 			return nil, errors.Wrap(err, 500003, "Failed to get adapter from pool")
 		}
 		
@@ -314,57 +676,131 @@ func (s *service) ChatCompletionsStream(ctx context.Context, req *ProxyRequest)
 		if !ok {
 			return nil, errors.New(500001, "Invalid adapter type from pool")
 		}
-		s.logger.Info("✓ Adapter created from pool", logger.Uint("credential_id", credentialID))
+		// 账号池适配器由凭据创建，User-Agent 模板和请求头转发策略取自当前配置
+		adapter.ApplyUserAgent(adapterInstance, apiConfig.UserAgent)
+		adapter.ApplyHeaderPolicy(adapterInstance, apiConfig.GetHeaderPolicy())
+		s.log(ctx).Info("✓ Adapter created from pool", logger.Uint("credential_id", credentialID))
 	} else {
 		return nil, errors.New(500001, "Invalid config type")
 	}
-	s.logger.Info("✓ Adapter created")
+	s.log(ctx).Info("✓ Adapter created")
+
+	// 别名请求改写为实际模型并注入默认 system 提示词，须在截断和 token 估算之前
+	applyModelAlias(apiConfig, req.ChatRequest)
+
+	s.resolveServiceTier(ctx, req, adapterInstance)
+
+	// 工具结果紧跟对应的工具调用，所有上游都要求二者成对出现
+	req.ChatRequest.Messages = adapter.PairToolResults(req.ChatRequest.Messages)
+
+	// 对话历史消息数超出 API Key 或配置上限时按策略拒绝或只保留最近的消息
+	if err := s.applyHistoryLimit(ctx, apiConfig, req); err != nil {
+		return nil, err
+	}
+
+	// 超出配置上限的工具结果按策略截断或摘要
+	s.limitToolResults(ctx, apiConfig, req.ChatRequest)
+
+	// 按配置截断超出上下文窗口的对话
+	if err := s.truncateContext(ctx, apiConfig, req); err != nil {
+		return nil, err
+	}
+
+	// 重复出现的长对话前缀走上游提示词缓存或本地摘要
+	s.applyPrefixCaching(ctx, apiConfig, adapterInstance, req.ChatRequest)
+
+	// 工具数超出配置或供应商上限时按策略拒绝、截断或合并
+	if err := s.applyToolLimit(ctx, apiConfig, adapterInstance, req); err != nil {
+		return nil, err
+	}
+
+	// 供应商不支持的工具结果图片会替换为文本占位符
+	s.reportDroppedImages(ctx, apiConfig, adapterInstance, req.ChatRequest)
+
+	// 适配器不支持流式时按配置改为非流式调用并模拟流，否则在下面的能力校验中返回错误
+	emulate := s.shouldEmulateStream(adapterInstance)
+	if emulate {
+		s.log(ctx).Info("→ Adapter does not support streaming, emulating the stream from a non-streaming call")
+		req.ChatRequest.Stream = false
+	}
+
+	// 按适配器声明的能力预校验请求，避免把不支持的特性发往上游
+	if err := adapter.ValidateRequest(adapterInstance, req.ChatRequest); err != nil {
+		return nil, err
+	}
+
+	// 共享上游的并发预算按模型公平分配，流式请求占用名额直到流关闭
+	release, err := s.acquireUpstreamSlot(ctx, apiConfig, credentialID, req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	// 用户启用预扣时按 max_tokens 预扣配额（引用配额预留时使用预留），流结束后按实际用量结算
+	if err := s.reserveStreamQuota(ctx, req, apiConfig.ID); err != nil {
+		release()
+		return nil, err
+	}
 
 	// 5. 调用上游 API（流式）
-	s.logger.Info("→ Calling upstream API (stream)...")
-	resp, err := adapterInstance.CallStream(ctx, req.ChatRequest)
+	s.log(ctx).Info("→ Calling upstream API (stream)...")
+	ctx, req.Sizes = adapter.WithPayloadSizes(ctx)
+	ctx, rateLimits := adapter.WithRateLimitRecorder(ctx)
+	ctx, upstreamID := adapter.WithUpstreamRequestID(ctx)
+	callCtx, callSpan := s.startSpan(ctx, SpanUpstreamCall, configAttributes(apiConfig)...)
+	var resp *http.Response
+	if emulate {
+		resp, err = s.callEmulatedStream(callCtx, adapterInstance, req.ChatRequest)
+	} else {
+		resp, err = adapterInstance.CallStream(callCtx, req.ChatRequest)
+	}
+	s.recordPoolRateLimit(ctx, apiConfig, credentialID, rateLimits)
+	s.observeHealth(ctx, apiConfig.ID, err)
+	req.UpstreamRequestID = upstreamID.Value()
 	if err != nil {
-		s.logger.Error("✗ Failed to call upstream API", logger.Error(err))
+		tracing.EndSpan(callSpan, err)
+		release()
+		s.releaseStreamQuota(req)
+		s.log(ctx).Error("✗ Failed to call upstream API", logger.Error(err))
 		// 如果是账号池，记录错误
 		if apiConfig.IsAccountPool() && credentialID > 0 {
 			s.poolManager.RecordError(ctx, credentialID, err.Error())
 		}
 		return nil, errors.Wrap(err, 500004, "Failed to call upstream API")
 	}
-	s.logger.Info("✓ Upstream API called successfully")
+	s.log(ctx).Info("✓ Upstream API called successfully")
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
 
 	// 返回响应和元数据，由 handler 层包装流并处理日志记录
 	return &StreamResponse{
 		Response:     resp,
 		APIConfigID:  apiConfig.ID,
 		CredentialID: credentialID,
+		IdleTimeout:  time.Duration(apiConfig.StreamIdleTimeout) * time.Second,
+		MaxDuration:  s.runtimeConfig.Get().GetStreamMaxDuration(),
+		trace:        &streamTrace{call: callSpan, attrs: configAttributes(apiConfig)},
 	}, nil
 }
 
-// checkQuota 检查用户配额
-func (s *service) checkQuota(ctx context.Context, userID uint) error {
+// checkQuota 检查用户配额，通过时返回配额信息
+func (s *service) checkQuota(ctx context.Context, userID uint) (*quota.QuotaInfoResponse, error) {
 	quotaInfo, err := s.quotaService.GetQuotaInfo(ctx, userID)
 	if err != nil {
-		return errors.Wrap(err, 500005, "Failed to check quota")
+		return nil, errors.Wrap(err, 500005, "Failed to check quota")
 	}
 
-	if quotaInfo.UsedQuota >= quotaInfo.TotalQuota {
-		return errors.ErrQuotaExceeded
+	// 透支额度内仍允许发起请求
+	if quotaInfo.UsedQuota >= quotaInfo.TotalQuota+quotaInfo.OverdraftLimit {
+		return nil, errors.ErrQuotaExceeded
 	}
 
-	return nil
+	return quotaInfo, nil
 }
 
 // generateCacheKey 生成缓存键
 func (s *service) generateCacheKey(req *adapter.ChatRequest) string {
-	// 将请求序列化为 JSON
-	data, _ := json.Marshal(map[string]interface{}{
-		"model":       req.Model,
-		"messages":    req.Messages,
-		"temperature": req.Temperature,
-		"top_p":       req.TopP,
-		"max_tokens":  req.MaxTokens,
-	})
+	// 将请求序列化为 JSON（启用时先规范化空白和默认参数）
+	normalize := s.runtimeConfig.Get().IsCacheKeyNormalizeEnabled()
+	data, _ := json.Marshal(cacheKeyPayload(req, normalize))
 	
 	// 计算 MD5 哈希
 	hash := md5.Sum(data)
@@ -372,20 +808,33 @@ func (s *service) generateCacheKey(req *adapter.ChatRequest) string {
 }
 
 // checkCache 检查缓存
-func (s *service) checkCache(ctx context.Context, userID uint, model string, cacheKey string, req *adapter.ChatRequest) (*adapter.ChatResponse, error) {
-	// 1. 精确匹配查询
-	cachedItem, err := s.cacheService.FindByCacheKey(ctx, cacheKey)
+func (s *service) checkCache(ctx context.Context, proxyReq *ProxyRequest, cacheKey string) (*adapter.ChatResponse, error) {
+	// 1. 精确匹配查询（启用 stale-while-revalidate 时包含过期窗口内的记录）
+	var cachedItem *cache.RequestCache
+	var err error
+	if s.runtimeConfig.Get().GetCacheStaleWhileRevalidate() > 0 {
+		cachedItem, err = s.cacheService.FindByCacheKeyAllowStale(ctx, cacheKey)
+	} else {
+		cachedItem, err = s.cacheService.FindByCacheKey(ctx, cacheKey)
+	}
 	if err == nil && cachedItem != nil {
 		// 解析响应
-		var resp adapter.ChatResponse
-		if err := json.Unmarshal([]byte(cachedItem.Response), &resp); err == nil {
-			return &resp, nil
+		if resp, err := decodeCachedResponse(cachedItem); err == nil {
+			// 同一缓存键同时只允许一个后台刷新，避免过期瞬间大量请求同时打到上游
+			if cachedItem.IsStale(time.Now()) {
+				if _, loaded := s.revalidating.LoadOrStore(cacheKey, struct{}{}); !loaded {
+					s.log(ctx).Info("Serving stale cache entry, refreshing in background",
+						logger.String("cache_key", cacheKey))
+					go s.revalidateCache(proxyReq, cacheKey)
+				}
+			}
+			return resp, nil
 		}
 	}
 
-	// 2. 语义匹配查询（如果启用）
-	if s.runtimeConfig.Get().IsSemanticEnabled() && s.embeddingClient != nil {
-		return s.semanticCacheMatch(ctx, userID, model, req)
+	// 2. 语义匹配查询（如果启用，且请求和 API Key 没有关闭；带工具的请求只走精确匹配）
+	if s.runtimeConfig.Get().IsSemanticEnabled() && s.embeddingClient != nil && !proxyReq.NoSemanticCache && !usesTools(proxyReq.ChatRequest) {
+		return s.semanticCacheMatch(ctx, proxyReq.UserID, proxyReq.Model, proxyReq.ChatRequest)
 	}
 
 	return nil, nil
@@ -402,7 +851,7 @@ func (s *service) semanticCacheMatch(ctx context.Context, userID uint, model str
 	// 获取查询文本的 embedding
 	queryEmbedding, err := s.embeddingClient.Embed(ctx, queryText)
 	if err != nil {
-		s.logger.Warn("Failed to get embedding", logger.Error(err))
+		s.log(ctx).Warn("Failed to get embedding", logger.Error(err))
 		return nil, nil
 	}
 
@@ -414,12 +863,7 @@ func (s *service) semanticCacheMatch(ctx context.Context, userID uint, model str
 	}
 
 	// 解析响应
-	var resp adapter.ChatResponse
-	if err := json.Unmarshal([]byte(cachedItem.Response), &resp); err != nil {
-		return nil, err
-	}
-
-	return &resp, nil
+	return decodeCachedResponse(cachedItem)
 }
 
 // findSemanticMatch 查找语义匹配的缓存
@@ -434,7 +878,7 @@ func (s *service) findSemanticMatch(ctx context.Context, userID uint, model stri
 	var bestSimilarity float64
 
 	for _, cachedItem := range caches {
-		if !cachedItem.HasEmbedding() {
+		if !cachedItem.HasEmbedding() || cachedRequestUsesTools(cachedItem) {
 			continue
 		}
 
@@ -498,6 +942,14 @@ func (s *service) storeCache(ctx context.Context, userID uint, model string, cac
 		TokensSaved: tokensSaved,
 		HitCount:    0,
 		ExpiresAt:   time.Now().Add(s.runtimeConfig.Get().GetCacheTTL()),
+
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	if swr := s.runtimeConfig.Get().GetCacheStaleWhileRevalidate(); swr > 0 {
+		staleUntil := cacheItem.ExpiresAt.Add(swr)
+		cacheItem.StaleUntil = &staleUntil
 	}
 
 	// 如果启用 embedding，生成向量
@@ -505,7 +957,7 @@ func (s *service) storeCache(ctx context.Context, userID uint, model string, cac
 	if s.runtimeConfig.Get().IsEmbeddingEnabled() && s.embeddingClient != nil && queryText != "" {
 		vec, err := s.embeddingClient.Embed(ctx, queryText)
 		if err != nil {
-			s.logger.Warn("Failed to generate embedding", logger.Error(err))
+			s.log(ctx).Warn("Failed to generate embedding", logger.Error(err))
 		} else {
 			embeddingVec = vec
 		}
@@ -513,82 +965,109 @@ func (s *service) storeCache(ctx context.Context, userID uint, model string, cac
 
 	// 存储缓存
 	if err := s.cacheService.CreateCacheWithEmbedding(ctx, cacheItem, embeddingVec); err != nil {
-		s.logger.Warn("Failed to store cache", logger.Error(err))
+		s.log(ctx).Warn("Failed to store cache", logger.Error(err))
 	}
 }
 
 // selectAPIConfig 选择 API 配置（负载均衡）
-func (s *service) selectAPIConfig(ctx context.Context, model string) (*apiconfig.APIConfig, error) {
+// 指定供应商偏好时，先按偏好顺序筛选出第一个可用供应商的配置，再应用负载均衡策略
+func (s *service) selectAPIConfig(ctx context.Context, model string, preference []string, routingMode string, exclude map[uint]bool) (*apiconfig.APIConfig, error) {
+	cfg, _, err := s.selectAPIConfigWithStrategy(ctx, model, preference, routingMode, exclude)
+	return cfg, err
+}
+
+// selectAPIConfigWithStrategy 选择 API 配置，同时返回决定选择结果的策略，用于路由诊断
+func (s *service) selectAPIConfigWithStrategy(ctx context.Context, model string, preference []string, routingMode string, exclude map[uint]bool) (*apiconfig.APIConfig, string, error) {
+	if s.isEchoModel(model) {
+		return echoAPIConfig(), RoutingStrategyEcho, nil
+	}
+
 	// 获取支持该模型的所有配置
 	configs, err := s.apiConfigRepo.FindByModel(ctx, model)
 	if err != nil {
-		return nil, errors.Wrap(err, 500006, "Failed to find API configs")
+		return nil, "", errors.Wrap(err, 500006, "Failed to find API configs")
 	}
 
 	if len(configs) == 0 {
-		return nil, errors.New(404002, fmt.Sprintf("No API configuration found for model: %s", model))
+		return nil, "", errors.New(404002, fmt.Sprintf("No API configuration found for model: %s", model))
+	}
+
+	// 故障转移时排除已失败的配置
+	if len(exclude) > 0 {
+		remaining := make([]*apiconfig.APIConfig, 0, len(configs))
+		for _, cfg := range configs {
+			if !exclude[cfg.ID] {
+				remaining = append(remaining, cfg)
+			}
+		}
+		if len(remaining) == 0 {
+			return nil, "", errNoFailoverConfig
+		}
+		configs = remaining
+	}
+
+	if len(preference) > 0 {
+		if preferred := s.preferredConfigs(ctx, configs, preference); len(preferred) > 0 {
+			configs = preferred
+		} else {
+			s.log(ctx).Warn("No preferred provider available, using default selection",
+				logger.String("model", model),
+				logger.Any("preference", preference))
+		}
+	}
+
+	// 灰度配置按固定比例承接流量，未命中时只在稳定配置中选择
+	configs, canary := s.splitCanaryConfigs(configs)
+	if canary != nil {
+		return canary, RoutingStrategyCanary, nil
 	}
 
 	// 如果只有一个配置，直接返回
 	if len(configs) == 1 {
-		return configs[0], nil
+		return configs[0], RoutingStrategySingle, nil
+	}
+
+	// 按成本或延迟路由时在健康候选中选择，无法判断时回到负载均衡策略
+	if routingMode != "" {
+		if cfg := s.selectByRoutingMode(ctx, model, routingMode, configs); cfg != nil {
+			return cfg, routingMode, nil
+		}
 	}
 
 	// 获取负载均衡配置
 	lbConfig, err := s.loadBalancerSvc.GetConfigByModel(ctx, model)
 	if err != nil || lbConfig == nil {
 		// 没有负载均衡配置，使用第一个
-		return configs[0], nil
+		return configs[0], RoutingStrategyFirst, nil
 	}
 
-	// 根据策略选择配置
-	return s.selectByStrategy(configs, lbConfig.Strategy)
-}
-
-// selectByStrategy 根据策略选择配置
-func (s *service) selectByStrategy(configs []*apiconfig.APIConfig, strategy string) (*apiconfig.APIConfig, error) {
-	switch strategy {
-	case "round_robin":
-		// 简单轮询：返回第一个（实际应该维护计数器）
-		return configs[0], nil
-	case "weighted_round_robin":
-		// 加权轮询：根据权重选择
-		return s.selectByWeight(configs), nil
-	case "random":
-		// 随机选择
-		return configs[utils.Min(len(configs)-1, int(time.Now().UnixNano()%int64(len(configs))))], nil
-	default:
-		return configs[0], nil
-	}
+	// 根据策略选择配置，开启健康衰减的配置按近期成败调整权重
+	return configs[s.selector.Select(model, lbConfig.Strategy, s.effectiveWeights(configs))], lbConfig.Strategy, nil
 }
 
-// selectByWeight 根据权重选择配置
-func (s *service) selectByWeight(configs []*apiconfig.APIConfig) *apiconfig.APIConfig {
-	totalWeight := 0
-	for _, cfg := range configs {
-		totalWeight += cfg.Weight
+// estimateCost 按用量计算费用（不扣费）
+func (s *service) estimateCost(ctx context.Context, apiConfigID uint, model, serviceTier string, usage adapter.UsageInfo) (int64, error) {
+	if isEchoCall(apiConfigID, model) {
+		return 0, nil
 	}
-
-	if totalWeight == 0 {
-		return configs[0]
+	costResp, err := s.pricingService.CalculateCost(ctx, &pricing.CalculateCostRequest{
+		APIConfigID:  apiConfigID,
+		ModelName:    model,
+		InputTokens:  int64(usage.PromptTokens),
+		OutputTokens: int64(usage.CompletionTokens),
+	})
+	if err != nil {
+		return 0, err
 	}
+	return s.chargedCredits(costResp.TotalCost, serviceTier), nil
+}
 
-	// 生成随机数
-	random := int(time.Now().UnixNano() % int64(totalWeight))
-	
-	// 根据权重选择
-	for _, cfg := range configs {
-		random -= cfg.Weight
-		if random < 0 {
-			return cfg
-		}
+// calculateAndDeductCost 计算费用并扣除配额，费用按 service_tier 倍率调整
+func (s *service) calculateAndDeductCost(ctx context.Context, userID uint, apiConfigID uint, model, serviceTier string, usage adapter.UsageInfo) (int, error) {
+	if isEchoCall(apiConfigID, model) {
+		return 0, nil
 	}
 
-	return configs[0]
-}
-
-// calculateAndDeductCost 计算费用并扣除配额
-func (s *service) calculateAndDeductCost(ctx context.Context, userID uint, apiConfigID uint, model string, usage adapter.UsageInfo) (int, error) {
 	// 计算费用
 	costReq := &pricing.CalculateCostRequest{
 		APIConfigID:  apiConfigID,
@@ -602,16 +1081,22 @@ func (s *service) calculateAndDeductCost(ctx context.Context, userID uint, apiCo
 		return 0, err
 	}
 
+	cost := s.chargedCredits(costResp.TotalCost, serviceTier)
+
 	// 扣除配额
-	if err := s.quotaService.DeductQuota(ctx, userID, int64(costResp.TotalCost)); err != nil {
+	if err := s.quotaService.DeductQuota(ctx, userID, cost); err != nil {
 		return 0, err
 	}
 
-	return int(costResp.TotalCost), nil
+	return int(cost), nil
 }
 
 // validatePricing 验证定价策略是否存在
 func (s *service) validatePricing(ctx context.Context, apiConfigID uint, model string) error {
+	if isEchoCall(apiConfigID, model) {
+		return nil
+	}
+
 	// 尝试获取定价信息
 	costReq := &pricing.CalculateCostRequest{
 		APIConfigID:  apiConfigID,
@@ -624,26 +1109,119 @@ func (s *service) validatePricing(ctx context.Context, apiConfigID uint, model s
 	return err
 }
 
-// logRequest 记录请求日志
-func (s *service) logRequest(ctx context.Context, req *ProxyRequest, apiConfigID uint, tokensUsed int, responseTime time.Duration, err error) {
+// isDegradedResponse 判断上游响应是否为空（没有任何文本或工具调用），内容策略拒绝不算空响应
+func isDegradedResponse(resp *adapter.ChatResponse) bool {
+	if resp == nil || len(resp.Choices) == 0 {
+		return true
+	}
+	if resp.IsRefusal() {
+		return false
+	}
+	for _, choice := range resp.Choices {
+		if len(choice.Message.ToolCalls) > 0 || adapter.GetContentAsString(choice.Message.Content) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// refundFailedRequest 对已扣费但判定失败的请求自动退款
+// 有请求日志时按日志核对扣费；关闭了日志或日志写入失败时按网关请求 ID 记账，扣费金额取本次计费结果
+func (s *service) refundFailedRequest(req *ProxyRequest, logID uint, cost int, reason string) {
+	if cost <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		refunded int64
+		err      error
+	)
+	fields := []logger.Field{logger.Uint("user_id", req.UserID)}
+	if logID > 0 {
+		fields = append(fields, logger.Uint("request_log_id", logID))
+		refunded, err = s.quotaService.AutoRefund(ctx, req.UserID, logID, reason)
+	} else {
+		requestID := req.RequestID
+		if requestID == "" {
+			requestID = uuid.New().String()
+		}
+		fields = append(fields, logger.String("request_id", requestID))
+		refunded, err = s.quotaService.AutoRefundRequest(ctx, req.UserID, requestID, int64(cost), reason)
+	}
+	if err != nil {
+		s.log(ctx).Error("✗ Failed to auto refund failed request", append(fields, logger.Error(err))...)
+		return
+	}
+	s.log(ctx).Warn("Request failed after billing, quota refunded",
+		append(fields, logger.Int64("refunded", refunded), logger.String("reason", reason))...)
+}
+
+// skipLog 请求是否不写请求日志：API Key 或本次实际使用的配置关闭了日志
+func (r *ProxyRequest) skipLog() bool {
+	return r.NoLog || r.ConfigNoLog
+}
+
+// logRequest 记录请求日志，返回日志ID（失败或批量写入时为 0）
+// needID 为 true 时同步写入，供退款等需要关联日志的场景使用；其余日志交给批量写入器
+func (s *service) logRequest(ctx context.Context, req *ProxyRequest, apiConfigID uint, tokensUsed int, cost int, responseTime time.Duration, err error, needID bool) uint {
 	logReq := &log.CreateLogRequest{
-		UserID:       req.UserID,
-		APIKeyID:     req.APIKeyID,
-		APIConfigID:  apiConfigID,
-		Model:        req.Model,
-		Method:       "POST",
-		Path:         "/v1/chat/completions",
-		StatusCode:   200,
-		ResponseTime: int(responseTime.Milliseconds()),
-		TokensUsed:   tokensUsed,
+		UserID:            req.UserID,
+		APIKeyID:          req.APIKeyID,
+		APIConfigID:       apiConfigID,
+		Model:             req.Model,
+		Method:            "POST",
+		Path:              "/v1/chat/completions",
+		StatusCode:        200,
+		ResponseTime:      int(responseTime.Milliseconds()),
+		TokensUsed:        tokensUsed,
+		QuotaCost:         int64(cost),
+		Provider:          req.Provider,
+		ServiceTier:       req.ServiceTier,
+		ToolsDropped:      req.ToolsDropped,
+		HistoryDropped:    req.HistoryDropped,
+		UpstreamRequestID: req.UpstreamRequestID,
+	}
+	if req.Endpoint != "" {
+		logReq.Path = req.Endpoint
+	}
+	if req.Sizes != nil {
+		logReq.RequestBytes = req.Sizes.RequestBytes()
+		logReq.ResponseBytes = req.Sizes.ResponseBytes()
 	}
 
 	if err != nil {
 		logReq.StatusCode = 500
+		if _, ok := err.(*HistoryLimitError); ok {
+			logReq.StatusCode = 400
+		}
 		logReq.ErrorMsg = err.Error()
 	}
 
-	if err := s.logService.CreateLog(context.Background(), logReq); err != nil {
-		s.logger.Warn("Failed to create log", logger.Error(err))
+	// 关闭请求日志时只累加聚合计数，计费不受影响（扣费已基于配额计数完成）
+	// 没有日志可关联，自动退款按网关请求 ID 记账
+	if req.skipLog() {
+		if err := s.logService.RecordUsage(context.Background(), logReq); err != nil {
+			s.log(ctx).Warn("Failed to record usage counter", logger.Error(err))
+		}
+		s.checkPayloadSize(logReq, 0)
+		return 0
+	}
+
+	// 体积告警需要附带日志 ID，同样同步写入
+	if !needID && !s.payloadExceeded(logReq) {
+		s.logService.CreateLogAsync(logReq)
+		return 0
+	}
+
+	requestLog, err := s.logService.CreateLog(context.Background(), logReq)
+	if err != nil {
+		s.log(ctx).Warn("Failed to create log", logger.Error(err))
+		s.checkPayloadSize(logReq, 0)
+		return 0
 	}
+	s.checkPayloadSize(logReq, requestLog.ID)
+	return requestLog.ID
 }
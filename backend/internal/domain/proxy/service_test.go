@@ -0,0 +1,251 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/internal/domain/quota"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakePricing 固定费用的定价服务
+type fakePricing struct {
+	pricing.Service
+	cost float64
+}
+
+func (f *fakePricing) CalculateCost(ctx context.Context, req *pricing.CalculateCostRequest) (*pricing.CostCalculationResponse, error) {
+	return &pricing.CostCalculationResponse{TotalCost: f.cost}, nil
+}
+
+// fakeQuota 记录扣费与自动退款调用
+type fakeQuota struct {
+	quota.Service
+	deducted        int64
+	refundedLog     uint
+	refundedRequest string
+	refundCalls     int
+	released        int64
+}
+
+func (f *fakeQuota) DeductQuota(ctx context.Context, userID uint, amount int64) error {
+	f.deducted += amount
+	return nil
+}
+
+func (f *fakeQuota) ReleaseQuota(ctx context.Context, userID uint, amount int64) error {
+	f.released += amount
+	return nil
+}
+
+func (f *fakeQuota) AutoRefund(ctx context.Context, userID, requestLogID uint, reason string) (int64, error) {
+	f.refundCalls++
+	f.refundedLog = requestLogID
+	return f.deducted, nil
+}
+
+func (f *fakeQuota) AutoRefundRequest(ctx context.Context, userID uint, requestID string, charged int64, reason string) (int64, error) {
+	f.refundCalls++
+	f.refundedRequest = requestID
+	return charged, nil
+}
+
+// fakeLog 为每条日志分配递增ID
+type fakeLog struct {
+	log.Service
+	created []*log.CreateLogRequest
+	usage   []*log.CreateLogRequest
+	async   int // 经批量写入的日志数
+}
+
+func (f *fakeLog) RecordUsage(ctx context.Context, req *log.CreateLogRequest) error {
+	f.usage = append(f.usage, req)
+	return nil
+}
+
+func (f *fakeLog) CreateLog(ctx context.Context, req *log.CreateLogRequest) (*log.RequestLog, error) {
+	f.created = append(f.created, req)
+	return &log.RequestLog{ID: uint(len(f.created)), QuotaCost: req.QuotaCost}, nil
+}
+
+func (f *fakeLog) CreateLogAsync(req *log.CreateLogRequest) {
+	f.created = append(f.created, req)
+	f.async++
+}
+
+func newBillingTestService(q *fakeQuota, l *fakeLog) *service {
+	return &service{
+		pricingService: &fakePricing{cost: 42},
+		quotaService:   q,
+		logService:     l,
+		runtimeConfig:  runtime.NewManager(nil),
+		logger:         *logger.NewNop(),
+	}
+}
+
+func drainStream(t *testing.T, svc *service, body string) {
+	t.Helper()
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader(body)), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	if _, err := io.ReadAll(w); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+}
+
+func TestStreamWrapper_AutoRefundsEmptyStream(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+
+	drainStream(t, svc, "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\ndata: [DONE]\n\n")
+
+	if q.deducted != 42 {
+		t.Errorf("Expected estimated charge of 42, got %d", q.deducted)
+	}
+	if len(l.created) != 1 || l.created[0].QuotaCost != 42 {
+		t.Fatalf("Expected one log with quota_cost 42, got %+v", l.created)
+	}
+	if q.refundCalls != 1 || q.refundedLog != 1 {
+		t.Errorf("Expected auto refund for log 1, got %d calls (log %d)", q.refundCalls, q.refundedLog)
+	}
+}
+
+func TestStreamWrapper_AutoRefundsUpstreamError(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+
+	drainStream(t, svc, "data: {\"error\":{\"message\":\"overloaded\"}}\n\n")
+
+	if q.refundCalls != 1 {
+		t.Errorf("Expected auto refund after upstream error, got %d calls", q.refundCalls)
+	}
+}
+
+func TestStreamWrapper_BillsDeliveredContentBeforeUpstreamError(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+
+	drainStream(t, svc, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\ndata: {\"error\":{\"message\":\"overloaded\"}}\n\n")
+
+	if q.refundCalls != 0 {
+		t.Errorf("Expected no refund once content was delivered, got %d calls", q.refundCalls)
+	}
+	if q.deducted != 42 || len(l.created) != 1 || l.created[0].QuotaCost != 42 {
+		t.Errorf("Expected the delivered content billed, got deducted %d, logs %+v", q.deducted, l.created)
+	}
+}
+
+func TestStreamWrapper_NoRefundOnSuccess(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+
+	drainStream(t, svc, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\ndata: [DONE]\n\n")
+
+	if q.refundCalls != 0 {
+		t.Errorf("Expected no refund for successful stream, got %d calls", q.refundCalls)
+	}
+}
+
+func TestStreamWrapper_LogsSynchronouslyOnlyWhenRefunding(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	drainStream(t, svc, "data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: [DONE]\n\n")
+	if len(l.created) != 1 || l.async != 1 {
+		t.Errorf("Expected a successful stream logged through the batch writer, got %d logs (%d async)", len(l.created), l.async)
+	}
+
+	q, l = &fakeQuota{}, &fakeLog{}
+	svc = newBillingTestService(q, l)
+	drainStream(t, svc, "data: [DONE]\n\n")
+	if len(l.created) != 1 || l.async != 0 || q.refundedLog != 1 {
+		t.Errorf("Expected a refunded stream logged synchronously, got %d logs (%d async), refund for log %d", len(l.created), l.async, q.refundedLog)
+	}
+}
+
+func TestIsDegradedResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *adapter.ChatResponse
+		want bool
+	}{
+		{"nil", nil, true},
+		{"no choices", &adapter.ChatResponse{}, true},
+		{"empty content", &adapter.ChatResponse{Choices: []adapter.ChatChoice{{Message: adapter.Message{Content: ""}}}}, true},
+		{"text", &adapter.ChatResponse{Choices: []adapter.ChatChoice{{Message: adapter.Message{Content: "ok"}}}}, false},
+		{"tool call", &adapter.ChatResponse{Choices: []adapter.ChatChoice{{Message: adapter.Message{ToolCalls: []adapter.ToolCall{{ID: "call_1"}}}}}}, false},
+		{"refusal", &adapter.ChatResponse{Choices: []adapter.ChatChoice{{FinishReason: adapter.FinishReasonContentFilter}}}, false},
+	}
+	for _, tt := range tests {
+		if got := isDegradedResponse(tt.resp); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestStreamWrapper_NoLogSkipsRequestLogButStillBills(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		NoLog:       true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":2,\"total_tokens\":5}}\n\n" +
+		"data: [DONE]\n\n"
+
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader(body)), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	if _, err := io.ReadAll(w); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	if len(l.created) != 0 {
+		t.Errorf("Expected no request_logs rows, got %d", len(l.created))
+	}
+	if q.deducted != 42 {
+		t.Errorf("Expected quota deduction of 42, got %d", q.deducted)
+	}
+	if len(l.usage) != 1 || l.usage[0].TokensUsed != 5 || l.usage[0].QuotaCost != 42 {
+		t.Fatalf("Expected one usage counter increment with tokens 5 and cost 42, got %+v", l.usage)
+	}
+}
+
+func TestStreamWrapper_NoLogRefundsByRequestID(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		NoLog:       true,
+		RequestID:   "req-1",
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader("data: [DONE]\n\n")), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	if _, err := io.ReadAll(w); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	if len(l.created) != 0 {
+		t.Errorf("Expected no request_logs rows, got %d", len(l.created))
+	}
+	if q.refundCalls != 1 || q.refundedRequest != "req-1" {
+		t.Errorf("Expected auto refund keyed on the request ID, got %d calls (request %q)", q.refundCalls, q.refundedRequest)
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+)
+
+// resolveServiceTier 记录实际发送给上游的 service_tier
+// 不支持该字段的供应商会丢弃它，此时按默认层级计费，日志中也不记录
+func (s *service) resolveServiceTier(ctx context.Context, req *ProxyRequest, adapterInstance adapter.Adapter) {
+	requested := req.ChatRequest.ServiceTier
+	req.ServiceTier = adapter.EffectiveServiceTier(adapterInstance, requested)
+	if requested != "" && req.ServiceTier == "" {
+		s.log(ctx).Info("service_tier not supported by provider, dropped",
+			logger.String("service_tier", requested),
+			logger.String("provider", req.Provider))
+	}
+}
+
+// chargedCredits 按 service_tier 倍率调整后实际扣除的积分
+func (s *service) chargedCredits(totalCost float64, tier string) int64 {
+	return int64(totalCost * s.serviceTierPriceMultiplier(tier))
+}
+
+// serviceTierPriceMultiplier 获取 service_tier 的计费倍率（flex 可配置为更便宜，priority 更贵）
+func (s *service) serviceTierPriceMultiplier(tier string) float64 {
+	if tier == "" || s.runtimeConfig == nil {
+		return 1
+	}
+	return s.runtimeConfig.Get().GetServiceTierPriceMultiplier(tier)
+}
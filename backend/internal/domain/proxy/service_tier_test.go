@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestResolveServiceTier_DropsForUnsupportedProviders(t *testing.T) {
+	svc := newBillingTestService(&fakeQuota{}, &fakeLog{})
+	tests := []struct {
+		name    string
+		adapter adapter.Adapter
+		tier    string
+		want    string
+	}{
+		{"openai passes through", adapter.NewOpenAIAdapter(&adapter.Config{}), adapter.ServiceTierFlex, adapter.ServiceTierFlex},
+		{"anthropic maps default", adapter.NewAnthropicAdapter(&adapter.Config{}), adapter.ServiceTierDefault, "standard_only"},
+		{"anthropic drops flex", adapter.NewAnthropicAdapter(&adapter.Config{}), adapter.ServiceTierFlex, ""},
+		{"gemini drops", adapter.NewGeminiAdapter(&adapter.Config{}), adapter.ServiceTierPriority, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &ProxyRequest{ChatRequest: &adapter.ChatRequest{ServiceTier: tt.tier}}
+			svc.resolveServiceTier(context.Background(), req, tt.adapter)
+			if req.ServiceTier != tt.want {
+				t.Errorf("Expected effective tier %q, got %q", tt.want, req.ServiceTier)
+			}
+		})
+	}
+}
+
+func TestCalculateAndDeductCost_AppliesServiceTierMultiplier(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newBillingTestService(q, &fakeLog{})
+	svc.runtimeConfig.Get().FlexTierPriceMultiplier = 0.5
+	svc.runtimeConfig.Get().PriorityTierPriceMultiplier = 2
+
+	tests := []struct {
+		tier string
+		want int64
+	}{
+		{"", 42},
+		{adapter.ServiceTierDefault, 42},
+		{adapter.ServiceTierFlex, 21},
+		{adapter.ServiceTierPriority, 84},
+	}
+	for _, tt := range tests {
+		q.deducted = 0
+		cost, err := svc.calculateAndDeductCost(context.Background(), 1, 1, "gpt-4", tt.tier, adapter.UsageInfo{PromptTokens: 10})
+		if err != nil {
+			t.Fatalf("Tier %q: unexpected error: %v", tt.tier, err)
+		}
+		if int64(cost) != tt.want || q.deducted != tt.want {
+			t.Errorf("Tier %q: expected cost %d, got %d (deducted %d)", tt.tier, tt.want, cost, q.deducted)
+		}
+	}
+}
+
+func TestCalculateAndDeductCost_UnconfiguredMultiplierIsNeutral(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newBillingTestService(q, &fakeLog{})
+
+	if _, err := svc.calculateAndDeductCost(context.Background(), 1, 1, "gpt-4", adapter.ServiceTierFlex, adapter.UsageInfo{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if q.deducted != 42 {
+		t.Errorf("Expected flex billed at the default price without configuration, got %d", q.deducted)
+	}
+}
+
+func TestLogRequest_RecordsEffectiveServiceTier(t *testing.T) {
+	l := &fakeLog{}
+	svc := newBillingTestService(&fakeQuota{}, l)
+
+	req := &ProxyRequest{UserID: 1, Model: "gpt-4", ServiceTier: adapter.ServiceTierFlex}
+	svc.logRequest(context.Background(), req, 1, 10, 21, time.Millisecond, nil, false)
+	if len(l.created) != 1 || l.created[0].ServiceTier != adapter.ServiceTierFlex {
+		t.Fatalf("Expected service tier recorded in the request log, got %+v", l.created)
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler_CancelStreamAbortsUpstreamAndBillsPartialOutput(t *testing.T) {
+	aborted := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", strings.Repeat("p", 40))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	svc.pricingService = &perTokenPricing{}
+	svc.streams = newStreamRegistry()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(5))
+		c.Set("api_key_id", uint(9))
+		if c.GetHeader("X-Test-User") == "other" {
+			c.Set("user_id", uint(6))
+		}
+		c.Next()
+	})
+	handler := NewHandler(svc)
+	engine.POST("/v1/chat/completions", handler.ChatCompletionsOpenAI)
+	engine.POST("/v1/cancel/:stream_id", handler.CancelStream)
+	gateway := httptest.NewServer(engine)
+	defer gateway.Close()
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	streamID := resp.Header.Get(StreamIDHeader)
+	if streamID == "" {
+		t.Fatal("Expected the stream ID in the response header")
+	}
+
+	// 其他用户不能取消
+	other, _ := http.NewRequest(http.MethodPost, gateway.URL+"/v1/cancel/"+streamID, nil)
+	other.Header.Set("X-Test-User", "other")
+	if res, err := http.DefaultClient.Do(other); err != nil || res.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected another user's cancel to be rejected, got %v %v", res, err)
+	}
+
+	res, err := http.Post(gateway.URL+"/v1/cancel/"+streamID, "application/json", nil)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected cancel to succeed, got %v %v", res, err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream request to be aborted")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "ppp") || !strings.Contains(string(body), "stream_cancelled") {
+		t.Errorf("Expected partial content followed by the cancelled event, got %q", body)
+	}
+
+	q := svc.quotaService.(*openQuota)
+	if q.deducted != 10 {
+		t.Errorf("Expected billing for the 10 delivered tokens only, deducted %d", q.deducted)
+	}
+	if got := svc.ActiveStreams(); len(got) != 0 {
+		t.Errorf("Expected the cancelled stream deregistered, got %+v", got)
+	}
+}
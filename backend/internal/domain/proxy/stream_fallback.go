@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// emulatedStreamChunk 模拟流的数据块，最后一块携带用量
+type emulatedStreamChunk struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []emulatedStreamChoice `json:"choices"`
+	Usage   *adapter.UsageInfo     `json:"usage,omitempty"`
+}
+
+type emulatedStreamChoice struct {
+	Index        int                 `json:"index"`
+	Delta        emulatedStreamDelta `json:"delta"`
+	FinishReason string              `json:"finish_reason,omitempty"`
+}
+
+type emulatedStreamDelta struct {
+	Role      string                   `json:"role,omitempty"`
+	Content   string                   `json:"content,omitempty"`
+	ToolCalls []emulatedStreamToolCall `json:"tool_calls,omitempty"`
+}
+
+// emulatedStreamToolCall 流式工具调用需要带上序号
+type emulatedStreamToolCall struct {
+	Index int `json:"index"`
+	adapter.ToolCall
+}
+
+// shouldEmulateStream 适配器不支持流式且配置允许时，流式请求改为非流式调用并模拟流
+func (s *service) shouldEmulateStream(adapterInstance adapter.Adapter) bool {
+	return !adapter.GetCapabilities(adapterInstance).Streaming && s.runtimeConfig.Get().IsStreamFallbackEmulated()
+}
+
+// callEmulatedStream 以非流式调用上游，把完整响应按 OpenAI SSE 格式输出为单个内容块，
+// 随后是携带用量的结束块和 [DONE]，由 StreamWrapper 和协议转换器照常处理
+func (s *service) callEmulatedStream(ctx context.Context, adapterInstance adapter.Adapter, req *adapter.ChatRequest) (*http.Response, error) {
+	chatReq := *req
+	chatReq.Stream = false
+	chatReq.StreamOptions = nil
+	resp, err := adapterInstance.Call(ctx, &chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+	newChunk := func(choices []emulatedStreamChoice, usage *adapter.UsageInfo) emulatedStreamChunk {
+		return emulatedStreamChunk{ID: resp.ID, Object: "chat.completion.chunk", Created: time.Now().Unix(), Model: model, Choices: choices, Usage: usage}
+	}
+
+	var content, finish []emulatedStreamChoice
+	for _, choice := range resp.Choices {
+		delta := emulatedStreamDelta{Role: "assistant", Content: adapter.GetContentAsString(choice.Message.Content)}
+		for i, call := range choice.Message.ToolCalls {
+			delta.ToolCalls = append(delta.ToolCalls, emulatedStreamToolCall{Index: i, ToolCall: call})
+		}
+		finishReason := choice.FinishReason
+		if finishReason == "" {
+			finishReason = "stop"
+		}
+		content = append(content, emulatedStreamChoice{Index: choice.Index, Delta: delta})
+		finish = append(finish, emulatedStreamChoice{Index: choice.Index, FinishReason: finishReason})
+	}
+	usage := resp.Usage
+
+	var body bytes.Buffer
+	for _, chunk := range []emulatedStreamChunk{newChunk(content, nil), newChunk(finish, &usage)} {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&body, "data: %s\n\n", data)
+	}
+	body.WriteString("data: [DONE]\n\n")
+
+	streamResp := &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Body:          io.NopCloser(&body),
+		ContentLength: int64(body.Len()),
+		Header:        make(http.Header),
+	}
+	streamResp.Header.Set("Content-Type", "text/event-stream")
+	return streamResp, nil
+}
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// nonStreamingAdapter 只支持非流式调用的上游
+type nonStreamingAdapter struct {
+	calls      int
+	streamSeen bool
+}
+
+func (a *nonStreamingAdapter) Call(ctx context.Context, req *adapter.ChatRequest) (*adapter.ChatResponse, error) {
+	a.calls++
+	a.streamSeen = req.Stream
+	return &adapter.ChatResponse{
+		ID:      "chatcmpl-1",
+		Model:   "batch-model",
+		Choices: []adapter.ChatChoice{{Message: adapter.Message{Role: "assistant", Content: "Hello there"}, FinishReason: "stop"}},
+		Usage:   adapter.UsageInfo{PromptTokens: 12, CompletionTokens: 34, TotalTokens: 46},
+	}, nil
+}
+
+func (a *nonStreamingAdapter) CallStream(ctx context.Context, req *adapter.ChatRequest) (*http.Response, error) {
+	panic("CallStream must not be used for an adapter without streaming support")
+}
+
+func (a *nonStreamingAdapter) GetType() string { return "custom" }
+
+func (a *nonStreamingAdapter) Capabilities() adapter.Capabilities {
+	return adapter.Capabilities{Tools: true}
+}
+
+func (a *nonStreamingAdapter) HealthCheck(ctx context.Context) error { return nil }
+
+func TestStreamFallback_EmulatesStreamFromNonStreamingCall(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+	svc.runtimeConfig.Get().StreamFallbackEmulate = true
+	upstream := &nonStreamingAdapter{}
+
+	if !svc.shouldEmulateStream(upstream) {
+		t.Fatal("Expected the stream emulated for an adapter without streaming support")
+	}
+	chatReq := &adapter.ChatRequest{Model: "batch-model", Stream: true, Messages: []adapter.Message{{Role: "user", Content: "hi"}}}
+	resp, err := svc.callEmulatedStream(context.Background(), upstream, chatReq)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if upstream.calls != 1 || upstream.streamSeen {
+		t.Errorf("Expected a single non-streaming upstream call, got calls=%d stream=%v", upstream.calls, upstream.streamSeen)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", ct)
+	}
+
+	req := &ProxyRequest{UserID: 1, Model: "batch-model", Stream: true, ChatRequest: chatReq}
+	w := NewStreamWrapper(resp.Body, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	out, err := io.ReadAll(w)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	body := string(out)
+	if !strings.Contains(body, `"delta":{"role":"assistant","content":"Hello there"}`) {
+		t.Errorf("Expected the full response in a single content chunk, got %s", body)
+	}
+	if !strings.Contains(body, `"finish_reason":"stop"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("Expected a finish chunk followed by [DONE], got %s", body)
+	}
+	if q.deducted != 34 {
+		t.Errorf("Expected billing from the upstream usage (34 completion tokens), deducted %d", q.deducted)
+	}
+	if q.refundCalls != 0 {
+		t.Error("Expected the emulated stream not to be refunded")
+	}
+}
+
+func TestStreamFallback_PolicyOffRejectsStreaming(t *testing.T) {
+	svc := &service{runtimeConfig: runtime.NewManager(nil)}
+	upstream := &nonStreamingAdapter{}
+
+	if svc.shouldEmulateStream(upstream) {
+		t.Fatal("Expected no emulation when the fallback is disabled")
+	}
+	err := adapter.ValidateRequest(upstream, &adapter.ChatRequest{Stream: true})
+	if capErr, ok := err.(*adapter.CapabilityError); !ok || capErr.Feature != "streaming" {
+		t.Errorf("Expected a streaming capability error, got %v", err)
+	}
+
+	svc.runtimeConfig.Get().StreamFallbackEmulate = true
+	if svc.shouldEmulateStream(&adapter.EchoAdapter{}) {
+		t.Error("Expected adapters with streaming support to stream normally")
+	}
+}
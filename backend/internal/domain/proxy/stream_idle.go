@@ -0,0 +1,33 @@
+package proxy
+
+import "time"
+
+// streamIdleTimeoutEvent 上游空闲超时时追加到流末尾的终止事件（OpenAI SSE 格式，由协议转换器统一处理）
+var streamIdleTimeoutEvent = []byte("data: {\"error\":{\"message\":\"Upstream stream idle timeout, response truncated\",\"type\":\"timeout_error\",\"code\":\"stream_idle_timeout\"}}\n\ndata: [DONE]\n\n")
+
+// SetIdleTimeout 启用空闲超时：超过 timeout 未收到上游数据时关闭上游流，timeout <= 0 不启用
+func (w *StreamWrapper) SetIdleTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	w.idleTimeout = timeout
+	w.idleTimer = time.AfterFunc(timeout, func() {
+		w.idleFired.Store(true)
+		// 关闭上游使阻塞中的 Read 立即返回
+		w.reader.Close()
+	})
+}
+
+// resetIdleTimer 收到数据后重新计时
+func (w *StreamWrapper) resetIdleTimer() {
+	if w.idleTimer != nil && !w.idleFired.Load() {
+		w.idleTimer.Reset(w.idleTimeout)
+	}
+}
+
+// stopIdleTimer 流结束或已截断时停止计时
+func (w *StreamWrapper) stopIdleTimer() {
+	if w.idleTimer != nil {
+		w.idleTimer.Stop()
+	}
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallingReader 先返回若干数据块，随后一直阻塞直到被关闭，模拟中途静默的上游
+type stallingReader struct {
+	chunks    []string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if len(r.chunks) > 0 {
+		n := copy(p, r.chunks[0])
+		r.chunks = r.chunks[1:]
+		return n, nil
+	}
+	<-r.closed
+	return 0, fmt.Errorf("read on closed body")
+}
+
+func (r *stallingReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+func TestStreamWrapper_IdleTimeoutBillsDeliveredTokens(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	// 3 个数据块，每块 40 字符（约 10 个 token），之后上游不再发送数据
+	chunk := fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", strings.Repeat("x", 40))
+	upstream := &stallingReader{chunks: []string{chunk, chunk, chunk}, closed: make(chan struct{})}
+
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	w := NewStreamWrapper(upstream, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	w.SetIdleTimeout(50 * time.Millisecond)
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(w)
+		done <- out
+	}()
+
+	var out []byte
+	select {
+	case out = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected stalled stream to be terminated by idle timeout")
+	}
+	w.Close()
+
+	if strings.Count(string(out), strings.Repeat("x", 40)) != 3 {
+		t.Errorf("Expected all delivered chunks forwarded, got %q", out)
+	}
+	if !strings.HasSuffix(string(out), string(streamIdleTimeoutEvent)) {
+		t.Errorf("Expected terminal idle timeout event at end of stream, got %q", out)
+	}
+	if q.deducted != 30 {
+		t.Errorf("Expected billing for 30 delivered tokens, deducted %d", q.deducted)
+	}
+	if q.refundCalls != 0 {
+		t.Error("Expected partial output not to be refunded")
+	}
+	if len(l.created) != 1 {
+		t.Errorf("Expected a single request log, got %d", len(l.created))
+	}
+}
+
+func TestStreamWrapper_IdleTimeoutKeepsHealthyStream(t *testing.T) {
+	svc := newBillingTestService(&fakeQuota{}, &fakeLog{})
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"
+	req := &ProxyRequest{UserID: 1, Model: "gpt-4", Stream: true, ChatRequest: &adapter.ChatRequest{}}
+
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader(body)), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	w.SetIdleTimeout(time.Second)
+	out, err := io.ReadAll(w)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+	if string(out) != body {
+		t.Errorf("Expected stream passed through unchanged, got %q", out)
+	}
+}
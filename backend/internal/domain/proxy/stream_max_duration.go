@@ -0,0 +1,28 @@
+package proxy
+
+import "time"
+
+// streamMaxDurationEvent 流超过最长持续时间时追加到流末尾的终止事件（OpenAI SSE 格式，由协议转换器统一处理）
+var streamMaxDurationEvent = []byte("data: {\"error\":{\"message\":\"Stream exceeded the maximum duration, response truncated\",\"type\":\"timeout_error\",\"code\":\"stream_max_duration\"}}\n\ndata: [DONE]\n\n")
+
+// SetMaxDuration 限制流的最长持续时间：与空闲超时不同，计时从开流开始且不随数据重置，
+// 到期时即使上游仍在输出也关闭上游流，已下发内容照常计费；maxDuration <= 0 不启用
+func (w *StreamWrapper) SetMaxDuration(maxDuration time.Duration) {
+	if maxDuration <= 0 {
+		return
+	}
+	w.maxDuration = maxDuration
+	w.durationTimer = time.AfterFunc(maxDuration, func() {
+		w.durationFired.Store(true)
+		w.stopIdleTimer()
+		// 关闭上游使阻塞中的 Read 立即返回
+		w.reader.Close()
+	})
+}
+
+// stopMaxDurationTimer 流结束或已截断时停止计时
+func (w *StreamWrapper) stopMaxDurationTimer() {
+	if w.durationTimer != nil {
+		w.durationTimer.Stop()
+	}
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flowingReader 持续输出数据块直到被关闭，模拟一直在输出的上游
+type flowingReader struct {
+	chunk     string
+	interval  time.Duration
+	pending   string
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *flowingReader) Read(p []byte) (int, error) {
+	if r.pending == "" {
+		select {
+		case <-r.closed:
+			return 0, fmt.Errorf("read on closed body")
+		case <-time.After(r.interval):
+			r.pending = r.chunk
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *flowingReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+
+func TestStreamWrapper_MaxDurationTerminatesFlowingStream(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	// 每块 40 字符（约 10 个 token），上游一直在输出，空闲超时不会触发
+	content := strings.Repeat("x", 40)
+	upstream := &flowingReader{
+		chunk:    fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", content),
+		interval: 10 * time.Millisecond,
+		closed:   make(chan struct{}),
+	}
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	w := NewStreamWrapper(upstream, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	w.SetIdleTimeout(time.Second)
+	w.SetMaxDuration(100 * time.Millisecond)
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := io.ReadAll(w)
+		done <- out
+	}()
+
+	var out []byte
+	select {
+	case out = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the flowing stream to be terminated at the maximum duration")
+	}
+	w.Close()
+
+	if !strings.HasSuffix(string(out), string(streamMaxDurationEvent)) {
+		t.Errorf("Expected the max duration terminal event at the end of the stream, got %q", out)
+	}
+	delivered := strings.Count(string(out), content)
+	if delivered == 0 {
+		t.Fatal("Expected chunks delivered before the cap")
+	}
+	if q.deducted != int64(delivered*10) {
+		t.Errorf("Expected billing for %d delivered tokens, deducted %d", delivered*10, q.deducted)
+	}
+	if q.refundCalls != 0 {
+		t.Error("Expected delivered output not to be refunded")
+	}
+	if len(l.created) != 1 {
+		t.Errorf("Expected a single request log, got %d", len(l.created))
+	}
+}
+
+func TestStreamWrapper_MaxDurationKeepsShortStream(t *testing.T) {
+	svc := newBillingTestService(&fakeQuota{}, &fakeLog{})
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"
+	req := &ProxyRequest{UserID: 1, Model: "gpt-4", Stream: true, ChatRequest: &adapter.ChatRequest{}}
+
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader(body)), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	w.SetMaxDuration(time.Second)
+	out, err := io.ReadAll(w)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+	if string(out) != body {
+		t.Errorf("Expected stream passed through unchanged, got %q", out)
+	}
+}
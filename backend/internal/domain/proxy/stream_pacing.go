@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// pacedReader 按 tokens/秒 的速率向客户端转发流式数据块
+// 后台协程持续读取上游并缓冲突发的数据块，节流只影响向客户端的写出，不会阻塞上游读取
+type pacedReader struct {
+	ctx context.Context
+	tps float64
+
+	mu      sync.Mutex
+	queue   [][]byte
+	err     error         // 上游读取结束的原因（io.EOF 或读取错误）
+	notify  chan struct{} // 有新数据或上游结束时发出信号
+	current []byte        // 已放行但尚未被 Read 取完的数据
+	next    time.Time     // 下一个数据块最早的放行时间
+	done    chan struct{} // 后台读取协程退出时关闭
+}
+
+// newPacedReader 以 tps 速率转发 r 中的数据块，tps <= 0 时原样返回 r
+func newPacedReader(ctx context.Context, r io.Reader, tps int) io.Reader {
+	if tps <= 0 {
+		return r
+	}
+	p := &pacedReader{
+		ctx:    ctx,
+		tps:    float64(tps),
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go p.fill(r)
+	return p
+}
+
+// fill 逐行读取上游直到结束，数据块进入缓冲队列
+func (p *pacedReader) fill(r io.Reader) {
+	defer close(p.done)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		p.mu.Lock()
+		if len(line) > 0 {
+			p.queue = append(p.queue, line)
+		}
+		if err != nil {
+			p.err = err
+		}
+		p.mu.Unlock()
+
+		select {
+		case p.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// stop 调用 abort 中止阻塞中的上游读取，并等待后台协程退出
+// 客户端断开时后台协程可能仍在读取和解析上游，结算流之前必须先调用 stop
+func (p *pacedReader) stop(abort func()) {
+	abort()
+	<-p.done
+}
+
+// Read 取出下一个数据块，按其中的输出 token 数等待放行
+func (p *pacedReader) Read(buf []byte) (int, error) {
+	if len(p.current) == 0 {
+		line, err := p.pop()
+		if err != nil {
+			return 0, err
+		}
+		if !p.wait(line) {
+			return 0, p.ctx.Err()
+		}
+		p.current = line
+	}
+	n := copy(buf, p.current)
+	p.current = p.current[n:]
+	return n, nil
+}
+
+// pop 等待并取出队首数据块，队列为空且上游已结束时返回结束原因
+func (p *pacedReader) pop() ([]byte, error) {
+	for {
+		p.mu.Lock()
+		if len(p.queue) > 0 {
+			line := p.queue[0]
+			p.queue = p.queue[1:]
+			p.mu.Unlock()
+			return line, nil
+		}
+		err := p.err
+		p.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-p.notify:
+		case <-p.ctx.Done():
+			return nil, p.ctx.Err()
+		}
+	}
+}
+
+// wait 等到数据块的放行时间，并按其 token 数推迟下一个数据块；客户端断开时返回 false
+// 不含输出内容的数据块（空行、usage、[DONE]）不占用速率
+func (p *pacedReader) wait(line []byte) bool {
+	payload, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data: "))
+	if !ok {
+		return true
+	}
+	tokens := estimateTokenCount(streamDeltaChars(payload))
+	if tokens == 0 {
+		return true
+	}
+
+	now := time.Now()
+	if p.next.After(now) {
+		if !sleepContext(p.ctx, p.next.Sub(now)) {
+			return false
+		}
+		now = p.next
+	}
+	p.next = now.Add(time.Duration(float64(tokens) / p.tps * float64(time.Second)))
+	return true
+}
+
+// sleepContext 等待 d，ctx 取消时提前返回 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,150 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// drainTracker 记录上游是否已被读完
+type drainTracker struct {
+	io.Reader
+	drained atomic.Bool
+}
+
+func (d *drainTracker) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	if err == io.EOF {
+		d.drained.Store(true)
+	}
+	return n, err
+}
+
+// pacingStream 生成 chunks 个数据块，每块 40 个字符（约 10 token）
+func pacingStream(chunks int) string {
+	var sb strings.Builder
+	for i := 0; i < chunks; i++ {
+		sb.WriteString(`data: {"choices":[{"delta":{"content":"` + strings.Repeat("a", 40) + `"}}]}` + "\n\n")
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+func TestPacedReader_RespectsConfiguredRate(t *testing.T) {
+	// 10 个数据块共约 100 token，500 tokens/秒时第一块立即放行，其余每块间隔 20ms
+	upstream := &drainTracker{Reader: strings.NewReader(pacingStream(10))}
+	paced := newPacedReader(context.Background(), upstream, 500)
+
+	start := time.Now()
+	data, err := io.ReadAll(paced)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != pacingStream(10) {
+		t.Error("Expected the stream forwarded unchanged")
+	}
+	if elapsed < 180*time.Millisecond {
+		t.Errorf("Expected at least 180ms for 100 tokens at 500 tokens/s, took %v", elapsed)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected pacing close to the configured rate, took %v", elapsed)
+	}
+}
+
+func TestPacedReader_DoesNotStallUpstream(t *testing.T) {
+	upstream := &drainTracker{Reader: strings.NewReader(pacingStream(20))}
+	paced := newPacedReader(context.Background(), upstream, 100)
+
+	reader := bufio.NewReader(paced)
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		t.Fatal(err)
+	}
+	// 客户端只收到第一块，上游已被完整读取并缓冲
+	deadline := time.Now().Add(time.Second)
+	for !upstream.drained.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !upstream.drained.Load() {
+		t.Error("Expected the upstream read to continue while output is paced")
+	}
+}
+
+func TestPacedReader_StopsOnClientDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	paced := newPacedReader(ctx, strings.NewReader(pacingStream(100)), 1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := io.ReadAll(paced)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected the cancellation returned, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the paced read to stop after the client disconnected")
+	}
+}
+
+func TestPacedReader_StopJoinsUpstreamBeforeSettlement(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		PacingTPS:   1,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+
+	// 上游持续输出，客户端断开时后台协程仍在读取和解析
+	body, writer := io.Pipe()
+	go func() {
+		chunk := []byte(`data: {"choices":[{"delta":{"content":"` + strings.Repeat("a", 40) + `"}}]}` + "\n\n")
+		for {
+			if _, err := writer.Write(chunk); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := NewStreamWrapper(body, ctx, svc, req, 1, 0, protocol.ProtocolOpenAI)
+	paced := newPacedReader(ctx, w, req.PacingTPS).(*pacedReader)
+	if _, err := bufio.NewReader(paced).ReadBytes('\n'); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	// 与 handler 相同的顺序：先停止后台读取，再结算
+	paced.stop(func() { body.Close() })
+	w.Close()
+
+	if len(l.created) != 1 {
+		t.Fatalf("Expected the stream settled once, got %d logs", len(l.created))
+	}
+	if q.deducted == 0 {
+		t.Error("Expected the delivered output charged after the client disconnected")
+	}
+}
+
+func TestPacedReader_DisabledByDefault(t *testing.T) {
+	upstream := strings.NewReader(pacingStream(1))
+	if r := newPacedReader(context.Background(), upstream, 0); r != io.Reader(upstream) {
+		t.Error("Expected the upstream reader returned unchanged when pacing is disabled")
+	}
+}
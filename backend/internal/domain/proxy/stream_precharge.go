@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"time"
+)
+
+// reserveStreamQuota 用户启用预扣时（管理员按用户设置或系统默认，客户端无法选择），按 max_tokens 估算的费用在开流前预扣配额
+// 剩余配额不足以覆盖预扣时直接拒绝，避免发起大量最终被放弃的长流；请求引用配额预留时改用预留
+func (s *service) reserveStreamQuota(ctx context.Context, req *ProxyRequest, apiConfigID uint) error {
+	if req.HoldID != "" {
+		return s.consumeQuotaHold(ctx, req)
+	}
+	if !req.StreamPrecharge || s.runtimeConfig == nil || req.ChatRequest.MaxTokens <= 0 {
+		return nil
+	}
+
+	usage := adapter.UsageInfo{
+		PromptTokens:     estimatePromptTokens(req.ChatRequest),
+		CompletionTokens: int(float64(req.ChatRequest.MaxTokens) * s.runtimeConfig.Get().GetStreamPrechargeRatio()),
+	}
+	amount, err := s.estimateCost(ctx, apiConfigID, req.Model, req.ServiceTier, usage)
+	if err != nil {
+		return errors.Wrap(err, 500005, "Failed to estimate stream reservation")
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	if err := s.quotaService.DeductQuota(ctx, req.UserID, amount); err != nil {
+		if errors.Is(err, errors.ErrQuotaExceeded) {
+			return errors.ErrQuotaExceeded.WithDetails("Insufficient quota to reserve for the requested max_tokens")
+		}
+		return err
+	}
+	req.Reserved = amount
+	s.log(ctx).Info("✓ Stream quota reserved",
+		logger.Uint("user_id", req.UserID),
+		logger.Int64("reserved", amount),
+		logger.Int("max_tokens", req.ChatRequest.MaxTokens))
+	return nil
+}
+
+// releaseStreamQuota 流没有开始（上游调用失败）或非流式请求未计费时退还全部预扣
+func (s *service) releaseStreamQuota(req *ProxyRequest) {
+	if req.Reserved <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.quotaService.ReleaseQuota(ctx, req.UserID, req.Reserved); err != nil {
+		s.log(ctx).Error("✗ Failed to release stream reservation", logger.Uint("user_id", req.UserID), logger.Error(err))
+		return
+	}
+	req.Reserved = 0
+}
+
+// settleStreamCost 按实际用量结算请求：没有预扣时直接扣费；有预扣（含引用的配额预留）时补扣差额或退还多扣部分
+// 返回实际费用
+func (s *service) settleStreamCost(ctx context.Context, req *ProxyRequest, apiConfigID uint, usage adapter.UsageInfo) (int, error) {
+	if req.Reserved <= 0 {
+		return s.calculateAndDeductCost(ctx, req.UserID, apiConfigID, req.Model, req.ServiceTier, usage)
+	}
+
+	cost, err := s.estimateCost(ctx, apiConfigID, req.Model, req.ServiceTier, usage)
+	if err != nil {
+		return 0, err
+	}
+	switch diff := cost - req.Reserved; {
+	case diff > 0:
+		err = s.quotaService.DeductQuota(ctx, req.UserID, diff)
+	case diff < 0:
+		err = s.quotaService.ReleaseQuota(ctx, req.UserID, -diff)
+	}
+	if err != nil {
+		return 0, err
+	}
+	req.Reserved = 0
+	return int(cost), nil
+}
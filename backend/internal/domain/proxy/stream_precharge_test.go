@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPrechargeTestService(q *fakeQuota) *service {
+	return &service{
+		pricingService: &perTokenPricing{},
+		quotaService:   q,
+		logService:     &fakeLog{},
+		runtimeConfig:  runtime.NewManager(nil),
+		logger:         *logger.NewNop(),
+	}
+}
+
+// prechargeRequest 配额检查得到用户启用了流式预扣的请求
+func prechargeRequest(maxTokens int, tier string) *ProxyRequest {
+	return &ProxyRequest{UserID: 1, Model: "gpt-4", Stream: true, ServiceTier: tier, StreamPrecharge: true, ChatRequest: &adapter.ChatRequest{
+		Model: "gpt-4", MaxTokens: maxTokens, Messages: []adapter.Message{{Role: "user", Content: "hi"}},
+	}}
+}
+
+func TestReserveStreamQuota_ProportionalToMaxTokens(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newPrechargeTestService(q)
+
+	small, large := prechargeRequest(100, ""), prechargeRequest(4000, "")
+	if err := svc.reserveStreamQuota(context.Background(), small, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.reserveStreamQuota(context.Background(), large, 1); err != nil {
+		t.Fatal(err)
+	}
+	if small.Reserved != 100 || large.Reserved != 4000 || q.deducted != 4100 {
+		t.Errorf("Expected reservations of 100 and 4000, got %d and %d (deducted %d)", small.Reserved, large.Reserved, q.deducted)
+	}
+
+	// 按比例预扣
+	svc.runtimeConfig.Get().StreamPrechargeRatio = 0.5
+	half := prechargeRequest(4000, "")
+	svc.reserveStreamQuota(context.Background(), half, 1)
+	if half.Reserved != 2000 {
+		t.Errorf("Expected half of max_tokens reserved, got %d", half.Reserved)
+	}
+}
+
+func TestReserveStreamQuota_FollowsUserSettingNotServiceTier(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newPrechargeTestService(q)
+
+	// 用户未启用预扣时，客户端选择任何 service_tier 都不预扣；启用后任何层级都预扣
+	for _, tier := range []string{"", adapter.ServiceTierFlex, "priority"} {
+		off := prechargeRequest(4000, tier)
+		off.StreamPrecharge = false
+		if err := svc.reserveStreamQuota(context.Background(), off, 1); err != nil || off.Reserved != 0 {
+			t.Errorf("Expected no reservation for tier %q without the user setting, got %d (%v)", tier, off.Reserved, err)
+		}
+		on := prechargeRequest(4000, tier)
+		if err := svc.reserveStreamQuota(context.Background(), on, 1); err != nil || on.Reserved == 0 {
+			t.Errorf("Expected a reservation for tier %q with the user setting, got %d (%v)", tier, on.Reserved, err)
+		}
+	}
+	if noLimit := prechargeRequest(0, ""); svc.reserveStreamQuota(context.Background(), noLimit, 1) != nil || noLimit.Reserved != 0 {
+		t.Errorf("Expected no reservation without max_tokens, got %d", noLimit.Reserved)
+	}
+}
+
+func TestStreamPrecharge_ShortCompletionReleasesUnused(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newPrechargeTestService(q)
+	req := prechargeRequest(4000, "")
+	if err := svc.reserveStreamQuota(context.Background(), req, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":25,\"total_tokens\":28}}\n\ndata: [DONE]\n\n"
+	w := NewStreamWrapper(io.NopCloser(strings.NewReader(body)), context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	io.ReadAll(w)
+	w.Close()
+
+	if q.released != 3975 {
+		t.Errorf("Expected the unused 3975 of the reservation released, got %d", q.released)
+	}
+	if net := q.deducted - q.released; net != 25 {
+		t.Errorf("Expected the user charged only the 25 tokens used, net charge %d", net)
+	}
+	if logs := svc.logService.(*fakeLog).created; len(logs) != 1 || logs[0].QuotaCost != 25 {
+		t.Errorf("Expected the actual cost logged, got %+v", logs)
+	}
+}
+
+func TestStreamPrecharge_LongCompletionChargesDifference(t *testing.T) {
+	q := &fakeQuota{}
+	svc := newPrechargeTestService(q)
+	svc.runtimeConfig.Get().StreamPrechargeRatio = 0.01
+	req := prechargeRequest(1000, "")
+	svc.reserveStreamQuota(context.Background(), req, 1)
+
+	if _, err := svc.settleStreamCost(context.Background(), req, 1, adapter.UsageInfo{CompletionTokens: 30, TotalTokens: 30}); err != nil {
+		t.Fatal(err)
+	}
+	if q.deducted != 30 || q.released != 0 || req.Reserved != 0 {
+		t.Errorf("Expected reservation of 10 topped up to 30, deducted %d released %d", q.deducted, q.released)
+	}
+}
+
+func TestChatCompletionsStream_ReleasesReservationOnUpstreamFailure(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusServiceUnavailable})
+	defer upstream.Close()
+
+	svc, _ := newFailoverTestService(0, failoverConfig(1, upstream.URL, 0))
+	svc.pricingService = &perTokenPricing{}
+	svc.quotaService.(*openQuota).streamPrecharge = true
+
+	// 预扣取决于配额检查返回的用户设置，而不是请求自带的字段
+	req := prechargeRequest(2000, "")
+	req.StreamPrecharge = false
+	if _, err := svc.ChatCompletionsStream(context.Background(), req); err == nil {
+		t.Fatal("Expected the upstream failure to be returned")
+	}
+	q := svc.quotaService.(*openQuota)
+	if q.deducted != 2000 || q.released != 2000 {
+		t.Errorf("Expected the 2000 reservation released after the failure, deducted %d released %d", q.deducted, q.released)
+	}
+}
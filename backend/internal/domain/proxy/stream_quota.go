@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// streamQuotaExceededEvent 配额耗尽时追加到流末尾的终止事件（OpenAI SSE 格式，由协议转换器统一处理）
+var streamQuotaExceededEvent = []byte("data: {\"error\":{\"message\":\"Quota exhausted during streaming, response truncated\",\"type\":\"insufficient_quota\",\"code\":\"quota_exceeded\"}}\n\ndata: [DONE]\n\n")
+
+// streamQuotaGuard 流式响应中途的配额检查
+// 按估算的输出 token 数周期性检查，累计费用达到剩余配额时截断流
+type streamQuotaGuard struct {
+	interval     int    // 每累计多少估算输出 token 检查一次
+	nextCheck    int    // 下一次检查的输出 token 阈值
+	promptTokens int    // 估算的输入 token
+	outputChars  int    // 已下发的输出字符数
+	pending      []byte // 尚未读到换行的半行数据
+}
+
+// newStreamQuotaGuard 创建配额检查器，interval <= 0 时返回 nil（不检查）
+func newStreamQuotaGuard(interval int, req *adapter.ChatRequest) *streamQuotaGuard {
+	if interval <= 0 {
+		return nil
+	}
+
+	return &streamQuotaGuard{
+		interval:     interval,
+		nextCheck:    interval,
+		promptTokens: estimatePromptTokens(req),
+	}
+}
+
+// observe 记录新读取的数据，达到检查间隔时返回 true
+func (g *streamQuotaGuard) observe(data []byte) bool {
+	g.pending = append(g.pending, data...)
+	for {
+		idx := bytes.IndexByte(g.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		line := bytes.TrimSpace(g.pending[:idx])
+		g.pending = g.pending[idx+1:]
+		if payload, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			g.outputChars += streamDeltaChars(payload)
+		}
+	}
+
+	if g.outputTokens() >= g.nextCheck {
+		g.nextCheck = g.outputTokens() + g.interval
+		return true
+	}
+	return false
+}
+
+func (g *streamQuotaGuard) outputTokens() int {
+	return estimateTokenCount(g.outputChars)
+}
+
+// usage 返回按已下发内容估算的用量
+func (g *streamQuotaGuard) usage() adapter.UsageInfo {
+	return adapter.UsageInfo{
+		PromptTokens:     g.promptTokens,
+		CompletionTokens: g.outputTokens(),
+		TotalTokens:      g.promptTokens + g.outputTokens(),
+	}
+}
+
+// streamDeltaChars 统计一个数据块中的输出字符数（OpenAI、Anthropic、Gemini 格式），工具调用按函数名和参数计
+func streamDeltaChars(data []byte) int {
+	textChars, toolCalls := streamDeltaOutput(data)
+	return textChars + utf8.RuneCountInString(toolCalls)
+}
+
+// streamDeltaOutput 拆分一个数据块中的输出：文本字符数，以及工具调用内容（函数名和参数片段依次拼接）
+func streamDeltaOutput(data []byte) (int, string) {
+	var chunk struct {
+		Choices []struct {
+			Delta struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+		} `json:"choices"`
+		ContentBlock *struct {
+			Name string `json:"name"`
+		} `json:"content_block"`
+		Delta *struct {
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+		} `json:"delta"`
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text         string `json:"text"`
+					FunctionCall *struct {
+						Name string          `json:"name"`
+						Args json.RawMessage `json:"args"`
+					} `json:"functionCall"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return 0, ""
+	}
+
+	chars := 0
+	var toolCalls strings.Builder
+	for _, choice := range chunk.Choices {
+		chars += utf8.RuneCountInString(choice.Delta.Content)
+		for _, tc := range choice.Delta.ToolCalls {
+			toolCalls.WriteString(tc.Function.Name)
+			toolCalls.WriteString(tc.Function.Arguments)
+		}
+	}
+	if chunk.ContentBlock != nil {
+		toolCalls.WriteString(chunk.ContentBlock.Name)
+	}
+	if chunk.Delta != nil {
+		chars += utf8.RuneCountInString(chunk.Delta.Text)
+		toolCalls.WriteString(chunk.Delta.PartialJSON)
+	}
+	for _, candidate := range chunk.Candidates {
+		for _, part := range candidate.Content.Parts {
+			chars += utf8.RuneCountInString(part.Text)
+			if part.FunctionCall != nil {
+				toolCalls.WriteString(part.FunctionCall.Name)
+				toolCalls.Write(part.FunctionCall.Args)
+			}
+		}
+	}
+	return chars, toolCalls.String()
+}
+
+// quotaExhausted 按估算用量计算费用，判断是否已达到用户剩余配额（含剩余透支额度）
+// 查询失败时不截断（fail open），最终以流结束时的实际扣费为准
+func (w *StreamWrapper) quotaExhausted() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	info, err := w.service.quotaService.GetQuotaInfo(ctx, w.req.UserID)
+	if err != nil {
+		w.logger.Warn("Mid-stream quota check failed", logger.Error(err))
+		return false
+	}
+
+	usage := w.quota.usage()
+	cost, err := w.service.estimateCost(ctx, w.apiConfigID, w.req.Model, w.req.ServiceTier, usage)
+	if err != nil {
+		w.logger.Warn("Mid-stream cost estimate failed", logger.Error(err))
+		return false
+	}
+	// 开流时预扣的配额已从剩余配额中扣除，可用于本次流
+	return cost >= info.RemainingQuota+info.OverdraftAvailable()+w.req.Reserved
+}
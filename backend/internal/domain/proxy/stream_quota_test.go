@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/internal/domain/quota"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// perTokenPricing 每个输出 token 计 1 点
+type perTokenPricing struct {
+	pricing.Service
+}
+
+func (f *perTokenPricing) CalculateCost(ctx context.Context, req *pricing.CalculateCostRequest) (*pricing.CostCalculationResponse, error) {
+	return &pricing.CostCalculationResponse{TotalCost: float64(req.OutputTokens)}, nil
+}
+
+// limitedQuota 固定剩余配额
+type limitedQuota struct {
+	fakeQuota
+	remaining int64
+}
+
+func (f *limitedQuota) GetQuotaInfo(ctx context.Context, userID uint) (*quota.QuotaInfoResponse, error) {
+	return &quota.QuotaInfoResponse{RemainingQuota: f.remaining - f.deducted}, nil
+}
+
+// chunkReader 每次 Read 只返回一个 SSE 数据块，模拟逐块到达的上游流
+type chunkReader struct {
+	chunks []string
+	closed bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.closed || len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	if n < len(r.chunks[0]) {
+		r.chunks[0] = r.chunks[0][n:]
+	} else {
+		r.chunks = r.chunks[1:]
+	}
+	return n, nil
+}
+
+func (r *chunkReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestStreamWrapper_StopsStreamWhenQuotaRunsOut(t *testing.T) {
+	q := &limitedQuota{remaining: 100}
+	l := &fakeLog{}
+	svc := newBillingTestService(&q.fakeQuota, l)
+	svc.quotaService = q
+	svc.pricingService = &perTokenPricing{}
+	svc.runtimeConfig.Get().StreamQuotaCheckTokens = 20
+
+	// 200 个数据块，每块 40 字符（约 10 个 token），总计远超 100 点配额
+	var chunks []string
+	for i := 0; i < 200; i++ {
+		chunks = append(chunks, fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":\"%s\"}}]}\n\n", strings.Repeat("x", 40)))
+	}
+	chunks = append(chunks, "data: [DONE]\n\n")
+	upstream := &chunkReader{chunks: chunks}
+
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+	w := NewStreamWrapper(upstream, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+
+	out, err := io.ReadAll(w)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	delivered := strings.Count(string(out), strings.Repeat("x", 40))
+	if delivered >= 200 || delivered < 10 {
+		t.Fatalf("Expected stream to be cut off near the quota limit, delivered %d chunks", delivered)
+	}
+	if !upstream.closed {
+		t.Error("Expected upstream body to be closed after quota exhaustion")
+	}
+	if !strings.HasSuffix(string(out), string(streamQuotaExceededEvent)) {
+		t.Errorf("Expected terminal quota error event at end of stream, got tail %q", string(out[len(out)-120:]))
+	}
+
+	// 按已下发内容计费：每块 10 token、每 token 1 点
+	if q.deducted != int64(delivered*10) {
+		t.Errorf("Expected billing for %d delivered tokens, deducted %d", delivered*10, q.deducted)
+	}
+	if q.deducted > 100+20+10 {
+		t.Errorf("Expected cut-off within one check interval of the limit, deducted %d", q.deducted)
+	}
+	if len(l.created) != 1 {
+		t.Errorf("Expected a single request log, got %d", len(l.created))
+	}
+}
+
+func TestStreamQuotaGuard_DisabledWhenIntervalZero(t *testing.T) {
+	if newStreamQuotaGuard(0, &adapter.ChatRequest{}) != nil {
+		t.Error("Expected no guard when interval is 0")
+	}
+}
@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/protocol"
+	"bytes"
+)
+
+// maxStreamLineBytes 单行 SSE 数据参与解析的上限，超长的行照常下发但不解析，避免异常上游撑大缓冲
+const maxStreamLineBytes = 1 << 20
+
+// streamLineParser 在转发过程中按行切分 SSE 数据，完整的行立即交给回调，只保留未结束的半行
+type streamLineParser struct {
+	partial []byte
+	tooLong bool // 当前半行已超出上限，丢弃到下一个换行为止
+}
+
+// feed 处理新读到的数据，data 只在调用期间使用，不被保留
+func (p *streamLineParser) feed(data []byte, handle func(line []byte)) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			p.keep(data)
+			return
+		}
+		if len(p.partial) == 0 && !p.tooLong {
+			handle(data[:i])
+		} else {
+			p.keep(data[:i])
+			if !p.tooLong {
+				handle(p.partial)
+			}
+			p.partial = p.partial[:0]
+			p.tooLong = false
+		}
+		data = data[i+1:]
+	}
+}
+
+// flush 流结束时处理没有以换行结尾的最后一行
+func (p *streamLineParser) flush(handle func(line []byte)) {
+	if len(p.partial) > 0 && !p.tooLong {
+		handle(p.partial)
+	}
+	p.partial = nil
+	p.tooLong = false
+}
+
+func (p *streamLineParser) keep(data []byte) {
+	if p.tooLong {
+		return
+	}
+	if len(p.partial)+len(data) > maxStreamLineBytes {
+		p.partial = p.partial[:0]
+		p.tooLong = true
+		return
+	}
+	p.partial = append(p.partial, data...)
+}
+
+// parseLine 解析一行 SSE 数据：统计已下发的文本和工具调用，并提取上游报告的用量
+// （OpenAI 兼容上游在 stream_options.include_usage 时于最后一个 choices 为空的数据块中报告）
+func (w *StreamWrapper) parseLine(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	data, ok := bytes.CutPrefix(line, []byte("data: "))
+	// 跳过空行、注释、非 data 字段和 [DONE] 标记
+	if !ok || string(data) == "[DONE]" {
+		return
+	}
+
+	chars, toolCall := streamDeltaOutput(data)
+	w.textChars += chars
+	w.toolCalls.WriteString(toolCall)
+
+	// 根据协议解析数据
+	switch w.proto {
+	case protocol.ProtocolOpenAI, protocol.ProtocolAnthropic, protocol.ProtocolResponses:
+		w.parseOpenAIChunk(string(data))
+	case protocol.ProtocolGemini:
+		w.parseGeminiChunk(string(data))
+	}
+}
@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamWrapper_BillsProviderUsageFromFinalChunk(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	// OpenAI 兼容上游开启 include_usage 时，最后一个数据块 choices 为空并携带真实用量
+	body := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}],\"usage\":null}\r\n\r\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}],\"usage\":null}\r\n\r\n" +
+		"data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}],\"usage\":null}\r\n\r\n" +
+		"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":37,\"completion_tokens\":123,\"total_tokens\":160}}\r\n\r\n" +
+		"data: [DONE]\r\n\r\n"
+	req := &ProxyRequest{
+		UserID:      1,
+		Model:       "gpt-4",
+		Stream:      true,
+		ChatRequest: &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: "hi"}}},
+	}
+
+	// 每次只读一个字节，数据块在任意位置被切开
+	upstream := io.NopCloser(iotest.OneByteReader(strings.NewReader(body)))
+	w := NewStreamWrapper(upstream, context.Background(), svc, req, 1, 0, protocol.ProtocolOpenAI)
+	out, err := io.ReadAll(w)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	if string(out) != body {
+		t.Errorf("Expected stream passed through unchanged, got %q", out)
+	}
+	if q.deducted != 123 {
+		t.Errorf("Expected billing for the provider-reported 123 completion tokens, deducted %d", q.deducted)
+	}
+	if w.usage.PromptTokens != 37 || w.usage.TotalTokens != 160 {
+		t.Errorf("Expected provider usage reconciled, got %+v", w.usage)
+	}
+	if q.refundCalls != 0 {
+		t.Error("Expected a successful stream not to be refunded")
+	}
+	logs := append(l.created, l.usage...)
+	if len(logs) != 1 || logs[0].TokensUsed != 160 {
+		t.Errorf("Expected a single request log with 160 tokens, got %+v", logs)
+	}
+}
+
+func TestStreamLineParser_KeepsOnlyPartialLine(t *testing.T) {
+	var p streamLineParser
+	var lines []string
+	handle := func(line []byte) { lines = append(lines, string(line)) }
+
+	p.feed([]byte("data: a\nda"), handle)
+	if len(p.partial) != 2 {
+		t.Errorf("Expected only the unfinished line retained, got %q", p.partial)
+	}
+	p.feed([]byte("ta: b\n\ndata: c"), handle)
+	p.flush(handle)
+
+	want := []string{"data: a", "data: b", "", "data: c"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected lines %q, got %q", want, lines)
+	}
+}
+
+func TestStreamLineParser_SkipsOverlongLine(t *testing.T) {
+	var p streamLineParser
+	var lines []string
+	handle := func(line []byte) { lines = append(lines, string(line)) }
+
+	chunk := []byte("data: " + strings.Repeat("x", maxStreamLineBytes/4))
+	for i := 0; i < 5; i++ {
+		p.feed(chunk, handle)
+	}
+	if len(p.partial) != 0 {
+		t.Errorf("Expected an overlong line not to be buffered, got %d bytes", len(p.partial))
+	}
+	p.feed([]byte("\ndata: next\n"), handle)
+
+	if len(lines) != 1 || lines[0] != "data: next" {
+		t.Errorf("Expected parsing to resume after the overlong line, got %d lines", len(lines))
+	}
+}
@@ -4,29 +4,56 @@ import (
 	"api-aggregator/backend/internal/adapter"
 	"api-aggregator/backend/internal/protocol"
 	"api-aggregator/backend/pkg/logger"
-	"bufio"
-	"bytes"
+	"api-aggregator/backend/pkg/tracing"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // StreamWrapper 包装流式响应，用于拦截和解析 token 使用信息
 type StreamWrapper struct {
 	reader       io.ReadCloser
-	buffer       *bytes.Buffer
 	usage        *adapter.UsageInfo
 	startTime    time.Time
-	logger       logger.Logger
+	logger       *logger.Logger
 	ctx          context.Context
 	service      *service
 	req          *ProxyRequest
 	apiConfigID  uint
 	credentialID uint
 	proto        protocol.Protocol
+	trace        *streamTrace // 流关闭时结束的 span（未追踪时为 nil）
+	hasOutput    bool   // 是否收到过任何文本或工具调用
+	upstreamErr  string // 流中出现的上游错误
+
+	lines     streamLineParser // 边读边解析 SSE 行，只保留未结束的半行
+	textChars int              // 已下发的文本字符数
+	toolCalls strings.Builder  // 已下发的工具调用参数
+	settled   bool             // 已完成计费和日志记录
+
+	quota         *streamQuotaGuard // 中途配额检查（未启用时为 nil）
+	quotaExceeded bool              // 因配额耗尽被截断
+	terminal      []byte            // 截断后待输出的终止事件
+
+	idleTimeout  time.Duration // 上游空闲超时（未启用时为 0）
+	idleTimer    *time.Timer   // 空闲计时器，每次收到数据重置
+	idleFired    atomic.Bool   // 计时器已触发并关闭了上游
+	idleTimedOut bool          // 因上游空闲被截断
+
+	maxDuration      time.Duration // 流的最长持续时间（未启用时为 0）
+	durationTimer    *time.Timer   // 最长持续时间计时器，不随数据重置
+	durationFired    atomic.Bool   // 计时器已触发并关闭了上游
+	durationExceeded bool          // 因超过最长持续时间被截断
+
+	terminateFired  atomic.Bool // 管理员强制结束或客户端取消，已关闭上游
+	cancelRequested atomic.Bool // 由客户端取消而非管理员终止
+	terminated      bool        // 因强制结束或取消被截断
 }
 
 // NewStreamWrapper 创建流式响应包装器
@@ -41,25 +68,93 @@ func NewStreamWrapper(
 ) *StreamWrapper {
 	return &StreamWrapper{
 		reader:       reader,
-		buffer:       &bytes.Buffer{},
 		usage:        &adapter.UsageInfo{},
 		startTime:    time.Now(),
-		logger:       service.logger,
+		logger:       service.log(ctx),
 		ctx:          ctx,
 		service:      service,
 		req:          req,
 		apiConfigID:  apiConfigID,
 		credentialID: credentialID,
 		proto:        proto,
+		quota:        newStreamQuotaGuard(service.runtimeConfig.Get().GetStreamQuotaCheckTokens(), req.ChatRequest),
 	}
 }
 
 // Read 实现 io.Reader 接口，拦截并解析流数据
 func (w *StreamWrapper) Read(p []byte) (n int, err error) {
+	// 配额耗尽、上游空闲超时、超过最长持续时间或被强制结束后输出终止事件，随后结束流
+	if w.quotaExceeded || w.idleTimedOut || w.durationExceeded || w.terminated {
+		if len(w.terminal) > 0 {
+			n = copy(p, w.terminal)
+			w.terminal = w.terminal[n:]
+			return n, nil
+		}
+		w.parseUsageAndLog()
+		return 0, io.EOF
+	}
+
 	n, err = w.reader.Read(p)
 	if n > 0 {
-		// 将读取的数据写入缓冲区用于解析
-		w.buffer.Write(p[:n])
+		w.resetIdleTimer()
+
+		// 逐行解析已读取的数据，提取输出和上游报告的用量，不缓冲整个流也不改动下发的数据
+		w.lines.feed(p[:n], w.parseLine)
+		if w.req.Sizes != nil {
+			w.req.Sizes.AddResponseBytes(int64(n))
+		}
+
+		// 周期性检查配额，耗尽时截断上游流（本次已读取的数据仍然下发）
+		if w.quota != nil && err == nil && w.quota.observe(p[:n]) && w.quotaExhausted() {
+			w.logger.Warn("Quota exhausted mid-stream, terminating stream",
+				logger.Uint("user_id", w.req.UserID),
+				logger.String("model", w.req.Model),
+				logger.Int("estimated_output_tokens", w.quota.outputTokens()))
+			w.quotaExceeded = true
+			w.terminal = streamQuotaExceededEvent
+			w.stopIdleTimer()
+			w.stopMaxDurationTimer()
+			w.reader.Close()
+		}
+	}
+
+	// 空闲计时器关闭上游导致的读取错误，转为输出终止事件
+	if err != nil && w.idleFired.Load() && !w.quotaExceeded {
+		w.logger.Warn("Upstream stream idle, terminating stream",
+			logger.Uint("user_id", w.req.UserID),
+			logger.String("model", w.req.Model),
+			logger.Duration("idle_timeout", w.idleTimeout))
+		w.idleTimedOut = true
+		w.terminal = streamIdleTimeoutEvent
+		return n, nil
+	}
+
+	// 最长持续时间计时器关闭上游导致的读取错误，转为输出终止事件
+	if err != nil && w.durationFired.Load() && !w.quotaExceeded {
+		w.logger.Warn("Stream exceeded maximum duration, terminating stream",
+			logger.Uint("user_id", w.req.UserID),
+			logger.String("model", w.req.Model),
+			logger.Duration("max_duration", w.maxDuration))
+		w.durationExceeded = true
+		w.terminal = streamMaxDurationEvent
+		return n, nil
+	}
+
+	// 强制结束或取消关闭上游导致的读取错误，同样转为输出终止事件
+	if err != nil && w.terminateFired.Load() && !w.quotaExceeded && !w.idleTimedOut {
+		w.terminated = true
+		if w.cancelRequested.Load() {
+			w.logger.Info("Stream cancelled by client",
+				logger.Uint("user_id", w.req.UserID),
+				logger.String("model", w.req.Model))
+			w.terminal = streamCancelledEvent
+		} else {
+			w.logger.Warn("Stream terminated by administrator",
+				logger.Uint("user_id", w.req.UserID),
+				logger.String("model", w.req.Model))
+			w.terminal = streamTerminatedEvent
+		}
+		return n, nil
 	}
 
 	// 如果读取完成（EOF），解析 token 使用信息并记录日志
@@ -73,49 +168,55 @@ func (w *StreamWrapper) Read(p []byte) (n int, err error) {
 // Close 实现 io.Closer 接口
 func (w *StreamWrapper) Close() error {
 	// 确保在关闭时也解析和记录（防止 Read 没有返回 EOF）
-	if w.usage.TotalTokens == 0 {
-		w.parseUsageAndLog()
+	w.parseUsageAndLog()
+	err := w.reader.Close()
+	w.trace.end(w.traceError())
+	return err
+}
+
+// SetTrace 设置流关闭时结束的请求和上游调用 span，流结算时的计费 span 作为请求 span 的子 span
+func (w *StreamWrapper) SetTrace(t *streamTrace) {
+	w.trace = t
+}
+
+// traceError 流中出现上游错误或上游空闲超时时返回记录到 span 的错误
+func (w *StreamWrapper) traceError() error {
+	switch {
+	case w.upstreamErr != "":
+		return errors.New("upstream stream error: " + w.upstreamErr)
+	case w.idleTimedOut:
+		return errors.New("upstream stream idle timeout")
 	}
-	return w.reader.Close()
+	return nil
 }
 
-// parseUsageAndLog 解析 token 使用信息并记录日志
+// parseUsageAndLog 解析 token 使用信息并记录日志，只执行一次
 func (w *StreamWrapper) parseUsageAndLog() {
+	if w.settled {
+		return
+	}
+	w.settled = true
 	defer func() {
 		if r := recover(); r != nil {
 			w.logger.Error("Panic in parseUsageAndLog", logger.Any("panic", r))
 		}
 	}()
+	w.stopIdleTimer()
+	w.stopMaxDurationTimer()
 
-	// 解析缓冲区中的所有 SSE 数据块
-	scanner := bufio.NewScanner(w.buffer)
-	for scanner.Scan() {
-		line := scanner.Text()
+	// 解析没有以换行结尾的最后一行
+	w.lines.flush(w.parseLine)
 
-		// 跳过空行和注释
-		if line == "" || strings.HasPrefix(line, ":") {
-			continue
-		}
-
-		// 解析 SSE 格式: data: {...}
-		if strings.HasPrefix(line, "data: ") {
-			data := strings.TrimPrefix(line, "data: ")
-
-			// 跳过 [DONE] 标记
-			if data == "[DONE]" {
-				continue
-			}
-
-			// 根据协议解析数据
-			if w.proto == protocol.ProtocolOpenAI || w.proto == protocol.ProtocolAnthropic {
-				w.parseOpenAIChunk(data)
-			} else if w.proto == protocol.ProtocolGemini {
-				w.parseGeminiChunk(data)
-			}
-		}
+	// 因配额、空闲超时、最长持续时间、强制结束或上游错误截断的流没有上游用量，按已下发内容估算计费
+	// 含工具调用的流（如 Kiro）上游同样不报告用量，按工具调用参数的 token 数估算，而不是使用默认值
+	if w.usage.TotalTokens == 0 && (w.quotaExceeded || w.idleTimedOut || w.durationExceeded || w.terminated || w.upstreamErr != "" || w.toolCalls.Len() > 0) {
+		completionTokens := estimateTokenCount(w.textChars) + estimateToolCallTokens(w.toolCalls.String())
+		w.usage.PromptTokens = estimatePromptTokens(w.req.ChatRequest)
+		w.usage.CompletionTokens = completionTokens
+		w.usage.TotalTokens = w.usage.PromptTokens + completionTokens
 	}
 
-// 如果没有解析到 token 使用信息，使用默认值
+	// 如果没有解析到 token 使用信息，使用默认值
 	if w.usage.TotalTokens == 0 {
 		w.logger.Warn("No token usage found in stream, using default values")
 		// 估算 token 数量（简单估算：每个字符约 0.25 个 token）
@@ -133,13 +234,9 @@ func (w *StreamWrapper) parseUsageAndLog() {
 	defer cancel()
 
 	// 计算并扣除费用
-	cost, err := w.service.calculateAndDeductCost(
-		ctx,
-		w.req.UserID,
-		w.apiConfigID,
-		w.req.Model,
-		*w.usage,
-	)
+	billingCtx, billingSpan := w.service.startSpan(w.trace.context(ctx), SpanBilling, w.trace.configAttributes()...)
+	cost, err := w.service.settleStreamCost(billingCtx, w.req, w.apiConfigID, *w.usage)
+	tracing.EndSpan(billingSpan, err, attribute.Int(AttrCost, cost))
 	if err != nil {
 		// 检查是否是 context 取消错误，如果是则使用后台 goroutine 异步处理
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -148,13 +245,7 @@ func (w *StreamWrapper) parseUsageAndLog() {
 			go func() {
 				asyncCtx, asyncCancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer asyncCancel()
-				_, asyncErr := w.service.calculateAndDeductCost(
-					asyncCtx,
-					w.req.UserID,
-					w.apiConfigID,
-					w.req.Model,
-					*w.usage,
-				)
+				_, asyncErr := w.service.settleStreamCost(asyncCtx, w.req, w.apiConfigID, *w.usage)
 				if asyncErr != nil {
 					w.logger.Error("Async cost calculation failed", logger.Error(asyncErr))
 				} else {
@@ -164,26 +255,38 @@ func (w *StreamWrapper) parseUsageAndLog() {
 		} else {
 			w.logger.Error("✗ Failed to calculate and deduct cost", logger.Uint("user_id", w.req.UserID), logger.String("model", w.req.Model), logger.Error(err))
 		}
-} else {
+	} else {
 		w.logger.Info("✓ Cost calculated and deducted", logger.Uint("user_id", w.req.UserID), logger.Int("cost", cost), logger.Int("total_tokens", w.usage.TotalTokens))
 	}
 
-	// 记录成功（如果使用账号池）
+	// 记录成功（如果使用账号池），空闲超时视为凭据错误
 	if w.credentialID > 0 {
-		w.service.poolManager.RecordSuccess(w.ctx, w.credentialID)
-		w.logger.Info("✓ Credential success recorded", logger.Uint("credential_id", w.credentialID))
+		if w.idleTimedOut {
+			w.service.poolManager.RecordError(w.ctx, w.credentialID, "upstream stream idle timeout")
+		} else {
+			w.service.poolManager.RecordSuccess(w.ctx, w.credentialID)
+			w.logger.Info("✓ Credential success recorded", logger.Uint("credential_id", w.credentialID))
+		}
 	}
 
-	// 记录请求日志
-	w.service.logRequest(
+	// 记录请求日志（需要退款时同步写入以取得日志 ID）
+	reason := w.failureReason()
+	logID := w.service.logRequest(
 		w.ctx,
 		w.req,
 		w.apiConfigID,
 		w.usage.TotalTokens,
+		cost,
 		responseTime,
 		nil,
+		reason != "" && cost > 0,
 	)
 
+	// 没有下发任何内容但已按估算扣费时自动退款；已下发内容后出错的流按已下发内容计费
+	if reason != "" {
+		w.service.refundFailedRequest(w.req, logID, cost, reason)
+	}
+
 	w.logger.Info("✓ Stream request completed",
 		logger.Uint("user_id", w.req.UserID),
 		logger.String("model", w.req.Model),
@@ -191,10 +294,35 @@ func (w *StreamWrapper) parseUsageAndLog() {
 		logger.Duration("response_time", responseTime))
 }
 
+// failureReason 返回流被判定为失败的原因，成功或已下发内容时返回空字符串
+func (w *StreamWrapper) failureReason() string {
+	if w.hasOutput {
+		return ""
+	}
+	if w.upstreamErr != "" {
+		return "upstream stream error: " + w.upstreamErr
+	}
+	return "upstream stream returned no content"
+}
+
 // parseOpenAIChunk 解析 OpenAI/Anthropic 格式的流式数据块
 func (w *StreamWrapper) parseOpenAIChunk(data string) {
 	var chunk struct {
-		Usage *adapter.UsageInfo `json:"usage,omitempty"`
+		Usage   *adapter.UsageInfo `json:"usage,omitempty"`
+		Choices []struct {
+			Delta struct {
+				Content   string            `json:"content"`
+				ToolCalls []json.RawMessage `json:"tool_calls"`
+			} `json:"delta"`
+		} `json:"choices"`
+		// Anthropic 原生事件
+		Delta *struct {
+			Text        string `json:"text"`
+			PartialJSON string `json:"partial_json"`
+		} `json:"delta,omitempty"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
 	}
 
 	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
@@ -202,6 +330,18 @@ func (w *StreamWrapper) parseOpenAIChunk(data string) {
 		return
 	}
 
+	if chunk.Error != nil {
+		w.upstreamErr = chunk.Error.Message
+	}
+	for _, choice := range chunk.Choices {
+		if choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0 {
+			w.hasOutput = true
+		}
+	}
+	if chunk.Delta != nil && (chunk.Delta.Text != "" || chunk.Delta.PartialJSON != "") {
+		w.hasOutput = true
+	}
+
 	// 如果包含 usage 信息，更新累计值
 	if chunk.Usage != nil {
 		if chunk.Usage.PromptTokens > 0 {
@@ -219,6 +359,14 @@ func (w *StreamWrapper) parseOpenAIChunk(data string) {
 // parseGeminiChunk 解析 Gemini 格式的流式数据块
 func (w *StreamWrapper) parseGeminiChunk(data string) {
 	var chunk struct {
+		Candidates []struct {
+			Content struct {
+				Parts []json.RawMessage `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
 		UsageMetadata *struct {
 			PromptTokenCount     int `json:"promptTokenCount"`
 			CandidatesTokenCount int `json:"candidatesTokenCount"`
@@ -231,6 +379,15 @@ func (w *StreamWrapper) parseGeminiChunk(data string) {
 		return
 	}
 
+	if chunk.Error != nil {
+		w.upstreamErr = chunk.Error.Message
+	}
+	for _, candidate := range chunk.Candidates {
+		if len(candidate.Content.Parts) > 0 {
+			w.hasOutput = true
+		}
+	}
+
 	// 如果包含 usage 信息，更新累计值
 	if chunk.UsageMetadata != nil {
 		if chunk.UsageMetadata.PromptTokenCount > 0 {
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"unicode/utf8"
+)
+
+// messageTokenOverhead 每条消息的角色、分隔符等固定开销
+const messageTokenOverhead = 4
+
+// estimateTokenCount 按每 4 个字符约 1 个 token 粗略估算
+func estimateTokenCount(chars int) int {
+	return (chars + 3) / 4
+}
+
+// estimateTextTokens 按字符（而不是字节）估算文本的 token 数，中文等多字节文本不会被高估
+func estimateTextTokens(text string) int {
+	return estimateTokenCount(utf8.RuneCountInString(text))
+}
+
+// estimateMessageTokens 估算单条消息的 token 数：文本、工具调用的函数名和参数，加上每条消息的固定开销
+func estimateMessageTokens(msg adapter.Message) int {
+	chars := utf8.RuneCountInString(adapter.GetContentAsString(msg.Content))
+	for _, tc := range msg.ToolCalls {
+		chars += utf8.RuneCountInString(tc.Function.Name) + utf8.RuneCountInString(tc.Function.Arguments)
+	}
+	return estimateTokenCount(chars) + messageTokenOverhead
+}
+
+// estimatePromptTokens 估算请求的输入 token 数
+// 上下文截断、长度路由、成本上限、流式预扣和中途配额检查都使用这一估算，结果保持一致
+func estimatePromptTokens(req *adapter.ChatRequest) int {
+	if req == nil {
+		return 0
+	}
+	total := 0
+	for _, msg := range req.Messages {
+		total += estimateMessageTokens(msg)
+	}
+	return total
+}
@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"strings"
+	"testing"
+)
+
+func TestEstimatePromptTokens_CountsRunesNotBytes(t *testing.T) {
+	// 400 个汉字占 1200 字节，按字符估算约 100 个 token
+	req := &adapter.ChatRequest{Messages: []adapter.Message{{Role: "user", Content: strings.Repeat("你", 400)}}}
+	if got := estimatePromptTokens(req); got != 100+messageTokenOverhead {
+		t.Fatalf("Expected %d prompt tokens, got %d", 100+messageTokenOverhead, got)
+	}
+}
+
+func TestEstimatePromptTokens_SharedAcrossFeatures(t *testing.T) {
+	req := &adapter.ChatRequest{Messages: []adapter.Message{
+		{Role: "system", Content: "你是一个助手"},
+		{Role: "user", Content: strings.Repeat("天气怎么样？", 50)},
+	}}
+	want := estimatePromptTokens(req)
+
+	truncation := 0
+	for _, msg := range req.Messages {
+		truncation += estimateMessageTokens(msg)
+	}
+	if truncation != want {
+		t.Errorf("Context truncation estimates %d tokens, want %d", truncation, want)
+	}
+	if got := estimateRequestUsage(req, "gpt-4").PromptTokens; got != want {
+		t.Errorf("Cost ceiling estimates %d tokens, want %d", got, want)
+	}
+	if got := newStreamQuotaGuard(1, req).promptTokens; got != want {
+		t.Errorf("Stream quota guard estimates %d tokens, want %d", got, want)
+	}
+}
+
+func TestStreamDeltaChars_CountsRunes(t *testing.T) {
+	if got := streamDeltaChars([]byte(`{"choices":[{"delta":{"content":"你好世界"}}]}`)); got != 4 {
+		t.Errorf("Expected 4 characters, got %d", got)
+	}
+}
@@ -0,0 +1,18 @@
+package proxy
+
+// estimateToolCallTokens 估算工具调用内容（函数名与 JSON 参数）的 token 数
+// JSON 的结构符号（括号、引号、冒号、逗号）通常各自成为一个 token，按字符长度估算会明显偏低；
+// 其余字符按每 4 个约 1 个 token，空白不计
+func estimateToolCallTokens(content string) int {
+	structural, other := 0, 0
+	for _, r := range content {
+		switch r {
+		case '{', '}', '[', ']', ':', ',', '"':
+			structural++
+		case ' ', '\t', '\r', '\n':
+		default:
+			other++
+		}
+	}
+	return structural + estimateTokenCount(other)
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateToolCallTokens(t *testing.T) {
+	// 11 个结构符号，其余 21 个字符约 6 个 token
+	content := `weather{"city":"Paris","days":3}`
+	if got := estimateToolCallTokens(content); got != 17 {
+		t.Errorf("Expected 17 tokens, got %d", got)
+	}
+	if naive := estimateTokenCount(len(content)); naive >= 17 {
+		t.Errorf("Expected the length-based estimate to undercount JSON, got %d", naive)
+	}
+	if got := estimateToolCallTokens(`{ "a" : 1 }`); got != estimateToolCallTokens(`{"a":1}`) {
+		t.Errorf("Expected whitespace not to be counted, got %d", got)
+	}
+}
+
+func TestStreamWrapper_ToolCallOnlyStreamBilledOnArguments(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	// 上游（如 Kiro）只返回工具调用，不报告用量；参数分两块到达，请求 "hi" 约 1 个 token 加 4 个消息开销
+	body := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\",\"days\":3}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	drainStream(t, svc, body)
+
+	if q.deducted != 17 {
+		t.Errorf("Expected billing on the 17 tool-call tokens, deducted %d", q.deducted)
+	}
+	if q.refundCalls != 0 {
+		t.Error("Expected a tool-call-only stream not to be refunded as empty")
+	}
+	if len(l.created) != 1 || l.created[0].TokensUsed != 1+4+17 {
+		t.Errorf("Expected the request log to record the estimated tokens, got %+v", l.created)
+	}
+}
+
+func TestStreamWrapper_ToolCallStreamKeepsUpstreamUsage(t *testing.T) {
+	q, l := &fakeQuota{}, &fakeLog{}
+	svc := newBillingTestService(q, l)
+	svc.pricingService = &perTokenPricing{}
+
+	body := strings.Join([]string{
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":40,"total_tokens":60}}`,
+		`data: [DONE]`,
+	}, "\n\n") + "\n\n"
+	drainStream(t, svc, body)
+
+	if q.deducted != 40 {
+		t.Errorf("Expected the upstream-reported usage billed, deducted %d", q.deducted)
+	}
+}
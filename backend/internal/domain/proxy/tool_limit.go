@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 工具数超出上限时的处理策略
+const (
+	ToolLimitReject   = "reject"         // 拒绝请求
+	ToolLimitTruncate = "truncate-extra" // 只保留最相关的工具
+	ToolLimitMerge    = "merge"          // 多余的工具合并为一个按名称分发的工具
+)
+
+// mergedToolName 合并工具的名称，模型通过它调用被合并的工具
+const mergedToolName = "call_additional_tool"
+
+// toolLimitFor 返回生效的工具数上限：配置上限与供应商已知上限取较小值，0 表示不限制
+func toolLimitFor(cfg *apiconfig.APIConfig, adapterInstance adapter.Adapter) int {
+	limit := cfg.MaxTools
+	if providerLimit := adapter.GetCapabilities(adapterInstance).MaxTools; providerLimit > 0 && (limit <= 0 || providerLimit < limit) {
+		limit = providerLimit
+	}
+	return limit
+}
+
+// applyToolLimit 按配置策略处理超出上限的工具定义，被丢弃或合并的工具数记录到请求日志
+func (s *service) applyToolLimit(ctx context.Context, cfg *apiconfig.APIConfig, adapterInstance adapter.Adapter, req *ProxyRequest) error {
+	chatReq := req.ChatRequest
+	limit := toolLimitFor(cfg, adapterInstance)
+	if limit <= 0 || len(chatReq.Tools) <= limit {
+		return nil
+	}
+
+	policy := cfg.ToolLimitPolicy
+	// 流式响应中的工具调用参数是分块下发的，无法还原合并工具的调用，退化为截断
+	if policy == ToolLimitMerge && chatReq.Stream {
+		policy = ToolLimitTruncate
+	}
+
+	switch policy {
+	case ToolLimitTruncate:
+		req.ToolsDropped = len(chatReq.Tools) - limit
+		chatReq.Tools = selectTools(chatReq, limit)
+	case ToolLimitMerge:
+		kept := selectTools(chatReq, limit-1)
+		req.ToolsDropped = len(chatReq.Tools) - len(kept)
+		chatReq.Tools = append(kept, mergeTools(excludeTools(chatReq.Tools, kept)))
+		req.ToolsMerged = true
+	default:
+		return errors.New(400001, fmt.Sprintf("Request has %d tools, the limit for this model is %d", len(chatReq.Tools), limit))
+	}
+
+	s.log(ctx).Info("✓ Tool count limit applied",
+		logger.String("model", chatReq.Model),
+		logger.String("policy", policy),
+		logger.Int("limit", limit),
+		logger.Int("dropped", req.ToolsDropped))
+	return nil
+}
+
+// selectTools 选出最相关的 n 个工具，保持原有顺序
+// 相关性依次为：tool_choice 指定的工具、对话中已调用过的工具、声明顺序靠前的工具
+func selectTools(req *adapter.ChatRequest, n int) []adapter.Tool {
+	if n <= 0 {
+		return nil
+	}
+
+	rank := make(map[string]int)
+	if name := forcedToolName(req.ToolChoice); name != "" {
+		rank[name] = 2
+	}
+	for _, msg := range req.Messages {
+		for _, tc := range msg.ToolCalls {
+			if _, ok := rank[tc.Function.Name]; !ok {
+				rank[tc.Function.Name] = 1
+			}
+		}
+	}
+
+	order := make([]int, len(req.Tools))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return rank[req.Tools[order[a]].Function.Name] > rank[req.Tools[order[b]].Function.Name]
+	})
+	order = order[:n]
+	sort.Ints(order)
+
+	kept := make([]adapter.Tool, 0, n+1)
+	for _, i := range order {
+		kept = append(kept, req.Tools[i])
+	}
+	return kept
+}
+
+// forcedToolName 返回 tool_choice 强制调用的函数名（{"type":"function","function":{"name":...}}）
+func forcedToolName(toolChoice interface{}) string {
+	choice, ok := toolChoice.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	function, _ := choice["function"].(map[string]interface{})
+	name, _ := function["name"].(string)
+	return name
+}
+
+// excludeTools 返回不在 kept 中的工具
+func excludeTools(tools, kept []adapter.Tool) []adapter.Tool {
+	keptNames := make(map[string]bool, len(kept))
+	for _, tool := range kept {
+		keptNames[tool.Function.Name] = true
+	}
+	var rest []adapter.Tool
+	for _, tool := range tools {
+		if !keptNames[tool.Function.Name] {
+			rest = append(rest, tool)
+		}
+	}
+	return rest
+}
+
+// mergeTools 将多个工具合并为一个分发工具，描述中列出各工具的名称、说明和参数
+func mergeTools(tools []adapter.Tool) adapter.Tool {
+	names := make([]interface{}, len(tools))
+	var desc strings.Builder
+	desc.WriteString("Call one of the following tools by name, passing its arguments as an object:")
+	for i, tool := range tools {
+		names[i] = tool.Function.Name
+		params, _ := json.Marshal(tool.Function.Parameters)
+		fmt.Fprintf(&desc, "\n- %s: %s Parameters: %s", tool.Function.Name, tool.Function.Description, params)
+	}
+
+	return adapter.Tool{
+		Type: "function",
+		Function: adapter.ToolFunction{
+			Name:        mergedToolName,
+			Description: desc.String(),
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":      map[string]interface{}{"type": "string", "enum": names},
+					"arguments": map[string]interface{}{"type": "object"},
+				},
+				"required": []interface{}{"name", "arguments"},
+			},
+		},
+	}
+}
+
+// unmergeToolCalls 将对合并工具的调用还原为对原工具的调用，客户端无需感知合并
+func unmergeToolCalls(resp *adapter.ChatResponse) {
+	for i := range resp.Choices {
+		calls := resp.Choices[i].Message.ToolCalls
+		for j := range calls {
+			if calls[j].Function.Name != mergedToolName {
+				continue
+			}
+			var dispatch struct {
+				Name      string          `json:"name"`
+				Arguments json.RawMessage `json:"arguments"`
+			}
+			if json.Unmarshal([]byte(calls[j].Function.Arguments), &dispatch) != nil || dispatch.Name == "" {
+				continue
+			}
+			calls[j].Function.Name = dispatch.Name
+			calls[j].Function.Arguments = "{}"
+			if len(dispatch.Arguments) > 0 && string(dispatch.Arguments) != "null" {
+				calls[j].Function.Arguments = string(dispatch.Arguments)
+			}
+		}
+	}
+}
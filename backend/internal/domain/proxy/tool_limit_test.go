@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"testing"
+)
+
+func toolRequest(count int) *ProxyRequest {
+	tools := make([]adapter.Tool, count)
+	for i := range tools {
+		tools[i] = adapter.Tool{Type: "function", Function: adapter.ToolFunction{
+			Name:        fmt.Sprintf("tool_%d", i),
+			Description: fmt.Sprintf("does %d", i),
+			Parameters:  map[string]interface{}{"type": "object"},
+		}}
+	}
+	return &ProxyRequest{ChatRequest: &adapter.ChatRequest{Model: "m", Tools: tools}}
+}
+
+func toolNames(tools []adapter.Tool) []string {
+	names := make([]string, len(tools))
+	for i, tool := range tools {
+		names[i] = tool.Function.Name
+	}
+	return names
+}
+
+func TestApplyToolLimit_RejectByDefault(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{MaxTools: 3}
+
+	err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), toolRequest(4))
+	if appErr, ok := err.(*errors.AppError); !ok || appErr.Code != 400001 {
+		t.Fatalf("Expected 400001 when tools exceed the limit, got %v", err)
+	}
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), toolRequest(3)); err != nil {
+		t.Errorf("Expected requests within the limit to pass, got %v", err)
+	}
+}
+
+func TestApplyToolLimit_TruncateKeepsMostRelevant(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{MaxTools: 3, ToolLimitPolicy: ToolLimitTruncate}
+
+	req := toolRequest(6)
+	req.ChatRequest.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "tool_5"}}
+	req.ChatRequest.Messages = []adapter.Message{
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", ToolCalls: []adapter.ToolCall{{ID: "c1", Type: "function", Function: adapter.FunctionCall{Name: "tool_3"}}}},
+	}
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// 强制调用和已调用的工具优先，剩余名额按声明顺序，结果保持原有顺序
+	if got := fmt.Sprint(toolNames(req.ChatRequest.Tools)); got != "[tool_0 tool_3 tool_5]" {
+		t.Errorf("Expected [tool_0 tool_3 tool_5], got %s", got)
+	}
+	if req.ToolsDropped != 3 {
+		t.Errorf("Expected 3 dropped tools recorded, got %d", req.ToolsDropped)
+	}
+}
+
+func TestApplyToolLimit_EnforcesProviderLimit(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{ToolLimitPolicy: ToolLimitTruncate}
+
+	req := toolRequest(130)
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewOpenAIAdapter(&adapter.Config{}), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(req.ChatRequest.Tools) != 128 || req.ToolsDropped != 2 {
+		t.Errorf("Expected OpenAI's 128 tool limit enforced, got %d tools (%d dropped)", len(req.ChatRequest.Tools), req.ToolsDropped)
+	}
+	if names := toolNames(req.ChatRequest.Tools); names[0] != "tool_0" || names[127] != "tool_127" {
+		t.Errorf("Expected the first 128 tools retained, got %s..%s", names[0], names[127])
+	}
+
+	unlimited := toolRequest(130)
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), unlimited); err != nil || len(unlimited.ChatRequest.Tools) != 130 {
+		t.Errorf("Expected no limit without configuration or known provider limit, got %d tools, err %v", len(unlimited.ChatRequest.Tools), err)
+	}
+}
+
+func TestApplyToolLimit_MergeAndUnmerge(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{MaxTools: 3, ToolLimitPolicy: ToolLimitMerge}
+
+	req := toolRequest(5)
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(toolNames(req.ChatRequest.Tools)); got != "[tool_0 tool_1 "+mergedToolName+"]" {
+		t.Fatalf("Expected two tools plus the merged tool, got %s", got)
+	}
+	if !req.ToolsMerged || req.ToolsDropped != 3 {
+		t.Errorf("Expected merge recorded with 3 tools merged, got merged=%v dropped=%d", req.ToolsMerged, req.ToolsDropped)
+	}
+
+	resp := &adapter.ChatResponse{Choices: []adapter.ChatChoice{{Message: adapter.Message{ToolCalls: []adapter.ToolCall{
+		{ID: "c1", Type: "function", Function: adapter.FunctionCall{Name: mergedToolName, Arguments: `{"name":"tool_4","arguments":{"city":"Paris"}}`}},
+		{ID: "c2", Type: "function", Function: adapter.FunctionCall{Name: "tool_0", Arguments: `{}`}},
+	}}}}}
+	unmergeToolCalls(resp)
+	calls := resp.Choices[0].Message.ToolCalls
+	if calls[0].Function.Name != "tool_4" || calls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Expected merged call restored to tool_4, got %+v", calls[0].Function)
+	}
+	if calls[1].Function.Name != "tool_0" {
+		t.Errorf("Expected direct calls untouched, got %+v", calls[1].Function)
+	}
+
+	// 流式请求无法还原合并调用，退化为截断
+	stream := toolRequest(5)
+	stream.ChatRequest.Stream = true
+	if err := svc.applyToolLimit(context.Background(), cfg, adapter.NewAnthropicAdapter(&adapter.Config{}), stream); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stream.ToolsMerged || len(stream.ChatRequest.Tools) != 3 {
+		t.Errorf("Expected streaming merge to truncate instead, got %s", toolNames(stream.ChatRequest.Tools))
+	}
+}
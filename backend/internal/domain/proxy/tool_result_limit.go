@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// 超大工具结果的处理策略
+const (
+	ToolResultTruncate  = "truncate"  // 只保留开头部分
+	ToolResultSummarize = "summarize" // 保留开头和结尾，中间替换为省略说明
+)
+
+// limitToolResults 把超过配置 token 上限的工具结果按策略截断或摘要
+// 只改写工具结果的文本内容，消息及其 tool_call_id 保持不变，工具调用与结果的配对不受影响
+func (s *service) limitToolResults(ctx context.Context, cfg *apiconfig.APIConfig, req *adapter.ChatRequest) {
+	if cfg.ToolResultMaxTokens <= 0 {
+		return
+	}
+	limited := 0
+	for i := range req.Messages {
+		msg := &req.Messages[i]
+		if msg.Role != "tool" {
+			continue
+		}
+		if content, ok := limitToolResultContent(msg.Content, cfg.ToolResultMaxTokens, cfg.ToolResultPolicy); ok {
+			msg.Content = content
+			limited++
+		}
+	}
+	if limited > 0 {
+		s.log(ctx).Info("✓ Oversized tool results shortened",
+			logger.String("policy", toolResultPolicy(cfg.ToolResultPolicy)),
+			logger.Int("max_tokens", cfg.ToolResultMaxTokens),
+			logger.Int("tool_results", limited))
+	}
+}
+
+// reportDroppedImages 记录适配器将替换为文本占位符的工具结果图片数
+func (s *service) reportDroppedImages(ctx context.Context, cfg *apiconfig.APIConfig, adapterInstance adapter.Adapter, req *adapter.ChatRequest) {
+	if dropped := adapter.DroppedToolResultImages(adapterInstance, req); dropped > 0 {
+		s.log(ctx).Warn("Tool result images not supported by provider, replaced with placeholders",
+			logger.String("provider", cfg.Type),
+			logger.Int("images", dropped))
+	}
+}
+
+// toolResultPolicy 未配置策略时按截断处理
+func toolResultPolicy(policy string) string {
+	if policy == ToolResultSummarize {
+		return ToolResultSummarize
+	}
+	return ToolResultTruncate
+}
+
+// limitToolResultContent 工具结果文本超过上限时返回缩短后的内容，未超出时返回 false
+// 多段内容中的文本合并为一段放在第一段文本的位置，图片等其他片段原样保留
+func limitToolResultContent(content interface{}, maxTokens int, policy string) (interface{}, bool) {
+	text := adapter.GetContentAsString(content)
+	if estimateTokenCount(len([]rune(text))) <= maxTokens {
+		return content, false
+	}
+	shortened := shortenToolResult(text, maxTokens, toolResultPolicy(policy))
+
+	parts, ok := content.([]interface{})
+	if !ok {
+		return shortened, true
+	}
+	result := make([]interface{}, 0, len(parts))
+	placed := false
+	for _, part := range parts {
+		if partMap, ok := part.(map[string]interface{}); ok {
+			if _, isText := partMap["text"].(string); isText {
+				if !placed {
+					result = append(result, map[string]interface{}{"type": "text", "text": shortened})
+					placed = true
+				}
+				continue
+			}
+		}
+		result = append(result, part)
+	}
+	return result, true
+}
+
+// shortenToolResult 把文本缩短到约 maxTokens 个 token，并注明省略了多少内容
+// 截断在行边界处切开（行过长时直接按字符切），摘要保留开头和结尾各一半
+func shortenToolResult(text string, maxTokens int, policy string) string {
+	runes := []rune(text)
+	total := estimateTokenCount(len(runes))
+	budget := maxTokens * 4
+
+	if policy == ToolResultSummarize {
+		head := cutAtLine(string(runes[:budget/2]), false)
+		tail := cutAtLine(string(runes[len(runes)-budget/2:]), true)
+		omitted := len(runes) - len([]rune(head)) - len([]rune(tail))
+		return fmt.Sprintf("%s\n[... tool result summarized: ~%d of ~%d tokens omitted from the middle ...]\n%s",
+			head, estimateTokenCount(omitted), total, tail)
+	}
+
+	head := cutAtLine(string(runes[:budget]), false)
+	return fmt.Sprintf("%s\n[... tool result truncated: kept ~%d of ~%d tokens ...]",
+		head, estimateTokenCount(len([]rune(head))), total)
+}
+
+// cutAtLine 去掉被切断的半行：开头部分舍弃最后一个换行之后的内容，结尾部分舍弃第一个换行之前的内容
+// 换行位置会舍弃超过一半的内容时原样返回
+func cutAtLine(s string, fromStart bool) string {
+	if fromStart {
+		if i := strings.IndexByte(s, '\n'); i >= 0 && i < len(s)/2 {
+			return s[i+1:]
+		}
+		return s
+	}
+	if i := strings.LastIndexByte(s, '\n'); i >= len(s)/2 {
+		return s[:i]
+	}
+	return s
+}
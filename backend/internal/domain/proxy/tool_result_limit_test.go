@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fileListing 约 n 行、每行 20 个字符的工具输出
+func fileListing(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %04d: content..", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func toolConversation(result interface{}) *adapter.ChatRequest {
+	return &adapter.ChatRequest{Model: "gpt-4", Messages: []adapter.Message{
+		{Role: "user", Content: "read the file"},
+		{Role: "assistant", ToolCalls: []adapter.ToolCall{{ID: "call_1", Type: "function", Function: adapter.FunctionCall{Name: "read_file", Arguments: "{}"}}}},
+		{Role: "tool", ToolCallID: "call_1", Name: "read_file", Content: result},
+		{Role: "user", Content: "what does it say?"},
+	}}
+}
+
+// assertToolPairing 工具结果仍紧跟在对应的 tool_calls 之后
+func assertToolPairing(t *testing.T, req *adapter.ChatRequest) {
+	t.Helper()
+	if len(req.Messages) != 4 || req.Messages[2].Role != "tool" || req.Messages[2].ToolCallID != "call_1" || req.Messages[1].ToolCalls[0].ID != "call_1" {
+		t.Fatalf("Expected the tool_use/tool_result pairing preserved, got %+v", req.Messages)
+	}
+	if paired := adapter.PairToolResults(req.Messages); len(paired) != len(req.Messages) {
+		t.Errorf("Expected the conversation to remain valid, pairing changed it to %d messages", len(paired))
+	}
+}
+
+func TestLimitToolResults_TruncatesOversizedResult(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	req := toolConversation(fileListing(1000)) // 约 5000 token
+	svc.limitToolResults(context.Background(), &apiconfig.APIConfig{ToolResultMaxTokens: 200}, req)
+
+	assertToolPairing(t, req)
+	content := adapter.GetContentAsString(req.Messages[2].Content)
+	if tokens := estimateTokenCount(len(content)); tokens > 230 {
+		t.Errorf("Expected the tool result cut to about 200 tokens, got %d", tokens)
+	}
+	if !strings.HasPrefix(content, "line 0000") || !strings.Contains(content, "[... tool result truncated") {
+		t.Errorf("Expected the head kept with a truncation marker, got %q", content)
+	}
+	if strings.Contains(content, "line 0999") {
+		t.Error("Expected the end of the result dropped")
+	}
+}
+
+func TestLimitToolResults_SummarizeKeepsHeadAndTail(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	req := toolConversation(fileListing(1000))
+	svc.limitToolResults(context.Background(), &apiconfig.APIConfig{ToolResultMaxTokens: 200, ToolResultPolicy: ToolResultSummarize}, req)
+
+	assertToolPairing(t, req)
+	content := adapter.GetContentAsString(req.Messages[2].Content)
+	if !strings.HasPrefix(content, "line 0000") || !strings.HasSuffix(content, "line 0999: content..") {
+		t.Errorf("Expected the first and last lines kept, got %q", content)
+	}
+	if !strings.Contains(content, "[... tool result summarized") {
+		t.Errorf("Expected a marker for the omitted middle, got %q", content)
+	}
+}
+
+func TestLimitToolResults_KeepsSmallResultsAndNonTextParts(t *testing.T) {
+	svc := &service{logger: *logger.NewNop()}
+	cfg := &apiconfig.APIConfig{ToolResultMaxTokens: 200}
+
+	req := toolConversation("42 files")
+	svc.limitToolResults(context.Background(), cfg, req)
+	if req.Messages[2].Content != "42 files" {
+		t.Errorf("Expected a small tool result untouched, got %v", req.Messages[2].Content)
+	}
+
+	image := map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,iVBORw0KGgo="}}
+	req = toolConversation([]interface{}{
+		map[string]interface{}{"type": "text", "text": fileListing(500)},
+		image,
+		map[string]interface{}{"type": "text", "text": fileListing(500)},
+	})
+	svc.limitToolResults(context.Background(), cfg, req)
+
+	assertToolPairing(t, req)
+	parts, ok := req.Messages[2].Content.([]interface{})
+	if !ok || len(parts) != 2 {
+		t.Fatalf("Expected the text merged into one shortened part next to the image, got %v", req.Messages[2].Content)
+	}
+	if parts[1].(map[string]interface{})["type"] != "image_url" {
+		t.Errorf("Expected the image part preserved, got %v", parts[1])
+	}
+	if !strings.Contains(adapter.GetContentAsString(parts), "[... tool result truncated") {
+		t.Errorf("Expected the text part truncated, got %v", parts[0])
+	}
+}
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/tracing"
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 代理服务 span 的 instrumentation 名称
+const tracerName = "api-aggregator/backend/proxy"
+
+// 代理请求各阶段的 span 名称
+const (
+	SpanChatCompletion = "proxy.chat_completion"
+	SpanQuotaCheck     = "proxy.quota_check"
+	SpanSelectConfig   = "proxy.select_config"
+	SpanUpstreamCall   = "proxy.upstream_call"
+	SpanBilling        = "proxy.billing"
+)
+
+// span 属性名
+const (
+	AttrRequestID = "prism.request_id"
+	AttrModel     = "prism.model"
+	AttrConfigID  = "prism.api_config_id"
+	AttrProvider  = "prism.provider"
+	AttrStrategy  = "prism.routing_strategy"
+	AttrCacheHit  = "prism.cache_hit"
+	AttrCost      = "prism.cost"
+)
+
+// startSpan 开始代理操作的 span，未注入 tracer 时使用全局 TracerProvider（未启用追踪时为空实现）
+func (s *service) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	tracer := s.tracer
+	if tracer == nil {
+		tracer = otel.Tracer(tracerName)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// scopeTraceLog 记录的请求把 trace_id 记入请求日志，日志与链路可以互相查找
+// 是否导出在请求结束时才决定，未导出的链路在追踪后端查不到
+func scopeTraceLog(ctx context.Context) {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsSampled() {
+		logger.AddFields(ctx, logger.String("trace_id", sc.TraceID().String()))
+	}
+}
+
+// configAttributes 选中配置的 span 属性，未选中配置时为空
+// 配置了采样比例时一并带上，设置在请求 span 上后决定整条链路的采样
+func configAttributes(apiConfig *apiconfig.APIConfig) []attribute.KeyValue {
+	if apiConfig == nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{
+		attribute.Int64(AttrConfigID, int64(apiConfig.ID)),
+		attribute.String(AttrProvider, apiConfig.Type),
+	}
+	if apiConfig.TraceSampleRate != nil {
+		attrs = append(attrs, attribute.Float64(tracing.AttrSampleRate, *apiConfig.TraceSampleRate))
+	}
+	return attrs
+}
+
+// streamTrace 流式请求在流关闭时才结束的 span：请求 span 和上游调用子 span
+type streamTrace struct {
+	chat  trace.Span
+	call  trace.Span
+	attrs []attribute.KeyValue // 选中配置的属性，用于计费子 span
+	once  sync.Once
+}
+
+// context 把请求 span 放入 ctx，使流结算时的计费 span 成为其子 span；未追踪时原样返回
+func (t *streamTrace) context(ctx context.Context) context.Context {
+	if t == nil || t.chat == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, t.chat)
+}
+
+// configAttributes 选中配置的 span 属性，未追踪时为空
+func (t *streamTrace) configAttributes() []attribute.KeyValue {
+	if t == nil {
+		return nil
+	}
+	return t.attrs
+}
+
+// end 结束上游调用 span 和请求 span，只执行一次
+func (t *streamTrace) end(err error) {
+	if t == nil {
+		return
+	}
+	t.once.Do(func() {
+		tracing.EndSpan(t.call, err)
+		if t.chat != nil {
+			tracing.EndSpan(t.chat, err)
+		}
+	})
+}
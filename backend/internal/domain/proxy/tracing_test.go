@@ -0,0 +1,257 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apiconfig"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/tracing"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTracedTestService 把 span 同步导出到内存的代理服务
+func newTracedTestService(t *testing.T, sampleRate float64, upstreamURL string) (*service, *tracetest.InMemoryExporter) {
+	t.Helper()
+	return newSampledTestService(t, sampleRate, 0, failoverConfig(1, upstreamURL, 0))
+}
+
+// newSampledTestService 按给定的全局和失败采样比例把 span 导出到内存、只有 cfg 一个配置的代理服务
+func newSampledTestService(t *testing.T, sampleRate, errorSampleRate float64, cfg *apiconfig.APIConfig) (*service, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(sdktrace.NewSimpleSpanProcessor(exporter), sampleRate, errorSampleRate, "test")
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	svc, _ := newFailoverTestService(1, cfg)
+	svc.tracer = provider.Tracer(tracerName)
+	return svc, exporter
+}
+
+func spanAttribute(span tracetest.SpanStub, key string) attribute.Value {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_ProxiedRequestProducesSpanHierarchy(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer upstream.Close()
+	svc, exporter := newTracedTestService(t, 1, upstream.URL)
+
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logger.NewContext(context.Background(), logger.FromZap(zap.New(core)))
+	req := failoverRequest()
+	req.RequestID = "req-42"
+	if _, err := svc.ChatCompletions(ctx, req); err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	root, ok := spans[SpanChatCompletion]
+	if !ok {
+		t.Fatalf("Expected a %s span, got %v", SpanChatCompletion, spans)
+	}
+	if root.Parent.IsValid() {
+		t.Errorf("Expected %s to be the root span", SpanChatCompletion)
+	}
+	if spanAttribute(root, AttrRequestID).AsString() != "req-42" || spanAttribute(root, AttrModel).AsString() != "gpt-4" ||
+		spanAttribute(root, AttrConfigID).AsInt64() != 1 || spanAttribute(root, AttrCacheHit).AsBool() {
+		t.Errorf("Expected request, model, config and cache attributes on the root span, got %v", root.Attributes)
+	}
+	for _, name := range []string{SpanQuotaCheck, SpanSelectConfig, SpanUpstreamCall, SpanBilling} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if child.Parent.SpanID() != root.SpanContext.SpanID() || child.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("Expected %s to be a child of %s", name, SpanChatCompletion)
+		}
+	}
+	if spanAttribute(spans[SpanUpstreamCall], AttrProvider).AsString() != "openai" {
+		t.Errorf("Expected the upstream span to name the provider, got %v", spans[SpanUpstreamCall].Attributes)
+	}
+
+	traceID := root.SpanContext.TraceID().String()
+	for _, entry := range logs.All() {
+		if entry.ContextMap()["trace_id"] != traceID {
+			t.Fatalf("Expected request logs to carry trace_id %s, got %v", traceID, entry.ContextMap())
+		}
+	}
+}
+
+func TestTracing_UpstreamErrorMarksSpans(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusBadRequest})
+	defer upstream.Close()
+	svc, exporter := newTracedTestService(t, 1, upstream.URL)
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err == nil {
+		t.Fatal("Expected the upstream error returned")
+	}
+	failed := 0
+	for _, span := range exporter.GetSpans() {
+		if span.Name == SpanUpstreamCall || span.Name == SpanChatCompletion {
+			failed++
+			if span.Status.Code != codes.Error || len(span.Events) == 0 {
+				t.Errorf("Expected %s marked as failed with the error recorded, got %+v", span.Name, span.Status)
+			}
+		}
+	}
+	if failed != 2 {
+		t.Errorf("Expected the upstream and request spans exported, got %d", failed)
+	}
+}
+
+func TestTracing_UnsampledRequestsExportNothing(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer upstream.Close()
+	svc, exporter := newTracedTestService(t, 0, upstream.URL)
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("Expected no spans at a sample rate of 0, got %d", len(spans))
+	}
+}
+
+func TestTracing_ConfigSampleRateOverridesGlobalRate(t *testing.T) {
+	upstream := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer upstream.Close()
+
+	always, never := 1.0, 0.0
+	cases := []struct {
+		name       string
+		global     float64
+		configRate *float64
+		wantSpans  bool
+	}{
+		{"config samples all under a zero global rate", 0, &always, true},
+		{"config samples none under a full global rate", 1, &never, false},
+		{"config without a rate uses the global rate", 1, nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := failoverConfig(1, upstream.URL, 0)
+			cfg.TraceSampleRate = tc.configRate
+			svc, exporter := newSampledTestService(t, tc.global, 0, cfg)
+
+			if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+				t.Fatalf("ChatCompletions: %v", err)
+			}
+			spans := exporter.GetSpans()
+			if tc.wantSpans && len(spans) == 0 {
+				t.Error("Expected the request traced")
+			}
+			if !tc.wantSpans && len(spans) != 0 {
+				t.Errorf("Expected no spans, got %d", len(spans))
+			}
+		})
+	}
+}
+
+func TestTracing_FailedRequestsUseErrorSampleRate(t *testing.T) {
+	failing := httptest.NewServer(&recordingUpstream{status: http.StatusBadRequest})
+	defer failing.Close()
+	svc, exporter := newSampledTestService(t, 0, 1, failoverConfig(1, failing.URL, 0))
+
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err == nil {
+		t.Fatal("Expected the upstream error returned")
+	}
+	names := map[string]bool{}
+	for _, span := range exporter.GetSpans() {
+		names[span.Name] = true
+	}
+	for _, name := range []string{SpanChatCompletion, SpanQuotaCheck, SpanSelectConfig, SpanUpstreamCall} {
+		if !names[name] {
+			t.Errorf("Expected the failed request's %s span exported, got %v", name, names)
+		}
+	}
+
+	// 同样的采样比例下成功的请求不导出
+	ok := httptest.NewServer(&recordingUpstream{status: http.StatusOK})
+	defer ok.Close()
+	svc, exporter = newSampledTestService(t, 0, 1, failoverConfig(1, ok.URL, 0))
+	if _, err := svc.ChatCompletions(context.Background(), failoverRequest()); err != nil {
+		t.Fatalf("ChatCompletions: %v", err)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("Expected successful requests unsampled at a rate of 0, got %d spans", len(spans))
+	}
+}
+
+func TestTracing_StreamSpansEndWhenStreamCloses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+	svc, exporter := newTracedTestService(t, 1, upstream.URL)
+
+	req := failoverRequest()
+	req.RequestID = "req-stream"
+	req.Stream = true
+	req.ChatRequest.Stream = true
+	streamResp, err := svc.ChatCompletionsStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletionsStream: %v", err)
+	}
+	for _, span := range exporter.GetSpans() {
+		if span.Name == SpanChatCompletion || span.Name == SpanUpstreamCall {
+			t.Fatalf("Expected %s to stay open until the stream closes", span.Name)
+		}
+	}
+
+	w := NewStreamWrapper(streamResp.Response.Body, context.Background(), svc, req, streamResp.APIConfigID, 0, protocol.ProtocolOpenAI)
+	w.SetTrace(streamResp.trace)
+	if _, err := io.ReadAll(w); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	w.Close()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	root, ok := spans[SpanChatCompletion]
+	if !ok {
+		t.Fatalf("Expected a %s span once the stream closed, got %v", SpanChatCompletion, spans)
+	}
+	if spanAttribute(root, AttrRequestID).AsString() != "req-stream" || spanAttribute(root, AttrConfigID).AsInt64() != 1 {
+		t.Errorf("Expected request and config attributes on the root span, got %v", root.Attributes)
+	}
+	for _, name := range []string{SpanQuotaCheck, SpanSelectConfig, SpanUpstreamCall, SpanBilling} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if child.Parent.SpanID() != root.SpanContext.SpanID() || child.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("Expected %s to be a child of %s", name, SpanChatCompletion)
+		}
+	}
+	if cost := spanAttribute(spans[SpanBilling], AttrCost).AsInt64(); cost != 42 {
+		t.Errorf("Expected the billing span to carry the settled cost, got %d", cost)
+	}
+	if root.EndTime.Before(spans[SpanBilling].EndTime) {
+		t.Error("Expected the request span to end after stream billing")
+	}
+}
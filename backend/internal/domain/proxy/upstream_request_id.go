@@ -0,0 +1,13 @@
+package proxy
+
+import "github.com/gin-gonic/gin"
+
+// UpstreamRequestIDHeader 返回给客户端的上游请求 ID，向供应商反馈问题时使用
+const UpstreamRequestIDHeader = "X-Upstream-Request-Id"
+
+// setUpstreamRequestIDHeader 上游返回了请求 ID 时写入响应头（失败的请求同样返回，便于排查）
+func setUpstreamRequestIDHeader(c *gin.Context, req *ProxyRequest) {
+	if req.UpstreamRequestID != "" {
+		c.Header(UpstreamRequestIDHeader, req.UpstreamRequestID)
+	}
+}
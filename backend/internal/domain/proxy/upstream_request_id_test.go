@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func upstreamRequestIDGateway(t *testing.T, upstream http.HandlerFunc) (*httptest.Server, *fakeLog) {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+
+	svc, l := newFailoverTestService(0, failoverConfig(1, srv.URL, 0))
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Next()
+	}, NewHandler(svc).ChatCompletionsOpenAI)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway, l
+}
+
+func TestHandler_ReturnsAndLogsUpstreamRequestID(t *testing.T) {
+	gateway, l := upstreamRequestIDGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-request-id", "req_upstream_1")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(chatResponseJSON("hello"))
+	})
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := resp.Header.Get(UpstreamRequestIDHeader); got != "req_upstream_1" {
+		t.Errorf("Expected %s req_upstream_1, got %q", UpstreamRequestIDHeader, got)
+	}
+	if len(l.created) != 1 || l.created[0].UpstreamRequestID != "req_upstream_1" {
+		t.Errorf("Expected the upstream request ID stored in the log, got %+v", l.created)
+	}
+}
+
+func TestHandler_StreamReturnsUpstreamRequestID(t *testing.T) {
+	gateway, l := upstreamRequestIDGateway(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-request-id", "req_stream_1")
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n")
+	})
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"model":"gpt-4","stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if got := resp.Header.Get(UpstreamRequestIDHeader); got != "req_stream_1" {
+		t.Errorf("Expected %s set before the stream began, got %q", UpstreamRequestIDHeader, got)
+	}
+	if len(l.created) != 1 || l.created[0].UpstreamRequestID != "req_stream_1" {
+		t.Errorf("Expected the upstream request ID stored in the stream log, got %+v", l.created)
+	}
+}
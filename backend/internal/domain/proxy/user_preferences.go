@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/domain/user"
+	"api-aggregator/backend/internal/protocol"
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreferenceSource 查询用户偏好
+type PreferenceSource interface {
+	GetPreferences(ctx context.Context, userID uint) (*user.Preferences, error)
+}
+
+// SetPreferenceSource 设置用户偏好来源，未设置时不应用用户偏好
+func (h *Handler) SetPreferenceSource(source PreferenceSource) {
+	h.preferences = source
+}
+
+// loadUserPreferences 查询用户偏好和当前 API Key 的参数覆盖规则
+// 查询失败时按未设置偏好处理，不影响请求
+func (h *Handler) loadUserPreferences(c *gin.Context, userID uint) (*user.Preferences, apikey.ParamOverrides) {
+	var overrides apikey.ParamOverrides
+	if info, ok := c.Get("api_key_info"); ok {
+		if key, ok := info.(*apikey.APIKey); ok {
+			overrides = key.ParamOverrides
+		}
+	}
+	if h.preferences == nil {
+		return nil, overrides
+	}
+	prefs, err := h.preferences.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		return nil, overrides
+	}
+	return prefs, overrides
+}
+
+// applyUserPreferences 用用户偏好填充请求未指定的值，必须在 applyAPIKeySettings 之前调用
+// 填充后仍没有模型时按协议格式返回 400，返回 false
+func (h *Handler) applyUserPreferences(c *gin.Context, proto protocol.Protocol, proxyReq *ProxyRequest) bool {
+	prefs, overrides := h.loadUserPreferences(c, proxyReq.UserID)
+	mergeUserPreferences(proxyReq, prefs, overrides)
+
+	if proxyReq.Model == "" {
+		writeValidationError(c, proto, &protocol.ValidationError{
+			Path:    []interface{}{"model"},
+			Message: "field is required",
+		})
+		return false
+	}
+	return true
+}
+
+// mergeUserPreferences 优先级：请求显式传值 > API Key 参数覆盖 > 用户偏好 > 系统默认
+// Key 的 default 规则优先于用户默认值，所以对应参数不填充；force 和 clamp 在之后的覆盖阶段照常生效
+func mergeUserPreferences(req *ProxyRequest, prefs *user.Preferences, overrides apikey.ParamOverrides) {
+	if prefs == nil {
+		return
+	}
+	if req.Model == "" && prefs.DefaultModel != "" {
+		req.Model = prefs.DefaultModel
+		if req.ChatRequest != nil {
+			req.ChatRequest.Model = prefs.DefaultModel
+		}
+	}
+	if len(req.ProviderPreference) == 0 && len(prefs.ProviderPreference) > 0 {
+		req.ProviderPreference = parseProviderPreference(strings.Join(prefs.ProviderPreference, ","))
+	}
+
+	chatReq := req.ChatRequest
+	if chatReq == nil {
+		return
+	}
+	if chatReq.Temperature == nil && !hasDefaultOverride(overrides, apikey.ParamTemperature) {
+		chatReq.Temperature = copyFloat(prefs.Temperature)
+	}
+	if chatReq.TopP == nil && !hasDefaultOverride(overrides, apikey.ParamTopP) {
+		chatReq.TopP = copyFloat(prefs.TopP)
+	}
+	if chatReq.MaxTokens == 0 && prefs.MaxTokens != nil && !hasDefaultOverride(overrides, apikey.ParamMaxTokens) {
+		chatReq.MaxTokens = *prefs.MaxTokens
+	}
+}
+
+// hasDefaultOverride API Key 是否为参数设置了 default 规则
+func hasDefaultOverride(overrides apikey.ParamOverrides, param string) bool {
+	o, ok := overrides[param]
+	return ok && o.Mode == apikey.ParamOverrideDefault
+}
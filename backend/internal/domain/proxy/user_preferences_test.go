@@ -0,0 +1,179 @@
+package proxy
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/domain/user"
+	"api-aggregator/backend/pkg/errors"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakePreferences struct {
+	prefs *user.Preferences
+}
+
+func (f *fakePreferences) GetPreferences(ctx context.Context, userID uint) (*user.Preferences, error) {
+	return f.prefs, nil
+}
+
+// captureService 记录收到的代理请求
+type captureService struct {
+	Service
+	got *ProxyRequest
+}
+
+func (s *captureService) ChatCompletions(ctx context.Context, req *ProxyRequest) (*adapter.ChatResponse, error) {
+	s.got = req
+	return nil, errors.ErrInternal
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestMergeUserPreferences_PrecedenceChain(t *testing.T) {
+	prefs := &user.Preferences{
+		Temperature: float64Ptr(0.3),
+		TopP:        float64Ptr(0.4),
+		MaxTokens:   intPtr(300),
+	}
+	overrides := apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideDefault, Value: float64Ptr(0.6)},
+		apikey.ParamTopP:        {Mode: apikey.ParamOverrideDefault, Value: float64Ptr(0.7)},
+	}
+
+	// temperature：请求显式传值优先；top_p：Key 的 default 优先于用户偏好；max_tokens：只有用户偏好
+	req := &ProxyRequest{Model: "gpt-4", ChatRequest: &adapter.ChatRequest{Model: "gpt-4", Temperature: float64Ptr(0.9)}}
+	mergeUserPreferences(req, prefs, overrides)
+	applyParamOverrides(req.ChatRequest, overrides)
+
+	chatReq := req.ChatRequest
+	if *chatReq.Temperature != 0.9 {
+		t.Errorf("Expected the explicit temperature kept, got %v", *chatReq.Temperature)
+	}
+	if *chatReq.TopP != 0.7 {
+		t.Errorf("Expected the key default top_p over the user preference, got %v", *chatReq.TopP)
+	}
+	if chatReq.MaxTokens != 300 {
+		t.Errorf("Expected the user default max_tokens, got %d", chatReq.MaxTokens)
+	}
+
+	// 都没有设置时保持为空，由上游使用默认值
+	bare := &ProxyRequest{Model: "gpt-4", ChatRequest: &adapter.ChatRequest{Model: "gpt-4"}}
+	mergeUserPreferences(bare, &user.Preferences{}, nil)
+	if bare.ChatRequest.Temperature != nil || bare.ChatRequest.TopP != nil || bare.ChatRequest.MaxTokens != 0 {
+		t.Errorf("Expected parameters left unset, got %+v", bare.ChatRequest)
+	}
+}
+
+func TestMergeUserPreferences_KeyClampAppliesToUserDefault(t *testing.T) {
+	req := &ProxyRequest{Model: "gpt-4", ChatRequest: &adapter.ChatRequest{Model: "gpt-4"}}
+	overrides := apikey.ParamOverrides{
+		apikey.ParamTemperature: {Mode: apikey.ParamOverrideClamp, Max: float64Ptr(0.5)},
+	}
+	mergeUserPreferences(req, &user.Preferences{Temperature: float64Ptr(1.2)}, overrides)
+	applyParamOverrides(req.ChatRequest, overrides)
+
+	if *req.ChatRequest.Temperature != 0.5 {
+		t.Errorf("Expected the user default clamped by the key, got %v", *req.ChatRequest.Temperature)
+	}
+}
+
+func TestMergeUserPreferences_ModelAndProviderPreference(t *testing.T) {
+	prefs := &user.Preferences{DefaultModel: "claude-3", ProviderPreference: user.StringArray{"anthropic", "openai"}}
+
+	omitted := &ProxyRequest{ChatRequest: &adapter.ChatRequest{}}
+	mergeUserPreferences(omitted, prefs, nil)
+	if omitted.Model != "claude-3" || omitted.ChatRequest.Model != "claude-3" {
+		t.Errorf("Expected the user default model, got %q / %q", omitted.Model, omitted.ChatRequest.Model)
+	}
+	if strings.Join(omitted.ProviderPreference, ",") != "anthropic,openai" {
+		t.Errorf("Expected the user provider preference, got %v", omitted.ProviderPreference)
+	}
+
+	explicit := &ProxyRequest{Model: "gpt-4", ProviderPreference: []string{"gemini"}, ChatRequest: &adapter.ChatRequest{Model: "gpt-4"}}
+	mergeUserPreferences(explicit, prefs, nil)
+	if explicit.Model != "gpt-4" || strings.Join(explicit.ProviderPreference, ",") != "gemini" {
+		t.Errorf("Expected request values kept, got %q %v", explicit.Model, explicit.ProviderPreference)
+	}
+}
+
+func preferenceGateway(t *testing.T, prefs *user.Preferences) (*httptest.Server, *captureService) {
+	t.Helper()
+	svc := &captureService{}
+	h := NewHandler(svc)
+	if prefs != nil {
+		h.SetPreferenceSource(&fakePreferences{prefs: prefs})
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("api_key_id", uint(2))
+		c.Next()
+	}, h.ChatCompletionsOpenAI)
+	gateway := httptest.NewServer(engine)
+	t.Cleanup(gateway.Close)
+	return gateway, svc
+}
+
+func TestHandler_AppliesUserPreferencesWhenRequestOmitsValues(t *testing.T) {
+	gateway, svc := preferenceGateway(t, &user.Preferences{
+		DefaultModel:       "gpt-4o",
+		ProviderPreference: user.StringArray{"openai"},
+		Temperature:        float64Ptr(0.2),
+		MaxTokens:          intPtr(128),
+	})
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if svc.got == nil {
+		t.Fatalf("Expected the request to reach the service, got status %d", resp.StatusCode)
+	}
+	if svc.got.Model != "gpt-4o" || svc.got.ChatRequest.Model != "gpt-4o" {
+		t.Errorf("Expected the default model, got %q", svc.got.Model)
+	}
+	if len(svc.got.ProviderPreference) != 1 || svc.got.ProviderPreference[0] != "openai" {
+		t.Errorf("Expected the user provider preference, got %v", svc.got.ProviderPreference)
+	}
+	if svc.got.ChatRequest.Temperature == nil || *svc.got.ChatRequest.Temperature != 0.2 || svc.got.ChatRequest.MaxTokens != 128 {
+		t.Errorf("Expected default parameters applied, got %+v", svc.got.ChatRequest)
+	}
+}
+
+func TestHandler_MissingModelWithoutPreferenceIsRejected(t *testing.T) {
+	gateway, svc := preferenceGateway(t, nil)
+
+	resp, err := http.Post(gateway.URL+"/v1/chat/completions", "application/json",
+		strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || svc.got != nil {
+		t.Fatalf("Expected 400 before reaching the service, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Error struct {
+			Param string `json:"param"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.Error.Param != "model" {
+		t.Errorf("Expected the error to point at model, got %q", body.Error.Param)
+	}
+}
@@ -4,15 +4,29 @@ import "time"
 
 // QuotaInfoResponse 配额信息响应
 type QuotaInfoResponse struct {
-	TotalQuota     int64      `json:"total_quota"`
-	UsedQuota      int64      `json:"used_quota"`
-	RemainingQuota int64      `json:"remaining_quota"`
-	LastSignIn     *time.Time `json:"last_sign_in,omitempty"`
+	TotalQuota      int64      `json:"total_quota"`
+	UsedQuota       int64      `json:"used_quota"`
+	RemainingQuota  int64      `json:"remaining_quota"`
+	LastSignIn      *time.Time `json:"last_sign_in,omitempty"`
+	SignInStreak    int        `json:"sign_in_streak"`   // 当前连续签到天数，中断后为 0
+	OverdraftLimit  int64      `json:"overdraft_limit"`  // 允许透支的配额
+	Overdrawn       int64      `json:"overdrawn"`        // 已透支的配额，由下一次配额发放抵扣
+	StreamPrecharge bool       `json:"stream_precharge"` // 流式请求开始前是否按 max_tokens 预扣配额
+}
+
+// OverdraftAvailable 剩余可透支的配额
+func (r *QuotaInfoResponse) OverdraftAvailable() int64 {
+	if r.Overdrawn >= r.OverdraftLimit {
+		return 0
+	}
+	return r.OverdraftLimit - r.Overdrawn
 }
 
 // SignInResponse 签到响应
 type SignInResponse struct {
 	QuotaAwarded   int       `json:"quota_awarded"`
+	Streak         int       `json:"streak"`
+	StreakBonus    int       `json:"streak_bonus"`
 	TotalQuota     int64     `json:"total_quota"`
 	RemainingQuota int64     `json:"remaining_quota"`
 	SignInDate     time.Time `json:"sign_in_date"`
@@ -51,3 +65,24 @@ type CheckQuotaResponse struct {
 	RemainingQuota     int64 `json:"remaining_quota"`
 	RequiredAmount     int64 `json:"required_amount"`
 }
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	Credits      int64  `json:"credits" binding:"required,min=1"`
+	Reason       string `json:"reason" binding:"required,max=500"`
+	RequestLogID *uint  `json:"request_log_id" binding:"omitempty"`
+}
+
+// RefundResponse 退款响应
+type RefundResponse struct {
+	LedgerID       uint  `json:"ledger_id"`
+	Credits        int64 `json:"credits"`
+	UsedQuota      int64 `json:"used_quota"`
+	RemainingQuota int64 `json:"remaining_quota"`
+}
+
+// CreateHoldRequest 创建配额预留请求
+type CreateHoldRequest struct {
+	Amount     int64 `json:"amount" binding:"required,min=1"`
+	TTLSeconds int   `json:"ttl_seconds" binding:"omitempty,min=1"` // 不传使用默认有效期
+}
@@ -3,6 +3,7 @@ package quota
 import (
 	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/response"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -226,3 +227,132 @@ func (h *Handler) DeductQuota(c *gin.Context) {
 
 	response.SuccessWithMessage(c, "Quota deducted successfully", nil)
 }
+
+// Refund 管理员退款
+// @Summary 退还用户配额
+// @Description 为失败或降级的请求退还配额，并记录到账本（管理员）
+// @Tags Quota
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Param request body RefundRequest true "退款请求"
+// @Success 200 {object} RefundResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/users/{id}/refund [post]
+func (h *Handler) Refund(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID", "User ID must be a valid number")
+		return
+	}
+
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	var operatorID uint
+	if v, exists := c.Get("user_id"); exists {
+		operatorID, _ = v.(uint)
+	}
+
+	refundResp, err := h.service.Refund(c.Request.Context(), uint(id), operatorID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrUserNotFound):
+			response.NotFound(c, "User not found")
+		case errors.Is(err, errors.ErrNotFound):
+			response.NotFound(c, "Request log not found")
+		case errors.Is(err, errors.ErrRefundExceedsCharge), errors.Is(err, errors.ErrInvalidParam):
+			appErr := err.(*errors.AppError)
+			response.BadRequest(c, appErr.Message, appErr.Details)
+		default:
+			response.InternalError(c, err)
+		}
+		return
+	}
+
+	response.Success(c, refundResp)
+}
+
+// CreateHold 创建配额预留
+// @Summary 创建配额预留
+// @Description 预扣指定配额并返回预留ID，代理请求通过 X-Prism-Quota-Hold 头引用后按实际用量结算，到期未使用自动释放
+// @Tags Quota
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateHoldRequest true "预留请求"
+// @Success 201 {object} QuotaHold
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 429 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/user/quota/hold [post]
+func (h *Handler) CreateHold(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req CreateHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	hold, err := h.service.CreateHold(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrUserNotFound):
+			response.NotFound(c, "User not found")
+		case errors.Is(err, errors.ErrQuotaExceeded):
+			response.TooManyRequests(c, "Quota exceeded")
+		case errors.Is(err, errors.ErrInvalidParam):
+			response.BadRequest(c, err.Error(), "")
+		default:
+			response.InternalError(c, err)
+		}
+		return
+	}
+
+	response.Created(c, hold)
+}
+
+// ReleaseHold 释放配额预留
+// @Summary 释放配额预留
+// @Description 释放尚未使用的配额预留并退还预扣的配额
+// @Tags Quota
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "预留ID"
+// @Success 200 {object} QuotaHold
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/user/quota/hold/{id} [delete]
+func (h *Handler) ReleaseHold(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	hold, err := h.service.ReleaseHold(c.Request.Context(), userID.(uint), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+			response.Conflict(c, "Quota hold not found, expired or already used")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, hold)
+}
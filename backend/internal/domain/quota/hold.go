@@ -0,0 +1,192 @@
+package quota
+
+import (
+	"api-aggregator/backend/pkg/crypto"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"time"
+)
+
+// holdExpiryInterval 过期预留的检查间隔
+const holdExpiryInterval = time.Minute
+
+// holdExpiryBatch 单轮最多释放的过期预留数
+const holdExpiryBatch = 500
+
+// holdTTL 返回预留有效期，请求未指定时使用默认值，超过上限时拒绝
+func (s *service) holdTTL(requested int) (time.Duration, error) {
+	ttl, maxTTL := 5*time.Minute, time.Hour
+	if s.runtimeConfig != nil {
+		ttl, maxTTL = s.runtimeConfig.Get().GetQuotaHoldTTL()
+	}
+	if requested <= 0 {
+		return ttl, nil
+	}
+	if d := time.Duration(requested) * time.Second; d <= maxTTL {
+		return d, nil
+	}
+	return 0, errors.ErrInvalidParam.WithDetails("ttl_seconds exceeds the maximum hold lifetime")
+}
+
+// CreateHold 从用户配额中预扣 amount 并创建预留，到期未被请求引用时自动释放
+func (s *service) CreateHold(ctx context.Context, userID uint, req *CreateHoldRequest) (*QuotaHold, error) {
+	if req.Amount <= 0 {
+		return nil, errors.ErrInvalidParam.WithDetails("Amount must be positive")
+	}
+	ttl, err := s.holdTTL(req.TTLSeconds)
+	if err != nil {
+		return nil, err
+	}
+	id, err := crypto.GenerateRandomString(24)
+	if err != nil {
+		return nil, errors.Wrap(err, 500001, "Failed to generate hold ID")
+	}
+
+	if err := s.DeductQuota(ctx, userID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	hold := &QuotaHold{
+		ID:        "hold_" + id,
+		UserID:    userID,
+		Amount:    req.Amount,
+		Status:    HoldStatusHeld,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.repo.CreateHold(ctx, hold); err != nil {
+		s.logger.Error("Failed to create quota hold", logger.Uint("user_id", userID), logger.Error(err))
+		s.ReleaseQuota(ctx, userID, req.Amount)
+		return nil, errors.Wrap(err, 500002, "Failed to create quota hold")
+	}
+
+	s.logger.Info("Quota hold created",
+		logger.Uint("user_id", userID),
+		logger.String("hold_id", hold.ID),
+		logger.Int64("amount", hold.Amount),
+		logger.Duration("ttl", ttl))
+	return hold, nil
+}
+
+// ConsumeHold 将预留转交给引用它的请求，返回预扣的配额；请求结束时由调用方按实际用量多退少补
+// 预留不存在、不属于该用户、已过期或已被使用时返回 ErrQuotaHoldUnavailable
+func (s *service) ConsumeHold(ctx context.Context, userID uint, holdID string) (int64, error) {
+	hold, err := s.repo.FindHold(ctx, holdID)
+	if err != nil {
+		s.logger.Error("Failed to find quota hold", logger.String("hold_id", holdID), logger.Error(err))
+		return 0, errors.Wrap(err, 500002, "Failed to find quota hold")
+	}
+	if hold == nil || hold.UserID != userID {
+		return 0, errors.ErrQuotaHoldUnavailable
+	}
+
+	ok, err := s.repo.SettleHold(ctx, holdID, userID, HoldStatusConsumed, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to consume quota hold", logger.String("hold_id", holdID), logger.Error(err))
+		return 0, errors.Wrap(err, 500002, "Failed to consume quota hold")
+	}
+	if !ok {
+		return 0, errors.ErrQuotaHoldUnavailable
+	}
+	return hold.Amount, nil
+}
+
+// ReleaseHold 用户主动释放未使用的预留，退还预扣的配额
+func (s *service) ReleaseHold(ctx context.Context, userID uint, holdID string) (*QuotaHold, error) {
+	hold, err := s.repo.FindHold(ctx, holdID)
+	if err != nil {
+		s.logger.Error("Failed to find quota hold", logger.String("hold_id", holdID), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to find quota hold")
+	}
+	if hold == nil || hold.UserID != userID {
+		return nil, errors.ErrQuotaHoldUnavailable
+	}
+
+	now := time.Now()
+	ok, err := s.repo.SettleHold(ctx, holdID, userID, HoldStatusReleased, now)
+	if err != nil {
+		s.logger.Error("Failed to release quota hold", logger.String("hold_id", holdID), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to release quota hold")
+	}
+	if !ok {
+		return nil, errors.ErrQuotaHoldUnavailable
+	}
+	if err := s.ReleaseQuota(ctx, userID, hold.Amount); err != nil {
+		return nil, err
+	}
+
+	hold.Status = HoldStatusReleased
+	hold.SettledAt = &now
+	return hold, nil
+}
+
+// ExpireHolds 释放截至 now 已过期且未被使用的预留，返回释放的数量
+func (s *service) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	holds, err := s.repo.FindExpiredHolds(ctx, now, holdExpiryBatch)
+	if err != nil {
+		return 0, errors.Wrap(err, 500002, "Failed to find expired quota holds")
+	}
+
+	released := 0
+	for _, hold := range holds {
+		ok, err := s.repo.ExpireHold(ctx, hold.ID, now)
+		if err != nil {
+			s.logger.Error("Failed to expire quota hold", logger.String("hold_id", hold.ID), logger.Error(err))
+			continue
+		}
+		if !ok {
+			// 已被请求使用或用户释放
+			continue
+		}
+		if err := s.ReleaseQuota(ctx, hold.UserID, hold.Amount); err != nil {
+			continue
+		}
+		released++
+	}
+	if released > 0 {
+		s.logger.Info("Expired quota holds released", logger.Int("count", released))
+	}
+	return released, nil
+}
+
+// HoldExpirer 定期释放过期的配额预留
+type HoldExpirer struct {
+	service Service
+	logger  logger.Logger
+}
+
+// NewHoldExpirer 创建过期预留释放任务
+func NewHoldExpirer(service Service, logger logger.Logger) *HoldExpirer {
+	return &HoldExpirer{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Start 按间隔释放过期预留，直到 ctx 取消
+func (e *HoldExpirer) Start(ctx context.Context) {
+	ticker := time.NewTicker(holdExpiryInterval)
+	defer ticker.Stop()
+
+	e.logger.Info("Quota hold expirer started", logger.Duration("interval", holdExpiryInterval))
+
+	for {
+		select {
+		case <-ticker.C:
+			e.RunOnce(ctx, time.Now())
+		case <-ctx.Done():
+			e.logger.Info("Quota hold expirer stopped")
+			return
+		}
+	}
+}
+
+// RunOnce 释放截至 now 已过期的预留
+func (e *HoldExpirer) RunOnce(ctx context.Context, now time.Time) int {
+	released, err := e.service.ExpireHolds(ctx, now)
+	if err != nil {
+		e.logger.Error("Failed to release expired quota holds", logger.Error(err))
+	}
+	return released
+}
@@ -0,0 +1,150 @@
+package quota
+
+import (
+	"api-aggregator/backend/internal/domain/user"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"testing"
+	"time"
+)
+
+func (r *memRepository) CreateHold(ctx context.Context, hold *QuotaHold) error {
+	cp := *hold
+	r.holds[hold.ID] = &cp
+	return nil
+}
+
+func (r *memRepository) FindHold(ctx context.Context, id string) (*QuotaHold, error) {
+	hold, ok := r.holds[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *hold
+	return &cp, nil
+}
+
+func (r *memRepository) SettleHold(ctx context.Context, id string, userID uint, status string, now time.Time) (bool, error) {
+	hold, ok := r.holds[id]
+	if !ok || hold.UserID != userID || hold.Status != HoldStatusHeld || !hold.ExpiresAt.After(now) {
+		return false, nil
+	}
+	hold.Status, hold.SettledAt = status, &now
+	return true, nil
+}
+
+func (r *memRepository) FindExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*QuotaHold, error) {
+	var holds []*QuotaHold
+	for _, hold := range r.holds {
+		if hold.Status == HoldStatusHeld && !hold.ExpiresAt.After(now) && len(holds) < limit {
+			cp := *hold
+			holds = append(holds, &cp)
+		}
+	}
+	return holds, nil
+}
+
+func (r *memRepository) ExpireHold(ctx context.Context, id string, now time.Time) (bool, error) {
+	hold, ok := r.holds[id]
+	if !ok || hold.Status != HoldStatusHeld || hold.ExpiresAt.After(now) {
+		return false, nil
+	}
+	hold.Status, hold.SettledAt = HoldStatusExpired, &now
+	return true, nil
+}
+
+func TestCreateHold_ReservesQuota(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000}
+	svc := newTestService(repo)
+
+	hold, err := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 300})
+	if err != nil {
+		t.Fatalf("CreateHold failed: %v", err)
+	}
+	if repo.users[1].UsedQuota != 300 {
+		t.Errorf("Expected 300 reserved, used quota %d", repo.users[1].UsedQuota)
+	}
+	if hold.Status != HoldStatusHeld || time.Until(hold.ExpiresAt) < 4*time.Minute {
+		t.Errorf("Expected a held reservation with the default TTL, got %+v", hold)
+	}
+
+	// 超出剩余配额的预留被拒绝，不创建记录
+	if _, err := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 800}); !errors.Is(err, errors.ErrQuotaExceeded) {
+		t.Errorf("Expected quota exceeded, got %v", err)
+	}
+	if len(repo.holds) != 1 {
+		t.Errorf("Expected only the first hold stored, got %d", len(repo.holds))
+	}
+}
+
+func TestCreateHold_RejectsTTLAboveMaximum(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000}
+	rc := runtime.NewManager(nil)
+	rc.Get().QuotaHoldMaxTTL = 10 * time.Minute
+	svc := NewService(repo, rc, *logger.NewNop())
+
+	if _, err := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 10, TTLSeconds: 601}); !errors.Is(err, errors.ErrInvalidParam) {
+		t.Errorf("Expected invalid parameter, got %v", err)
+	}
+	if repo.users[1].UsedQuota != 0 {
+		t.Errorf("Expected nothing reserved, used quota %d", repo.users[1].UsedQuota)
+	}
+}
+
+func TestConsumeHold_UsableOnce(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000}
+	repo.users[2] = &user.User{ID: 2, Quota: 1000}
+	svc := newTestService(repo)
+	hold, _ := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 300})
+
+	if _, err := svc.ConsumeHold(context.Background(), 2, hold.ID); !errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		t.Errorf("Expected another user's hold to be unavailable, got %v", err)
+	}
+	amount, err := svc.ConsumeHold(context.Background(), 1, hold.ID)
+	if err != nil || amount != 300 {
+		t.Fatalf("Expected 300 consumed, got %d (%v)", amount, err)
+	}
+	if _, err := svc.ConsumeHold(context.Background(), 1, hold.ID); !errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		t.Errorf("Expected a consumed hold to be unavailable, got %v", err)
+	}
+	if _, err := svc.ReleaseHold(context.Background(), 1, hold.ID); !errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		t.Errorf("Expected a consumed hold not to be released, got %v", err)
+	}
+	// 预扣的配额交给请求结算，使用预留本身不退还
+	if repo.users[1].UsedQuota != 300 {
+		t.Errorf("Expected the reservation kept for the request, used quota %d", repo.users[1].UsedQuota)
+	}
+}
+
+func TestExpireHolds_ReleasesUnreferencedHolds(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000}
+	svc := newTestService(repo)
+
+	unused, _ := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 300, TTLSeconds: 60})
+	used, _ := svc.CreateHold(context.Background(), 1, &CreateHoldRequest{Amount: 200, TTLSeconds: 60})
+	svc.ConsumeHold(context.Background(), 1, used.ID)
+
+	expirer := NewHoldExpirer(svc, *logger.NewNop())
+	if released := expirer.RunOnce(context.Background(), time.Now()); released != 0 {
+		t.Errorf("Expected nothing released before the TTL, got %d", released)
+	}
+
+	later := time.Now().Add(2 * time.Minute)
+	if released := expirer.RunOnce(context.Background(), later); released != 1 {
+		t.Fatalf("Expected the unused hold released, got %d", released)
+	}
+	if repo.holds[unused.ID].Status != HoldStatusExpired || repo.users[1].UsedQuota != 200 {
+		t.Errorf("Expected 300 returned, status %s used quota %d", repo.holds[unused.ID].Status, repo.users[1].UsedQuota)
+	}
+	if _, err := svc.ConsumeHold(context.Background(), 1, unused.ID); !errors.Is(err, errors.ErrQuotaHoldUnavailable) {
+		t.Errorf("Expected an expired hold to be unavailable, got %v", err)
+	}
+	if released := expirer.RunOnce(context.Background(), later); released != 0 {
+		t.Errorf("Expected an expired hold released only once, got %d", released)
+	}
+}
@@ -11,6 +11,8 @@ type SignInRecord struct {
 	UpdatedAt    time.Time      `json:"updated_at"`
 	UserID       uint           `gorm:"not null;index" json:"user_id"`
 	QuotaAwarded int            `gorm:"not null" json:"quota_awarded"`
+	Streak       int            `gorm:"not null;default:1" json:"streak"`       // 截至本次的连续签到天数
+	StreakBonus  int            `gorm:"not null;default:0" json:"streak_bonus"` // 连续签到带来的额外配额
 }
 
 // TableName 鎸囧畾琛ㄥ悕
@@ -26,6 +28,13 @@ func (r *SignInRecord) IsToday() bool {
 		r.CreatedAt.Day() == now.Day()
 }
 
+// IsDayBefore 是否是 day 前一天的签到
+func (r *SignInRecord) IsDayBefore(day time.Time) bool {
+	y1, m1, d1 := r.CreatedAt.In(day.Location()).Date()
+	y2, m2, d2 := day.AddDate(0, 0, -1).Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
 // QuotaUsageRecord 閰嶉浣跨敤璁板綍锛堢敤浜庣粺璁★級
 type QuotaUsageRecord struct {
 	Date   string `json:"date"`
@@ -36,3 +45,62 @@ type QuotaUsageRecord struct {
 const (
 	DailySignInQuota = 1000 // 姣忔棩绛惧埌濂栧姳閰嶉
 )
+
+// 账本记录类型
+const (
+	LedgerTypeRefund     = "refund"      // 管理员手动退款
+	LedgerTypeAutoRefund = "auto_refund" // 失败请求自动退款
+
+	LedgerTypeOverdraft         = "overdraft"          // 扣费超出剩余配额的透支部分（负数）
+	LedgerTypeOverdraftSettled  = "overdraft_settled"  // 配额发放时抵扣的透支
+	LedgerTypeOverdraftReleased = "overdraft_released" // 退还预扣时冲回的透支
+)
+
+// QuotaLedger 配额账本记录（正数表示返还给用户的配额）
+type QuotaLedger struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	Type         string    `gorm:"not null;size:50" json:"type"`
+	Amount       int64     `gorm:"not null" json:"amount"`
+	Reason       string    `gorm:"type:text" json:"reason"`
+	RequestLogID *uint     `gorm:"index" json:"request_log_id,omitempty"`
+	RequestID    string    `gorm:"size:255" json:"request_id,omitempty"` // 没有请求日志的请求（关闭了日志）按网关请求 ID 关联
+	OperatorID   *uint     `json:"operator_id,omitempty"`
+}
+
+// TableName 指定表名
+func (QuotaLedger) TableName() string {
+	return "quota_ledger"
+}
+
+// RequestCharge 请求日志的计费信息
+type RequestCharge struct {
+	UserID    uint
+	QuotaCost int64
+}
+
+// 配额预留状态
+const (
+	HoldStatusHeld     = "held"     // 已预扣，等待请求引用
+	HoldStatusConsumed = "consumed" // 已被请求引用，按实际用量结算
+	HoldStatusReleased = "released" // 用户主动释放
+	HoldStatusExpired  = "expired"  // 到期未使用，自动释放
+)
+
+// QuotaHold 配额预留：创建时从用户配额中预扣，代理请求引用后转为该请求的预扣，结束时多退少补
+type QuotaHold struct {
+	ID        string     `gorm:"primaryKey;size:40" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `gorm:"not null;index" json:"-"`
+	Amount    int64      `gorm:"not null" json:"amount"`
+	Status    string     `gorm:"not null;size:20;default:'held'" json:"status"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	SettledAt *time.Time `json:"settled_at,omitempty"`
+}
+
+// TableName 指定表名
+func (QuotaHold) TableName() string {
+	return "quota_holds"
+}
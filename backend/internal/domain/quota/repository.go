@@ -18,7 +18,8 @@ type Repository interface {
 	UpdateUser(ctx context.Context, user *user.User) error
 	UpdateUserQuota(ctx context.Context, userID uint, quota int64) error
 	UpdateUserUsedQuota(ctx context.Context, userID uint, usedQuota int64) error
-	IncrementUsedQuota(ctx context.Context, userID uint, amount int64) error
+	IncrementUsedQuota(ctx context.Context, userID uint, amount, defaultOverdraft int64) error
+	DecrementUsedQuota(ctx context.Context, userID uint, amount int64) error
 	
 	// 签到记录相关
 	CreateSignInRecord(ctx context.Context, record *SignInRecord) error
@@ -28,6 +29,20 @@ type Repository interface {
 	
 	// 使用统计相关
 	GetDailyUsage(ctx context.Context, userID uint, startDate, endDate time.Time) (map[string]int, error)
+
+	// 退款与账本相关
+	FindRequestCharge(ctx context.Context, requestLogID uint) (*RequestCharge, error)
+	SumRefundsByRequestLog(ctx context.Context, requestLogID uint) (int64, error)
+	SumRefundsByRequestID(ctx context.Context, userID uint, requestID string) (int64, error)
+	ApplyRefund(ctx context.Context, entry *QuotaLedger) (*user.User, error)
+	CreateLedgerEntry(ctx context.Context, entry *QuotaLedger) error
+
+	// 配额预留相关
+	CreateHold(ctx context.Context, hold *QuotaHold) error
+	FindHold(ctx context.Context, id string) (*QuotaHold, error)
+	SettleHold(ctx context.Context, id string, userID uint, status string, now time.Time) (bool, error)
+	FindExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*QuotaHold, error)
+	ExpireHold(ctx context.Context, id string, now time.Time) (bool, error)
 }
 
 // repository 配额仓储实现
@@ -73,7 +88,8 @@ func (r *repository) UpdateUserUsedQuota(ctx context.Context, userID uint, usedQ
 }
 
 // IncrementUsedQuota 增加用户已使用配额（带事务和行锁）
-func (r *repository) IncrementUsedQuota(ctx context.Context, userID uint, amount int64) error {
+// 允许在透支额度内扣成负数，透支部分写入账本
+func (r *repository) IncrementUsedQuota(ctx context.Context, userID uint, amount, defaultOverdraft int64) error {
 	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// 使用行锁查询用户
 		var u user.User
@@ -84,18 +100,88 @@ func (r *repository) IncrementUsedQuota(ctx context.Context, userID uint, amount
 			return err
 		}
 
-		// 检查配额是否充足
-		if u.Quota-u.UsedQuota < amount {
+		// 检查配额是否充足（含透支额度）
+		remaining := u.Quota - u.UsedQuota
+		if remaining+u.OverdraftAllowance(defaultOverdraft) < amount {
 			return apperrors.ErrQuotaExceeded
 		}
 
 		// 扣减配额
-		return tx.Model(&user.User{}).
+		if err := tx.Model(&user.User{}).
+			Where("id = ?", userID).
+			UpdateColumn("used_quota", gorm.Expr("used_quota + ?", amount)).Error; err != nil {
+			return err
+		}
+
+		if overdrawn := overdraftPortion(remaining, amount); overdrawn > 0 {
+			return tx.Create(&QuotaLedger{
+				UserID: userID,
+				Type:   LedgerTypeOverdraft,
+				Amount: -overdrawn,
+				Reason: "Charge exceeded remaining quota",
+			}).Error
+		}
+		return nil
+	})
+}
+
+// DecrementUsedQuota 减少用户已使用配额，用于退还预扣但未用完的部分（带事务和行锁）
+// 退还的部分冲减了透支时写入账本，与扣费时记录的透支相抵
+func (r *repository) DecrementUsedQuota(ctx context.Context, userID uint, amount int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var u user.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, userID).Error; err != nil {
+			if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.ErrUserNotFound
+			}
+			return err
+		}
+
+		if err := tx.Model(&user.User{}).
 			Where("id = ?", userID).
-			UpdateColumn("used_quota", gorm.Expr("used_quota + ?", amount)).Error
+			UpdateColumn("used_quota", gorm.Expr("used_quota - ?", amount)).Error; err != nil {
+			return err
+		}
+
+		if released := overdraftReleased(u.Quota-u.UsedQuota, amount); released > 0 {
+			return tx.Create(&QuotaLedger{
+				UserID: userID,
+				Type:   LedgerTypeOverdraftReleased,
+				Amount: released,
+				Reason: "Released reservation reduced overdraft",
+			}).Error
+		}
+		return nil
 	})
 }
 
+// overdraftPortion 计算一次扣费中超出剩余配额（透支）的部分
+func overdraftPortion(remaining, amount int64) int64 {
+	if remaining < 0 {
+		remaining = 0
+	}
+	if amount <= remaining {
+		return 0
+	}
+	return amount - remaining
+}
+
+// overdraftReleased 计算一次退还中冲减透支的部分：退还前已透支的配额中被退还抵消的数量
+func overdraftReleased(remaining, amount int64) int64 {
+	if remaining >= 0 {
+		return 0
+	}
+	if overdrawn := -remaining; amount > overdrawn {
+		return overdrawn
+	}
+	return amount
+}
+
+// CreateLedgerEntry 写入账本记录
+func (r *repository) CreateLedgerEntry(ctx context.Context, entry *QuotaLedger) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
 // CreateSignInRecord 创建签到记录
 func (r *repository) CreateSignInRecord(ctx context.Context, record *SignInRecord) error {
 	return r.db.WithContext(ctx).Create(record).Error
@@ -175,3 +261,150 @@ func (r *repository) GetDailyUsage(ctx context.Context, userID uint, startDate,
 	
 	return usageMap, nil
 }
+
+// FindRequestCharge 查询请求日志的计费信息
+func (r *repository) FindRequestCharge(ctx context.Context, requestLogID uint) (*RequestCharge, error) {
+	var charge RequestCharge
+	result := r.db.WithContext(ctx).
+		Table("request_logs").
+		Select("user_id, quota_cost").
+		Where("id = ?", requestLogID).
+		Scan(&charge)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &charge, nil
+}
+
+// SumRefundsByRequestLog 统计某个请求已退款的配额
+func (r *repository) SumRefundsByRequestLog(ctx context.Context, requestLogID uint) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).
+		Model(&QuotaLedger{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("request_log_id = ? AND type IN ?", requestLogID, []string{LedgerTypeRefund, LedgerTypeAutoRefund}).
+		Scan(&total).Error
+	return total, err
+}
+
+// SumRefundsByRequestID 统计没有请求日志的请求已退款的配额
+func (r *repository) SumRefundsByRequestID(ctx context.Context, userID uint, requestID string) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).
+		Model(&QuotaLedger{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("user_id = ? AND request_id = ? AND type IN ?", userID, requestID, []string{LedgerTypeRefund, LedgerTypeAutoRefund}).
+		Scan(&total).Error
+	return total, err
+}
+
+// ApplyRefund 退还配额并写入账本（带事务和行锁）
+// 关联请求日志时，在同一事务内校验累计退款不超过原始扣费
+func (r *repository) ApplyRefund(ctx context.Context, entry *QuotaLedger) (*user.User, error) {
+	var u user.User
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定用户行，串行化同一用户的退款
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, entry.UserID).Error; err != nil {
+			if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+				return apperrors.ErrUserNotFound
+			}
+			return err
+		}
+
+		if entry.RequestLogID != nil {
+			var charge RequestCharge
+			result := tx.Table("request_logs").
+				Select("user_id, quota_cost").
+				Where("id = ?", *entry.RequestLogID).
+				Scan(&charge)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 || charge.UserID != entry.UserID {
+				return apperrors.ErrNotFound.WithDetails("Request log not found for user")
+			}
+
+			var refunded int64
+			if err := tx.Model(&QuotaLedger{}).
+				Select("COALESCE(SUM(amount), 0)").
+				Where("request_log_id = ? AND type IN ?", *entry.RequestLogID, []string{LedgerTypeRefund, LedgerTypeAutoRefund}).
+				Scan(&refunded).Error; err != nil {
+				return err
+			}
+			if refunded+entry.Amount > charge.QuotaCost {
+				return apperrors.ErrRefundExceedsCharge
+			}
+		}
+
+		// 优先冲减已使用配额，不足部分计入总配额
+		fromUsed := entry.Amount
+		if fromUsed > u.UsedQuota {
+			fromUsed = u.UsedQuota
+		}
+		u.UsedQuota -= fromUsed
+		u.Quota += entry.Amount - fromUsed
+
+		if err := tx.Model(&user.User{}).
+			Where("id = ?", u.ID).
+			UpdateColumns(map[string]interface{}{
+				"used_quota": u.UsedQuota,
+				"quota":      u.Quota,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(entry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateHold 创建配额预留
+func (r *repository) CreateHold(ctx context.Context, hold *QuotaHold) error {
+	return r.db.WithContext(ctx).Create(hold).Error
+}
+
+// FindHold 根据 ID 查找配额预留，不存在时返回 nil
+func (r *repository) FindHold(ctx context.Context, id string) (*QuotaHold, error) {
+	var hold QuotaHold
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&hold).Error; err != nil {
+		if stdErrors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &hold, nil
+}
+
+// SettleHold 将未过期的预留改为 status（已使用或已释放），预留不存在、已结束或已过期时返回 false
+// 条件更新保证同一预留只会被使用或释放一次
+func (r *repository) SettleHold(ctx context.Context, id string, userID uint, status string, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&QuotaHold{}).
+		Where("id = ? AND user_id = ? AND status = ? AND expires_at > ?", id, userID, HoldStatusHeld, now).
+		Updates(map[string]interface{}{"status": status, "settled_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindExpiredHolds 查找已过期但尚未释放的预留
+func (r *repository) FindExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*QuotaHold, error) {
+	var holds []*QuotaHold
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", HoldStatusHeld, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&holds).Error
+	return holds, err
+}
+
+// ExpireHold 将已过期的预留标记为过期，已被使用或释放时返回 false
+func (r *repository) ExpireHold(ctx context.Context, id string, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&QuotaHold{}).
+		Where("id = ? AND status = ? AND expires_at <= ?", id, HoldStatusHeld, now).
+		Updates(map[string]interface{}{"status": HoldStatusExpired, "settled_at": now})
+	return result.RowsAffected > 0, result.Error
+}
@@ -3,7 +3,9 @@ package quota
 import (
 	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
 	"context"
+	"fmt"
 	"time"
 )
 
@@ -12,21 +14,33 @@ type Service interface {
 	GetQuotaInfo(ctx context.Context, userID uint) (*QuotaInfoResponse, error)
 	SignIn(ctx context.Context, userID uint) (*SignInResponse, error)
 	DeductQuota(ctx context.Context, userID uint, amount int64) error
+	ReleaseQuota(ctx context.Context, userID uint, amount int64) error
 	CheckQuota(ctx context.Context, userID uint, amount int64) (*CheckQuotaResponse, error)
 	GetUsageHistory(ctx context.Context, userID uint, days int) (*UsageHistoryResponse, error)
+	Refund(ctx context.Context, userID, operatorID uint, req *RefundRequest) (*RefundResponse, error)
+	AutoRefund(ctx context.Context, userID, requestLogID uint, reason string) (int64, error)
+	AutoRefundRequest(ctx context.Context, userID uint, requestID string, charged int64, reason string) (int64, error)
+
+	// 配额预留
+	CreateHold(ctx context.Context, userID uint, req *CreateHoldRequest) (*QuotaHold, error)
+	ConsumeHold(ctx context.Context, userID uint, holdID string) (int64, error)
+	ReleaseHold(ctx context.Context, userID uint, holdID string) (*QuotaHold, error)
+	ExpireHolds(ctx context.Context, now time.Time) (int, error)
 }
 
 // service 配额服务实现
 type service struct {
-	repo   Repository
-	logger logger.Logger
+	repo          Repository
+	runtimeConfig *runtime.Manager
+	logger        logger.Logger
 }
 
-// NewService 创建配额服务
-func NewService(repo Repository, logger logger.Logger) Service {
+// NewService 创建配额服务，runtimeConfig 为 nil 时签到使用默认奖励策略
+func NewService(repo Repository, runtimeConfig *runtime.Manager, logger logger.Logger) Service {
 	return &service{
-		repo:   repo,
-		logger: logger,
+		repo:          repo,
+		runtimeConfig: runtimeConfig,
+		logger:        logger,
 	}
 }
 
@@ -42,15 +56,31 @@ func (s *service) GetQuotaInfo(ctx context.Context, userID uint) (*QuotaInfoResp
 	}
 
 	remainingQuota := user.Quota - user.UsedQuota
+	overdrawn := int64(0)
 	if remainingQuota < 0 {
+		overdrawn = -remainingQuota
 		remainingQuota = 0
 	}
 
+	// 当前连续签到天数：最近一次签到在今天或昨天时仍然有效
+	streak := 0
+	history, err := s.repo.GetSignInHistory(ctx, userID, 1)
+	if err != nil {
+		s.logger.Warn("Failed to get sign-in history", logger.Uint("user_id", userID), logger.Error(err))
+	} else if len(history) > 0 && (history[0].IsToday() || history[0].IsDayBefore(time.Now())) {
+		streak = history[0].Streak
+	}
+
 	return &QuotaInfoResponse{
 		TotalQuota:     user.Quota,
 		UsedQuota:      user.UsedQuota,
 		RemainingQuota: remainingQuota,
 		LastSignIn:     user.LastSignIn,
+		SignInStreak:   streak,
+		OverdraftLimit: user.OverdraftAllowance(s.defaultOverdraft()),
+		Overdrawn:      overdrawn,
+
+		StreamPrecharge: user.StreamPrechargeEnabled(s.defaultStreamPrecharge()),
 	}, nil
 }
 
@@ -78,9 +108,23 @@ func (s *service) SignIn(ctx context.Context, userID uint) (*SignInResponse, err
 		return nil, errors.ErrUserNotFound
 	}
 
-	// 增加配额
-	user.Quota += DailySignInQuota
+	// 根据签到历史计算连续天数和奖励
 	now := time.Now()
+	streak, err := s.currentStreak(ctx, userID, now)
+	if err != nil {
+		s.logger.Error("Failed to get sign-in history",
+			logger.Uint("user_id", userID),
+			logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get sign-in history")
+	}
+	awarded, bonus := s.signInGrant(streak)
+
+	// 增加配额，优先抵扣透支
+	settled := int64(0)
+	if overdrawn := user.UsedQuota - user.Quota; overdrawn > 0 {
+		settled = min(overdrawn, int64(awarded))
+	}
+	user.Quota += int64(awarded)
 	user.LastSignIn = &now
 
 	if err := s.repo.UpdateUser(ctx, user); err != nil {
@@ -93,7 +137,9 @@ func (s *service) SignIn(ctx context.Context, userID uint) (*SignInResponse, err
 	// 创建签到记录
 	record := &SignInRecord{
 		UserID:       userID,
-		QuotaAwarded: DailySignInQuota,
+		QuotaAwarded: awarded,
+		Streak:       streak,
+		StreakBonus:  bonus,
 	}
 	if err := s.repo.CreateSignInRecord(ctx, record); err != nil {
 		s.logger.Error("Failed to create sign-in record",
@@ -102,9 +148,25 @@ func (s *service) SignIn(ctx context.Context, userID uint) (*SignInResponse, err
 		return nil, errors.Wrap(err, 500002, "Failed to create sign-in record")
 	}
 
+	if settled > 0 {
+		entry := &QuotaLedger{
+			UserID: userID,
+			Type:   LedgerTypeOverdraftSettled,
+			Amount: settled,
+			Reason: "Settled by daily sign-in grant",
+		}
+		if err := s.repo.CreateLedgerEntry(ctx, entry); err != nil {
+			s.logger.Warn("Failed to record overdraft settlement",
+				logger.Uint("user_id", userID),
+				logger.Int64("settled", settled),
+				logger.Error(err))
+		}
+	}
+
 	s.logger.Info("User signed in successfully",
 		logger.Uint("user_id", userID),
-		logger.Int("quota_awarded", DailySignInQuota))
+		logger.Int("quota_awarded", awarded),
+		logger.Int("streak", streak))
 
 	remainingQuota := user.Quota - user.UsedQuota
 	if remainingQuota < 0 {
@@ -112,21 +174,75 @@ func (s *service) SignIn(ctx context.Context, userID uint) (*SignInResponse, err
 	}
 
 	return &SignInResponse{
-		QuotaAwarded:   DailySignInQuota,
+		QuotaAwarded:   awarded,
+		Streak:         streak,
+		StreakBonus:    bonus,
 		TotalQuota:     user.Quota,
 		RemainingQuota: remainingQuota,
 		SignInDate:     now,
 	}, nil
 }
 
+// currentStreak 计算本次签到后的连续天数，昨天未签到则重新从 1 开始
+func (s *service) currentStreak(ctx context.Context, userID uint, now time.Time) (int, error) {
+	history, err := s.repo.GetSignInHistory(ctx, userID, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(history) == 0 || !history[0].IsDayBefore(now) {
+		return 1, nil
+	}
+	return history[0].Streak + 1, nil
+}
+
+// signInGrant 按连续天数计算签到奖励，返回总奖励和其中的连续签到加成
+func (s *service) signInGrant(streak int) (awarded, bonus int) {
+	base, bonusPercent, streakCap, maxQuota := int64(DailySignInQuota), 10, 7, int64(2000)
+	if s.runtimeConfig != nil {
+		base, bonusPercent, streakCap, maxQuota = s.runtimeConfig.Get().GetSignInPolicy()
+	}
+
+	counted := streak
+	if streakCap > 0 && counted > streakCap {
+		counted = streakCap
+	}
+	total := base
+	if counted > 1 && bonusPercent > 0 {
+		total += base * int64(bonusPercent) * int64(counted-1) / 100
+	}
+	if maxQuota > 0 && total > maxQuota {
+		total = maxQuota
+	}
+	if total < base {
+		total = base
+	}
+	return int(total), int(total - base)
+}
+
+// defaultStreamPrecharge 流式请求默认是否预扣配额，未加载运行时配置时不预扣
+func (s *service) defaultStreamPrecharge() bool {
+	if s.runtimeConfig == nil {
+		return false
+	}
+	return s.runtimeConfig.Get().IsStreamPrechargeEnabled()
+}
+
+// defaultOverdraft 系统默认透支额度，未加载运行时配置时不允许透支
+func (s *service) defaultOverdraft() int64 {
+	if s.runtimeConfig == nil {
+		return 0
+	}
+	return s.runtimeConfig.Get().GetDefaultQuotaOverdraft()
+}
+
 // DeductQuota 扣除配额（原子操作）
 func (s *service) DeductQuota(ctx context.Context, userID uint, amount int64) error {
 	if amount < 0 {
 		return errors.ErrInvalidParam.WithDetails("Amount must be non-negative")
 	}
 
-	// 扣除配额（原子操作，包含检查）
-	if err := s.repo.IncrementUsedQuota(ctx, userID, amount); err != nil {
+	// 扣除配额（原子操作，包含检查），允许在透支额度内扣成负数
+	if err := s.repo.IncrementUsedQuota(ctx, userID, amount, s.defaultOverdraft()); err != nil {
 		s.logger.Error("Failed to deduct quota",
 			logger.Uint("user_id", userID),
 			logger.Int64("amount", amount),
@@ -141,6 +257,21 @@ func (s *service) DeductQuota(ctx context.Context, userID uint, amount int64) er
 	return nil
 }
 
+// ReleaseQuota 退还预扣后未实际使用的配额（预扣本身不记账本，只有冲回的透支部分记账本）
+func (s *service) ReleaseQuota(ctx context.Context, userID uint, amount int64) error {
+	if amount <= 0 {
+		return nil
+	}
+	if err := s.repo.DecrementUsedQuota(ctx, userID, amount); err != nil {
+		s.logger.Error("Failed to release reserved quota",
+			logger.Uint("user_id", userID),
+			logger.Int64("amount", amount),
+			logger.Error(err))
+		return errors.Wrap(err, 500002, "Failed to release reserved quota")
+	}
+	return nil
+}
+
 // CheckQuota 检查配额是否充足
 func (s *service) CheckQuota(ctx context.Context, userID uint, amount int64) (*CheckQuotaResponse, error) {
 	user, err := s.repo.FindUserByID(ctx, userID)
@@ -158,7 +289,7 @@ func (s *service) CheckQuota(ctx context.Context, userID uint, amount int64) (*C
 	}
 
 	return &CheckQuotaResponse{
-		HasSufficientQuota: remainingQuota >= amount,
+		HasSufficientQuota: user.Quota-user.UsedQuota+user.OverdraftAllowance(s.defaultOverdraft()) >= amount,
 		RemainingQuota:     remainingQuota,
 		RequiredAmount:     amount,
 	}, nil
@@ -204,3 +335,149 @@ func (s *service) GetUsageHistory(ctx context.Context, userID uint, days int) (*
 		Days:    days,
 	}, nil
 }
+
+// Refund 管理员手动退款
+func (s *service) Refund(ctx context.Context, userID, operatorID uint, req *RefundRequest) (*RefundResponse, error) {
+	if req.Credits <= 0 {
+		return nil, errors.ErrInvalidParam.WithDetails("Credits must be positive")
+	}
+
+	// 关联请求日志时，退款不能超过原始扣费（仓储层在事务内会再次校验）
+	if req.RequestLogID != nil {
+		remaining, err := s.refundableAmount(ctx, userID, *req.RequestLogID)
+		if err != nil {
+			return nil, err
+		}
+		if req.Credits > remaining {
+			return nil, errors.ErrRefundExceedsCharge.WithDetails(
+				fmt.Sprintf("At most %d credits can be refunded for request log %d", remaining, *req.RequestLogID))
+		}
+	}
+
+	entry := &QuotaLedger{
+		UserID:       userID,
+		Type:         LedgerTypeRefund,
+		Amount:       req.Credits,
+		Reason:       req.Reason,
+		RequestLogID: req.RequestLogID,
+	}
+	if operatorID > 0 {
+		entry.OperatorID = &operatorID
+	}
+
+	u, err := s.repo.ApplyRefund(ctx, entry)
+	if err != nil {
+		s.logger.Error("Failed to refund quota",
+			logger.Uint("user_id", userID),
+			logger.Int64("credits", req.Credits),
+			logger.Error(err))
+		if _, ok := err.(*errors.AppError); ok {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, 500002, "Failed to refund quota")
+	}
+
+	s.logger.Info("Quota refunded",
+		logger.Uint("user_id", userID),
+		logger.Uint("operator_id", operatorID),
+		logger.Int64("credits", req.Credits))
+
+	return &RefundResponse{
+		LedgerID:       entry.ID,
+		Credits:        req.Credits,
+		UsedQuota:      u.UsedQuota,
+		RemainingQuota: u.RemainingQuota(),
+	}, nil
+}
+
+// AutoRefund 对已扣费但判定为失败的请求自动退还剩余可退配额
+func (s *service) AutoRefund(ctx context.Context, userID, requestLogID uint, reason string) (int64, error) {
+	amount, err := s.refundableAmount(ctx, userID, requestLogID)
+	if err != nil {
+		return 0, err
+	}
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	entry := &QuotaLedger{
+		UserID:       userID,
+		Type:         LedgerTypeAutoRefund,
+		Amount:       amount,
+		Reason:       reason,
+		RequestLogID: &requestLogID,
+	}
+	if _, err := s.repo.ApplyRefund(ctx, entry); err != nil {
+		s.logger.Error("Failed to auto refund quota",
+			logger.Uint("user_id", userID),
+			logger.Uint("request_log_id", requestLogID),
+			logger.Error(err))
+		return 0, err
+	}
+
+	s.logger.Info("Quota auto refunded",
+		logger.Uint("user_id", userID),
+		logger.Uint("request_log_id", requestLogID),
+		logger.Int64("amount", amount),
+		logger.String("reason", reason))
+
+	return amount, nil
+}
+
+// AutoRefundRequest 对没有请求日志（关闭了日志）的失败请求自动退款
+// 扣费只记录在配额计数中，由调用方提供扣费金额，账本按网关请求 ID 关联，同一请求累计退款不超过扣费
+func (s *service) AutoRefundRequest(ctx context.Context, userID uint, requestID string, charged int64, reason string) (int64, error) {
+	refunded, err := s.repo.SumRefundsByRequestID(ctx, userID, requestID)
+	if err != nil {
+		return 0, errors.Wrap(err, 500002, "Failed to sum refunds")
+	}
+	amount := charged - refunded
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	entry := &QuotaLedger{
+		UserID:    userID,
+		Type:      LedgerTypeAutoRefund,
+		Amount:    amount,
+		Reason:    reason,
+		RequestID: requestID,
+	}
+	if _, err := s.repo.ApplyRefund(ctx, entry); err != nil {
+		s.logger.Error("Failed to auto refund quota",
+			logger.Uint("user_id", userID),
+			logger.String("request_id", requestID),
+			logger.Error(err))
+		return 0, err
+	}
+
+	s.logger.Info("Quota auto refunded",
+		logger.Uint("user_id", userID),
+		logger.String("request_id", requestID),
+		logger.Int64("amount", amount),
+		logger.String("reason", reason))
+
+	return amount, nil
+}
+
+// refundableAmount 计算请求日志剩余可退配额
+func (s *service) refundableAmount(ctx context.Context, userID, requestLogID uint) (int64, error) {
+	charge, err := s.repo.FindRequestCharge(ctx, requestLogID)
+	if err != nil {
+		return 0, errors.Wrap(err, 500002, "Failed to find request charge")
+	}
+	if charge == nil || charge.UserID != userID {
+		return 0, errors.ErrNotFound.WithDetails("Request log not found for user")
+	}
+
+	refunded, err := s.repo.SumRefundsByRequestLog(ctx, requestLogID)
+	if err != nil {
+		return 0, errors.Wrap(err, 500002, "Failed to sum refunds")
+	}
+
+	remaining := charge.QuotaCost - refunded
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
@@ -0,0 +1,415 @@
+package quota
+
+import (
+	"api-aggregator/backend/internal/domain/user"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"testing"
+	"time"
+)
+
+// memRepository 基于内存的配额仓储，仅用于测试
+type memRepository struct {
+	users   map[uint]*user.User
+	charges map[uint]*RequestCharge
+	ledger  []*QuotaLedger
+	signIns []*SignInRecord
+	holds   map[string]*QuotaHold
+}
+
+func newMemRepository() *memRepository {
+	return &memRepository{
+		users:   map[uint]*user.User{},
+		charges: map[uint]*RequestCharge{},
+		holds:   map[string]*QuotaHold{},
+	}
+}
+
+func (r *memRepository) FindUserByID(ctx context.Context, id uint) (*user.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *memRepository) UpdateUser(ctx context.Context, u *user.User) error {
+	cp := *u
+	r.users[u.ID] = &cp
+	return nil
+}
+
+func (r *memRepository) UpdateUserQuota(ctx context.Context, userID uint, quota int64) error {
+	r.users[userID].Quota = quota
+	return nil
+}
+
+func (r *memRepository) UpdateUserUsedQuota(ctx context.Context, userID uint, usedQuota int64) error {
+	r.users[userID].UsedQuota = usedQuota
+	return nil
+}
+
+func (r *memRepository) DecrementUsedQuota(ctx context.Context, userID uint, amount int64) error {
+	u := r.users[userID]
+	remaining := u.Quota - u.UsedQuota
+	u.UsedQuota -= amount
+	if released := overdraftReleased(remaining, amount); released > 0 {
+		r.CreateLedgerEntry(ctx, &QuotaLedger{UserID: userID, Type: LedgerTypeOverdraftReleased, Amount: released})
+	}
+	return nil
+}
+
+func (r *memRepository) IncrementUsedQuota(ctx context.Context, userID uint, amount, defaultOverdraft int64) error {
+	u, ok := r.users[userID]
+	if !ok {
+		return errors.ErrUserNotFound
+	}
+	remaining := u.Quota - u.UsedQuota
+	if remaining+u.OverdraftAllowance(defaultOverdraft) < amount {
+		return errors.ErrQuotaExceeded
+	}
+	u.UsedQuota += amount
+	if overdrawn := overdraftPortion(remaining, amount); overdrawn > 0 {
+		r.CreateLedgerEntry(ctx, &QuotaLedger{UserID: userID, Type: LedgerTypeOverdraft, Amount: -overdrawn})
+	}
+	return nil
+}
+
+func (r *memRepository) CreateLedgerEntry(ctx context.Context, entry *QuotaLedger) error {
+	entry.ID = uint(len(r.ledger) + 1)
+	r.ledger = append(r.ledger, entry)
+	return nil
+}
+
+func (r *memRepository) CreateSignInRecord(ctx context.Context, record *SignInRecord) error {
+	record.CreatedAt = time.Now()
+	r.signIns = append(r.signIns, record)
+	return nil
+}
+
+func (r *memRepository) FindTodaySignIn(ctx context.Context, userID uint) (*SignInRecord, error) {
+	return nil, nil
+}
+
+func (r *memRepository) HasSignedInToday(ctx context.Context, userID uint) (bool, error) {
+	return false, nil
+}
+
+func (r *memRepository) GetSignInHistory(ctx context.Context, userID uint, limit int) ([]*SignInRecord, error) {
+	var records []*SignInRecord
+	for i := len(r.signIns) - 1; i >= 0 && len(records) < limit; i-- {
+		if r.signIns[i].UserID == userID {
+			records = append(records, r.signIns[i])
+		}
+	}
+	return records, nil
+}
+
+func (r *memRepository) GetDailyUsage(ctx context.Context, userID uint, startDate, endDate time.Time) (map[string]int, error) {
+	return map[string]int{}, nil
+}
+
+func (r *memRepository) FindRequestCharge(ctx context.Context, requestLogID uint) (*RequestCharge, error) {
+	return r.charges[requestLogID], nil
+}
+
+func (r *memRepository) SumRefundsByRequestLog(ctx context.Context, requestLogID uint) (int64, error) {
+	var total int64
+	for _, e := range r.ledger {
+		if e.RequestLogID != nil && *e.RequestLogID == requestLogID {
+			total += e.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *memRepository) SumRefundsByRequestID(ctx context.Context, userID uint, requestID string) (int64, error) {
+	var total int64
+	for _, e := range r.ledger {
+		if e.UserID == userID && e.RequestID == requestID {
+			total += e.Amount
+		}
+	}
+	return total, nil
+}
+
+func (r *memRepository) ApplyRefund(ctx context.Context, entry *QuotaLedger) (*user.User, error) {
+	u, ok := r.users[entry.UserID]
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	fromUsed := entry.Amount
+	if fromUsed > u.UsedQuota {
+		fromUsed = u.UsedQuota
+	}
+	u.UsedQuota -= fromUsed
+	u.Quota += entry.Amount - fromUsed
+
+	entry.ID = uint(len(r.ledger) + 1)
+	r.ledger = append(r.ledger, entry)
+	cp := *u
+	return &cp, nil
+}
+
+func newTestService(repo *memRepository) Service {
+	return NewService(repo, nil, *logger.NewNop())
+}
+
+func TestRefund_CreditsQuotaAndRecordsLedger(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000, UsedQuota: 400}
+	svc := newTestService(repo)
+
+	resp, err := svc.Refund(context.Background(), 1, 99, &RefundRequest{Credits: 150, Reason: "bad response"})
+	if err != nil {
+		t.Fatalf("Refund failed: %v", err)
+	}
+	if resp.UsedQuota != 250 || resp.RemainingQuota != 750 {
+		t.Errorf("Expected used 250 / remaining 750, got %d / %d", resp.UsedQuota, resp.RemainingQuota)
+	}
+	if len(repo.ledger) != 1 {
+		t.Fatalf("Expected 1 ledger entry, got %d", len(repo.ledger))
+	}
+	entry := repo.ledger[0]
+	if entry.Type != LedgerTypeRefund || entry.Amount != 150 || entry.OperatorID == nil || *entry.OperatorID != 99 {
+		t.Errorf("Unexpected ledger entry: %+v", entry)
+	}
+}
+
+func TestRefund_CannotExceedOriginalCharge(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000, UsedQuota: 400}
+	repo.charges[7] = &RequestCharge{UserID: 1, QuotaCost: 100}
+	svc := newTestService(repo)
+	logID := uint(7)
+
+	if _, err := svc.Refund(context.Background(), 1, 99, &RefundRequest{Credits: 60, Reason: "partial", RequestLogID: &logID}); err != nil {
+		t.Fatalf("First refund failed: %v", err)
+	}
+
+	_, err := svc.Refund(context.Background(), 1, 99, &RefundRequest{Credits: 50, Reason: "again", RequestLogID: &logID})
+	if !errors.Is(err, errors.ErrRefundExceedsCharge) {
+		t.Fatalf("Expected ErrRefundExceedsCharge, got %v", err)
+	}
+
+	// 其他用户的日志不能被引用
+	_, err = svc.Refund(context.Background(), 2, 99, &RefundRequest{Credits: 10, Reason: "wrong user", RequestLogID: &logID})
+	if !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for foreign log, got %v", err)
+	}
+}
+
+func TestAutoRefund_RefundsRemainingChargeOnce(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000, UsedQuota: 300}
+	repo.charges[3] = &RequestCharge{UserID: 1, QuotaCost: 120}
+	svc := newTestService(repo)
+
+	amount, err := svc.AutoRefund(context.Background(), 1, 3, "no content")
+	if err != nil {
+		t.Fatalf("AutoRefund failed: %v", err)
+	}
+	if amount != 120 {
+		t.Errorf("Expected refund of 120, got %d", amount)
+	}
+	if repo.users[1].UsedQuota != 180 {
+		t.Errorf("Expected used quota 180, got %d", repo.users[1].UsedQuota)
+	}
+
+	amount, err = svc.AutoRefund(context.Background(), 1, 3, "no content")
+	if err != nil || amount != 0 {
+		t.Errorf("Expected second auto refund to be a no-op, got %d, %v", amount, err)
+	}
+	if repo.ledger[0].Type != LedgerTypeAutoRefund {
+		t.Errorf("Expected auto_refund ledger type, got %s", repo.ledger[0].Type)
+	}
+}
+
+func TestAutoRefundRequest_RefundsUnloggedRequestOnce(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 1000, UsedQuota: 300}
+	svc := newTestService(repo)
+
+	amount, err := svc.AutoRefundRequest(context.Background(), 1, "req-1", 120, "no content")
+	if err != nil {
+		t.Fatalf("AutoRefundRequest failed: %v", err)
+	}
+	if amount != 120 || repo.users[1].UsedQuota != 180 {
+		t.Errorf("Expected refund of 120 leaving used quota 180, got %d / %d", amount, repo.users[1].UsedQuota)
+	}
+	if repo.ledger[0].RequestID != "req-1" || repo.ledger[0].RequestLogID != nil {
+		t.Errorf("Expected ledger keyed on the request ID, got %+v", repo.ledger[0])
+	}
+
+	amount, err = svc.AutoRefundRequest(context.Background(), 1, "req-1", 120, "no content")
+	if err != nil || amount != 0 {
+		t.Errorf("Expected second auto refund to be a no-op, got %d, %v", amount, err)
+	}
+}
+
+// passDays 将已有签到记录整体提前 n 天，模拟时间流逝
+func (r *memRepository) passDays(n int) {
+	for _, record := range r.signIns {
+		record.CreatedAt = record.CreatedAt.AddDate(0, 0, -n)
+	}
+}
+
+func TestSignIn_StreakBonusUpToCap(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1}
+	rc := runtime.NewManager(nil)
+	cfg := rc.Get()
+	cfg.SignInBaseQuota, cfg.SignInStreakBonus, cfg.SignInStreakCap, cfg.SignInMaxQuota = 100, 25, 3, 1000
+	svc := NewService(repo, rc, *logger.NewNop())
+
+	// 连续签到奖励递增，超过计入上限的天数后不再增加
+	for day, want := range []int{100, 125, 150, 150} {
+		resp, err := svc.SignIn(context.Background(), 1)
+		if err != nil {
+			t.Fatalf("SignIn failed: %v", err)
+		}
+		if resp.QuotaAwarded != want || resp.Streak != day+1 || resp.StreakBonus != want-100 {
+			t.Errorf("Day %d: expected %d with streak %d, got %+v", day+1, want, day+1, resp)
+		}
+		repo.passDays(1)
+	}
+	if repo.users[1].Quota != 525 {
+		t.Errorf("Expected total quota 525, got %d", repo.users[1].Quota)
+	}
+	if r := repo.signIns[3]; r.Streak != 4 || r.StreakBonus != 50 {
+		t.Errorf("Expected streak recorded on sign-in record, got %+v", r)
+	}
+
+	info, _ := svc.GetQuotaInfo(context.Background(), 1)
+	if info.SignInStreak != 4 {
+		t.Errorf("Expected current streak 4, got %d", info.SignInStreak)
+	}
+
+	// 单日奖励上限
+	cfg.SignInMaxQuota = 110
+	resp, _ := svc.SignIn(context.Background(), 1)
+	if resp.QuotaAwarded != 110 {
+		t.Errorf("Expected grant capped at 110, got %d", resp.QuotaAwarded)
+	}
+}
+
+func TestSignIn_MissedDayResetsStreak(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1}
+	svc := newTestService(repo)
+
+	svc.SignIn(context.Background(), 1)
+	repo.passDays(1)
+	resp, _ := svc.SignIn(context.Background(), 1)
+	if resp.Streak != 2 || resp.QuotaAwarded != 1100 {
+		t.Fatalf("Expected default policy streak 2 with 1100, got %+v", resp)
+	}
+
+	repo.passDays(2)
+	if info, _ := svc.GetQuotaInfo(context.Background(), 1); info.SignInStreak != 0 {
+		t.Errorf("Expected broken streak reported as 0, got %d", info.SignInStreak)
+	}
+	resp, _ = svc.SignIn(context.Background(), 1)
+	if resp.Streak != 1 || resp.QuotaAwarded != DailySignInQuota || resp.StreakBonus != 0 {
+		t.Errorf("Expected streak reset after a missed day, got %+v", resp)
+	}
+}
+
+func newOverdraftTestService(repo *memRepository, overdraft int64) Service {
+	rc := runtime.NewManager(nil)
+	rc.Get().DefaultQuotaOverdraft = overdraft
+	rc.Get().SignInBaseQuota = DailySignInQuota
+	return NewService(repo, rc, *logger.NewNop())
+}
+
+func TestDeductQuota_AllowsOverdraftUntilExhausted(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 100, UsedQuota: 95}
+	svc := newOverdraftTestService(repo, 50)
+	ctx := context.Background()
+
+	// 只剩 5 点，一次 30 点的请求在透支额度内完成
+	if err := svc.DeductQuota(ctx, 1, 30); err != nil {
+		t.Fatalf("Expected request within overdraft to succeed, got %v", err)
+	}
+	if len(repo.ledger) != 1 || repo.ledger[0].Type != LedgerTypeOverdraft || repo.ledger[0].Amount != -25 {
+		t.Fatalf("Expected overdraft of 25 flagged in ledger, got %+v", repo.ledger)
+	}
+
+	info, err := svc.GetQuotaInfo(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetQuotaInfo failed: %v", err)
+	}
+	if info.RemainingQuota != 0 || info.Overdrawn != 25 || info.OverdraftLimit != 50 || info.OverdraftAvailable() != 25 {
+		t.Errorf("Unexpected quota info %+v", info)
+	}
+
+	// 超出透支额度后被拒绝
+	if err := svc.DeductQuota(ctx, 1, 30); !errors.Is(err, errors.ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded beyond overdraft, got %v", err)
+	}
+	if repo.users[1].UsedQuota != 125 {
+		t.Errorf("Expected used quota 125 after rejected charge, got %d", repo.users[1].UsedQuota)
+	}
+}
+
+func TestDeductQuota_UserOverdraftOverridesDefault(t *testing.T) {
+	repo := newMemRepository()
+	none := int64(0)
+	repo.users[1] = &user.User{ID: 1, Quota: 100, UsedQuota: 95, OverdraftLimit: &none}
+	svc := newOverdraftTestService(repo, 50)
+
+	if err := svc.DeductQuota(context.Background(), 1, 30); !errors.Is(err, errors.ErrQuotaExceeded) {
+		t.Errorf("Expected per-user limit of 0 to disable overdraft, got %v", err)
+	}
+}
+
+func TestReleaseQuota_ReversesOverdraftInLedger(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 100, UsedQuota: 95}
+	svc := newOverdraftTestService(repo, 50)
+	ctx := context.Background()
+
+	// 预扣 40 点透支了 35 点，实际只用了 10 点，退还的 30 点全部冲回透支
+	if err := svc.DeductQuota(ctx, 1, 40); err != nil {
+		t.Fatalf("DeductQuota failed: %v", err)
+	}
+	if err := svc.ReleaseQuota(ctx, 1, 30); err != nil {
+		t.Fatalf("ReleaseQuota failed: %v", err)
+	}
+	if len(repo.ledger) != 2 || repo.ledger[1].Type != LedgerTypeOverdraftReleased || repo.ledger[1].Amount != 30 {
+		t.Fatalf("Expected the released 30 recorded against the overdraft, got %+v", repo.ledger)
+	}
+
+	// 退还超过剩余透支时只冲回透支部分，账本与用户实际透支一致
+	if err := svc.ReleaseQuota(ctx, 1, 10); err != nil {
+		t.Fatalf("ReleaseQuota failed: %v", err)
+	}
+	var net int64
+	for _, entry := range repo.ledger {
+		net += entry.Amount
+	}
+	if len(repo.ledger) != 3 || repo.ledger[2].Amount != 5 || net != 0 || repo.users[1].UsedQuota != 95 {
+		t.Errorf("Expected the overdraft fully reversed, got ledger %+v and used quota %d", repo.ledger, repo.users[1].UsedQuota)
+	}
+}
+
+func TestSignIn_SettlesOverdraft(t *testing.T) {
+	repo := newMemRepository()
+	repo.users[1] = &user.User{ID: 1, Quota: 100, UsedQuota: 130}
+	svc := newOverdraftTestService(repo, 50)
+
+	resp, err := svc.SignIn(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("SignIn failed: %v", err)
+	}
+	if resp.RemainingQuota != int64(resp.QuotaAwarded)-30 {
+		t.Errorf("Expected grant reduced by the 30 overdrawn, remaining %d of %d", resp.RemainingQuota, resp.QuotaAwarded)
+	}
+	if len(repo.ledger) != 1 || repo.ledger[0].Type != LedgerTypeOverdraftSettled || repo.ledger[0].Amount != 30 {
+		t.Errorf("Expected settlement of 30 in ledger, got %+v", repo.ledger)
+	}
+}
@@ -1,6 +1,9 @@
 package settings
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // RuntimeConfigResponse 运行时配置响应
 type RuntimeConfigResponse struct {
@@ -106,3 +109,18 @@ func ToSettingResponse(setting *Setting) *SettingResponse {
 		IsSystem:    setting.IsSystem,
 	}
 }
+
+// UpdateSettingRequest 更新单个设置项请求，value 可以是字符串或 JSON 字面量（true、3600）
+type UpdateSettingRequest struct {
+	Value json.RawMessage `json:"value" binding:"required"`
+	Force bool            `json:"force"` // 修改系统设置项时必须为 true
+}
+
+// SettingValue 返回要保存的字符串值，JSON 字符串取其内容，其余字面量保持原样
+func (r *UpdateSettingRequest) SettingValue() string {
+	var str string
+	if json.Unmarshal(r.Value, &str) == nil {
+		return str
+	}
+	return string(r.Value)
+}
@@ -1,6 +1,7 @@
 package settings
 
 import (
+	"api-aggregator/backend/pkg/errors"
 	"api-aggregator/backend/pkg/response"
 
 	"github.com/gin-gonic/gin"
@@ -221,4 +222,57 @@ func (h *Handler) UpdateRegistrationConfig(c *gin.Context) {
 	}
 
 	response.Success(c, config)
-}
\ No newline at end of file
+}
+// ListSettings 获取所有设置项
+// @Summary 获取所有设置项
+// @Tags Settings
+// @Produce json
+// @Success 200 {array} SettingResponse
+// @Router /api/v1/admin/settings [get]
+func (h *Handler) ListSettings(c *gin.Context) {
+	settings, err := h.service.ListSettings(c.Request.Context())
+	if err != nil {
+		response.HandleError(c, err)
+		return
+	}
+
+	response.Success(c, settings)
+}
+
+// UpdateSetting 更新单个设置项
+// @Summary 更新设置项
+// @Description 按设置项声明的类型和已知键的取值范围校验，系统设置项需要 force，更新后立即生效
+// @Tags Settings
+// @Accept json
+// @Produce json
+// @Param key path string true "设置键"
+// @Param request body UpdateSettingRequest true "更新请求"
+// @Success 200 {object} SettingResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/v1/admin/settings/{key} [put]
+func (h *Handler) UpdateSetting(c *gin.Context) {
+	var req UpdateSettingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	setting, err := h.service.UpdateSetting(c.Request.Context(), c.Param("key"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrNotFound):
+			response.NotFound(c, "Setting not found")
+		case errors.Is(err, errors.ErrForbidden):
+			response.Forbidden(c, err.(*errors.AppError).Details)
+		case errors.Is(err, errors.ErrInvalidParam):
+			response.BadRequest(c, "Invalid setting value", err.(*errors.AppError).Details)
+		default:
+			response.HandleError(c, err)
+		}
+		return
+	}
+
+	response.Success(c, setting)
+}
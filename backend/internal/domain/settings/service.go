@@ -4,8 +4,10 @@ import (
 	"api-aggregator/backend/internal/domain/user"
 	"api-aggregator/backend/pkg/crypto"
 	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/runtime"
 	"api-aggregator/backend/pkg/utils"
 	"context"
+	"sort"
 	"strconv"
 )
 
@@ -22,18 +24,23 @@ type Service interface {
 	// 注册配置
 	GetRegistrationConfig(ctx context.Context) (*RegistrationConfigResponse, error)
 	UpdateRegistrationConfig(ctx context.Context, req *UpdateRegistrationConfigRequest) (*RegistrationConfigResponse, error)
+	// 通用设置项
+	ListSettings(ctx context.Context) ([]*SettingResponse, error)
+	UpdateSetting(ctx context.Context, key string, req *UpdateSettingRequest) (*SettingResponse, error)
 }
 
 type service struct {
-	repo     Repository
-	userRepo user.Repository
+	repo          Repository
+	userRepo      user.Repository
+	runtimeConfig *runtime.Manager
 }
 
-// NewService 创建设置服务实例
-func NewService(repo Repository, userRepo user.Repository) Service {
+// NewService 创建设置服务实例，runtimeConfig 用于设置更新后立即热加载（可为 nil）
+func NewService(repo Repository, userRepo user.Repository, runtimeConfig *runtime.Manager) Service {
 	return &service{
-		repo:     repo,
-		userRepo: userRepo,
+		repo:          repo,
+		userRepo:      userRepo,
+		runtimeConfig: runtimeConfig,
 	}
 }
 
@@ -103,6 +110,7 @@ func (s *service) UpdateRuntimeConfig(ctx context.Context, req *UpdateRuntimeCon
 		if err := s.repo.SetMultiple(ctx, updates); err != nil {
 			return nil, errors.Wrap(err, "failed to update runtime config")
 		}
+		s.reloadRuntime(ctx)
 	}
 
 	return s.GetRuntimeConfig(ctx)
@@ -211,6 +219,7 @@ func (s *service) UpdateDefaultQuota(ctx context.Context, req *UpdateDefaultQuot
 		if err := s.repo.SetMultiple(ctx, updates); err != nil {
 			return nil, errors.Wrap(err, "failed to update default quota")
 		}
+		s.reloadRuntime(ctx)
 	}
 
 	return s.GetDefaultQuota(ctx)
@@ -251,6 +260,7 @@ func (s *service) UpdateDefaultRateLimit(ctx context.Context, req *UpdateDefault
 		if err := s.repo.SetMultiple(ctx, updates); err != nil {
 			return nil, errors.Wrap(err, "failed to update default rate limit")
 		}
+		s.reloadRuntime(ctx)
 	}
 return s.GetDefaultRateLimit(ctx)
 }
@@ -290,11 +300,68 @@ func (s *service) UpdateRegistrationConfig(ctx context.Context, req *UpdateRegis
 		if err := s.repo.SetMultiple(ctx, updates); err != nil {
 			return nil, errors.Wrap(err, "failed to update registration config")
 		}
+		s.reloadRuntime(ctx)
 	}
 
 	return s.GetRegistrationConfig(ctx)
 }
 
+// ListSettings 列出所有设置项
+func (s *service) ListSettings(ctx context.Context) ([]*SettingResponse, error) {
+	settings, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list settings")
+	}
+
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	result := make([]*SettingResponse, 0, len(settings))
+	for _, setting := range settings {
+		result = append(result, ToSettingResponse(setting))
+	}
+	return result, nil
+}
+
+// UpdateSetting 按声明的类型和已知键的取值范围校验后更新设置项，并立即热加载运行时配置
+// 系统设置项需要显式传 force 才能修改
+func (s *service) UpdateSetting(ctx context.Context, key string, req *UpdateSettingRequest) (*SettingResponse, error) {
+	setting, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return nil, errors.ErrNotFound.WithDetails("setting " + key + " not found")
+	}
+	if setting.IsSystem && !req.Force {
+		return nil, errors.ErrForbidden.WithDetails("setting " + key + " is a system setting, pass force to modify it")
+	}
+
+	value := req.SettingValue()
+	if err := validateSetting(key, setting.Type, value); err != nil {
+		return nil, errors.ErrInvalidParam.WithDetails(err.Error())
+	}
+
+	if err := s.repo.Set(ctx, key, value, setting.Type); err != nil {
+		return nil, errors.Wrap(err, "failed to update setting")
+	}
+	s.reloadRuntime(ctx)
+
+	setting.Value = value
+	return ToSettingResponse(setting), nil
+}
+
+// reloadRuntime 设置更新后立即热加载运行时配置，失败时由自动重载兜底
+func (s *service) reloadRuntime(ctx context.Context) {
+	if s.runtimeConfig == nil {
+		return
+	}
+	settings, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return
+	}
+	values := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		values[setting.Key] = setting.Value
+	}
+	s.runtimeConfig.LoadSettings(values)
+}
+
 // 辅助方法
 
 func (s *service) getString(settings map[string]*Setting, key, defaultValue string) string {
@@ -0,0 +1,148 @@
+package settings
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeRepo 内存中的设置表
+type fakeRepo struct {
+	Repository
+	settings map[string]*Setting
+}
+
+func newFakeRepo(settings ...*Setting) *fakeRepo {
+	r := &fakeRepo{settings: make(map[string]*Setting)}
+	for _, setting := range settings {
+		r.settings[setting.Key] = setting
+	}
+	return r
+}
+
+func (r *fakeRepo) Get(ctx context.Context, key string) (*Setting, error) {
+	if setting, ok := r.settings[key]; ok {
+		copied := *setting
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("setting %s not found", key)
+}
+
+func (r *fakeRepo) Set(ctx context.Context, key, value, settingType string) error {
+	r.settings[key].Value = value
+	return nil
+}
+
+func (r *fakeRepo) FindAll(ctx context.Context) ([]*Setting, error) {
+	all := make([]*Setting, 0, len(r.settings))
+	for _, setting := range r.settings {
+		all = append(all, setting)
+	}
+	return all, nil
+}
+
+func newTestService() (Service, *fakeRepo, *runtime.Manager) {
+	repo := newFakeRepo(
+		&Setting{Key: KeyRuntimeCacheTTL, Value: "3600", Type: TypeInt, IsSystem: true},
+		&Setting{Key: KeyRuntimeCacheEnabled, Value: "true", Type: TypeBool},
+		&Setting{Key: KeyRuntimeSemanticThreshold, Value: "0.85", Type: TypeFloat},
+	)
+	rc := runtime.NewManager(nil)
+	return NewService(repo, nil, rc), repo, rc
+}
+
+func updateRequest(value string, force bool) *UpdateSettingRequest {
+	return &UpdateSettingRequest{Value: json.RawMessage(value), Force: force}
+}
+
+func TestUpdateSetting_ValidatesDeclaredType(t *testing.T) {
+	svc, repo, _ := newTestService()
+
+	_, err := svc.UpdateSetting(context.Background(), KeyRuntimeCacheEnabled, updateRequest(`"sometimes"`, false))
+	if !errors.Is(err, errors.ErrInvalidParam) {
+		t.Fatalf("Expected a non-bool value rejected, got %v", err)
+	}
+	_, err = svc.UpdateSetting(context.Background(), KeyRuntimeCacheTTL, updateRequest(`"1h"`, true))
+	if !errors.Is(err, errors.ErrInvalidParam) {
+		t.Fatalf("Expected a non-integer value rejected, got %v", err)
+	}
+	if repo.settings[KeyRuntimeCacheEnabled].Value != "true" || repo.settings[KeyRuntimeCacheTTL].Value != "3600" {
+		t.Error("Expected rejected values not to be saved")
+	}
+
+	// JSON 字面量和字符串形式都接受
+	if _, err := svc.UpdateSetting(context.Background(), KeyRuntimeCacheEnabled, updateRequest(`false`, false)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if repo.settings[KeyRuntimeCacheEnabled].Value != "false" {
+		t.Errorf("Expected false saved, got %s", repo.settings[KeyRuntimeCacheEnabled].Value)
+	}
+}
+
+func TestUpdateSetting_EnforcesRanges(t *testing.T) {
+	svc, _, _ := newTestService()
+
+	cases := []struct {
+		key, value string
+		ok         bool
+	}{
+		{KeyRuntimeCacheTTL, `0`, false},
+		{KeyRuntimeCacheTTL, `1`, true},
+		{KeyRuntimeSemanticThreshold, `1.5`, false},
+		{KeyRuntimeSemanticThreshold, `-0.1`, false},
+		{KeyRuntimeSemanticThreshold, `1`, true},
+	}
+	for _, tc := range cases {
+		_, err := svc.UpdateSetting(context.Background(), tc.key, updateRequest(tc.value, true))
+		if (err == nil) != tc.ok {
+			t.Errorf("%s=%s: expected ok=%v, got %v", tc.key, tc.value, tc.ok, err)
+		}
+	}
+}
+
+func TestValidateSetting_KeyRateLimitZeroMeansUnlimited(t *testing.T) {
+	for _, key := range []string{KeyDefaultRateLimitPerHour, KeyDefaultRateLimitPerDay} {
+		if err := validateSetting(key, TypeInt, "0"); err != nil {
+			t.Errorf("Expected %s=0 (unlimited) accepted, got %v", key, err)
+		}
+		if err := validateSetting(key, TypeInt, "-1"); err == nil {
+			t.Errorf("Expected a negative %s rejected", key)
+		}
+	}
+	if err := validateSetting(KeyDefaultRateLimitPerMinute, TypeInt, "0"); err == nil {
+		t.Error("Expected the per-minute default to stay at least 1")
+	}
+}
+
+func TestUpdateSetting_SystemSettingRequiresForce(t *testing.T) {
+	svc, _, _ := newTestService()
+
+	_, err := svc.UpdateSetting(context.Background(), KeyRuntimeCacheTTL, updateRequest(`60`, false))
+	if !errors.Is(err, errors.ErrForbidden) {
+		t.Fatalf("Expected a system setting to require force, got %v", err)
+	}
+	if _, err := svc.UpdateSetting(context.Background(), "runtime.unknown", updateRequest(`1`, true)); !errors.Is(err, errors.ErrNotFound) {
+		t.Errorf("Expected an unknown key to be not found, got %v", err)
+	}
+}
+
+func TestUpdateSetting_ReloadsRuntimeConfig(t *testing.T) {
+	svc, _, rc := newTestService()
+
+	if _, err := svc.UpdateSetting(context.Background(), KeyRuntimeCacheTTL, updateRequest(`120`, true)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := rc.Get().GetCacheTTL(); got != 2*time.Minute {
+		t.Errorf("Expected the runtime cache TTL reloaded to 2m, got %v", got)
+	}
+	if _, err := svc.UpdateSetting(context.Background(), KeyRuntimeCacheEnabled, updateRequest(`"false"`, false)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rc.Get().IsCacheEnabled() {
+		t.Error("Expected the cache disabled in the runtime config")
+	}
+}
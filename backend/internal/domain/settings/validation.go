@@ -0,0 +1,92 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// valueRange 已知数值设置项的取值范围，Max 为 0 表示没有上限
+type valueRange struct {
+	Min float64
+	Max float64
+}
+
+// settingRanges 已知数值设置项的取值范围，未列出的键只校验类型
+var settingRanges = map[string]valueRange{
+	KeyRuntimeCacheTTL:                     {Min: 1},
+	KeyRuntimeSemanticThreshold:            {Min: 0, Max: 1},
+	KeyRuntimeEmbeddingTimeout:             {Min: 1, Max: 600},
+	KeyRuntimeMaxRetries:                   {Min: 0, Max: 10},
+	KeyRuntimeTimeout:                      {Min: 1, Max: 600},
+	"runtime.cache_stale_while_revalidate": {Min: 0},
+	"runtime.stream_quota_check_tokens":    {Min: 1},
+	"runtime.stream_max_duration":          {Min: 0},
+	"runtime.stream_precharge_ratio":       {Min: 0, Max: 10},
+	"runtime.request_max_messages":         {Min: 0},
+	"runtime.prefix_cache_min_tokens":      {Min: 0},
+	"runtime.prefix_cache_ttl":             {Min: 1},
+	"runtime.pool_near_limit_percent":      {Min: 0, Max: 100},
+	"runtime.pool_min_healthy_credentials": {Min: 0},
+	"runtime.pool_credential_grace_period": {Min: 0},
+	"runtime.payload_alert_bytes":          {Min: 0},
+	"runtime.expired_key_retention_days":   {Min: 0},
+	"runtime.dynamic_timeout_multiplier":   {Min: 1, Max: 20},
+	"runtime.dynamic_timeout_min":          {Min: 1, Max: 3600},
+	"runtime.dynamic_timeout_max":          {Min: 1, Max: 3600},
+	"cache_warming.lead_minutes":           {Min: 1},
+	"cache_warming.min_hits":               {Min: 1},
+	"cache_warming.daily_budget":           {Min: 0},
+	"cache_warming.off_peak_start_hour":    {Min: 0, Max: 23},
+	"cache_warming.off_peak_end_hour":      {Min: 0, Max: 23},
+	"cache_warming.billing_user_id":        {Min: 0},
+	KeyDefaultQuotaDaily:                   {Min: 0},
+	KeyDefaultQuotaMonthly:                 {Min: 0},
+	KeyDefaultQuotaTotal:                   {Min: 0},
+	KeyDefaultRateLimitPerMinute:           {Min: 1},
+	KeyDefaultRateLimitPerHour:             {Min: 0},
+	KeyDefaultRateLimitPerDay:              {Min: 0},
+}
+
+// validateSetting 校验值是否符合设置项声明的类型，已知数值键还需在取值范围内
+func validateSetting(key, settingType, value string) error {
+	var number float64
+	switch settingType {
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be a bool, got %q", key, value)
+		}
+		return nil
+	case TypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, got %q", key, value)
+		}
+		number = float64(n)
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, got %q", key, value)
+		}
+		number = f
+	case TypeJSON:
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("%s must be valid JSON", key)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	r, ok := settingRanges[key]
+	if !ok {
+		return nil
+	}
+	if number < r.Min {
+		return fmt.Errorf("%s must be at least %v, got %v", key, r.Min, number)
+	}
+	if r.Max > 0 && number > r.Max {
+		return fmt.Errorf("%s must be at most %v, got %v", key, r.Max, number)
+	}
+	return nil
+}
@@ -54,3 +54,16 @@ type GetTokenUsageResponse struct {
 	Days  int              `json:"days"`
 	Total int64            `json:"total"`
 }
+
+// GetPayloadSizesRequest 获取请求体积统计请求
+type GetPayloadSizesRequest struct {
+	Days int `form:"days" binding:"omitempty,min=1,max=90"`
+}
+
+// GetPayloadSizesResponse 请求体积统计响应
+type GetPayloadSizesResponse struct {
+	Sizes              []PayloadSizeItem `json:"sizes"`
+	Days               int               `json:"days"`
+	TotalRequestBytes  int64             `json:"total_request_bytes"`
+	TotalResponseBytes int64             `json:"total_response_bytes"`
+}
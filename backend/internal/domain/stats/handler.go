@@ -154,3 +154,32 @@ func (h *Handler) GetTokenUsage(c *gin.Context) {
 
 	response.Success(c, usage)
 }
+
+// GetPayloadSizes 获取请求/响应体积统计
+// @Summary 获取请求体积统计
+// @Description 获取每日上游请求/响应体积统计（管理员）
+// @Tags Stats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param days query int false "天数" default(7)
+// @Success 200 {object} GetPayloadSizesResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/stats/payload-sizes [get]
+func (h *Handler) GetPayloadSizes(c *gin.Context) {
+	var req GetPayloadSizesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	sizes, err := h.service.GetPayloadSizes(c.Request.Context(), &req)
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, sizes)
+}
@@ -36,3 +36,15 @@ type TokenUsageItem struct {
 	Date   string `json:"date"`
 	Tokens int64  `json:"tokens"`
 }
+
+// PayloadSizeItem 请求/响应体积统计项
+type PayloadSizeItem struct {
+	Date             string  `json:"date"`
+	Requests         int64   `json:"requests"`
+	RequestBytes     int64   `json:"request_bytes"`
+	ResponseBytes    int64   `json:"response_bytes"`
+	AvgRequestBytes  float64 `json:"avg_request_bytes"`
+	AvgResponseBytes float64 `json:"avg_response_bytes"`
+	MaxRequestBytes  int64   `json:"max_request_bytes"`
+	MaxResponseBytes int64   `json:"max_response_bytes"`
+}
@@ -26,6 +26,9 @@ type Repository interface {
 	
 	// Token统计
 	GetTokenUsage(ctx context.Context, startDate, endDate time.Time) ([]TokenUsageItem, error)
+
+	// 请求体积统计
+	GetPayloadSizes(ctx context.Context, startDate, endDate time.Time) ([]PayloadSizeItem, error)
 }
 
 // repository 统计仓储实现
@@ -141,3 +144,23 @@ func (r *repository) GetTokenUsage(ctx context.Context, startDate, endDate time.
 		Scan(&results).Error
 	return results, err
 }
+
+// GetPayloadSizes 获取每日请求/响应体积统计
+func (r *repository) GetPayloadSizes(ctx context.Context, startDate, endDate time.Time) ([]PayloadSizeItem, error) {
+	var results []PayloadSizeItem
+	err := r.db.WithContext(ctx).
+		Table("request_logs").
+		Select(`TO_CHAR(DATE(created_at), 'YYYY-MM-DD') as date,
+			COUNT(*) as requests,
+			COALESCE(SUM(request_bytes), 0) as request_bytes,
+			COALESCE(SUM(response_bytes), 0) as response_bytes,
+			COALESCE(AVG(request_bytes), 0) as avg_request_bytes,
+			COALESCE(AVG(response_bytes), 0) as avg_response_bytes,
+			COALESCE(MAX(request_bytes), 0) as max_request_bytes,
+			COALESCE(MAX(response_bytes), 0) as max_response_bytes`).
+		Where("created_at >= ? AND created_at < ?", startDate, endDate).
+		Group("DATE(created_at)").
+		Order("DATE(created_at) ASC").
+		Scan(&results).Error
+	return results, err
+}
@@ -14,6 +14,7 @@ type Service interface {
 	GetModelUsage(ctx context.Context, req *GetModelUsageRequest) (*GetModelUsageResponse, error)
 	GetUserGrowth(ctx context.Context, req *GetUserGrowthRequest) (*GetUserGrowthResponse, error)
 	GetTokenUsage(ctx context.Context, req *GetTokenUsageRequest) (*GetTokenUsageResponse, error)
+	GetPayloadSizes(ctx context.Context, req *GetPayloadSizesRequest) (*GetPayloadSizesResponse, error)
 }
 
 // service 统计服务实现
@@ -233,3 +234,41 @@ func (s *service) GetTokenUsage(ctx context.Context, req *GetTokenUsageRequest)
 		Total: total,
 	}, nil
 }
+
+// GetPayloadSizes 获取请求/响应体积统计
+func (s *service) GetPayloadSizes(ctx context.Context, req *GetPayloadSizesRequest) (*GetPayloadSizesResponse, error) {
+	// 设置默认值
+	days := req.Days
+	if days == 0 {
+		days = 7
+	}
+
+	// 计算日期范围
+	endDate := time.Now()
+	startDate := endDate.AddDate(0, 0, -days+1).Truncate(24 * time.Hour)
+
+	sizeData, err := s.repo.GetPayloadSizes(ctx, startDate, endDate)
+	if err != nil {
+		s.logger.Error("Failed to get payload sizes", logger.Int("days", days), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get payload sizes")
+	}
+
+	sizeMap := make(map[string]PayloadSizeItem)
+	resp := &GetPayloadSizesResponse{Days: days}
+	for _, item := range sizeData {
+		sizeMap[item.Date] = item
+		resp.TotalRequestBytes += item.RequestBytes
+		resp.TotalResponseBytes += item.ResponseBytes
+	}
+
+	// 填充所有日期
+	resp.Sizes = make([]PayloadSizeItem, days)
+	for i := 0; i < days; i++ {
+		dateStr := startDate.AddDate(0, 0, i).Format("2006-01-02")
+		item := sizeMap[dateStr]
+		item.Date = dateStr
+		resp.Sizes[i] = item
+	}
+
+	return resp, nil
+}
@@ -18,6 +18,19 @@ type UpdateUserStatusRequest struct {
 // UpdateUserQuotaRequest 更新用户配额请求
 type UpdateUserQuotaRequest struct {
 	Quota int64 `json:"quota" binding:"required,min=0"`
+
+	// 用户级透支额度，-1 恢复使用系统默认值，不传保持不变
+	OverdraftLimit *int64 `json:"overdraft_limit" binding:"omitempty,min=-1"`
+
+	// 流式请求是否按 max_tokens 预扣配额：on、off，default 恢复使用系统默认值，不传保持不变
+	StreamPrecharge *string `json:"stream_precharge" binding:"omitempty,oneof=default on off"`
+}
+
+// UpdateUserRateLimitRequest 更新用户聚合限流请求，Limit 为 0 时取消聚合限流
+type UpdateUserRateLimitRequest struct {
+	Limit  int    `json:"limit" binding:"min=0"`
+	Window int    `json:"window" binding:"omitempty,min=1,max=86400"` // 窗口秒数，默认 60
+	Unit   string `json:"unit" binding:"omitempty,oneof=requests tokens"`
 }
 
 // UserResponse 用户响应
@@ -32,6 +45,13 @@ type UserResponse struct {
 	LastSignIn *time.Time `json:"last_sign_in,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+
+	OverdraftLimit  *int64 `json:"overdraft_limit,omitempty"`
+	StreamPrecharge *bool  `json:"stream_precharge,omitempty"`
+
+	AggregateRateLimit  int    `json:"aggregate_rate_limit"`
+	AggregateRateWindow int    `json:"aggregate_rate_window"`
+	AggregateRateUnit   string `json:"aggregate_rate_unit"`
 }
 
 // GetUsersResponse 获取用户列表响应
@@ -55,6 +75,13 @@ func (u *User) ToResponse() *UserResponse {
 		LastSignIn: u.LastSignIn,
 		CreatedAt:  u.CreatedAt,
 		UpdatedAt:  u.UpdatedAt,
+
+		OverdraftLimit:  u.OverdraftLimit,
+		StreamPrecharge: u.StreamPrecharge,
+
+		AggregateRateLimit:  u.AggregateRateLimit,
+		AggregateRateWindow: u.AggregateRateWindow,
+		AggregateRateUnit:   u.AggregateRateUnit,
 	}
 }
 
@@ -66,3 +93,16 @@ func ToResponseList(users []*User) []*UserResponse {
 	}
 	return responses
 }
+
+// PurgeUserDataRequest 清除用户数据请求
+type PurgeUserDataRequest struct {
+	Scope string `form:"scope" binding:"omitempty,oneof=caches full"` // 默认 full
+}
+
+// PurgeUserDataResponse 清除用户数据结果
+type PurgeUserDataResponse struct {
+	UserID         uint   `json:"user_id"`
+	Scope          string `json:"scope"`
+	CachesDeleted  int64  `json:"caches_deleted"`
+	LogsAnonymized int64  `json:"logs_anonymized"`
+}
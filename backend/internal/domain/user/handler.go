@@ -165,6 +165,45 @@ func (h *Handler) UpdateUserQuota(c *gin.Context) {
 	response.SuccessWithMessage(c, "User quota updated successfully", nil)
 }
 
+// UpdateUserRateLimit 更新用户聚合限流
+// @Summary 更新用户聚合限流
+// @Description 设置用户所有 API Key 共享的请求数或 token 限流（管理员）
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param request body UpdateUserRateLimitRequest true "更新请求"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/users/{id}/rate-limit [put]
+func (h *Handler) UpdateUserRateLimit(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID", "User ID must be a valid number")
+		return
+	}
+
+	var req UpdateUserRateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request body", err.Error())
+		return
+	}
+
+	if err := h.service.UpdateUserRateLimit(c.Request.Context(), uint(id), &req); err != nil {
+		if errors.Is(err, errors.ErrUserNotFound) {
+			response.NotFound(c, "User not found")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "User rate limit updated successfully", nil)
+}
+
 // DeleteUser 删除用户
 // @Summary 删除用户
 // @Description 删除用户（管理员）
@@ -196,3 +235,114 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 
 	response.SuccessWithMessage(c, "User deleted successfully", nil)
 }
+
+// PurgeUserData 清除用户数据
+// @Summary 清除用户数据
+// @Description 删除用户缓存的请求/响应，full 范围下同时匿名化请求日志（管理员）
+// @Tags User
+// @Produce json
+// @Param id path int true "用户ID"
+// @Param scope query string false "清除范围：caches 或 full（默认）"
+// @Success 200 {object} response.Response{data=PurgeUserDataResponse}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/admin/users/{id}/data [delete]
+func (h *Handler) PurgeUserData(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID", "User ID must be a valid number")
+		return
+	}
+
+	var req PurgeUserDataRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	var operatorID uint
+	if v, exists := c.Get("user_id"); exists {
+		operatorID, _ = v.(uint)
+	}
+
+	resp, err := h.service.PurgeUserData(c.Request.Context(), uint(id), operatorID, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, errors.ErrUserNotFound):
+			response.NotFound(c, "User not found")
+		case errors.Is(err, errors.ErrInvalidParam):
+			response.BadRequest(c, err.Error(), "")
+		default:
+			response.InternalError(c, err)
+		}
+		return
+	}
+
+	response.Success(c, resp)
+}
+
+// GetPreferences 获取当前用户的偏好设置
+// @Summary 获取用户偏好
+// @Description 获取默认模型、供应商偏好顺序和默认请求参数，代理请求未指定时使用
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} response.Response{data=Preferences}
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/user/preferences [get]
+func (h *Handler) GetPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	prefs, err := h.service.GetPreferences(c.Request.Context(), userID.(uint))
+	if err != nil {
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, prefs)
+}
+
+// UpdatePreferences 更新当前用户的偏好设置
+// @Summary 更新用户偏好
+// @Description 整体替换默认模型、供应商偏好顺序和默认请求参数；请求显式传值和 API Key 参数覆盖优先于偏好
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdatePreferencesRequest true "偏好设置"
+// @Success 200 {object} response.Response{data=Preferences}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/v1/user/preferences [put]
+func (h *Handler) UpdatePreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err.Error())
+		return
+	}
+
+	prefs, err := h.service.UpdatePreferences(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		if errors.Is(err, errors.ErrInvalidParam) {
+			response.BadRequest(c, err.Error(), "")
+			return
+		}
+		response.InternalError(c, err)
+		return
+	}
+
+	response.Success(c, prefs)
+}
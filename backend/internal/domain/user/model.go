@@ -17,8 +17,24 @@ type User struct {
 	IsAdmin      bool           `gorm:"not null;default:false" json:"is_admin"`
 	Status       string         `gorm:"not null;default:'active';size:50" json:"status"`
 	LastSignIn   *time.Time     `json:"last_sign_in,omitempty"`
+
+	// 允许透支的配额，为空时使用系统默认值
+	OverdraftLimit *int64 `json:"overdraft_limit,omitempty"`
+	// 流式请求是否按 max_tokens 预扣配额，为空时使用系统默认值
+	StreamPrecharge *bool `json:"stream_precharge,omitempty"`
+
+	// 用户所有 API Key 共享的聚合限流：每 AggregateRateWindow 秒最多消耗 AggregateRateLimit 个单位，0 表示不限制
+	AggregateRateLimit  int    `gorm:"not null;default:0" json:"aggregate_rate_limit"`
+	AggregateRateWindow int    `gorm:"not null;default:60" json:"aggregate_rate_window"`
+	AggregateRateUnit   string `gorm:"not null;default:'requests';size:20" json:"aggregate_rate_unit"`
 }
 
+// 聚合限流的计量单位
+const (
+	RateUnitRequests = "requests" // 每个请求消耗 1
+	RateUnitTokens   = "tokens"   // 按请求体估算的输入 token 消耗
+)
+
 // TableName 鎸囧畾琛ㄥ悕
 func (User) TableName() string {
 	return "users"
@@ -29,6 +45,22 @@ func (u *User) IsActive() bool {
 	return u.Status == "active"
 }
 
+// OverdraftAllowance 返回用户可透支的配额，未单独设置时使用 defaultLimit
+func (u *User) OverdraftAllowance(defaultLimit int64) int64 {
+	if u.OverdraftLimit != nil {
+		return *u.OverdraftLimit
+	}
+	return defaultLimit
+}
+
+// StreamPrechargeEnabled 返回用户的流式请求是否需要预扣配额，未单独设置时使用 defaultEnabled
+func (u *User) StreamPrechargeEnabled(defaultEnabled bool) bool {
+	if u.StreamPrecharge != nil {
+		return *u.StreamPrecharge
+	}
+	return defaultEnabled
+}
+
 // HasQuota 妫€鏌ユ槸鍚︽湁瓒冲閰嶉
 func (u *User) HasQuota(required int64) bool {
 	return u.Quota-u.UsedQuota >= required
@@ -61,3 +93,30 @@ func (u *User) AddQuota(amount int64) {
 func (u *User) ResetUsedQuota() {
 	u.UsedQuota = 0
 }
+
+// 用户数据清除范围
+const (
+	PurgeScopeCaches = "caches" // 仅删除缓存的请求/响应
+	PurgeScopeFull   = "full"   // 删除缓存并匿名化请求日志（保留计费数据）
+)
+
+// 审计动作
+const (
+	AuditActionPurgeUserData = "user.purge_data"
+)
+
+// AuditLog 管理操作审计日志
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	OperatorID uint      `gorm:"not null;index" json:"operator_id"`
+	Action     string    `gorm:"not null;size:100;index" json:"action"`
+	TargetType string    `gorm:"not null;size:50" json:"target_type"`
+	TargetID   uint      `gorm:"not null;index" json:"target_id"`
+	Details    string    `gorm:"type:jsonb" json:"details,omitempty"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
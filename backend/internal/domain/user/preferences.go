@@ -0,0 +1,139 @@
+package user
+
+import (
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// preferenceProviders 可在供应商偏好中使用的配置类型
+var preferenceProviders = map[string]bool{
+	"openai":    true,
+	"anthropic": true,
+	"gemini":    true,
+	"kiro":      true,
+	"custom":    true,
+}
+
+// StringArray 字符串数组类型（JSONB 存储）
+type StringArray []string
+
+func (s StringArray) Value() (driver.Value, error) {
+	if s == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal(s)
+}
+
+func (s *StringArray) Scan(value interface{}) error {
+	if value == nil {
+		*s = []string{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// Preferences 用户级默认设置，代理请求未指定时使用
+// 优先级：请求显式传值 > API Key 参数覆盖 > 用户偏好 > 系统默认
+type Preferences struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"uniqueIndex;not null" json:"user_id"`
+
+	// 请求未指定模型时使用
+	DefaultModel string `gorm:"size:100" json:"default_model"`
+	// 供应商类型偏好顺序，请求未带 X-Prism-Provider-Preference 时使用
+	ProviderPreference StringArray `gorm:"type:jsonb" json:"provider_preference"`
+
+	// 默认请求参数，为空表示不设置
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+// TableName 指定表名
+func (Preferences) TableName() string {
+	return "user_preferences"
+}
+
+// UpdatePreferencesRequest 更新用户偏好请求，整体替换已有设置
+type UpdatePreferencesRequest struct {
+	DefaultModel       string   `json:"default_model" binding:"omitempty,max=100"`
+	ProviderPreference []string `json:"provider_preference"`
+	Temperature        *float64 `json:"temperature"`
+	TopP               *float64 `json:"top_p"`
+	MaxTokens          *int     `json:"max_tokens"`
+}
+
+// validate 检查供应商类型和参数取值
+func (r *UpdatePreferencesRequest) validate() error {
+	seen := make(map[string]bool, len(r.ProviderPreference))
+	for _, provider := range r.ProviderPreference {
+		if !preferenceProviders[provider] {
+			return fmt.Errorf("provider_preference: unknown provider %q", provider)
+		}
+		if seen[provider] {
+			return fmt.Errorf("provider_preference: duplicate provider %q", provider)
+		}
+		seen[provider] = true
+	}
+	if r.Temperature != nil && *r.Temperature < 0 {
+		return fmt.Errorf("temperature: must not be negative")
+	}
+	if r.TopP != nil && *r.TopP < 0 {
+		return fmt.Errorf("top_p: must not be negative")
+	}
+	if r.MaxTokens != nil && *r.MaxTokens < 1 {
+		return fmt.Errorf("max_tokens: must be a positive integer")
+	}
+	return nil
+}
+
+// GetPreferences 获取用户偏好，未设置时返回空偏好
+func (s *service) GetPreferences(ctx context.Context, userID uint) (*Preferences, error) {
+	prefs, err := s.repo.FindPreferences(ctx, userID)
+	if err != nil {
+		s.logger.Error("Failed to get user preferences", logger.Uint("user_id", userID), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get user preferences")
+	}
+	if prefs == nil {
+		return &Preferences{UserID: userID, ProviderPreference: StringArray{}}, nil
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences 保存用户偏好
+func (s *service) UpdatePreferences(ctx context.Context, userID uint, req *UpdatePreferencesRequest) (*Preferences, error) {
+	if err := req.validate(); err != nil {
+		return nil, errors.ErrInvalidParam.WithDetails(err.Error())
+	}
+
+	prefs := &Preferences{
+		UserID:             userID,
+		DefaultModel:       req.DefaultModel,
+		ProviderPreference: StringArray(req.ProviderPreference),
+		Temperature:        req.Temperature,
+		TopP:               req.TopP,
+		MaxTokens:          req.MaxTokens,
+	}
+	if prefs.ProviderPreference == nil {
+		prefs.ProviderPreference = StringArray{}
+	}
+
+	if err := s.repo.SavePreferences(ctx, prefs); err != nil {
+		s.logger.Error("Failed to save user preferences", logger.Uint("user_id", userID), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to save user preferences")
+	}
+
+	s.logger.Info("User preferences updated", logger.Uint("user_id", userID))
+	return prefs, nil
+}
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"context"
+	"testing"
+
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+)
+
+// fakePreferenceRepo 内存中的偏好存储
+type fakePreferenceRepo struct {
+	Repository
+	prefs map[uint]*Preferences
+}
+
+func (r *fakePreferenceRepo) FindPreferences(ctx context.Context, userID uint) (*Preferences, error) {
+	return r.prefs[userID], nil
+}
+
+func (r *fakePreferenceRepo) SavePreferences(ctx context.Context, prefs *Preferences) error {
+	r.prefs[prefs.UserID] = prefs
+	return nil
+}
+
+func TestPreferences_DefaultsToEmptyAndReplacesOnUpdate(t *testing.T) {
+	repo := &fakePreferenceRepo{prefs: map[uint]*Preferences{}}
+	svc := NewService(repo, *logger.NewNop())
+
+	empty, err := svc.GetPreferences(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.DefaultModel != "" || len(empty.ProviderPreference) != 0 || empty.Temperature != nil {
+		t.Errorf("Expected empty preferences, got %+v", empty)
+	}
+
+	temperature := 0.3
+	if _, err := svc.UpdatePreferences(context.Background(), 1, &UpdatePreferencesRequest{
+		DefaultModel:       "gpt-4o",
+		ProviderPreference: []string{"anthropic", "openai"},
+		Temperature:        &temperature,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.UpdatePreferences(context.Background(), 1, &UpdatePreferencesRequest{DefaultModel: "claude-3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := svc.GetPreferences(context.Background(), 1)
+	if got.DefaultModel != "claude-3" || len(got.ProviderPreference) != 0 || got.Temperature != nil {
+		t.Errorf("Expected the second update to replace all fields, got %+v", got)
+	}
+}
+
+func TestUpdatePreferences_RejectsInvalidValues(t *testing.T) {
+	svc := NewService(&fakePreferenceRepo{prefs: map[uint]*Preferences{}}, *logger.NewNop())
+	negative, zero := -0.1, 0
+
+	for name, req := range map[string]*UpdatePreferencesRequest{
+		"unknown provider":   {ProviderPreference: []string{"bedrock"}},
+		"duplicate provider": {ProviderPreference: []string{"openai", "openai"}},
+		"negative top_p":     {TopP: &negative},
+		"zero max_tokens":    {MaxTokens: &zero},
+	} {
+		if _, err := svc.UpdatePreferences(context.Background(), 1, req); !errors.Is(err, errors.ErrInvalidParam) {
+			t.Errorf("%s: expected invalid param error, got %v", name, err)
+		}
+	}
+}
@@ -3,9 +3,11 @@ package user
 import (
 	"api-aggregator/backend/pkg/query"
 	"context"
+	"encoding/json"
 	"errors"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository 用户仓储接口
@@ -20,8 +22,14 @@ type Repository interface {
 	List(ctx context.Context, filters []query.Filter, sorts []query.Sort, pagination *query.Pagination) ([]*User, int64, error)
 	UpdateStatus(ctx context.Context, id uint, status string) error
 	UpdateQuota(ctx context.Context, id uint, quota int64) error
+	UpdateOverdraftLimit(ctx context.Context, id uint, limit *int64) error
+	UpdateStreamPrecharge(ctx context.Context, id uint, enabled *bool) error
+	UpdateRateLimit(ctx context.Context, id uint, limit, window int, unit string) error
 	CountAll(ctx context.Context) (int64, error)
 	CountByStatus(ctx context.Context, status string) (int64, error)
+	PurgeUserData(ctx context.Context, userID uint, scope string, audit *AuditLog) (*PurgeUserDataResponse, error)
+	FindPreferences(ctx context.Context, userID uint) (*Preferences, error)
+	SavePreferences(ctx context.Context, prefs *Preferences) error
 }
 
 // repository 用户仓储实现
@@ -147,6 +155,25 @@ func (r *repository) UpdateQuota(ctx context.Context, id uint, quota int64) erro
 	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("quota", quota).Error
 }
 
+// UpdateOverdraftLimit 更新用户透支额度，nil 表示使用系统默认值
+func (r *repository) UpdateOverdraftLimit(ctx context.Context, id uint, limit *int64) error {
+	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("overdraft_limit", limit).Error
+}
+
+// UpdateStreamPrecharge 更新用户流式请求是否预扣配额，nil 表示使用系统默认值
+func (r *repository) UpdateStreamPrecharge(ctx context.Context, id uint, enabled *bool) error {
+	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Update("stream_precharge", enabled).Error
+}
+
+// UpdateRateLimit 更新用户聚合限流设置
+func (r *repository) UpdateRateLimit(ctx context.Context, id uint, limit, window int, unit string) error {
+	return r.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"aggregate_rate_limit":  limit,
+		"aggregate_rate_window": window,
+		"aggregate_rate_unit":   unit,
+	}).Error
+}
+
 // CountAll 统计所有用户数
 func (r *repository) CountAll(ctx context.Context) (int64, error) {
 	var count int64
@@ -160,3 +187,60 @@ func (r *repository) CountByStatus(ctx context.Context, status string) (int64, e
 	err := r.db.WithContext(ctx).Model(&User{}).Where("status = ?", status).Count(&count).Error
 	return count, err
 }
+
+// PurgeUserData 在事务中清除用户数据并写入审计日志
+// 缓存直接删除；full 范围下请求日志去除用户标识，但保留模型、token 和费用用于计费汇总
+// quota_ledger 与 usage_counters 属于计费账本，不做处理
+func (r *repository) PurgeUserData(ctx context.Context, userID uint, scope string, audit *AuditLog) (*PurgeUserDataResponse, error) {
+	result := &PurgeUserDataResponse{UserID: userID, Scope: scope}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec("DELETE FROM request_caches WHERE user_id = ?", userID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.CachesDeleted = res.RowsAffected
+
+		if scope == PurgeScopeFull {
+			res = tx.Exec("UPDATE request_logs SET user_id = NULL, api_key_id = 0, error_msg = NULL WHERE user_id = ?", userID)
+			if res.Error != nil {
+				return res.Error
+			}
+			result.LogsAnonymized = res.RowsAffected
+		}
+
+		details, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		audit.Details = string(details)
+		return tx.Create(audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindPreferences 查找用户偏好，未设置时返回 nil
+func (r *repository) FindPreferences(ctx context.Context, userID uint) (*Preferences, error) {
+	var prefs Preferences
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&prefs).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SavePreferences 保存用户偏好（已存在时整体替换）
+func (r *repository) SavePreferences(ctx context.Context, prefs *Preferences) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "default_model", "provider_preference", "temperature", "top_p", "max_tokens",
+		}),
+	}).Create(prefs).Error
+}
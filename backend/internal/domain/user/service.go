@@ -13,7 +13,11 @@ type Service interface {
 	GetUserByID(ctx context.Context, id uint) (*UserResponse, error)
 	UpdateUserStatus(ctx context.Context, id uint, req *UpdateUserStatusRequest) error
 	UpdateUserQuota(ctx context.Context, id uint, req *UpdateUserQuotaRequest) error
+	UpdateUserRateLimit(ctx context.Context, id uint, req *UpdateUserRateLimitRequest) error
 	DeleteUser(ctx context.Context, id uint) error
+	PurgeUserData(ctx context.Context, id, operatorID uint, req *PurgeUserDataRequest) (*PurgeUserDataResponse, error)
+	GetPreferences(ctx context.Context, userID uint) (*Preferences, error)
+	UpdatePreferences(ctx context.Context, userID uint, req *UpdatePreferencesRequest) (*Preferences, error)
 }
 
 // service 用户服务实现
@@ -152,6 +156,35 @@ func (s *service) UpdateUserQuota(ctx context.Context, id uint, req *UpdateUserQ
 		return errors.Wrap(err, 500002, "Failed to update user quota")
 	}
 
+	if req.OverdraftLimit != nil {
+		var limit *int64
+		if *req.OverdraftLimit >= 0 {
+			limit = req.OverdraftLimit
+		}
+		if err := s.repo.UpdateOverdraftLimit(ctx, id, limit); err != nil {
+			s.logger.Error("Failed to update user overdraft limit",
+				logger.Uint("user_id", id),
+				logger.Int64("overdraft_limit", *req.OverdraftLimit),
+				logger.Error(err))
+			return errors.Wrap(err, 500002, "Failed to update user overdraft limit")
+		}
+	}
+
+	if req.StreamPrecharge != nil {
+		var enabled *bool
+		if *req.StreamPrecharge != "default" {
+			on := *req.StreamPrecharge == "on"
+			enabled = &on
+		}
+		if err := s.repo.UpdateStreamPrecharge(ctx, id, enabled); err != nil {
+			s.logger.Error("Failed to update user stream precharge",
+				logger.Uint("user_id", id),
+				logger.String("stream_precharge", *req.StreamPrecharge),
+				logger.Error(err))
+			return errors.Wrap(err, 500002, "Failed to update user stream precharge")
+		}
+	}
+
 	s.logger.Info("User quota updated",
 		logger.Uint("user_id", id),
 		logger.Int64("quota", req.Quota))
@@ -159,6 +192,40 @@ func (s *service) UpdateUserQuota(ctx context.Context, id uint, req *UpdateUserQ
 	return nil
 }
 
+// UpdateUserRateLimit 更新用户所有 API Key 共享的聚合限流
+func (s *service) UpdateUserRateLimit(ctx context.Context, id uint, req *UpdateUserRateLimitRequest) error {
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get user", logger.Uint("user_id", id), logger.Error(err))
+		return errors.Wrap(err, 500002, "Failed to get user")
+	}
+	if user == nil {
+		return errors.ErrUserNotFound
+	}
+
+	window := req.Window
+	if window == 0 {
+		window = 60
+	}
+	unit := req.Unit
+	if unit == "" {
+		unit = RateUnitRequests
+	}
+
+	if err := s.repo.UpdateRateLimit(ctx, id, req.Limit, window, unit); err != nil {
+		s.logger.Error("Failed to update user rate limit", logger.Uint("user_id", id), logger.Error(err))
+		return errors.Wrap(err, 500002, "Failed to update user rate limit")
+	}
+
+	s.logger.Info("User rate limit updated",
+		logger.Uint("user_id", id),
+		logger.Int("limit", req.Limit),
+		logger.Int("window", window),
+		logger.String("unit", unit))
+
+	return nil
+}
+
 // DeleteUser 删除用户
 func (s *service) DeleteUser(ctx context.Context, id uint) error {
 	// 检查用户是否存在
@@ -181,3 +248,44 @@ func (s *service) DeleteUser(ctx context.Context, id uint) error {
 
 	return nil
 }
+
+// PurgeUserData 清除用户存储的提示词/响应（数据删除请求）
+func (s *service) PurgeUserData(ctx context.Context, id, operatorID uint, req *PurgeUserDataRequest) (*PurgeUserDataResponse, error) {
+	scope := req.Scope
+	if scope == "" {
+		scope = PurgeScopeFull
+	}
+	if scope != PurgeScopeCaches && scope != PurgeScopeFull {
+		return nil, errors.ErrInvalidParam.WithDetails("Scope must be caches or full")
+	}
+
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Failed to get user", logger.Uint("user_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to get user")
+	}
+	if user == nil {
+		return nil, errors.ErrUserNotFound
+	}
+
+	audit := &AuditLog{
+		OperatorID: operatorID,
+		Action:     AuditActionPurgeUserData,
+		TargetType: "user",
+		TargetID:   id,
+	}
+	result, err := s.repo.PurgeUserData(ctx, id, scope, audit)
+	if err != nil {
+		s.logger.Error("Failed to purge user data", logger.Uint("user_id", id), logger.Error(err))
+		return nil, errors.Wrap(err, 500002, "Failed to purge user data")
+	}
+
+	s.logger.Info("User data purged",
+		logger.Uint("user_id", id),
+		logger.Uint("operator_id", operatorID),
+		logger.String("scope", scope),
+		logger.Int64("caches_deleted", result.CachesDeleted),
+		logger.Int64("logs_anonymized", result.LogsAnonymized))
+
+	return result, nil
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
+)
+
+type fakeLogRow struct {
+	UserID    uint
+	APIKeyID  uint
+	ErrorMsg  string
+	QuotaCost int64
+}
+
+// fakeRepo 内存中的用户仓储，模拟缓存和请求日志表
+type fakeRepo struct {
+	Repository
+	users  map[uint]*User
+	caches map[uint]int // user_id -> 缓存条数
+	logs   []*fakeLogRow
+	audits []*AuditLog
+}
+
+func (r *fakeRepo) FindByID(ctx context.Context, id uint) (*User, error) {
+	return r.users[id], nil
+}
+
+func (r *fakeRepo) PurgeUserData(ctx context.Context, userID uint, scope string, audit *AuditLog) (*PurgeUserDataResponse, error) {
+	result := &PurgeUserDataResponse{UserID: userID, Scope: scope, CachesDeleted: int64(r.caches[userID])}
+	delete(r.caches, userID)
+	if scope == PurgeScopeFull {
+		for _, l := range r.logs {
+			if l.UserID == userID {
+				l.UserID, l.APIKeyID, l.ErrorMsg = 0, 0, ""
+				result.LogsAnonymized++
+			}
+		}
+	}
+	details, _ := json.Marshal(result)
+	audit.Details = string(details)
+	r.audits = append(r.audits, audit)
+	return result, nil
+}
+
+func (r *fakeRepo) totalCost() int64 {
+	var total int64
+	for _, l := range r.logs {
+		total += l.QuotaCost
+	}
+	return total
+}
+
+func newPurgeTestService() (Service, *fakeRepo) {
+	repo := &fakeRepo{
+		users:  map[uint]*User{1: {ID: 1}, 2: {ID: 2}},
+		caches: map[uint]int{1: 3, 2: 1},
+		logs: []*fakeLogRow{
+			{UserID: 1, APIKeyID: 10, ErrorMsg: "prompt: secret", QuotaCost: 100},
+			{UserID: 1, APIKeyID: 10, QuotaCost: 50},
+			{UserID: 2, APIKeyID: 20, QuotaCost: 70},
+		},
+	}
+	return NewService(repo, *logger.NewNop()), repo
+}
+
+func TestPurgeUserData_FullRemovesCachesAndAnonymizesLogs(t *testing.T) {
+	svc, repo := newPurgeTestService()
+	before := repo.totalCost()
+
+	resp, err := svc.PurgeUserData(context.Background(), 1, 99, &PurgeUserDataRequest{})
+	if err != nil {
+		t.Fatalf("PurgeUserData failed: %v", err)
+	}
+	if resp.Scope != PurgeScopeFull || resp.CachesDeleted != 3 || resp.LogsAnonymized != 2 {
+		t.Errorf("Unexpected result: %+v", resp)
+	}
+	if _, ok := repo.caches[1]; ok {
+		t.Error("Expected user caches removed")
+	}
+	if repo.caches[2] != 1 {
+		t.Error("Other users' caches must be kept")
+	}
+	for _, l := range repo.logs[:2] {
+		if l.UserID != 0 || l.APIKeyID != 0 || l.ErrorMsg != "" {
+			t.Errorf("Expected log anonymized, got %+v", l)
+		}
+	}
+	if repo.logs[2].UserID != 2 {
+		t.Error("Other users' logs must be kept")
+	}
+	if after := repo.totalCost(); after != before {
+		t.Errorf("Billing totals changed: %d -> %d", before, after)
+	}
+
+	if len(repo.audits) != 1 {
+		t.Fatalf("Expected one audit record, got %d", len(repo.audits))
+	}
+	audit := repo.audits[0]
+	if audit.OperatorID != 99 || audit.Action != AuditActionPurgeUserData || audit.TargetID != 1 || audit.Details == "" {
+		t.Errorf("Unexpected audit record: %+v", audit)
+	}
+}
+
+func TestPurgeUserData_CachesScopeKeepsLogs(t *testing.T) {
+	svc, repo := newPurgeTestService()
+
+	resp, err := svc.PurgeUserData(context.Background(), 1, 99, &PurgeUserDataRequest{Scope: PurgeScopeCaches})
+	if err != nil {
+		t.Fatalf("PurgeUserData failed: %v", err)
+	}
+	if resp.CachesDeleted != 3 || resp.LogsAnonymized != 0 {
+		t.Errorf("Unexpected result: %+v", resp)
+	}
+	if repo.logs[0].UserID != 1 || repo.logs[0].ErrorMsg == "" {
+		t.Error("Logs must not be touched for caches scope")
+	}
+}
+
+func TestPurgeUserData_Errors(t *testing.T) {
+	svc, repo := newPurgeTestService()
+
+	if _, err := svc.PurgeUserData(context.Background(), 42, 99, &PurgeUserDataRequest{}); !errors.Is(err, errors.ErrUserNotFound) {
+		t.Errorf("Expected user not found, got %v", err)
+	}
+	if _, err := svc.PurgeUserData(context.Background(), 1, 99, &PurgeUserDataRequest{Scope: "everything"}); !errors.Is(err, errors.ErrInvalidParam) {
+		t.Errorf("Expected invalid param, got %v", err)
+	}
+	if len(repo.audits) != 0 {
+		t.Error("Failed purges must not be audited")
+	}
+}
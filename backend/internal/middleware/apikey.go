@@ -2,6 +2,8 @@ package middleware
 
 import (
 	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/pkg/errors"
+	"api-aggregator/backend/pkg/logger"
 	"api-aggregator/backend/pkg/response"
 	"strings"
 
@@ -51,7 +53,12 @@ func (m *APIKey) Handle() gin.HandlerFunc {
 		}
 
 		// 验证API密钥
-		userID, apiKeyID, err := m.apiKeyService.ValidateAPIKey(c.Request.Context(), key)
+		apiKey, err := m.apiKeyService.ValidateAPIKey(c.Request.Context(), key)
+		if errors.Is(err, errors.ErrAPIKeyExpired) {
+			response.Unauthorized(c, "API key has expired")
+			c.Abort()
+			return
+		}
 		if err != nil {
 			response.Unauthorized(c, "invalid or inactive API key")
 			c.Abort()
@@ -59,9 +66,11 @@ func (m *APIKey) Handle() gin.HandlerFunc {
 		}
 
 		// 设置用户信息到上下文
-		c.Set("user_id", userID)
-		c.Set("api_key_id", apiKeyID)
+		c.Set("user_id", apiKey.UserID)
+		c.Set("api_key_id", apiKey.ID)
 		c.Set("api_key", key)
+		c.Set("api_key_info", apiKey)
+		logger.AddFields(c.Request.Context(), logger.Uint("user_id", apiKey.UserID), logger.Uint("api_key_id", apiKey.ID))
 		c.Next()
 	}
 }
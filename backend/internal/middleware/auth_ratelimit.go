@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"api-aggregator/backend/pkg/cache"
+	"api-aggregator/backend/pkg/response"
+	"api-aggregator/backend/pkg/runtime"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未加载运行时配置时使用的默认策略
+const (
+	defaultAuthRateLimitPerMinute = 20
+	defaultAuthMaxLoginFailures   = 5
+	defaultAuthLockout            = time.Minute
+	defaultAuthMaxLockout         = time.Hour
+
+	// 登录失败计数的统计窗口，以及锁定次数的记忆时长（用于逐次加长锁定）
+	authFailureWindow = 15 * time.Minute
+	authLockoutMemory = 24 * time.Hour
+)
+
+// AuthRateLimit 登录、注册等公开认证接口的限流中间件
+// 按 IP 限制每分钟请求数；登录连续失败达到阈值后按用户名和 IP 锁定，锁定时长逐次翻倍
+type AuthRateLimit struct {
+	cache         cache.Cache
+	runtimeConfig *runtime.Manager
+	now           func() time.Time
+}
+
+// NewAuthRateLimit 创建认证接口限流中间件实例
+func NewAuthRateLimit(cache cache.Cache, runtimeConfig *runtime.Manager) *AuthRateLimit {
+	return &AuthRateLimit{
+		cache:         cache,
+		runtimeConfig: runtimeConfig,
+		now:           time.Now,
+	}
+}
+
+// policy 获取当前限流策略
+func (m *AuthRateLimit) policy() (perMinute, maxFailures int, lockout, maxLockout time.Duration) {
+	if m.runtimeConfig == nil {
+		return defaultAuthRateLimitPerMinute, defaultAuthMaxLoginFailures, defaultAuthLockout, defaultAuthMaxLockout
+	}
+	return m.runtimeConfig.Get().GetAuthRateLimit()
+}
+
+// Handle 按 IP 限制接口每分钟请求数，name 区分不同接口的计数
+func (m *AuthRateLimit) Handle(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.allowIP(c, name) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// HandleLogin 登录接口限流：在 IP 限流基础上检查锁定，并根据登录结果累计或清除失败次数
+func (m *AuthRateLimit) HandleLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.allowIP(c, "login") {
+			return
+		}
+
+		subjects := []string{"ip:" + c.ClientIP()}
+		if username := peekUsername(c); username != "" {
+			subjects = append(subjects, "user:"+username)
+		}
+
+		for _, subject := range subjects {
+			if retryAfter := m.lockedFor(subject); retryAfter > 0 {
+				abortTooManyRequests(c, retryAfter, "too many failed login attempts, please try again later")
+				return
+			}
+		}
+
+		c.Next()
+
+		switch c.Writer.Status() {
+		case http.StatusUnauthorized:
+			for _, subject := range subjects {
+				m.recordFailure(subject)
+			}
+		case http.StatusOK:
+			for _, subject := range subjects {
+				m.reset(subject)
+			}
+		}
+	}
+}
+
+// allowIP 检查并累计 IP 在当前分钟的请求数，超限时直接返回 429
+// 缓存不可用时放行，避免 Redis 故障导致无法登录
+func (m *AuthRateLimit) allowIP(c *gin.Context, name string) bool {
+	perMinute, _, _, _ := m.policy()
+	if perMinute <= 0 {
+		return true
+	}
+
+	now := m.now()
+	key := fmt.Sprintf("auth_rate_limit:%s:%s:%d", name, c.ClientIP(), now.Unix()/60)
+	var count int64
+	if err := m.cache.Get(key, &count); err != nil {
+		count = 0
+	}
+	count++
+	if err := m.cache.Set(key, count, 2*time.Minute); err != nil {
+		return true
+	}
+
+	if count > int64(perMinute) {
+		retryAfter := time.Duration(60-now.Unix()%60) * time.Second
+		abortTooManyRequests(c, retryAfter, fmt.Sprintf("rate limit of %d requests per minute exceeded", perMinute))
+		return false
+	}
+	return true
+}
+
+// lockedFor 返回锁定剩余时长，未锁定时返回 0
+func (m *AuthRateLimit) lockedFor(subject string) time.Duration {
+	var until int64
+	if err := m.cache.Get("auth_lock:"+subject, &until); err != nil {
+		return 0
+	}
+	remaining := time.Unix(until, 0).Sub(m.now())
+	if remaining <= 0 {
+		return 0
+	}
+	return remaining
+}
+
+// recordFailure 累计登录失败次数，达到阈值后锁定，锁定时长按历史锁定次数翻倍
+func (m *AuthRateLimit) recordFailure(subject string) {
+	_, maxFailures, lockout, maxLockout := m.policy()
+	if maxFailures <= 0 || lockout <= 0 {
+		return
+	}
+
+	failKey := "auth_fail:" + subject
+	var failures int64
+	if err := m.cache.Get(failKey, &failures); err != nil {
+		failures = 0
+	}
+	failures++
+	if failures < int64(maxFailures) {
+		m.cache.Set(failKey, failures, authFailureWindow)
+		return
+	}
+
+	countKey := "auth_lockouts:" + subject
+	var lockouts int64
+	if err := m.cache.Get(countKey, &lockouts); err != nil {
+		lockouts = 0
+	}
+	lockouts++
+
+	duration := lockout
+	for i := int64(1); i < lockouts && duration < maxLockout; i++ {
+		duration *= 2
+	}
+	if maxLockout > 0 && duration > maxLockout {
+		duration = maxLockout
+	}
+
+	m.cache.Set("auth_lock:"+subject, m.now().Add(duration).Unix(), duration)
+	m.cache.Set(countKey, lockouts, authLockoutMemory)
+	m.cache.Delete(failKey)
+}
+
+// reset 登录成功后清除失败次数和锁定记录
+func (m *AuthRateLimit) reset(subject string) {
+	m.cache.Delete("auth_fail:" + subject)
+	m.cache.Delete("auth_lockouts:" + subject)
+	m.cache.Delete("auth_lock:" + subject)
+}
+
+// peekUsername 读取请求体中的用户名，并恢复请求体供后续处理器绑定
+func peekUsername(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var payload struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	return strings.TrimSpace(payload.Username)
+}
+
+// abortTooManyRequests 返回 429 并设置 Retry-After（秒，向上取整）
+func abortTooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	response.TooManyRequests(c, message)
+	c.Abort()
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memCache 以 JSON 存储的内存缓存，行为与 Redis 实现一致（忽略过期时间）
+type memCache struct {
+	data map[string][]byte
+}
+
+func newMemCache() *memCache {
+	return &memCache{data: make(map[string][]byte)}
+}
+
+func (m *memCache) Get(key string, value interface{}) error {
+	data, ok := m.data[key]
+	if !ok {
+		return fmt.Errorf("cache miss")
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (m *memCache) Set(key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	m.data[key] = data
+	return nil
+}
+
+func (m *memCache) Delete(key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memCache) Exists(key string) (bool, error) {
+	_, ok := m.data[key]
+	return ok, nil
+}
+
+func (m *memCache) Clear() error {
+	m.data = make(map[string][]byte)
+	return nil
+}
+
+// newLoginEngine 登录处理器只接受 alice/secret
+func newLoginEngine(m *AuthRateLimit) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/login", m.HandleLogin(), func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		c.ShouldBindJSON(&req)
+		if req.Username == "alice" && req.Password == "secret" {
+			c.JSON(http.StatusOK, gin.H{"token": "t"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid"})
+	})
+	return engine
+}
+
+func login(engine *gin.Engine, ip, username, password string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":1234"
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthRateLimit_LocksOutAfterRepeatedFailures(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewAuthRateLimit(newMemCache(), nil)
+	m.now = func() time.Time { return now }
+	engine := newLoginEngine(m)
+
+	for i := 0; i < defaultAuthMaxLoginFailures; i++ {
+		if w := login(engine, "10.0.0.1", "alice", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Attempt %d: expected 401, got %d", i+1, w.Code)
+		}
+	}
+
+	// 锁定期间即使密码正确也被拒绝，换 IP 也无法绕过用户名锁定
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		w := login(engine, ip, "alice", "secret")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected lockout from %s, got %d", ip, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "60" {
+			t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// 锁定到期后可以登录，再次失败累计重新开始
+	now = now.Add(defaultAuthLockout)
+	if w := login(engine, "10.0.0.2", "alice", "secret"); w.Code != http.StatusOK {
+		t.Fatalf("Expected login after lockout expired, got %d", w.Code)
+	}
+}
+
+func TestAuthRateLimit_LockoutDoublesOnRepeat(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	m := NewAuthRateLimit(newMemCache(), nil)
+	m.now = func() time.Time { return now }
+
+	for round := 0; round < 2; round++ {
+		for i := 0; i < defaultAuthMaxLoginFailures; i++ {
+			m.recordFailure("user:bob")
+		}
+		want := defaultAuthLockout << round
+		if got := m.lockedFor("user:bob"); got != want {
+			t.Fatalf("Round %d: expected lockout %v, got %v", round, want, got)
+		}
+		now = now.Add(want)
+	}
+}
+
+func TestAuthRateLimit_SuccessResetsFailures(t *testing.T) {
+	m := NewAuthRateLimit(newMemCache(), nil)
+	engine := newLoginEngine(m)
+
+	for i := 0; i < defaultAuthMaxLoginFailures-1; i++ {
+		login(engine, "10.0.0.1", "alice", "wrong")
+	}
+	if w := login(engine, "10.0.0.1", "alice", "secret"); w.Code != http.StatusOK {
+		t.Fatalf("Expected login within limits to succeed, got %d", w.Code)
+	}
+	// 计数已清零，再失败一次不会触发锁定
+	login(engine, "10.0.0.1", "alice", "wrong")
+	if w := login(engine, "10.0.0.1", "alice", "secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected failure counter reset after success, got %d", w.Code)
+	}
+}
+
+func TestAuthRateLimit_LimitsRequestsPerIP(t *testing.T) {
+	m := NewAuthRateLimit(newMemCache(), nil)
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/register", m.Handle("register"), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	var last *httptest.ResponseRecorder
+	for i := 0; i <= defaultAuthRateLimitPerMinute; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/register", nil)
+		req.RemoteAddr = "10.0.0.9:1234"
+		last = httptest.NewRecorder()
+		engine.ServeHTTP(last, req)
+	}
+	if last.Code != http.StatusTooManyRequests || last.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 429 with Retry-After once the per-minute limit is hit, got %d", last.Code)
+	}
+}
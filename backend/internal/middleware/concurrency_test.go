@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newConcurrencyEngine 处理器阻塞到 release 关闭，started 在每个请求进入处理器时收到信号
+func newConcurrencyEngine(m *ConcurrencyLimit, started chan<- struct{}, release <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(m.Handle())
+	engine.GET("/slow", func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func TestConcurrencyLimit_ShedsExcessAndLetsInFlightFinish(t *testing.T) {
+	m := NewConcurrencyLimit(2)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	engine := newConcurrencyEngine(m, started, release)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			codes[i] = w.Code
+		}(i)
+	}
+	<-started
+	<-started
+
+	if stats := m.Stats(); stats.InFlight != 2 || stats.MaxInFlight != 2 {
+		t.Errorf("Expected 2 of 2 in flight, got %+v", stats)
+	}
+
+	// 达到上限后新请求被拒绝，不进入处理器
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 when saturated, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After header, got %q", w.Header().Get("Retry-After"))
+	}
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("In-flight request %d: expected 200, got %d", i, code)
+		}
+	}
+	if stats := m.Stats(); stats.InFlight != 0 || stats.Shed != 1 {
+		t.Errorf("Expected no requests in flight and 1 shed, got %+v", stats)
+	}
+
+	// 容量释放后恢复接收请求
+	started = make(chan struct{}, 1)
+	w = httptest.NewRecorder()
+	newConcurrencyEngine(m, started, release).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected requests to be admitted once capacity frees up, got %d", w.Code)
+	}
+}
+
+func TestConcurrencyLimit_ZeroDisablesLimit(t *testing.T) {
+	m := NewConcurrencyLimit(0)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	engine := newConcurrencyEngine(m, started, release)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d", w.Code)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-started
+	}
+	if got := m.Stats().InFlight; got != 10 {
+		t.Errorf("Expected 10 in flight, got %d", got)
+	}
+	close(release)
+	wg.Wait()
+}
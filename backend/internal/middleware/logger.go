@@ -53,13 +53,14 @@ func (m *Logger) Handle() gin.HandlerFunc {
 			fields = append(fields, zap.String("errors", c.Errors.String()))
 		}
 
-		// 根据状态码选择日志级别
+		// 根据状态码选择日志级别，使用带请求字段的日志
+		log := logger.FromContext(c.Request.Context(), m.logger)
 		if statusCode >= 500 {
-			m.logger.Error("Server error", fields...)
+			log.Error("Server error", fields...)
 		} else if statusCode >= 400 {
-			m.logger.Warn("Client error", fields...)
+			log.Warn("Client error", fields...)
 		} else {
-			m.logger.Info("Request completed", fields...)
+			log.Info("Request completed", fields...)
 		}
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"api-aggregator/backend/internal/domain/user"
 	"api-aggregator/backend/pkg/cache"
 	"api-aggregator/backend/pkg/logger"
+	"api-aggregator/backend/pkg/runtime"
 	"time"
 )
 
@@ -17,7 +18,12 @@ type Manager struct {
 	Admin    *Admin
 	
 	// 限流相关
-	RateLimit *RateLimit
+	RateLimit        *RateLimit
+	AuthRateLimit    *AuthRateLimit
+	ConcurrencyLimit *ConcurrencyLimit
+
+	// 请求校验
+	RequestSchema *RequestSchema
 	
 	// 通用中间件
 	CORS      *CORS
@@ -35,12 +41,16 @@ type Config struct {
 	UserService   user.Service
 	Cache         cache.Cache
 	Logger        *logger.Logger
+	RuntimeConfig *runtime.Manager
 	
 	// CORS配置
 	CORSConfig *CORSConfig
 	
 	// 超时配置
 	RequestTimeout time.Duration
+
+	// 全局在途请求上限，0 表示不限制
+	MaxInFlightRequests int
 }
 
 // NewManager 创建中间件管理器实例
@@ -57,13 +67,18 @@ func NewManager(config *Config) *Manager {
 		Admin:  NewAdmin(config.UserService),
 		
 		// 限流相关
-		RateLimit: NewRateLimit(config.Cache),
+		RateLimit:        NewRateLimit(config.Cache, config.RuntimeConfig, config.UserService),
+		AuthRateLimit:    NewAuthRateLimit(config.Cache, config.RuntimeConfig),
+		ConcurrencyLimit: NewConcurrencyLimit(config.MaxInFlightRequests),
+
+		// 请求校验
+		RequestSchema: NewRequestSchema(config.RuntimeConfig),
 		
 		// 通用中间件
 		CORS:      NewCORS(config.CORSConfig),
 		Logger:    NewLogger(config.Logger),
 		Recovery:  NewRecovery(config.Logger),
-		RequestID: NewRequestID(),
+		RequestID: NewRequestID(config.Logger),
 		Timeout:   NewTimeout(config.RequestTimeout),
 	}
 }
@@ -2,23 +2,53 @@ package middleware
 
 import (
 	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/domain/user"
 	"api-aggregator/backend/pkg/cache"
 	"api-aggregator/backend/pkg/response"
+	"api-aggregator/backend/pkg/runtime"
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 未加载运行时配置时每个 API 密钥的默认小时、每日请求数上限
+const (
+	defaultRateLimitPerHour = 1000
+	defaultRateLimitPerDay  = 10000
+)
+
+// rateWindow 一个计数窗口：窗口长度、计数键前缀、请求数上限
+type rateWindow struct {
+	name   string
+	length time.Duration
+	prefix string
+	limit  int
+}
+
 // RateLimit 速率限制中间件
+// 按 API 密钥分别限制每分钟、每小时、每日请求数，小时和每日上限未在密钥上设置时取系统默认值；
+// 用户设置了聚合限流时，其所有密钥还共享一个令牌桶，两者都通过才放行
 type RateLimit struct {
-	cache cache.Cache
+	cache         cache.Cache
+	runtimeConfig *runtime.Manager
+	users         userLookup
+	now           func() time.Time
 }
 
-// NewRateLimit 创建速率限制中间件实例
-func NewRateLimit(cache cache.Cache) *RateLimit {
+// userLookup 读取用户的聚合限流设置
+type userLookup interface {
+	GetUserByID(ctx context.Context, id uint) (*user.UserResponse, error)
+}
+
+// NewRateLimit 创建速率限制中间件实例，users 为 nil 时只按密钥限流
+func NewRateLimit(cache cache.Cache, runtimeConfig *runtime.Manager, users userLookup) *RateLimit {
 	return &RateLimit{
-		cache: cache,
+		cache:         cache,
+		runtimeConfig: runtimeConfig,
+		users:         users,
+		now:           time.Now,
 	}
 }
 
@@ -27,31 +57,28 @@ func NewRateLimit(cache cache.Cache) *RateLimit {
 func (m *RateLimit) Handle() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从上下文获取API密钥（由APIKey中间件设置）
-		apiKeyInterface, exists := c.Get("api_key")
+		apiKeyInterface, exists := c.Get("api_key_info")
 		if !exists {
 			response.Unauthorized(c, "API key not found in context")
 			c.Abort()
 			return
 		}
 
-		apiKeyObj, ok := apiKeyInterface.(*apikey.APIKeyResponse)
+		apiKeyObj, ok := apiKeyInterface.(*apikey.APIKey)
 		if !ok {
 			response.InternalError(c, "invalid API key type in context")
 			c.Abort()
 			return
 		}
 
-		// 检查速率限制
-		allowed, err := m.checkRateLimit(apiKeyObj.ID, apiKeyObj.RateLimit)
+		retryAfter, reason, err := m.allow(c.Request.Context(), apiKeyObj, c.Request.ContentLength)
 		if err != nil {
 			response.InternalError(c, "failed to check rate limit")
 			c.Abort()
 			return
 		}
-
-		if !allowed {
-			response.TooManyRequests(c, fmt.Sprintf("rate limit of %d requests per minute exceeded", apiKeyObj.RateLimit))
-			c.Abort()
+		if reason != "" {
+			abortTooManyRequests(c, retryAfter, reason)
 			return
 		}
 
@@ -59,29 +86,99 @@ func (m *RateLimit) Handle() gin.HandlerFunc {
 	}
 }
 
-// checkRateLimit 检查API密钥是否超过速率限制
-// 使用Redis滑动窗口算法
-func (m *RateLimit) checkRateLimit(apiKeyID uint, rateLimit int) (bool, error) {
-	// 创建当前分钟的key
-	now := time.Now()
-	key := fmt.Sprintf("rate_limit:%d:%d", apiKeyID, now.Unix()/60)
+// Allow 为不经过本中间件的请求（如消息批次中逐条处理的请求）检查并计数速率限制，与 Handle 共用同一组计数
+// requestBytes 为请求体大小，按 token 计量的用户聚合限流据此估算消耗；返回值大于 0 表示已超限，需等待该时长后重试
+func (m *RateLimit) Allow(ctx context.Context, key *apikey.APIKey, requestBytes int64) (time.Duration, error) {
+	retryAfter, reason, err := m.allow(ctx, key, requestBytes)
+	if err != nil || reason == "" {
+		return 0, err
+	}
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	return retryAfter, nil
+}
+
+// allow 检查用户聚合令牌桶和密钥计数窗口，都通过时计数；被拒绝时返回需等待的时长和原因
+// 先计算用户聚合令牌桶，通过后再检查并计数密钥窗口，最后扣减令牌桶，任一层拒绝时另一层都不计数
+func (m *RateLimit) allow(ctx context.Context, key *apikey.APIKey, requestBytes int64) (time.Duration, string, error) {
+	bucket, err := m.takeUserBucket(ctx, key.UserID, requestBytes)
+	if err != nil {
+		return 0, "", err
+	}
+	if bucket != nil && bucket.retryAfter > 0 {
+		return bucket.retryAfter, fmt.Sprintf("aggregate rate limit of %d %s per %ds exceeded across the user's API keys",
+			bucket.limit.AggregateRateLimit, bucket.limit.AggregateRateUnit, bucket.limit.AggregateRateWindow), nil
+	}
 
-	// 获取当前计数
-	var count int64
-	err := m.cache.Get(key, &count)
+	// 检查速率限制
+	exceeded, retryAfter, err := m.checkRateLimit(key.ID, m.windows(key))
 	if err != nil {
-		// 如果key不存在，初始化为0
-		count = 0
+		return 0, "", err
+	}
+	if exceeded != nil {
+		return retryAfter, fmt.Sprintf("rate limit of %d requests per %s exceeded", exceeded.limit, exceeded.name), nil
+	}
+
+	if bucket != nil {
+		if err := m.saveUserBucket(bucket); err != nil {
+			return 0, "", err
+		}
 	}
+	return 0, "", nil
+}
 
-	// 增加计数
-	count++
+// windows 返回密钥生效的计数窗口，上限为 0 的窗口不限制
+func (m *RateLimit) windows(key *apikey.APIKey) []rateWindow {
+	perHour, perDay := defaultRateLimitPerHour, defaultRateLimitPerDay
+	if m.runtimeConfig != nil {
+		_, perHour, perDay = m.runtimeConfig.Get().GetDefaultRateLimit()
+	}
+	if key.RateLimitPerHour > 0 {
+		perHour = key.RateLimitPerHour
+	}
+	if key.RateLimitPerDay > 0 {
+		perDay = key.RateLimitPerDay
+	}
 
-	// 保存计数，过期时间2分钟
-	if err := m.cache.Set(key, count, 2*time.Minute); err != nil {
-		return false, err
+	return []rateWindow{
+		{name: "minute", length: time.Minute, prefix: "rate_limit", limit: key.RateLimit},
+		{name: "hour", length: time.Hour, prefix: "rate_limit_hour", limit: perHour},
+		{name: "day", length: 24 * time.Hour, prefix: "rate_limit_day", limit: perDay},
 	}
+}
 
-	// 检查是否超过限制
-	return count <= int64(rateLimit), nil
+// checkRateLimit 检查API密钥是否超过速率限制，未超限时在所有窗口计数
+// 窗口按自然分钟、小时、UTC 日对齐，超限时返回该窗口及距窗口重置的时长；被拒绝的请求不计数
+func (m *RateLimit) checkRateLimit(apiKeyID uint, windows []rateWindow) (*rateWindow, time.Duration, error) {
+	now := m.now()
+	keys := make([]string, len(windows))
+	counts := make([]int64, len(windows))
+
+	for i, w := range windows {
+		if w.limit <= 0 {
+			continue
+		}
+		seconds := int64(w.length / time.Second)
+		keys[i] = fmt.Sprintf("%s:%d:%d", w.prefix, apiKeyID, now.Unix()/seconds)
+
+		// 如果key不存在，计数为0
+		if err := m.cache.Get(keys[i], &counts[i]); err != nil {
+			counts[i] = 0
+		}
+		if counts[i] >= int64(w.limit) {
+			return &windows[i], time.Duration(seconds-now.Unix()%seconds) * time.Second, nil
+		}
+	}
+
+	// 保存计数，过期时间为两个窗口长度
+	for i, w := range windows {
+		if keys[i] == "" {
+			continue
+		}
+		if err := m.cache.Set(keys[i], counts[i]+1, 2*w.length); err != nil {
+			return nil, 0, err
+		}
+	}
+	return nil, 0, nil
 }
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newRateLimitEngine 模拟 APIKey 中间件把密钥放入上下文
+func newRateLimitEngine(m *RateLimit, key *apikey.APIKey) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("api_key_info", key)
+		c.Next()
+	}, m.Handle(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func callProxy(engine *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	return w
+}
+
+func TestRateLimit_PerDayBlocksUntilDayBoundary(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 50, 0, 0, time.UTC)
+	m := NewRateLimit(newMemCache(), nil, nil)
+	m.now = func() time.Time { return now }
+	engine := newRateLimitEngine(m, &apikey.APIKey{ID: 7, RateLimit: 100, RateLimitPerDay: 3})
+
+	for i := 0; i < 3; i++ {
+		if w := callProxy(engine); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, w.Code)
+		}
+		now = now.Add(2 * time.Minute)
+	}
+
+	w := callProxy(engine)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected the 4th request of the day to be rejected, got %d", w.Code)
+	}
+	// 23:56 被拒绝，距 UTC 零点还有 4 分钟
+	if got := w.Header().Get("Retry-After"); got != "240" {
+		t.Errorf("Expected Retry-After to point at midnight UTC, got %q", got)
+	}
+	if !strings.Contains(w.Body.String(), "per day") {
+		t.Errorf("Expected the per-day limit in the error, got %s", w.Body.String())
+	}
+
+	// 被拒绝的请求不计数，跨过零点后重新计数
+	now = time.Date(2024, 3, 2, 0, 0, 1, 0, time.UTC)
+	if w := callProxy(engine); w.Code != http.StatusOK {
+		t.Errorf("Expected the limit to reset at the day boundary, got %d", w.Code)
+	}
+}
+
+func TestRateLimit_PerHourFallsBackToDefault(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	m := NewRateLimit(newMemCache(), nil, nil)
+	m.now = func() time.Time { return now }
+	engine := newRateLimitEngine(m, &apikey.APIKey{ID: 8, RateLimit: defaultRateLimitPerHour + 1})
+
+	for i := 0; i < defaultRateLimitPerHour; i++ {
+		if w := callProxy(engine); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	w := callProxy(engine)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "3600" {
+		t.Errorf("Expected the default hourly limit with Retry-After 3600, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimit_KeysCountedSeparately(t *testing.T) {
+	m := NewRateLimit(newMemCache(), nil, nil)
+	m.now = func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+	first := newRateLimitEngine(m, &apikey.APIKey{ID: 1, RateLimit: 1})
+	second := newRateLimitEngine(m, &apikey.APIKey{ID: 2, RateLimit: 1})
+
+	callProxy(first)
+	if w := callProxy(first); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected per-minute limit on the first key, got %d", w.Code)
+	}
+	if w := callProxy(second); w.Code != http.StatusOK {
+		t.Errorf("Expected the second key unaffected, got %d", w.Code)
+	}
+}
@@ -1,16 +1,22 @@
 package middleware
 
 import (
+	"api-aggregator/backend/pkg/logger"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // RequestID 请求ID中间件
-type RequestID struct{}
+// 同时为请求创建带 request_id 字段的日志并放入请求 context，后续中间件和业务代码通过
+// logger.AddFields 追加用户、密钥、模型等字段，通过 logger.FromContext 取得
+type RequestID struct {
+	logger *logger.Logger
+}
 
-// NewRequestID 创建请求ID中间件实例
-func NewRequestID() *RequestID {
-	return &RequestID{}
+// NewRequestID 创建请求ID中间件实例，log 为 nil 时不创建请求日志
+func NewRequestID(log *logger.Logger) *RequestID {
+	return &RequestID{logger: log}
 }
 
 // Handle 请求ID处理
@@ -27,6 +33,10 @@ func (m *RequestID) Handle() gin.HandlerFunc {
 		// 设置请求ID到上下文和响应头
 		c.Set("request_id", requestID)
 		c.Header("X-Request-ID", requestID)
+		if m.logger != nil {
+			ctx := logger.NewContext(c.Request.Context(), m.logger.With(logger.String("request_id", requestID)))
+			c.Request = c.Request.WithContext(ctx)
+		}
 
 		c.Next()
 	}
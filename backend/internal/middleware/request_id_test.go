@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestID_ScopesLoggerToRequest(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	base := logger.FromZap(zap.New(core))
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(NewRequestID(base).Handle(), NewLogger(base).Handle())
+	engine.GET("/v1/models", func(c *gin.Context) {
+		logger.AddFields(c.Request.Context(), logger.Uint("user_id", 3), logger.Uint("api_key_id", 9))
+		logger.FromContext(c.Request.Context(), base).Info("handling")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("X-Request-ID", "req-42")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if logs.Len() != 2 {
+		t.Fatalf("Expected the handler and access log lines, got %d", logs.Len())
+	}
+	for _, entry := range logs.All() {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-42" || fields["user_id"] != uint64(3) || fields["api_key_id"] != uint64(9) {
+			t.Errorf("%q: expected request, user and key fields, got %v", entry.Message, fields)
+		}
+	}
+}
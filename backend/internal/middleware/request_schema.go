@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/runtime"
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestSchema 代理请求体校验中间件
+// 在进入业务逻辑前按接口协议校验请求体，返回该协议格式的 400 错误（包含字段路径）；
+// 读取请求体时即检查消息数上限和未知字段，超限的请求不会被完整缓冲；
+// 配置了角色别名时先把 human、ai 等非标准角色名改写为标准角色，无法识别的角色返回 400
+type RequestSchema struct {
+	runtimeConfig *runtime.Manager
+}
+
+// NewRequestSchema 创建请求体校验中间件实例
+func NewRequestSchema(runtimeConfig *runtime.Manager) *RequestSchema {
+	return &RequestSchema{runtimeConfig: runtimeConfig}
+}
+
+// Handle 按指定协议校验请求体，校验后恢复请求体供处理器读取
+func (m *RequestSchema) Handle(proto protocol.Protocol) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var limits protocol.DecodeLimits
+		var roleAliases map[string]string
+		validate := true
+		if m.runtimeConfig != nil {
+			cfg := m.runtimeConfig.Get()
+			limits.MaxMessages, limits.DisallowUnknownFields = cfg.GetRequestDecodeLimits()
+			validate = cfg.IsRequestSchemaValidationEnabled()
+			roleAliases = cfg.GetRoleAliases()
+		}
+		if !validate && limits == (protocol.DecodeLimits{}) && len(roleAliases) == 0 {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var verr *protocol.ValidationError
+			var err error
+			body, verr, err = protocol.ReadRequestBody(proto, c.Request.Body, limits)
+			if verr != nil {
+				m.reject(c, proto, verr)
+				return
+			}
+			if err != nil {
+				// 读取失败交给处理器返回原有错误
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+				c.Next()
+				return
+			}
+			// 非标准角色名改写为标准角色后再校验，处理器读到的是改写后的请求体
+			if len(roleAliases) > 0 {
+				if body, verr = protocol.NormalizeRoles(proto, body, roleAliases); verr != nil {
+					m.reject(c, proto, verr)
+					return
+				}
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		if !validate {
+			c.Next()
+			return
+		}
+		if verr := protocol.ValidateRequest(proto, body); verr != nil {
+			m.reject(c, proto, verr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// reject 以协议格式返回 400，API Key 配置了错误格式时按该格式返回
+func (m *RequestSchema) reject(c *gin.Context, proto protocol.Protocol, verr *protocol.ValidationError) {
+	if apikey.WriteFormattedError(c, verr.APIError(proto)) {
+		c.Abort()
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, protocol.ValidationErrorBody(proto, verr))
+}
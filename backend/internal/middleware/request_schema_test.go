@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"api-aggregator/backend/internal/protocol"
+	"api-aggregator/backend/pkg/runtime"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newSchemaEngine(m *RequestSchema, proto protocol.Protocol, received *string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/", m.Handle(proto), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		*received = string(body)
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func postBody(engine *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequestSchema_RejectsMalformedRequestPerProtocol(t *testing.T) {
+	tests := []struct {
+		proto protocol.Protocol
+		body  string
+		want  string
+	}{
+		{protocol.ProtocolOpenAI, `{"model":"gpt-4o"}`, `{"error":{"message":"messages: field is required","type":"invalid_request_error","param":"messages","code":null}}`},
+		{protocol.ProtocolAnthropic, `{"model":"claude","messages":"hi"}`, `{"type":"error","error":{"type":"invalid_request_error","message":"messages: expected array, got string"}}`},
+		{protocol.ProtocolGemini, `{"contents":[]}`, `{"error":{"code":400,"message":"contents: must contain at least 1 item(s)","status":"INVALID_ARGUMENT"}}`},
+	}
+	for _, tt := range tests {
+		var received string
+		w := postBody(newSchemaEngine(NewRequestSchema(nil), tt.proto, &received), tt.body)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", tt.proto, w.Code)
+		}
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.proto, got, tt.want)
+		}
+		if received != "" {
+			t.Errorf("%s: handler should not run for invalid requests", tt.proto)
+		}
+	}
+}
+
+func TestRequestSchema_PassesBodyThrough(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`
+	var received string
+	w := postBody(newSchemaEngine(NewRequestSchema(nil), protocol.ProtocolOpenAI, &received), body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if received != body {
+		t.Errorf("Expected handler to read the original body, got %q", received)
+	}
+}
+
+func TestRequestSchema_RejectsTooManyMessagesBeforeHandler(t *testing.T) {
+	rc := runtime.NewManager(nil)
+	rc.Get().RequestMaxMessages = 2
+	body := `{"model":"claude","messages":[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":"c"}]}`
+
+	var received string
+	w := postBody(newSchemaEngine(NewRequestSchema(rc), protocol.ProtocolAnthropic, &received), body)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400, got %d", w.Code)
+	}
+	want := `{"type":"error","error":{"type":"invalid_request_error","message":"messages: must contain at most 2 item(s)"}}`
+	if got := w.Body.String(); got != want {
+		t.Errorf("\n got: %s\nwant: %s", got, want)
+	}
+	if received != "" {
+		t.Error("Handler should not run for requests over the message limit")
+	}
+}
+
+func TestRequestSchema_DecodeLimitsApplyWithoutSchemaValidation(t *testing.T) {
+	rc := runtime.NewManager(nil)
+	rc.Get().RequestSchemaValidation = false
+	rc.Get().RequestMaxMessages = 1
+
+	var received string
+	engine := newSchemaEngine(NewRequestSchema(rc), protocol.ProtocolOpenAI, &received)
+	if w := postBody(engine, `{"messages":[{"role":"user"},{"role":"user"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected the message limit enforced, got %d", w.Code)
+	}
+	// schema 校验关闭时不符合 schema 的请求照常放行
+	body := `{"messages":"hi"}`
+	if w := postBody(engine, body); w.Code != http.StatusOK || received != body {
+		t.Errorf("Expected schema validation skipped, got %d %q", w.Code, received)
+	}
+}
+
+func TestRequestSchema_NormalizesRoleAliasesBeforeValidation(t *testing.T) {
+	rc := runtime.NewManager(nil)
+	rc.Get().RequestSchemaValidation = true
+	rc.Get().RoleAliases = map[string]string{"human": "user", "ai": "assistant"}
+
+	var received string
+	engine := newSchemaEngine(NewRequestSchema(rc), protocol.ProtocolOpenAI, &received)
+	w := postBody(engine, `{"model":"gpt-4o","messages":[{"role":"human","content":"hi"},{"role":"ai","content":"hello"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected aliased roles to pass validation, got %d: %s", w.Code, w.Body.String())
+	}
+	want := `{"messages":[{"content":"hi","role":"user"},{"content":"hello","role":"assistant"}],"model":"gpt-4o"}`
+	if received != want {
+		t.Errorf("Expected the handler to read normalized roles, got %s", received)
+	}
+
+	received = ""
+	w = postBody(engine, `{"model":"gpt-4o","messages":[{"role":"wizard","content":"hi"}]}`)
+	if w.Code != http.StatusBadRequest || received != "" {
+		t.Fatalf("Expected an unknown role rejected before the handler, got %d", w.Code)
+	}
+	wantErr := `{"error":{"message":"messages[0].role: must be one of: system, developer, user, assistant, tool, function, got \"wizard\"","type":"invalid_request_error","param":"messages[0].role","code":null}}`
+	if got := w.Body.String(); got != wantErr {
+		t.Errorf("\n got: %s\nwant: %s", got, wantErr)
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"api-aggregator/backend/internal/domain/apikey"
+	"api-aggregator/backend/internal/domain/user"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeUsers 按 ID 返回固定的用户限流设置
+type fakeUsers map[uint]*user.UserResponse
+
+func (f fakeUsers) GetUserByID(ctx context.Context, id uint) (*user.UserResponse, error) {
+	return f[id], nil
+}
+
+func TestRateLimit_UserKeysShareAggregateBucket(t *testing.T) {
+	users := fakeUsers{1: {ID: 1, AggregateRateLimit: 3, AggregateRateWindow: 60, AggregateRateUnit: user.RateUnitRequests}}
+	m := NewRateLimit(newMemCache(), nil, users)
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	first := newRateLimitEngine(m, &apikey.APIKey{ID: 1, UserID: 1, RateLimit: 10})
+	second := newRateLimitEngine(m, &apikey.APIKey{ID: 2, UserID: 1, RateLimit: 10})
+
+	// 两个密钥各自远未达到每分钟上限，但合计超过用户的聚合上限
+	for i, engine := range []*gin.Engine{first, second, first} {
+		if w := callProxy(engine); w.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200 within the aggregate limit, got %d", i+1, w.Code)
+		}
+	}
+	w := callProxy(second)
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "aggregate rate limit") {
+		t.Fatalf("Expected the aggregate limit to block the fourth request, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") != "20" {
+		t.Errorf("Expected Retry-After for one refilled token, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// 20 秒补充一个令牌，任一密钥都可以使用
+	now = now.Add(20 * time.Second)
+	if w := callProxy(first); w.Code != http.StatusOK {
+		t.Errorf("Expected a refilled token to be shared, got %d", w.Code)
+	}
+	if w := callProxy(second); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the bucket empty again, got %d", w.Code)
+	}
+}
+
+func TestRateLimit_StricterPerKeyLimitStillApplies(t *testing.T) {
+	users := fakeUsers{1: {ID: 1, AggregateRateLimit: 100, AggregateRateWindow: 60, AggregateRateUnit: user.RateUnitRequests}}
+	cache := newMemCache()
+	m := NewRateLimit(cache, nil, users)
+	m.now = func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+	engine := newRateLimitEngine(m, &apikey.APIKey{ID: 1, UserID: 1, RateLimit: 1})
+
+	callProxy(engine)
+	if w := callProxy(engine); w.Code != http.StatusTooManyRequests || strings.Contains(w.Body.String(), "aggregate") {
+		t.Fatalf("Expected the per-key limit to block, got %d %s", w.Code, w.Body.String())
+	}
+
+	// 被密钥限流拒绝的请求不消耗聚合令牌
+	var state bucketState
+	if err := cache.Get("user_rate_bucket:1", &state); err != nil || state.Tokens != 99 {
+		t.Errorf("Expected only the admitted request charged to the bucket, got %+v (%v)", state, err)
+	}
+}
+
+func TestRateLimit_AggregateTokenUnitWeighsRequestBody(t *testing.T) {
+	users := fakeUsers{1: {ID: 1, AggregateRateLimit: 100, AggregateRateWindow: 60, AggregateRateUnit: user.RateUnitTokens}}
+	m := NewRateLimit(newMemCache(), nil, users)
+	m.now = func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+	engine := newRateLimitEngine(m, &apikey.APIKey{ID: 1, UserID: 1, RateLimit: 10})
+
+	send := func(bytes int) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(strings.Repeat("x", bytes))))
+		return w.Code
+	}
+	if code := send(240); code != http.StatusOK {
+		t.Fatalf("Expected a 60-token request admitted, got %d", code)
+	}
+	if code := send(240); code != http.StatusTooManyRequests {
+		t.Errorf("Expected a second 60-token request to exceed the 100-token bucket, got %d", code)
+	}
+	if code := send(100); code != http.StatusOK {
+		t.Errorf("Expected a 25-token request to fit the remaining tokens, got %d", code)
+	}
+}
+
+func TestRateLimit_NoAggregateLimitByDefault(t *testing.T) {
+	m := NewRateLimit(newMemCache(), nil, fakeUsers{1: {ID: 1}})
+	m.now = func() time.Time { return time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC) }
+	engine := newRateLimitEngine(m, &apikey.APIKey{ID: 1, UserID: 1, RateLimit: 5})
+
+	for i := 0; i < 5; i++ {
+		if w := callProxy(engine); w.Code != http.StatusOK {
+			t.Fatalf("Expected only per-key limits without an aggregate limit, got %d", w.Code)
+		}
+	}
+}
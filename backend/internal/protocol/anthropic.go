@@ -39,18 +39,15 @@ func (c *AnthropicConverter) ParseRequest(rawBody []byte, model string) (*adapte
 	}
 
 	// 设置可选参数
-	if anthropicReq.Temperature != nil {
-		req.Temperature = *anthropicReq.Temperature
-	}
-	if anthropicReq.TopP != nil {
-		req.TopP = *anthropicReq.TopP
-	}
+	req.Temperature = anthropicReq.Temperature
+	req.TopP = anthropicReq.TopP
 	if anthropicReq.TopK != nil {
 		req.TopK = *anthropicReq.TopK
 	}
 	if anthropicReq.Stream != nil {
 		req.Stream = *anthropicReq.Stream
 	}
+	req.Metadata = anthropicReq.Metadata
 
 	// 转换消息
 	messages := make([]adapter.Message, 0, len(anthropicReq.Messages)+1)
@@ -89,9 +86,10 @@ func (c *AnthropicConverter) ParseRequest(rawBody []byte, model string) (*adapte
 		case string:
 			message.Content = content
 		case []interface{}:
-			// 多部分内容，提取文本和工具调用
+			// 多部分内容，提取文本、工具调用和工具结果
 			var textParts []string
 			var toolCalls []adapter.ToolCall
+			var toolResults []adapter.Message
 
 			for _, part := range content {
 				if partMap, ok := part.(map[string]interface{}); ok {
@@ -118,6 +116,14 @@ func (c *AnthropicConverter) ParseRequest(rawBody []byte, model string) (*adapte
 							toolCall.Function.Arguments = string(inputBytes)
 						}
 						toolCalls = append(toolCalls, toolCall)
+					case "tool_result":
+						// 工具结果转换为 tool 消息，按 tool_use_id 与之前的工具调用配对
+						toolUseID, _ := partMap["tool_use_id"].(string)
+						toolResults = append(toolResults, adapter.Message{
+							Role:       "tool",
+							ToolCallID: toolUseID,
+							Content:    anthropicToolResultContent(partMap["content"]),
+						})
 					}
 				}
 			}
@@ -128,6 +134,14 @@ func (c *AnthropicConverter) ParseRequest(rawBody []byte, model string) (*adapte
 			if len(toolCalls) > 0 {
 				message.ToolCalls = toolCalls
 			}
+
+			// 工具结果在前，同一轮中附带的文本作为之后的 user 消息
+			if len(toolResults) > 0 {
+				messages = append(messages, toolResults...)
+				if message.Content == nil {
+					continue
+				}
+			}
 		}
 
 		messages = append(messages, message)
@@ -188,17 +202,7 @@ func (c *AnthropicConverter) FormatResponse(resp *adapter.ChatResponse) (interfa
 	}
 
 	// 映射 finish_reason
-	stopReason := ""
-	switch choice.FinishReason {
-	case "stop":
-		stopReason = "end_turn"
-	case "length":
-		stopReason = "max_tokens"
-	case "tool_calls":
-		stopReason = "tool_use"
-	default:
-		stopReason = choice.FinishReason
-	}
+	stopReason := anthropicStopReason(choice.FinishReason)
 
 	anthropicResp := &AnthropicResponse{
 		ID:         resp.ID,
@@ -217,7 +221,7 @@ func (c *AnthropicConverter) FormatResponse(resp *adapter.ChatResponse) (interfa
 }
 
 // FormatStreamChunk 格式化流式响应块
-// 将 OpenAI SSE 格式转换为 Anthropic SSE 格式
+// 无状态转换只输出增量事件，包含 message_start 和内容块边界的完整事件序列由 NewStreamSession 提供
 func (c *AnthropicConverter) FormatStreamChunk(chunk []byte) ([]byte, error) {
 	// 跳过空行
 	if len(bytes.TrimSpace(chunk)) == 0 {
@@ -308,23 +312,10 @@ func (c *AnthropicConverter) FormatStreamChunk(chunk []byte) ([]byte, error) {
 
 	// 处理 finish_reason
 	if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
-		// 映射 finish_reason
-		stopReason := ""
-		switch finishReason {
-		case "stop":
-			stopReason = "end_turn"
-		case "length":
-			stopReason = "max_tokens"
-		case "tool_calls":
-			stopReason = "tool_use"
-		default:
-			stopReason = finishReason
-		}
-
 		event := map[string]interface{}{
 			"type": "message_delta",
 			"delta": map[string]interface{}{
-				"stop_reason": stopReason,
+				"stop_reason": anthropicStopReason(finishReason),
 			},
 		}
 		eventJSON, _ := json.Marshal(event)
@@ -337,3 +328,40 @@ func (c *AnthropicConverter) FormatStreamChunk(chunk []byte) ([]byte, error) {
 
 	return []byte(""), nil
 }
+
+// anthropicToolResultContent 将 tool_result 的 content 转换为统一格式
+// 字符串原样保留；内容块数组中的文本和 base64 图片转换为 OpenAI 风格的多部分内容
+func anthropicToolResultContent(content interface{}) interface{} {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		if text, ok := content.(string); ok {
+			return text
+		}
+		return ""
+	}
+
+	parts := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch blockMap["type"] {
+		case "text":
+			text, _ := blockMap["text"].(string)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		case "image":
+			source, _ := blockMap["source"].(map[string]interface{})
+			mediaType, _ := source["media_type"].(string)
+			data, _ := source["data"].(string)
+			url, _ := source["url"].(string)
+			if data != "" {
+				url = fmt.Sprintf("data:%s;base64,%s", mediaType, data)
+			}
+			if url != "" {
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": url}})
+			}
+		}
+	}
+	return parts
+}
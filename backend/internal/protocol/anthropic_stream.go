@@ -0,0 +1,180 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// NewStreamSession 创建单个流的转换会话
+func (c *AnthropicConverter) NewStreamSession() StreamSession {
+	return &anthropicStream{toolBlocks: make(map[int]int)}
+}
+
+// anthropicStream 将 OpenAI SSE 数据块转换为 Anthropic 流式事件
+// 事件顺序：message_start → (content_block_start → content_block_delta* → content_block_stop)* → message_delta → message_stop
+// 文本块使用 text_delta，工具调用块以 tool_use 开始，参数分片通过 input_json_delta 逐段下发
+type anthropicStream struct {
+	started      bool
+	finished     bool
+	blockOpen    bool
+	blockIndex   int         // 当前（或下一个）内容块的序号
+	textBlock    bool        // 当前打开的块是否为文本块
+	toolBlocks   map[int]int // OpenAI tool_calls 序号 → 内容块序号
+	finishReason string
+	usage        *adapter.UsageInfo
+}
+
+// FormatStreamChunk 格式化流式响应块
+func (s *anthropicStream) FormatStreamChunk(chunk []byte) ([]byte, error) {
+	line := bytes.TrimSpace(chunk)
+	if !bytes.HasPrefix(line, []byte("data: ")) {
+		return []byte(""), nil
+	}
+	data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data: ")))
+
+	var out bytes.Buffer
+	if string(data) == "[DONE]" {
+		s.finish(&out)
+		return out.Bytes(), nil
+	}
+
+	var openaiChunk struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Choices []struct {
+			Delta struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Index    int    `json:"index"`
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason *string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *adapter.UsageInfo `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &openaiChunk); err != nil {
+		return []byte(""), nil
+	}
+
+	if !s.started {
+		s.start(&out, openaiChunk.ID, openaiChunk.Model)
+	}
+	if openaiChunk.Usage != nil && openaiChunk.Usage.TotalTokens > 0 {
+		s.usage = openaiChunk.Usage
+	}
+	if len(openaiChunk.Choices) == 0 {
+		return out.Bytes(), nil
+	}
+
+	choice := openaiChunk.Choices[0]
+	if choice.Delta.Content != "" {
+		if !s.blockOpen || !s.textBlock {
+			s.openBlock(&out, map[string]interface{}{"type": "text", "text": ""})
+			s.textBlock = true
+		}
+		s.emit(&out, "content_block_delta", map[string]interface{}{
+			"index": s.blockIndex,
+			"delta": map[string]interface{}{"type": "text_delta", "text": choice.Delta.Content},
+		})
+	}
+	for _, tc := range choice.Delta.ToolCalls {
+		index, seen := s.toolBlocks[tc.Index]
+		if !seen {
+			// 新的工具调用：关闭上一个块，以 tool_use 开始新块，参数通过后续的 input_json_delta 下发
+			s.openBlock(&out, map[string]interface{}{
+				"type":  "tool_use",
+				"id":    tc.ID,
+				"name":  tc.Function.Name,
+				"input": map[string]interface{}{},
+			})
+			s.textBlock = false
+			s.toolBlocks[tc.Index] = s.blockIndex
+			index = s.blockIndex
+		}
+		if tc.Function.Arguments == "" {
+			continue
+		}
+		s.emit(&out, "content_block_delta", map[string]interface{}{
+			"index": index,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": tc.Function.Arguments},
+		})
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+		s.closeBlock(&out)
+	}
+
+	return out.Bytes(), nil
+}
+
+// start 输出 message_start 事件
+func (s *anthropicStream) start(out *bytes.Buffer, id, model string) {
+	s.started = true
+	s.emit(out, "message_start", map[string]interface{}{
+		"message": map[string]interface{}{
+			"id":            id,
+			"type":          "message",
+			"role":          "assistant",
+			"content":       []interface{}{},
+			"model":         model,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         AnthropicUsage{},
+		},
+	})
+}
+
+// openBlock 关闭当前块并开始一个新的内容块
+func (s *anthropicStream) openBlock(out *bytes.Buffer, block map[string]interface{}) {
+	if s.blockOpen {
+		s.closeBlock(out)
+	}
+	s.blockOpen = true
+	s.emit(out, "content_block_start", map[string]interface{}{
+		"index":         s.blockIndex,
+		"content_block": block,
+	})
+}
+
+// closeBlock 结束当前打开的内容块
+func (s *anthropicStream) closeBlock(out *bytes.Buffer) {
+	if !s.blockOpen {
+		return
+	}
+	s.emit(out, "content_block_stop", map[string]interface{}{"index": s.blockIndex})
+	s.blockOpen = false
+	s.blockIndex++
+}
+
+// finish 输出 message_delta（停止原因和用量）和 message_stop
+func (s *anthropicStream) finish(out *bytes.Buffer) {
+	if !s.started || s.finished {
+		return
+	}
+	s.finished = true
+	s.closeBlock(out)
+
+	usage := AnthropicUsage{}
+	if s.usage != nil {
+		usage = AnthropicUsage{InputTokens: s.usage.PromptTokens, OutputTokens: s.usage.CompletionTokens}
+	}
+	s.emit(out, "message_delta", map[string]interface{}{
+		"delta": map[string]interface{}{"stop_reason": anthropicStopReason(s.finishReason), "stop_sequence": nil},
+		"usage": usage,
+	})
+	s.emit(out, "message_stop", map[string]interface{}{})
+}
+
+// emit 写入一个 SSE 事件，自动附加 type
+func (s *anthropicStream) emit(out *bytes.Buffer, eventType string, payload map[string]interface{}) {
+	payload["type"] = eventType
+	eventJSON, _ := json.Marshal(payload)
+	fmt.Fprintf(out, "event: %s\ndata: %s\n\n", eventType, eventJSON)
+}
@@ -0,0 +1,151 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// anthropicEvent 解析后的 Anthropic SSE 事件
+type anthropicEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	ContentBlock map[string]interface{} `json:"content_block"`
+	Delta        map[string]interface{} `json:"delta"`
+	Usage        *AnthropicUsage        `json:"usage"`
+}
+
+// runAnthropicStream 将 OpenAI SSE 数据块依次送入同一个流会话，返回解析后的事件
+func runAnthropicStream(t *testing.T, chunks ...string) []anthropicEvent {
+	t.Helper()
+	session := NewAnthropicConverter().NewStreamSession()
+	var events []anthropicEvent
+	for _, chunk := range append(chunks, "[DONE]") {
+		out, err := session.FormatStreamChunk([]byte("data: " + chunk + "\n"))
+		if err != nil {
+			t.Fatalf("FormatStreamChunk: %v", err)
+		}
+		for _, block := range strings.Split(string(out), "\n\n") {
+			lines := strings.Split(block, "\n")
+			if len(lines) < 2 || !strings.HasPrefix(lines[1], "data: ") {
+				continue
+			}
+			var event anthropicEvent
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event); err != nil {
+				t.Fatalf("Invalid event JSON %q: %v", lines[1], err)
+			}
+			if lines[0] != "event: "+event.Type {
+				t.Errorf("Expected the event line to match type %s, got %q", event.Type, lines[0])
+			}
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// reassembleToolInputs 按 Anthropic SDK 的方式拼接各 tool_use 块的 input_json_delta
+func reassembleToolInputs(t *testing.T, events []anthropicEvent) map[string]map[string]interface{} {
+	t.Helper()
+	names := map[int]string{}
+	partial := map[int]string{}
+	open := map[int]bool{}
+	inputs := map[string]map[string]interface{}{}
+	for _, event := range events {
+		switch event.Type {
+		case "content_block_start":
+			open[event.Index] = true
+			if event.ContentBlock["type"] == "tool_use" {
+				names[event.Index], _ = event.ContentBlock["name"].(string)
+			}
+		case "content_block_delta":
+			if !open[event.Index] {
+				t.Fatalf("Delta for block %d outside its start/stop", event.Index)
+			}
+			if event.Delta["type"] == "input_json_delta" {
+				fragment, _ := event.Delta["partial_json"].(string)
+				partial[event.Index] += fragment
+			}
+		case "content_block_stop":
+			open[event.Index] = false
+			if name, ok := names[event.Index]; ok {
+				var input map[string]interface{}
+				if err := json.Unmarshal([]byte(partial[event.Index]), &input); err != nil {
+					t.Fatalf("Tool %s input %q is not valid JSON: %v", name, partial[event.Index], err)
+				}
+				inputs[name] = input
+			}
+		}
+	}
+	return inputs
+}
+
+func TestAnthropicStream_ReassemblesToolInput(t *testing.T) {
+	events := runAnthropicStream(t,
+		`{"id":"chatcmpl-1","model":"gpt-4","choices":[{"index":0,"delta":{"role":"assistant","content":"Checking."}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\",\"days\":3}"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{\"tz\":"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"CET\"}"}}]}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`{"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":8,"total_tokens":20}}`,
+	)
+
+	inputs := reassembleToolInputs(t, events)
+	want := map[string]map[string]interface{}{
+		"weather": {"city": "Paris", "days": float64(3)},
+		"time":    {"tz": "CET"},
+	}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Errorf("Expected tool inputs %v, got %v", want, inputs)
+	}
+
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	got := strings.Join(types, ",")
+	wantOrder := "message_start," +
+		"content_block_start,content_block_delta,content_block_stop," +
+		"content_block_start,content_block_delta,content_block_delta,content_block_stop," +
+		"content_block_start,content_block_delta,content_block_delta,content_block_stop," +
+		"message_delta,message_stop"
+	if got != wantOrder {
+		t.Errorf("Unexpected event sequence:\n got %s\nwant %s", got, wantOrder)
+	}
+
+	// 块序号依次递增，工具块带有调用 ID
+	if events[4].Index != 1 || events[4].ContentBlock["id"] != "call_1" || events[8].Index != 2 || events[8].ContentBlock["id"] != "call_2" {
+		t.Errorf("Expected tool_use blocks 1 and 2 with their call IDs, got %+v / %+v", events[4], events[8])
+	}
+	last := events[len(events)-2]
+	if last.Delta["stop_reason"] != "tool_use" || last.Usage == nil || last.Usage.InputTokens != 12 || last.Usage.OutputTokens != 8 {
+		t.Errorf("Expected stop_reason tool_use with usage 12/8, got %+v", last)
+	}
+}
+
+func TestAnthropicStream_TextOnly(t *testing.T) {
+	events := runAnthropicStream(t,
+		`{"id":"chatcmpl-2","model":"gpt-4","choices":[{"index":0,"delta":{"content":"Hel"}}]}`,
+		`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+		`{"id":"chatcmpl-2","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+
+	text := ""
+	starts := 0
+	for _, event := range events {
+		if event.Type == "content_block_start" {
+			starts++
+		}
+		if event.Type == "content_block_delta" && event.Delta["type"] == "text_delta" {
+			text += event.Delta["text"].(string)
+		}
+	}
+	if text != "Hello" || starts != 1 {
+		t.Errorf("Expected one text block with Hello, got %q in %d blocks", text, starts)
+	}
+	if stop := events[len(events)-2]; stop.Type != "message_delta" || stop.Delta["stop_reason"] != "end_turn" {
+		t.Errorf("Expected message_delta with end_turn, got %+v", stop)
+	}
+}
@@ -0,0 +1,166 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// DecodeLimits 读取代理请求体时边读边检查的限制，零值表示不限制
+type DecodeLimits struct {
+	MaxMessages           int  // 消息数组最多元素数
+	DisallowUnknownFields bool // 顶层出现协议和网关扩展参数都不认识的字段时拒绝
+}
+
+// messageFields 各协议的消息数组字段
+var messageFields = map[Protocol]string{
+	ProtocolOpenAI:    "messages",
+	ProtocolAnthropic: "messages",
+	ProtocolGemini:    "contents",
+	ProtocolResponses: "input",
+}
+
+// gatewayRequestFields 网关自有的请求体扩展参数，所有协议都接受
+var gatewayRequestFields = []string{"max_cost", "models", "auto_continue", "max_continuations", "candidates"}
+
+// requestTypes 各协议解析请求体使用的结构体，其 JSON 字段都是已知字段
+var requestTypes = map[Protocol]reflect.Type{
+	ProtocolOpenAI:    reflect.TypeOf(adapter.ChatRequest{}),
+	ProtocolAnthropic: reflect.TypeOf(AnthropicRequest{}),
+	ProtocolGemini:    reflect.TypeOf(GeminiRequest{}),
+	ProtocolResponses: reflect.TypeOf(ResponsesRequest{}),
+}
+
+// knownFields 协议请求体的已知顶层字段：解析结构体的 JSON 字段、schema 字段和网关扩展参数
+var knownFields = func() map[Protocol]map[string]bool {
+	fields := make(map[Protocol]map[string]bool, len(requestTypes))
+	for proto, t := range requestTypes {
+		known := make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name != "" && name != "-" {
+				known[name] = true
+			}
+		}
+		if s, ok := requestSchemas[proto]; ok {
+			for name := range s.props {
+				known[name] = true
+			}
+		}
+		for _, name := range gatewayRequestFields {
+			known[name] = true
+		}
+		fields[proto] = known
+	}
+	return fields
+}()
+
+// ReadRequestBody 边读边检查请求体：消息数超出上限或出现未知字段时立即返回 ValidationError，不再读取剩余内容，
+// 避免为病态请求缓冲整个请求体；未超限时返回完整请求体，JSON 本身的语法错误留给 schema 校验和解析报告
+func ReadRequestBody(proto Protocol, r io.Reader, limits DecodeLimits) ([]byte, *ValidationError, error) {
+	if limits.MaxMessages <= 0 && !limits.DisallowUnknownFields {
+		body, err := io.ReadAll(r)
+		return body, nil, err
+	}
+
+	var buf bytes.Buffer
+	scanner := &bodyScanner{dec: json.NewDecoder(io.TeeReader(r, &buf)), proto: proto, limits: limits}
+	if verr := scanner.scan(); verr != nil {
+		return nil, verr, nil
+	}
+	// 解码器停下后剩余的内容（结尾空白或语法错误之后的部分）原样保留
+	_, err := io.Copy(&buf, r)
+	return buf.Bytes(), nil, err
+}
+
+// bodyScanner 逐个 token 扫描请求体顶层对象
+type bodyScanner struct {
+	dec    *json.Decoder
+	proto  Protocol
+	limits DecodeLimits
+}
+
+// scan 只检查限制；遇到语法错误或非对象请求体时停止扫描，不视为违反限制
+func (s *bodyScanner) scan() *ValidationError {
+	if tok, err := s.dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	for s.dec.More() {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, _ := tok.(string)
+		if s.limits.DisallowUnknownFields && !knownFields[s.proto][key] {
+			return &ValidationError{Path: []interface{}{key}, Message: "unknown field"}
+		}
+
+		var verr *ValidationError
+		if key == messageFields[s.proto] && s.limits.MaxMessages > 0 {
+			verr, err = s.countMessages(key)
+		} else {
+			err = s.skipValue()
+		}
+		if verr != nil || err != nil {
+			return verr
+		}
+	}
+	return nil
+}
+
+// countMessages 逐条跳过消息数组的元素，超出上限时立即返回
+func (s *bodyScanner) countMessages(key string) (*ValidationError, error) {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		// 非数组（如 Responses 的字符串 input）交给 schema 校验
+		return nil, s.skipRest(tok)
+	}
+	count := 0
+	for s.dec.More() {
+		count++
+		if count > s.limits.MaxMessages {
+			return &ValidationError{Path: []interface{}{key}, Message: fmt.Sprintf("must contain at most %d item(s)", s.limits.MaxMessages)}, nil
+		}
+		if err := s.skipValue(); err != nil {
+			return nil, err
+		}
+	}
+	_, err = s.dec.Token()
+	return nil, err
+}
+
+// skipValue 跳过一个完整的 JSON 值，不构造中间对象
+func (s *bodyScanner) skipValue() error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	return s.skipRest(tok)
+}
+
+// skipRest 已读到值的第一个 token，跳过该值剩余部分
+func (s *bodyScanner) skipRest(tok json.Token) error {
+	if tok != json.Delim('{') && tok != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := s.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// endlessMessages 不断输出消息的请求体，并记录被读取的字节数
+type endlessMessages struct {
+	started bool
+	read    int
+}
+
+func (r *endlessMessages) Read(p []byte) (int, error) {
+	chunk := `{"role":"user","content":"hi"},`
+	if !r.started {
+		r.started = true
+		chunk = `{"model":"gpt-4o","messages":[` + chunk
+	}
+	n := copy(p, chunk)
+	r.read += n
+	return n, nil
+}
+
+func TestReadRequestBody_RejectsTooManyMessagesWhileDecoding(t *testing.T) {
+	body := &endlessMessages{}
+	_, verr, err := ReadRequestBody(ProtocolOpenAI, body, DecodeLimits{MaxMessages: 100})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if verr == nil || verr.FieldPath(ProtocolOpenAI) != "messages" || verr.Message != "must contain at most 100 item(s)" {
+		t.Fatalf("Expected messages limit error, got %+v", verr)
+	}
+	// 请求体没有尽头，能返回说明在超限时就停止了读取
+	if body.read > 101*64 {
+		t.Errorf("Expected reading to stop near the limit, read %d bytes", body.read)
+	}
+}
+
+func TestReadRequestBody_PassesNormalBodyUnchanged(t *testing.T) {
+	bodies := map[Protocol]string{
+		ProtocolOpenAI:    `{"model":"gpt-4o","messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}],"max_cost":0.1}` + "\n",
+		ProtocolGemini:    `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"systemInstruction":{"parts":[{"text":"be brief"}]}}`,
+		ProtocolResponses: `{"model":"gpt-4o","input":"hi"}`,
+		ProtocolAnthropic: `{"model":"claude","messages":[{"role":"user","content":"hi"}],"max_tokens":16`, // 语法错误留给 schema 校验
+	}
+	for proto, body := range bodies {
+		got, verr, err := ReadRequestBody(proto, strings.NewReader(body), DecodeLimits{MaxMessages: 1, DisallowUnknownFields: true})
+		if err != nil || verr != nil {
+			t.Fatalf("%s: unexpected rejection %v %v", proto, verr, err)
+		}
+		if string(got) != body {
+			t.Errorf("%s: expected body unchanged, got %q", proto, got)
+		}
+	}
+}
+
+func TestReadRequestBody_DisallowUnknownFields(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[],"temprature":0.5}`
+	_, verr, _ := ReadRequestBody(ProtocolOpenAI, strings.NewReader(body), DecodeLimits{DisallowUnknownFields: true})
+	if verr == nil || verr.FieldPath(ProtocolOpenAI) != "temprature" || verr.Message != "unknown field" {
+		t.Errorf("Expected unknown field error, got %+v", verr)
+	}
+
+	// 未开启时接受未知字段
+	got, verr, _ := ReadRequestBody(ProtocolOpenAI, strings.NewReader(body), DecodeLimits{MaxMessages: 10})
+	if verr != nil || string(got) != body {
+		t.Errorf("Expected unknown fields accepted by default, got %+v %q", verr, got)
+	}
+}
+
+func TestReadRequestBody_NoLimitsReadsAll(t *testing.T) {
+	body := strings.Repeat(" ", 10) + `{"messages":[{},{}]}`
+	got, verr, err := ReadRequestBody(ProtocolOpenAI, io.NopCloser(strings.NewReader(body)), DecodeLimits{})
+	if err != nil || verr != nil || string(got) != body {
+		t.Errorf("Expected body read as-is without limits, got %q %v %v", got, verr, err)
+	}
+}
@@ -0,0 +1,144 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// APIError 与协议无关的错误描述，按协议格式或自定义模板渲染为响应体
+type APIError struct {
+	Status  int     // HTTP 状态码
+	Message string  // 错误信息
+	Param   *string // 出错的请求字段，只在 OpenAI 格式中输出
+}
+
+// openAIErrorBody OpenAI（含 Responses）错误格式
+type openAIErrorBody struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Param   *string     `json:"param"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// anthropicErrorBody Anthropic 错误格式
+type anthropicErrorBody struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// geminiErrorBody Gemini 错误格式
+type geminiErrorBody struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// ErrorBody 按协议构造错误响应体，错误类型由 HTTP 状态码推导
+func ErrorBody(proto Protocol, err *APIError) interface{} {
+	switch proto {
+	case ProtocolAnthropic:
+		var body anthropicErrorBody
+		body.Type = "error"
+		body.Error.Type = anthropicErrorType(err.Status)
+		body.Error.Message = err.Message
+		return body
+	case ProtocolGemini:
+		var body geminiErrorBody
+		body.Error.Code = err.Status
+		body.Error.Message = err.Message
+		body.Error.Status = geminiErrorStatus(err.Status)
+		return body
+	default:
+		var body openAIErrorBody
+		body.Error.Message = err.Message
+		body.Error.Type = openAIErrorType(err.Status)
+		body.Error.Param = err.Param
+		return body
+	}
+}
+
+// openAIErrorType OpenAI 错误类型
+func openAIErrorType(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "authentication_error"
+	case status == http.StatusForbidden:
+		return "permission_error"
+	case status == http.StatusNotFound:
+		return "not_found_error"
+	case status == http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case status >= 500:
+		return "api_error"
+	}
+	return "invalid_request_error"
+}
+
+// anthropicErrorType Anthropic 错误类型
+func anthropicErrorType(status int) string {
+	switch {
+	case status == http.StatusServiceUnavailable:
+		return "overloaded_error"
+	case status >= 500:
+		return "api_error"
+	}
+	return openAIErrorType(status)
+}
+
+// geminiErrorStatus Gemini（Google RPC）错误状态
+func geminiErrorStatus(status int) string {
+	switch {
+	case status == http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case status == http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case status == http.StatusNotFound:
+		return "NOT_FOUND"
+	case status == http.StatusConflict:
+		return "ABORTED"
+	case status == http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case status == http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case status >= 500:
+		return "INTERNAL"
+	}
+	return "INVALID_ARGUMENT"
+}
+
+// RenderErrorTemplate 用错误替换自定义模板中的占位符，结果必须是合法 JSON
+// 支持 {{message}}、{{type}}（OpenAI 错误类型）和 {{status}}（HTTP 状态码），
+// 字符串占位符按 JSON 字符串转义，模板中需自行加引号
+func RenderErrorTemplate(tmpl string, err *APIError) ([]byte, error) {
+	rendered := strings.NewReplacer(
+		"{{message}}", jsonEscape(err.Message),
+		"{{type}}", jsonEscape(openAIErrorType(err.Status)),
+		"{{status}}", strconv.Itoa(err.Status),
+	).Replace(tmpl)
+	if !json.Valid([]byte(rendered)) {
+		return nil, fmt.Errorf("error template does not render valid JSON")
+	}
+	return []byte(rendered), nil
+}
+
+// ValidateErrorTemplate 用示例错误渲染模板，检查结果是否为合法 JSON
+func ValidateErrorTemplate(tmpl string) error {
+	_, err := RenderErrorTemplate(tmpl, &APIError{Status: http.StatusBadRequest, Message: `sample "message"`})
+	return err
+}
+
+// jsonEscape 返回 JSON 字符串转义后的内容（不含两侧引号）
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
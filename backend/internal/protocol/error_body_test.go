@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestErrorBody_DerivesTypeFromStatus(t *testing.T) {
+	apiErr := &APIError{Status: http.StatusServiceUnavailable, Message: "busy"}
+
+	tests := []struct {
+		proto Protocol
+		want  string
+	}{
+		{ProtocolOpenAI, `{"error":{"message":"busy","type":"api_error","param":null,"code":null}}`},
+		{ProtocolAnthropic, `{"type":"error","error":{"type":"overloaded_error","message":"busy"}}`},
+		{ProtocolGemini, `{"error":{"code":503,"message":"busy","status":"UNAVAILABLE"}}`},
+	}
+	for _, tt := range tests {
+		data, _ := json.Marshal(ErrorBody(tt.proto, apiErr))
+		if string(data) != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.proto, data, tt.want)
+		}
+	}
+}
+
+func TestRenderErrorTemplate_EscapesMessage(t *testing.T) {
+	body, err := RenderErrorTemplate(`{"msg":"{{message}}","status":{{status}}}`,
+		&APIError{Status: http.StatusBadRequest, Message: `bad "input"` + "\n"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var got struct {
+		Msg    string `json:"msg"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil || got.Msg != "bad \"input\"\n" || got.Status != 400 {
+		t.Errorf("Expected the message escaped into valid JSON, got %s (%v)", body, err)
+	}
+
+	if err := ValidateErrorTemplate(`{"msg":{{message}}}`); err == nil {
+		t.Error("Expected a template that renders invalid JSON to be rejected")
+	}
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import "api-aggregator/backend/internal/adapter"
+
+// anthropicStopReason 将统一的 finish_reason 映射为 Anthropic stop_reason
+// 未知取值原样透传
+func anthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case adapter.FinishReasonStop:
+		return "end_turn"
+	case adapter.FinishReasonLength:
+		return "max_tokens"
+	case adapter.FinishReasonToolCalls:
+		return "tool_use"
+	case adapter.FinishReasonContentFilter:
+		return "refusal"
+	default:
+		return finishReason
+	}
+}
+
+// geminiFinishReason 将统一的 finish_reason 映射为 Gemini finishReason（大写）
+func geminiFinishReason(finishReason string) string {
+	switch finishReason {
+	case adapter.FinishReasonStop:
+		return "STOP"
+	case adapter.FinishReasonLength:
+		return "MAX_TOKENS"
+	case adapter.FinishReasonToolCalls:
+		return "STOP" // Gemini 没有专门的 tool_calls finish reason
+	case adapter.FinishReasonContentFilter:
+		return "SAFETY"
+	default:
+		return "OTHER"
+	}
+}
+
+// responsesIncompleteReason 返回 Responses API 的 incomplete_details.reason，正常结束时返回空
+func responsesIncompleteReason(finishReason string) string {
+	switch finishReason {
+	case adapter.FinishReasonLength:
+		return "max_output_tokens"
+	case adapter.FinishReasonContentFilter:
+		return "content_filter"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,143 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func finishResponse(finishReason string) *adapter.ChatResponse {
+	msg := adapter.Message{Role: "assistant", Content: "Once upon"}
+	if finishReason == adapter.FinishReasonToolCalls {
+		msg = adapter.Message{Role: "assistant", ToolCalls: []adapter.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: adapter.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+		}}}
+	}
+	return &adapter.ChatResponse{
+		ID:      "abc",
+		Model:   "test-model",
+		Choices: []adapter.ChatChoice{{Message: msg, FinishReason: finishReason}},
+	}
+}
+
+func finishChunk(finishReason string) []byte {
+	return []byte(`data: {"id":"abc","model":"test-model","choices":[{"index":0,"delta":{},"finish_reason":"` + finishReason + `"}]}` + "\n")
+}
+
+func TestFinishReason_OpenAIPassthrough(t *testing.T) {
+	for _, reason := range []string{adapter.FinishReasonLength, adapter.FinishReasonToolCalls} {
+		out, err := NewOpenAIConverter().FormatResponse(finishResponse(reason))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := out.(*adapter.ChatResponse).Choices[0].FinishReason; got != reason {
+			t.Errorf("Expected %q, got %q", reason, got)
+		}
+	}
+}
+
+func TestFinishReason_Anthropic(t *testing.T) {
+	cases := map[string]string{
+		adapter.FinishReasonLength:    "max_tokens",
+		adapter.FinishReasonToolCalls: "tool_use",
+		adapter.FinishReasonStop:      "end_turn",
+	}
+	for reason, want := range cases {
+		out, err := NewAnthropicConverter().FormatResponse(finishResponse(reason))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := out.(*AnthropicResponse).StopReason; got != want {
+			t.Errorf("%s: expected stop_reason %q, got %q", reason, want, got)
+		}
+
+		chunk, err := NewAnthropicConverter().FormatStreamChunk(finishChunk(reason))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(string(chunk), `"stop_reason":"`+want+`"`) {
+			t.Errorf("%s: expected stream stop_reason %q, got %s", reason, want, chunk)
+		}
+	}
+}
+
+func TestFinishReason_Gemini(t *testing.T) {
+	cases := map[string]string{
+		adapter.FinishReasonLength:    "MAX_TOKENS",
+		adapter.FinishReasonToolCalls: "STOP",
+		adapter.FinishReasonStop:      "STOP",
+	}
+	for reason, want := range cases {
+		out, err := NewGeminiConverter().FormatResponse(finishResponse(reason))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := out.(*GeminiResponse).Candidates[0].FinishReason; got != want {
+			t.Errorf("%s: expected finishReason %q, got %q", reason, want, got)
+		}
+
+		chunk, err := NewGeminiConverter().FormatStreamChunk(finishChunk(reason))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !strings.Contains(string(chunk), `"finishReason":"`+want+`"`) {
+			t.Errorf("%s: expected stream finishReason %q, got %s", reason, want, chunk)
+		}
+	}
+}
+
+func TestFinishReason_Responses(t *testing.T) {
+	out, err := NewResponsesConverter().FormatResponse(finishResponse(adapter.FinishReasonLength))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	r := out.(*ResponsesResponse)
+	if r.Status != "incomplete" || r.IncompleteDetails == nil || r.IncompleteDetails.Reason != "max_output_tokens" {
+		t.Errorf("Expected incomplete max_output_tokens, got %+v", r)
+	}
+
+	out, err = NewResponsesConverter().FormatResponse(finishResponse(adapter.FinishReasonToolCalls))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	r = out.(*ResponsesResponse)
+	if r.Status != "completed" || r.IncompleteDetails != nil {
+		t.Errorf("Expected completed response for tool calls, got %+v", r)
+	}
+	hasCall := false
+	for _, item := range r.Output {
+		if item.Type == "function_call" {
+			hasCall = true
+		}
+	}
+	if !hasCall {
+		t.Errorf("Expected function_call output item, got %+v", r.Output)
+	}
+
+	session := NewResponsesConverter().NewStreamSession()
+	var stream strings.Builder
+	for _, chunk := range [][]byte{finishChunk(adapter.FinishReasonLength), []byte("data: [DONE]\n")} {
+		formatted, err := session.FormatStreamChunk(chunk)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		stream.Write(formatted)
+	}
+	var incomplete map[string]interface{}
+	for _, event := range strings.Split(strings.TrimSpace(stream.String()), "\n\n") {
+		lines := strings.SplitN(event, "\n", 2)
+		if lines[0] == "event: response.incomplete" {
+			json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &incomplete)
+		}
+	}
+	if incomplete == nil {
+		t.Fatalf("Expected response.incomplete event, got %s", stream.String())
+	}
+	details := incomplete["response"].(map[string]interface{})["incomplete_details"].(map[string]interface{})
+	if details["reason"] != "max_output_tokens" {
+		t.Errorf("Expected max_output_tokens, got %v", details)
+	}
+}
@@ -2,7 +2,6 @@ package protocol
 
 import (
 	"api-aggregator/backend/internal/adapter"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -34,12 +33,8 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 
 	// 处理 generation config
 	if geminiReq.GenerationConfig != nil {
-		if geminiReq.GenerationConfig.Temperature != nil {
-			req.Temperature = *geminiReq.GenerationConfig.Temperature
-		}
-		if geminiReq.GenerationConfig.TopP != nil {
-			req.TopP = *geminiReq.GenerationConfig.TopP
-		}
+		req.Temperature = geminiReq.GenerationConfig.Temperature
+		req.TopP = geminiReq.GenerationConfig.TopP
 		if geminiReq.GenerationConfig.TopK != nil {
 			req.TopK = *geminiReq.GenerationConfig.TopK
 		}
@@ -51,23 +46,28 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 	// 转换消息
 	messages := make([]adapter.Message, 0, len(geminiReq.Contents)+1)
 
-	// 添加系统指令
-	if geminiReq.SystemInstruction != nil {
-		var systemText strings.Builder
-		for _, part := range geminiReq.SystemInstruction.Parts {
+	// 添加系统指令，多个文本 part 按行拼接为一条 system 消息
+	systemInstruction := geminiReq.SystemInstruction
+	if systemInstruction == nil {
+		systemInstruction = geminiReq.SystemInstructionSnake
+	}
+	if systemInstruction != nil {
+		texts := make([]string, 0, len(systemInstruction.Parts))
+		for _, part := range systemInstruction.Parts {
 			if part.Text != "" {
-				systemText.WriteString(part.Text)
+				texts = append(texts, part.Text)
 			}
 		}
-		if systemText.Len() > 0 {
+		if len(texts) > 0 {
 			messages = append(messages, adapter.Message{
 				Role:    "system",
-				Content: systemText.String(),
+				Content: strings.Join(texts, "\n"),
 			})
 		}
 	}
 
 	// 转换内容
+	callIDs := newGeminiCallIDs()
 	for _, content := range geminiReq.Contents {
 		role := content.Role
 		// Gemini 使用 "model" 作为助手角色
@@ -77,6 +77,7 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 
 		var textBuilder strings.Builder
 		var toolCalls []adapter.ToolCall
+		var toolResults []adapter.Message
 
 		for _, part := range content.Parts {
 			if part.Text != "" {
@@ -87,7 +88,7 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 			if part.FunctionCall != nil {
 				argsBytes, _ := json.Marshal(part.FunctionCall.Args)
 				toolCalls = append(toolCalls, adapter.ToolCall{
-					ID:   fmt.Sprintf("call_%s", part.FunctionCall.Name),
+					ID:   callIDs.call(part.FunctionCall),
 					Type: "function",
 					Function: adapter.FunctionCall{
 						Name:      part.FunctionCall.Name,
@@ -95,7 +96,19 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 					},
 				})
 			}
+
+			// 函数响应转换为 tool 消息，按函数名依次配对之前的函数调用
+			if part.FunctionResponse != nil {
+				responseBytes, _ := json.Marshal(part.FunctionResponse.Response)
+				toolResults = append(toolResults, adapter.Message{
+					Role:       "tool",
+					Name:       part.FunctionResponse.Name,
+					ToolCallID: callIDs.response(part.FunctionResponse),
+					Content:    string(responseBytes),
+				})
+			}
 		}
+		messages = append(messages, toolResults...)
 
 		message := adapter.Message{
 			Role:    role,
@@ -130,6 +143,9 @@ func (c *GeminiConverter) ParseRequest(rawBody []byte, model string) (*adapter.C
 		}
 		req.Tools = tools
 	}
+	if geminiReq.ToolConfig != nil {
+		req.ToolChoice = geminiToolChoice(geminiReq.ToolConfig.FunctionCallingConfig)
+	}
 
 	return req, nil
 }
@@ -154,32 +170,12 @@ func (c *GeminiConverter) FormatResponse(resp *adapter.ChatResponse) (interface{
 	// 添加函数调用
 	if len(choice.Message.ToolCalls) > 0 {
 		for _, toolCall := range choice.Message.ToolCalls {
-			var args map[string]interface{}
-			json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-
-			parts = append(parts, GeminiPart{
-				FunctionCall: &GeminiFunctionCall{
-					Name: toolCall.Function.Name,
-					Args: args,
-				},
-			})
+			parts = append(parts, geminiFunctionCallPart(toolCall))
 		}
 	}
 
 	// 映射 finish_reason（Gemini 使用大写）
-	finishReason := ""
-	switch choice.FinishReason {
-	case "stop":
-		finishReason = "STOP"
-	case "length":
-		finishReason = "MAX_TOKENS"
-	case "tool_calls":
-		finishReason = "STOP" // Gemini 没有专门的 tool_calls finish reason
-	case "content_filter":
-		finishReason = "SAFETY"
-	default:
-		finishReason = "OTHER"
-	}
+	finishReason := geminiFinishReason(choice.FinishReason)
 
 	geminiResp := &GeminiResponse{
 		Candidates: []GeminiCandidate{
@@ -202,132 +198,18 @@ func (c *GeminiConverter) FormatResponse(resp *adapter.ChatResponse) (interface{
 	return geminiResp, nil
 }
 
-// FormatStreamChunk 格式化流式响应块
-// 将 OpenAI SSE 格式转换为 Gemini 流式格式（纯 JSON，非 SSE）
+// FormatStreamChunk 无状态转换逐块输出，分片的工具调用参数由 NewStreamSession 合并
 func (c *GeminiConverter) FormatStreamChunk(chunk []byte) ([]byte, error) {
-	// 跳过空行
-	if len(bytes.TrimSpace(chunk)) == 0 {
-		return []byte(""), nil
-	}
-
-	// 解析 OpenAI SSE 格式
-	line := string(chunk)
-
-	// 处理 data: 行
-	if !strings.HasPrefix(line, "data: ") {
-		return []byte(""), nil
-	}
-
-	data := strings.TrimPrefix(line, "data: ")
-	data = strings.TrimSpace(data)
-
-	// 处理 [DONE] 标记
-	if data == "[DONE]" {
-		// Gemini 流式结束不需要特殊标记
-		return []byte(""), nil
-	}
-
-	// 解析 JSON
-	var openaiChunk map[string]interface{}
-	if err := json.Unmarshal([]byte(data), &openaiChunk); err != nil {
-		return []byte(""), nil // 解析失败，返回空
-	}
-
-	// 提取 delta 内容
-	choices, ok := openaiChunk["choices"].([]interface{})
-	if !ok || len(choices) == 0 {
-		return []byte(""), nil
-	}
-
-	choice, ok := choices[0].(map[string]interface{})
-	if !ok {
-		return []byte(""), nil
-	}
-
-	delta, ok := choice["delta"].(map[string]interface{})
-	if !ok {
-		return []byte(""), nil
-	}
-
-	// 转换为 Gemini 格式
-	parts := []GeminiPart{}
-
-	// 处理文本内容
-	if content, ok := delta["content"].(string); ok && content != "" {
-		parts = append(parts, GeminiPart{
-			Text: content,
-		})
+	stream := &geminiStream{
+		flushEachChunk: true,
+		toolCalls:      make(map[int]*adapter.ToolCall),
 	}
+	return stream.FormatStreamChunk(chunk)
+}
 
-	// 处理工具调用
-	if toolCalls, ok := delta["tool_calls"].([]interface{}); ok && len(toolCalls) > 0 {
-		for _, tc := range toolCalls {
-			toolCall, ok := tc.(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			function, ok := toolCall["function"].(map[string]interface{})
-			if !ok {
-				continue
-			}
-
-			name, _ := function["name"].(string)
-			argsStr, _ := function["arguments"].(string)
-			
-			var args map[string]interface{}
-			if argsStr != "" {
-				json.Unmarshal([]byte(argsStr), &args)
-			}
-
-			parts = append(parts, GeminiPart{
-				FunctionCall: &GeminiFunctionCall{
-					Name: name,
-					Args: args,
-				},
-			})
-		}
-	}
-
-	// 如果有内容，构建 Gemini 响应
-	if len(parts) > 0 {
-		geminiChunk := map[string]interface{}{
-			"candidates": []map[string]interface{}{
-				{
-					"content": map[string]interface{}{
-						"parts": parts,
-						"role":  "model",
-					},
-					"index": 0,
-				},
-			},
-		}
-
-		// 处理 finish_reason
-		if finishReason, ok := choice["finish_reason"].(string); ok && finishReason != "" {
-			// 映射 finish_reason（Gemini 使用大写）
-			mappedReason := ""
-			switch finishReason {
-			case "stop":
-				mappedReason = "STOP"
-			case "length":
-				mappedReason = "MAX_TOKENS"
-			case "content_filter":
-				mappedReason = "SAFETY"
-			default:
-				mappedReason = "OTHER"
-			}
-			geminiChunk["candidates"].([]map[string]interface{})[0]["finishReason"] = mappedReason
-		}
-
-		chunkJSON, err := json.Marshal(geminiChunk)
-		if err != nil {
-			return []byte(""), nil
-		}
-
-		// Gemini 流式响应是纯 JSON，每行一个对象，不使用 SSE 格式
-		return append(chunkJSON, '\n'), nil
+// NewStreamSession 创建单个流的转换会话
+func (c *GeminiConverter) NewStreamSession() StreamSession {
+	return &geminiStream{
+		toolCalls: make(map[int]*adapter.ToolCall),
 	}
-
-	return []byte(""), nil
 }
@@ -0,0 +1,40 @@
+package protocol
+
+import "testing"
+
+func TestGeminiConverter_SystemInstructionBecomesSystemMessage(t *testing.T) {
+	bodies := map[string]string{
+		"camelCase":  `{"systemInstruction":{"parts":[{"text":"You are terse."},{"text":"Answer in French."}]},"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`,
+		"snake_case": `{"system_instruction":{"parts":[{"text":"You are terse."},{"text":"Answer in French."}]},"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`,
+	}
+	for name, body := range bodies {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateRequest(ProtocolGemini, []byte(body)); err != nil {
+				t.Fatalf("Expected request to validate, got %v", err)
+			}
+			req, err := NewGeminiConverter().ParseRequest([]byte(body), "gemini-2.0")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(req.Messages) != 2 {
+				t.Fatalf("Expected system and user messages, got %+v", req.Messages)
+			}
+			if req.Messages[0].Role != "system" || req.Messages[0].Content != "You are terse.\nAnswer in French." {
+				t.Errorf("Expected leading system message from systemInstruction, got %+v", req.Messages[0])
+			}
+			if req.Messages[1].Role != "user" {
+				t.Errorf("Expected user message after system, got %+v", req.Messages[1])
+			}
+		})
+	}
+}
+
+func TestGeminiConverter_WithoutSystemInstruction(t *testing.T) {
+	req, err := NewGeminiConverter().ParseRequest([]byte(`{"contents":[{"role":"user","parts":[{"text":"hi"}]}]}`), "gemini-2.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Role != "user" {
+		t.Errorf("Expected only the user message, got %+v", req.Messages)
+	}
+}
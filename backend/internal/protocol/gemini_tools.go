@@ -0,0 +1,219 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// geminiCallIDs 为 Gemini 函数调用生成统一的工具调用 ID
+// Gemini 的 functionCall/functionResponse 通常不带 ID，按函数名先进先出配对，
+// 同一函数的多次调用也能得到不同的 ID
+type geminiCallIDs struct {
+	count   int
+	pending map[string][]string
+}
+
+func newGeminiCallIDs() *geminiCallIDs {
+	return &geminiCallIDs{pending: make(map[string][]string)}
+}
+
+// call 返回函数调用的 ID，并记录为等待响应
+func (g *geminiCallIDs) call(fc *GeminiFunctionCall) string {
+	id := fc.ID
+	if id == "" {
+		g.count++
+		id = fmt.Sprintf("call_%s_%d", fc.Name, g.count)
+	}
+	g.pending[fc.Name] = append(g.pending[fc.Name], id)
+	return id
+}
+
+// response 返回函数响应对应的调用 ID；找不到对应调用时按函数名生成
+func (g *geminiCallIDs) response(fr *GeminiFunctionResponse) string {
+	queue := g.pending[fr.Name]
+	if fr.ID != "" {
+		for i, id := range queue {
+			if id == fr.ID {
+				g.pending[fr.Name] = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		return fr.ID
+	}
+	if len(queue) == 0 {
+		return fmt.Sprintf("call_%s", fr.Name)
+	}
+	g.pending[fr.Name] = queue[1:]
+	return queue[0]
+}
+
+// geminiToolChoice 将 Gemini functionCallingConfig 映射为统一的 tool_choice
+func geminiToolChoice(cfg *GeminiFunctionCallingConfig) interface{} {
+	if cfg == nil {
+		return nil
+	}
+	switch cfg.Mode {
+	case "AUTO":
+		return "auto"
+	case "NONE":
+		return "none"
+	case "ANY":
+		if len(cfg.AllowedFunctionNames) == 1 {
+			return map[string]interface{}{
+				"type":     "function",
+				"function": map[string]interface{}{"name": cfg.AllowedFunctionNames[0]},
+			}
+		}
+		return "required"
+	default:
+		return nil
+	}
+}
+
+// geminiFunctionCallPart 将统一的工具调用转换为 functionCall 部分
+func geminiFunctionCallPart(toolCall adapter.ToolCall) GeminiPart {
+	var args map[string]interface{}
+	json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	return GeminiPart{
+		FunctionCall: &GeminiFunctionCall{
+			ID:   toolCall.ID,
+			Name: toolCall.Function.Name,
+			Args: args,
+		},
+	}
+}
+
+// geminiStream 将 OpenAI SSE 数据块转换为 Gemini 流式格式（每行一个 JSON 对象，非 SSE）
+// OpenAI 的工具调用参数分多个数据块到达，而 Gemini 的 functionCall 必须是完整对象，
+// 因此工具调用按 index 缓存，在结束块（或流结束）时一次输出；文本增量立即输出
+// 上游已是 Gemini 原生格式的数据块原样透传
+type geminiStream struct {
+	flushEachChunk bool
+	toolCalls      map[int]*adapter.ToolCall
+}
+
+// FormatStreamChunk 格式化流式响应块
+func (s *geminiStream) FormatStreamChunk(chunk []byte) ([]byte, error) {
+	line := bytes.TrimSpace(chunk)
+	if !bytes.HasPrefix(line, []byte("data: ")) {
+		return []byte(""), nil
+	}
+	data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data: ")))
+
+	if string(data) == "[DONE]" {
+		// Gemini 流式结束不需要特殊标记，只输出未结束的工具调用
+		return s.emit(s.flush(), "")
+	}
+
+	var openaiChunk struct {
+		Candidates []json.RawMessage `json:"candidates"`
+		Choices    []struct {
+			Delta struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Index    int    `json:"index"`
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason *string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &openaiChunk); err != nil {
+		return []byte(""), nil
+	}
+	if len(openaiChunk.Candidates) > 0 {
+		return append(data, '\n'), nil
+	}
+	if len(openaiChunk.Choices) == 0 {
+		return []byte(""), nil
+	}
+
+	choice := openaiChunk.Choices[0]
+	var parts []GeminiPart
+	if choice.Delta.Content != "" {
+		parts = append(parts, GeminiPart{Text: choice.Delta.Content})
+	}
+	for _, tc := range choice.Delta.ToolCalls {
+		call, ok := s.toolCalls[tc.Index]
+		if !ok {
+			call = &adapter.ToolCall{Type: "function"}
+			s.toolCalls[tc.Index] = call
+		}
+		if call.ID == "" {
+			call.ID = tc.ID
+		}
+		if call.Function.Name == "" {
+			call.Function.Name = tc.Function.Name
+		}
+		call.Function.Arguments += tc.Function.Arguments
+	}
+
+	// OpenAI 的结束块通常 delta 为空
+	var finishReason string
+	if choice.FinishReason != nil {
+		finishReason = *choice.FinishReason
+	}
+	if finishReason != "" || s.flushEachChunk {
+		parts = append(parts, s.flush()...)
+	}
+	return s.emit(parts, finishReason)
+}
+
+// flush 按 index 顺序输出缓存的工具调用并清空
+func (s *geminiStream) flush() []GeminiPart {
+	if len(s.toolCalls) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(s.toolCalls))
+	for index := range s.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	parts := make([]GeminiPart, 0, len(indexes))
+	for _, index := range indexes {
+		parts = append(parts, geminiFunctionCallPart(*s.toolCalls[index]))
+		delete(s.toolCalls, index)
+	}
+	return parts
+}
+
+// emit 构建 Gemini 流式数据块，没有内容也没有结束原因时不输出
+func (s *geminiStream) emit(parts []GeminiPart, finishReason string) ([]byte, error) {
+	if len(parts) == 0 && finishReason == "" {
+		return []byte(""), nil
+	}
+	if parts == nil {
+		parts = []GeminiPart{}
+	}
+
+	candidate := map[string]interface{}{
+		"content": map[string]interface{}{
+			"parts": parts,
+			"role":  "model",
+		},
+		"index": 0,
+	}
+	if finishReason != "" {
+		// 映射 finish_reason（Gemini 使用大写）
+		candidate["finishReason"] = geminiFinishReason(finishReason)
+	}
+
+	chunkJSON, err := json.Marshal(map[string]interface{}{
+		"candidates": []map[string]interface{}{candidate},
+	})
+	if err != nil {
+		return []byte(""), nil
+	}
+	return append(chunkJSON, '\n'), nil
+}
@@ -0,0 +1,197 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGeminiConverter_ParsesToolUseRequest(t *testing.T) {
+	body := `{
+		"contents": [
+			{"role": "user", "parts": [{"text": "weather in Paris and Rome?"}]},
+			{"role": "model", "parts": [
+				{"functionCall": {"name": "weather", "args": {"city": "Paris"}}},
+				{"functionCall": {"name": "weather", "args": {"city": "Rome"}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"name": "weather", "response": {"forecast": "Sunny"}}},
+				{"functionResponse": {"name": "weather", "response": {"forecast": "Rain"}}}
+			]}
+		],
+		"tools": [{"functionDeclarations": [{"name": "weather", "description": "Get weather", "parameters": {"type": "object"}}]}],
+		"toolConfig": {"functionCallingConfig": {"mode": "ANY", "allowedFunctionNames": ["weather"]}}
+	}`
+
+	req, err := NewGeminiConverter().ParseRequest([]byte(body), "gemini-2.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "weather" {
+		t.Errorf("Expected function declaration as a unified tool, got %+v", req.Tools)
+	}
+	choice, ok := req.ToolChoice.(map[string]interface{})
+	if !ok || choice["function"].(map[string]interface{})["name"] != "weather" {
+		t.Errorf("Expected forced function tool_choice, got %#v", req.ToolChoice)
+	}
+
+	if len(req.Messages) != 4 {
+		t.Fatalf("Expected user, assistant, 2 tool messages, got %+v", req.Messages)
+	}
+	calls := req.Messages[1].ToolCalls
+	if len(calls) != 2 || calls[0].ID == calls[1].ID {
+		t.Fatalf("Expected two tool calls with distinct IDs, got %+v", calls)
+	}
+	if calls[1].Function.Name != "weather" || calls[1].Function.Arguments != `{"city":"Rome"}` {
+		t.Errorf("Unexpected tool call: %+v", calls[1])
+	}
+	// 同名函数的响应按调用顺序配对
+	if req.Messages[2].ToolCallID != calls[0].ID || req.Messages[3].ToolCallID != calls[1].ID {
+		t.Errorf("Expected responses paired in call order, got %q and %q", req.Messages[2].ToolCallID, req.Messages[3].ToolCallID)
+	}
+	if req.Messages[3].Content != `{"forecast":"Rain"}` {
+		t.Errorf("Unexpected tool result: %+v", req.Messages[3])
+	}
+	if err := adapter.ValidateToolPairing(req.Messages); err != nil {
+		t.Errorf("Expected tool calls and responses to pair, got %v", err)
+	}
+}
+
+func TestGeminiConverter_PairsByExplicitID(t *testing.T) {
+	body := `{
+		"contents": [
+			{"role": "model", "parts": [
+				{"functionCall": {"id": "a", "name": "lookup", "args": {}}},
+				{"functionCall": {"id": "b", "name": "lookup", "args": {}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"id": "b", "name": "lookup", "response": {}}},
+				{"functionResponse": {"name": "lookup", "response": {}}}
+			]}
+		]
+	}`
+
+	req, err := NewGeminiConverter().ParseRequest([]byte(body), "gemini-2.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(req.Messages) != 3 || req.Messages[1].ToolCallID != "b" || req.Messages[2].ToolCallID != "a" {
+		t.Errorf("Expected explicit ID first and the remaining call second, got %+v", req.Messages)
+	}
+}
+
+func TestGeminiToolChoice(t *testing.T) {
+	cases := map[string]interface{}{"AUTO": "auto", "NONE": "none", "ANY": "required", "": nil}
+	for mode, want := range cases {
+		if got := geminiToolChoice(&GeminiFunctionCallingConfig{Mode: mode}); got != want {
+			t.Errorf("mode %q: expected %v, got %v", mode, want, got)
+		}
+	}
+}
+
+func TestGeminiConverter_FormatsToolCalls(t *testing.T) {
+	resp := &adapter.ChatResponse{
+		Choices: []adapter.ChatChoice{{
+			Message: adapter.Message{
+				Role: "assistant",
+				ToolCalls: []adapter.ToolCall{{
+					ID:       "call_1",
+					Type:     "function",
+					Function: adapter.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+				}},
+			},
+			FinishReason: adapter.FinishReasonToolCalls,
+		}},
+	}
+
+	formatted, err := NewGeminiConverter().FormatResponse(resp)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	parts := formatted.(*GeminiResponse).Candidates[0].Content.Parts
+	if len(parts) != 1 || parts[0].FunctionCall == nil {
+		t.Fatalf("Expected a functionCall part, got %+v", parts)
+	}
+	if fc := parts[0].FunctionCall; fc.Name != "weather" || fc.Args["city"] != "Paris" || fc.ID != "call_1" {
+		t.Errorf("Unexpected function call: %+v", fc)
+	}
+}
+
+// geminiStreamParts 解析 Gemini 流式输出的所有部分和最后的结束原因
+func geminiStreamParts(t *testing.T, session StreamSession, chunks []string) ([]GeminiPart, string) {
+	t.Helper()
+	var parts []GeminiPart
+	var finishReason string
+	for _, chunk := range chunks {
+		formatted, err := session.FormatStreamChunk([]byte(chunk + "\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(formatted)), "\n") {
+			if line == "" {
+				continue
+			}
+			var resp GeminiResponse
+			if err := json.Unmarshal([]byte(line), &resp); err != nil {
+				t.Fatalf("Expected a JSON line, got %q", line)
+			}
+			parts = append(parts, resp.Candidates[0].Content.Parts...)
+			if resp.Candidates[0].FinishReason != "" {
+				finishReason = resp.Candidates[0].FinishReason
+			}
+		}
+	}
+	return parts, finishReason
+}
+
+func TestGeminiStream_ReassemblesToolCallFragments(t *testing.T) {
+	chunks := []string{
+		`data: {"choices":[{"index":0,"delta":{"content":"Checking"}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`data: [DONE]`,
+	}
+
+	parts, finishReason := geminiStreamParts(t, NewGeminiConverter().NewStreamSession(), chunks)
+	if len(parts) != 3 || parts[0].Text != "Checking" {
+		t.Fatalf("Expected text then two function calls, got %+v", parts)
+	}
+	first, second := parts[1].FunctionCall, parts[2].FunctionCall
+	if first == nil || first.Name != "weather" || first.Args["city"] != "Paris" || first.ID != "call_1" {
+		t.Errorf("Expected reassembled weather call, got %+v", first)
+	}
+	if second == nil || second.Name != "time" {
+		t.Errorf("Expected time call, got %+v", second)
+	}
+	if finishReason != "STOP" {
+		t.Errorf("Expected STOP, got %q", finishReason)
+	}
+}
+
+func TestGeminiStream_FlushesToolCallsOnDone(t *testing.T) {
+	chunks := []string{
+		`data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"weather","arguments":"{\"city\":\"Rome\"}"}}]}}]}`,
+		`data: [DONE]`,
+	}
+
+	parts, _ := geminiStreamParts(t, NewGeminiConverter().NewStreamSession(), chunks)
+	if len(parts) != 1 || parts[0].FunctionCall == nil || parts[0].FunctionCall.Args["city"] != "Rome" {
+		t.Errorf("Expected pending call flushed at end of stream, got %+v", parts)
+	}
+}
+
+func TestGeminiStream_PassesNativeChunksThrough(t *testing.T) {
+	native := `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"weather","args":{"city":"Oslo"}}}],"role":"model"},"finishReason":"STOP","index":0}]}`
+
+	formatted, err := NewGeminiConverter().NewStreamSession().FormatStreamChunk([]byte("data: " + native + "\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(formatted) != native+"\n" {
+		t.Errorf("Expected native chunk passed through, got %q", formatted)
+	}
+}
@@ -19,13 +19,23 @@ func (c *OpenAIConverter) GetProtocol() Protocol {
 	return ProtocolOpenAI
 }
 
+// openAIRequest OpenAI 请求中统一格式之外的字段
+type openAIRequest struct {
+	adapter.ChatRequest
+	MaxCompletionTokens int `json:"max_completion_tokens"`
+}
+
 // ParseRequest 解析 OpenAI 请求为统一格式
-// OpenAI 格式就是内部统一格式，直接解析即可
+// OpenAI 格式就是内部统一格式，直接解析即可；max_completion_tokens 优先于 max_tokens，统一记为 max_tokens
 func (c *OpenAIConverter) ParseRequest(rawBody []byte, model string) (*adapter.ChatRequest, error) {
-	var req adapter.ChatRequest
-	if err := json.Unmarshal(rawBody, &req); err != nil {
+	var parsed openAIRequest
+	if err := json.Unmarshal(rawBody, &parsed); err != nil {
 		return nil, fmt.Errorf("failed to parse openai request: %w", err)
 	}
+	req := parsed.ChatRequest
+	if parsed.MaxCompletionTokens > 0 {
+		req.MaxTokens = parsed.MaxCompletionTokens
+	}
 
 	// 如果提供了 model 参数，覆盖请求中的 model
 	if model != "" {
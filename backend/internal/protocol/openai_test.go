@@ -0,0 +1,22 @@
+package protocol
+
+import "testing"
+
+func TestOpenAIConverter_ParseRequestPrefersMaxCompletionTokens(t *testing.T) {
+	for name, tc := range map[string]struct {
+		body string
+		want int
+	}{
+		"max_completion_tokens only": {`{"model":"o3","messages":[],"max_completion_tokens":300}`, 300},
+		"both fields":                {`{"model":"o3","messages":[],"max_tokens":100,"max_completion_tokens":300}`, 300},
+		"max_tokens only":            {`{"model":"gpt-4o","messages":[],"max_tokens":100}`, 100},
+	} {
+		req, err := NewOpenAIConverter().ParseRequest([]byte(tc.body), "")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if req.MaxTokens != tc.want {
+			t.Errorf("%s: expected an output limit of %d, got %d", name, tc.want, req.MaxTokens)
+		}
+	}
+}
@@ -11,6 +11,7 @@ const (
 	ProtocolOpenAI    Protocol = "openai"
 	ProtocolAnthropic Protocol = "anthropic"
 	ProtocolGemini    Protocol = "gemini"
+	ProtocolResponses Protocol = "responses"
 )
 
 // Converter 协议转换器接口
@@ -36,6 +37,18 @@ type Converter interface {
 	FormatStreamChunk(chunk []byte) ([]byte, error)
 }
 
+// StreamSession 单个流的有状态转换器
+type StreamSession interface {
+	// FormatStreamChunk 格式化流式响应块（可依赖同一流中之前的数据块）
+	FormatStreamChunk(chunk []byte) ([]byte, error)
+}
+
+// StreamSessionProvider 需要跨数据块保持状态的转换器实现此接口
+// 每个流调用一次 NewStreamSession，替代无状态的 Converter.FormatStreamChunk
+type StreamSessionProvider interface {
+	NewStreamSession() StreamSession
+}
+
 // ConverterFactory 转换器工厂
 type ConverterFactory struct {
 	converters map[Protocol]Converter
@@ -51,6 +64,7 @@ func NewConverterFactory() *ConverterFactory {
 	factory.Register(NewOpenAIConverter())
 	factory.Register(NewAnthropicConverter())
 	factory.Register(NewGeminiConverter())
+	factory.Register(NewResponsesConverter())
 
 	return factory
 }
@@ -0,0 +1,477 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ResponsesConverter OpenAI Responses API (/v1/responses) 协议转换器
+type ResponsesConverter struct{}
+
+// NewResponsesConverter 创建 Responses 转换器
+func NewResponsesConverter() *ResponsesConverter {
+	return &ResponsesConverter{}
+}
+
+// GetProtocol 返回协议类型
+func (c *ResponsesConverter) GetProtocol() Protocol {
+	return ProtocolResponses
+}
+
+// ParseRequest 解析 Responses 请求为统一格式
+// instructions 映射为 system 消息，input 映射为消息列表
+func (c *ResponsesConverter) ParseRequest(rawBody []byte, model string) (*adapter.ChatRequest, error) {
+	var respReq ResponsesRequest
+	if err := json.Unmarshal(rawBody, &respReq); err != nil {
+		return nil, fmt.Errorf("failed to parse responses request: %w", err)
+	}
+
+	req := &adapter.ChatRequest{
+		Model:      respReq.Model,
+		ToolChoice: respReq.ToolChoice,
+		User:       respReq.User,
+		Metadata:   respReq.Metadata,
+
+		PromptCacheKey:   respReq.PromptCacheKey,
+		SafetyIdentifier: respReq.SafetyIdentifier,
+	}
+	if model != "" {
+		req.Model = model
+	}
+	if respReq.MaxOutputTokens != nil {
+		req.MaxTokens = *respReq.MaxOutputTokens
+	}
+	req.Temperature = respReq.Temperature
+	req.TopP = respReq.TopP
+	if respReq.Reasoning != nil {
+		req.ReasoningEffort = respReq.Reasoning.Effort
+	}
+	if respReq.Stream != nil {
+		req.Stream = *respReq.Stream
+	}
+
+	messages := make([]adapter.Message, 0)
+	if respReq.Instructions != "" {
+		messages = append(messages, adapter.Message{
+			Role:    "system",
+			Content: respReq.Instructions,
+		})
+	}
+
+	switch input := respReq.Input.(type) {
+	case string:
+		messages = append(messages, adapter.Message{Role: "user", Content: input})
+	case []interface{}:
+		raw, _ := json.Marshal(input)
+		var items []ResponsesInputItem
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, fmt.Errorf("failed to parse responses input: %w", err)
+		}
+		messages = appendResponsesInput(messages, items)
+	case nil:
+	default:
+		return nil, fmt.Errorf("unsupported responses input type %T", input)
+	}
+	req.Messages = messages
+
+	// 转换工具（Responses 的函数工具为扁平结构）
+	if len(respReq.Tools) > 0 {
+		tools := make([]adapter.Tool, 0, len(respReq.Tools))
+		for _, tool := range respReq.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			tools = append(tools, adapter.Tool{
+				Type: "function",
+				Function: adapter.ToolFunction{
+					Name:        tool.Name,
+					Description: tool.Description,
+					Parameters:  tool.Parameters,
+				},
+			})
+		}
+		req.Tools = tools
+	}
+
+	return req, nil
+}
+
+// appendResponsesInput 将 Responses 输入项转换为统一消息
+func appendResponsesInput(messages []adapter.Message, items []ResponsesInputItem) []adapter.Message {
+	for _, item := range items {
+		switch item.Type {
+		case "function_call":
+			toolCall := adapter.ToolCall{
+				ID:   item.CallID,
+				Type: "function",
+				Function: adapter.FunctionCall{
+					Name:      item.Name,
+					Arguments: item.Arguments,
+				},
+			}
+			// 连续的函数调用合并到同一条助手消息
+			if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+				messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, toolCall)
+				continue
+			}
+			messages = append(messages, adapter.Message{
+				Role:      "assistant",
+				ToolCalls: []adapter.ToolCall{toolCall},
+			})
+		case "function_call_output":
+			messages = append(messages, adapter.Message{
+				Role:       "tool",
+				Content:    item.Output,
+				ToolCallID: item.CallID,
+			})
+		default:
+			role := item.Role
+			if role == "" {
+				role = "user"
+			}
+			if role == "developer" {
+				role = "system"
+			}
+			messages = append(messages, adapter.Message{
+				Role:    role,
+				Content: convertResponsesContent(item.Content),
+			})
+		}
+	}
+	return messages
+}
+
+// convertResponsesContent 转换消息内容，纯文本合并为字符串，包含图片时保留多部分格式
+func convertResponsesContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+
+	var texts []string
+	var blocks []interface{}
+	hasImage := false
+	for _, part := range parts {
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch partMap["type"] {
+		case "input_text", "output_text", "text":
+			text, _ := partMap["text"].(string)
+			texts = append(texts, text)
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+		case "input_image":
+			if url, ok := partMap["image_url"].(string); ok && url != "" {
+				hasImage = true
+				blocks = append(blocks, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": url},
+				})
+			}
+		}
+	}
+
+	if hasImage {
+		return blocks
+	}
+	return strings.Join(texts, "\n")
+}
+
+// FormatResponse 将统一响应格式化为 Responses 格式
+func (c *ResponsesConverter) FormatResponse(resp *adapter.ChatResponse) (interface{}, error) {
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in response")
+	}
+
+	choice := resp.Choices[0]
+	createdAt := resp.Created
+	if createdAt == 0 {
+		createdAt = time.Now().Unix()
+	}
+
+	output := make([]ResponsesOutputItem, 0, 1+len(choice.Message.ToolCalls))
+	if text := adapter.GetContentAsString(choice.Message.Content); text != "" {
+		output = append(output, responsesMessageItem("msg_"+resp.ID, text))
+	}
+	for _, toolCall := range choice.Message.ToolCalls {
+		output = append(output, responsesFunctionCallItem(toolCall.ID, toolCall.Function.Name, toolCall.Function.Arguments))
+	}
+
+	result := &ResponsesResponse{
+		ID:        "resp_" + resp.ID,
+		Object:    "response",
+		CreatedAt: createdAt,
+		Status:    "completed",
+		Model:     resp.Model,
+		Output:    output,
+		Usage: &ResponsesUsage{
+			InputTokens:  resp.Usage.PromptTokens,
+			OutputTokens: resp.Usage.CompletionTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+	}
+	if reason := responsesIncompleteReason(choice.FinishReason); reason != "" {
+		result.Status = "incomplete"
+		result.IncompleteDetails = &ResponsesIncomplete{Reason: reason}
+	}
+
+	return result, nil
+}
+
+// FormatStreamChunk 无状态转换仅输出文本增量，完整事件序列由 NewStreamSession 提供
+func (c *ResponsesConverter) FormatStreamChunk(chunk []byte) ([]byte, error) {
+	stream := &responsesStream{
+		started:   true,
+		finished:  true,
+		toolCalls: make(map[int]*adapter.ToolCall),
+	}
+	return stream.FormatStreamChunk(chunk)
+}
+
+// NewStreamSession 创建单个流的转换会话
+func (c *ResponsesConverter) NewStreamSession() StreamSession {
+	return &responsesStream{
+		toolCalls: make(map[int]*adapter.ToolCall),
+	}
+}
+
+func responsesMessageItem(id, text string) ResponsesOutputItem {
+	return ResponsesOutputItem{
+		Type:   "message",
+		ID:     id,
+		Status: "completed",
+		Role:   "assistant",
+		Content: []ResponsesContent{
+			{Type: "output_text", Text: text, Annotations: []interface{}{}},
+		},
+	}
+}
+
+func responsesFunctionCallItem(callID, name, arguments string) ResponsesOutputItem {
+	return ResponsesOutputItem{
+		Type:      "function_call",
+		ID:        "fc_" + callID,
+		Status:    "completed",
+		CallID:    callID,
+		Name:      name,
+		Arguments: arguments,
+	}
+}
+
+// responsesStream 将 OpenAI SSE 数据块转换为 Responses 流式事件
+// 事件顺序：response.created → output_item.added → content_part.added →
+// output_text.delta* → output_text.done → content_part.done → output_item.done → response.completed
+type responsesStream struct {
+	started      bool
+	finished     bool
+	sequence     int
+	id           string
+	model        string
+	createdAt    int64
+	text         strings.Builder
+	toolCalls    map[int]*adapter.ToolCall
+	finishReason string
+	usage        *ResponsesUsage
+}
+
+// FormatStreamChunk 格式化流式响应块
+func (s *responsesStream) FormatStreamChunk(chunk []byte) ([]byte, error) {
+	line := bytes.TrimSpace(chunk)
+	if !bytes.HasPrefix(line, []byte("data: ")) {
+		return []byte(""), nil
+	}
+	data := bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data: ")))
+
+	var out bytes.Buffer
+	if string(data) == "[DONE]" {
+		s.finish(&out)
+		return out.Bytes(), nil
+	}
+
+	var openaiChunk struct {
+		ID      string `json:"id"`
+		Model   string `json:"model"`
+		Created int64  `json:"created"`
+		Choices []struct {
+			Delta struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Index    int    `json:"index"`
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason *string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *adapter.UsageInfo `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &openaiChunk); err != nil {
+		return []byte(""), nil
+	}
+
+	if !s.started {
+		s.start(&out, openaiChunk.ID, openaiChunk.Model, openaiChunk.Created)
+	}
+	if openaiChunk.Usage != nil && openaiChunk.Usage.TotalTokens > 0 {
+		s.usage = &ResponsesUsage{
+			InputTokens:  openaiChunk.Usage.PromptTokens,
+			OutputTokens: openaiChunk.Usage.CompletionTokens,
+			TotalTokens:  openaiChunk.Usage.TotalTokens,
+		}
+	}
+	if len(openaiChunk.Choices) == 0 {
+		return out.Bytes(), nil
+	}
+
+	choice := openaiChunk.Choices[0]
+	if choice.Delta.Content != "" {
+		s.text.WriteString(choice.Delta.Content)
+		s.emit(&out, "response.output_text.delta", map[string]interface{}{
+			"item_id":       s.messageID(),
+			"output_index":  0,
+			"content_index": 0,
+			"delta":         choice.Delta.Content,
+		})
+	}
+	for _, tc := range choice.Delta.ToolCalls {
+		call, ok := s.toolCalls[tc.Index]
+		if !ok {
+			call = &adapter.ToolCall{Type: "function"}
+			s.toolCalls[tc.Index] = call
+		}
+		if tc.ID != "" {
+			call.ID = tc.ID
+		}
+		if tc.Function.Name != "" {
+			call.Function.Name = tc.Function.Name
+		}
+		call.Function.Arguments += tc.Function.Arguments
+	}
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		s.finishReason = *choice.FinishReason
+	}
+
+	return out.Bytes(), nil
+}
+
+// start 输出响应创建及消息项开始事件
+func (s *responsesStream) start(out *bytes.Buffer, id, model string, createdAt int64) {
+	s.started = true
+	s.id = "resp_" + id
+	s.model = model
+	s.createdAt = createdAt
+	if s.createdAt == 0 {
+		s.createdAt = time.Now().Unix()
+	}
+
+	s.emit(out, "response.created", map[string]interface{}{
+		"response": s.snapshot("in_progress", []ResponsesOutputItem{}),
+	})
+	s.emit(out, "response.output_item.added", map[string]interface{}{
+		"output_index": 0,
+		"item": ResponsesOutputItem{
+			Type:    "message",
+			ID:      s.messageID(),
+			Status:  "in_progress",
+			Role:    "assistant",
+			Content: []ResponsesContent{},
+		},
+	})
+	s.emit(out, "response.content_part.added", map[string]interface{}{
+		"item_id":       s.messageID(),
+		"output_index":  0,
+		"content_index": 0,
+		"part":          ResponsesContent{Type: "output_text", Text: "", Annotations: []interface{}{}},
+	})
+}
+
+// finish 输出结束事件及包含完整输出和用量的 response.completed
+func (s *responsesStream) finish(out *bytes.Buffer) {
+	if !s.started || s.finished {
+		return
+	}
+	s.finished = true
+
+	text := s.text.String()
+	message := responsesMessageItem(s.messageID(), text)
+	s.emit(out, "response.output_text.done", map[string]interface{}{
+		"item_id":       s.messageID(),
+		"output_index":  0,
+		"content_index": 0,
+		"text":          text,
+	})
+	s.emit(out, "response.content_part.done", map[string]interface{}{
+		"item_id":       s.messageID(),
+		"output_index":  0,
+		"content_index": 0,
+		"part":          message.Content[0],
+	})
+	s.emit(out, "response.output_item.done", map[string]interface{}{
+		"output_index": 0,
+		"item":         message,
+	})
+
+	output := []ResponsesOutputItem{message}
+	indexes := make([]int, 0, len(s.toolCalls))
+	for index := range s.toolCalls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		call := s.toolCalls[index]
+		item := responsesFunctionCallItem(call.ID, call.Function.Name, call.Function.Arguments)
+		s.emit(out, "response.output_item.done", map[string]interface{}{
+			"output_index": len(output),
+			"item":         item,
+		})
+		output = append(output, item)
+	}
+
+	status := "completed"
+	if responsesIncompleteReason(s.finishReason) != "" {
+		status = "incomplete"
+	}
+	s.emit(out, "response."+status, map[string]interface{}{
+		"response": s.snapshot(status, output),
+	})
+}
+
+// snapshot 构建当前响应对象
+func (s *responsesStream) snapshot(status string, output []ResponsesOutputItem) *ResponsesResponse {
+	resp := &ResponsesResponse{
+		ID:        s.id,
+		Object:    "response",
+		CreatedAt: s.createdAt,
+		Status:    status,
+		Model:     s.model,
+		Output:    output,
+		Usage:     s.usage,
+	}
+	if status == "incomplete" {
+		resp.IncompleteDetails = &ResponsesIncomplete{Reason: responsesIncompleteReason(s.finishReason)}
+	}
+	return resp
+}
+
+func (s *responsesStream) messageID() string {
+	return "msg_" + strings.TrimPrefix(s.id, "resp_")
+}
+
+// emit 写入一个 SSE 事件，自动附加 type 和 sequence_number
+func (s *responsesStream) emit(out *bytes.Buffer, eventType string, payload map[string]interface{}) {
+	payload["type"] = eventType
+	payload["sequence_number"] = s.sequence
+	s.sequence++
+
+	eventJSON, _ := json.Marshal(payload)
+	fmt.Fprintf(out, "event: %s\ndata: %s\n\n", eventType, eventJSON)
+}
@@ -0,0 +1,150 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestResponsesConverter_ParseRequest(t *testing.T) {
+	body := `{
+		"model": "gpt-4o",
+		"instructions": "Be brief.",
+		"max_output_tokens": 64,
+		"input": [
+			{"role": "user", "content": [{"type": "input_text", "text": "What is the weather?"}]},
+			{"type": "function_call", "call_id": "call_1", "name": "weather", "arguments": "{\"city\":\"Paris\"}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "Sunny"}
+		],
+		"tools": [{"type": "function", "name": "weather", "parameters": {"type": "object"}}]
+	}`
+
+	req, err := NewResponsesConverter().ParseRequest([]byte(body), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if req.Model != "gpt-4o" || req.MaxTokens != 64 {
+		t.Errorf("Unexpected model/max_tokens: %s/%d", req.Model, req.MaxTokens)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(req.Messages))
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].Content != "Be brief." {
+		t.Errorf("Expected instructions as system message, got %+v", req.Messages[0])
+	}
+	if req.Messages[1].Role != "user" || req.Messages[1].Content != "What is the weather?" {
+		t.Errorf("Unexpected user message: %+v", req.Messages[1])
+	}
+	if len(req.Messages[2].ToolCalls) != 1 || req.Messages[2].ToolCalls[0].Function.Name != "weather" {
+		t.Errorf("Expected assistant tool call, got %+v", req.Messages[2])
+	}
+	if req.Messages[3].Role != "tool" || req.Messages[3].ToolCallID != "call_1" {
+		t.Errorf("Expected tool result, got %+v", req.Messages[3])
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "weather" {
+		t.Errorf("Expected weather tool, got %+v", req.Tools)
+	}
+}
+
+func TestResponsesConverter_ParseRequest_StringInput(t *testing.T) {
+	req, err := NewResponsesConverter().ParseRequest([]byte(`{"model":"gpt-4o","input":"Hello","stream":true}`), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !req.Stream || len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "Hello" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+}
+
+func TestResponsesConverter_FormatResponse(t *testing.T) {
+	resp := &adapter.ChatResponse{
+		ID:    "abc",
+		Model: "gpt-4o",
+		Choices: []adapter.ChatChoice{{
+			Message:      adapter.Message{Role: "assistant", Content: "Hi there"},
+			FinishReason: "stop",
+		}},
+		Usage: adapter.UsageInfo{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+	}
+
+	out, err := NewResponsesConverter().FormatResponse(resp)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	r := out.(*ResponsesResponse)
+	if r.Object != "response" || r.Status != "completed" || r.ID != "resp_abc" {
+		t.Errorf("Unexpected response envelope: %+v", r)
+	}
+	if len(r.Output) != 1 || r.Output[0].Content[0].Type != "output_text" || r.Output[0].Content[0].Text != "Hi there" {
+		t.Errorf("Unexpected output: %+v", r.Output)
+	}
+	if r.Usage.InputTokens != 3 || r.Usage.OutputTokens != 2 || r.Usage.TotalTokens != 5 {
+		t.Errorf("Unexpected usage: %+v", r.Usage)
+	}
+}
+
+func TestResponsesStream_EventSequence(t *testing.T) {
+	session := NewResponsesConverter().NewStreamSession()
+	chunks := []string{
+		`data: {"id":"abc","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+		`data: {"id":"abc","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+		`data: {"id":"abc","model":"gpt-4o","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`,
+		`data: [DONE]`,
+	}
+
+	var out strings.Builder
+	for _, chunk := range chunks {
+		formatted, err := session.FormatStreamChunk([]byte(chunk + "\n"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		out.Write(formatted)
+	}
+
+	var types []string
+	var completed map[string]interface{}
+	for _, event := range strings.Split(strings.TrimSpace(out.String()), "\n\n") {
+		lines := strings.SplitN(event, "\n", 2)
+		var payload map[string]interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload); err != nil {
+			t.Fatalf("Invalid event data %q: %v", lines[1], err)
+		}
+		if strings.TrimPrefix(lines[0], "event: ") != payload["type"] {
+			t.Errorf("Event name %q does not match type %v", lines[0], payload["type"])
+		}
+		if int(payload["sequence_number"].(float64)) != len(types) {
+			t.Errorf("Unexpected sequence number %v at %d", payload["sequence_number"], len(types))
+		}
+		types = append(types, payload["type"].(string))
+		if payload["type"] == "response.completed" {
+			completed = payload["response"].(map[string]interface{})
+		}
+	}
+
+	expected := []string{
+		"response.created",
+		"response.output_item.added",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done",
+		"response.completed",
+	}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Unexpected event sequence:\n got %v\nwant %v", types, expected)
+	}
+
+	output := completed["output"].([]interface{})[0].(map[string]interface{})
+	text := output["content"].([]interface{})[0].(map[string]interface{})["text"]
+	if text != "Hello" {
+		t.Errorf("Expected completed text Hello, got %v", text)
+	}
+	usage := completed["usage"].(map[string]interface{})
+	if usage["total_tokens"] != float64(5) {
+		t.Errorf("Expected usage total 5, got %v", usage)
+	}
+}
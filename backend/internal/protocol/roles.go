@@ -0,0 +1,115 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// protocolRoleNames 通用角色在各协议中的名称，别名先映射为通用角色（user、assistant、system）再按协议改名
+var protocolRoleNames = map[Protocol]map[string]string{
+	ProtocolGemini: {"assistant": "model"},
+}
+
+// roleField 返回协议请求体中带角色的消息数组字段名及允许的角色，取自请求 schema
+func roleField(proto Protocol) (string, []string) {
+	s, ok := requestSchemas[proto]
+	if !ok {
+		return "", nil
+	}
+	for _, field := range []string{"messages", "contents"} {
+		if list, ok := s.props[field]; ok && list.items != nil {
+			if role, ok := list.items.props["role"]; ok && len(role.enum) > 0 {
+				return field, role.enum
+			}
+		}
+	}
+	return "", nil
+}
+
+// NormalizeRoles 按别名把请求体中的非标准角色名（如 human、ai）改写为协议的标准角色，角色名不区分大小写
+// 既不是标准角色也没有别名的角色返回 ValidationError；没有需要改写的角色时原样返回请求体，
+// 请求体结构不符合预期时同样原样返回，交给 schema 校验和协议解析报错
+func NormalizeRoles(proto Protocol, rawBody []byte, aliases map[string]string) ([]byte, *ValidationError) {
+	field, allowed := roleField(proto)
+	if field == "" {
+		return rawBody, nil
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return rawBody, nil
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(body[field], &items); err != nil {
+		return rawBody, nil
+	}
+
+	changed := false
+	for i, item := range items {
+		var role string
+		if err := json.Unmarshal(item["role"], &role); err != nil {
+			continue
+		}
+		normalized, ok := normalizeRole(proto, role, allowed, aliases)
+		if !ok {
+			return nil, &ValidationError{
+				Path:    []interface{}{field, i, "role"},
+				Message: fmt.Sprintf("must be one of: %s, got %q", strings.Join(allowed, ", "), role),
+			}
+		}
+		if normalized != role {
+			item["role"], _ = json.Marshal(normalized)
+			changed = true
+		}
+	}
+	if !changed {
+		return rawBody, nil
+	}
+
+	encoded, err := marshalRaw(items)
+	if err != nil {
+		return rawBody, nil
+	}
+	body[field] = encoded
+	if encoded, err = marshalRaw(body); err != nil {
+		return rawBody, nil
+	}
+	return encoded, nil
+}
+
+// normalizeRole 返回角色在协议中的标准名称，无法识别时返回 false
+func normalizeRole(proto Protocol, role string, allowed []string, aliases map[string]string) (string, bool) {
+	isAllowed := func(r string) bool {
+		for _, a := range allowed {
+			if a == r {
+				return true
+			}
+		}
+		return false
+	}
+	if isAllowed(role) {
+		return role, true
+	}
+
+	lower := strings.ToLower(role)
+	if target, ok := aliases[lower]; ok {
+		lower = target
+	}
+	if name, ok := protocolRoleNames[proto][lower]; ok {
+		lower = name
+	}
+	return lower, isAllowed(lower)
+}
+
+// marshalRaw 编码时不转义 HTML 字符，保持消息内容原样
+func marshalRaw(v interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
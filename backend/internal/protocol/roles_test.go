@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"testing"
+)
+
+var testRoleAliases = map[string]string{"human": "user", "ai": "assistant", "bot": "assistant"}
+
+func TestNormalizeRoles_MapsAliasesToStandardRoles(t *testing.T) {
+	tests := []struct {
+		proto Protocol
+		body  string
+		want  string
+	}{
+		{ProtocolOpenAI,
+			`{"model":"gpt-4o","messages":[{"role":"human","content":"<b>hi</b>"},{"role":"AI","content":"hello"},{"role":"user","content":"bye"}]}`,
+			`{"messages":[{"content":"<b>hi</b>","role":"user"},{"content":"hello","role":"assistant"},{"content":"bye","role":"user"}],"model":"gpt-4o"}`},
+		{ProtocolAnthropic,
+			`{"model":"claude","messages":[{"role":"Human","content":"hi"},{"role":"bot","content":"hello"}]}`,
+			`{"messages":[{"content":"hi","role":"user"},{"content":"hello","role":"assistant"}],"model":"claude"}`},
+		// Gemini 的助手角色名为 model
+		{ProtocolGemini,
+			`{"contents":[{"role":"human","parts":[{"text":"hi"}]},{"role":"ai","parts":[{"text":"hello"}]},{"parts":[{"text":"again"}]}]}`,
+			`{"contents":[{"parts":[{"text":"hi"}],"role":"user"},{"parts":[{"text":"hello"}],"role":"model"},{"parts":[{"text":"again"}]}]}`},
+	}
+	for _, tt := range tests {
+		got, verr := NormalizeRoles(tt.proto, []byte(tt.body), testRoleAliases)
+		if verr != nil {
+			t.Fatalf("%s: unexpected error %v", tt.proto, verr)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.proto, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRoles_KeepsStandardBodyUntouched(t *testing.T) {
+	body := `{"model":"gpt-4o",  "messages":[{"role":"user","content":"hi"}]}`
+	got, verr := NormalizeRoles(ProtocolOpenAI, []byte(body), testRoleAliases)
+	if verr != nil || string(got) != body {
+		t.Errorf("Expected a body with standard roles returned as is, got %s (%v)", got, verr)
+	}
+}
+
+func TestNormalizeRoles_RejectsUnknownRole(t *testing.T) {
+	body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"},{"role":"wizard","content":"hello"}]}`
+	_, verr := NormalizeRoles(ProtocolOpenAI, []byte(body), testRoleAliases)
+	if verr == nil {
+		t.Fatal("Expected an unknown role to be rejected")
+	}
+	if path := verr.FieldPath(ProtocolOpenAI); path != "messages[1].role" {
+		t.Errorf("Expected the error to point at messages[1].role, got %q", path)
+	}
+
+	// 别名映射到协议不允许的角色同样拒绝
+	if _, verr := NormalizeRoles(ProtocolAnthropic, []byte(`{"messages":[{"role":"sys","content":"x"}]}`), map[string]string{"sys": "system"}); verr == nil {
+		t.Error("Expected an alias to a role the protocol does not accept to be rejected")
+	}
+}
@@ -0,0 +1,67 @@
+package protocol
+
+import (
+	"api-aggregator/backend/internal/adapter"
+	"testing"
+)
+
+func TestAnthropicConverter_ParsesToolResults(t *testing.T) {
+	body := `{
+		"model": "claude-3",
+		"max_tokens": 64,
+		"messages": [
+			{"role": "user", "content": "weather in Paris?"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "weather", "input": {"city": "Paris"}}]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "Sunny"}]},
+				{"type": "text", "text": "answer briefly"}
+			]}
+		]
+	}`
+
+	req, err := NewAnthropicConverter().ParseRequest([]byte(body), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(req.Messages) != 4 {
+		t.Fatalf("Expected user, assistant, tool, user messages, got %+v", req.Messages)
+	}
+	result := req.Messages[2]
+	if result.Role != "tool" || result.ToolCallID != "toolu_1" {
+		t.Fatalf("Expected tool result paired with toolu_1, got %+v", result)
+	}
+	if text := adapter.GetContentAsString(result.Content); text != "Sunny" {
+		t.Errorf("Expected tool result text, got %q", text)
+	}
+	if req.Messages[3].Role != "user" || req.Messages[3].Content != "answer briefly" {
+		t.Errorf("Expected trailing text as a user message, got %+v", req.Messages[3])
+	}
+	if err := adapter.ValidateToolPairing(req.Messages); err != nil {
+		t.Errorf("Expected parsed continuation to pair, got %v", err)
+	}
+}
+
+func TestGeminiConverter_ParsesFunctionResponses(t *testing.T) {
+	body := `{
+		"contents": [
+			{"role": "user", "parts": [{"text": "weather in Paris?"}]},
+			{"role": "model", "parts": [{"functionCall": {"name": "weather", "args": {"city": "Paris"}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"name": "weather", "response": {"forecast": "Sunny"}}}]}
+		]
+	}`
+
+	req, err := NewGeminiConverter().ParseRequest([]byte(body), "gemini-2.0")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(req.Messages) != 3 {
+		t.Fatalf("Expected user, assistant, tool messages, got %+v", req.Messages)
+	}
+	result := req.Messages[2]
+	if result.Role != "tool" || result.Name != "weather" || result.Content != `{"forecast":"Sunny"}` {
+		t.Errorf("Unexpected tool result: %+v", result)
+	}
+	if err := adapter.ValidateToolPairing(req.Messages); err != nil {
+		t.Errorf("Expected function response to pair with the function call, got %v", err)
+	}
+}
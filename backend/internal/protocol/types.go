@@ -68,6 +68,10 @@ type GeminiRequest struct {
 	Tools             []GeminiToolDeclaration `json:"tools,omitempty"`
 	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
 	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	ToolConfig        *GeminiToolConfig       `json:"toolConfig,omitempty"`
+
+	// SystemInstructionSnake Gemini REST 同样接受 snake_case 写法，两者都有时以 systemInstruction 为准
+	SystemInstructionSnake *GeminiContent `json:"system_instruction,omitempty"`
 }
 
 type GeminiContent struct {
@@ -87,12 +91,16 @@ type GeminiInlineData struct {
 	Data     string `json:"data"`
 }
 
+// GeminiFunctionCall 函数调用，ID 仅在客户端或上游提供时存在
 type GeminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
 	Name string                 `json:"name"`
 	Args map[string]interface{} `json:"args"`
 }
 
+// GeminiFunctionResponse 函数响应，ID 与对应函数调用一致
 type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
 	Name     string                 `json:"name"`
 	Response map[string]interface{} `json:"response"`
 }
@@ -107,6 +115,16 @@ type GeminiFunctionDeclaration struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+// GeminiToolConfig 函数调用配置
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"` // AUTO, ANY, NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+}
+
 type GeminiSafetySetting struct {
 	Category  string `json:"category"`
 	Threshold string `json:"threshold"`
@@ -137,3 +155,85 @@ type GeminiUsage struct {
 	CandidatesTokenCount int `json:"candidatesTokenCount"`
 	TotalTokenCount      int `json:"totalTokenCount"`
 }
+
+// ResponsesRequest OpenAI Responses API 请求格式
+type ResponsesRequest struct {
+	Model           string                 `json:"model"`
+	Input           interface{}            `json:"input"` // string or []ResponsesInputItem
+	Instructions    string                 `json:"instructions,omitempty"`
+	MaxOutputTokens *int                   `json:"max_output_tokens,omitempty"`
+	Temperature     *float64               `json:"temperature,omitempty"`
+	TopP            *float64               `json:"top_p,omitempty"`
+	Stream          *bool                  `json:"stream,omitempty"`
+	Tools           []ResponsesTool        `json:"tools,omitempty"`
+	ToolChoice      interface{}            `json:"tool_choice,omitempty"`
+	User            string                 `json:"user,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+	Reasoning       *ResponsesReasoning    `json:"reasoning,omitempty"`
+
+	PromptCacheKey   string `json:"prompt_cache_key,omitempty"`
+	SafetyIdentifier string `json:"safety_identifier,omitempty"`
+}
+
+// ResponsesReasoning 推理模型配置
+type ResponsesReasoning struct {
+	Effort string `json:"effort,omitempty"`
+}
+
+// ResponsesInputItem 输入项：消息、函数调用或函数调用结果
+type ResponsesInputItem struct {
+	Type      string      `json:"type,omitempty"` // message, function_call, function_call_output
+	Role      string      `json:"role,omitempty"`
+	Content   interface{} `json:"content,omitempty"` // string or []ResponsesContent
+	CallID    string      `json:"call_id,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	Arguments string      `json:"arguments,omitempty"`
+	Output    string      `json:"output,omitempty"`
+}
+
+type ResponsesTool struct {
+	Type        string                 `json:"type"` // function
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// ResponsesResponse OpenAI Responses API 响应格式
+type ResponsesResponse struct {
+	ID                string                `json:"id"`
+	Object            string                `json:"object"` // "response"
+	CreatedAt         int64                 `json:"created_at"`
+	Status            string                `json:"status"` // completed, incomplete, in_progress
+	Model             string                `json:"model"`
+	Output            []ResponsesOutputItem `json:"output"`
+	Usage             *ResponsesUsage       `json:"usage,omitempty"`
+	IncompleteDetails *ResponsesIncomplete  `json:"incomplete_details,omitempty"`
+}
+
+type ResponsesOutputItem struct {
+	Type      string             `json:"type"` // message, function_call
+	ID        string             `json:"id"`
+	Status    string             `json:"status"`
+	Role      string             `json:"role,omitempty"`
+	Content   []ResponsesContent `json:"content,omitempty"`
+	CallID    string             `json:"call_id,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	Arguments string             `json:"arguments,omitempty"`
+}
+
+type ResponsesContent struct {
+	Type        string        `json:"type"` // input_text, output_text, input_image
+	Text        string        `json:"text,omitempty"`
+	ImageURL    string        `json:"image_url,omitempty"`
+	Annotations []interface{} `json:"annotations,omitempty"`
+}
+
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type ResponsesIncomplete struct {
+	Reason string `json:"reason"`
+}
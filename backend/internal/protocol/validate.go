@@ -0,0 +1,311 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError 请求体不符合协议 schema
+// Path 为出错字段的路径，元素为字段名（string）或数组下标（int），为空表示整个请求体
+type ValidationError struct {
+	Path    []interface{}
+	Message string
+}
+
+// Error 实现 error 接口，字段路径使用 OpenAI 风格
+func (e *ValidationError) Error() string {
+	return e.describe(ProtocolOpenAI)
+}
+
+// FieldPath 按协议习惯渲染字段路径：Anthropic 使用 messages.0.role，其余使用 messages[0].role
+func (e *ValidationError) FieldPath(proto Protocol) string {
+	var b strings.Builder
+	for _, seg := range e.Path {
+		switch v := seg.(type) {
+		case int:
+			if proto == ProtocolAnthropic {
+				b.WriteString("." + strconv.Itoa(v))
+			} else {
+				b.WriteString("[" + strconv.Itoa(v) + "]")
+			}
+		case string:
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+func (e *ValidationError) describe(proto Protocol) string {
+	if path := e.FieldPath(proto); path != "" {
+		return path + ": " + e.Message
+	}
+	return e.Message
+}
+
+// APIError 转换为 400 错误，字段路径按协议习惯渲染
+func (e *ValidationError) APIError(proto Protocol) *APIError {
+	apiErr := &APIError{Status: http.StatusBadRequest, Message: e.describe(proto)}
+	if path := e.FieldPath(proto); path != "" {
+		apiErr.Param = &path
+	}
+	return apiErr
+}
+
+// ValidationErrorBody 按协议构造 400 响应体，客户端 SDK 可按各自格式解析
+func ValidationErrorBody(proto Protocol, err *ValidationError) interface{} {
+	return ErrorBody(proto, err.APIError(proto))
+}
+
+// ValidateRequest 按协议 schema 校验原始请求体，只检查必填字段、类型和枚举值，未知字段忽略
+// 没有 schema 的协议直接通过
+func ValidateRequest(proto Protocol, rawBody []byte) *ValidationError {
+	s, ok := requestSchemas[proto]
+	if !ok {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(rawBody))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return &ValidationError{Message: "request body is not valid JSON"}
+	}
+	if _, ok := body.(map[string]interface{}); !ok {
+		return &ValidationError{Message: "request body must be a JSON object"}
+	}
+	return s.validate(body, nil)
+}
+
+// jsonType JSON 值类型
+type jsonType string
+
+const (
+	typeString  jsonType = "string"
+	typeNumber  jsonType = "number"
+	typeInteger jsonType = "integer"
+	typeBoolean jsonType = "boolean"
+	typeObject  jsonType = "object"
+	typeArray   jsonType = "array"
+	typeNull    jsonType = "null"
+)
+
+// schema JSON Schema 的最小子集：类型、必填字段、属性、数组元素、最少元素数、枚举
+type schema struct {
+	types    []jsonType
+	required []string
+	props    map[string]*schema
+	items    *schema
+	minItems int
+	enum     []string
+}
+
+func oneOf(types ...jsonType) *schema { return &schema{types: types} }
+func str() *schema                    { return oneOf(typeString) }
+func num() *schema                    { return oneOf(typeNumber) }
+func integer() *schema                { return oneOf(typeInteger) }
+func boolean() *schema                { return oneOf(typeBoolean) }
+func anyObject() *schema              { return oneOf(typeObject) }
+
+func enumOf(values ...string) *schema {
+	return &schema{types: []jsonType{typeString}, enum: values}
+}
+
+func object(required []string, props map[string]*schema) *schema {
+	return &schema{types: []jsonType{typeObject}, required: required, props: props}
+}
+
+func array(minItems int, items *schema) *schema {
+	return &schema{types: []jsonType{typeArray}, minItems: minItems, items: items}
+}
+
+// withItems 为允许数组的联合类型指定数组元素 schema
+func (s *schema) withItems(items *schema) *schema {
+	s.items = items
+	return s
+}
+
+func typeOf(v interface{}) jsonType {
+	switch val := v.(type) {
+	case nil:
+		return typeNull
+	case bool:
+		return typeBoolean
+	case string:
+		return typeString
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return typeInteger
+		}
+		return typeNumber
+	case map[string]interface{}:
+		return typeObject
+	case []interface{}:
+		return typeArray
+	}
+	return typeNull
+}
+
+func (s *schema) accepts(t jsonType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, want := range s.types {
+		if want == t || (want == typeNumber && t == typeInteger) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schema) validate(v interface{}, path []interface{}) *ValidationError {
+	t := typeOf(v)
+	if !s.accepts(t) {
+		names := make([]string, len(s.types))
+		for i, want := range s.types {
+			names[i] = string(want)
+		}
+		return &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %s", strings.Join(names, " or "), t)}
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := val[name]; !ok {
+				return &ValidationError{Path: appendPath(path, name), Message: "field is required"}
+			}
+		}
+		// 按字段名排序，保证同一请求总是报告同一个错误
+		names := make([]string, 0, len(s.props))
+		for name := range s.props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if fv, ok := val[name]; ok {
+				if err := s.props[name].validate(fv, appendPath(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		if len(val) < s.minItems {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("must contain at least %d item(s)", s.minItems)}
+		}
+		if s.items != nil {
+			for i, item := range val {
+				if err := s.items.validate(item, appendPath(path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if len(s.enum) > 0 {
+			for _, allowed := range s.enum {
+				if val == allowed {
+					return nil
+				}
+			}
+			return &ValidationError{Path: path, Message: fmt.Sprintf("must be one of: %s, got %q", strings.Join(s.enum, ", "), val)}
+		}
+	}
+	return nil
+}
+
+func appendPath(path []interface{}, seg interface{}) []interface{} {
+	next := make([]interface{}, len(path), len(path)+1)
+	copy(next, path)
+	return append(next, seg)
+}
+
+// requestSchemas 各协议请求体 schema，只覆盖网关实际读取的字段
+// model 不是必填：未指定时使用用户偏好的默认模型，都没有时由代理处理器返回同样格式的错误
+var requestSchemas = map[Protocol]*schema{
+	ProtocolOpenAI: object([]string{"messages"}, map[string]*schema{
+		"model": str(),
+		"messages": array(1, object([]string{"role"}, map[string]*schema{
+			"role":         enumOf("system", "developer", "user", "assistant", "tool", "function"),
+			"content":      oneOf(typeString, typeArray, typeNull).withItems(object([]string{"type"}, nil)),
+			"name":         str(),
+			"tool_calls":   array(0, object([]string{"function"}, map[string]*schema{"function": object([]string{"name"}, map[string]*schema{"name": str()})})),
+			"tool_call_id": str(),
+		})),
+		"stream":                boolean(),
+		"stream_options":        anyObject(),
+		"temperature":           num(),
+		"top_p":                 num(),
+		"n":                     integer(),
+		"max_tokens":            integer(),
+		"max_completion_tokens": integer(),
+		"reasoning_effort":      enumOf("low", "medium", "high"),
+		"presence_penalty":      num(),
+		"frequency_penalty":     num(),
+		"stop":                  oneOf(typeString, typeArray, typeNull).withItems(str()),
+		"tools": array(0, object([]string{"type"}, map[string]*schema{
+			"type":     str(),
+			"function": object([]string{"name"}, map[string]*schema{"name": str(), "parameters": anyObject()}),
+		})),
+		"user":     str(),
+		"metadata": anyObject(),
+	}),
+
+	ProtocolAnthropic: object([]string{"messages"}, map[string]*schema{
+		"model": str(),
+		"messages": array(1, object([]string{"role", "content"}, map[string]*schema{
+			"role":    enumOf("user", "assistant"),
+			"content": oneOf(typeString, typeArray).withItems(object([]string{"type"}, map[string]*schema{"type": str()})),
+		})),
+		"system":         oneOf(typeString, typeArray),
+		"max_tokens":     integer(),
+		"temperature":    num(),
+		"top_p":          num(),
+		"top_k":          integer(),
+		"stop_sequences": array(0, str()),
+		"stream":         boolean(),
+		"tools": array(0, object([]string{"name"}, map[string]*schema{
+			"name":         str(),
+			"input_schema": anyObject(),
+		})),
+		"metadata": anyObject(),
+	}),
+
+	ProtocolGemini: object([]string{"contents"}, map[string]*schema{
+		"contents": array(1, object([]string{"parts"}, map[string]*schema{
+			"role":  enumOf("user", "model"),
+			"parts": array(1, anyObject()),
+		})),
+		"systemInstruction":  object([]string{"parts"}, map[string]*schema{"parts": array(0, anyObject())}),
+		"system_instruction": object([]string{"parts"}, map[string]*schema{"parts": array(0, anyObject())}),
+		"tools":              array(0, anyObject()),
+		"safetySettings":     array(0, anyObject()),
+		"generationConfig": object(nil, map[string]*schema{
+			"temperature":     num(),
+			"topP":            num(),
+			"topK":            integer(),
+			"maxOutputTokens": integer(),
+			"candidateCount":  integer(),
+			"stopSequences":   array(0, str()),
+		}),
+	}),
+
+	ProtocolResponses: object([]string{"input"}, map[string]*schema{
+		"model":             str(),
+		"input":             oneOf(typeString, typeArray).withItems(anyObject()),
+		"instructions":      str(),
+		"max_output_tokens": integer(),
+		"temperature":       num(),
+		"top_p":             num(),
+		"stream":            boolean(),
+		"tools":             array(0, anyObject()),
+		"user":              str(),
+		"metadata":          anyObject(),
+		"reasoning":         object(nil, map[string]*schema{"effort": enumOf("low", "medium", "high")}),
+	}),
+}
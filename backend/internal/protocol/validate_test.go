@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestValidateRequest_ValidBodies(t *testing.T) {
+	tests := []struct {
+		proto Protocol
+		body  string
+	}{
+		{ProtocolOpenAI, `{"model":"gpt-4o","messages":[{"role":"system","content":"s"},{"role":"user","content":[{"type":"text","text":"hi"}]},{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}}]}],"temperature":0.2,"max_tokens":16,"stop":["x"]}`},
+		{ProtocolAnthropic, `{"model":"claude","max_tokens":64,"system":[{"type":"text","text":"s"}],"messages":[{"role":"user","content":"hi"}]}`},
+		{ProtocolGemini, `{"contents":[{"role":"user","parts":[{"text":"hi"}]}],"generationConfig":{"temperature":1,"maxOutputTokens":32}}`},
+		{ProtocolResponses, `{"model":"gpt-4o","input":"hi","max_output_tokens":64}`},
+	}
+	for _, tt := range tests {
+		if err := ValidateRequest(tt.proto, []byte(tt.body)); err != nil {
+			t.Errorf("%s: expected valid body, got %v", tt.proto, err)
+		}
+	}
+}
+
+func TestValidateRequest_ReportsFieldPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		proto Protocol
+		body  string
+		path  string
+	}{
+		{"openai missing messages", ProtocolOpenAI, `{"model":"gpt-4o"}`, "messages"},
+		{"openai empty messages", ProtocolOpenAI, `{"model":"gpt-4o","messages":[]}`, "messages"},
+		{"openai bad role", ProtocolOpenAI, `{"model":"gpt-4o","messages":[{"role":"bot","content":"hi"}]}`, "messages[0].role"},
+		{"openai wrong type", ProtocolOpenAI, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"max_tokens":"16"}`, "max_tokens"},
+		{"openai fractional integer", ProtocolOpenAI, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"max_tokens":1.5}`, "max_tokens"},
+		{"openai content part without type", ProtocolOpenAI, `{"model":"gpt-4o","messages":[{"role":"user","content":[{"text":"hi"}]}]}`, "messages[0].content[0].type"},
+		{"anthropic missing content", ProtocolAnthropic, `{"model":"claude","messages":[{"role":"user"}]}`, "messages.0.content"},
+		{"anthropic system role", ProtocolAnthropic, `{"model":"claude","messages":[{"role":"system","content":"hi"}]}`, "messages.0.role"},
+		{"gemini missing parts", ProtocolGemini, `{"contents":[{"role":"user"}]}`, "contents[0].parts"},
+		{"gemini wrong config type", ProtocolGemini, `{"contents":[{"parts":[{"text":"hi"}]}],"generationConfig":{"topK":"3"}}`, "generationConfig.topK"},
+		{"responses missing input", ProtocolResponses, `{"model":"gpt-4o"}`, "input"},
+		{"not an object", ProtocolOpenAI, `[1,2]`, ""},
+		{"invalid json", ProtocolAnthropic, `{"model":`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRequest(tt.proto, []byte(tt.body))
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if got := err.FieldPath(tt.proto); got != tt.path {
+				t.Errorf("Expected path %q, got %q (%s)", tt.path, got, err.Message)
+			}
+		})
+	}
+}
+
+func TestValidateRequest_UnknownProtocolPasses(t *testing.T) {
+	if err := ValidateRequest(Protocol("other"), []byte(`not json`)); err != nil {
+		t.Errorf("Expected no validation for unknown protocol, got %v", err)
+	}
+}
+
+func TestValidationErrorBody_UsesProtocolFormat(t *testing.T) {
+	verr := &ValidationError{Path: []interface{}{"messages", 0, "role"}, Message: "field is required"}
+
+	tests := []struct {
+		proto Protocol
+		want  string
+	}{
+		{ProtocolOpenAI, `{"error":{"message":"messages[0].role: field is required","type":"invalid_request_error","param":"messages[0].role","code":null}}`},
+		{ProtocolResponses, `{"error":{"message":"messages[0].role: field is required","type":"invalid_request_error","param":"messages[0].role","code":null}}`},
+		{ProtocolAnthropic, `{"type":"error","error":{"type":"invalid_request_error","message":"messages.0.role: field is required"}}`},
+		{ProtocolGemini, `{"error":{"code":400,"message":"messages[0].role: field is required","status":"INVALID_ARGUMENT"}}`},
+	}
+	for _, tt := range tests {
+		data, _ := json.Marshal(ValidationErrorBody(tt.proto, verr))
+		if string(data) != tt.want {
+			t.Errorf("%s:\n got: %s\nwant: %s", tt.proto, data, tt.want)
+		}
+	}
+}
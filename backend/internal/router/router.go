@@ -2,19 +2,23 @@ package router
 
 import (
 	"api-aggregator/backend/internal/domain/accountpool"
+	"api-aggregator/backend/internal/domain/anomaly"
 	"api-aggregator/backend/internal/domain/apiconfig"
 	"api-aggregator/backend/internal/domain/apikey"
 	"api-aggregator/backend/internal/domain/auth"
 	"api-aggregator/backend/internal/domain/cache"
 	"api-aggregator/backend/internal/domain/loadbalancer"
 	"api-aggregator/backend/internal/domain/log"
+	"api-aggregator/backend/internal/domain/modelmeta"
 	"api-aggregator/backend/internal/domain/pricing"
+	"api-aggregator/backend/internal/domain/promptblock"
 	"api-aggregator/backend/internal/domain/proxy"
 	"api-aggregator/backend/internal/domain/quota"
 	"api-aggregator/backend/internal/domain/settings"
 	"api-aggregator/backend/internal/domain/stats"
 	"api-aggregator/backend/internal/domain/user"
 	"api-aggregator/backend/internal/middleware"
+	"api-aggregator/backend/internal/protocol"
 
 	"github.com/gin-gonic/gin"
 )
@@ -38,6 +42,9 @@ type Router struct {
 	accountPoolHandler   *accountpool.Handler
 	settingsHandler      *settings.Handler
 	proxyHandler         *proxy.Handler
+	anomalyHandler       *anomaly.Handler
+	modelMetaHandler     *modelmeta.Handler
+	promptBlockHandler   *promptblock.Handler
 }
 
 // Config 路由配置
@@ -59,6 +66,9 @@ type Config struct {
 	AccountPoolHandler   *accountpool.Handler
 	SettingsHandler      *settings.Handler
 	ProxyHandler         *proxy.Handler
+	AnomalyHandler       *anomaly.Handler
+	ModelMetaHandler     *modelmeta.Handler
+	PromptBlockHandler   *promptblock.Handler
 }
 
 // New 创建路由管理器实例
@@ -79,6 +89,9 @@ func New(config *Config) *Router {
 		accountPoolHandler:   config.AccountPoolHandler,
 		settingsHandler:      config.SettingsHandler,
 		proxyHandler:         config.ProxyHandler,
+		anomalyHandler:       config.AnomalyHandler,
+		modelMetaHandler:     config.ModelMetaHandler,
+		promptBlockHandler:   config.PromptBlockHandler,
 	}
 }
 
@@ -90,9 +103,12 @@ func (r *Router) Setup() {
 	r.engine.Use(r.mw.Logger.Handle())
 	r.engine.Use(r.mw.CORS.Handle())
 
-	// 健康检查
+	// 健康检查（在并发限制之前注册，过载时仍可访问）
 	r.engine.GET("/health", r.healthCheck)
 
+	// 全局并发限制，保护数据库连接池
+	r.engine.Use(r.mw.ConcurrencyLimit.Handle())
+
 	// 设置各模块路由
 	r.setupAuthRoutes()
 	r.setupUserRoutes()
@@ -103,15 +119,15 @@ func (r *Router) Setup() {
 
 // healthCheck 健康检查
 func (r *Router) healthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{"status": "ok"})
+	c.JSON(200, gin.H{"status": "ok", "concurrency": r.mw.ConcurrencyLimit.Stats()})
 }
 
 // setupAuthRoutes 设置认证路由
 func (r *Router) setupAuthRoutes() {
 	auth := r.engine.Group("/api/v1/auth")
 	{
-		auth.POST("/register", r.authHandler.Register)
-		auth.POST("/login", r.authHandler.Login)
+		auth.POST("/register", r.mw.AuthRateLimit.Handle("register"), r.authHandler.Register)
+		auth.POST("/login", r.mw.AuthRateLimit.HandleLogin(), r.authHandler.Login)
 		
 		// 需要认证的路由
 		authProtected := auth.Group("")
@@ -132,9 +148,15 @@ func (r *Router) setupUserRoutes() {
 		user.GET("/quota", r.quotaHandler.GetQuotaInfo)
 		user.POST("/signin", r.quotaHandler.SignIn)
 		user.GET("/usage-history", r.quotaHandler.GetUsageHistory)
+		user.POST("/quota/hold", r.quotaHandler.CreateHold)
+		user.DELETE("/quota/hold/:id", r.quotaHandler.ReleaseHold)
 		
 		// 缓存统计
 		user.GET("/cache/stats", r.cacheHandler.GetCacheStats)
+
+		// 偏好设置
+		user.GET("/preferences", r.userHandler.GetPreferences)
+		user.PUT("/preferences", r.userHandler.UpdatePreferences)
 	}
 	
 	// 模型列表（不需要认证，但需要在 /api/v1 下）
@@ -155,6 +177,7 @@ func (r *Router) setupAPIKeyRoutes() {
 		apikeys.GET("/:id", r.apiKeyHandler.GetAPIKeyByID)
 		apikeys.PUT("/:id", r.apiKeyHandler.UpdateAPIKey)
 		apikeys.DELETE("/:id", r.apiKeyHandler.DeleteAPIKey)
+		apikeys.PUT("/:id/param-overrides", r.apiKeyHandler.SetParamOverrides)
 	}
 }
 
@@ -169,6 +192,9 @@ func (r *Router) setupAdminRoutes() {
 		
 		// API配置管理
 		r.setupAdminAPIConfigRoutes(admin)
+
+		// API密钥管理
+		r.setupAdminAPIKeyRoutes(admin)
 		
 		// 统计和日志
 		r.setupAdminStatsRoutes(admin)
@@ -188,6 +214,56 @@ func (r *Router) setupAdminRoutes() {
 		
 		// 系统设置
 		r.setupAdminSettingsRoutes(admin)
+
+		// 活跃流
+		r.setupAdminStreamRoutes(admin)
+
+		// 用量异常
+		r.setupAdminAnomalyRoutes(admin)
+
+		// 模型元数据（弃用状态）
+		r.setupAdminModelMetadataRoutes(admin)
+
+		// 提示词屏蔽规则
+		r.setupAdminPromptBlocklistRoutes(admin)
+	}
+}
+
+// setupAdminPromptBlocklistRoutes 设置管理员提示词屏蔽规则路由
+func (r *Router) setupAdminPromptBlocklistRoutes(group *gin.RouterGroup) {
+	blocklist := group.Group("/prompt-blocklist")
+	{
+		blocklist.GET("", r.promptBlockHandler.List)
+		blocklist.POST("", r.promptBlockHandler.Create)
+		blocklist.DELETE("/:id", r.promptBlockHandler.Delete)
+	}
+}
+
+// setupAdminModelMetadataRoutes 设置管理员模型元数据路由
+func (r *Router) setupAdminModelMetadataRoutes(group *gin.RouterGroup) {
+	metadata := group.Group("/model-metadata")
+	{
+		metadata.GET("", r.modelMetaHandler.List)
+		metadata.PUT("", r.modelMetaHandler.Set)
+		metadata.DELETE("", r.modelMetaHandler.Delete)
+	}
+}
+
+// setupAdminAnomalyRoutes 设置管理员用量异常路由
+func (r *Router) setupAdminAnomalyRoutes(group *gin.RouterGroup) {
+	anomalies := group.Group("/anomalies")
+	{
+		anomalies.GET("", r.anomalyHandler.ListFlags)
+		anomalies.POST("/:id/clear", r.anomalyHandler.ClearFlag)
+	}
+}
+
+// setupAdminStreamRoutes 设置管理员活跃流路由
+func (r *Router) setupAdminStreamRoutes(group *gin.RouterGroup) {
+	streams := group.Group("/streams")
+	{
+		streams.GET("/active", r.proxyHandler.ListActiveStreams)
+		streams.DELETE("/:id", r.proxyHandler.TerminateStream)
 	}
 }
 
@@ -199,7 +275,18 @@ func (r *Router) setupAdminUserRoutes(group *gin.RouterGroup) {
 		users.GET("/:id", r.userHandler.GetUserByID)
 		users.PUT("/:id/status", r.userHandler.UpdateUserStatus)
 		users.PUT("/:id/quota", r.userHandler.UpdateUserQuota)
+		users.PUT("/:id/rate-limit", r.userHandler.UpdateUserRateLimit)
+		users.POST("/:id/refund", r.quotaHandler.Refund)
 		users.DELETE("/:id", r.userHandler.DeleteUser)
+		users.DELETE("/:id/data", r.userHandler.PurgeUserData)
+	}
+}
+
+// setupAdminAPIKeyRoutes 设置管理员API密钥路由
+func (r *Router) setupAdminAPIKeyRoutes(group *gin.RouterGroup) {
+	apikeys := group.Group("/apikeys")
+	{
+		apikeys.PUT("/:id/param-overrides", r.apiKeyHandler.AdminSetParamOverrides)
 	}
 }
 
@@ -209,11 +296,21 @@ func (r *Router) setupAdminAPIConfigRoutes(group *gin.RouterGroup) {
 	{
 		configs.GET("", r.apiConfigHandler.GetConfigs)
 		configs.POST("", r.apiConfigHandler.CreateConfig)
+		configs.GET("/concurrency", r.proxyHandler.ListConcurrency)
 		configs.GET("/:id", r.apiConfigHandler.GetConfig)
 		configs.PUT("/:id", r.apiConfigHandler.UpdateConfig)
 		configs.DELETE("/:id", r.apiConfigHandler.DeleteConfig)
 		configs.POST("/:id/activate", r.apiConfigHandler.ActivateConfig)
 		configs.POST("/:id/deactivate", r.apiConfigHandler.DeactivateConfig)
+		configs.POST("/:id/test", r.apiConfigHandler.TestConfig)
+		configs.POST("/:id/promote", r.apiConfigHandler.PromoteCanary)
+		configs.GET("/:id/pricing-preview", r.proxyHandler.PricingPreview)
+
+		// 上游 Key 轮换
+		configs.GET("/:id/keys", r.apiConfigHandler.ListUpstreamKeys)
+		configs.POST("/:id/keys", r.apiConfigHandler.AddUpstreamKey)
+		configs.POST("/:id/keys/:key_id/promote", r.apiConfigHandler.PromoteUpstreamKey)
+		configs.DELETE("/:id/keys/:key_id", r.apiConfigHandler.RetireUpstreamKey)
 		
 		// 批量操作
 		configs.POST("/batch/delete", r.apiConfigHandler.BatchDeleteConfigs)
@@ -237,6 +334,7 @@ func (r *Router) setupAdminStatsRoutes(group *gin.RouterGroup) {
 		stats.GET("/models", r.statsHandler.GetModelUsage)
 		stats.GET("/users", r.statsHandler.GetUserGrowth)
 		stats.GET("/tokens", r.statsHandler.GetTokenUsage)
+		stats.GET("/payload-sizes", r.statsHandler.GetPayloadSizes)
 	}
 }
 
@@ -262,6 +360,10 @@ func (r *Router) setupAdminPricingRoutes(group *gin.RouterGroup) {
 		pricings.PUT("/:id", r.pricingHandler.UpdatePricing)
 		pricings.DELETE("/:id", r.pricingHandler.DeletePricing)
 		pricings.POST("/calculate", r.pricingHandler.CalculateCost)
+		pricings.GET("/templates", r.pricingHandler.GetTemplates)
+		pricings.POST("/templates", r.pricingHandler.CreateTemplate)
+		pricings.PUT("/templates/:id", r.pricingHandler.UpdateTemplate)
+		pricings.DELETE("/templates/:id", r.pricingHandler.DeleteTemplate)
 	}
 }
 
@@ -291,6 +393,7 @@ func (r *Router) setupAdminLoadBalancerRoutes(group *gin.RouterGroup) {
 		lb.GET("/models", r.loadBalancerHandler.GetAvailableModels)
 		lb.GET("/models/:model/config", r.loadBalancerHandler.GetConfigByModel)
 		lb.GET("/models/:model/endpoints", r.loadBalancerHandler.GetModelEndpoints)
+		lb.POST("/simulate", r.loadBalancerHandler.Simulate)
 	}
 }
 
@@ -305,6 +408,8 @@ func (r *Router) setupAdminAccountPoolRoutes(group *gin.RouterGroup) {
 		pools.DELETE("/:id", r.accountPoolHandler.DeletePool)
 		pools.PUT("/:id/status", r.accountPoolHandler.UpdatePoolStatus)
 		pools.GET("/:id/stats", r.accountPoolHandler.GetPoolStats)
+		pools.GET("/:id/usage", r.accountPoolHandler.GetPoolUsage)
+		pools.GET("/capacity", r.accountPoolHandler.GetPoolCapacity)
 		
 		// 凭据管理
 		pools.GET("/credentials", r.accountPoolHandler.ListCredentials)
@@ -328,6 +433,10 @@ func (r *Router) setupAdminAccountPoolRoutes(group *gin.RouterGroup) {
 func (r *Router) setupAdminSettingsRoutes(group *gin.RouterGroup) {
 	settings := group.Group("/settings")
 	{
+		// 通用设置项：按类型校验，更新后立即热加载
+		settings.GET("", r.settingsHandler.ListSettings)
+		settings.PUT("/:key", r.settingsHandler.UpdateSetting)
+
 		// 运行时配置
 		settings.GET("/runtime", r.settingsHandler.GetRuntimeConfig)
 		settings.PUT("/runtime", r.settingsHandler.UpdateRuntimeConfig)
@@ -358,14 +467,35 @@ func (r *Router) setupProxyRoutes() {
 	// OpenAI 兼容接口
 	v1 := r.engine.Group("/v1")
 	v1.Use(r.mw.APIKey.Handle()) // API Key 验证
+	{
+		// Anthropic Message Batches 状态和结果轮询，不计入请求数限制
+		v1.GET("/messages/batches/:id", r.proxyHandler.GetMessageBatch)
+		v1.GET("/messages/batches/:id/results", r.proxyHandler.GetMessageBatchResults)
+
+		// 取消流式请求，不计入请求数限制
+		v1.POST("/cancel/:stream_id", r.proxyHandler.CancelStream)
+	}
+
+	// 生成类接口按密钥限制每分钟、每小时、每日请求数
+	limited := v1.Group("")
+	limited.Use(r.mw.RateLimit.Handle())
 	{
 		// OpenAI 格式
-		v1.POST("/chat/completions", r.proxyHandler.ChatCompletionsOpenAI)
+		limited.POST("/chat/completions", r.mw.RequestSchema.Handle(protocol.ProtocolOpenAI), r.proxyHandler.ChatCompletionsOpenAI)
+
+		// OpenAI Embeddings，超过供应商单次上限的输入自动分批
+		limited.POST("/embeddings", r.proxyHandler.Embeddings)
+
+		// OpenAI Responses API 格式
+		limited.POST("/responses", r.mw.RequestSchema.Handle(protocol.ProtocolResponses), r.proxyHandler.Responses)
 		
 		// Anthropic 格式
-		v1.POST("/messages", r.proxyHandler.ChatCompletionsAnthropic)
+		limited.POST("/messages", r.mw.RequestSchema.Handle(protocol.ProtocolAnthropic), r.proxyHandler.ChatCompletionsAnthropic)
+
+		// Anthropic Message Batches，后台处理并轮询结果
+		limited.POST("/messages/batches", r.proxyHandler.CreateMessageBatch)
 		
 		// Gemini 格式 - 使用通配符匹配
-		v1.POST("/models/*action", r.proxyHandler.ChatCompletionsGemini)
+		limited.POST("/models/*action", r.mw.RequestSchema.Handle(protocol.ProtocolGemini), r.proxyHandler.ChatCompletionsGemini)
 	}
 }
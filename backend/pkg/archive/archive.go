@@ -0,0 +1,250 @@
+package archive
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrQueueFull 归档队列已满，记录未能入队
+	ErrQueueFull = errors.New("archive queue is full")
+	// ErrClosed 归档器已关闭
+	ErrClosed = errors.New("archive is closed")
+)
+
+// Record 一次请求/响应的归档记录（脱敏后的内容）
+// Hash 为去掉 Hash 字段后记录 JSON 的 SHA-256，PrevHash 指向上一条记录的 Hash，
+// 任意一条记录被修改、删除或插入都会使后续的哈希链校验失败
+type Record struct {
+	Seq         uint64          `json:"seq"`
+	Timestamp   time.Time       `json:"timestamp"`
+	UserID      uint            `json:"user_id"`
+	APIKeyID    uint            `json:"api_key_id"`
+	APIConfigID uint            `json:"api_config_id,omitempty"`
+	Model       string          `json:"model"`
+	Endpoint    string          `json:"endpoint,omitempty"`
+	Stream      bool            `json:"stream"`
+	Request     json.RawMessage `json:"request"`
+	Response    json.RawMessage `json:"response,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	PrevHash    string          `json:"prev_hash"`
+	Hash        string          `json:"hash"`
+}
+
+// computeHash 计算记录的完整性哈希（Hash 字段不参与计算）
+func computeHash(rec *Record) (string, error) {
+	unsealed := *rec
+	unsealed.Hash = ""
+	data, err := json.Marshal(&unsealed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Sink 只追加的归档存储，每次追加一行完整的记录
+type Sink interface {
+	Append(line []byte) error
+	Close() error
+}
+
+// chainTail 能够返回已有最后一条记录的存储，用于重启后接续哈希链
+type chainTail interface {
+	Last() (seq uint64, hash string)
+}
+
+// Options 归档器选项
+type Options struct {
+	QueueSize int
+	// FailClosed 归档不可用时拒绝新请求，而不是继续处理但不归档
+	FailClosed bool
+	// RetryInterval 写入失败后重试同一条记录的间隔，默认 1 秒
+	RetryInterval time.Duration
+}
+
+// Archiver 合规归档器
+// 记录先进入有界队列，由后台协程按顺序加上哈希链后写入存储；
+// 写入失败时按间隔重试同一条记录，保证链上没有缺口，期间归档器标记为不健康
+type Archiver struct {
+	sink   Sink
+	opts   Options
+	logger logger.Logger
+
+	queue     chan *Record
+	mu        sync.RWMutex // 保护 closed，关闭后不再向 queue 发送
+	closed    bool
+	started   bool
+	stop      chan struct{}
+	done      chan struct{}
+	failing   atomic.Bool
+	dropped   atomic.Int64
+	onFailure func(err error)
+
+	lastSeq  uint64
+	lastHash string
+}
+
+// New 创建归档器，需调用 Start 启动后台写入
+func New(sink Sink, opts Options, log logger.Logger) *Archiver {
+	if opts.QueueSize < 1 {
+		opts.QueueSize = 1000
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+	a := &Archiver{
+		sink:   sink,
+		opts:   opts,
+		logger: log,
+		queue:  make(chan *Record, opts.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if tail, ok := sink.(chainTail); ok {
+		a.lastSeq, a.lastHash = tail.Last()
+	}
+	return a
+}
+
+// OnFailure 设置归档由健康变为不健康时的回调（例如发送告警），回调在独立协程中执行
+func (a *Archiver) OnFailure(fn func(err error)) {
+	a.onFailure = fn
+}
+
+// Start 启动后台写入协程
+func (a *Archiver) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.started || a.closed {
+		return
+	}
+	a.started = true
+	go a.run()
+}
+
+// Archive 将记录放入归档队列；队列已满或归档器已关闭时返回错误并标记为不健康
+func (a *Archiver) Archive(rec *Record) error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return ErrClosed
+	}
+	select {
+	case a.queue <- rec:
+		a.mu.RUnlock()
+		return nil
+	default:
+	}
+	a.mu.RUnlock()
+
+	a.dropped.Add(1)
+	a.fail(ErrQueueFull)
+	return ErrQueueFull
+}
+
+// Healthy 最近一次写入成功且队列未满
+func (a *Archiver) Healthy() bool {
+	return !a.failing.Load() && len(a.queue) < cap(a.queue)
+}
+
+// Available 是否可以继续接收请求：故障开放模式下始终可用，故障关闭模式下取决于归档是否健康
+func (a *Archiver) Available() bool {
+	return !a.opts.FailClosed || a.Healthy()
+}
+
+// Dropped 因队列已满而未能归档的记录数
+func (a *Archiver) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Close 停止接收新记录并写完队列中剩余的记录，ctx 超时则放弃重试
+func (a *Archiver) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	started := a.started
+	a.mu.Unlock()
+
+	if !started {
+		go a.run()
+	}
+
+	var err error
+	select {
+	case <-a.done:
+	case <-ctx.Done():
+		close(a.stop)
+		<-a.done
+		err = ctx.Err()
+	}
+	if closeErr := a.sink.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// run 按顺序写入队列中的记录，队列关闭后退出
+func (a *Archiver) run() {
+	defer close(a.done)
+	for rec := range a.queue {
+		a.write(rec)
+	}
+}
+
+// write 为记录加上序号和哈希链并写入存储，失败时重试直到成功或归档器被强制停止
+func (a *Archiver) write(rec *Record) {
+	rec.Seq = a.lastSeq + 1
+	rec.PrevHash = a.lastHash
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now().UTC()
+	}
+	hash, err := computeHash(rec)
+	if err != nil {
+		a.logger.Error("Failed to seal archive record", logger.String("model", rec.Model), logger.Error(err))
+		return
+	}
+	rec.Hash = hash
+	line, err := json.Marshal(rec)
+	if err != nil {
+		a.logger.Error("Failed to encode archive record", logger.String("model", rec.Model), logger.Error(err))
+		return
+	}
+
+	for {
+		err := a.sink.Append(line)
+		if err == nil {
+			a.lastSeq, a.lastHash = rec.Seq, rec.Hash
+			if a.failing.CompareAndSwap(true, false) {
+				a.logger.Info("Compliance archive recovered", logger.Int64("seq", int64(rec.Seq)))
+			}
+			return
+		}
+		a.fail(err)
+		select {
+		case <-time.After(a.opts.RetryInterval):
+		case <-a.stop:
+			a.logger.Error("Archive record abandoned on shutdown", logger.Int64("seq", int64(rec.Seq)))
+			return
+		}
+	}
+}
+
+// fail 记录归档失败，由健康变为不健康时触发回调
+func (a *Archiver) fail(err error) {
+	a.logger.Error("Compliance archive write failed", logger.Error(err))
+	if a.failing.CompareAndSwap(false, true) && a.onFailure != nil {
+		go a.onFailure(err)
+	}
+}
@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"api-aggregator/backend/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func archiveRecords(t *testing.T, path string, models ...string) {
+	t.Helper()
+	sink, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	a := New(sink, Options{}, *logger.NewNop())
+	a.Start()
+	for _, model := range models {
+		if err := a.Archive(&Record{Model: model, Request: json.RawMessage(`{"model":"` + model + `"}`)}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestArchiver_ChainsHashesAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	archiveRecords(t, path, "a", "b")
+	archiveRecords(t, path, "c")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	count, err := Verify(bytes.NewReader(data))
+	if err != nil || count != 3 {
+		t.Fatalf("Expected 3 verified records, got %d (%v)", count, err)
+	}
+
+	var last Record
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	json.Unmarshal([]byte(lines[2]), &last)
+	if last.Seq != 3 || last.PrevHash == "" || len(last.Hash) != 64 {
+		t.Errorf("Expected the reopened archive to continue the chain at seq 3, got %+v", last)
+	}
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	archiveRecords(t, path, "a", "b", "c")
+	data, _ := os.ReadFile(path)
+
+	tampered := strings.Replace(string(data), `"model":"b"`, `"model":"x"`, 1)
+	if _, err := Verify(strings.NewReader(tampered)); err == nil {
+		t.Error("Expected a modified record to fail verification")
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	removed := lines[0] + "\n" + lines[2] + "\n"
+	if _, err := Verify(strings.NewReader(removed)); err == nil {
+		t.Error("Expected a removed record to break the chain")
+	}
+}
+
+// brokenSink 始终写入失败的存储
+type brokenSink struct{}
+
+func (brokenSink) Append(line []byte) error { return os.ErrPermission }
+func (brokenSink) Close() error             { return nil }
+
+func TestArchiver_FailClosedWhenSinkFails(t *testing.T) {
+	failures := make(chan error, 1)
+	a := New(brokenSink{}, Options{FailClosed: true, RetryInterval: time.Millisecond}, *logger.NewNop())
+	a.OnFailure(func(err error) { failures <- err })
+	a.Start()
+
+	if !a.Available() {
+		t.Fatal("Expected the archive available before any failure")
+	}
+	a.Archive(&Record{Model: "a"})
+
+	select {
+	case <-failures:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the failure handler called")
+	}
+	if a.Healthy() || a.Available() {
+		t.Error("Expected a fail-closed archive unavailable after a write failure")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := a.Close(ctx); err == nil {
+		t.Error("Expected Close to give up on the unwritable record")
+	}
+}
@@ -0,0 +1,139 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// tailChunkSize 打开归档文件时从末尾向前查找最后一条记录的读取块大小
+const tailChunkSize = 64 * 1024
+
+// FileSink 只追加的 JSONL 归档文件
+// 文件以 O_APPEND 打开，每条记录写入后立即 fsync；
+// 真正的 WORM 保证需要存储层配合（例如 chattr +a 或开启对象锁的存储桶同步）
+type FileSink struct {
+	file     *os.File
+	lastSeq  uint64
+	lastHash string
+}
+
+// OpenFile 打开（不存在时创建）归档文件，并读取最后一条记录以接续哈希链
+func OpenFile(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive file: %w", err)
+	}
+	sink := &FileSink{file: file}
+
+	last, err := readLastLine(path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if len(last) > 0 {
+		var rec Record
+		if err := json.Unmarshal(last, &rec); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to parse last archive record: %w", err)
+		}
+		sink.lastSeq, sink.lastHash = rec.Seq, rec.Hash
+	}
+	return sink, nil
+}
+
+// Append 追加一行记录并落盘
+func (s *FileSink) Append(line []byte) error {
+	buf := make([]byte, 0, len(line)+1)
+	buf = append(append(buf, line...), '\n')
+	if _, err := s.file.Write(buf); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// Last 打开时文件中最后一条记录的序号和哈希
+func (s *FileSink) Last() (uint64, string) {
+	return s.lastSeq, s.lastHash
+}
+
+// Close 关闭归档文件
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// readLastLine 从文件末尾向前读取最后一个非空行，文件为空时返回 nil
+func readLastLine(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var tail []byte
+	for offset := info.Size(); offset > 0; {
+		size := int64(tailChunkSize)
+		if offset < size {
+			size = offset
+		}
+		offset -= size
+		chunk := make([]byte, size)
+		if _, err := file.ReadAt(chunk, offset); err != nil {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if offset == 0 {
+			return trimmed, nil
+		}
+	}
+	return nil, nil
+}
+
+// Verify 逐条校验归档内容的哈希和链接关系，返回通过校验的记录数；遇到第一处不一致即返回错误
+func Verify(r io.Reader) (int, error) {
+	reader := bufio.NewReader(r)
+	prevHash := ""
+	var prevSeq uint64
+	count := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var rec Record
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+				return count, fmt.Errorf("record %d: invalid JSON: %w", count+1, jsonErr)
+			}
+			// 第一条记录可能接在轮转前的归档之后，只校验后续记录的链接
+			if count > 0 && (rec.PrevHash != prevHash || rec.Seq != prevSeq+1) {
+				return count, fmt.Errorf("record %d: chain broken after seq %d", rec.Seq, prevSeq)
+			}
+			hash, hashErr := computeHash(&rec)
+			if hashErr != nil {
+				return count, hashErr
+			}
+			if hash != rec.Hash {
+				return count, fmt.Errorf("record %d: hash mismatch", rec.Seq)
+			}
+			prevHash, prevSeq = rec.Hash, rec.Seq
+			count++
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,6 +16,10 @@ type Client struct {
 	baseURL string
 	timeout time.Duration
 	client  *http.Client
+
+	// 临时性失败（网络错误、429、5xx）的重试次数和首次重试前的等待时间，之后每次加倍
+	maxRetries   int
+	retryBackoff time.Duration
 }
 
 // NewClient 创建 Embedding 客户端
@@ -28,6 +33,24 @@ func NewClient(baseURL string, timeout time.Duration) *Client {
 	}
 }
 
+// WithRetry 设置临时性失败的重试策略
+func (c *Client) WithRetry(maxRetries int, backoff time.Duration) *Client {
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	c.maxRetries = maxRetries
+	c.retryBackoff = backoff
+	return c
+}
+
+// transientError 可重试的失败
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
 // EmbedRequest Embedding 请求
 type EmbedRequest struct {
 	Text string `json:"text"`
@@ -39,8 +62,27 @@ type EmbedResponse struct {
 	Error     string    `json:"error,omitempty"`
 }
 
-// Embed 获取文本的向量表示
+// Embed 获取文本的向量表示，临时性失败按重试策略退避重试
 func (c *Client) Embed(ctx context.Context, text string) ([]float64, error) {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		vec, err := c.embedOnce(ctx, text)
+		var transient *transientError
+		if err == nil || !errors.As(err, &transient) || attempt >= c.maxRetries {
+			return vec, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// embedOnce 发送一次 embedding 请求
+func (c *Client) embedOnce(ctx context.Context, text string) ([]float64, error) {
 	reqBody := EmbedRequest{Text: text}
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
@@ -56,7 +98,7 @@ func (c *Client) Embed(ctx context.Context, text string) ([]float64, error) {
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, &transientError{fmt.Errorf("failed to send request: %w", err)}
 	}
 	defer resp.Body.Close()
 
@@ -66,7 +108,11 @@ func (c *Client) Embed(ctx context.Context, text string) ([]float64, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("embedding service returned status %d: %s", resp.StatusCode, string(body))
+		err := fmt.Errorf("embedding service returned status %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, &transientError{err}
+		}
+		return nil, err
 	}
 
 	var embedResp EmbedResponse
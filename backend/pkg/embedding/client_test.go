@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer 前 failures 次返回 status，之后返回正常向量
+func flakyServer(failures int32, status int, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(status)
+			w.Write([]byte("unavailable"))
+			return
+		}
+		json.NewEncoder(w).Encode(EmbedResponse{Embedding: []float64{0.1, 0.2}})
+	}))
+}
+
+func TestEmbed_RetriesTransientFailure(t *testing.T) {
+	var calls int32
+	server := flakyServer(2, http.StatusServiceUnavailable, &calls)
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second).WithRetry(2, time.Millisecond)
+	vec, err := client.Embed(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if len(vec) != 2 || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("Expected 3 attempts and a vector, got %d attempts, %v", atomic.LoadInt32(&calls), vec)
+	}
+}
+
+func TestEmbed_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := flakyServer(10, http.StatusBadGateway, &calls)
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second).WithRetry(1, time.Millisecond)
+	if _, err := client.Embed(context.Background(), "hello"); err == nil {
+		t.Fatal("Expected error after retries are exhausted")
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", atomic.LoadInt32(&calls))
+	}
+}
+
+func TestEmbed_DoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := flakyServer(10, http.StatusBadRequest, &calls)
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second).WithRetry(3, time.Millisecond)
+	if _, err := client.Embed(context.Background(), "hello"); err == nil {
+		t.Fatal("Expected error for bad request")
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("Expected a single attempt, got %d", atomic.LoadInt32(&calls))
+	}
+}
@@ -6,12 +6,14 @@ var (
 	ErrInvalidParam     = New(400001, "Invalid parameter")
 	ErrInvalidRequest   = New(400002, "Invalid request")
 	ErrValidationFailed = New(400003, "Validation failed")
+	ErrRefundExceedsCharge = New(400004, "Refund exceeds original charge")
 
 	// 认证错误 (401xxx)
 	ErrUnauthorized     = New(401001, "Unauthorized")
 	ErrInvalidToken     = New(401002, "Invalid token")
 	ErrTokenExpired     = New(401003, "Token expired")
 	ErrInvalidPassword  = New(401004, "Invalid password")
+	ErrAPIKeyExpired    = New(401005, "API key expired")
 
 	// 权限错误 (403xxx)
 	ErrForbidden        = New(403001, "Forbidden")
@@ -30,6 +32,7 @@ var (
 	ErrAPIKeyExists     = New(409003, "API key already exists")
 	ErrEmailExists      = New(409004, "Email already exists")
 	ErrUsernameExists   = New(409005, "Username already exists")
+	ErrQuotaHoldUnavailable = New(409006, "Quota hold not found, expired or already used")
 
 	// 配额错误 (429xxx)
 	ErrQuotaExceeded    = New(429001, "Quota exceeded")
@@ -41,4 +44,7 @@ var (
 	ErrCache            = New(500003, "Cache error")
 	ErrExternal         = New(500004, "External service error")
 	ErrEncryption       = New(500005, "Encryption error")
+
+	// 服务不可用 (503xxx)
+	ErrServiceUnavailable = New(503001, "Service unavailable")
 )
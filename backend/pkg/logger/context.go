@@ -0,0 +1,91 @@
+package logger
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type contextKey struct{}
+
+// scope 请求范围内的日志，请求处理过程中可以继续追加字段
+// 同名字段后写入的覆盖先写入的，例如故障转移后更新的 api_config_id；
+// 单条日志再传入同名字段时以请求字段为准
+type scope struct {
+	mu     sync.RWMutex
+	base   *Logger
+	keys   []string
+	fields map[string]Field
+	logger *Logger
+}
+
+// NewContext 返回携带请求日志的 context，之后追加的字段都记录在这个日志上
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, &scope{base: l, fields: make(map[string]Field), logger: l})
+}
+
+// AddFields 为 context 中的请求日志追加字段，context 没有请求日志时忽略
+func AddFields(ctx context.Context, fields ...Field) {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok || len(fields) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, field := range fields {
+		if _, exists := s.fields[field.Key]; !exists {
+			s.keys = append(s.keys, field.Key)
+		}
+		s.fields[field.Key] = field
+	}
+	all := make([]Field, len(s.keys))
+	keys := make(map[string]bool, len(s.keys))
+	for i, key := range s.keys {
+		all[i] = s.fields[key]
+		keys[key] = true
+	}
+	s.logger = &Logger{zap: s.base.zap.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &scopedCore{Core: core.With(all), keys: keys}
+	}))}
+}
+
+// scopedCore 丢弃与请求字段同名的单条日志字段，避免同一个键在一行日志中出现两次
+type scopedCore struct {
+	zapcore.Core
+	keys map[string]bool
+}
+
+func (c *scopedCore) With(fields []Field) zapcore.Core {
+	return &scopedCore{Core: c.Core.With(fields), keys: c.keys}
+}
+
+func (c *scopedCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c *scopedCore) Write(entry zapcore.Entry, fields []Field) error {
+	kept := fields[:0:0]
+	for _, field := range fields {
+		if !c.keys[field.Key] {
+			kept = append(kept, field)
+		}
+	}
+	return c.Core.Write(entry, kept)
+}
+
+// FromContext 返回 context 中的请求日志，没有时返回 fallback
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	s, ok := ctx.Value(contextKey{}).(*scope)
+	if !ok {
+		return fallback
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.logger
+}
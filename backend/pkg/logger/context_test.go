@@ -0,0 +1,41 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAddFields_LaterValueReplacesEarlier(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := NewContext(context.Background(), FromZap(zap.New(core)).With(String("request_id", "req-1")))
+
+	AddFields(ctx, Uint("user_id", 7), Uint("api_config_id", 1))
+	AddFields(ctx, Uint("api_config_id", 2))
+	FromContext(ctx, NewNop()).Info("done", Uint("api_config_id", 2), String("status", "ok"))
+
+	entry := logs.All()[0]
+	fields := entry.ContextMap()
+	if fields["request_id"] != "req-1" || fields["user_id"] != uint64(7) || fields["api_config_id"] != uint64(2) || fields["status"] != "ok" {
+		t.Errorf("Expected the scoped fields with the latest config, got %v", fields)
+	}
+	count := 0
+	for _, field := range entry.Context {
+		if field.Key == "api_config_id" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected api_config_id logged once, got %d times", count)
+	}
+}
+
+func TestFromContext_FallsBackWithoutRequestLogger(t *testing.T) {
+	fallback := NewNop()
+	AddFields(context.Background(), String("ignored", "x"))
+	if FromContext(context.Background(), fallback) != fallback {
+		t.Error("Expected the fallback logger outside a request")
+	}
+}
@@ -105,6 +105,16 @@ func New(cfg *Config) (*Logger, error) {
 	return &Logger{zap: zapLogger}, nil
 }
 
+// FromZap 包装已有的 zap 日志实例
+func FromZap(z *zap.Logger) *Logger {
+	return &Logger{zap: z}
+}
+
+// NewNop 创建不输出任何内容的日志实例（用于测试）
+func NewNop() *Logger {
+	return &Logger{zap: zap.NewNop()}
+}
+
 // Debug 调试日志
 func (l *Logger) Debug(msg string, fields ...Field) {
 	l.zap.Debug(msg, fields...)
@@ -0,0 +1,188 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask 替换被脱敏内容的占位符
+const Mask = "[REDACTED]"
+
+// 内置检测器
+var builtinPatterns = []*regexp.Regexp{
+	// 邮箱
+	regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	// 美国社会安全号 (SSN)
+	regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+}
+
+// 信用卡号候选（13-19 位数字，允许空格或短横线分隔），命中后再做 Luhn 校验
+var creditCardPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+
+// Redactor 文本脱敏器
+type Redactor struct {
+	patterns []*regexp.Regexp
+}
+
+// New 创建脱敏器，包含内置检测器和自定义正则
+func New(customPatterns []string) (*Redactor, error) {
+	patterns := make([]*regexp.Regexp, 0, len(builtinPatterns)+len(customPatterns))
+	patterns = append(patterns, builtinPatterns...)
+	for _, p := range customPatterns {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return &Redactor{patterns: patterns}, nil
+}
+
+// Validate 校验自定义正则是否合法
+func Validate(customPatterns []string) error {
+	_, err := New(customPatterns)
+	return err
+}
+
+// Redact 对文本脱敏，返回脱敏后的文本和替换次数
+func (r *Redactor) Redact(text string) (string, int) {
+	spans := r.findSpans(text)
+	if len(spans) == 0 {
+		return text, 0
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, span := range spans {
+		sb.WriteString(text[last:span[0]])
+		sb.WriteString(Mask)
+		last = span[1]
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), len(spans)
+}
+
+// findSpans 查找所有需要脱敏的区间（已排序并合并重叠部分）
+func (r *Redactor) findSpans(text string) [][2]int {
+	var spans [][2]int
+	for _, re := range r.patterns {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	for _, loc := range creditCardPattern.FindAllStringIndex(text, -1) {
+		if luhnValid(text[loc[0]:loc[1]]) {
+			spans = append(spans, [2]int{loc[0], loc[1]})
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	// 按起点排序后合并重叠区间
+	for i := 1; i < len(spans); i++ {
+		for j := i; j > 0 && spans[j][0] < spans[j-1][0]; j-- {
+			spans[j], spans[j-1] = spans[j-1], spans[j]
+		}
+	}
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		tail := &merged[len(merged)-1]
+		if span[0] <= tail[1] {
+			if span[1] > tail[1] {
+				tail[1] = span[1]
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// luhnValid 使用 Luhn 算法校验卡号
+func luhnValid(s string) bool {
+	sum, count := 0, 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		count++
+	}
+	return count >= 13 && sum%10 == 0
+}
+
+// DefaultHoldback 流式脱敏时保留的尾部字符数，用于处理跨块边界的敏感信息
+const DefaultHoldback = 64
+
+// Stream 流式脱敏器，缓冲尾部文本以处理跨块边界的匹配（尽力而为）
+type Stream struct {
+	redactor *Redactor
+	pending  string
+	holdback int
+	count    int
+}
+
+// NewStream 创建流式脱敏器
+func (r *Redactor) NewStream() *Stream {
+	return &Stream{redactor: r, holdback: DefaultHoldback}
+}
+
+// Write 写入增量文本，返回可以安全输出的脱敏文本
+func (s *Stream) Write(delta string) string {
+	text := s.pending + delta
+	cut := len(text) - s.holdback
+	if cut <= 0 {
+		s.pending = text
+		return ""
+	}
+
+	// 跨越切分点的匹配整体留到下一次处理
+	for _, span := range s.redactor.findSpans(text) {
+		if span[0] < cut && span[1] > cut {
+			cut = span[0]
+			break
+		}
+	}
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+
+	s.pending = text[cut:]
+	out, n := s.redactor.Redact(text[:cut])
+	s.count += n
+	return out
+}
+
+// Flush 输出剩余缓冲内容
+func (s *Stream) Flush() string {
+	out, n := s.redactor.Redact(s.pending)
+	s.count += n
+	s.pending = ""
+	return out
+}
+
+// Pending 是否还有未输出的缓冲内容
+func (s *Stream) Pending() bool {
+	return s.pending != ""
+}
+
+// Count 返回累计脱敏次数
+func (s *Stream) Count() int {
+	return s.count
+}
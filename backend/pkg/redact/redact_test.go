@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedact_BuiltinDetectors(t *testing.T) {
+	r, err := New(nil)
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+
+	input := "Mail alice@example.com, SSN 123-45-6789, card 4111 1111 1111 1111, order 1234567890123"
+	out, n := r.Redact(input)
+	if n != 3 {
+		t.Errorf("Expected 3 redactions, got %d: %s", n, out)
+	}
+	for _, secret := range []string{"alice@example.com", "123-45-6789", "4111 1111 1111 1111"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Expected %q to be redacted: %s", secret, out)
+		}
+	}
+	// 未通过 Luhn 校验的数字串保留
+	if !strings.Contains(out, "1234567890123") {
+		t.Errorf("Expected non-card number to be kept: %s", out)
+	}
+}
+
+func TestRedact_CustomPattern(t *testing.T) {
+	r, err := New([]string{`EMP-\d{6}`})
+	if err != nil {
+		t.Fatalf("Failed to create redactor: %v", err)
+	}
+	out, n := r.Redact("employee EMP-123456 joined")
+	if n != 1 || out != "employee "+Mask+" joined" {
+		t.Errorf("Unexpected result %q (%d)", out, n)
+	}
+
+	if err := Validate([]string{"("}); err == nil {
+		t.Error("Expected invalid pattern to be rejected")
+	}
+}
+
+func TestStream_MatchAcrossChunkBoundary(t *testing.T) {
+	r, _ := New(nil)
+	s := r.NewStream()
+
+	var out strings.Builder
+	prefix := strings.Repeat("x", 80)
+	for _, delta := range []string{prefix + " contact bob@exa", "mple.org now ", strings.Repeat("y", 70)} {
+		out.WriteString(s.Write(delta))
+	}
+	out.WriteString(s.Flush())
+
+	got := out.String()
+	if strings.Contains(got, "bob@") || strings.Contains(got, "example.org") {
+		t.Errorf("Expected split email to be redacted: %s", got)
+	}
+	want := prefix + " contact " + Mask + " now " + strings.Repeat("y", 70)
+	if got != want {
+		t.Errorf("Unexpected stream output:\n got %q\nwant %q", got, want)
+	}
+	if s.Count() != 1 {
+		t.Errorf("Expected 1 redaction, got %d", s.Count())
+	}
+}
@@ -168,6 +168,16 @@ func TooManyRequests(c *gin.Context, message string) {
 	})
 }
 
+// ServiceUnavailable 503 错误
+func ServiceUnavailable(c *gin.Context, message string) {
+	c.JSON(http.StatusServiceUnavailable, ErrorResponse{
+		Error: ErrorDetail{
+			Code:    503001,
+			Message: message,
+		},
+	})
+}
+
 // RequestTimeout 408 错误
 func RequestTimeout(c *gin.Context, message string) {
 	c.JSON(http.StatusRequestTimeout, ErrorResponse{
@@ -3,6 +3,7 @@ package runtime
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +20,14 @@ type Config struct {
 	CacheTTL          time.Duration
 	SemanticEnabled   bool
 	SemanticThreshold float64
+	CacheKeyNormalize bool
+
+	// 缓存过期后仍返回旧响应并后台刷新的窗口（0 表示关闭），以及后台刷新是否计费
+	CacheStaleWhileRevalidate time.Duration
+	CacheRefreshBilled        bool
+
+	// 热门缓存预热
+	CacheWarming CacheWarmingPolicy
 
 	// Embedding 配置
 	EmbeddingEnabled bool
@@ -30,17 +39,128 @@ type Config struct {
 	Timeout           time.Duration
 	EnableLoadBalance bool
 
+	// 流式响应中途配额检查间隔（估算输出 token 数，0 表示不检查）
+	StreamQuotaCheckTokens int
+
+	// 流式响应的最长持续时间，超过后即使仍在输出也截断（0 表示不限制）
+	StreamMaxDuration time.Duration
+
+	// 选中的适配器不支持流式时，改为非流式调用并以单个数据块模拟流（关闭时直接返回不支持流式的错误）
+	StreamFallbackEmulate bool
+
+	// 上游以内容策略拒绝回答（finish_reason 为 content_filter）的非流式响应是否按用量计费
+	BillRefusals bool
+
+	// 是否允许请求通过 X-Prism-Routing 头在响应中附带路由诊断信息（prism_routing）
+	RoutingDiagnostics bool
+
+	// 动态上游超时：按模型近期 p99 响应时间乘以倍数确定非流式调用超时，并限制在上下限之间（无历史数据时使用配置的超时）
+	DynamicTimeoutEnabled    bool
+	DynamicTimeoutMultiplier float64
+	DynamicTimeoutMin        time.Duration
+	DynamicTimeoutMax        time.Duration
+
+	// service_tier 计费倍率：flex 更便宜但更慢，priority 更贵（1 表示与默认层级相同）
+	FlexTierPriceMultiplier     float64
+	PriorityTierPriceMultiplier float64
+
+	// 流式请求默认是否按 max_tokens 预扣配额（管理员可按用户单独设置），以及按 max_tokens 的多大比例预扣
+	StreamPrecharge      bool
+	StreamPrechargeRatio float64
+
+	// 代理请求进入业务逻辑前按协议 schema 校验请求体
+	RequestSchemaValidation bool
+
+	// 读取代理请求体时的消息数上限（0 表示不限制）以及是否拒绝未知的顶层字段，超出时不再读取剩余请求体
+	RequestMaxMessages           int
+	RequestDisallowUnknownFields bool
+
+	// 代理请求中非标准角色名到标准角色（user、assistant、system）的映射，校验前改写；为空时不做映射
+	RoleAliases map[string]string
+
+	// 过期的 API Key 保留多久后被清理任务软删除（0 表示不清理）
+	ExpiredKeyRetention time.Duration
+
+	// 对话前缀缓存：前缀估算 token 数达到阈值才缓存，缓存的前缀在多长时间内未再出现即失效
+	PrefixCacheMinTokens int
+	PrefixCacheTTL       time.Duration
+
+	// 账号池凭据剩余请求数低于上限的该百分比时，选择凭据时优先使用其他凭据（0 表示只避开已耗尽的凭据）
+	PoolNearLimitPercent int
+
+	// 账号池健康凭据数低于该值时发送告警（0 表示不告警）
+	PoolMinHealthyCredentials int
+
+	// 凭据过期且刷新失败后仍继续使用的宽限期，期间标记为 degraded 并在后台重试刷新（0 表示立即停用）
+	PoolCredentialGracePeriod time.Duration
+
+	// 内置 prism-echo 测试模型，仅用于非生产环境的集成测试
+	EchoModelEnabled          bool
+	EchoModelLatency          time.Duration
+	EchoModelTransform        string // 空、upper、reverse
+	EchoModelCompletionTokens int    // 固定上报的输出 token 数，0 表示按内容估算
+
+	// 告警配置
+	AlertWebhookURL   string
+	PayloadAlertBytes int64
+
+	// 用量异常检测
+	Anomaly AnomalyPolicy
+
 	// 默认配额
 	DefaultQuotaDaily   int64
 	DefaultQuotaMonthly int64
 	DefaultQuotaTotal   int64
 
+	// 默认透支额度：剩余配额可以低于 0 的最大值，由下一次配额发放抵扣（0 表示不允许透支）
+	DefaultQuotaOverdraft int64
+
+	// 配额预留：默认有效期与客户端可申请的最长有效期，过期未使用的预留自动释放
+	QuotaHoldTTL    time.Duration
+	QuotaHoldMaxTTL time.Duration
+
+	// 每日签到奖励：基础配额、连续签到每天额外加成百分比、计入加成的最大连续天数、单日奖励上限（0 表示不限）
+	SignInBaseQuota   int64
+	SignInStreakBonus int
+	SignInStreakCap   int
+	SignInMaxQuota    int64
+
+	// 登录、注册接口限流：每个 IP 每分钟请求数（0 表示不限制），登录连续失败多少次锁定，首次锁定时长与最长锁定时长（每次锁定翻倍）
+	AuthRateLimitPerMinute int
+	AuthMaxLoginFailures   int
+	AuthLockout            time.Duration
+	AuthMaxLockout         time.Duration
+
 	// 默认速率限制
 	DefaultRateLimitPerMinute int
 	DefaultRateLimitPerHour   int
 	DefaultRateLimitPerDay    int
 }
 
+// AnomalyPolicy 用量异常检测策略
+// 最近一个检测窗口的请求数或花费超过前 BaselineWindows 个窗口平均值的 SpikeRatio 倍时标记
+type AnomalyPolicy struct {
+	Enabled         bool
+	Window          time.Duration
+	BaselineWindows int
+	SpikeRatio      float64
+	MinRequests     int64 // 窗口内请求数低于此值不按请求数标记，避免低流量误报
+	MinSpend        int64 // 窗口内花费（配额）低于此值不按花费标记
+	AutoSuspend     bool  // 标记时自动停用 API Key
+}
+
+// CacheWarmingPolicy 热门缓存预热策略
+// 命中次数达到 MinHits 的缓存在到期前 LeadTime 内由后台任务重新请求上游刷新，每天最多 DailyBudget 次
+type CacheWarmingPolicy struct {
+	Enabled          bool
+	LeadTime         time.Duration
+	MinHits          int
+	DailyBudget      int
+	OffPeakStartHour int  // 只在 [OffPeakStartHour, OffPeakEndHour) 小时内预热，可跨零点，两者相等表示全天
+	OffPeakEndHour   int
+	BillingUserID    uint // 预热调用计入该系统账户，0 表示不计费
+}
+
 // Manager 配置管理器
 type Manager struct {
 	config *Config
@@ -98,9 +218,6 @@ func (m *Manager) Stop() {
 
 // loadFromDatabase 从数据库加载配置
 func (m *Manager) loadFromDatabase() error {
-	m.config.mu.Lock()
-	defer m.config.mu.Unlock()
-
 	settings := make(map[string]string)
 	
 	var results []struct {
@@ -120,11 +237,33 @@ func (m *Manager) loadFromDatabase() error {
 		settings[r.Key] = r.Value
 	}
 
+	m.LoadSettings(settings)
+	return nil
+}
+
+// LoadSettings 从设置键值加载配置，未设置的键使用默认值
+// 设置接口更新后直接调用，无需等待自动重载
+func (m *Manager) LoadSettings(settings map[string]string) {
+	m.config.mu.Lock()
+	defer m.config.mu.Unlock()
+
 	// 解析配置
 	m.config.CacheEnabled = getBool(settings, "runtime.cache_enabled", true)
 	m.config.CacheTTL = time.Duration(getDuration(settings, "runtime.cache_ttl", 3600)) * time.Second
 	m.config.SemanticEnabled = getBool(settings, "runtime.semantic_cache_enabled", false)
 	m.config.SemanticThreshold = getFloat(settings, "runtime.semantic_threshold", 0.85)
+	m.config.CacheKeyNormalize = getBool(settings, "runtime.cache_key_normalize", true)
+	m.config.CacheStaleWhileRevalidate = time.Duration(getDuration(settings, "runtime.cache_stale_while_revalidate", 0)) * time.Second
+	m.config.CacheRefreshBilled = getBool(settings, "runtime.cache_refresh_billed", true)
+	m.config.CacheWarming = CacheWarmingPolicy{
+		Enabled:          getBool(settings, "cache_warming.enabled", false),
+		LeadTime:         time.Duration(getInt(settings, "cache_warming.lead_minutes", 10)) * time.Minute,
+		MinHits:          getInt(settings, "cache_warming.min_hits", 5),
+		DailyBudget:      getInt(settings, "cache_warming.daily_budget", 100),
+		OffPeakStartHour: getInt(settings, "cache_warming.off_peak_start_hour", 0),
+		OffPeakEndHour:   getInt(settings, "cache_warming.off_peak_end_hour", 0),
+		BillingUserID:    uint(getInt(settings, "cache_warming.billing_user_id", 0)),
+	}
 	
 	m.config.EmbeddingEnabled = getBool(settings, "runtime.embedding_enabled", false)
 	m.config.EmbeddingURL = getString(settings, "runtime.embedding_url", "http://localhost:8765")
@@ -133,16 +272,68 @@ func (m *Manager) loadFromDatabase() error {
 	m.config.MaxRetries = getInt(settings, "runtime.max_retries", 3)
 	m.config.Timeout = time.Duration(getDuration(settings, "runtime.timeout", 30)) * time.Second
 	m.config.EnableLoadBalance = getBool(settings, "runtime.enable_load_balance", true)
+	m.config.StreamQuotaCheckTokens = getInt(settings, "runtime.stream_quota_check_tokens", 200)
+	m.config.StreamMaxDuration = time.Duration(getDuration(settings, "runtime.stream_max_duration", 0)) * time.Second
+	m.config.StreamFallbackEmulate = getBool(settings, "runtime.stream_fallback_emulate", true)
+	m.config.BillRefusals = getBool(settings, "runtime.bill_refusals", true)
+	m.config.RoutingDiagnostics = getBool(settings, "runtime.routing_diagnostics", false)
+	m.config.DynamicTimeoutEnabled = getBool(settings, "runtime.dynamic_timeout_enabled", false)
+	m.config.DynamicTimeoutMultiplier = getFloat(settings, "runtime.dynamic_timeout_multiplier", 3)
+	m.config.DynamicTimeoutMin = time.Duration(getDuration(settings, "runtime.dynamic_timeout_min", 10)) * time.Second
+	m.config.DynamicTimeoutMax = time.Duration(getDuration(settings, "runtime.dynamic_timeout_max", 600)) * time.Second
+	m.config.FlexTierPriceMultiplier = getFloat(settings, "runtime.flex_tier_price_multiplier", 1)
+	m.config.PriorityTierPriceMultiplier = getFloat(settings, "runtime.priority_tier_price_multiplier", 1)
+	m.config.StreamPrecharge = getBool(settings, "runtime.stream_precharge", false)
+	m.config.StreamPrechargeRatio = getFloat(settings, "runtime.stream_precharge_ratio", 1)
+	m.config.RequestSchemaValidation = getBool(settings, "runtime.request_schema_validation", true)
+	m.config.RequestMaxMessages = getInt(settings, "runtime.request_max_messages", 10000)
+	m.config.RequestDisallowUnknownFields = getBool(settings, "runtime.request_disallow_unknown_fields", false)
+	m.config.RoleAliases = getPairs(settings, "runtime.role_aliases", "human:user,ai:assistant,bot:assistant")
+	m.config.ExpiredKeyRetention = time.Duration(getInt(settings, "runtime.expired_key_retention_days", 30)) * 24 * time.Hour
+	m.config.PrefixCacheMinTokens = getInt(settings, "runtime.prefix_cache_min_tokens", 1024)
+	m.config.PrefixCacheTTL = time.Duration(getDuration(settings, "runtime.prefix_cache_ttl", 300)) * time.Second
+	m.config.PoolNearLimitPercent = getInt(settings, "runtime.pool_near_limit_percent", 10)
+	m.config.PoolMinHealthyCredentials = getInt(settings, "runtime.pool_min_healthy_credentials", 2)
+	m.config.PoolCredentialGracePeriod = time.Duration(getDuration(settings, "runtime.pool_credential_grace_period", 600)) * time.Second
+
+	m.config.EchoModelEnabled = getBool(settings, "runtime.echo_model_enabled", false)
+	m.config.EchoModelLatency = time.Duration(getDuration(settings, "runtime.echo_model_latency_ms", 0)) * time.Millisecond
+	m.config.EchoModelTransform = getString(settings, "runtime.echo_model_transform", "")
+	m.config.EchoModelCompletionTokens = getInt(settings, "runtime.echo_model_completion_tokens", 0)
+
+	m.config.AlertWebhookURL = getString(settings, "runtime.alert_webhook_url", "")
+	m.config.PayloadAlertBytes = getInt64(settings, "runtime.payload_alert_bytes", 0)
+
+	m.config.Anomaly = AnomalyPolicy{
+		Enabled:         getBool(settings, "anomaly.enabled", false),
+		Window:          time.Duration(getInt(settings, "anomaly.window_minutes", 60)) * time.Minute,
+		BaselineWindows: getInt(settings, "anomaly.baseline_windows", 24),
+		SpikeRatio:      getFloat(settings, "anomaly.spike_ratio", 5),
+		MinRequests:     getInt64(settings, "anomaly.min_requests", 100),
+		MinSpend:        getInt64(settings, "anomaly.min_spend", 10000),
+		AutoSuspend:     getBool(settings, "anomaly.auto_suspend", false),
+	}
 	
 	m.config.DefaultQuotaDaily = getInt64(settings, "default_quota.daily", 1000)
 	m.config.DefaultQuotaMonthly = getInt64(settings, "default_quota.monthly", 30000)
 	m.config.DefaultQuotaTotal = getInt64(settings, "default_quota.total", 100000)
+	m.config.DefaultQuotaOverdraft = getInt64(settings, "default_quota.overdraft", 0)
+	m.config.QuotaHoldTTL = time.Duration(getInt(settings, "quota_hold.ttl_seconds", 300)) * time.Second
+	m.config.QuotaHoldMaxTTL = time.Duration(getInt(settings, "quota_hold.max_ttl_seconds", 3600)) * time.Second
 	
+	m.config.SignInBaseQuota = getInt64(settings, "sign_in.base_quota", 1000)
+	m.config.SignInStreakBonus = getInt(settings, "sign_in.streak_bonus_percent", 10)
+	m.config.SignInStreakCap = getInt(settings, "sign_in.streak_cap_days", 7)
+	m.config.SignInMaxQuota = getInt64(settings, "sign_in.max_quota", 2000)
+
+	m.config.AuthRateLimitPerMinute = getInt(settings, "auth_rate_limit.per_minute", 20)
+	m.config.AuthMaxLoginFailures = getInt(settings, "auth_rate_limit.max_login_failures", 5)
+	m.config.AuthLockout = time.Duration(getDuration(settings, "auth_rate_limit.lockout_seconds", 60)) * time.Second
+	m.config.AuthMaxLockout = time.Duration(getDuration(settings, "auth_rate_limit.max_lockout_seconds", 3600)) * time.Second
+
 	m.config.DefaultRateLimitPerMinute = getInt(settings, "default_rate_limit.per_minute", 60)
 	m.config.DefaultRateLimitPerHour = getInt(settings, "default_rate_limit.per_hour", 1000)
 	m.config.DefaultRateLimitPerDay = getInt(settings, "default_rate_limit.per_day", 10000)
-
-	return nil
 }
 
 // Config 方法
@@ -175,6 +366,34 @@ func (c *Config) GetSemanticThreshold() float64 {
 	return c.SemanticThreshold
 }
 
+// IsCacheKeyNormalizeEnabled 生成缓存键前是否规范化请求
+func (c *Config) IsCacheKeyNormalizeEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CacheKeyNormalize
+}
+
+// GetCacheStaleWhileRevalidate 获取缓存 stale-while-revalidate 窗口
+func (c *Config) GetCacheStaleWhileRevalidate() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CacheStaleWhileRevalidate
+}
+
+// GetCacheWarmingPolicy 获取热门缓存预热策略
+func (c *Config) GetCacheWarmingPolicy() CacheWarmingPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CacheWarming
+}
+
+// IsCacheRefreshBilled 后台刷新缓存是否向用户计费
+func (c *Config) IsCacheRefreshBilled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.CacheRefreshBilled
+}
+
 // IsEmbeddingEnabled Embedding 服务是否启用
 func (c *Config) IsEmbeddingEnabled() bool {
 	c.mu.RLock()
@@ -217,6 +436,180 @@ func (c *Config) IsLoadBalanceEnabled() bool {
 	return c.EnableLoadBalance
 }
 
+// GetStreamQuotaCheckTokens 获取流式配额检查间隔
+func (c *Config) GetStreamQuotaCheckTokens() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StreamQuotaCheckTokens
+}
+
+// GetStreamMaxDuration 获取流式响应的最长持续时间，0 表示不限制
+func (c *Config) GetStreamMaxDuration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StreamMaxDuration
+}
+
+// IsStreamFallbackEmulated 适配器不支持流式时是否以非流式调用模拟流
+func (c *Config) IsStreamFallbackEmulated() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StreamFallbackEmulate
+}
+
+// IsRefusalBilled 上游以内容策略拒绝回答的响应是否计费
+func (c *Config) IsRefusalBilled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.BillRefusals
+}
+
+// IsRoutingDiagnosticsEnabled 是否允许在响应中附带路由诊断信息
+func (c *Config) IsRoutingDiagnosticsEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RoutingDiagnostics
+}
+
+// IsDynamicTimeoutEnabled 是否按模型近期 p99 响应时间动态确定上游超时
+func (c *Config) IsDynamicTimeoutEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.DynamicTimeoutEnabled
+}
+
+// GetDynamicTimeoutBounds 获取动态上游超时的 p99 倍数和上下限
+func (c *Config) GetDynamicTimeoutBounds() (multiplier float64, floor, ceiling time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.DynamicTimeoutMultiplier, c.DynamicTimeoutMin, c.DynamicTimeoutMax
+}
+
+// IsStreamPrechargeEnabled 未单独设置的用户的流式请求是否需要按 max_tokens 预扣配额
+func (c *Config) IsStreamPrechargeEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.StreamPrecharge
+}
+
+// GetStreamPrechargeRatio 获取按 max_tokens 预扣的比例，未配置时全额预扣
+func (c *Config) GetStreamPrechargeRatio() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.StreamPrechargeRatio <= 0 {
+		return 1
+	}
+	return c.StreamPrechargeRatio
+}
+
+// GetServiceTierPriceMultiplier 获取 service_tier 对应的计费倍率，未配置或其他层级返回 1
+func (c *Config) GetServiceTierPriceMultiplier(tier string) float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var multiplier float64
+	switch tier {
+	case "flex":
+		multiplier = c.FlexTierPriceMultiplier
+	case "priority":
+		multiplier = c.PriorityTierPriceMultiplier
+	}
+	if multiplier <= 0 {
+		return 1
+	}
+	return multiplier
+}
+
+// IsRequestSchemaValidationEnabled 是否校验代理请求体
+func (c *Config) IsRequestSchemaValidationEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RequestSchemaValidation
+}
+
+// GetRequestDecodeLimits 获取读取代理请求体时的消息数上限和是否拒绝未知字段
+func (c *Config) GetRequestDecodeLimits() (maxMessages int, disallowUnknownFields bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RequestMaxMessages, c.RequestDisallowUnknownFields
+}
+
+// GetRoleAliases 获取代理请求角色名的别名映射
+func (c *Config) GetRoleAliases() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.RoleAliases
+}
+
+// GetExpiredKeyRetention 获取过期 API Key 的保留时长，0 表示不清理
+func (c *Config) GetExpiredKeyRetention() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ExpiredKeyRetention
+}
+
+// GetPrefixCacheOptions 获取对话前缀缓存的最小 token 数和过期时长
+func (c *Config) GetPrefixCacheOptions() (minTokens int, ttl time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PrefixCacheMinTokens, c.PrefixCacheTTL
+}
+
+// GetPoolNearLimitPercent 获取账号池凭据视为接近速率上限的剩余百分比
+func (c *Config) GetPoolNearLimitPercent() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PoolNearLimitPercent
+}
+
+// GetPoolMinHealthyCredentials 获取账号池健康凭据数告警阈值（0 表示不告警）
+func (c *Config) GetPoolMinHealthyCredentials() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PoolMinHealthyCredentials
+}
+
+// GetPoolCredentialGracePeriod 获取过期凭据刷新失败后的宽限期
+func (c *Config) GetPoolCredentialGracePeriod() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PoolCredentialGracePeriod
+}
+
+// IsEchoModelEnabled prism-echo 测试模型是否启用
+func (c *Config) IsEchoModelEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.EchoModelEnabled
+}
+
+// GetEchoModelOptions 获取 prism-echo 的模拟延迟、内容变换和输出 token 数
+func (c *Config) GetEchoModelOptions() (latency time.Duration, transform string, completionTokens int) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.EchoModelLatency, c.EchoModelTransform, c.EchoModelCompletionTokens
+}
+
+// GetAlertWebhookURL 获取告警 Webhook 地址
+func (c *Config) GetAlertWebhookURL() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AlertWebhookURL
+}
+
+// GetPayloadAlertBytes 获取单次请求体积告警阈值（0 表示不告警）
+func (c *Config) GetPayloadAlertBytes() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PayloadAlertBytes
+}
+
+// GetAnomalyPolicy 获取用量异常检测策略
+func (c *Config) GetAnomalyPolicy() AnomalyPolicy {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.Anomaly
+}
+
 // GetDefaultQuota 获取默认配额
 func (c *Config) GetDefaultQuota() (daily, monthly, total int64) {
 	c.mu.RLock()
@@ -224,6 +617,44 @@ func (c *Config) GetDefaultQuota() (daily, monthly, total int64) {
 	return c.DefaultQuotaDaily, c.DefaultQuotaMonthly, c.DefaultQuotaTotal
 }
 
+// GetDefaultQuotaOverdraft 获取默认透支额度
+func (c *Config) GetDefaultQuotaOverdraft() int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.DefaultQuotaOverdraft
+}
+
+// GetQuotaHoldTTL 获取配额预留的默认有效期与最长有效期，未配置时分别为 5 分钟和 1 小时
+func (c *Config) GetQuotaHoldTTL() (ttl, maxTTL time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ttl, maxTTL = c.QuotaHoldTTL, c.QuotaHoldMaxTTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if maxTTL <= 0 {
+		maxTTL = time.Hour
+	}
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl, maxTTL
+}
+
+// GetSignInPolicy 获取签到奖励策略
+func (c *Config) GetSignInPolicy() (baseQuota int64, streakBonusPercent, streakCapDays int, maxQuota int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.SignInBaseQuota, c.SignInStreakBonus, c.SignInStreakCap, c.SignInMaxQuota
+}
+
+// GetAuthRateLimit 获取登录、注册接口的限流与锁定策略
+func (c *Config) GetAuthRateLimit() (perMinute, maxLoginFailures int, lockout, maxLockout time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.AuthRateLimitPerMinute, c.AuthMaxLoginFailures, c.AuthLockout, c.AuthMaxLockout
+}
+
 // GetDefaultRateLimit 获取默认速率限制
 func (c *Config) GetDefaultRateLimit() (perMinute, perHour, perDay int) {
 	c.mu.RLock()
@@ -239,6 +670,30 @@ func getString(settings map[string]string, key, defaultValue string) string {
 	return defaultValue
 }
 
+// getList 解析逗号分隔的设置项，忽略空项
+func getList(settings map[string]string, key string) []string {
+	var items []string
+	for _, item := range strings.Split(settings[key], ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getPairs 解析逗号分隔的 key:value 设置项，忽略格式不正确的项
+func getPairs(settings map[string]string, key, defaultValue string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(getString(settings, key, defaultValue), ",") {
+		k, v, ok := strings.Cut(item, ":")
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.ToLower(strings.TrimSpace(v))
+		if ok && k != "" && v != "" {
+			pairs[k] = v
+		}
+	}
+	return pairs
+}
+
 func getInt(settings map[string]string, key string, defaultValue int) int {
 	if val, ok := settings[key]; ok {
 		var result int
@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AttrSampleRate 本地根 span 上的采样比例属性，设置后取代全局采样比例（如按请求选中的上游配置设置）
+const AttrSampleRate = "prism.trace.sample_rate"
+
+// maxPendingTraces 同时等待采样决定的链路数上限，超出后新链路的子 span 直接丢弃
+const maxPendingTraces = 10000
+
+// tailSampler 在本地根 span 结束时决定是否导出整条链路
+// 采样比例和请求是否失败都要到请求结束才能确定，因此子 span 先缓存到根 span 结束；子 span 须先于根 span 结束
+type tailSampler struct {
+	next      sdktrace.SpanProcessor
+	rate      float64
+	errorRate float64
+
+	mu      sync.Mutex
+	pending map[trace.TraceID][]sdktrace.ReadOnlySpan
+}
+
+func newTailSampler(next sdktrace.SpanProcessor, rate, errorRate float64) *tailSampler {
+	return &tailSampler{
+		next:      next,
+		rate:      rate,
+		errorRate: errorRate,
+		pending:   make(map[trace.TraceID][]sdktrace.ReadOnlySpan),
+	}
+}
+
+func (t *tailSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	t.next.OnStart(parent, s)
+}
+
+func (t *tailSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	traceID := s.SpanContext().TraceID()
+	parent := s.Parent()
+	t.mu.Lock()
+	if parent.IsValid() && !parent.IsRemote() {
+		if _, ok := t.pending[traceID]; ok || len(t.pending) < maxPendingTraces {
+			t.pending[traceID] = append(t.pending[traceID], s)
+		}
+		t.mu.Unlock()
+		return
+	}
+	children := t.pending[traceID]
+	delete(t.pending, traceID)
+	t.mu.Unlock()
+
+	if !t.keep(s) {
+		return
+	}
+	for _, child := range children {
+		t.next.OnEnd(child)
+	}
+	t.next.OnEnd(s)
+}
+
+// keep 上游已采样的链路始终导出；否则按根 span 的采样比例（失败时取失败采样比例，不低于正常比例）按 trace ID 决定
+func (t *tailSampler) keep(root sdktrace.ReadOnlySpan) bool {
+	if root.Parent().IsRemote() && root.Parent().IsSampled() {
+		return true
+	}
+	rate := t.rate
+	for _, attr := range root.Attributes() {
+		if string(attr.Key) == AttrSampleRate {
+			rate = attr.Value.AsFloat64()
+		}
+	}
+	if root.Status().Code == codes.Error && t.errorRate > rate {
+		rate = t.errorRate
+	}
+	result := sdktrace.TraceIDRatioBased(rate).ShouldSample(sdktrace.SamplingParameters{TraceID: root.SpanContext().TraceID()})
+	return result.Decision == sdktrace.RecordAndSample
+}
+
+func (t *tailSampler) Shutdown(ctx context.Context) error {
+	return t.next.Shutdown(ctx)
+}
+
+func (t *tailSampler) ForceFlush(ctx context.Context) error {
+	return t.next.ForceFlush(ctx)
+}
@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options 链路追踪配置
+type Options struct {
+	// Endpoint OTLP/HTTP 接收地址（如 http://localhost:4318），为空时不启用追踪
+	Endpoint string
+	// SampleRate 新请求的采样比例（0-1），上游已采样的请求始终随之采样
+	SampleRate float64
+	// ErrorSampleRate 失败请求的采样比例（0-1），低于 SampleRate 时按 SampleRate
+	ErrorSampleRate float64
+	ServiceName     string
+}
+
+// Setup 按配置创建 OTLP 导出的 TracerProvider 并设为全局，返回关闭函数（关闭时导出剩余的 span）
+// 未配置 Endpoint 时保持全局的空实现，返回的关闭函数什么也不做
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(opts.Endpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := NewProvider(sdktrace.NewBatchSpanProcessor(exporter), opts.SampleRate, opts.ErrorSampleRate, opts.ServiceName)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// NewProvider 创建按比例采样的 TracerProvider，测试中可传入内存导出器
+// 新请求全部记录，在请求结束时按根 span 的采样比例（AttrSampleRate，默认 sampleRate）或失败采样比例决定是否导出
+// 上游未采样的请求不记录
+func NewProvider(processor sdktrace.SpanProcessor, sampleRate, errorSampleRate float64, serviceName string) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(newTailSampler(processor, sampleRate, errorSampleRate)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+}
+
+// EndSpan 结束 span，err 不为 nil 时记录错误并把状态标记为失败
+func EndSpan(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}